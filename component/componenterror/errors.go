@@ -19,6 +19,7 @@ package componenterror
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"go.opentelemetry.io/collector/consumer/consumererror"
@@ -35,7 +36,12 @@ var (
 	ErrNilNextConsumer = errors.New("nil nextConsumer")
 )
 
-// CombineErrors converts a list of errors into one error.
+// CombineErrors converts a list of errors into one error: nil if there are
+// none, the error itself if there is only one, and else a *CombinedError,
+// wrapped with consumererror.Permanent if any of the errors is permanent. The
+// errors of the *CombinedError errors in the list, including those wrapped
+// with consumererror.Permanent, are combined instead of the *CombinedError
+// itself, in which case the result is still permanent.
 func CombineErrors(errs []error) error {
 	numErrors := len(errs)
 	if numErrors == 0 {
@@ -47,17 +53,77 @@ func CombineErrors(errs []error) error {
 		return errs[0]
 	}
 
-	errMsgs := make([]string, 0, numErrors)
+	combined := make([]error, 0, numErrors)
 	permanent := false
 	for _, err := range errs {
 		if !permanent && consumererror.IsPermanent(err) {
 			permanent = true
 		}
-		errMsgs = append(errMsgs, err.Error())
+		var c *CombinedError
+		if errors.As(err, &c) {
+			combined = append(combined, c.errs...)
+			continue
+		}
+		combined = append(combined, err)
 	}
-	err := fmt.Errorf("[%s]", strings.Join(errMsgs, "; "))
+	var err error = &CombinedError{errs: combined}
 	if permanent {
 		err = consumererror.Permanent(err)
 	}
 	return err
 }
+
+// CombinedError is an error combining several errors, returned by
+// CombineErrors. Its message lists the messages of the errors sorted, and
+// repeated messages once followed by their count, so that it does not depend
+// on the order the errors were collected in. errors.Is and errors.As match
+// any of the errors.
+type CombinedError struct {
+	errs []error
+}
+
+// Errors returns the combined errors, in the order they were passed to
+// CombineErrors.
+func (e *CombinedError) Errors() []error {
+	return append([]error(nil), e.errs...)
+}
+
+func (e *CombinedError) Error() string {
+	counts := make(map[string]int, len(e.errs))
+	errMsgs := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		errMsg := err.Error()
+		if counts[errMsg] == 0 {
+			errMsgs = append(errMsgs, errMsg)
+		}
+		counts[errMsg]++
+	}
+	sort.Strings(errMsgs)
+	for i, errMsg := range errMsgs {
+		if count := counts[errMsg]; count > 1 {
+			errMsgs[i] = fmt.Sprintf("%s (x%d)", errMsg, count)
+		}
+	}
+	return "[" + strings.Join(errMsgs, "; ") + "]"
+}
+
+// Is reports whether any of the combined errors matches the target.
+func (e *CombinedError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the combined errors that matches the target, and if
+// so, sets the target to it and returns true.
+func (e *CombinedError) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -15,9 +15,14 @@
 package componenterror_test
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"go.opentelemetry.io/collector/component/componenterror"
 	"go.opentelemetry.io/collector/consumer/consumererror"
 )
@@ -44,14 +49,25 @@ func TestCombineErrors(t *testing.T) {
 				fmt.Errorf("foo"),
 				fmt.Errorf("bar"),
 			},
-			expected: "[foo; bar]",
+			expected: "[bar; foo]",
+		},
+		{
+			errors: []error{
+				fmt.Errorf("foo"),
+				fmt.Errorf("bar"),
+				fmt.Errorf("foo"),
+				fmt.Errorf("baz"),
+				fmt.Errorf("foo"),
+			},
+			expected: "[bar; baz; foo (x3)]",
 		},
 		{
 			errors: []error{
 				fmt.Errorf("foo"),
 				fmt.Errorf("bar"),
 				consumererror.Permanent(fmt.Errorf("permanent"))},
-			expected: "Permanent error: [foo; bar; Permanent error: permanent]",
+			expected:          "Permanent error: [Permanent error: permanent; bar; foo]",
+			expectedPermanent: true,
 		},
 	}
 
@@ -68,3 +84,65 @@ func TestCombineErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestCombineErrorsDeterministic(t *testing.T) {
+	errs := []error{errors.New("c"), errors.New("a"), errors.New("b"), errors.New("a")}
+	reversed := []error{errs[3], errs[2], errs[1], errs[0]}
+	assert.Equal(t, "[a (x2); b; c]", componenterror.CombineErrors(errs).Error())
+	assert.Equal(t, "[a (x2); b; c]", componenterror.CombineErrors(reversed).Error())
+}
+
+func TestCombinedErrorErrors(t *testing.T) {
+	errs := []error{errors.New("foo"), errors.New("bar"), errors.New("foo")}
+	var combined *componenterror.CombinedError
+	require.True(t, errors.As(componenterror.CombineErrors(errs), &combined))
+	assert.Equal(t, errs, combined.Errors())
+
+	// the errors are copied.
+	combined.Errors()[0] = nil
+	errs[1] = nil
+	assert.Equal(t, "[bar; foo (x2)]", combined.Error())
+}
+
+func TestCombineErrorsFlattensPermanentCombinedError(t *testing.T) {
+	errs := []error{errors.New("foo"), errors.New("bar")}
+	inner := componenterror.CombineErrors(append(errs, consumererror.Permanent(errors.New("permanent"))))
+	require.True(t, consumererror.IsPermanent(inner))
+
+	got := componenterror.CombineErrors([]error{inner, errors.New("baz")})
+	assert.True(t, consumererror.IsPermanent(got))
+	assert.Equal(t, "Permanent error: [Permanent error: permanent; bar; baz; foo]", got.Error())
+	var combined *componenterror.CombinedError
+	require.True(t, errors.As(got, &combined))
+	assert.Len(t, combined.Errors(), 4)
+}
+
+func TestCombinedErrorIsAs(t *testing.T) {
+	pathErr := &os.PathError{Op: "open", Path: "/proc/stat", Err: os.ErrNotExist}
+	errs := []error{
+		errors.New("foo"),
+		fmt.Errorf("failed to close: %w", componenterror.ErrAlreadyStopped),
+		pathErr,
+	}
+	for _, err := range []error{
+		componenterror.CombineErrors(errs),
+		componenterror.CombineErrors(append(errs, consumererror.Permanent(errors.New("permanent")))),
+		fmt.Errorf("shutdown: %w", componenterror.CombineErrors(errs)),
+	} {
+		assert.True(t, errors.Is(err, componenterror.ErrAlreadyStopped), err)
+		assert.True(t, errors.Is(err, os.ErrNotExist), err)
+		assert.False(t, errors.Is(err, componenterror.ErrAlreadyStarted), err)
+
+		var target *os.PathError
+		require.True(t, errors.As(err, &target), err)
+		assert.Same(t, pathErr, target)
+		var combined *componenterror.CombinedError
+		assert.True(t, errors.As(err, &combined), err)
+		var validationErr *testValidationError
+		assert.False(t, errors.As(err, &validationErr), err)
+	}
+}
+
+type testValidationError struct{}
+
+func (*testValidationError) Error() string { return "invalid" }
@@ -0,0 +1,50 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configauth
+
+import (
+	"net/http"
+
+	"google.golang.org/grpc/credentials"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// ClientAuthenticator is an extension that authenticates the outgoing
+// requests of components calling remote endpoints. It is either a
+// HTTPClientAuthenticator or a GRPCClientAuthenticator, or both.
+type ClientAuthenticator interface {
+	component.ServiceExtension
+}
+
+// HTTPClientAuthenticator is a ClientAuthenticator that authenticates HTTP
+// requests.
+type HTTPClientAuthenticator interface {
+	ClientAuthenticator
+
+	// RoundTripper returns a http.RoundTripper adding the authentication data
+	// to the requests, before sending them with the base round tripper.
+	RoundTripper(base http.RoundTripper) (http.RoundTripper, error)
+}
+
+// GRPCClientAuthenticator is a ClientAuthenticator that authenticates gRPC
+// calls.
+type GRPCClientAuthenticator interface {
+	ClientAuthenticator
+
+	// PerRPCCredentials returns the credentials attached to each of the gRPC
+	// calls.
+	PerRPCCredentials() (credentials.PerRPCCredentials, error)
+}
@@ -33,6 +33,11 @@ func (p permanent) Error() string {
 	return "Permanent error: " + p.err.Error()
 }
 
+// Unwrap returns the wrapped error.
+func (p permanent) Unwrap() error {
+	return p.err
+}
+
 // IsPermanent checks if an error was wrapped with the Permanent function, that
 // is used to indicate that a given error will always be returned in the case
 // that its sources receives the same input.
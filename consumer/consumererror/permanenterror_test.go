@@ -33,3 +33,8 @@ func TestIsPermanent_NilError(t *testing.T) {
 	var err error
 	require.False(t, IsPermanent(err))
 }
+
+func TestPermanent_Unwrap(t *testing.T) {
+	err := errors.New("testError")
+	require.True(t, errors.Is(Permanent(err), err))
+}
@@ -73,6 +73,14 @@ func Configure(level configtelemetry.Level) (views []*view.View) {
 	return views
 }
 
+// Enabled reports whether the observability metrics are recorded, which is
+// not the case when the level set with Configure is configtelemetry.LevelNone.
+// It is cheap enough to be checked on every operation, so that components can
+// skip preparing the observability signals of an operation when it is not.
+func Enabled() bool {
+	return gLevel != configtelemetry.LevelNone
+}
+
 func buildComponentPrefix(componentPrefix, configType string) string {
 	if !strings.HasSuffix(componentPrefix, nameSep) {
 		componentPrefix += nameSep
@@ -103,6 +111,16 @@ func AllViews() (views []*view.View) {
 	measures = []*stats.Int64Measure{
 		mScraperScrapedMetricPoints,
 		mScraperErroredMetricPoints,
+		mScraperFilteredScrapes,
+		mScraperNotForwardedScrapes,
+		mScraperFilteredMetricPoints,
+		mScraperSkippedTicks,
+		mScraperMemoryPressureSkippedTicks,
+		mScraperConsumerShutdownSkippedTicks,
+		mScraperEvictedSeries,
+		mScraperScrapeWallTime,
+		mScraperScrapeCPUTime,
+		mScraperDryRunMetricPoints,
 	}
 	tagKeys = []tag.Key{tagKeyReceiver, tagKeyScraper}
 	views = append(views, genViews(measures, tagKeys, view.Sum())...)
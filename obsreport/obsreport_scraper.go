@@ -16,6 +16,7 @@ package obsreport
 
 import (
 	"context"
+	"time"
 
 	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
@@ -36,6 +37,39 @@ const (
 	// ErroredMetricPointsKey used to identify metric points errored (i.e.
 	// unable to be scraped) by the Collector.
 	ErroredMetricPointsKey = "errored_metric_points"
+	// FilteredScrapesKey used to identify scrapes that were skipped by the
+	// Collector because a scrape predicate did not hold.
+	FilteredScrapesKey = "filtered_scrapes"
+	// NotForwardedScrapesKey used to identify successful scrapes whose
+	// metrics were not passed to the next consumer by the Collector.
+	NotForwardedScrapesKey = "not_forwarded_scrapes"
+	// FilteredMetricPointsKey used to identify scraped metric points that
+	// were dropped by the Collector because of their metric name.
+	FilteredMetricPointsKey = "filtered_metric_points"
+	// SkippedTicksKey used to identify the ticks of a receiver that were
+	// skipped by the Collector because they were missed during a long scrape.
+	SkippedTicksKey = "skipped_ticks"
+	// MemoryPressureSkippedTicksKey used to identify the ticks of a receiver
+	// that were skipped by the Collector because it was under memory
+	// pressure.
+	MemoryPressureSkippedTicksKey = "memory_pressure_skipped_ticks"
+	// ConsumerShutdownSkippedTicksKey used to identify the ticks of a
+	// receiver that were skipped by the Collector because the next consumer
+	// of the receiver was shut down.
+	ConsumerShutdownSkippedTicksKey = "consumer_shutdown_skipped_ticks"
+	// EvictedSeriesKey used to identify the series that were forgotten by
+	// the Collector because a scraper tracked more series than allowed.
+	EvictedSeriesKey = "evicted_series"
+	// ScrapeWallTimeKey used to identify the time the scrapes of a scraper
+	// took.
+	ScrapeWallTimeKey = "scrape_wall_time"
+	// ScrapeCPUTimeKey used to identify the CPU time consumed by the scrapes
+	// of a scraper.
+	ScrapeCPUTimeKey = "scrape_cpu_time"
+	// DryRunMetricPointsKey used to identify scraped metric points that were
+	// dropped by the Collector instead of being passed to the next consumer,
+	// because of a dry run.
+	DryRunMetricPointsKey = "dry_run_metric_points"
 )
 
 const (
@@ -54,6 +88,46 @@ var (
 		scraperPrefix+ErroredMetricPointsKey,
 		"Number of metric points that were unable to be scraped.",
 		stats.UnitDimensionless)
+	mScraperFilteredScrapes = stats.Int64(
+		scraperPrefix+FilteredScrapesKey,
+		"Number of scrapes that were skipped because a scrape predicate did not hold.",
+		stats.UnitDimensionless)
+	mScraperNotForwardedScrapes = stats.Int64(
+		scraperPrefix+NotForwardedScrapesKey,
+		"Number of successful scrapes whose metrics were not forwarded to the next consumer.",
+		stats.UnitDimensionless)
+	mScraperFilteredMetricPoints = stats.Int64(
+		scraperPrefix+FilteredMetricPointsKey,
+		"Number of scraped metric points that were dropped because of their metric name.",
+		stats.UnitDimensionless)
+	mScraperSkippedTicks = stats.Int64(
+		scraperPrefix+SkippedTicksKey,
+		"Number of ticks that were skipped because they were missed during a long scrape.",
+		stats.UnitDimensionless)
+	mScraperMemoryPressureSkippedTicks = stats.Int64(
+		scraperPrefix+MemoryPressureSkippedTicksKey,
+		"Number of ticks that were skipped because the collector was under memory pressure.",
+		stats.UnitDimensionless)
+	mScraperConsumerShutdownSkippedTicks = stats.Int64(
+		scraperPrefix+ConsumerShutdownSkippedTicksKey,
+		"Number of ticks that were skipped because the next consumer was shut down.",
+		stats.UnitDimensionless)
+	mScraperEvictedSeries = stats.Int64(
+		scraperPrefix+EvictedSeriesKey,
+		"Number of tracked series that were forgotten because a scraper tracked more series than allowed.",
+		stats.UnitDimensionless)
+	mScraperScrapeWallTime = stats.Int64(
+		scraperPrefix+ScrapeWallTimeKey,
+		"Time the scrapes took, in microseconds.",
+		"us")
+	mScraperScrapeCPUTime = stats.Int64(
+		scraperPrefix+ScrapeCPUTimeKey,
+		"CPU time consumed by the scrapes, in microseconds.",
+		"us")
+	mScraperDryRunMetricPoints = stats.Int64(
+		scraperPrefix+DryRunMetricPointsKey,
+		"Number of scraped metric points that were dropped instead of being passed to the next consumer because of a dry run.",
+		stats.UnitDimensionless)
 )
 
 // ScraperContext adds the keys used when recording observability metrics to
@@ -130,3 +204,92 @@ func EndMetricsScrapeOp(
 
 	span.End()
 }
+
+// RecordMetricsScrapeFiltered records that a scrape was skipped because a
+// scrape predicate did not hold. The scraperCtx should be created with
+// ScraperContext.
+func RecordMetricsScrapeFiltered(scraperCtx context.Context) {
+	if gLevel != configtelemetry.LevelNone {
+		stats.Record(scraperCtx, mScraperFilteredScrapes.M(1))
+	}
+}
+
+// RecordMetricsScrapeNotForwarded records that the metrics of a successful
+// scrape were not forwarded to the next consumer. The scraperCtx should be
+// created with ScraperContext.
+func RecordMetricsScrapeNotForwarded(scraperCtx context.Context) {
+	if gLevel != configtelemetry.LevelNone {
+		stats.Record(scraperCtx, mScraperNotForwardedScrapes.M(1))
+	}
+}
+
+// RecordMetricsScrapeFilteredPoints records the number of scraped metric
+// points that were dropped because of their metric name. The scraperCtx
+// should be created with ScraperContext.
+func RecordMetricsScrapeFilteredPoints(scraperCtx context.Context, numFilteredPoints int) {
+	if gLevel != configtelemetry.LevelNone {
+		stats.Record(scraperCtx, mScraperFilteredMetricPoints.M(int64(numFilteredPoints)))
+	}
+}
+
+// RecordMetricsScrapeSkippedTicks records the number of ticks of a receiver
+// that were skipped because they were missed during a long scrape. The
+// receiverCtx should be created with ReceiverContext.
+func RecordMetricsScrapeSkippedTicks(receiverCtx context.Context, numSkippedTicks int) {
+	if gLevel != configtelemetry.LevelNone {
+		stats.Record(receiverCtx, mScraperSkippedTicks.M(int64(numSkippedTicks)))
+	}
+}
+
+// RecordMetricsScrapeMemoryPressureSkippedTicks records that a tick of a
+// receiver was skipped because the collector was under memory pressure. The
+// receiverCtx should be created with ReceiverContext.
+func RecordMetricsScrapeMemoryPressureSkippedTicks(receiverCtx context.Context) {
+	if gLevel != configtelemetry.LevelNone {
+		stats.Record(receiverCtx, mScraperMemoryPressureSkippedTicks.M(1))
+	}
+}
+
+// RecordMetricsScrapeConsumerShutdownSkippedTicks records that a tick of a
+// receiver was skipped because its next consumer was shut down. The
+// receiverCtx should be created with ReceiverContext.
+func RecordMetricsScrapeConsumerShutdownSkippedTicks(receiverCtx context.Context) {
+	if gLevel != configtelemetry.LevelNone {
+		stats.Record(receiverCtx, mScraperConsumerShutdownSkippedTicks.M(1))
+	}
+}
+
+// RecordMetricsScrapeEvictedSeries records the number of series tracked by a
+// scraper that were forgotten because it tracked more series than allowed.
+// The scraperCtx should be created with ScraperContext.
+func RecordMetricsScrapeEvictedSeries(scraperCtx context.Context, numEvictedSeries int) {
+	if gLevel != configtelemetry.LevelNone {
+		stats.Record(scraperCtx, mScraperEvictedSeries.M(int64(numEvictedSeries)))
+	}
+}
+
+// RecordMetricsScrapeWallTime records the time a scrape took. The scraperCtx
+// should be created with ScraperContext.
+func RecordMetricsScrapeWallTime(scraperCtx context.Context, wallTime time.Duration) {
+	if gLevel != configtelemetry.LevelNone {
+		stats.Record(scraperCtx, mScraperScrapeWallTime.M(wallTime.Microseconds()))
+	}
+}
+
+// RecordMetricsScrapeCPUTime records the CPU time a scrape consumed. The
+// scraperCtx should be created with ScraperContext.
+func RecordMetricsScrapeCPUTime(scraperCtx context.Context, cpuTime time.Duration) {
+	if gLevel != configtelemetry.LevelNone {
+		stats.Record(scraperCtx, mScraperScrapeCPUTime.M(cpuTime.Microseconds()))
+	}
+}
+
+// RecordMetricsScrapeDryRunPoints records the number of scraped metric points
+// that were dropped instead of being passed to the next consumer because of a
+// dry run. The ctx should be created with ReceiverContext, or with
+// ScraperContext if the metric points were scraped by a single scraper.
+func RecordMetricsScrapeDryRunPoints(ctx context.Context, numDryRunPoints int) {
+	if gLevel != configtelemetry.LevelNone {
+		stats.Record(ctx, mScraperDryRunMetricPoints.M(int64(numDryRunPoints)))
+	}
+}
@@ -83,6 +83,7 @@ func TestConfigure(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			gotViews := obsreport.Configure(tt.level)
 			assert.Equal(t, tt.wantViews, gotViews)
+			assert.Equal(t, tt.level != configtelemetry.LevelNone, obsreport.Enabled())
 		})
 	}
 }
@@ -18,6 +18,7 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.opencensus.io/stats/view"
@@ -55,6 +56,18 @@ func SetupRecordedMetricsTest() (func(), error) {
 	}, err
 }
 
+// SetupRecordedMetrics does setup the testing environment to check the metrics recorded by receivers, scrapers, processors or exporters
+// in the given test. The views start empty, even if a previous test left them registered, and are unregistered when the test finishes.
+func SetupRecordedMetrics(t *testing.T) {
+	views := obsreport.Configure(configtelemetry.LevelNormal)
+	// Unregistering the views drops the values recorded by previous tests.
+	view.Unregister(views...)
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() {
+		view.Unregister(views...)
+	})
+}
+
 // CheckExporterTracesViews checks that for the current exported values for trace exporter views match given values.
 // When this function is called it is required to also call SetupRecordedMetricsTest as first thing.
 func CheckExporterTracesViews(t *testing.T, exporter string, acceptedSpans, droppedSpans int64) {
@@ -138,6 +151,69 @@ func CheckScraperMetricsViews(t *testing.T, receiver, scraper string, scrapedMet
 	CheckValueForView(t, scraperTags, erroredMetricPoints, "scraper/errored_metric_points")
 }
 
+// CheckScraperFilteredScrapesView checks that for the current exported value for the filtered scrapes view matches the given value.
+// When this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckScraperFilteredScrapesView(t *testing.T, receiver, scraper string, filteredScrapes int64) {
+	scraperTags := tagsForScraperView(receiver, scraper)
+	CheckValueForView(t, scraperTags, filteredScrapes, "scraper/filtered_scrapes")
+}
+
+// CheckScraperNotForwardedScrapesView checks that for the current exported value for the not forwarded scrapes view matches the given value.
+// When this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckScraperNotForwardedScrapesView(t *testing.T, receiver, scraper string, notForwardedScrapes int64) {
+	scraperTags := tagsForScraperView(receiver, scraper)
+	CheckValueForView(t, scraperTags, notForwardedScrapes, "scraper/not_forwarded_scrapes")
+}
+
+// CheckScraperFilteredMetricPointsView checks that for the current exported value for the filtered metric points view matches the given value.
+// When this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckScraperFilteredMetricPointsView(t *testing.T, receiver, scraper string, filteredMetricPoints int64) {
+	scraperTags := tagsForScraperView(receiver, scraper)
+	CheckValueForView(t, scraperTags, filteredMetricPoints, "scraper/filtered_metric_points")
+}
+
+// CheckScraperSkippedTicksView checks that for the current exported value for the skipped ticks view of the receiver matches the given value.
+// When this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckScraperSkippedTicksView(t *testing.T, receiver string, skippedTicks int64) {
+	CheckValueForView(t, tagsForScraperView(receiver, ""), skippedTicks, "scraper/skipped_ticks")
+}
+
+// CheckScraperMemoryPressureSkippedTicksView checks that for the current exported value for the memory pressure skipped ticks view of the receiver matches the given value.
+// When this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckScraperMemoryPressureSkippedTicksView(t *testing.T, receiver string, skippedTicks int64) {
+	CheckValueForView(t, tagsForScraperView(receiver, ""), skippedTicks, "scraper/memory_pressure_skipped_ticks")
+}
+
+// CheckScraperConsumerShutdownSkippedTicksView checks that for the current exported value for the consumer shutdown skipped ticks view of the receiver matches the given value.
+// When this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckScraperConsumerShutdownSkippedTicksView(t *testing.T, receiver string, skippedTicks int64) {
+	CheckValueForView(t, tagsForScraperView(receiver, ""), skippedTicks, "scraper/consumer_shutdown_skipped_ticks")
+}
+
+// CheckScraperDryRunMetricPointsView checks that for the current exported value for the dry run metric points view matches the given value.
+// The scraper is empty for the metric points of a receiver in dry run.
+// When this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckScraperDryRunMetricPointsView(t *testing.T, receiver, scraper string, dryRunPoints int64) {
+	CheckValueForView(t, tagsForScraperView(receiver, scraper), dryRunPoints, "scraper/dry_run_metric_points")
+}
+
+// CheckScraperEvictedSeriesView checks that for the current exported value for the evicted series view matches the given value.
+// When this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckScraperEvictedSeriesView(t *testing.T, receiver, scraper string, evictedSeries int64) {
+	scraperTags := tagsForScraperView(receiver, scraper)
+	CheckValueForView(t, scraperTags, evictedSeries, "scraper/evicted_series")
+}
+
+// ScraperScrapeTimeValues returns the current exported values for the scrape wall time and CPU time views of the scraper,
+// zero when nothing was recorded. They are not checked against given values, since they depend on the machine running the test.
+// When this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func ScraperScrapeTimeValues(t *testing.T, receiver, scraper string) (wallTime, cpuTime time.Duration) {
+	scraperTags := tagsForScraperView(receiver, scraper)
+	wallTime = time.Duration(valueForView(t, scraperTags, "scraper/scrape_wall_time")) * time.Microsecond
+	cpuTime = time.Duration(valueForView(t, scraperTags, "scraper/scrape_cpu_time")) * time.Microsecond
+	return wallTime, cpuTime
+}
+
 // CheckValueForView checks that for the current exported value in the view with the given name
 // for {LegacyTagKeyReceiver: receiverName} is equal to "value".
 func CheckValueForView(t *testing.T, wantTags []tag.Tag, value int64, vName string) {
@@ -160,6 +236,23 @@ func CheckValueForView(t *testing.T, wantTags []tag.Tag, value int64, vName stri
 	require.Failf(t, "could not find tags", "wantTags: %s in rows %v", wantTags, rows)
 }
 
+// valueForView returns the current exported value in the view with the given name for the given tags,
+// zero if there is none.
+func valueForView(t *testing.T, wantTags []tag.Tag, vName string) int64 {
+	sortTags(wantTags)
+
+	rows, err := view.RetrieveData(vName)
+	require.NoError(t, err)
+
+	for _, row := range rows {
+		sortTags(row.Tags)
+		if reflect.DeepEqual(wantTags, row.Tags) {
+			return int64(row.Data.(*view.SumData).Value)
+		}
+	}
+	return 0
+}
+
 // tagsForReceiverView returns the tags that are needed for the receiver views.
 func tagsForReceiverView(receiver, transport string) []tag.Tag {
 	tags := make([]tag.Tag, 0, 2)
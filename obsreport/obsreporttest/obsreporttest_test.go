@@ -17,6 +17,7 @@ package obsreporttest_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -29,6 +30,7 @@ import (
 const (
 	exporter  = "fakeExporter"
 	receiver  = "fakeReicever"
+	scraper   = "fakeScraper"
 	transport = "fakeTransport"
 	format    = "fakeFormat"
 )
@@ -119,3 +121,40 @@ func TestCheckExporterLogsViews(t *testing.T) {
 
 	obsreporttest.CheckExporterLogsViews(t, exporter, 7, 0)
 }
+
+func TestSetupRecordedMetricsResetsViews(t *testing.T) {
+	scraperCtx := obsreport.ScraperContext(context.Background(), receiver, scraper)
+	// the values left registered by a previous test are dropped.
+	doneFn, err := obsreporttest.SetupRecordedMetricsTest()
+	require.NoError(t, err)
+	obsreport.EndMetricsScrapeOp(obsreport.StartMetricsScrapeOp(scraperCtx, receiver, scraper), 7, nil)
+
+	t.Run("first", func(t *testing.T) {
+		obsreporttest.SetupRecordedMetrics(t)
+		obsreport.EndMetricsScrapeOp(obsreport.StartMetricsScrapeOp(scraperCtx, receiver, scraper), 3, nil)
+		obsreporttest.CheckScraperMetricsViews(t, receiver, scraper, 3, 0)
+	})
+	t.Run("second", func(t *testing.T) {
+		obsreporttest.SetupRecordedMetrics(t)
+		obsreport.EndMetricsScrapeOp(obsreport.StartMetricsScrapeOp(scraperCtx, receiver, scraper), 5, nil)
+		obsreporttest.CheckScraperMetricsViews(t, receiver, scraper, 5, 0)
+	})
+
+	doneFn()
+}
+
+func TestScraperScrapeTimeValues(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	scraperCtx := obsreport.ScraperContext(context.Background(), receiver, scraper)
+	wallTime, cpuTime := obsreporttest.ScraperScrapeTimeValues(t, receiver, scraper)
+	assert.Zero(t, wallTime)
+	assert.Zero(t, cpuTime)
+
+	obsreport.RecordMetricsScrapeWallTime(scraperCtx, 3*time.Millisecond)
+	obsreport.RecordMetricsScrapeWallTime(scraperCtx, 2*time.Millisecond)
+	obsreport.RecordMetricsScrapeCPUTime(scraperCtx, time.Millisecond)
+	wallTime, cpuTime = obsreporttest.ScraperScrapeTimeValues(t, receiver, scraper)
+	assert.Equal(t, 5*time.Millisecond, wallTime)
+	assert.Equal(t, time.Millisecond, cpuTime)
+}
@@ -41,6 +41,7 @@ import (
 	"go.opentelemetry.io/collector/receiver/hostmetricsreceiver/internal/scraper/processesscraper"
 	"go.opentelemetry.io/collector/receiver/hostmetricsreceiver/internal/scraper/processscraper"
 	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
 )
 
 var standardMetrics = []string{
@@ -98,7 +99,7 @@ func TestGatherMetrics_EndToEnd(t *testing.T) {
 	scraperFactories = factories
 	resourceScraperFactories = resourceFactories
 
-	sink := new(consumertest.MetricsSink)
+	sink := new(scrapertest.SinkConsumer)
 
 	config := &Config{
 		ScraperControllerSettings: scraperhelper.ScraperControllerSettings{
@@ -132,17 +133,8 @@ func TestGatherMetrics_EndToEnd(t *testing.T) {
 	// canceling the context provided to Start should not cancel any async processes initiated by the receiver
 	cancelFn()
 
-	const tick = 50 * time.Millisecond
-	const waitFor = 5 * time.Second
-	require.Eventuallyf(t, func() bool {
-		got := sink.AllMetrics()
-		if len(got) == 0 {
-			return false
-		}
-
-		assertIncludesExpectedMetrics(t, got[0])
-		return true
-	}, waitFor, tick, "No metrics were collected after %v", waitFor)
+	batches := scrapertest.WaitForBatches(t, sink, 1, 5*time.Second)
+	assertIncludesExpectedMetrics(t, batches[0])
 }
 
 func assertIncludesExpectedMetrics(t *testing.T, got pdata.Metrics) {
@@ -403,8 +403,8 @@ func TestScrapeMetrics_ProcessErrors(t *testing.T) {
 			expectedError: `[[error reading command for process "test" (pid 1): err2; ` +
 				`error reading username for process "test" (pid 1): err3]; ` +
 				`error reading cpu times for process "test" (pid 1): err4; ` +
-				`error reading memory info for process "test" (pid 1): err5; ` +
-				`error reading disk usage for process "test" (pid 1): err6]`,
+				`error reading disk usage for process "test" (pid 1): err6; ` +
+				`error reading memory info for process "test" (pid 1): err5]`,
 		},
 	}
 
@@ -0,0 +1,136 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"google.golang.org/grpc/credentials"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configauth"
+)
+
+// WithAuthenticator authenticates the requests of the scraper to remote
+// endpoints with the extension of the given name, such as "oauth2client" or
+// "oauth2client/1". The extension is looked up in the extensions of the host
+// whenever the scraper is started, and must be a
+// configauth.HTTPClientAuthenticator or a configauth.GRPCClientAuthenticator,
+// or else starting the scraper fails. The authentication is passed to the
+// start and scrape functions of the scraper in their context, see
+// ClientAuthFromContext.
+func WithAuthenticator(extensionName string) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.clientAuth = &clientAuthTracker{extension: extensionName}
+	}
+}
+
+// ClientAuth authenticates the requests of a scraper created with
+// WithAuthenticator to remote endpoints.
+type ClientAuth struct {
+	// Extension is the name of the authenticator extension.
+	Extension     string
+	authenticator configauth.ClientAuthenticator
+}
+
+// RoundTripper returns a http.RoundTripper authenticating the requests sent
+// with base, or an error if the extension does not authenticate HTTP
+// requests.
+func (a *ClientAuth) RoundTripper(base http.RoundTripper) (http.RoundTripper, error) {
+	authenticator, ok := a.authenticator.(configauth.HTTPClientAuthenticator)
+	if !ok {
+		return nil, fmt.Errorf("authenticator extension %q does not authenticate HTTP requests", a.Extension)
+	}
+	return authenticator.RoundTripper(base)
+}
+
+// PerRPCCredentials returns the credentials authenticating gRPC calls, or an
+// error if the extension does not authenticate gRPC calls.
+func (a *ClientAuth) PerRPCCredentials() (credentials.PerRPCCredentials, error) {
+	authenticator, ok := a.authenticator.(configauth.GRPCClientAuthenticator)
+	if !ok {
+		return nil, fmt.Errorf("authenticator extension %q does not authenticate gRPC calls", a.Extension)
+	}
+	return authenticator.PerRPCCredentials()
+}
+
+type clientAuthContextKey struct{}
+
+// ClientAuthFromContext returns the ClientAuth of the scraper, from the
+// context passed to its start and scrape functions, if it was created with
+// WithAuthenticator and has been started.
+func ClientAuthFromContext(ctx context.Context) (*ClientAuth, bool) {
+	t, ok := ctx.Value(clientAuthContextKey{}).(*clientAuthTracker)
+	if !ok {
+		return nil, false
+	}
+	return t.get()
+}
+
+// clientAuthTracker holds the ClientAuth of a scraper, which is looked up
+// again whenever the scraper is started. Like the sizeHintTracker, it is
+// added to the cached observability context of the scraper.
+type clientAuthTracker struct {
+	extension string
+
+	mu   sync.Mutex
+	auth *ClientAuth
+}
+
+// contextWithClientAuth adds the tracker to the context, if any.
+func contextWithClientAuth(ctx context.Context, t *clientAuthTracker) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, clientAuthContextKey{}, t)
+}
+
+func (t *clientAuthTracker) get() (*ClientAuth, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.auth, t.auth != nil
+}
+
+// lookup finds the authenticator extension of the scraper in the extensions
+// of the host.
+func (t *clientAuthTracker) lookup(scraper string, host component.Host) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.auth = nil
+
+	var extension component.ServiceExtension
+	if host != nil {
+		for cfg, ext := range host.GetExtensions() {
+			if cfg.Name() == t.extension {
+				extension = ext
+				break
+			}
+		}
+	}
+	if extension == nil {
+		return fmt.Errorf("authenticator extension %q of scraper %q not found", t.extension, scraper)
+	}
+
+	_, isHTTP := extension.(configauth.HTTPClientAuthenticator)
+	_, isGRPC := extension.(configauth.GRPCClientAuthenticator)
+	if !isHTTP && !isGRPC {
+		return fmt.Errorf("extension %q of scraper %q is not a client authenticator", t.extension, scraper)
+	}
+	t.auth = &ClientAuth{Extension: t.extension, authenticator: extension}
+	return nil
+}
@@ -0,0 +1,245 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configauth"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+// headerAuthenticator authenticates HTTP requests by setting a header, and
+// gRPC calls with the same header as metadata.
+type headerAuthenticator struct {
+	header string
+	value  string
+}
+
+var (
+	_ configauth.HTTPClientAuthenticator = (*headerAuthenticator)(nil)
+	_ configauth.GRPCClientAuthenticator = (*headerAuthenticator)(nil)
+)
+
+func (*headerAuthenticator) Start(context.Context, component.Host) error { return nil }
+func (*headerAuthenticator) Shutdown(context.Context) error              { return nil }
+
+func (a *headerAuthenticator) RoundTripper(base http.RoundTripper) (http.RoundTripper, error) {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set(a.header, a.value)
+		return base.RoundTrip(req)
+	}), nil
+}
+
+func (a *headerAuthenticator) PerRPCCredentials() (credentials.PerRPCCredentials, error) {
+	return a, nil
+}
+
+func (a *headerAuthenticator) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{a.header: a.value}, nil
+}
+
+func (*headerAuthenticator) RequireTransportSecurity() bool { return false }
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// httpOnlyAuthenticator only authenticates HTTP requests, leaving them as is.
+type httpOnlyAuthenticator struct {
+	authExtension
+}
+
+func (*httpOnlyAuthenticator) RoundTripper(base http.RoundTripper) (http.RoundTripper, error) {
+	return base, nil
+}
+
+func scrapeOneMetric(context.Context) (pdata.MetricSlice, error) {
+	return scrapertest.GenerateMetrics(1, 1), nil
+}
+
+func TestWithAuthenticatorHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// the client is created when the scraper is initialized, and used on
+	// every scrape.
+	var client *http.Client
+	start := func(ctx context.Context, _ component.Host) error {
+		auth, ok := scraperhelper.ClientAuthFromContext(ctx)
+		if !ok {
+			return fmt.Errorf("no client authentication")
+		}
+		transport, err := auth.RoundTripper(http.DefaultTransport)
+		if err != nil {
+			return err
+		}
+		client = &http.Client{Transport: transport}
+		return nil
+	}
+	scrape := func(ctx context.Context) (pdata.MetricSlice, error) {
+		if _, ok := scraperhelper.ClientAuthFromContext(ctx); !ok {
+			return pdata.NewMetricSlice(), fmt.Errorf("no client authentication")
+		}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return pdata.NewMetricSlice(), err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return pdata.NewMetricSlice(), fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return scrapertest.GenerateMetrics(1, 1), nil
+	}
+
+	host := &scrapertest.Host{}
+	host.AddExtension("headers/api", &headerAuthenticator{header: "X-Api-Key", value: "secret"})
+	next := new(scrapertest.SinkConsumer)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	require.NoError(t, scraperhelper.ScrapeCycle(context.Background(), &cfg, zap.NewNop(), next, host,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrape,
+			scraperhelper.WithStart(start),
+			scraperhelper.WithAuthenticator("headers/api"))),
+	))
+	batches := next.Batches()
+	require.Len(t, batches, 1)
+	assert.Equal(t, 1, batches[0].MetricCount())
+}
+
+func TestWithAuthenticatorGRPC(t *testing.T) {
+	var metadata map[string]string
+	start := func(ctx context.Context, _ component.Host) error {
+		auth, ok := scraperhelper.ClientAuthFromContext(ctx)
+		if !ok {
+			return fmt.Errorf("no client authentication")
+		}
+		creds, err := auth.PerRPCCredentials()
+		if err != nil {
+			return err
+		}
+		metadata, err = creds.GetRequestMetadata(ctx)
+		return err
+	}
+
+	host := &scrapertest.Host{}
+	host.AddExtension("headers", &headerAuthenticator{header: "authorization", value: "Bearer token"})
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	require.NoError(t, scraperhelper.ScrapeCycle(context.Background(), &cfg, zap.NewNop(), new(scrapertest.SinkConsumer), host,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapeOneMetric,
+			scraperhelper.WithStart(start),
+			scraperhelper.WithAuthenticator("headers"))),
+	))
+	assert.Equal(t, map[string]string{"authorization": "Bearer token"}, metadata)
+}
+
+func TestWithAuthenticatorUnsupportedProtocol(t *testing.T) {
+	var credsErr error
+	start := func(ctx context.Context, _ component.Host) error {
+		auth, _ := scraperhelper.ClientAuthFromContext(ctx)
+		_, credsErr = auth.PerRPCCredentials()
+		return nil
+	}
+
+	host := &scrapertest.Host{}
+	host.AddExtension("headers", &httpOnlyAuthenticator{})
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	require.NoError(t, scraperhelper.ScrapeCycle(context.Background(), &cfg, zap.NewNop(), new(scrapertest.SinkConsumer), host,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapeOneMetric,
+			scraperhelper.WithStart(start),
+			scraperhelper.WithAuthenticator("headers"))),
+	))
+	assert.EqualError(t, credsErr, `authenticator extension "headers" does not authenticate gRPC calls`)
+}
+
+func TestWithAuthenticatorStartErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		host        component.Host
+		expectedErr string
+	}{
+		{
+			name:        "NoExtensions",
+			host:        componenttest.NewNopHost(),
+			expectedErr: `authenticator extension "headers" of scraper "scraper" not found`,
+		},
+		{
+			name: "MissingExtension",
+			host: func() component.Host {
+				host := &scrapertest.Host{}
+				host.AddExtension("headers/other", &headerAuthenticator{})
+				return host
+			}(),
+			expectedErr: `authenticator extension "headers" of scraper "scraper" not found`,
+		},
+		{
+			name: "NotAnAuthenticator",
+			host: func() component.Host {
+				host := &scrapertest.Host{}
+				host.AddExtension("headers", &authExtension{})
+				return host
+			}(),
+			expectedErr: `extension "headers" of scraper "scraper" is not a client authenticator`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			started := false
+			start := func(context.Context, component.Host) error {
+				started = true
+				return nil
+			}
+			cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+			err := scraperhelper.ScrapeCycle(context.Background(), &cfg, zap.NewNop(), new(scrapertest.SinkConsumer), test.host,
+				scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapeOneMetric,
+					scraperhelper.WithStart(start),
+					scraperhelper.WithAuthenticator("headers"))),
+			)
+			assert.EqualError(t, err, `failed to initialize scraper "scraper": `+test.expectedErr)
+			assert.False(t, started)
+		})
+	}
+}
+
+func TestWithAuthenticatorEmptyName(t *testing.T) {
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	_, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapeOneMetric,
+			scraperhelper.WithAuthenticator(""))),
+	)
+	assert.EqualError(t, err, `invalid scraper "scraper": authenticator extension name must not be empty`)
+}
@@ -0,0 +1,188 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// splitMetrics splits the metrics into batches of at most maxBatchSize data
+// points. Batches are split between ResourceMetrics and between metrics, and
+// the data points of a single metric are only split across batches if the
+// metric has more than maxBatchSize data points. The order of the data
+// points is preserved.
+func splitMetrics(metrics pdata.Metrics, maxBatchSize int) []pdata.Metrics {
+	if _, dataPoints := metrics.MetricAndDataPointCount(); dataPoints <= maxBatchSize {
+		return []pdata.Metrics{metrics}
+	}
+
+	s := &batchSplitter{maxBatchSize: maxBatchSize}
+	rms := metrics.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		ilms := rm.InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			ilm := ilms.At(j)
+			ms := ilm.Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				s.add(source{rm: rm, ilm: ilm, rmIndex: i, ilmIndex: j}, ms.At(k))
+			}
+		}
+	}
+	return s.batches
+}
+
+type batchSplitter struct {
+	maxBatchSize int
+
+	batches []pdata.Metrics
+	// size is the number of data points in the last batch.
+	size int
+	// metrics is where metrics from source are appended in the last batch,
+	// if hasMetrics is set.
+	metrics    pdata.MetricSlice
+	hasMetrics bool
+	source     source
+}
+
+// source identifies the ResourceMetrics and InstrumentationLibraryMetrics a
+// metric comes from.
+type source struct {
+	rm       pdata.ResourceMetrics
+	ilm      pdata.InstrumentationLibraryMetrics
+	rmIndex  int
+	ilmIndex int
+}
+
+// add adds the metric to the last batch, starting new batches as needed.
+func (s *batchSplitter) add(src source, metric pdata.Metric) {
+	count := dataPointCount(metric)
+	if s.size+count > s.maxBatchSize && s.size > 0 {
+		s.newBatch()
+	}
+	if count <= s.maxBatchSize {
+		s.destination(src).Append(metric)
+		s.size += count
+		return
+	}
+
+	// the metric does not fit in a batch on its own, so its data points are
+	// split across batches.
+	for from := 0; from < count; from += s.maxBatchSize {
+		to := from + s.maxBatchSize
+		if to > count {
+			to = count
+		}
+		if s.size > 0 {
+			s.newBatch()
+		}
+		part := pdata.NewMetric()
+		copyDataPoints(metric, part, from, to)
+		s.destination(src).Append(part)
+		s.size += to - from
+	}
+}
+
+func (s *batchSplitter) newBatch() {
+	s.batches = append(s.batches, pdata.NewMetrics())
+	s.size = 0
+	s.hasMetrics = false
+}
+
+// destination returns the slice of the last batch to which metrics from src
+// are appended.
+func (s *batchSplitter) destination(src source) pdata.MetricSlice {
+	if len(s.batches) == 0 {
+		s.newBatch()
+	}
+	sameRM := s.hasMetrics && s.source.rmIndex == src.rmIndex
+	if sameRM && s.source.ilmIndex == src.ilmIndex {
+		return s.metrics
+	}
+
+	rms := s.batches[len(s.batches)-1].ResourceMetrics()
+	if !sameRM {
+		rms.Resize(rms.Len() + 1)
+		src.rm.Resource().CopyTo(rms.At(rms.Len() - 1).Resource())
+	}
+	ilms := rms.At(rms.Len() - 1).InstrumentationLibraryMetrics()
+	ilms.Resize(ilms.Len() + 1)
+	src.ilm.InstrumentationLibrary().CopyTo(ilms.At(ilms.Len() - 1).InstrumentationLibrary())
+
+	s.metrics = ilms.At(ilms.Len() - 1).Metrics()
+	s.hasMetrics = true
+	s.source = src
+	return s.metrics
+}
+
+// copyDataPoints copies the metric to dest, with only the data points in
+// the range [from, to).
+func copyDataPoints(metric, dest pdata.Metric, from, to int) {
+	dest.SetName(metric.Name())
+	dest.SetDescription(metric.Description())
+	dest.SetUnit(metric.Unit())
+	dest.SetDataType(metric.DataType())
+
+	switch metric.DataType() {
+	case pdata.MetricDataTypeIntGauge:
+		src, dst := metric.IntGauge().DataPoints(), dest.IntGauge().DataPoints()
+		dst.Resize(to - from)
+		for i := from; i < to; i++ {
+			src.At(i).CopyTo(dst.At(i - from))
+		}
+	case pdata.MetricDataTypeDoubleGauge:
+		src, dst := metric.DoubleGauge().DataPoints(), dest.DoubleGauge().DataPoints()
+		dst.Resize(to - from)
+		for i := from; i < to; i++ {
+			src.At(i).CopyTo(dst.At(i - from))
+		}
+	case pdata.MetricDataTypeIntSum:
+		dest.IntSum().SetAggregationTemporality(metric.IntSum().AggregationTemporality())
+		dest.IntSum().SetIsMonotonic(metric.IntSum().IsMonotonic())
+		src, dst := metric.IntSum().DataPoints(), dest.IntSum().DataPoints()
+		dst.Resize(to - from)
+		for i := from; i < to; i++ {
+			src.At(i).CopyTo(dst.At(i - from))
+		}
+	case pdata.MetricDataTypeDoubleSum:
+		dest.DoubleSum().SetAggregationTemporality(metric.DoubleSum().AggregationTemporality())
+		dest.DoubleSum().SetIsMonotonic(metric.DoubleSum().IsMonotonic())
+		src, dst := metric.DoubleSum().DataPoints(), dest.DoubleSum().DataPoints()
+		dst.Resize(to - from)
+		for i := from; i < to; i++ {
+			src.At(i).CopyTo(dst.At(i - from))
+		}
+	case pdata.MetricDataTypeIntHistogram:
+		dest.IntHistogram().SetAggregationTemporality(metric.IntHistogram().AggregationTemporality())
+		src, dst := metric.IntHistogram().DataPoints(), dest.IntHistogram().DataPoints()
+		dst.Resize(to - from)
+		for i := from; i < to; i++ {
+			src.At(i).CopyTo(dst.At(i - from))
+		}
+	case pdata.MetricDataTypeDoubleHistogram:
+		dest.DoubleHistogram().SetAggregationTemporality(metric.DoubleHistogram().AggregationTemporality())
+		src, dst := metric.DoubleHistogram().DataPoints(), dest.DoubleHistogram().DataPoints()
+		dst.Resize(to - from)
+		for i := from; i < to; i++ {
+			src.At(i).CopyTo(dst.At(i - from))
+		}
+	case pdata.MetricDataTypeDoubleSummary:
+		src, dst := metric.DoubleSummary().DataPoints(), dest.DoubleSummary().DataPoints()
+		dst.Resize(to - from)
+		for i := from; i < to; i++ {
+			src.At(i).CopyTo(dst.At(i - from))
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// testPayload returns metrics from two resources, with gauges with the
+// given numbers of data points in each. Data points are labeled with their
+// index so that their order can be checked.
+func testPayload(counts ...[]int) pdata.Metrics {
+	md := pdata.NewMetrics()
+	rms := md.ResourceMetrics()
+	rms.Resize(len(counts))
+	for i, resourceCounts := range counts {
+		rm := rms.At(i)
+		rm.Resource().Attributes().InsertString("host.name", fmt.Sprintf("host%d", i))
+		rm.InstrumentationLibraryMetrics().Resize(1)
+		rm.InstrumentationLibraryMetrics().At(0).InstrumentationLibrary().SetName("library")
+		metrics := gaugesWithDataPoints(resourceCounts...)
+		for j := 0; j < metrics.Len(); j++ {
+			dps := metrics.At(j).IntGauge().DataPoints()
+			for k := 0; k < dps.Len(); k++ {
+				dps.At(k).SetValue(int64(k))
+			}
+		}
+		metrics.MoveAndAppendTo(rm.InstrumentationLibraryMetrics().At(0).Metrics())
+	}
+	return md
+}
+
+// flatten returns a description of each data point of the metrics, in order.
+func flatten(md pdata.Metrics) []string {
+	var out []string
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		host, _ := rms.At(i).Resource().Attributes().Get("host.name")
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			library := ilms.At(j).InstrumentationLibrary().Name()
+			metrics := ilms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				dps := metrics.At(k).IntGauge().DataPoints()
+				for l := 0; l < dps.Len(); l++ {
+					out = append(out, fmt.Sprintf("%s/%s/%s/%d", host.StringVal(), library, metrics.At(k).Name(), dps.At(l).Value()))
+				}
+			}
+		}
+	}
+	return out
+}
+
+func TestSplitMetrics(t *testing.T) {
+	tests := []struct {
+		name            string
+		counts          [][]int
+		maxBatchSize    int
+		expectedBatches int
+	}{
+		{
+			name:            "fits",
+			counts:          [][]int{{2, 3}, {4}},
+			maxBatchSize:    10,
+			expectedBatches: 1,
+		},
+		{
+			name:            "split between metrics and resources",
+			counts:          [][]int{{2, 3}, {4, 1}},
+			maxBatchSize:    5,
+			expectedBatches: 2,
+		},
+		{
+			name:            "split oversized metric",
+			counts:          [][]int{{2, 12}, {1}},
+			maxBatchSize:    5,
+			expectedBatches: 4,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			original := testPayload(test.counts...)
+			expected := flatten(original)
+
+			batches := splitMetrics(original, test.maxBatchSize)
+			require.Len(t, batches, test.expectedBatches)
+
+			var reassembled []string
+			for _, batch := range batches {
+				_, dataPoints := batch.MetricAndDataPointCount()
+				assert.LessOrEqual(t, dataPoints, test.maxBatchSize)
+				reassembled = append(reassembled, flatten(batch)...)
+			}
+			assert.Equal(t, expected, reassembled)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+// benchmarkTicks sends b.N ticks to a receiver created with the given
+// options. The ticker channel is unbuffered, so that each tick is only sent
+// once the previous one has been scraped and reported, and the receiver is
+// shutdown before the timer is stopped, so that the last tick is measured.
+func benchmarkTicks(b *testing.B, next consumer.MetricsConsumer, options ...scraperhelper.ScraperControllerOption) {
+	tickerCh := make(chan time.Time)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), next,
+		append(options, scraperhelper.WithTickerChannel(tickerCh))...)
+	require.NoError(b, err)
+	require.NoError(b, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tickerCh <- time.Now()
+	}
+	require.NoError(b, receiver.Shutdown(context.Background()))
+	b.StopTimer()
+}
+
+func BenchmarkSingleScraperSmallPayload(b *testing.B) {
+	benchmarkTicks(b, consumertest.NewMetricsNop(),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(10, 1))))
+}
+
+func BenchmarkSingleScraperLargePayload(b *testing.B) {
+	// 1,000 metrics of 100 data points each.
+	benchmarkTicks(b, consumertest.NewMetricsNop(),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1000, 100))))
+}
+
+func BenchmarkMixedTypesPayload(b *testing.B) {
+	// 10 resources of 50 gauges, sums and histograms of 10 data points each,
+	// with 3 labels of 5 values.
+	spec := scrapertest.GenSpec{
+		Resources:        10,
+		Metrics:          50,
+		DataPoints:       10,
+		Types:            []pdata.MetricDataType{pdata.MetricDataTypeDoubleGauge, pdata.MetricDataTypeDoubleSum, pdata.MetricDataTypeDoubleHistogram},
+		Labels:           3,
+		LabelCardinality: 5,
+	}
+	benchmarkTicks(b, consumertest.NewMetricsNop(),
+		scraperhelper.AddResourceMetricsScraper(scraperhelper.NewResourceMetricsScraper("scraper", scrapertest.NewScrapeGenerated(spec))))
+}
+
+func BenchmarkManyScrapersBatched(b *testing.B) {
+	options := []scraperhelper.ScraperControllerOption{scraperhelper.WithMaxBatchSize(1000)}
+	for i := 0; i < 50; i++ {
+		scraper := scraperhelper.NewMetricsScraper(fmt.Sprintf("scraper%d", i), scrapertest.NewScrapeMetrics(10, 10))
+		options = append(options, scraperhelper.AddMetricsScraper(scraper))
+	}
+	benchmarkTicks(b, consumertest.NewMetricsNop(), options...)
+}
+
+// slowConsumer takes the given time to consume metrics.
+type slowConsumer time.Duration
+
+func (c slowConsumer) ConsumeMetrics(context.Context, pdata.Metrics) error {
+	time.Sleep(time.Duration(c))
+	return nil
+}
+
+func BenchmarkSlowConsumer(b *testing.B) {
+	scraper := scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(10, 1))
+	next := slowConsumer(100 * time.Microsecond)
+	b.Run("sync", func(b *testing.B) {
+		benchmarkTicks(b, next, scraperhelper.AddMetricsScraper(scraper))
+	})
+	b.Run("async", func(b *testing.B) {
+		benchmarkTicks(b, next, scraperhelper.AddMetricsScraper(scraper), scraperhelper.WithAsyncConsume(100, 1))
+	})
+}
@@ -0,0 +1,174 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// CachedTimestamps specifies the timestamps of the data points returned from
+// the result cache set with WithResultCache.
+type CachedTimestamps int
+
+const (
+	// CachedTimestampsRefresh sets the timestamps of the cached data points
+	// to the time of the scrape returning them. This is the default.
+	CachedTimestampsRefresh CachedTimestamps = iota
+	// CachedTimestampsKeep keeps the timestamps of the scrape the data points
+	// were cached from.
+	CachedTimestampsKeep
+)
+
+// WithResultCache caches the result of each successful scrape for the given
+// time to live, returning a copy of the cached result instead of calling the
+// scrape function as long as it is younger than the time to live. This avoids
+// reading the same data again from sources that refresh their data less
+// often than they are scraped. Failed and partially failed scrapes are never
+// cached.
+//
+// The result returned by the scrape function is cached, and the copies
+// returned from the cache are processed like the result of a scrape, for
+// instance by WithStalenessMarkers and WithDeltaToCumulative. As a cached
+// result holds no change since the scrape it was cached from, the values of
+// the delta sums of the copies are zero.
+func WithResultCache(ttl time.Duration, timestamps CachedTimestamps) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.resultCache = newResultCache(ttl, timestamps)
+	}
+}
+
+// resultCache holds the result of the last successful scrape of a scraper.
+// Only one of metrics and resourceMetrics is used, depending on the type of
+// the scraper.
+type resultCache struct {
+	ttl        time.Duration
+	timestamps CachedTimestamps
+	// now returns the current time, and is only replaced by tests.
+	now func() time.Time
+
+	mu              sync.Mutex
+	cachedAt        time.Time
+	cached          bool
+	metrics         pdata.MetricSlice
+	resourceMetrics pdata.ResourceMetricsSlice
+}
+
+func newResultCache(ttl time.Duration, timestamps CachedTimestamps) *resultCache {
+	return &resultCache{ttl: ttl, timestamps: timestamps, now: time.Now}
+}
+
+// reset forgets the cached result.
+func (c *resultCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cached = false
+	c.metrics, c.resourceMetrics = pdata.MetricSlice{}, pdata.ResourceMetricsSlice{}
+}
+
+// fresh reports whether a result is cached, and is younger than the time to
+// live, returning the timestamp of the data points returned from the cache.
+// It must be called with mu held.
+func (c *resultCache) fresh() (bool, pdata.TimestampUnixNano) {
+	now := c.now()
+	return c.cached && now.Sub(c.cachedAt) < c.ttl, pdata.TimestampUnixNano(now.UnixNano())
+}
+
+// getMetrics returns a copy of the cached metrics, if they are fresh.
+func (c *resultCache) getMetrics() (pdata.MetricSlice, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fresh, ts := c.fresh()
+	if !fresh {
+		return pdata.MetricSlice{}, false
+	}
+	metrics := pdata.NewMetricSlice()
+	c.metrics.CopyTo(metrics)
+	zeroDeltaSums(metrics)
+	if c.timestamps == CachedTimestampsRefresh {
+		setTimestamps(metrics, ts)
+	}
+	return metrics, true
+}
+
+// putMetrics caches a copy of the metrics of a successful scrape.
+func (c *resultCache) putMetrics(metrics pdata.MetricSlice) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = pdata.NewMetricSlice()
+	metrics.CopyTo(c.metrics)
+	c.cachedAt, c.cached = c.now(), true
+}
+
+// getResourceMetrics returns a copy of the cached resource metrics, if they
+// are fresh.
+func (c *resultCache) getResourceMetrics() (pdata.ResourceMetricsSlice, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fresh, ts := c.fresh()
+	if !fresh {
+		return pdata.ResourceMetricsSlice{}, false
+	}
+	resourceMetrics := pdata.NewResourceMetricsSlice()
+	c.resourceMetrics.CopyTo(resourceMetrics)
+	for i := 0; i < resourceMetrics.Len(); i++ {
+		ilms := resourceMetrics.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			zeroDeltaSums(ilms.At(j).Metrics())
+			if c.timestamps == CachedTimestampsRefresh {
+				setTimestamps(ilms.At(j).Metrics(), ts)
+			}
+		}
+	}
+	return resourceMetrics, true
+}
+
+// putResourceMetrics caches a copy of the resource metrics of a successful
+// scrape.
+func (c *resultCache) putResourceMetrics(resourceMetrics pdata.ResourceMetricsSlice) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resourceMetrics = pdata.NewResourceMetricsSlice()
+	resourceMetrics.CopyTo(c.resourceMetrics)
+	c.cachedAt, c.cached = c.now(), true
+}
+
+// zeroDeltaSums sets the values of the data points of the delta IntSum and
+// DoubleSum metrics to zero.
+func zeroDeltaSums(metrics pdata.MetricSlice) {
+	for i := 0; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		switch metric.DataType() {
+		case pdata.MetricDataTypeIntSum:
+			if metric.IntSum().AggregationTemporality() != pdata.AggregationTemporalityDelta {
+				continue
+			}
+			dps := metric.IntSum().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dps.At(j).SetValue(0)
+			}
+		case pdata.MetricDataTypeDoubleSum:
+			if metric.DoubleSum().AggregationTemporality() != pdata.AggregationTemporalityDelta {
+				continue
+			}
+			dps := metric.DoubleSum().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dps.At(j).SetValue(0)
+			}
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// countingScrape returns a scrape function returning the given errors in
+// order, then no error, and counting its invocations.
+func countingScrape(invocations *int, errs ...error) ScrapeMetrics {
+	return func(context.Context) (pdata.MetricSlice, error) {
+		*invocations++
+		metrics := singleMetric()
+		metrics.At(0).IntGauge().DataPoints().At(0).SetValue(int64(*invocations))
+		var err error
+		if len(errs) > 0 {
+			err, errs = errs[0], errs[1:]
+		}
+		return metrics, err
+	}
+}
+
+func TestResultCacheConsumes(t *testing.T) {
+	var invocations int
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	cfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", countingScrape(&invocations), WithResultCache(time.Hour, CachedTimestampsRefresh))),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	for i := 0; i < 6; i++ {
+		tickerCh <- time.Now()
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	// the scrape function is only invoked once, but the metrics are consumed
+	// on every tick.
+	assert.Equal(t, 1, invocations)
+	require.Len(t, sink.AllMetrics(), 6)
+	for _, md := range sink.AllMetrics() {
+		assert.Equal(t, int64(1), md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0).IntGauge().DataPoints().At(0).Value())
+	}
+}
+
+// scrapeAt scrapes the scraper with the clock of its result cache set to the
+// given time, and returns the value and timestamp of the scraped data point.
+func scrapeAt(t *testing.T, ms MetricsScraper, now time.Time) (int64, pdata.TimestampUnixNano) {
+	ms.(*metricsScraper).resultCache.now = func() time.Time { return now }
+	metrics, _ := ms.Scrape(context.Background(), "receiver")
+	require.Equal(t, 1, metrics.Len())
+	dp := metrics.At(0).IntGauge().DataPoints().At(0)
+	return dp.Value(), dp.Timestamp()
+}
+
+func TestResultCacheExpires(t *testing.T) {
+	var invocations int
+	ms := NewMetricsScraper("scraper", countingScrape(&invocations), WithResultCache(time.Minute, CachedTimestampsRefresh))
+	require.NoError(t, ms.Start(context.Background(), componenttest.NewNopHost()))
+
+	start := time.Now()
+	for _, tt := range []struct {
+		after       time.Duration
+		invocations int
+	}{
+		{0, 1},
+		{10 * time.Second, 1},
+		{50 * time.Second, 1},
+		{time.Minute, 2},
+		{90 * time.Second, 2},
+		{2 * time.Minute, 3},
+	} {
+		value, ts := scrapeAt(t, ms, start.Add(tt.after))
+		assert.Equal(t, tt.invocations, invocations, "after %v", tt.after)
+		assert.Equal(t, int64(tt.invocations), value, "after %v", tt.after)
+		if tt.after != 0 && invocations == 1 {
+			// the timestamps of the cached data points are refreshed.
+			assert.Equal(t, pdata.TimestampUnixNano(start.Add(tt.after).UnixNano()), ts)
+		}
+	}
+
+	// restarting the scraper forgets the cached result.
+	require.NoError(t, ms.Start(context.Background(), componenttest.NewNopHost()))
+	scrapeAt(t, ms, start.Add(2*time.Minute))
+	assert.Equal(t, 4, invocations)
+}
+
+func TestResultCacheKeepsTimestamps(t *testing.T) {
+	var invocations int
+	ms := NewMetricsScraper("scraper", countingScrape(&invocations), WithResultCache(time.Minute, CachedTimestampsKeep))
+	require.NoError(t, ms.Start(context.Background(), componenttest.NewNopHost()))
+
+	start := time.Now()
+	_, first := scrapeAt(t, ms, start)
+	_, cached := scrapeAt(t, ms, start.Add(time.Second))
+	assert.Equal(t, 1, invocations)
+	assert.Equal(t, first, cached)
+}
+
+func TestResultCacheDoesNotCacheErrors(t *testing.T) {
+	var invocations int
+	scrape := countingScrape(&invocations,
+		errors.New("err1"),
+		consumererror.NewPartialScrapeError(errors.New("err2"), 1))
+	ms := NewMetricsScraper("scraper", scrape, WithResultCache(time.Minute, CachedTimestampsRefresh))
+	require.NoError(t, ms.Start(context.Background(), componenttest.NewNopHost()))
+
+	start := time.Now()
+	for i := 1; i <= 4; i++ {
+		ms.(*metricsScraper).resultCache.now = func() time.Time { return start }
+		_, err := ms.Scrape(context.Background(), "receiver")
+		if i <= 2 {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+	// the failed and partially failed scrapes are not cached, but the third,
+	// successful one is.
+	assert.Equal(t, 3, invocations)
+}
+
+func TestResultCacheResourceMetrics(t *testing.T) {
+	var invocations int
+	scrape := func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		invocations++
+		return singleResourceMetric(), nil
+	}
+	rms := NewResourceMetricsScraper("scraper", scrape, WithResultCache(time.Minute, CachedTimestampsRefresh))
+	require.NoError(t, rms.Start(context.Background(), componenttest.NewNopHost()))
+
+	start := time.Now()
+	rms.(*resourceMetricsScraper).resultCache.now = func() time.Time { return start }
+	first, err := rms.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	rms.(*resourceMetricsScraper).resultCache.now = func() time.Time { return start.Add(time.Second) }
+	cached, err := rms.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, invocations)
+	require.Equal(t, 1, cached.Len())
+	dp := cached.At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0).IntGauge().DataPoints().At(0)
+	assert.Equal(t, pdata.TimestampUnixNano(start.Add(time.Second).UnixNano()), dp.Timestamp())
+
+	// the cached result is a copy, which is not changed by changing the
+	// returned resource metrics.
+	first.Resize(0)
+	cached, err = rms.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	assert.Equal(t, 1, cached.Len())
+}
+
+func TestResultCacheStalenessMarkers(t *testing.T) {
+	scrapes := [][]string{{"sda", "sdb"}, {"sda"}}
+	var invocations int
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		invocations++
+		return doubleGauge(scrapes[invocations-1]...), nil
+	}
+	ms := NewMetricsScraper("scraper", scrape, WithResultCache(time.Minute, CachedTimestampsRefresh), WithStalenessMarkers())
+	require.NoError(t, ms.Start(context.Background(), componenttest.NewNopHost()))
+
+	start := time.Now()
+	for _, tt := range []struct {
+		after       time.Duration
+		invocations int
+		want        map[string]string
+	}{
+		{0, 1, map[string]string{"sda": "value", "sdb": "value"}},
+		{10 * time.Second, 1, map[string]string{"sda": "value", "sdb": "value"}},
+		{time.Minute, 2, map[string]string{"sda": "value", "sdb": "stale"}},
+		// the staleness marker is not replayed from the cache.
+		{70 * time.Second, 2, map[string]string{"sda": "value"}},
+	} {
+		ms.(*metricsScraper).resultCache.now = func() time.Time { return start.Add(tt.after) }
+		metrics, err := ms.Scrape(context.Background(), "receiver")
+		require.NoError(t, err)
+		assert.Equal(t, tt.invocations, invocations, "after %v", tt.after)
+		assert.Equal(t, tt.want, deviceValues(t, metrics), "after %v", tt.after)
+	}
+}
+
+func TestResultCacheDeltaToCumulative(t *testing.T) {
+	scrapes := []pdata.MetricSlice{
+		deltaSum(100, deltaPoint{"a", 1}),
+		deltaSum(200, deltaPoint{"a", 2}),
+	}
+	var invocations int
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		invocations++
+		return scrapes[invocations-1], nil
+	}
+	ms := NewMetricsScraper("scraper", scrape, WithResultCache(time.Minute, CachedTimestampsKeep), WithDeltaToCumulative(1))
+	require.NoError(t, ms.Start(context.Background(), componenttest.NewNopHost()))
+
+	start := time.Now()
+	for _, tt := range []struct {
+		after time.Duration
+		value int64
+	}{
+		{0, 1},
+		// the cached delta is not accumulated again.
+		{10 * time.Second, 1},
+		{time.Minute, 3},
+		{70 * time.Second, 3},
+	} {
+		ms.(*metricsScraper).resultCache.now = func() time.Time { return start.Add(tt.after) }
+		metrics, err := ms.Scrape(context.Background(), "receiver")
+		require.NoError(t, err)
+		assert.Equal(t, cumulativePoint{value: tt.value, startTime: 90}, cumulativePoints(t, metrics)["a"], "after %v", tt.after)
+	}
+	assert.Equal(t, 2, invocations)
+}
+
+func TestResultCacheInvalidTTL(t *testing.T) {
+	var invocations int
+	cfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", countingScrape(&invocations), WithResultCache(0, CachedTimestampsRefresh))),
+	)
+	assert.EqualError(t, err, `invalid scraper "scraper": result cache ttl must be positive`)
+}
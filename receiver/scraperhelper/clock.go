@@ -0,0 +1,128 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"time"
+)
+
+// Clock is the source of time of a receiver, which tests can replace with
+// WithClock to control when the receiver ticks, without waiting.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTicker returns a ticker sending the time on its channel every
+	// period d, like time.NewTicker.
+	NewTicker(d time.Duration) Ticker
+	// NewTimer returns a timer sending the time on its channel once d
+	// elapsed, like time.NewTimer.
+	NewTimer(d time.Duration) Timer
+}
+
+// Ticker sends the time on its channel at intervals, like time.Ticker.
+type Ticker interface {
+	// C returns the channel on which the ticks are sent.
+	C() <-chan time.Time
+	// Stop stops the ticker. No more ticks are sent once Stop returned.
+	Stop()
+}
+
+// Timer sends the time on its channel once, like time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is sent.
+	C() <-chan time.Time
+	// Stop stops the timer. The time is not sent once Stop returned, if it
+	// was not already.
+	Stop()
+}
+
+// WithClock sets the clock measuring the time of the receiver, timestamping
+// the statistics and staleness markers of its scrapers, and waiting for the
+// deadlines of its scrapers, unless WithTickerChannel is used. The timeouts of
+// the scrapes and of the next consumer, and the costs of the scrapes, are
+// still measured in wall time. The default is the system clock. WithClock
+// can not be used with WithSharedScheduler.
+func WithClock(clock Clock) ScraperControllerOption {
+	return func(o *controller) {
+		o.clock = clock
+		o.now = clock.Now
+	}
+}
+
+// systemClock is the Clock of the system.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{ticker: time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{timer: time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t systemTimer) Stop() {
+	t.timer.Stop()
+}
+
+// setClock sets the time source of the staleness markers and of the start of
+// the series without timestamps of the scraper to the one of the receiver
+// starting it.
+func (b *baseScraper) setClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	if b.startTimes != nil {
+		b.startTimes.setClock(now)
+	}
+	if b.staleness != nil {
+		b.staleness.setClock(now)
+	}
+}
+
+// now returns the current time of the receiver that started the scraper, or
+// of the system if it was not started by a receiver.
+func (b *baseScraper) now() time.Time {
+	b.mu.Lock()
+	now := b.receiverSettings.now
+	b.mu.Unlock()
+	if now == nil {
+		return time.Now()
+	}
+	return now()
+}
@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"fmt"
+)
+
+// optionConflict is a pair of receiver options that can not be used
+// together.
+type optionConflict struct {
+	first  string
+	second string
+	// conflicts reports whether the receiver was created with both options.
+	conflicts func(sc *controller) bool
+}
+
+// optionConflicts lists the receiver options that can not be used together.
+// The options of a scraper that can not be used together, WithStart and
+// WithStartEx, are checked by the scraper.
+var optionConflicts = []optionConflict{
+	{
+		first:     "WithSharedScheduler",
+		second:    "WithTickerChannel",
+		conflicts: func(sc *controller) bool { return sc.sharedScheduler && sc.tickerCh != nil },
+	},
+	{
+		first:     "WithSharedScheduler",
+		second:    "WithClock",
+		conflicts: func(sc *controller) bool { return sc.sharedScheduler && sc.clock != nil },
+	},
+}
+
+// initialDelayOptions names the option setting the initial delay of a
+// scraper from each source.
+var initialDelayOptions = map[ConfigSource]string{
+	ConfigSourceOption:        "WithInitialDelay",
+	ConfigSourceScraperConfig: "the initial delay of WithConfig",
+	ConfigSourceReceiver:      "WithDefaultInitialDelay",
+}
+
+// conflictErrs returns an error naming both options for each pair of options
+// the receiver was created with that can not be used together, once all the
+// options were applied, so that the order of the options does not matter.
+func (sc *controller) conflictErrs() []error {
+	var errs []error
+	for _, conflict := range optionConflicts {
+		if conflict.conflicts(sc) {
+			errs = append(errs, withKind(ErrInvalidOption, fmt.Errorf("%s can not be used with %s", conflict.first, conflict.second)))
+		}
+	}
+	return errs
+}
+
+// scraperConflictErr returns an error if the scraper is set up in a way that
+// can not be used with the options of the receiver: the scrape on start of
+// ScrapeOnStartGate can not be used with an initial delay longer than the
+// collection interval of the scraper, which would skip both the scrape on
+// start and the first ticks.
+func (sc *controller) scraperConflictErr(scraper BaseScraper) error {
+	if !sc.scrapeOnStart {
+		return nil
+	}
+	delay, source := sc.initialDelayWithSource(scraper)
+	if interval := sc.scraperInterval(scraper); interval <= 0 || delay <= interval {
+		return nil
+	}
+	return withKind(ErrInvalidOption, fmt.Errorf("scraper %q: %s longer than the collection interval can not be used with the %s feature gate",
+		scraper.Name(), initialDelayOptions[source], ScrapeOnStartGate))
+}
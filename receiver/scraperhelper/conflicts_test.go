@@ -0,0 +1,131 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+func TestOptionConflicts(t *testing.T) {
+	start := func(context.Context, component.Host) error { return nil }
+	startEx := func(context.Context, component.Host, scraperhelper.StartInfo) error { return nil }
+	scraper := func(options ...scraperhelper.ScraperOption) scraperhelper.ScraperControllerOption {
+		return scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1), options...))
+	}
+	tests := []struct {
+		name          string
+		scrapeOnStart bool
+		// options are applied in order, then in reverse order.
+		options []scraperhelper.ScraperControllerOption
+		wantErr string
+	}{
+		{
+			name:    "shared scheduler and ticker channel",
+			options: []scraperhelper.ScraperControllerOption{scraperhelper.WithSharedScheduler(), scraperhelper.WithTickerChannel(make(chan time.Time)), scraper()},
+			wantErr: "WithSharedScheduler can not be used with WithTickerChannel",
+		},
+		{
+			name:    "shared scheduler and clock",
+			options: []scraperhelper.ScraperControllerOption{scraperhelper.WithSharedScheduler(), scraperhelper.WithClock(scrapertest.NewFakeClock(time.Now())), scraper()},
+			wantErr: "WithSharedScheduler can not be used with WithClock",
+		},
+		{
+			name:    "start and start ex",
+			options: []scraperhelper.ScraperControllerOption{scraper(scraperhelper.WithStartEx(startEx), scraperhelper.WithStart(start))},
+			wantErr: `invalid scraper "scraper": only one of WithStart and WithStartEx can be set`,
+		},
+		{
+			name:          "scrape on start and initial delay",
+			scrapeOnStart: true,
+			options:       []scraperhelper.ScraperControllerOption{scraper(scraperhelper.WithInitialDelay(2 * time.Minute))},
+			wantErr:       `scraper "scraper": WithInitialDelay longer than the collection interval can not be used with the scraperhelper.scrapeOnStart feature gate`,
+		},
+		{
+			name:          "scrape on start and configured initial delay",
+			scrapeOnStart: true,
+			options: []scraperhelper.ScraperControllerOption{
+				scraper(scraperhelper.WithConfig(&scraperhelper.ScraperSettings{InitialDelayVal: 20 * time.Second, CollectionIntervalVal: 10 * time.Second})),
+			},
+			wantErr: `scraper "scraper": the initial delay of WithConfig longer than the collection interval can not be used with the scraperhelper.scrapeOnStart feature gate`,
+		},
+		{
+			name:          "scrape on start and default initial delay",
+			scrapeOnStart: true,
+			options:       []scraperhelper.ScraperControllerOption{scraperhelper.WithDefaultInitialDelay(2 * time.Minute), scraper()},
+			wantErr:       `scraper "scraper": WithDefaultInitialDelay longer than the collection interval can not be used with the scraperhelper.scrapeOnStart feature gate`,
+		},
+		{
+			name:    "shared scheduler",
+			options: []scraperhelper.ScraperControllerOption{scraperhelper.WithSharedScheduler(), scraperhelper.WithMaxConcurrentScrapes(2), scraper()},
+		},
+		{
+			name:    "ticker channel and clock",
+			options: []scraperhelper.ScraperControllerOption{scraperhelper.WithTickerChannel(make(chan time.Time)), scraperhelper.WithClock(scrapertest.NewFakeClock(time.Now())), scraper()},
+		},
+		{
+			name:    "start",
+			options: []scraperhelper.ScraperControllerOption{scraper(scraperhelper.WithStart(start), scraperhelper.WithStart(start))},
+		},
+		{
+			name:          "scrape on start and short initial delay",
+			scrapeOnStart: true,
+			options:       []scraperhelper.ScraperControllerOption{scraperhelper.WithDefaultInitialDelay(2 * time.Minute), scraper(scraperhelper.WithInitialDelay(time.Minute))},
+		},
+		{
+			name:          "scrape on start and long collection interval",
+			scrapeOnStart: true,
+			options: []scraperhelper.ScraperControllerOption{
+				scraper(scraperhelper.WithInitialDelay(2*time.Minute), scraperhelper.WithConfig(&scraperhelper.ScraperSettings{CollectionIntervalVal: 5 * time.Minute})),
+			},
+		},
+		{
+			name:    "long initial delay",
+			options: []scraperhelper.ScraperControllerOption{scraper(scraperhelper.WithInitialDelay(2 * time.Minute))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scrapertest.SetGate(t, scraperhelper.ScrapeOnStartGate, tt.scrapeOnStart)
+			reversed := make([]scraperhelper.ScraperControllerOption, len(tt.options))
+			for i, option := range tt.options {
+				reversed[len(reversed)-1-i] = option
+			}
+			for _, options := range [][]scraperhelper.ScraperControllerOption{tt.options, reversed} {
+				cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+				receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer), options...)
+				if tt.wantErr == "" {
+					require.NoError(t, err)
+					assert.NotNil(t, receiver)
+					continue
+				}
+				require.EqualError(t, err, tt.wantErr)
+				var verr *scraperhelper.ValidationError
+				require.True(t, errors.As(err, &verr))
+				assert.True(t, errors.Is(err, scraperhelper.ErrInvalidOption) || errors.Is(err, scraperhelper.ErrInvalidScraper))
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// WithConsumeContextMetadata passes the given metadata to the next consumer
+// with the metrics of the scraper, as the gRPC metadata of the incoming
+// context of ConsumeMetrics, like the receivers serving gRPC requests pass
+// on the metadata of the requests, so that processors, such as those routing
+// the metrics of each tenant, can read it with metadata.FromIncomingContext.
+// The keys are lowercased, and must not be empty.
+//
+// The metrics of the scraper are passed to the next consumer separately from
+// those of the other scrapers, so that the metadata of a scraper is never
+// passed with the metrics of another one.
+func WithConsumeContextMetadata(md map[string]string) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		if _, ok := md[""]; ok {
+			s.optionErrs = append(s.optionErrs, errors.New("consume context metadata keys must not be empty"))
+			return
+		}
+		s.consumeMetadata = metadata.New(md)
+	}
+}
+
+// consumeMetadata returns the metadata the metrics of the scraper are
+// consumed with, if it was created with WithConsumeContextMetadata.
+func consumeMetadata(scraper BaseScraper) metadata.MD {
+	if s, ok := scraper.(interface{ consumeContextMetadata() metadata.MD }); ok {
+		return s.consumeContextMetadata()
+	}
+	return nil
+}
+
+func (b *baseScraper) consumeContextMetadata() metadata.MD {
+	return b.consumeMetadata
+}
+
+// contextWithConsumeMetadata returns a copy of the context whose incoming
+// metadata also contains the given metadata, or the context if there is no
+// metadata.
+func contextWithConsumeMetadata(ctx context.Context, md metadata.MD) context.Context {
+	if len(md) == 0 {
+		return ctx
+	}
+	if incoming, ok := metadata.FromIncomingContext(ctx); ok {
+		md = metadata.Join(incoming, md)
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
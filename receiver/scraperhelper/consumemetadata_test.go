@@ -0,0 +1,218 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+// metadataSink records the tenant of the incoming metadata of each call to
+// ConsumeMetrics, with the names of the consumed metrics.
+type metadataSink struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (s *metadataSink) ConsumeMetrics(ctx context.Context, md pdata.Metrics) error {
+	tenant := "none"
+	if incoming, ok := metadata.FromIncomingContext(ctx); ok {
+		tenant = strings.Join(incoming.Get("tenant"), ",")
+	}
+	names := scrapertest.MetricNames(md)
+	sort.Strings(names)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, tenant+": "+strings.Join(names, " "))
+	return nil
+}
+
+func (s *metadataSink) consumed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := append([]string(nil), s.calls...)
+	sort.Strings(calls)
+	return calls
+}
+
+func namedMetric(name string) pdata.MetricSlice {
+	metrics := pdata.NewMetricSlice()
+	metrics.Resize(1)
+	metrics.At(0).SetName(name)
+	metrics.At(0).SetDataType(pdata.MetricDataTypeIntGauge)
+	metrics.At(0).IntGauge().DataPoints().Resize(1)
+	return metrics
+}
+
+func namedResourceMetric(name string) pdata.ResourceMetricsSlice {
+	resourceMetrics := pdata.NewResourceMetricsSlice()
+	resourceMetrics.Resize(1)
+	resourceMetrics.At(0).InstrumentationLibraryMetrics().Resize(1)
+	namedMetric(name).MoveAndAppendTo(resourceMetrics.At(0).InstrumentationLibraryMetrics().At(0).Metrics())
+	return resourceMetrics
+}
+
+func TestConsumeContextMetadata(t *testing.T) {
+	metricsScraper := func(name string, options ...scraperhelper.ScraperOption) scraperhelper.ScraperControllerOption {
+		return scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper(name,
+			func(context.Context) (pdata.MetricSlice, error) { return namedMetric(name), nil }, options...))
+	}
+	resourceScraper := func(name string, options ...scraperhelper.ScraperOption) scraperhelper.ScraperControllerOption {
+		return scraperhelper.AddResourceMetricsScraper(scraperhelper.NewResourceMetricsScraper(name,
+			func(context.Context) (pdata.ResourceMetricsSlice, error) { return namedResourceMetric(name), nil }, options...))
+	}
+	streamingScraper := func(name string, options ...scraperhelper.ScraperOption) scraperhelper.ScraperControllerOption {
+		return scraperhelper.AddStreamingScraper(scraperhelper.NewStreamingScraper(name,
+			func(_ context.Context, emit scraperhelper.EmitMetrics) error {
+				md := pdata.NewMetrics()
+				md.ResourceMetrics().Append(namedResourceMetric(name).At(0))
+				return emit(md)
+			}, options...))
+	}
+	tenant := func(name string) scraperhelper.ScraperOption {
+		return scraperhelper.WithConsumeContextMetadata(map[string]string{"Tenant": name})
+	}
+	scrapers := []scraperhelper.ScraperControllerOption{
+		metricsScraper("a", tenant("a")),
+		metricsScraper("shared1"),
+		resourceScraper("b", tenant("b")),
+		metricsScraper("c", tenant("c")),
+		resourceScraper("shared2"),
+		metricsScraper("shared3"),
+		streamingScraper("d", tenant("d")),
+	}
+	tenants := []string{
+		"a: a",
+		"b: b",
+		"c: c",
+		"d: d",
+		"none: shared1 shared2 shared3",
+	}
+
+	tests := []struct {
+		name     string
+		options  []scraperhelper.ScraperControllerOption
+		consumed []string
+	}{
+		{
+			name:     "default",
+			options:  scrapers,
+			consumed: tenants,
+		},
+		{
+			name:     "concurrent scrapes",
+			options:  append([]scraperhelper.ScraperControllerOption{scraperhelper.WithMaxConcurrentScrapes(3)}, scrapers...),
+			consumed: tenants,
+		},
+		{
+			name:    "batch flush threshold",
+			options: append([]scraperhelper.ScraperControllerOption{scraperhelper.WithBatchFlushThreshold(1)}, scrapers...),
+			consumed: []string{
+				"a: a",
+				"b: b",
+				"c: c",
+				"d: d",
+				"none: shared1",
+				"none: shared2",
+				"none: shared3",
+			},
+		},
+		{
+			name: "batch flush threshold and concurrent scrapes",
+			options: append([]scraperhelper.ScraperControllerOption{
+				scraperhelper.WithBatchFlushThreshold(2),
+				scraperhelper.WithMaxConcurrentScrapes(3),
+			}, scrapers...),
+			consumed: []string{
+				"a: a",
+				"b: b",
+				"c: c",
+				"d: d",
+				"none: shared1 shared2",
+				"none: shared3",
+			},
+		},
+		{
+			name:    "async consume",
+			options: append([]scraperhelper.ScraperControllerOption{scraperhelper.WithAsyncConsume(10, 2)}, scrapers...),
+			consumed: []string{
+				"a: a",
+				"b: b",
+				"c: c",
+				"d: d",
+				"none: shared1",
+				"none: shared2",
+				"none: shared3",
+			},
+		},
+		{
+			name:     "only scrapers with metadata",
+			options:  []scraperhelper.ScraperControllerOption{metricsScraper("a", tenant("a")), resourceScraper("b", tenant("b"))},
+			consumed: []string{"a: a", "b: b"},
+		},
+		{
+			name:     "single scraper",
+			options:  []scraperhelper.ScraperControllerOption{metricsScraper("a", tenant("a"))},
+			consumed: []string{"a: a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tickerCh := make(chan time.Time)
+			sink := &metadataSink{}
+			cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+			receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), sink,
+				append(tt.options, scraperhelper.WithTickerChannel(tickerCh))...)
+			require.NoError(t, err)
+			require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+			tickerCh <- time.Now()
+			require.Eventually(t, func() bool { return len(sink.consumed()) == len(tt.consumed) }, time.Second, time.Millisecond)
+			require.NoError(t, receiver.Shutdown(context.Background()))
+			assert.Equal(t, tt.consumed, sink.consumed())
+		})
+	}
+}
+
+func TestConsumeContextMetadataEmptyKey(t *testing.T) {
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	_, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), &metadataSink{},
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper",
+			func(context.Context) (pdata.MetricSlice, error) { return namedMetric("scraper"), nil },
+			scraperhelper.WithConsumeContextMetadata(map[string]string{"": "value"}))),
+	)
+	assert.EqualError(t, err, `invalid scraper "scraper": consume context metadata keys must not be empty`)
+}
+
+func TestConsumeContextMetadataEmptyKeyStart(t *testing.T) {
+	scraper := scraperhelper.NewMetricsScraper("scraper",
+		func(context.Context) (pdata.MetricSlice, error) { return namedMetric("scraper"), nil },
+		scraperhelper.WithConsumeContextMetadata(map[string]string{"": "value"}))
+	assert.EqualError(t, scraper.Start(context.Background(), componenttest.NewNopHost()), "consume context metadata keys must not be empty")
+}
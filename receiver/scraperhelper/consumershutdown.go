@@ -0,0 +1,158 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+)
+
+// WithConsumerShutdownDetection stops scraping once the next consumer
+// returned an error matching componenterror.ErrAlreadyStopped, or one of the
+// given errors, with errors.Is, on the given number of consecutive calls, as
+// the components it passes the metrics to were shut down, and the metrics
+// scraped would only be refused. This is logged, and the ticks skipped while
+// scraping is stopped are counted in the
+// scraper/consumer_shutdown_skipped_ticks metric.
+//
+// Scraping resumes when the receiver is restarted, or, with
+// WithConsumerShutdownProbe, once the next consumer recovers.
+func WithConsumerShutdownDetection(consecutive int, shutdownErrs ...error) ScraperControllerOption {
+	return func(o *controller) {
+		if consecutive <= 0 {
+			o.optionErrs = append(o.optionErrs, errors.New("consumer shutdown detection must apply to a positive number of calls"))
+			return
+		}
+		o.shutdownDetector = &consumerShutdownDetector{
+			threshold: consecutive,
+			errs:      append([]error{componenterror.ErrAlreadyStopped}, shutdownErrs...),
+		}
+	}
+}
+
+// WithConsumerShutdownProbe resumes scraping once the next consumer
+// recovers, after WithConsumerShutdownDetection stopped it. While scraping is
+// stopped, the next consumer is passed empty metrics on every tick instead,
+// and scraping resumes on the first tick it no longer returns an error
+// indicating that it was shut down, so the next consumer must accept empty
+// metrics. It requires WithConsumerShutdownDetection.
+func WithConsumerShutdownProbe() ScraperControllerOption {
+	return func(o *controller) {
+		o.probeConsumerShutdown = true
+	}
+}
+
+// consumerShutdownDetector counts the consecutive calls to the next consumer
+// that returned an error indicating that it was shut down. It is safe for
+// concurrent use, as the next consumer may be called by several goroutines
+// with WithAsyncConsume.
+type consumerShutdownDetector struct {
+	threshold int
+	errs      []error
+
+	mu          sync.Mutex
+	consecutive int
+	// stopped is set once threshold is reached, until the next consumer
+	// recovers.
+	stopped bool
+}
+
+// isShutdown reports whether the error indicates that the next consumer was
+// shut down.
+func (d *consumerShutdownDetector) isShutdown(err error) bool {
+	for _, shutdownErr := range d.errs {
+		if errors.Is(err, shutdownErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// record records the error returned by the next consumer, and reports
+// whether it stops scraping.
+func (d *consumerShutdownDetector) record(err error) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil || !d.isShutdown(err) {
+		d.consecutive = 0
+		return false
+	}
+	d.consecutive++
+	if d.stopped || d.consecutive < d.threshold {
+		return false
+	}
+	d.stopped = true
+	return true
+}
+
+func (d *consumerShutdownDetector) isStopped() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stopped
+}
+
+// reset resumes scraping.
+func (d *consumerShutdownDetector) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.consecutive = 0
+	d.stopped = false
+}
+
+// recordConsumeError records the error returned by the next consumer, and
+// logs the shutdown of the next consumer once it stops scraping.
+func (sc *controller) recordConsumeError(err error) {
+	if sc.shutdownDetector == nil || !sc.shutdownDetector.record(err) {
+		return
+	}
+	err = fmt.Errorf("next consumer of receiver %q was shut down, after %d consecutive errors: %w", sc.name, sc.shutdownDetector.threshold, err)
+	if sc.probeConsumerShutdown {
+		sc.logger.Error("Scraping stopped until the next consumer recovers", zap.Error(err))
+	} else {
+		sc.logger.Error("Scraping stopped until the receiver is restarted", zap.Error(err))
+	}
+}
+
+// skipForConsumerShutdown reports whether the tick must be skipped because
+// the next consumer was shut down, which is checked by passing it empty
+// metrics with WithConsumerShutdownProbe. It must only be called from the
+// scrape loop.
+func (sc *controller) skipForConsumerShutdown(ctx context.Context) bool {
+	if sc.shutdownDetector == nil || !sc.shutdownDetector.isStopped() {
+		return false
+	}
+	if !sc.probeConsumerShutdown {
+		obsreport.RecordMetricsScrapeConsumerShutdownSkippedTicks(ctx)
+		return true
+	}
+	err := callWithDeadline(ctx, sc.consumeTimeout, "consume", func(ctx context.Context) error {
+		return sc.nextConsumer.ConsumeMetrics(ctx, pdata.NewMetrics())
+	})
+	if sc.shutdownDetector.isShutdown(err) {
+		obsreport.RecordMetricsScrapeConsumerShutdownSkippedTicks(ctx)
+		return true
+	}
+	sc.shutdownDetector.reset()
+	sc.logger.Info("Resuming scrapes after the next consumer recovered")
+	return false
+}
@@ -0,0 +1,157 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport/obsreporttest"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+func TestConsumerShutdownDetection(t *testing.T) {
+	errPipelineStopped := errors.New("pipeline stopped")
+	stopped := scrapertest.ConsumeStep{Err: componenterror.ErrAlreadyStopped}
+	tests := []struct {
+		name         string
+		shutdownErrs []error
+		probe        bool
+		script       []scrapertest.ConsumeStep
+		// ticks is the number of ticks sent to the receiver.
+		ticks int
+		// wantScrapes is the number of scrapes run, the other calls to the
+		// consumer probing it with empty metrics.
+		wantScrapes int
+		wantCalls   int
+		wantSkipped int64
+	}{
+		{
+			name: "stops until restarted",
+			// two scrapes refused, then two skipped ticks, without probes.
+			script:      []scrapertest.ConsumeStep{stopped, stopped, {}},
+			ticks:       4,
+			wantScrapes: 2,
+			wantCalls:   2,
+			wantSkipped: 2,
+		},
+		{
+			name:  "stops and resumes",
+			probe: true,
+			// two scrapes refused, then one failed probe, then a successful
+			// probe followed by a scrape.
+			script:      []scrapertest.ConsumeStep{stopped, stopped, stopped, {}},
+			ticks:       4,
+			wantScrapes: 3,
+			wantCalls:   5,
+			wantSkipped: 1,
+		},
+		{
+			name:        "other errors reset the count",
+			script:      []scrapertest.ConsumeStep{stopped, {Err: errors.New("boom")}, stopped, {}},
+			ticks:       4,
+			wantScrapes: 4,
+			wantCalls:   4,
+		},
+		{
+			name:         "custom errors",
+			shutdownErrs: []error{errPipelineStopped},
+			probe:        true,
+			script:       []scrapertest.ConsumeStep{stopped, {Err: errPipelineStopped}, {}},
+			ticks:        3,
+			wantScrapes:  3,
+			wantCalls:    4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obsreporttest.SetupRecordedMetrics(t)
+
+			var scrapes atomic.Int64
+			scrape := func(context.Context) (pdata.MetricSlice, error) {
+				scrapes.Inc()
+				return scrapertest.GenerateMetrics(1, 1), nil
+			}
+			next := scrapertest.NewFlakyConsumer(scrapertest.WithConsumeScript(tt.script...))
+			tickerCh := make(chan time.Time)
+			options := []scraperhelper.ScraperControllerOption{
+				scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrape)),
+				scraperhelper.WithConsumerShutdownDetection(2, tt.shutdownErrs...),
+				scraperhelper.WithTickerChannel(tickerCh),
+			}
+			if tt.probe {
+				options = append(options, scraperhelper.WithConsumerShutdownProbe())
+			}
+			cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+			receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), next, options...)
+			require.NoError(t, err)
+
+			host := new(scrapertest.Host)
+			require.NoError(t, receiver.Start(context.Background(), host))
+			for i := 0; i < tt.ticks; i++ {
+				tickerCh <- time.Now()
+			}
+			require.NoError(t, receiver.Shutdown(context.Background()))
+
+			assert.EqualValues(t, tt.wantScrapes, scrapes.Load())
+			calls := next.Calls()
+			require.Len(t, calls, tt.wantCalls)
+			var probes int
+			for _, call := range calls {
+				if call.Metrics.MetricCount() == 0 {
+					probes++
+				}
+			}
+			assert.Equal(t, tt.wantCalls-tt.wantScrapes, probes)
+			// the shutdown of the next consumer is not fatal to the collector.
+			assert.Empty(t, host.FatalErrors())
+			if tt.wantSkipped > 0 {
+				obsreporttest.CheckScraperConsumerShutdownSkippedTicksView(t, "receiver", tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestConsumerShutdownDetectionInvalid(t *testing.T) {
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	_, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1))),
+		scraperhelper.WithConsumerShutdownDetection(0),
+	)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, scraperhelper.ErrInvalidOption))
+	assert.Contains(t, err.Error(), "consumer shutdown detection must apply to a positive number of calls")
+}
+
+func TestConsumerShutdownProbeWithoutDetection(t *testing.T) {
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	_, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1))),
+		scraperhelper.WithConsumerShutdownProbe(),
+	)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, scraperhelper.ErrInvalidOption))
+	assert.Contains(t, err.Error(), "consumer shutdown probe requires consumer shutdown detection")
+}
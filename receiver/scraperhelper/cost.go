@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"go.opentelemetry.io/collector/obsreport"
+)
+
+// ScrapeCost is the cumulative cost of the scrapes of a scraper created by
+// this package, since it was created.
+type ScrapeCost struct {
+	// ID identifies the scraper.
+	ID ScraperID
+	// Scrapes is the number of scrapes, including the scrapes that failed.
+	Scrapes uint64
+	// WallTime is the time the scrapes took. For streaming scrapers, it
+	// includes the time the emitted metrics took to be consumed.
+	WallTime time.Duration
+	// CPUTime approximates the CPU time consumed by the scrapes, if the
+	// receiver measures it with WithCPUAccounting and the platform supports
+	// it. Only the CPU time of the goroutine scraping is measured.
+	CPUTime time.Duration
+}
+
+// WithCPUAccounting measures the CPU time consumed by each scrape, in addition
+// to the time it took, on the platforms that support it, which is currently
+// Linux. The goroutine scraping is locked to its thread during the scrape,
+// and the CPU time of the thread is read before and after the scrape, so that
+// CPU time spent on other goroutines the scraper starts or waits for is not
+// accounted.
+func WithCPUAccounting() ScraperControllerOption {
+	return func(o *controller) {
+		o.cpuAccounting = true
+	}
+}
+
+// scrapeCosts accumulates the costs of the scrapes of a scraper. The counters
+// only ever increase, and may be read while the scraper is scraped.
+type scrapeCosts struct {
+	scrapes  atomic.Uint64
+	wallTime atomic.Int64
+	cpuTime  atomic.Int64
+}
+
+// timeScrape runs the scrape, and accounts the time it took, and the CPU
+// time it consumed if the receiver measures it, to the scraper.
+func (b *baseScraper) timeScrape(ctx context.Context, scrape func()) {
+	start := time.Now()
+	cpuTime, measured := time.Duration(0), false
+	if b.cpuAccounting.Load() {
+		cpuTime, measured = measureCPUTime(scrape)
+	} else {
+		scrape()
+	}
+	wallTime := time.Since(start)
+
+	b.costs.scrapes.Inc()
+	b.costs.wallTime.Add(int64(wallTime))
+	b.stats.lastDuration.Store(int64(wallTime))
+	obsreport.RecordMetricsScrapeWallTime(ctx, wallTime)
+	if measured {
+		b.costs.cpuTime.Add(int64(cpuTime))
+		obsreport.RecordMetricsScrapeCPUTime(ctx, cpuTime)
+	}
+}
+
+// scrapeCost returns the cumulative cost of the scrapes of the scraper,
+// without its ID, which is set by the receiver.
+func (b *baseScraper) scrapeCost() ScrapeCost {
+	return ScrapeCost{
+		Scrapes:  b.costs.scrapes.Load(),
+		WallTime: time.Duration(b.costs.wallTime.Load()),
+		CPUTime:  time.Duration(b.costs.cpuTime.Load()),
+	}
+}
+
+// ScrapeCosts returns the cumulative cost of the scrapes of each of the
+// scrapers created by this package, in the order they were added.
+func (sc *controller) ScrapeCosts() []ScrapeCost {
+	sc.scrapersMu.RLock()
+	defer sc.scrapersMu.RUnlock()
+	var costs []ScrapeCost
+	for _, scraper := range sc.scrapers {
+		if s, ok := scraper.(interface{ scrapeCost() ScrapeCost }); ok {
+			cost := s.scrapeCost()
+			cost.ID = sc.scraperID(scraper.Name())
+			costs = append(costs, cost)
+		}
+	}
+	return costs
+}
@@ -0,0 +1,139 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+	"go.opentelemetry.io/collector/obsreport/obsreporttest"
+)
+
+// busyLoop keeps the CPU busy for the given duration.
+func busyLoop(d time.Duration) {
+	for start := time.Now(); time.Since(start) < d; {
+	}
+}
+
+func TestScrapeCosts(t *testing.T) {
+	busy := func(context.Context) (pdata.MetricSlice, error) {
+		busyLoop(5 * time.Millisecond)
+		return singleMetric(), nil
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), sink,
+		AddMetricsScraper(NewMetricsScraper("busy", busy)),
+		WithCPUAccounting(),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	var previous ScrapeCost
+	for tick := 1; tick <= 3; tick++ {
+		tickerCh <- time.Now()
+		// the costs may be read while the scraper is scraped.
+		require.Eventually(t, func() bool { return receiver.(ScraperInspector).ScrapeCosts()[0].Scrapes == uint64(tick) }, time.Second, time.Millisecond)
+
+		costs := receiver.(ScraperInspector).ScrapeCosts()
+		require.Len(t, costs, 1)
+		cost := costs[0]
+		assert.Equal(t, ScraperID{Receiver: "receiver", Scraper: "busy"}, cost.ID)
+		assert.GreaterOrEqual(t, int64(cost.WallTime), int64(tick)*int64(5*time.Millisecond))
+		assert.Greater(t, int64(cost.WallTime), int64(previous.WallTime))
+		if runtime.GOOS == "linux" {
+			// the CPU time may be less than the wall time, as the busy loop
+			// shares the CPU with the other goroutines.
+			assert.Greater(t, int64(cost.CPUTime), int64(previous.CPUTime))
+			assert.LessOrEqual(t, int64(cost.CPUTime), int64(cost.WallTime))
+		} else {
+			assert.Zero(t, cost.CPUTime)
+		}
+		previous = cost
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+func TestScrapeCostsWithoutCPUAccounting(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		busyLoop(time.Millisecond)
+		return singleMetric(), nil
+	}
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	for tick := 1; tick <= 2; tick++ {
+		tickerCh <- time.Now()
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) == tick }, time.Second, time.Millisecond)
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	costs := receiver.(ScraperInspector).ScrapeCosts()
+	require.Len(t, costs, 1)
+	assert.EqualValues(t, 2, costs[0].Scrapes)
+	assert.GreaterOrEqual(t, int64(costs[0].WallTime), int64(2*time.Millisecond))
+	assert.Zero(t, costs[0].CPUTime)
+
+	// the wall time is recorded, but not the CPU time.
+	wallTime, cpuTime := obsreporttest.ScraperScrapeTimeValues(t, "receiver", "scraper")
+	assert.GreaterOrEqual(t, int64(wallTime), int64(2*time.Millisecond))
+	assert.Zero(t, cpuTime)
+}
+
+func BenchmarkScrapeCostAccounting(b *testing.B) {
+	defer obsreport.Configure(configtelemetry.LevelBasic)
+
+	metrics := pdata.NewMetricSlice()
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return metrics, nil }
+	for _, level := range []configtelemetry.Level{configtelemetry.LevelNone, configtelemetry.LevelBasic} {
+		for _, cpuAccounting := range []bool{false, true} {
+			name := level.String() + "/wall_time"
+			if cpuAccounting {
+				name = level.String() + "/cpu_time"
+			}
+			b.Run(name, func(b *testing.B) {
+				obsreport.Configure(level)
+				scraper := NewMetricsScraper("scraper", scrape).(*metricsScraper)
+				scraper.setReceiverSettings(receiverSettings{cpuAccounting: cpuAccounting})
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					_, _ = scraper.Scrape(context.Background(), "receiver")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package scraperhelper
+
+import (
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// measureCPUTime runs f locked to the current thread, and returns the CPU
+// time the thread consumed, which does not include the CPU time of the
+// goroutines f starts or waits for.
+func measureCPUTime(f func()) (time.Duration, bool) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	start, ok := threadCPUTime()
+	f()
+	if !ok {
+		return 0, false
+	}
+	end, ok := threadCPUTime()
+	if !ok || end < start {
+		return 0, false
+	}
+	return end - start, true
+}
+
+// threadCPUTime returns the CPU time consumed by the current thread, as
+// accounted by the scheduler, which is precise even over short scrapes.
+func threadCPUTime() (time.Duration, bool) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_THREAD_CPUTIME_ID, &ts); err != nil {
+		return 0, false
+	}
+	return time.Duration(ts.Nano()), true
+}
@@ -0,0 +1,177 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+type runningTotal struct {
+	startTime pdata.TimestampUnixNano
+	intTotal  int64
+	total     float64
+	lastSeen  uint64
+}
+
+// deltaAccumulator converts delta sums to cumulative sums, by keeping the
+// running total of each series across scrapes. Series that are not seen for
+// more than maxMissed consecutive scrapes are forgotten, as are the least
+// recently seen series past maxSeries.
+type deltaAccumulator struct {
+	maxMissed uint64
+
+	mu        sync.Mutex
+	maxSeries int
+	scrapes   uint64
+	series    map[string]*runningTotal
+	keys      seriesKeys
+}
+
+func newDeltaAccumulator(maxMissed int) *deltaAccumulator {
+	if maxMissed < 0 {
+		maxMissed = 0
+	}
+	return &deltaAccumulator{
+		maxMissed: uint64(maxMissed),
+		maxSeries: defaultMaxTrackedSeries,
+		series:    map[string]*runningTotal{},
+	}
+}
+
+// setMaxSeries sets the maximum number of series retained between scrapes.
+func (a *deltaAccumulator) setMaxSeries(maxSeries int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.maxSeries = maxSeries
+}
+
+// reset forgets all the running totals.
+func (a *deltaAccumulator) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.scrapes = 0
+	a.series = map[string]*runningTotal{}
+}
+
+// accumulateResourceMetrics converts the delta sums of the resource metrics
+// scraped in a single scrape to cumulative sums, and returns the number of
+// series evicted past the maximum number of series.
+func (a *deltaAccumulator) accumulateResourceMetrics(rms pdata.ResourceMetricsSlice) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.scrapes++
+	for i := 0; i < rms.Len(); i++ {
+		id := resourceID(rms.At(i).Resource())
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			a.accumulate(id, ilms.At(j).Metrics())
+		}
+	}
+	return a.evict()
+}
+
+// accumulateMetrics converts the delta sums of the metrics scraped in a
+// single scrape to cumulative sums, and returns the number of series evicted
+// past the maximum number of series.
+func (a *deltaAccumulator) accumulateMetrics(metrics pdata.MetricSlice) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.scrapes++
+	a.accumulate("", metrics)
+	return a.evict()
+}
+
+func (a *deltaAccumulator) accumulate(resourceID string, metrics pdata.MetricSlice) {
+	for i := 0; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		switch metric.DataType() {
+		case pdata.MetricDataTypeIntSum:
+			sum := metric.IntSum()
+			if sum.AggregationTemporality() != pdata.AggregationTemporalityDelta {
+				continue
+			}
+			dps := sum.DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dp := dps.At(j)
+				total := a.runningTotal(resourceID, metric.Name(), dp.LabelsMap(), dp.StartTime(), dp.Timestamp(), sum.IsMonotonic() && dp.Value() < 0)
+				if dp.Value() > 0 || !sum.IsMonotonic() {
+					total.intTotal += dp.Value()
+				}
+				dp.SetValue(total.intTotal)
+				dp.SetStartTime(total.startTime)
+			}
+			sum.SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+		case pdata.MetricDataTypeDoubleSum:
+			sum := metric.DoubleSum()
+			if sum.AggregationTemporality() != pdata.AggregationTemporalityDelta {
+				continue
+			}
+			dps := sum.DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dp := dps.At(j)
+				total := a.runningTotal(resourceID, metric.Name(), dp.LabelsMap(), dp.StartTime(), dp.Timestamp(), sum.IsMonotonic() && dp.Value() < 0)
+				if dp.Value() > 0 || !sum.IsMonotonic() {
+					total.total += dp.Value()
+				}
+				dp.SetValue(total.total)
+				dp.SetStartTime(total.startTime)
+			}
+			sum.SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+		}
+	}
+}
+
+// runningTotal returns the running total of the series, starting a new one
+// if the series is new or if reset is set.
+func (a *deltaAccumulator) runningTotal(resourceID, name string, labels pdata.StringMap, startTime, timestamp pdata.TimestampUnixNano, reset bool) *runningTotal {
+	key := a.keys.key(resourceID, name, labels)
+	total, ok := a.series[key]
+	if !ok || reset {
+		if startTime == 0 || reset {
+			startTime = timestamp
+		}
+		total = &runningTotal{startTime: startTime}
+		a.series[key] = total
+	}
+	total.lastSeen = a.scrapes
+	return total
+}
+
+// evict forgets the series that have not been seen for more than maxMissed
+// scrapes, then the least recently seen series past maxSeries, and returns
+// the number of the latter.
+func (a *deltaAccumulator) evict() int {
+	for key, total := range a.series {
+		if a.scrapes-total.lastSeen > a.maxMissed {
+			delete(a.series, key)
+		}
+	}
+	excess := len(a.series) - a.maxSeries
+	if excess <= 0 {
+		return 0
+	}
+	seen := make([]seenSeries, 0, len(a.series))
+	for key, total := range a.series {
+		seen = append(seen, seenSeries{key: key, lastSeen: total.lastSeen})
+	}
+	for _, series := range leastRecentlySeen(seen, excess) {
+		delete(a.series, series.key)
+	}
+	return excess
+}
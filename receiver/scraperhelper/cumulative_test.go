@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+type deltaPoint struct {
+	label string
+	value int64
+}
+
+// deltaSum returns a monotonic delta sum with the given data points, at the
+// given timestamp.
+func deltaSum(ts pdata.TimestampUnixNano, points ...deltaPoint) pdata.MetricSlice {
+	metrics := pdata.NewMetricSlice()
+	metrics.Resize(1)
+	metric := metrics.At(0)
+	metric.SetName("requests")
+	metric.SetDataType(pdata.MetricDataTypeIntSum)
+	metric.IntSum().SetAggregationTemporality(pdata.AggregationTemporalityDelta)
+	metric.IntSum().SetIsMonotonic(true)
+	dps := metric.IntSum().DataPoints()
+	dps.Resize(len(points))
+	for i, point := range points {
+		dps.At(i).LabelsMap().Insert("label", point.label)
+		dps.At(i).SetStartTime(ts - 10)
+		dps.At(i).SetTimestamp(ts)
+		dps.At(i).SetValue(point.value)
+	}
+	return metrics
+}
+
+type cumulativePoint struct {
+	value     int64
+	startTime pdata.TimestampUnixNano
+}
+
+func cumulativePoints(t *testing.T, metrics pdata.MetricSlice) map[string]cumulativePoint {
+	sum := metrics.At(0).IntSum()
+	assert.Equal(t, pdata.AggregationTemporalityCumulative, sum.AggregationTemporality())
+	out := map[string]cumulativePoint{}
+	for i := 0; i < sum.DataPoints().Len(); i++ {
+		dp := sum.DataPoints().At(i)
+		label, _ := dp.LabelsMap().Get("label")
+		out[label] = cumulativePoint{value: dp.Value(), startTime: dp.StartTime()}
+	}
+	return out
+}
+
+func TestDeltaToCumulative(t *testing.T) {
+	scrapes := []pdata.MetricSlice{
+		deltaSum(100, deltaPoint{"a", 1}, deltaPoint{"b", 5}),
+		deltaSum(110, deltaPoint{"a", 2}, deltaPoint{"b", 5}),
+		deltaSum(120, deltaPoint{"a", 3}, deltaPoint{"b", -1}),
+		deltaSum(130, deltaPoint{"a", 4}, deltaPoint{"b", 2}),
+	}
+	expected := []map[string]cumulativePoint{
+		{"a": {1, 90}, "b": {5, 90}},
+		{"a": {3, 90}, "b": {10, 90}},
+		// b was reset.
+		{"a": {6, 90}, "b": {0, 120}},
+		{"a": {10, 90}, "b": {2, 120}},
+	}
+
+	i := 0
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		metrics := scrapes[i]
+		i++
+		return metrics, nil
+	}
+
+	scraper := NewMetricsScraper("scraper", scrape, WithDeltaToCumulative(1))
+	require.NoError(t, scraper.Start(context.Background(), componenttest.NewNopHost()))
+	for _, want := range expected {
+		metrics, err := scraper.Scrape(context.Background(), "receiver")
+		require.NoError(t, err)
+		assert.Equal(t, want, cumulativePoints(t, metrics))
+	}
+}
+
+func TestDeltaToCumulativeEviction(t *testing.T) {
+	accumulator := newDeltaAccumulator(1)
+
+	accumulator.accumulateMetrics(deltaSum(100, deltaPoint{"a", 1}, deltaPoint{"b", 1}))
+	// b is missed once, and its running total is kept.
+	accumulator.accumulateMetrics(deltaSum(110, deltaPoint{"a", 1}))
+	metrics := deltaSum(120, deltaPoint{"a", 1}, deltaPoint{"b", 1})
+	accumulator.accumulateMetrics(metrics)
+	assert.Equal(t, map[string]cumulativePoint{"a": {3, 90}, "b": {2, 90}}, cumulativePoints(t, metrics))
+
+	// b is missed twice, and its running total is forgotten.
+	accumulator.accumulateMetrics(deltaSum(130, deltaPoint{"a", 1}))
+	accumulator.accumulateMetrics(deltaSum(140, deltaPoint{"a", 1}))
+	assert.Len(t, accumulator.series, 1)
+	metrics = deltaSum(150, deltaPoint{"a", 1}, deltaPoint{"b", 1})
+	accumulator.accumulateMetrics(metrics)
+	assert.Equal(t, map[string]cumulativePoint{"a": {6, 90}, "b": {1, 140}}, cumulativePoints(t, metrics))
+}
@@ -0,0 +1,77 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+// ScrapeCycle creates a receiver like NewScraperControllerReceiver, and runs a
+// single cycle of it synchronously: the scrapers are initialized, scraped
+// once on the calling goroutine, and their metrics are passed to the next
+// consumer like on a tick of a running receiver, before the scrapers are
+// closed. No ticker is started, so that tests of scrapers are fast and
+// deterministic, although the options that scrape or consume concurrently,
+// or set timeouts, still start goroutines.
+//
+// Scrapers whose initial delay did not elapse when the receiver is started
+// are not scraped. The error of the initialization or of the closing of the
+// scrapers is returned, while scrape errors are only logged, like when the
+// receiver is running. A scraper panicking panics once the scrapers are
+// closed.
+func ScrapeCycle(
+	ctx context.Context,
+	cfg *ScraperControllerSettings,
+	logger *zap.Logger,
+	nextConsumer consumer.MetricsConsumer,
+	host component.Host,
+	options ...ScraperControllerOption,
+) (err error) {
+	options = append(options, func(o *controller) {
+		o.noScrapeLoop = true
+	})
+	receiver, err := NewScraperControllerReceiver(cfg, logger, nextConsumer, options...)
+	if err != nil {
+		return err
+	}
+	sc := receiver.(*controller)
+	if err := sc.Start(ctx, host); err != nil {
+		return err
+	}
+	defer func() {
+		if shutdownErr := sc.Shutdown(ctx); err == nil {
+			err = shutdownErr
+		}
+	}()
+
+	sc.scrapeOnce(contextWithHost(ctx, host))
+	return nil
+}
+
+// scrapeOnce scrapes on a single tick from the calling goroutine, like the
+// scrape loop does.
+func (sc *controller) scrapeOnce(ctx context.Context) {
+	ctx = sc.receiverContext(ctx)
+	if sc.maxConcurrentScrapes > 1 {
+		sc.dispatcher = newScrapeDispatcher(sc.maxConcurrentScrapes)
+		defer sc.dispatcher.stop()
+	}
+	sc.scrapeMetricsAndReport(ctx, sc.now())
+}
@@ -0,0 +1,100 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/obsreport/obsreporttest"
+)
+
+func TestScrapeCycle(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	var calls []string
+	tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+	delayed := &testScrapeMetrics{ch: make(chan int, 1)}
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	require.NoError(t, ScrapeCycle(
+		context.Background(),
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		componenttest.NewNopHost(),
+		AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape,
+			WithStart(func(context.Context, component.Host) error {
+				calls = append(calls, "start")
+				return nil
+			}),
+			WithShutdown(func(context.Context) error {
+				calls = append(calls, "shutdown")
+				return nil
+			}),
+		)),
+		AddMetricsScraper(NewMetricsScraper("delayed", delayed.scrape, WithInitialDelay(time.Hour))),
+	))
+
+	assert.Equal(t, []string{"start", "shutdown"}, calls)
+	assert.Equal(t, 1, tsm.timesScrapeCalled)
+	assert.Equal(t, 0, delayed.timesScrapeCalled)
+	assert.Equal(t, 1, sink.MetricsCount())
+	obsreporttest.CheckScraperMetricsViews(t, "receiver", "scraper", 1, 0)
+}
+
+func TestScrapeCycleErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ScraperOption
+		err     string
+	}{
+		{
+			name:    "Start",
+			options: []ScraperOption{WithStart(func(context.Context, component.Host) error { return errors.New("err1") })},
+			err:     `failed to initialize scraper "scraper": err1`,
+		},
+		{
+			name:    "Shutdown",
+			options: []ScraperOption{WithShutdown(func(context.Context) error { return errors.New("err2") })},
+			err:     "err2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tsm := &testScrapeMetrics{ch: make(chan int, 1), err: errors.New("scrape errors are not returned")}
+			defaultCfg := DefaultScraperControllerSettings("receiver")
+			err := ScrapeCycle(
+				context.Background(),
+				&defaultCfg,
+				zap.NewNop(),
+				new(consumertest.MetricsSink),
+				componenttest.NewNopHost(),
+				AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape, test.options...)),
+			)
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// WithMaxConcurrentScrapes scrapes the scrapers due on a tick concurrently,
+// on a pool of n workers started with the scrape loop, instead of one after
+// the other on the scrape loop goroutine. A scraper is never scraped
+// concurrently with itself. The scraped metrics are only assembled once all
+// the scrapers due on the tick have been scraped, in the order of the
+// scrapers, so that the payloads passed to the next consumer do not depend on
+// the order the scrapes finished in. A value of 1, the default, scrapes
+// sequentially.
+func WithMaxConcurrentScrapes(n int) ScraperControllerOption {
+	return func(o *controller) {
+		if n < 1 {
+			o.optionErrs = append(o.optionErrs, errors.New("max concurrent scrapes must be positive"))
+			return
+		}
+		o.maxConcurrentScrapes = n
+	}
+}
+
+// scrapeResult is the result of the scrape of a MetricsScraper or
+// ResourceMetricsScraper.
+type scrapeResult struct {
+	metrics         pdata.MetricSlice
+	resourceMetrics pdata.ResourceMetricsSlice
+	err             error
+	// panicked is set if the scrape panicked.
+	panicked *scraperPanic
+}
+
+// scraperPanic is the value a scrape dispatcher panics with again when a
+// scrape panicked, keeping the value the scraper panicked with.
+type scraperPanic struct {
+	scraper string
+	value   interface{}
+}
+
+func (p *scraperPanic) Error() string {
+	return fmt.Sprintf("scraper %q: %v", p.scraper, p.value)
+}
+
+// Unwrap returns the value the scraper panicked with if it is an error.
+func (p *scraperPanic) Unwrap() error {
+	err, _ := p.value.(error)
+	return err
+}
+
+type scrapeJob struct {
+	ctx          context.Context
+	receiverName string
+	scraper      BaseScraper
+	// slot is the single-slot channel of the scraper.
+	slot   chan struct{}
+	result *scrapeResult
+	done   *sync.WaitGroup
+}
+
+// scrapeDispatcher scrapes scrapers on a fixed pool of workers. Each scraper
+// holds a single-slot channel while it is scraped, so that it is never
+// scraped by two workers at once. The workers only share the jobs channel:
+// each job carries the slot of its scraper and the result it writes to, so
+// that the scrapes of different scrapers never contend on a lock.
+type scrapeDispatcher struct {
+	jobs    chan scrapeJob
+	workers sync.WaitGroup
+
+	// slots is only accessed by the goroutine dispatching the scrapes.
+	slots map[string]chan struct{}
+}
+
+func newScrapeDispatcher(workers int) *scrapeDispatcher {
+	d := &scrapeDispatcher{
+		jobs:  make(chan scrapeJob),
+		slots: map[string]chan struct{}{},
+	}
+	d.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer d.workers.Done()
+			for job := range d.jobs {
+				d.run(job)
+			}
+		}()
+	}
+	return d
+}
+
+// stop stops the workers once they are done with the current scrapes.
+func (d *scrapeDispatcher) stop() {
+	close(d.jobs)
+	d.workers.Wait()
+}
+
+// scrape scrapes the given scrapers on the workers, and waits until they have
+// all been scraped. The results of the resource scrapers, then of the metrics
+// scrapers, are returned in the order of the scrapers, reusing the given
+// slice. A panic of any of the scrapes is raised again once all of them are
+// done, with a *scraperPanic keeping the value the scraper panicked with, so
+// that it is handled by the scrape loop, and if several of them panicked,
+// the panic of the first scraper in that order is raised.
+func (d *scrapeDispatcher) scrape(ctx context.Context, receiverName string, resourceScrapers []ResourceMetricsScraper, metricsScrapers []MetricsScraper, results []scrapeResult) []scrapeResult {
+	n := len(resourceScrapers) + len(metricsScrapers)
+	if cap(results) < n {
+		results = make([]scrapeResult, n)
+	}
+	results = results[:n]
+	for i := range results {
+		results[i] = scrapeResult{}
+	}
+
+	var done sync.WaitGroup
+	done.Add(n)
+	dispatch := func(i int, scraper BaseScraper) {
+		d.jobs <- scrapeJob{
+			ctx:          ctx,
+			receiverName: receiverName,
+			scraper:      scraper,
+			slot:         d.slot(scraper.Name()),
+			result:       &results[i],
+			done:         &done,
+		}
+	}
+	for i, rms := range resourceScrapers {
+		dispatch(i, rms)
+	}
+	for i, ms := range metricsScrapers {
+		dispatch(len(resourceScrapers)+i, ms)
+	}
+	done.Wait()
+
+	for i := range results {
+		if panicked := results[i].panicked; panicked != nil {
+			panic(panicked)
+		}
+	}
+	return results
+}
+
+// run scrapes the scraper of the job once it holds the slot of the scraper.
+func (d *scrapeDispatcher) run(job scrapeJob) {
+	defer job.done.Done()
+
+	job.slot <- struct{}{}
+	defer func() { <-job.slot }()
+
+	defer func() {
+		if r := recover(); r != nil {
+			job.result.panicked = &scraperPanic{scraper: job.scraper.Name(), value: r}
+		}
+	}()
+	switch s := job.scraper.(type) {
+	case ResourceMetricsScraper:
+		job.result.resourceMetrics, job.result.err = s.Scrape(job.ctx, job.receiverName)
+	case MetricsScraper:
+		job.result.metrics, job.result.err = s.Scrape(job.ctx, job.receiverName)
+	}
+}
+
+// prune forgets the slots of the scrapers that are no longer registered,
+// once there are more slots than registered scrapers, as scrapers are removed
+// or replaced by others. It must be called by the goroutine dispatching the
+// scrapes, between two of them.
+func (d *scrapeDispatcher) prune(scrapers []BaseScraper) {
+	if len(d.slots) <= len(scrapers) {
+		return
+	}
+	registered := make(map[string]bool, len(scrapers))
+	for _, scraper := range scrapers {
+		registered[scraper.Name()] = true
+	}
+	for name := range d.slots {
+		if !registered[name] {
+			delete(d.slots, name)
+		}
+	}
+}
+
+// slot returns the single-slot channel of the scraper with the given name,
+// which is unique within the receiver.
+func (d *scrapeDispatcher) slot(name string) chan struct{} {
+	slot, ok := d.slots[name]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		d.slots[name] = slot
+	}
+	return slot
+}
@@ -0,0 +1,270 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// selfConcurrencyScrape returns a scrape function that counts its scrapes,
+// and the scrapes that overlapped another scrape of the same function.
+func selfConcurrencyScrape(name string, scrapes, overlaps *int32) ScrapeMetrics {
+	var inFlight int32
+	return func(context.Context) (pdata.MetricSlice, error) {
+		if atomic.AddInt32(&inFlight, 1) > 1 {
+			atomic.AddInt32(overlaps, 1)
+		}
+		defer atomic.AddInt32(&inFlight, -1)
+		atomic.AddInt32(scrapes, 1)
+		runtime.Gosched()
+
+		metrics := pdata.NewMetricSlice()
+		metrics.Resize(1)
+		metrics.At(0).SetName(name)
+		return metrics, nil
+	}
+}
+
+func TestMaxConcurrentScrapesStress(t *testing.T) {
+	const scrapers, ticks = 100, 50
+	for _, async := range []bool{false, true} {
+		t.Run(fmt.Sprintf("async=%v", async), func(t *testing.T) {
+			tickerCh := make(chan time.Time)
+			sink := new(consumertest.MetricsSink)
+			options := []ScraperControllerOption{WithTickerChannel(tickerCh), WithMaxConcurrentScrapes(8)}
+			if async {
+				options = append(options, WithAsyncConsume(scrapers*ticks, 1))
+			}
+			scrapes := make([]int32, scrapers)
+			overlaps := make([]int32, scrapers)
+			var names []string
+			for i := 0; i < scrapers; i++ {
+				name := fmt.Sprintf("scraper%d", i)
+				names = append(names, name)
+				options = append(options, AddMetricsScraper(NewMetricsScraper(name, selfConcurrencyScrape(name, &scrapes[i], &overlaps[i]))))
+			}
+			defaultCfg := DefaultScraperControllerSettings("receiver")
+			receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), sink, options...)
+			require.NoError(t, err)
+			require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+			for i := 0; i < ticks; i++ {
+				tickerCh <- time.Now()
+			}
+			require.NoError(t, receiver.Shutdown(context.Background()))
+
+			for i := 0; i < scrapers; i++ {
+				assert.EqualValues(t, ticks, atomic.LoadInt32(&scrapes[i]), names[i])
+				assert.Zero(t, atomic.LoadInt32(&overlaps[i]), names[i])
+			}
+			if async {
+				assert.Equal(t, scrapers*ticks, sink.MetricsCount())
+				return
+			}
+			// the metrics are passed in the order of the scrapers, whichever
+			// scrape finished first.
+			require.Len(t, sink.AllMetrics(), ticks)
+			for _, md := range sink.AllMetrics() {
+				metrics := md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+				require.Equal(t, scrapers, metrics.Len())
+				for i := 0; i < metrics.Len(); i++ {
+					assert.Equal(t, names[i], metrics.At(i).Name())
+				}
+			}
+		})
+	}
+}
+
+func TestScrapeDispatcherSerializesScraper(t *testing.T) {
+	var scrapes, overlaps int32
+	scraper := NewMetricsScraper("scraper", selfConcurrencyScrape("scraper", &scrapes, &overlaps))
+	dispatcher := newScrapeDispatcher(8)
+	defer dispatcher.stop()
+
+	// the same scraper dispatched many times at once is still scraped one
+	// scrape at a time.
+	scrapers := make([]MetricsScraper, 100)
+	for i := range scrapers {
+		scrapers[i] = scraper
+	}
+	results := dispatcher.scrape(context.Background(), "receiver", nil, scrapers, nil)
+	require.Len(t, results, len(scrapers))
+	for _, result := range results {
+		require.NoError(t, result.err)
+		assert.Equal(t, 1, result.metrics.Len())
+	}
+	assert.EqualValues(t, len(scrapers), scrapes)
+	assert.Zero(t, overlaps)
+}
+
+func TestMaxConcurrentScrapesPanic(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	scrape := func(context.Context) (pdata.ResourceMetricsSlice, error) { panic("boom") }
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddResourceMetricsScraper(NewResourceMetricsScraper("scraper", scrape)),
+		WithTickerChannel(tickerCh),
+		WithMaxConcurrentScrapes(2),
+	)
+	require.NoError(t, err)
+
+	host := componenttest.NewErrorWaitingHost()
+	require.NoError(t, receiver.Start(context.Background(), host))
+
+	tickerCh <- time.Now()
+	received, err := host.WaitForFatalError(time.Second)
+	require.True(t, received)
+	assert.EqualError(t, err, `scraping for receiver "receiver" with scrapers ["scraper"] stopped unexpectedly: panic: scraper "scraper": boom`)
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+func TestMaxConcurrentScrapesInvalid(t *testing.T) {
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithMaxConcurrentScrapes(0),
+	)
+	assert.EqualError(t, err, "max concurrent scrapes must be positive")
+}
+
+func TestScrapeDispatcherPanicsInScraperOrder(t *testing.T) {
+	dispatcher := newScrapeDispatcher(32)
+	defer dispatcher.stop()
+
+	// several of the scrapers panic concurrently, and the panic of the first
+	// of them is raised.
+	scrapers := make([]MetricsScraper, 32)
+	for i := range scrapers {
+		i := i
+		scrapers[i] = NewMetricsScraper(fmt.Sprintf("scraper%d", i), func(context.Context) (pdata.MetricSlice, error) {
+			if i%8 == 3 {
+				panic(fmt.Sprintf("boom%d", i))
+			}
+			return singleMetric(), nil
+		})
+	}
+	for i := 0; i < 10; i++ {
+		assert.PanicsWithError(t, `scraper "scraper3": boom3`, func() {
+			dispatcher.scrape(context.Background(), "receiver", nil, scrapers, nil)
+		})
+	}
+}
+
+func TestMaxConcurrentScrapesPanicWithError(t *testing.T) {
+	errBoom := errors.New("boom")
+	tickerCh := make(chan time.Time)
+	scrape := func(context.Context) (pdata.MetricSlice, error) { panic(errBoom) }
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithTickerChannel(tickerCh),
+		WithMaxConcurrentScrapes(2),
+	)
+	require.NoError(t, err)
+
+	host := componenttest.NewErrorWaitingHost()
+	require.NoError(t, receiver.Start(context.Background(), host))
+
+	tickerCh <- time.Now()
+	received, err := host.WaitForFatalError(time.Second)
+	require.True(t, received)
+	assert.EqualError(t, err, `scraping for receiver "receiver" with scrapers ["scraper"] stopped unexpectedly: panic: scraper "scraper": boom`)
+	assert.True(t, errors.Is(err, errBoom))
+	var panicked *scraperPanic
+	require.True(t, errors.As(err, &panicked))
+	assert.Equal(t, errBoom, panicked.value)
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+func TestScrapeDispatcherPrune(t *testing.T) {
+	dispatcher := newScrapeDispatcher(2)
+	defer dispatcher.stop()
+
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	scrapers := []MetricsScraper{
+		NewMetricsScraper("scraper0", scrape),
+		NewMetricsScraper("scraper1", scrape),
+		NewMetricsScraper("scraper2", scrape),
+	}
+	dispatcher.scrape(context.Background(), "receiver", nil, scrapers, nil)
+	require.Len(t, dispatcher.slots, 3)
+
+	dispatcher.prune([]BaseScraper{scrapers[0], scrapers[1], scrapers[2]})
+	assert.Len(t, dispatcher.slots, 3)
+
+	// the slots of the removed scrapers are forgotten.
+	dispatcher.prune([]BaseScraper{scrapers[1]})
+	assert.Len(t, dispatcher.slots, 1)
+	assert.Contains(t, dispatcher.slots, "scraper1")
+}
+
+// spinSink keeps the work of spinScrape from being optimized away.
+var spinSink uint64
+
+// spinScrape returns a scrape function that burns CPU for a fixed number of
+// iterations, without locking or allocating.
+func spinScrape(iterations int) ScrapeMetrics {
+	metrics := pdata.NewMetricSlice()
+	return func(context.Context) (pdata.MetricSlice, error) {
+		x := uint64(1)
+		for i := 0; i < iterations; i++ {
+			x = x*6364136223846793005 + 1442695040888963407
+		}
+		atomic.AddUint64(&spinSink, x)
+		return metrics, nil
+	}
+}
+
+// BenchmarkScrapeDispatcherContention dispatches 32 CPU bound scrapers on an
+// increasing number of workers. As the scrapes of different scrapers share no
+// lock, the time per tick scales down with the workers up to GOMAXPROCS.
+func BenchmarkScrapeDispatcherContention(b *testing.B) {
+	scrapers := make([]MetricsScraper, 32)
+	for i := range scrapers {
+		scrapers[i] = NewMetricsScraper(fmt.Sprintf("scraper%d", i), spinScrape(20000))
+	}
+	for _, workers := range []int{1, 2, 4, 8, 16, 32} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			dispatcher := newScrapeDispatcher(workers)
+			defer dispatcher.stop()
+
+			var results []scrapeResult
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				results = dispatcher.scrape(context.Background(), "receiver", nil, scrapers, results)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+)
+
+// dryRunOutcome is the outcome logged for the metrics dropped because of a
+// dry run.
+const dryRunOutcome = "dry_run"
+
+// WithDryRun scrapes the scrapers of the receiver as usual, but drops the
+// scraped metrics instead of passing them to the next consumer, which is
+// never called, so that new scrapers can be validated in production without
+// their metrics entering the pipeline. The scrapes are observed, and their
+// stats, costs and effective configuration recorded, as without a dry run.
+//
+// The metric points dropped are counted by the dry run metric points metric,
+// and logged at debug level with the "dry_run" outcome. The dry run is logged
+// when the receiver starts, and reported by EffectiveConfig.
+func WithDryRun() ScraperControllerOption {
+	return func(o *controller) {
+		o.dryRun = true
+	}
+}
+
+// WithScraperDryRun drops the metrics of the scraper instead of passing them
+// to the next consumer, like WithDryRun does for all the scrapers of a
+// receiver, while the metrics of the other scrapers are passed on. The
+// metrics of the scraper are processed separately from those of the other
+// scrapers.
+func WithScraperDryRun() ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.dryRun = true
+	}
+}
+
+// dryRun reports whether the metrics of the scraper are dropped because of
+// WithScraperDryRun.
+func dryRun(scraper BaseScraper) bool {
+	s, ok := scraper.(interface{ isDryRun() bool })
+	return ok && s.isDryRun()
+}
+
+func (b *baseScraper) isDryRun() bool {
+	return b.dryRun
+}
+
+// dropDryRun drops the metrics of the payload instead of passing them to the
+// next consumer, counting and logging the dropped metric points. The
+// scrapers whose first successful scrape the metrics hold are recorded, as
+// their metrics went through the receiver.
+func (sc *controller) dropDryRun(ctx context.Context, payload scrapedPayload, metrics pdata.Metrics) {
+	_, dataPointCount := metrics.MetricAndDataPointCount()
+	if payload.scraper != "" {
+		ctx = obsreport.ScraperContext(ctx, sc.name, payload.scraper)
+	}
+	obsreport.RecordMetricsScrapeDryRunPoints(ctx, dataPointCount)
+	sc.logger.Debug("Dropped scraped metrics",
+		zap.String("outcome", dryRunOutcome),
+		zap.String("scraper", payload.scraper),
+		zap.Int("data_points", dataPointCount))
+	sc.firstScrapes.record(payload.firstScrapes)
+	sc.releaseMetrics(metrics)
+}
+
+// logDryRun logs the dry run of the receiver, or of its scrapers, when the
+// receiver starts.
+func (sc *controller) logDryRun() {
+	if sc.dryRun {
+		sc.logger.Warn("Dry run: the scraped metrics are dropped instead of being passed to the next consumer")
+		return
+	}
+	var names []string
+	for _, scraper := range sc.registeredScrapers() {
+		if dryRun(scraper) {
+			names = append(names, scraper.Name())
+		}
+	}
+	if len(names) > 0 {
+		sc.logger.Warn("Dry run: the metrics of scrapers are dropped instead of being passed to the next consumer",
+			zap.Strings("scrapers", names))
+	}
+}
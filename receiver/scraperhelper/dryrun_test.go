@@ -0,0 +1,115 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/obsreport/obsreporttest"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+func TestDryRun(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	sink := new(scrapertest.SinkConsumer)
+	tickerCh := make(chan time.Time)
+	core, logs := observer.New(zap.DebugLevel)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.New(core), sink,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("metrics", scrapertest.NewScrapeMetrics(2, 1))),
+		scraperhelper.AddResourceMetricsScraper(scraperhelper.NewResourceMetricsScraper("resource", scrapertest.NewScrapeResourceMetrics(1, 3, 1))),
+		scraperhelper.WithDryRun(),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	assert.Equal(t, 1, logs.FilterMessageSnippet("Dry run").Len())
+
+	tickerCh <- time.Now()
+	tickerCh <- time.Now()
+	// the first scrapes are ready although their metrics were dropped.
+	require.NoError(t, receiver.(scraperhelper.FirstScrapeWaiter).WaitForFirstScrape(context.Background()))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	assert.Zero(t, sink.Calls())
+	obsreporttest.CheckScraperMetricsViews(t, "receiver", "metrics", 4, 0)
+	obsreporttest.CheckScraperMetricsViews(t, "receiver", "resource", 6, 0)
+	obsreporttest.CheckScraperDryRunMetricPointsView(t, "receiver", "", 10)
+
+	dropped := logs.FilterMessage("Dropped scraped metrics").All()
+	require.Len(t, dropped, 2)
+	for _, entry := range dropped {
+		assert.Equal(t, "dry_run", entry.ContextMap()["outcome"])
+		assert.EqualValues(t, 5, entry.ContextMap()["data_points"])
+	}
+
+	for _, cfg := range receiver.(scraperhelper.ScraperInspector).EffectiveConfig() {
+		require.NotNil(t, cfg.DryRun, cfg.Name)
+		assert.Equal(t, scraperhelper.EffectiveBool{Value: true, Source: scraperhelper.ConfigSourceReceiver}, *cfg.DryRun)
+	}
+}
+
+func TestScraperDryRun(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	sink := new(scrapertest.SinkConsumer)
+	tickerCh := make(chan time.Time)
+	core, logs := observer.New(zap.InfoLevel)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.New(core), sink,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("metrics", scrapertest.NewScrapeMetrics(2, 1))),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("dry", scrapertest.NewScrapeMetrics(3, 1), scraperhelper.WithScraperDryRun())),
+		scraperhelper.AddResourceMetricsScraper(scraperhelper.NewResourceMetricsScraper("resource", scrapertest.NewScrapeResourceMetrics(1, 1, 1), scraperhelper.WithScraperDryRun())),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	started := logs.FilterMessageSnippet("Dry run").All()
+	require.Len(t, started, 1)
+	assert.ElementsMatch(t, []interface{}{"dry", "resource"}, started[0].ContextMap()["scrapers"])
+
+	tickerCh <- time.Now()
+	tickerCh <- time.Now()
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	// only the metrics of the other scraper are passed to the next consumer.
+	require.Len(t, sink.Batches(), 2)
+	for _, md := range sink.Batches() {
+		metricCount, dataPointCount := md.MetricAndDataPointCount()
+		assert.Equal(t, 2, metricCount)
+		assert.Equal(t, 2, dataPointCount)
+	}
+	obsreporttest.CheckScraperMetricsViews(t, "receiver", "dry", 6, 0)
+	obsreporttest.CheckScraperDryRunMetricPointsView(t, "receiver", "dry", 6)
+	obsreporttest.CheckScraperDryRunMetricPointsView(t, "receiver", "resource", 2)
+
+	dryRuns := make(map[string]*scraperhelper.EffectiveBool)
+	for _, cfg := range receiver.(scraperhelper.ScraperInspector).EffectiveConfig() {
+		dryRuns[cfg.Name] = cfg.DryRun
+	}
+	assert.Nil(t, dryRuns["metrics"])
+	assert.Equal(t, &scraperhelper.EffectiveBool{Value: true, Source: scraperhelper.ConfigSourceOption}, dryRuns["dry"])
+	assert.Equal(t, &scraperhelper.EffectiveBool{Value: true, Source: scraperhelper.ConfigSourceOption}, dryRuns["resource"])
+}
@@ -0,0 +1,128 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ConfigSource specifies where the effective value of a scraper setting comes
+// from.
+type ConfigSource string
+
+const (
+	// ConfigSourceOption means the value was set with a ScraperOption.
+	ConfigSourceOption ConfigSource = "option"
+	// ConfigSourceScraperConfig means the value was set in the ScraperConfig
+	// of the scraper.
+	ConfigSourceScraperConfig ConfigSource = "scraper_config"
+	// ConfigSourceReceiver means the value is the one of the receiver, either
+	// configured or its default.
+	ConfigSourceReceiver ConfigSource = "receiver"
+	// ConfigSourceEnv means the value was read from an environment variable
+	// with WithEnvDefaults.
+	ConfigSourceEnv ConfigSource = "env"
+)
+
+// EffectiveBool is the effective value of a boolean scraper setting.
+type EffectiveBool struct {
+	Value  bool         `json:"value"`
+	Source ConfigSource `json:"source"`
+}
+
+// EffectiveDuration is the effective value of a duration scraper setting.
+// Durations are serialized in the format of time.Duration.String.
+type EffectiveDuration struct {
+	Value  time.Duration
+	Source ConfigSource
+}
+
+// MarshalJSON serializes the duration as a human readable string.
+func (d EffectiveDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Value  string       `json:"value"`
+		Source ConfigSource `json:"source"`
+	}{
+		Value:  d.Value.String(),
+		Source: d.Source,
+	})
+}
+
+// EffectiveScraperConfig is the configuration a scraper is scraped with, once
+// the defaults of the receiver have been applied. Only the settings shared by
+// all scrapers are included, and the settings of disabled scrapers other than
+// Enabled are not reported.
+type EffectiveScraperConfig struct {
+	Name               string             `json:"name"`
+	Enabled            EffectiveBool      `json:"enabled"`
+	CollectionInterval *EffectiveDuration `json:"collection_interval,omitempty"`
+	// Timeout is the time a scrape may take, where zero means no timeout.
+	Timeout      *EffectiveDuration `json:"timeout,omitempty"`
+	InitialDelay *EffectiveDuration `json:"initial_delay,omitempty"`
+	// DryRun is set if the metrics of the scraper are dropped by WithDryRun
+	// or WithScraperDryRun.
+	DryRun *EffectiveBool `json:"dry_run,omitempty"`
+}
+
+// EffectiveConfig returns the effective configuration of each of the scrapers
+// added to the receiver, in the order they were added, followed by the
+// disabled scrapers.
+func (sc *controller) EffectiveConfig() []EffectiveScraperConfig {
+	sc.scrapersMu.RLock()
+	defer sc.scrapersMu.RUnlock()
+
+	configs := make([]EffectiveScraperConfig, 0, len(sc.scrapers)+len(sc.disabledScrapers))
+	for _, scraper := range sc.scrapers {
+		cfg := EffectiveScraperConfig{
+			Name:               scraper.Name(),
+			Enabled:            EffectiveBool{Value: true, Source: ConfigSourceReceiver},
+			CollectionInterval: &EffectiveDuration{Value: sc.collectionInterval, Source: ConfigSourceReceiver},
+			Timeout:            &EffectiveDuration{Source: ConfigSourceReceiver},
+			InitialDelay:       &EffectiveDuration{Value: sc.defaultInitialDelay, Source: ConfigSourceReceiver},
+		}
+		if s, ok := scraper.(interface{ effectiveConfig(*EffectiveScraperConfig) }); ok {
+			s.effectiveConfig(&cfg)
+		}
+		if sc.envTimeout > 0 && envTimeoutApplies(scraper) {
+			cfg.Timeout = &EffectiveDuration{Value: sc.envTimeout, Source: ConfigSourceEnv}
+		}
+		if sc.dryRun {
+			cfg.DryRun = &EffectiveBool{Value: true, Source: ConfigSourceReceiver}
+		} else if dryRun(scraper) {
+			cfg.DryRun = &EffectiveBool{Value: true, Source: ConfigSourceOption}
+		}
+		// the collection interval may have been updated in place by
+		// UpdateConfig.
+		if scraperCfg := sc.scraperConfig(scraper); scraperCfg != nil && scraperCfg.CollectionInterval() > 0 {
+			cfg.CollectionInterval = &EffectiveDuration{Value: scraperCfg.CollectionInterval(), Source: ConfigSourceScraperConfig}
+		} else if sc.envCollectionInterval > 0 {
+			cfg.CollectionInterval = &EffectiveDuration{Value: sc.envCollectionInterval, Source: ConfigSourceEnv}
+		}
+		configs = append(configs, cfg)
+	}
+
+	for _, disabled := range sc.disabledScrapers {
+		source := ConfigSourceOption
+		if disabled.Reason == DisabledByZeroInterval {
+			source = ConfigSourceReceiver
+		}
+		configs = append(configs, EffectiveScraperConfig{
+			Name:    disabled.ID.Scraper,
+			Enabled: EffectiveBool{Value: false, Source: source},
+		})
+	}
+	return configs
+}
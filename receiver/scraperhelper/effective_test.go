@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+type secretScraperConfig struct {
+	ScraperSettings `mapstructure:",squash"`
+	Password        string `mapstructure:"password"`
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	cfg := &secretScraperConfig{
+		ScraperSettings: ScraperSettings{
+			TimeoutVal:            10 * time.Second,
+			InitialDelayVal:       5 * time.Second,
+			CollectionIntervalVal: 2 * time.Minute,
+		},
+		Password: "hunter2",
+	}
+
+	receiverCfg := DefaultScraperControllerSettings("receiver")
+	receiverCfg.CollectionInterval = 30 * time.Second
+	receiver, err := NewScraperControllerReceiver(
+		&receiverCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("defaults", scrape)),
+		AddMetricsScraper(NewMetricsScraper("configured", scrape, WithConfig(cfg))),
+		AddMetricsScraper(NewMetricsScraper("options", scrape, WithConfig(cfg), WithInitialDelay(time.Second), WithEnabled(true))),
+		AddMetricsScraper(NewMetricsScraper("disabled", scrape, WithConfig(cfg), WithEnabled(false))),
+		WithMinCollectionInterval(time.Minute),
+		WithIntervalPolicy(ClampInterval),
+		WithDefaultInitialDelay(15*time.Second),
+	)
+	require.NoError(t, err)
+
+	actual, err := json.MarshalIndent(receiver.(ScraperInspector).EffectiveConfig(), "", "  ")
+	require.NoError(t, err)
+	expected, err := ioutil.ReadFile(path.Join(".", "testdata", "effective_config.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+	assert.NotContains(t, string(actual), cfg.Password)
+}
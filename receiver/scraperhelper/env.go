@@ -0,0 +1,117 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WithEnvDefaults reads host-wide defaults for the settings of the scrapers
+// from environment variables when the receiver is created:
+//
+// ${prefix}_COLLECTION_INTERVAL is the collection interval of the scrapers
+// without an interval in their configuration.
+//
+// ${prefix}_TIMEOUT is the time a scrape may take, and unless set otherwise,
+// the time the initialization of the scraper may take, for the scrapers
+// without a timeout in their configuration.
+//
+// Unset or empty variables are ignored. Variables that are not valid
+// durations, or are negative, make NewScraperControllerReceiver fail. The
+// defaults applied to the scrapers the receiver is created with are logged
+// with the variables they come from.
+func WithEnvDefaults(prefix string) ScraperControllerOption {
+	return func(o *controller) {
+		if prefix == "" {
+			o.optionErrs = append(o.optionErrs, errors.New("environment variable prefix must not be empty"))
+			return
+		}
+		o.envPrefix = prefix
+		var err error
+		if o.envCollectionInterval, err = envDuration(prefix + envCollectionIntervalSuffix); err != nil {
+			o.optionErrs = append(o.optionErrs, err)
+		}
+		if o.envTimeout, err = envDuration(prefix + envTimeoutSuffix); err != nil {
+			o.optionErrs = append(o.optionErrs, err)
+		}
+	}
+}
+
+const (
+	envCollectionIntervalSuffix = "_COLLECTION_INTERVAL"
+	envTimeoutSuffix            = "_TIMEOUT"
+)
+
+// envDuration reads a duration from an environment variable.
+func envDuration(name string) (time.Duration, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil {
+		err = checkDuration(d)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid environment variable %s=%q: %w", name, value, err)
+	}
+	return d, nil
+}
+
+// logEnvDefaults logs the defaults read from environment variables, with the
+// scrapers they apply to.
+func (sc *controller) logEnvDefaults() {
+	var intervalScrapers, timeoutScrapers []string
+	for _, scraper := range sc.scrapers {
+		if sc.envCollectionInterval > 0 && sc.envIntervalApplies(scraper) {
+			intervalScrapers = append(intervalScrapers, scraper.Name())
+		}
+		if sc.envTimeout > 0 && envTimeoutApplies(scraper) {
+			timeoutScrapers = append(timeoutScrapers, scraper.Name())
+		}
+	}
+
+	log := func(suffix string, d time.Duration, scrapers []string) {
+		if len(scrapers) == 0 {
+			return
+		}
+		sc.logger.Info("Applying scraper default from environment variable",
+			zap.String("variable", sc.envPrefix+suffix),
+			zap.Duration("value", d),
+			zap.Strings("scrapers", scrapers))
+	}
+	log(envCollectionIntervalSuffix, sc.envCollectionInterval, intervalScrapers)
+	log(envTimeoutSuffix, sc.envTimeout, timeoutScrapers)
+}
+
+// envIntervalApplies reports whether the scraper has no collection interval
+// of its own, so that the default read from the environment applies. It must
+// be called with scrapersMu held.
+func (sc *controller) envIntervalApplies(scraper BaseScraper) bool {
+	cfg := sc.scraperConfig(scraper)
+	return cfg == nil || cfg.CollectionInterval() <= 0
+}
+
+// envTimeoutApplies reports whether the scraper was created by this package
+// without a timeout, so that the default read from the environment applies.
+func envTimeoutApplies(scraper BaseScraper) bool {
+	s, ok := scraper.(interface{ hasScrapeTimeout() bool })
+	return ok && !s.hasScrapeTimeout()
+}
@@ -0,0 +1,122 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+func TestEnvDefaults(t *testing.T) {
+	t.Setenv("TEST_SCRAPE_COLLECTION_INTERVAL", "3m")
+	t.Setenv("TEST_SCRAPE_TIMEOUT", "5s")
+
+	deadlines := map[string]time.Duration{}
+	scrape := func(name string) ScrapeMetrics {
+		return func(ctx context.Context) (pdata.MetricSlice, error) {
+			d, ok := ctx.Deadline()
+			require.True(t, ok)
+			deadlines[name] = time.Until(d)
+			return singleMetric(), nil
+		}
+	}
+	cfg := &testScraperConfig{ScraperSettings: ScraperSettings{TimeoutVal: 10 * time.Second, CollectionIntervalVal: 2 * time.Minute}}
+
+	core, logs := observer.New(zap.InfoLevel)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.New(core),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("defaults", scrape("defaults"))),
+		AddMetricsScraper(NewMetricsScraper("configured", scrape("configured"), WithConfig(cfg))),
+		WithEnvDefaults("TEST_SCRAPE"),
+	)
+	require.NoError(t, err)
+
+	applied := logs.FilterMessage("Applying scraper default from environment variable").All()
+	require.Len(t, applied, 2)
+	assert.Equal(t, "TEST_SCRAPE_COLLECTION_INTERVAL", applied[0].ContextMap()["variable"])
+	assert.Equal(t, 3*time.Minute, applied[0].ContextMap()["value"])
+	assert.Equal(t, []interface{}{"defaults"}, applied[0].ContextMap()["scrapers"])
+	assert.Equal(t, "TEST_SCRAPE_TIMEOUT", applied[1].ContextMap()["variable"])
+	assert.Equal(t, []interface{}{"defaults"}, applied[1].ContextMap()["scrapers"])
+
+	effective := receiver.(ScraperInspector).EffectiveConfig()
+	require.Len(t, effective, 2)
+	assert.Equal(t, &EffectiveDuration{Value: 3 * time.Minute, Source: ConfigSourceEnv}, effective[0].CollectionInterval)
+	assert.Equal(t, &EffectiveDuration{Value: 5 * time.Second, Source: ConfigSourceEnv}, effective[0].Timeout)
+	assert.Equal(t, &EffectiveDuration{Value: 2 * time.Minute, Source: ConfigSourceScraperConfig}, effective[1].CollectionInterval)
+	assert.Equal(t, &EffectiveDuration{Value: 10 * time.Second, Source: ConfigSourceScraperConfig}, effective[1].Timeout)
+
+	// the scrapers are scraped with the timeout of their configuration, or
+	// else the default.
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	_, err = receiver.(*controller).metricsScrapers.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.True(t, deadlines["defaults"] > 4*time.Second && deadlines["defaults"] <= 5*time.Second, deadlines["defaults"])
+	assert.True(t, deadlines["configured"] > 9*time.Second && deadlines["configured"] <= 10*time.Second, deadlines["configured"])
+}
+
+func TestEnvDefaultsUnset(t *testing.T) {
+	t.Setenv("TEST_SCRAPE_TIMEOUT", "")
+
+	core, logs := observer.New(zap.InfoLevel)
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.New(core), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithEnvDefaults("TEST_SCRAPE"),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 0, logs.Len())
+
+	effective := receiver.(ScraperInspector).EffectiveConfig()
+	require.Len(t, effective, 1)
+	assert.Equal(t, &EffectiveDuration{Value: time.Minute, Source: ConfigSourceReceiver}, effective[0].CollectionInterval)
+	assert.Equal(t, &EffectiveDuration{Source: ConfigSourceReceiver}, effective[0].Timeout)
+}
+
+func TestEnvDefaultsInvalid(t *testing.T) {
+	t.Setenv("TEST_SCRAPE_COLLECTION_INTERVAL", "-1m")
+	t.Setenv("TEST_SCRAPE_TIMEOUT", "5")
+
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithEnvDefaults("TEST_SCRAPE"),
+	)
+	assert.EqualError(t, err, `receiver "receiver" has 2 configuration errors:
+  - invalid environment variable TEST_SCRAPE_COLLECTION_INTERVAL="-1m": duration must not be negative
+  - invalid environment variable TEST_SCRAPE_TIMEOUT="5": time: missing unit in duration "5"`)
+
+	_, err = NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithEnvDefaults(""),
+	)
+	assert.EqualError(t, err, "environment variable prefix must not be empty")
+}
@@ -23,6 +23,72 @@ import (
 	"go.opentelemetry.io/collector/consumer/consumererror"
 )
 
+// ErrNotStarted indicates that the receiver has not been started, or has
+// been shutdown.
+var ErrNotStarted = errors.New("receiver not started")
+
+// The errors returned when creating, starting and shutting down a receiver
+// created with NewScraperControllerReceiver, or when adding scrapers to it,
+// match one of these errors with errors.Is, while keeping their own message.
+var (
+	// ErrInvalidOption indicates that a receiver was created with an invalid
+	// option.
+	ErrInvalidOption = errors.New("invalid option")
+	// ErrInvalidCollectionInterval indicates that the collection interval of
+	// a receiver is not positive, or is outside of the allowed range.
+	ErrInvalidCollectionInterval = errors.New("invalid collection interval")
+	// ErrNoScrapers indicates that a receiver was created without scrapers.
+	ErrNoScrapers = errors.New("no scrapers")
+	// ErrDuplicateScraper indicates that a scraper has the same name as
+	// another scraper of the receiver.
+	ErrDuplicateScraper = errors.New("duplicate scraper")
+	// ErrInvalidScraper indicates that a scraper is of an unsupported type,
+	// or was created with invalid options.
+	ErrInvalidScraper = errors.New("invalid scraper")
+	// ErrInvalidState indicates that a scraper was added to a receiver that
+	// is starting, stopping or stopped.
+	ErrInvalidState = errors.New("invalid receiver state")
+	// ErrScraperInitialization indicates that a scraper failed to initialize,
+	// or was not initialized in time.
+	ErrScraperInitialization = errors.New("scraper initialization failed")
+	// ErrScraperClose indicates that a scraper failed to close, or was not
+	// closed in time.
+	ErrScraperClose = errors.New("scraper close failed")
+	// ErrConsumeQueueDrain indicates that the metrics queued with
+	// WithAsyncConsume were not passed to the next consumer before the
+	// shutdown context was done.
+	ErrConsumeQueueDrain = errors.New("consume queue not drained")
+	// ErrShutdownPhaseTimeout indicates that a phase of the shutdown exceeded
+	// its share of the shutdown context set with WithShutdownBudget.
+	ErrShutdownPhaseTimeout = errors.New("shutdown phase timed out")
+)
+
+// kindError is an error of one of the kinds of errors above, keeping the
+// message of the error.
+type kindError struct {
+	kind error
+	err  error
+}
+
+// withKind returns err as an error matching kind with errors.Is.
+func withKind(kind error, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error.
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is the kind of the error.
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
 // CombineScrapeErrors converts a list of errors into one error.
 func CombineScrapeErrors(errs []error) error {
 	partialScrapeErr := false
@@ -38,22 +104,68 @@ func CombineScrapeErrors(errs []error) error {
 		return componenterror.CombineErrors(errs)
 	}
 
-	errMsgs := make([]string, 0, len(errs))
 	failedScrapeCount := 0
 	for _, err := range errs {
 		if partialError, isPartial := err.(consumererror.PartialScrapeError); isPartial {
 			failedScrapeCount += partialError.Failed
 		}
+	}
 
-		errMsgs = append(errMsgs, err.Error())
+	return consumererror.NewPartialScrapeError(componenterror.CombineErrors(errs), failedScrapeCount)
+}
+
+// ScraperNotFoundError is returned when a scraper with the given ID has not
+// been added to a receiver.
+type ScraperNotFoundError struct {
+	ID ScraperID
+}
+
+func (e *ScraperNotFoundError) Error() string {
+	return fmt.Sprintf("scraper %q not found", e.ID.String())
+}
+
+// ValidationError is returned when a receiver is created with invalid
+// options or scrapers, and lists all the problems found, those of the
+// receiver first, followed by those of the scrapers sorted by scraper name.
+type ValidationError struct {
+	// Receiver is the name of the receiver.
+	Receiver string
+	// Errors contains the problems found.
+	Errors []error
+}
+
+// Error returns the problem found if there is only one, or else lists each
+// problem on its own line.
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
 	}
 
-	var err error
-	if len(errs) == 1 {
-		err = errs[0]
-	} else {
-		err = fmt.Errorf("[%s]", strings.Join(errMsgs, "; "))
+	var b strings.Builder
+	fmt.Fprintf(&b, "receiver %q has %d configuration errors:", e.Receiver, len(e.Errors))
+	for _, err := range e.Errors {
+		b.WriteString("\n  - ")
+		b.WriteString(err.Error())
 	}
+	return b.String()
+}
 
-	return consumererror.NewPartialScrapeError(err, failedScrapeCount)
+// Is reports whether any of the problems found matches target.
+func (e *ValidationError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the problems found that matches target.
+func (e *ValidationError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
 }
@@ -15,12 +15,22 @@
 package scraperhelper
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
 )
 
 func TestCombineScrapeErrors(t *testing.T) {
@@ -46,14 +56,14 @@ func TestCombineScrapeErrors(t *testing.T) {
 				fmt.Errorf("foo"),
 				fmt.Errorf("bar"),
 			},
-			expected: "[foo; bar]",
+			expected: "[bar; foo]",
 		},
 		{
 			errors: []error{
 				fmt.Errorf("foo"),
 				fmt.Errorf("bar"),
 				consumererror.NewPartialScrapeError(fmt.Errorf("partial"), 0)},
-			expected:                  "[foo; bar; partial]",
+			expected:                  "[bar; foo; partial]",
 			expectedPartialScrapeErr:  true,
 			expectedFailedScrapeCount: 0,
 		},
@@ -63,7 +73,7 @@ func TestCombineScrapeErrors(t *testing.T) {
 				fmt.Errorf("bar"),
 				consumererror.NewPartialScrapeError(fmt.Errorf("partial 1"), 2),
 				consumererror.NewPartialScrapeError(fmt.Errorf("partial 2"), 3)},
-			expected:                  "[foo; bar; partial 1; partial 2]",
+			expected:                  "[bar; foo; partial 1; partial 2]",
 			expectedPartialScrapeErr:  true,
 			expectedFailedScrapeCount: 5,
 		},
@@ -86,3 +96,249 @@ func TestCombineScrapeErrors(t *testing.T) {
 		}
 	}
 }
+
+func scrapeSingleMetric(context.Context) (pdata.MetricSlice, error) {
+	return singleMetric(), nil
+}
+
+func TestNewScraperControllerReceiverErrorKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		options  []ScraperControllerOption
+		expected error
+	}{
+		{
+			name:     "InvalidOption",
+			options:  []ScraperControllerOption{AddMetricsScraper(nil)},
+			expected: ErrInvalidOption,
+		},
+		{
+			name:     "InvalidControllerOption",
+			options:  []ScraperControllerOption{WithMaxConcurrentScrapes(0)},
+			expected: ErrInvalidOption,
+		},
+		{
+			name:     "EmptyResourceAttributeKey",
+			options:  []ScraperControllerOption{WithResourceAttributes(map[string]string{"": "value"})},
+			expected: ErrInvalidOption,
+		},
+		{
+			name:     "InvalidMetricNamePrefix",
+			options:  []ScraperControllerOption{WithMetricNamePrefix("1prefix")},
+			expected: ErrInvalidOption,
+		},
+		{
+			name:     "NegativeInitialDelay",
+			options:  []ScraperControllerOption{WithDefaultInitialDelay(-time.Second)},
+			expected: ErrInvalidOption,
+		},
+		{
+			name:     "SharedSchedulerWithTickerChannel",
+			options:  []ScraperControllerOption{WithSharedScheduler(), WithTickerChannel(make(chan time.Time))},
+			expected: ErrInvalidOption,
+		},
+		{
+			name:     "NonPositiveCollectionInterval",
+			interval: -time.Second,
+			expected: ErrInvalidCollectionInterval,
+		},
+		{
+			name:     "CollectionIntervalOutOfRange",
+			interval: time.Millisecond,
+			options:  []ScraperControllerOption{WithMinCollectionInterval(time.Second)},
+			expected: ErrInvalidCollectionInterval,
+		},
+		{
+			name:     "NoScrapers",
+			expected: ErrNoScrapers,
+		},
+		{
+			name: "DuplicateScraper",
+			options: []ScraperControllerOption{
+				AddMetricsScraper(NewMetricsScraper("scraper", scrapeSingleMetric)),
+				AddMetricsScraper(NewMetricsScraper("scraper", scrapeSingleMetric)),
+			},
+			expected: ErrDuplicateScraper,
+		},
+		{
+			name:     "InvalidScraper",
+			options:  []ScraperControllerOption{AddMetricsScraper(NewMetricsScraper("scraper", scrapeSingleMetric, WithInitialDelay(-time.Second)))},
+			expected: ErrInvalidScraper,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := DefaultScraperControllerSettings("receiver")
+			if test.interval != 0 {
+				cfg.CollectionInterval = test.interval
+			}
+			_, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), new(consumertest.MetricsSink), test.options...)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, test.expected), "%v is not %v", err, test.expected)
+
+			var verr *ValidationError
+			assert.True(t, errors.As(err, &verr))
+		})
+	}
+
+	t.Run("NilNextConsumer", func(t *testing.T) {
+		cfg := DefaultScraperControllerSettings("receiver")
+		_, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), nil)
+		assert.True(t, errors.Is(err, componenterror.ErrNilNextConsumer))
+	})
+}
+
+func TestStartErrorKinds(t *testing.T) {
+	errStart := errors.New("start failed")
+	failingStart := func(context.Context, component.Host) error { return errStart }
+	blockingStart := func(ctx context.Context, _ component.Host) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	tests := []struct {
+		name     string
+		options  []ScraperControllerOption
+		scraper  []ScraperOption
+		expected error
+	}{
+		{
+			name:     "StartError",
+			scraper:  []ScraperOption{WithStart(failingStart)},
+			expected: errStart,
+		},
+		{
+			name:     "StartErrorInParallel",
+			options:  []ScraperControllerOption{WithParallelInit(0)},
+			scraper:  []ScraperOption{WithStart(failingStart)},
+			expected: errStart,
+		},
+		{
+			name:     "StartTimeout",
+			scraper:  []ScraperOption{WithStart(blockingStart), WithInitTimeout(10 * time.Millisecond)},
+			expected: context.DeadlineExceeded,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := DefaultScraperControllerSettings("receiver")
+			options := append([]ScraperControllerOption{
+				AddMetricsScraper(NewMetricsScraper("scraper", scrapeSingleMetric, test.scraper...)),
+				WithTickerChannel(make(chan time.Time)),
+			}, test.options...)
+			receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), new(consumertest.MetricsSink), options...)
+			require.NoError(t, err)
+
+			err = receiver.Start(context.Background(), componenttest.NewNopHost())
+			assert.True(t, errors.Is(err, ErrScraperInitialization), "%v is not %v", err, ErrScraperInitialization)
+			assert.True(t, errors.Is(err, test.expected), "%v is not %v", err, test.expected)
+		})
+	}
+
+	t.Run("StartContextCanceledInParallel", func(t *testing.T) {
+		cfg := DefaultScraperControllerSettings("receiver")
+		receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), new(consumertest.MetricsSink),
+			AddMetricsScraper(NewMetricsScraper("scraper", scrapeSingleMetric)),
+			WithParallelInit(1),
+			WithTickerChannel(make(chan time.Time)),
+		)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = receiver.Start(ctx, componenttest.NewNopHost())
+		assert.True(t, errors.Is(err, ErrScraperInitialization), "%v is not %v", err, ErrScraperInitialization)
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+}
+
+func TestShutdownErrorKinds(t *testing.T) {
+	errShutdown := errors.New("shutdown failed")
+	blockingShutdown := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	tests := []struct {
+		name     string
+		scraper  []ScraperOption
+		expected error
+	}{
+		{
+			name:     "ShutdownError",
+			scraper:  []ScraperOption{WithShutdown(func(context.Context) error { return errShutdown })},
+			expected: errShutdown,
+		},
+		{
+			name:     "ShutdownTimeout",
+			scraper:  []ScraperOption{WithShutdown(blockingShutdown), WithCloseTimeout(10 * time.Millisecond)},
+			expected: context.DeadlineExceeded,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := DefaultScraperControllerSettings("receiver")
+			receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), new(consumertest.MetricsSink),
+				AddMetricsScraper(NewMetricsScraper("scraper1", scrapeSingleMetric)),
+				AddMetricsScraper(NewMetricsScraper("scraper2", scrapeSingleMetric, test.scraper...)),
+				WithTickerChannel(make(chan time.Time)),
+			)
+			require.NoError(t, err)
+			require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+			err = receiver.Shutdown(context.Background())
+			assert.True(t, errors.Is(err, ErrScraperClose), "%v is not %v", err, ErrScraperClose)
+			assert.True(t, errors.Is(err, test.expected), "%v is not %v", err, test.expected)
+		})
+	}
+
+	t.Run("ConsumeQueueNotDrained", func(t *testing.T) {
+		tickerCh := make(chan time.Time)
+		next := &blockingConsumer{release: make(chan struct{})}
+		cfg := DefaultScraperControllerSettings("receiver")
+		receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), next,
+			AddMetricsScraper(NewMetricsScraper("scraper", scrapeSingleMetric)),
+			WithAsyncConsume(10, 1),
+			WithTickerChannel(tickerCh),
+		)
+		require.NoError(t, err)
+		require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+		tickerCh <- time.Now()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err = receiver.Shutdown(ctx)
+		close(next.release)
+		assert.True(t, errors.Is(err, ErrConsumeQueueDrain), "%v is not %v", err, ErrConsumeQueueDrain)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+}
+
+func TestAddScraperErrorKinds(t *testing.T) {
+	cfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrapeSingleMetric)),
+		WithTickerChannel(make(chan time.Time)),
+	)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	err = receiver.(ScraperManager).AddScraper(ctx, NewMetricsScraper("scraper", scrapeSingleMetric))
+	assert.True(t, errors.Is(err, ErrDuplicateScraper), "%v is not %v", err, ErrDuplicateScraper)
+
+	err = receiver.(ScraperManager).AddScraper(ctx, NewMetricsScraper("invalid", scrapeSingleMetric, WithInitialDelay(-time.Second)))
+	assert.True(t, errors.Is(err, ErrInvalidScraper), "%v is not %v", err, ErrInvalidScraper)
+
+	require.NoError(t, receiver.Start(ctx, componenttest.NewNopHost()))
+	errStart := errors.New("start failed")
+	err = receiver.(ScraperManager).AddScraper(ctx, NewMetricsScraper("failing", scrapeSingleMetric,
+		WithStart(func(context.Context, component.Host) error { return errStart })))
+	assert.True(t, errors.Is(err, ErrScraperInitialization), "%v is not %v", err, ErrScraperInitialization)
+	assert.True(t, errors.Is(err, errStart))
+
+	require.NoError(t, receiver.Shutdown(ctx))
+	err = receiver.(ScraperManager).AddScraper(ctx, NewMetricsScraper("late", scrapeSingleMetric))
+	assert.True(t, errors.Is(err, ErrInvalidState), "%v is not %v", err, ErrInvalidState)
+}
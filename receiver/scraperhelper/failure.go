@@ -0,0 +1,193 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+)
+
+// FailureMode specifies what happens when a scraper keeps failing.
+type FailureMode string
+
+const (
+	// FailureKeepTrying keeps scraping the scraper on every tick. This is the
+	// default.
+	FailureKeepTrying FailureMode = "keep_trying"
+	// FailureBackoff skips the scrapes of the scraper for an exponentially
+	// increasing time after each consecutive failed scrape.
+	FailureBackoff FailureMode = "backoff"
+	// FailureDisableAfter stops scraping the scraper after MaxFailures
+	// consecutive failed scrapes, until the receiver is restarted.
+	FailureDisableAfter FailureMode = "disable_after"
+	// FailureFatal reports a fatal error to the host after MaxFailures
+	// consecutive failed scrapes.
+	FailureFatal FailureMode = "fatal"
+)
+
+// FailurePolicy defines what happens when a scraper keeps failing. A scrape
+// fails if it returns an error that is not a partial scrape error.
+type FailurePolicy struct {
+	// Mode is the FailureMode of the policy. Empty means FailureKeepTrying.
+	Mode FailureMode `mapstructure:"mode"`
+	// MaxFailures is the number of consecutive failed scrapes after which
+	// the FailureDisableAfter and FailureFatal modes apply.
+	MaxFailures int `mapstructure:"max_failures"`
+	// InitialBackoff is the time scrapes are skipped for after the first
+	// failed scrape in FailureBackoff mode, doubled after each consecutive
+	// failed scrape.
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	// MaxBackoff bounds the time scrapes are skipped for in FailureBackoff
+	// mode.
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+}
+
+// Validate returns an error if the policy has an unknown mode, or is missing
+// the parameters of its mode.
+func (p FailurePolicy) Validate() error {
+	switch p.Mode {
+	case "", FailureKeepTrying:
+	case FailureBackoff:
+		if p.InitialBackoff <= 0 {
+			return errors.New("initial_backoff must be positive in backoff mode")
+		}
+		if p.MaxBackoff < p.InitialBackoff {
+			return errors.New("max_backoff must not be less than initial_backoff in backoff mode")
+		}
+	case FailureDisableAfter, FailureFatal:
+		if p.MaxFailures <= 0 {
+			return fmt.Errorf("max_failures must be positive in %s mode", p.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown mode %q, must be one of %s, %s, %s or %s", p.Mode, FailureKeepTrying, FailureBackoff, FailureDisableAfter, FailureFatal)
+	}
+	return nil
+}
+
+// WithFailurePolicy sets what happens when the scraper keeps failing. This
+// overrides the policy of the scraper configuration set with WithConfig.
+func WithFailurePolicy(policy FailurePolicy) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.failurePolicy = policy
+		s.failurePolicySet = true
+	}
+}
+
+// failureTracker applies a FailurePolicy to the scrapes of a scraper.
+type failureTracker struct {
+	policy FailurePolicy
+
+	mu       sync.Mutex
+	failures int
+	// attempt is the tick of the last scrape, and next the first tick the
+	// scraper can be scraped on when backing off.
+	attempt time.Time
+	next    time.Time
+	// persistent is the error reported once the maximum number of failures
+	// is reached, until reported is set, truncated to maxErrorLength.
+	persistent     error
+	reported       bool
+	maxErrorLength int
+}
+
+func newFailureTracker(policy FailurePolicy) *failureTracker {
+	if policy.Mode == "" || policy.Mode == FailureKeepTrying {
+		return nil
+	}
+	return &failureTracker{policy: policy, maxErrorLength: defaultMaxErrorLength}
+}
+
+// setMaxErrorLength sets the maximum length of the retained error message.
+func (t *failureTracker) setMaxErrorLength(maxErrorLength int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.maxErrorLength = maxErrorLength
+}
+
+// reset forgets the failed scrapes, when the scraper is restarted.
+func (t *failureTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failures = 0
+	t.attempt, t.next = time.Time{}, time.Time{}
+	t.persistent, t.reported = nil, false
+}
+
+// allow reports whether the scraper can be scraped on the given tick, and if
+// so records the tick as the time of the scrape.
+func (t *failureTracker) allow(tick time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.policy.Mode == FailureDisableAfter && t.persistent != nil {
+		return false
+	}
+	if tick.Before(t.next) {
+		return false
+	}
+	t.attempt = tick
+	return true
+}
+
+// schedule returns the time until which the scrapes are skipped after failed
+// scrapes, and whether the scraper is disabled after them.
+func (t *failureTracker) schedule() (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.next, t.policy.Mode == FailureDisableAfter && t.persistent != nil
+}
+
+// record records the outcome of a scrape.
+func (t *failureTracker) record(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil || consumererror.IsPartialScrapeError(err) {
+		t.failures = 0
+		t.next = time.Time{}
+		return
+	}
+
+	t.failures++
+	switch t.policy.Mode {
+	case FailureBackoff:
+		backoff := t.policy.InitialBackoff
+		for i := 1; i < t.failures && backoff < t.policy.MaxBackoff; i++ {
+			backoff *= 2
+		}
+		if backoff > t.policy.MaxBackoff {
+			backoff = t.policy.MaxBackoff
+		}
+		t.next = t.attempt.Add(backoff)
+	case FailureDisableAfter, FailureFatal:
+		if t.failures >= t.policy.MaxFailures && t.persistent == nil {
+			t.persistent = truncateError(err, t.maxErrorLength)
+		}
+	}
+}
+
+// persistentFailure returns the error of the scrape that reached the maximum
+// number of consecutive failures, only once.
+func (t *failureTracker) persistentFailure() (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.persistent == nil || t.reported {
+		return 0, nil
+	}
+	t.reported = true
+	return t.failures, t.persistent
+}
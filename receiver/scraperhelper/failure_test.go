@@ -0,0 +1,227 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// scrapeFailingScraper sends the given number of ticks, one minute apart, to a
+// receiver with a scraper that always fails, and returns the ticks the scraper
+// was scraped on.
+func scrapeFailingScraper(t *testing.T, host component.Host, logger *zap.Logger, ticks int, options ...ScraperOption) []int {
+	var tick int
+	var scrapedOn []int
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		scrapedOn = append(scrapedOn, tick)
+		return pdata.NewMetricSlice(), errors.New("err1")
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		logger,
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape, options...)),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), host))
+
+	start := time.Now()
+	for tick = 1; tick <= ticks; tick++ {
+		tickerCh <- start.Add(time.Duration(tick) * time.Minute)
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) == tick }, time.Second, time.Millisecond)
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	return scrapedOn
+}
+
+func failurePolicyConfig(policy FailurePolicy) *testScraperConfig {
+	return &testScraperConfig{ScraperSettings: ScraperSettings{OnFailureVal: policy}}
+}
+
+func TestFailurePolicyKeepTrying(t *testing.T) {
+	scrapedOn := scrapeFailingScraper(t, componenttest.NewNopHost(), zap.NewNop(), 5)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, scrapedOn)
+
+	scrapedOn = scrapeFailingScraper(t, componenttest.NewNopHost(), zap.NewNop(), 5, WithConfig(failurePolicyConfig(FailurePolicy{Mode: FailureKeepTrying})))
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, scrapedOn)
+}
+
+func TestFailurePolicyBackoff(t *testing.T) {
+	cfg := failurePolicyConfig(FailurePolicy{Mode: FailureBackoff, InitialBackoff: 2 * time.Minute, MaxBackoff: 4 * time.Minute})
+	scrapedOn := scrapeFailingScraper(t, componenttest.NewNopHost(), zap.NewNop(), 12, WithConfig(cfg))
+	// backing off for 2, 4, then at most 4 minutes.
+	assert.Equal(t, []int{1, 3, 7, 11}, scrapedOn)
+}
+
+func TestFailurePolicyBackoffResetsOnSuccess(t *testing.T) {
+	failing := map[int]bool{1: true, 5: true}
+	var tick int
+	var scrapedOn []int
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		scrapedOn = append(scrapedOn, tick)
+		if failing[tick] {
+			return pdata.NewMetricSlice(), errors.New("err1")
+		}
+		return singleMetric(), nil
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	policy := FailurePolicy{Mode: FailureBackoff, InitialBackoff: 2 * time.Minute, MaxBackoff: 8 * time.Minute}
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape, WithFailurePolicy(policy))),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	start := time.Now()
+	for tick = 1; tick <= 9; tick++ {
+		tickerCh <- start.Add(time.Duration(tick) * time.Minute)
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) == tick }, time.Second, time.Millisecond)
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	// the successful scrape on tick 3 resets the backoff, so that the scraper
+	// backs off for 2 minutes again after failing on tick 5.
+	assert.Equal(t, []int{1, 3, 4, 5, 7, 8, 9}, scrapedOn)
+}
+
+func TestFailurePolicyDisableAfter(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	cfg := failurePolicyConfig(FailurePolicy{Mode: FailureDisableAfter, MaxFailures: 3})
+	scrapedOn := scrapeFailingScraper(t, componenttest.NewNopHost(), zap.New(core), 6, WithConfig(cfg))
+	assert.Equal(t, []int{1, 2, 3}, scrapedOn)
+
+	disabled := logs.FilterMessage("Scraper disabled after consecutive failed scrapes until the receiver is restarted").All()
+	require.Len(t, disabled, 1)
+	assert.Equal(t, "scraper", disabled[0].ContextMap()["scraper"])
+	assert.EqualValues(t, 3, disabled[0].ContextMap()["failures"])
+	assert.Equal(t, "err1", disabled[0].ContextMap()["error"])
+}
+
+func TestFailurePolicyFatal(t *testing.T) {
+	host := componenttest.NewErrorWaitingHost()
+	cfg := failurePolicyConfig(FailurePolicy{Mode: FailureFatal, MaxFailures: 2})
+	scrapedOn := scrapeFailingScraper(t, host, zap.NewNop(), 3, WithConfig(cfg))
+	assert.Equal(t, []int{1, 2, 3}, scrapedOn)
+
+	received, err := host.WaitForFatalError(time.Second)
+	require.True(t, received)
+	assert.EqualError(t, err, `scraper "scraper" of receiver "receiver" failed 2 consecutive scrapes: err1`)
+
+	// the fatal error is only reported once.
+	received, _ = host.WaitForFatalError(10 * time.Millisecond)
+	assert.False(t, received)
+}
+
+func TestFailurePolicyOptionTakesPrecedence(t *testing.T) {
+	cfg := failurePolicyConfig(FailurePolicy{Mode: FailureDisableAfter, MaxFailures: 1})
+	scrapedOn := scrapeFailingScraper(t, componenttest.NewNopHost(), zap.NewNop(), 3,
+		WithConfig(cfg),
+		WithFailurePolicy(FailurePolicy{Mode: FailureKeepTrying}))
+	assert.Equal(t, []int{1, 2, 3}, scrapedOn)
+}
+
+func TestFailurePolicyInvalid(t *testing.T) {
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("option", scrape, WithFailurePolicy(FailurePolicy{Mode: FailureFatal}))),
+		AddMetricsScraper(NewMetricsScraper("config", scrape, WithConfig(failurePolicyConfig(FailurePolicy{Mode: "retry"})))),
+	)
+	assert.EqualError(t, err, `receiver "receiver" has 2 configuration errors:
+  - invalid scraper "config": invalid on_failure: unknown mode "retry", must be one of keep_trying, backoff, disable_after or fatal
+  - invalid scraper "option": invalid failure policy: max_failures must be positive in fatal mode`)
+}
+
+func TestFailurePolicyDisableAfterReplacedScraper(t *testing.T) {
+	var mu sync.Mutex
+	var scrapedOn []string
+	create := func(name string, cfg ScraperConfig) (BaseScraper, error) {
+		path := cfg.(*testScraperConfig).Path
+		scrape := func(context.Context) (pdata.MetricSlice, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			scrapedOn = append(scrapedOn, path)
+			return pdata.NewMetricSlice(), errors.New("err1")
+		}
+		return NewMetricsScraper(name, scrape, WithConfig(cfg)), nil
+	}
+	config := func(path string) *testScraperConfig {
+		cfg := failurePolicyConfig(FailurePolicy{Mode: FailureDisableAfter, MaxFailures: 1})
+		cfg.Path = path
+		return cfg
+	}
+	scraper, err := create("scraper", config("/old"))
+	require.NoError(t, err)
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(scraper.(MetricsScraper)),
+		WithScraperFactory(create),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	start := time.Now()
+	tick := func(i int) {
+		tickerCh <- start.Add(time.Duration(i) * time.Minute)
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) == i }, time.Second, time.Millisecond)
+	}
+	tick(1)
+	tick(2)
+	assert.Equal(t, []ScheduledScrape{{ID: ScraperID{Receiver: "receiver", Scraper: "scraper"}, Reason: SchedulePaused}}, receiver.(ScraperInspector).NextScrapes())
+
+	// the scraper replacing the disabled one is scraped again, until its own
+	// failure policy disables it.
+	require.NoError(t, receiver.(ScraperManager).UpdateConfig(context.Background(), map[string]ScraperConfig{"scraper": config("/new")}))
+	tick(3)
+	tick(4)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/old", "/new"}, scrapedOn)
+}
@@ -0,0 +1,168 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// GateStage is the stage of a feature gate, which determines whether the
+// gate is enabled by default.
+type GateStage int
+
+const (
+	// GateAlpha gates are disabled by default.
+	GateAlpha GateStage = iota
+	// GateBeta gates are enabled by default, and can still be disabled.
+	GateBeta
+	// GateStable gates are always enabled, and are removed in their removal
+	// version.
+	GateStable
+)
+
+// String returns the name of the GateStage.
+func (s GateStage) String() string {
+	switch s {
+	case GateAlpha:
+		return "Alpha"
+	case GateBeta:
+		return "Beta"
+	case GateStable:
+		return "Stable"
+	}
+	return fmt.Sprintf("GateStage(%d)", int(s))
+}
+
+// Gate is a feature gate, guarding a behavior of the receivers created by
+// this package that changes what every receiver reports, so that the
+// behavior can be shipped disabled, enabled per deployment with
+// SetGateEnabled, and later become the default.
+type Gate struct {
+	// ID identifies the gate.
+	ID string
+	// Description describes the behavior guarded by the gate.
+	Description string
+	// Stage is the stage of the gate.
+	Stage GateStage
+	// RemovalVersion is the version in which a stable gate is removed.
+	RemovalVersion string
+}
+
+// The IDs of the gates registered by this package.
+const (
+	// ScrapeOnStartGate makes the receivers scrape once as soon as they are
+	// started, rather than on the first tick.
+	ScrapeOnStartGate = "scraperhelper.scrapeOnStart"
+	// SkipEmptyPayloadsGate makes the receivers skip the scrapes without
+	// metrics, rather than passing empty metrics to the next consumer.
+	SkipEmptyPayloadsGate = "scraperhelper.skipEmptyPayloads"
+)
+
+var gates = newGateRegistry()
+
+func init() {
+	for _, gate := range []Gate{
+		{
+			ID:          ScrapeOnStartGate,
+			Description: "Scrape once as soon as the receiver is started, rather than on the first tick.",
+			Stage:       GateAlpha,
+		},
+		{
+			ID:          SkipEmptyPayloadsGate,
+			Description: "Do not pass the scrapes without metrics to the next consumer.",
+			Stage:       GateAlpha,
+		},
+	} {
+		if err := gates.register(gate); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterGate registers a feature gate, whose ID must be unique. Gates are
+// meant to be registered once, when the package guarding a behavior with
+// them is initialized.
+func RegisterGate(gate Gate) error {
+	return gates.register(gate)
+}
+
+// IsGateEnabled reports whether the gate with the given ID is enabled. The
+// gates that are not registered are disabled. It is safe to call
+// concurrently, including with SetGateEnabled, and the receivers check their
+// gates when they are created.
+func IsGateEnabled(id string) bool {
+	return gates.isEnabled(id)
+}
+
+// SetGateEnabled enables or disables the gate with the given ID, overriding
+// the default of its stage. Stable gates can not be disabled.
+func SetGateEnabled(id string, enabled bool) error {
+	return gates.setEnabled(id, enabled)
+}
+
+// gateRegistry holds the registered feature gates.
+type gateRegistry struct {
+	mu    sync.RWMutex
+	gates map[string]*registeredGate
+}
+
+type registeredGate struct {
+	gate    Gate
+	enabled bool
+}
+
+func newGateRegistry() *gateRegistry {
+	return &gateRegistry{gates: map[string]*registeredGate{}}
+}
+
+func (r *gateRegistry) register(gate Gate) error {
+	if gate.ID == "" {
+		return errors.New("gate ID must not be empty")
+	}
+	if gate.Stage == GateStable && gate.RemovalVersion == "" {
+		return fmt.Errorf("stable gate %q must have a removal version", gate.ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.gates[gate.ID]; ok {
+		return fmt.Errorf("gate %q is already registered", gate.ID)
+	}
+	r.gates[gate.ID] = &registeredGate{gate: gate, enabled: gate.Stage != GateAlpha}
+	return nil
+}
+
+func (r *gateRegistry) isEnabled(id string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	g, ok := r.gates[id]
+	return ok && g.enabled
+}
+
+func (r *gateRegistry) setEnabled(id string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.gates[id]
+	if !ok {
+		return fmt.Errorf("gate %q is not registered", id)
+	}
+	if g.gate.Stage == GateStable && !enabled {
+		return fmt.Errorf("gate %q is stable and can not be disabled, it is removed in version %s", id, g.gate.RemovalVersion)
+	}
+	g.enabled = enabled
+	return nil
+}
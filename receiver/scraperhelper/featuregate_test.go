@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateRegistry(t *testing.T) {
+	r := newGateRegistry()
+	require.NoError(t, r.register(Gate{ID: "alpha", Stage: GateAlpha}))
+	require.NoError(t, r.register(Gate{ID: "beta", Stage: GateBeta}))
+	require.NoError(t, r.register(Gate{ID: "stable", Stage: GateStable, RemovalVersion: "v0.30.0"}))
+
+	assert.False(t, r.isEnabled("alpha"))
+	assert.True(t, r.isEnabled("beta"))
+	assert.True(t, r.isEnabled("stable"))
+	assert.False(t, r.isEnabled("unknown"))
+
+	require.NoError(t, r.setEnabled("alpha", true))
+	assert.True(t, r.isEnabled("alpha"))
+	require.NoError(t, r.setEnabled("beta", false))
+	assert.False(t, r.isEnabled("beta"))
+	require.NoError(t, r.setEnabled("stable", true))
+	assert.True(t, r.isEnabled("stable"))
+
+	assert.EqualError(t, r.setEnabled("stable", false), `gate "stable" is stable and can not be disabled, it is removed in version v0.30.0`)
+	assert.True(t, r.isEnabled("stable"))
+	assert.EqualError(t, r.setEnabled("unknown", true), `gate "unknown" is not registered`)
+	assert.False(t, r.isEnabled("unknown"))
+}
+
+func TestGateRegistryInvalidGates(t *testing.T) {
+	r := newGateRegistry()
+	require.NoError(t, r.register(Gate{ID: "gate"}))
+
+	assert.EqualError(t, r.register(Gate{ID: "gate", Stage: GateBeta}), `gate "gate" is already registered`)
+	assert.False(t, r.isEnabled("gate"))
+	assert.EqualError(t, r.register(Gate{}), "gate ID must not be empty")
+	assert.EqualError(t, r.register(Gate{ID: "stable", Stage: GateStable}), `stable gate "stable" must have a removal version`)
+}
+
+func TestGateRegistryConcurrentAccess(t *testing.T) {
+	r := newGateRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		id := fmt.Sprint("gate", i)
+		require.NoError(t, r.register(Gate{ID: id}))
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.NoError(t, r.setEnabled(id, j%2 == 0))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.isEnabled(id)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPackageGates(t *testing.T) {
+	for _, id := range []string{ScrapeOnStartGate, SkipEmptyPayloadsGate} {
+		assert.False(t, IsGateEnabled(id), id)
+	}
+	assert.Error(t, RegisterGate(Gate{ID: ScrapeOnStartGate}))
+}
+
+func TestGateStageString(t *testing.T) {
+	assert.Equal(t, "Alpha", GateAlpha.String())
+	assert.Equal(t, "Beta", GateBeta.String())
+	assert.Equal(t, "Stable", GateStable.String())
+	assert.Equal(t, "GateStage(3)", GateStage(3).String())
+}
@@ -0,0 +1,86 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/internal/processor/filterset"
+)
+
+// metricNameFilter drops the metrics whose name does not match any of the
+// include patterns, or matches any of the exclude patterns.
+type metricNameFilter struct {
+	include filterset.FilterSet
+	exclude filterset.FilterSet
+}
+
+func newMetricNameFilter(include, exclude []string) (*metricNameFilter, error) {
+	cfg := &filterset.Config{MatchType: filterset.Regexp}
+	f := &metricNameFilter{}
+	var err error
+	if len(include) > 0 {
+		if f.include, err = filterset.CreateFilterSet(include, cfg); err != nil {
+			return nil, fmt.Errorf("invalid metric name include pattern: %w", err)
+		}
+	}
+	if len(exclude) > 0 {
+		if f.exclude, err = filterset.CreateFilterSet(exclude, cfg); err != nil {
+			return nil, fmt.Errorf("invalid metric name exclude pattern: %w", err)
+		}
+	}
+	return f, nil
+}
+
+func (f *metricNameFilter) matches(name string) bool {
+	if f.exclude != nil && f.exclude.Matches(name) {
+		return false
+	}
+	return f.include == nil || f.include.Matches(name)
+}
+
+// filterMetrics removes the metrics that do not match the filter, and
+// returns the number of data points removed.
+func (f *metricNameFilter) filterMetrics(metrics pdata.MetricSlice) int {
+	removed := 0
+	kept := 0
+	for i := 0; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		if !f.matches(metric.Name()) {
+			removed += dataPointCount(metric)
+			continue
+		}
+		if kept != i {
+			metric.CopyTo(metrics.At(kept))
+		}
+		kept++
+	}
+	metrics.Resize(kept)
+	return removed
+}
+
+// filterResourceMetrics removes the metrics that do not match the filter from
+// the resource metrics, and returns the number of data points removed.
+func (f *metricNameFilter) filterResourceMetrics(rms pdata.ResourceMetricsSlice) int {
+	removed := 0
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			removed += f.filterMetrics(ilms.At(j).Metrics())
+		}
+	}
+	return removed
+}
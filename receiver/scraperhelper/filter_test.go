@@ -0,0 +1,134 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport/obsreporttest"
+)
+
+// namedGauges returns a gauge with two data points for each of the names.
+func namedGauges(names ...string) pdata.MetricSlice {
+	counts := make([]int, len(names))
+	for i := range counts {
+		counts[i] = 2
+	}
+	metrics := gaugesWithDataPoints(counts...)
+	for i, name := range names {
+		metrics.At(i).SetName(name)
+	}
+	return metrics
+}
+
+func metricNames(metrics pdata.MetricSlice) []string {
+	var names []string
+	for i := 0; i < metrics.Len(); i++ {
+		names = append(names, metrics.At(i).Name())
+	}
+	return names
+}
+
+func TestMetricNameFilter(t *testing.T) {
+	names := []string{"system.cpu.time", "system.cpu.load_average.1m", "system.memory.usage", "system.disk.io"}
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{
+			name:     "include",
+			include:  []string{`system\.cpu\..*`, `system\.disk\.io`},
+			expected: []string{"system.cpu.time", "system.cpu.load_average.1m", "system.disk.io"},
+		},
+		{
+			name:     "exclude",
+			exclude:  []string{`.*\.load_average\..*`, `system\.memory\..*`},
+			expected: []string{"system.cpu.time", "system.disk.io"},
+		},
+		{
+			name:     "overlapping",
+			include:  []string{`system\.cpu\..*`, `system\.memory\.usage`},
+			exclude:  []string{`system\.cpu\.load_average\..*`, `system\.memory\..*`},
+			expected: []string{"system.cpu.time"},
+		},
+		{
+			// patterns can match any part of the name.
+			name:     "partial match",
+			include:  []string{`cpu`},
+			expected: []string{"system.cpu.time", "system.cpu.load_average.1m"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obsreporttest.SetupRecordedMetrics(t)
+
+			scrape := func(context.Context) (pdata.MetricSlice, error) {
+				return namedGauges(names...), nil
+			}
+			scraper := NewMetricsScraper("scraper", scrape, WithMetricNameFilter(test.include, test.exclude))
+			require.NoError(t, scraper.Start(context.Background(), componenttest.NewNopHost()))
+
+			metrics, err := scraper.Scrape(context.Background(), "receiver")
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, metricNames(metrics))
+			obsreporttest.CheckScraperFilteredMetricPointsView(t, "receiver", "scraper", int64(2*(len(names)-len(test.expected))))
+		})
+	}
+}
+
+func TestMetricNameFilterResourceMetrics(t *testing.T) {
+	scrape := func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		rms := pdata.NewResourceMetricsSlice()
+		rms.Resize(2)
+		for i := 0; i < rms.Len(); i++ {
+			rms.At(i).InstrumentationLibraryMetrics().Resize(1)
+			namedGauges("process.cpu.time", "process.memory.usage").MoveAndAppendTo(rms.At(i).InstrumentationLibraryMetrics().At(0).Metrics())
+		}
+		return rms, nil
+	}
+	scraper := NewResourceMetricsScraper("scraper", scrape, WithMetricNameFilter(nil, []string{`process\.memory\..*`}))
+	require.NoError(t, scraper.Start(context.Background(), componenttest.NewNopHost()))
+
+	rms, err := scraper.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	require.Equal(t, 2, rms.Len())
+	for i := 0; i < rms.Len(); i++ {
+		assert.Equal(t, []string{"process.cpu.time"}, metricNames(rms.At(i).InstrumentationLibraryMetrics().At(0).Metrics()))
+	}
+}
+
+func TestInvalidMetricNameFilter(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape, WithMetricNameFilter(nil, []string{`system\.(`}))),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid scraper "scraper": invalid metric name exclude pattern: `)
+}
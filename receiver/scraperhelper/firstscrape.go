@@ -0,0 +1,192 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// WithWaitForAnyScraper makes WaitForFirstScrape return once any of the
+// scrapers of the receiver completed a successful scrape whose metrics were
+// consumed, instead of all of them.
+func WithWaitForAnyScraper() ScraperControllerOption {
+	return func(o *controller) {
+		o.waitForAnyScraper = true
+	}
+}
+
+// WaitForFirstScrape blocks until each of the scrapers of the receiver, or
+// any of them with WithWaitForAnyScraper, completed a successful scrape whose
+// metrics were consumed by the next consumer since the receiver was started,
+// or until the context expires, in which case the error of the context is
+// returned. It returns immediately if this already happened.
+//
+// The scrapers disabled after failed scrapes by their FailureDisableAfter
+// failure policy are no longer waited for, and an error is returned if all
+// the scrapers that did not complete a successful scrape are disabled, as
+// the wait would never end. A scrape is successful if it did not fail, or
+// failed with a partial scrape error, as reported by ScraperStats, the
+// scrapers not created by this package counting as successful whenever their
+// metrics were consumed, and streaming scrapers as soon as one of their
+// chunks was consumed.
+func (sc *controller) WaitForFirstScrape(ctx context.Context) error {
+	for {
+		changed := sc.firstScrapes.changes()
+		ready, err := sc.firstScrapeReady()
+		if ready || err != nil {
+			return err
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// firstScrapeReady reports whether the scrapers WaitForFirstScrape waits for
+// completed their first successful scrape, or returns an error if they never
+// will.
+func (sc *controller) firstScrapeReady() (bool, error) {
+	scrapers := sc.registeredScrapers()
+	succeeded, waiting := 0, 0
+	for _, scraper := range scrapers {
+		switch {
+		case sc.firstScrapes.done(scraper.Name()):
+			succeeded++
+		case !disabledByFailures(scraper):
+			waiting++
+		}
+	}
+	if len(scrapers) == 0 || (succeeded > 0 && (sc.waitForAnyScraper || waiting == 0)) {
+		return true, nil
+	}
+	if waiting == 0 {
+		return false, fmt.Errorf("the scrapers of receiver %q that did not complete a successful scrape were disabled after failed scrapes", sc.name)
+	}
+	return false, nil
+}
+
+// appendFirstScrape appends the name of the scraper to names if it was just
+// successfully scraped for the first time since the receiver was started,
+// and its metrics were not withheld, so that the scraper is recorded as such
+// once its metrics are consumed.
+func (sc *controller) appendFirstScrape(names []string, scraper BaseScraper) []string {
+	if sc.firstScrapes.done(scraper.Name()) || withheld(scraper) || !lastScrapeSucceeded(scraper) {
+		return names
+	}
+	return append(names, scraper.Name())
+}
+
+// lastScrapeSucceeded reports whether the last scrape of the scraper
+// succeeded, which can only be told for the scrapers created by this
+// package.
+func lastScrapeSucceeded(scraper BaseScraper) bool {
+	s, ok := scraper.(interface{ lastScrapeSucceeded() bool })
+	return !ok || s.lastScrapeSucceeded()
+}
+
+// disabledByFailures reports whether the scraper is no longer scraped because
+// of its FailureDisableAfter failure policy.
+func disabledByFailures(scraper BaseScraper) bool {
+	s, ok := scraper.(interface{ disabledByFailures() bool })
+	return ok && s.disabledByFailures()
+}
+
+type firstScrapesContextKey struct{}
+
+// contextWithFirstScrapes returns a context holding the names of the
+// scrapers whose first successful scrape is in the metrics consumed with it,
+// which are recorded once the metrics were consumed.
+func contextWithFirstScrapes(ctx context.Context, names []string) context.Context {
+	if len(names) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, firstScrapesContextKey{}, names)
+}
+
+// recordFirstScrapes records the first successful scrapes of the scrapers
+// held by the context, once their metrics were consumed.
+func (sc *controller) recordFirstScrapes(ctx context.Context) {
+	if names, ok := ctx.Value(firstScrapesContextKey{}).([]string); ok {
+		sc.firstScrapes.record(names)
+	}
+}
+
+// firstScrapeTracker tracks the scrapers that completed a successful scrape
+// whose metrics were consumed since the receiver was started.
+type firstScrapeTracker struct {
+	mu        sync.Mutex
+	succeeded map[string]bool
+	// changed is closed, and replaced, whenever the scrapers that succeeded,
+	// or the scrapers of the receiver, change.
+	changed chan struct{}
+}
+
+func newFirstScrapeTracker() *firstScrapeTracker {
+	return &firstScrapeTracker{succeeded: map[string]bool{}, changed: make(chan struct{})}
+}
+
+// changes returns a channel closed on the next change.
+func (t *firstScrapeTracker) changes() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.changed
+}
+
+func (t *firstScrapeTracker) done(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.succeeded[name]
+}
+
+func (t *firstScrapeTracker) record(names []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, name := range names {
+		t.succeeded[name] = true
+	}
+	t.notifyLocked()
+}
+
+// notify signals a change of the scrapers of the receiver.
+func (t *firstScrapeTracker) notify() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.notifyLocked()
+}
+
+// forget forgets the scraper, when it is removed.
+func (t *firstScrapeTracker) forget(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.succeeded, name)
+	t.notifyLocked()
+}
+
+// reset forgets the scrapers that succeeded, when the receiver is started.
+func (t *firstScrapeTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.succeeded = map[string]bool{}
+	t.notifyLocked()
+}
+
+func (t *firstScrapeTracker) notifyLocked() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
@@ -0,0 +1,206 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+// failingScrape returns a scrape function failing the given number of times
+// before succeeding.
+func failingScrape(failures int64) scraperhelper.ScrapeMetrics {
+	var scrapes atomic.Int64
+	return func(context.Context) (pdata.MetricSlice, error) {
+		if scrapes.Inc() <= failures {
+			return pdata.NewMetricSlice(), errors.New("unavailable")
+		}
+		return scrapertest.GenerateMetrics(1, 1), nil
+	}
+}
+
+// waitForFirstScrape calls WaitForFirstScrape in the background, returning
+// the channel its error is sent to.
+func waitForFirstScrape(receiver component.MetricsReceiver) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- receiver.(scraperhelper.FirstScrapeWaiter).WaitForFirstScrape(context.Background())
+	}()
+	return errCh
+}
+
+func assertWaiting(t *testing.T, errCh <-chan error) {
+	t.Helper()
+	select {
+	case err := <-errCh:
+		t.Fatalf("WaitForFirstScrape returned early: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func assertReturned(t *testing.T, errCh <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("WaitForFirstScrape did not return")
+		return nil
+	}
+}
+
+func TestWaitForFirstScrape(t *testing.T) {
+	tests := []struct {
+		name string
+		any  bool
+		// readyAfter is the tick after which WaitForFirstScrape returns.
+		readyAfter int
+	}{
+		{name: "all scrapers", readyAfter: 2},
+		{name: "any scraper", any: true, readyAfter: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := scrapertest.NewFakeClock(time.Now())
+			sink := new(scrapertest.SinkConsumer)
+			options := []scraperhelper.ScraperControllerOption{
+				scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("ok", failingScrape(0))),
+				scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("flaky", failingScrape(1))),
+				scraperhelper.WithClock(clock),
+			}
+			if tt.any {
+				options = append(options, scraperhelper.WithWaitForAnyScraper())
+			}
+			cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+			cfg.CollectionInterval = 10 * time.Second
+			receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), sink, options...)
+			require.NoError(t, err)
+
+			// the first scrapes are waited for again after a restart, when the
+			// flaky scraper no longer fails.
+			for _, readyAfter := range []int{tt.readyAfter, 1} {
+				require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+				errCh := waitForFirstScrape(receiver)
+				scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+				assertWaiting(t, errCh)
+
+				sink.Reset()
+				for tick := 1; tick < readyAfter; tick++ {
+					clock.Advance(10 * time.Second)
+					scrapertest.WaitForBatches(t, sink, tick, time.Second)
+					assertWaiting(t, errCh)
+				}
+				clock.Advance(10 * time.Second)
+				require.NoError(t, assertReturned(t, errCh))
+
+				// the condition stays met while the receiver is running.
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				assert.NoError(t, receiver.(scraperhelper.FirstScrapeWaiter).WaitForFirstScrape(ctx))
+				require.NoError(t, receiver.Shutdown(context.Background()))
+			}
+		})
+	}
+}
+
+func TestWaitForFirstScrapeConsumeError(t *testing.T) {
+	clock := scrapertest.NewFakeClock(time.Now())
+	sink := new(scrapertest.SinkConsumer)
+	sink.SetConsumeError(errors.New("refused"))
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = 10 * time.Second
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), sink,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", failingScrape(0))),
+		scraperhelper.WithClock(clock),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, receiver.Shutdown(context.Background())) }()
+
+	// the scrape succeeded, but its metrics were not consumed.
+	errCh := waitForFirstScrape(receiver)
+	scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+	clock.Advance(10 * time.Second)
+	require.Eventually(t, func() bool { return sink.Calls() == 1 }, time.Second, time.Millisecond)
+	assertWaiting(t, errCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, receiver.(scraperhelper.FirstScrapeWaiter).WaitForFirstScrape(ctx))
+
+	sink.SetConsumeError(nil)
+	clock.Advance(10 * time.Second)
+	assert.NoError(t, assertReturned(t, errCh))
+}
+
+func TestWaitForFirstScrapeDisabledAfterFailures(t *testing.T) {
+	disableAfter := scraperhelper.WithFailurePolicy(scraperhelper.FailurePolicy{Mode: scraperhelper.FailureDisableAfter, MaxFailures: 1})
+	tests := []struct {
+		name     string
+		scrapers []scraperhelper.ScraperControllerOption
+		wantErr  string
+	}{
+		{
+			name: "others succeeded",
+			scrapers: []scraperhelper.ScraperControllerOption{
+				scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("ok", failingScrape(0))),
+				scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("disabled", failingScrape(1), disableAfter)),
+			},
+		},
+		{
+			name: "all disabled",
+			scrapers: []scraperhelper.ScraperControllerOption{
+				scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("disabled", failingScrape(1), disableAfter)),
+			},
+			wantErr: `the scrapers of receiver "receiver" that did not complete a successful scrape were disabled after failed scrapes`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := scrapertest.NewFakeClock(time.Now())
+			cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+			cfg.CollectionInterval = 10 * time.Second
+			receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+				append(tt.scrapers, scraperhelper.WithClock(clock))...)
+			require.NoError(t, err)
+			require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, receiver.Shutdown(context.Background())) }()
+
+			errCh := waitForFirstScrape(receiver)
+			scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+			assertWaiting(t, errCh)
+			clock.Advance(10 * time.Second)
+			err = assertReturned(t, errCh)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
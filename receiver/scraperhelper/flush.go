@@ -0,0 +1,144 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// WithBatchFlushThreshold passes the metrics scraped on each tick to the next
+// consumer in several payloads, instead of a single one: the scrapers are
+// merged in order, resource metrics scrapers first, and a payload is flushed
+// as soon as the scrapers merged since the previous flush returned at least
+// the given number of data points. The remaining scrapers are flushed once
+// all the scrapers have been scraped.
+//
+// The metrics of a scraper are never split across payloads, so that a
+// payload may hold more data points than the threshold, and
+// WithMaxBatchSize still applies to each payload. The scrapers of a payload
+// that the next consumer fails to consume are logged with the error. The
+// threshold has no effect with WithAsyncConsume, which already passes the
+// metrics of each scraper separately.
+func WithBatchFlushThreshold(points int) ScraperControllerOption {
+	return func(o *controller) {
+		if points < 1 {
+			o.optionErrs = append(o.optionErrs, errors.New("batch flush threshold must be positive"))
+			return
+		}
+		o.batchFlushThreshold = points
+	}
+}
+
+// scrapeFlushes scrapes the due scrapers, unless the dispatcher already did,
+// and merges their metrics in payloads flushed whenever they reach the batch
+// flush threshold. It must be called with scrapersMu held.
+func (sc *controller) scrapeFlushes(ctx context.Context, resourceScrapers []ResourceMetricsScraper, metricsScrapers []MetricsScraper, results []scrapeResult) []scrapedPayload {
+	if results == nil {
+		results = sc.scrapeSequentially(ctx, resourceScrapers, metricsScrapers)
+	}
+
+	payloads := sc.payloads[:0]
+	payload := scrapedPayload{metrics: sc.newMetrics()}
+	points := 0
+	flush := func() {
+		payloads = append(payloads, payload)
+		payload = scrapedPayload{metrics: sc.newMetrics()}
+		points = 0
+	}
+
+	// the data points are counted before the scraped metrics are moved to
+	// the payload.
+	for i, rms := range resourceScrapers {
+		points += results[i].dataPoints()
+		sc.appendResourceMetrics(rms, results[i].resourceMetrics, results[i].err, payload.metrics)
+		payload.withheld = payload.withheld || withheld(rms)
+		payload.scrapers = append(payload.scrapers, rms.Name())
+		payload.firstScrapes = sc.appendFirstScrape(payload.firstScrapes, rms)
+		if points >= sc.batchFlushThreshold {
+			flush()
+		}
+	}
+
+	// the metrics scrapers merged in the same payload share a resource, like
+	// when the metrics are not flushed.
+	metricsResults := results[len(resourceScrapers):]
+	from := 0
+	merge := func(to int) {
+		if from == to {
+			return
+		}
+		sc.multiScraper.scrapers = metricsScrapers[from:to]
+		sc.multiScraper.results = metricsResults[from:to]
+		sc.scrapeResourceMetrics(ctx, &sc.multiScraper, payload.metrics)
+		for _, ms := range metricsScrapers[from:to] {
+			payload.withheld = payload.withheld || withheld(ms)
+			payload.scrapers = append(payload.scrapers, ms.Name())
+			payload.firstScrapes = sc.appendFirstScrape(payload.firstScrapes, ms)
+		}
+		from = to
+	}
+	for i := range metricsScrapers {
+		if points += metricsResults[i].dataPoints(); points >= sc.batchFlushThreshold {
+			merge(i + 1)
+			flush()
+		}
+	}
+	merge(len(metricsScrapers))
+
+	// the last payload is only flushed empty if there is no other payload,
+	// like when the metrics are not flushed.
+	if len(payload.scrapers) > 0 || len(payloads) == 0 {
+		payloads = append(payloads, payload)
+	} else {
+		sc.releaseMetrics(payload.metrics)
+	}
+	sc.payloads = payloads
+	return payloads
+}
+
+// scrapeSequentially scrapes the scrapers one after the other, and returns
+// their results like scrapeDispatcher.scrape.
+func (sc *controller) scrapeSequentially(ctx context.Context, resourceScrapers []ResourceMetricsScraper, metricsScrapers []MetricsScraper) []scrapeResult {
+	results := sc.results[:0]
+	for _, rms := range resourceScrapers {
+		resourceMetrics, err := rms.Scrape(ctx, sc.name)
+		results = append(results, scrapeResult{resourceMetrics: resourceMetrics, err: err})
+	}
+	for _, ms := range metricsScrapers {
+		metrics, err := ms.Scrape(ctx, sc.name)
+		results = append(results, scrapeResult{metrics: metrics, err: err})
+	}
+	sc.results = results
+	return results
+}
+
+// dataPoints returns the number of data points of the scraped metrics that
+// are passed on, which is none if the scrape failed without a partial result.
+func (r scrapeResult) dataPoints() int {
+	if r.err != nil && !consumererror.IsPartialScrapeError(r.err) {
+		return 0
+	}
+	if r.resourceMetrics != (pdata.ResourceMetricsSlice{}) {
+		return ResourceMetricsDataPointCount(r.resourceMetrics)
+	}
+	if r.metrics != (pdata.MetricSlice{}) {
+		return DataPointCount(r.metrics)
+	}
+	return 0
+}
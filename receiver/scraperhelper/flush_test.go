@@ -0,0 +1,169 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// sizedGauge returns a gauge named after the scraper, with the given number
+// of data points.
+func sizedGauge(name string, points int) pdata.MetricSlice {
+	metrics := gaugesWithDataPoints(points)
+	metrics.At(0).SetName(name)
+	return metrics
+}
+
+// sizedScraperOptions adds a resource metrics scraper with 3 data points,
+// and metrics scrapers with 4, 2, 5 and 1 data points.
+func sizedScraperOptions() []ScraperControllerOption {
+	options := []ScraperControllerOption{
+		AddResourceMetricsScraper(NewResourceMetricsScraper("resource", func(context.Context) (pdata.ResourceMetricsSlice, error) {
+			rms := pdata.NewResourceMetricsSlice()
+			rms.Resize(1)
+			rms.At(0).InstrumentationLibraryMetrics().Resize(1)
+			sizedGauge("resource", 3).MoveAndAppendTo(rms.At(0).InstrumentationLibraryMetrics().At(0).Metrics())
+			return rms, nil
+		})),
+	}
+	for i, points := range []int{4, 2, 5, 1} {
+		name, points := fmt.Sprintf("scraper%d", i+1), points
+		options = append(options, AddMetricsScraper(NewMetricsScraper(name, func(context.Context) (pdata.MetricSlice, error) {
+			return sizedGauge(name, points), nil
+		})))
+	}
+	return options
+}
+
+// flushedNames returns the names of the flushed metrics.
+func flushedNames(md pdata.Metrics) []string {
+	var names []string
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			for k := 0; k < ilms.At(j).Metrics().Len(); k++ {
+				names = append(names, ilms.At(j).Metrics().At(k).Name())
+			}
+		}
+	}
+	return names
+}
+
+func TestBatchFlushThreshold(t *testing.T) {
+	tests := []struct {
+		threshold int
+		flushes   [][]string
+		points    []int
+	}{
+		{
+			threshold: 5,
+			flushes:   [][]string{{"resource", "scraper1"}, {"scraper2", "scraper3"}, {"scraper4"}},
+			points:    []int{7, 7, 1},
+		},
+		{
+			threshold: 3,
+			flushes:   [][]string{{"resource"}, {"scraper1"}, {"scraper2", "scraper3"}, {"scraper4"}},
+			points:    []int{3, 4, 7, 1},
+		},
+		{
+			// the last scraper reaches the threshold, so that there is
+			// nothing left to flush once all the scrapers are scraped.
+			threshold: 1,
+			flushes:   [][]string{{"resource"}, {"scraper1"}, {"scraper2"}, {"scraper3"}, {"scraper4"}},
+			points:    []int{3, 4, 2, 5, 1},
+		},
+		{
+			threshold: 100,
+			flushes:   [][]string{{"resource", "scraper1", "scraper2", "scraper3", "scraper4"}},
+			points:    []int{15},
+		},
+	}
+	for _, tt := range tests {
+		for _, concurrent := range []bool{false, true} {
+			t.Run(fmt.Sprintf("threshold=%d/concurrent=%v", tt.threshold, concurrent), func(t *testing.T) {
+				tickerCh := make(chan time.Time)
+				sink := new(consumertest.MetricsSink)
+				options := append(sizedScraperOptions(), WithBatchFlushThreshold(tt.threshold), WithTickerChannel(tickerCh))
+				if concurrent {
+					options = append(options, WithMaxConcurrentScrapes(4))
+				}
+				defaultCfg := DefaultScraperControllerSettings("receiver")
+				receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), sink, options...)
+				require.NoError(t, err)
+
+				require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+				tickerCh <- time.Now()
+				require.Eventually(t, func() bool { return len(sink.AllMetrics()) == len(tt.flushes) }, time.Second, time.Millisecond)
+				require.NoError(t, receiver.Shutdown(context.Background()))
+
+				var flushes [][]string
+				var points []int
+				for _, md := range sink.AllMetrics() {
+					flushes = append(flushes, flushedNames(md))
+					_, dataPoints := md.MetricAndDataPointCount()
+					points = append(points, dataPoints)
+				}
+				assert.Equal(t, tt.flushes, flushes)
+				assert.Equal(t, tt.points, points)
+			})
+		}
+	}
+}
+
+func TestBatchFlushThresholdConsumeFailure(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	tickerCh := make(chan time.Time)
+	next := &failingBatchConsumer{fail: map[int]bool{2: true}}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.New(core), next,
+		append(sizedScraperOptions(), WithBatchFlushThreshold(5), WithTickerChannel(tickerCh))...)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(next.AllMetrics()) == 2 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	// the flushes following the failed one are still consumed.
+	assert.Equal(t, []string{"resource", "scraper1"}, flushedNames(next.AllMetrics()[0]))
+	assert.Equal(t, []string{"scraper4"}, flushedNames(next.AllMetrics()[1]))
+
+	failed := logs.FilterMessage("Error consuming flushed metrics").All()
+	require.Len(t, failed, 1)
+	assert.EqualValues(t, 2, failed[0].ContextMap()["flush"])
+	assert.EqualValues(t, 3, failed[0].ContextMap()["flushes"])
+	assert.Equal(t, []interface{}{"scraper2", "scraper3"}, failed[0].ContextMap()["scrapers"])
+	assert.Equal(t, "rejected", failed[0].ContextMap()["error"])
+}
+
+func TestBatchFlushThresholdInvalid(t *testing.T) {
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		append(sizedScraperOptions(), WithBatchFlushThreshold(0))...)
+	assert.EqualError(t, err, "batch flush threshold must be positive")
+}
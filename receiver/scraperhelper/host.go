@@ -0,0 +1,35 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+type hostContextKey struct{}
+
+// contextWithHost returns a copy of the context carrying the host.
+func contextWithHost(ctx context.Context, host component.Host) context.Context {
+	return context.WithValue(ctx, hostContextKey{}, host)
+}
+
+// HostFromContext returns the component.Host the receiver was started with,
+// from the context passed to the scrape and shutdown functions of scrapers.
+func HostFromContext(ctx context.Context) (component.Host, bool) {
+	host, ok := ctx.Value(hostContextKey{}).(component.Host)
+	return host, ok
+}
@@ -0,0 +1,117 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+func TestHost(t *testing.T) {
+	host := componenttest.NewNopHost()
+	tickerCh := make(chan time.Time)
+
+	scrapeHostCh := make(chan component.Host, 1)
+	scrape := func(ctx context.Context) (pdata.MetricSlice, error) {
+		h, _ := HostFromContext(ctx)
+		scrapeHostCh <- h
+		return singleMetric(), nil
+	}
+	var shutdownHost component.Host
+	shutdown := func(ctx context.Context) error {
+		shutdownHost, _ = HostFromContext(ctx)
+		return nil
+	}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape, WithShutdown(shutdown))),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+
+	_, err = receiver.(StateReporter).Host()
+	assert.Equal(t, ErrNotStarted, err)
+
+	require.NoError(t, receiver.Start(context.Background(), host))
+	h, err := receiver.(StateReporter).Host()
+	require.NoError(t, err)
+	assert.Equal(t, host, h)
+
+	tickerCh <- time.Now()
+	assert.Equal(t, host, <-scrapeHostCh)
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Equal(t, host, shutdownHost)
+
+	_, err = receiver.(StateReporter).Host()
+	assert.Equal(t, ErrNotStarted, err)
+}
+
+func TestHostConcurrentAccessDuringShutdown(t *testing.T) {
+	cfg := DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = time.Millisecond
+	receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), new(consumertest.MetricsSink), WithAllowEmptyScrapers())
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					_, _ = receiver.(StateReporter).Host()
+				}
+			}
+		}()
+	}
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	close(stop)
+	wg.Wait()
+
+	_, err = receiver.(StateReporter).Host()
+	assert.Equal(t, ErrNotStarted, err)
+}
+
+func TestHostFromContext(t *testing.T) {
+	_, ok := HostFromContext(context.Background())
+	assert.False(t, ok)
+
+	host := componenttest.NewNopHost()
+	h, ok := HostFromContext(contextWithHost(context.Background(), host))
+	assert.True(t, ok)
+	assert.Equal(t, host, h)
+}
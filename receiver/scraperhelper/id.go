@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+)
+
+// ScraperID identifies a scraper of a receiver. It is comparable, so that it
+// can be used as a map key.
+type ScraperID struct {
+	// Receiver is the name of the receiver.
+	Receiver string
+	// Scraper is the name of the scraper.
+	Scraper string
+}
+
+// NewScraperID returns the ScraperID of the scraper of the receiver, whose
+// names must not be empty.
+func NewScraperID(receiver, scraper string) (ScraperID, error) {
+	if receiver == "" {
+		return ScraperID{}, errors.New("receiver name must not be empty")
+	}
+	if scraper == "" {
+		return ScraperID{}, errors.New("scraper name must not be empty")
+	}
+	return ScraperID{Receiver: receiver, Scraper: scraper}, nil
+}
+
+// String returns the name of the receiver and the name of the scraper,
+// separated by a slash, such as "hostmetrics/cpu".
+func (id ScraperID) String() string {
+	return id.Receiver + "/" + id.Scraper
+}
+
+type scraperIDContextKey struct{}
+
+// contextWithScraperID returns a copy of the context carrying the ScraperID.
+func contextWithScraperID(ctx context.Context, id ScraperID) context.Context {
+	return context.WithValue(ctx, scraperIDContextKey{}, id)
+}
+
+// ScraperIDFromContext returns the ScraperID of the scraper, from the context
+// passed to its scrape function.
+func ScraperIDFromContext(ctx context.Context) (ScraperID, bool) {
+	id, ok := ctx.Value(scraperIDContextKey{}).(ScraperID)
+	return id, ok
+}
+
+// scraperID returns the ScraperID of the scraper with the given name of the
+// receiver.
+func (sc *controller) scraperID(name string) ScraperID {
+	return ScraperID{Receiver: sc.name, Scraper: name}
+}
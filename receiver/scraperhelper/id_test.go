@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+func TestNewScraperID(t *testing.T) {
+	id, err := NewScraperID("hostmetrics", "cpu")
+	require.NoError(t, err)
+	assert.Equal(t, ScraperID{Receiver: "hostmetrics", Scraper: "cpu"}, id)
+	assert.Equal(t, "hostmetrics/cpu", id.String())
+
+	_, err = NewScraperID("", "cpu")
+	assert.EqualError(t, err, "receiver name must not be empty")
+	_, err = NewScraperID("hostmetrics", "")
+	assert.EqualError(t, err, "scraper name must not be empty")
+}
+
+func TestScraperIDMapKey(t *testing.T) {
+	cpu, err := NewScraperID("hostmetrics", "cpu")
+	require.NoError(t, err)
+	scrapes := map[ScraperID]int{cpu: 1}
+	scrapes[ScraperID{Receiver: "hostmetrics", Scraper: "cpu"}]++
+	scrapes[ScraperID{Receiver: "hostmetrics/1", Scraper: "cpu"}]++
+
+	assert.Equal(t, map[ScraperID]int{
+		{Receiver: "hostmetrics", Scraper: "cpu"}:   2,
+		{Receiver: "hostmetrics/1", Scraper: "cpu"}: 1,
+	}, scrapes)
+}
+
+func TestScraperIDFromContext(t *testing.T) {
+	_, ok := ScraperIDFromContext(context.Background())
+	assert.False(t, ok)
+
+	ids := make(chan ScraperID, 2)
+	scrape := func(ctx context.Context) (pdata.MetricSlice, error) {
+		id, _ := ScraperIDFromContext(ctx)
+		ids <- id
+		return singleMetric(), nil
+	}
+	scrapeResource := func(ctx context.Context) (pdata.ResourceMetricsSlice, error) {
+		id, _ := ScraperIDFromContext(ctx)
+		ids <- id
+		return pdata.NewResourceMetricsSlice(), nil
+	}
+
+	tickerCh := make(chan time.Time)
+	cfg := DefaultScraperControllerSettings("receiver")
+	cfg.SetName("receiver/1")
+	receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("metrics", scrape)),
+		AddResourceMetricsScraper(NewResourceMetricsScraper("resource", scrapeResource)),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, receiver.Shutdown(context.Background())) }()
+
+	tickerCh <- time.Now()
+	assert.ElementsMatch(t, []ScraperID{
+		{Receiver: "receiver/1", Scraper: "metrics"},
+		{Receiver: "receiver/1", Scraper: "resource"},
+	}, []ScraperID{<-ids, <-ids})
+}
@@ -0,0 +1,90 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// WithInstanceIDResourceAttribute adds a resource attribute with the given
+// key to all the scraped metrics, whose value identifies the collector
+// instance running the receiver, so that backends can detect several
+// collectors scraping the same target. Attributes already set by the scrapers
+// are not overwritten. Using this option declares that the receiver mutates
+// the scraped metrics.
+//
+// The instance ID is the hostname followed by a random suffix, and is
+// generated each time the receiver is started, so that it is stable while
+// the receiver is running. It is returned by InstanceID.
+func WithInstanceIDResourceAttribute(key string) ScraperControllerOption {
+	return func(o *controller) {
+		if key == "" {
+			o.optionErrs = append(o.optionErrs, errors.New("instance ID resource attribute key must not be empty"))
+			return
+		}
+		o.instanceIDKey = key
+		o.mutatesData = true
+	}
+}
+
+// InstanceID returns the instance ID the receiver was last started with, or
+// an empty string if it was never started, or if
+// WithInstanceIDResourceAttribute is not set.
+func (sc *controller) InstanceID() string {
+	return sc.instanceID.Load()
+}
+
+// generateInstanceID generates a new instance ID when the receiver is started,
+// if WithInstanceIDResourceAttribute is set.
+func (sc *controller) generateInstanceID() {
+	if sc.instanceIDKey == "" {
+		return
+	}
+	id := newInstanceID()
+	sc.instanceID.Store(id)
+	sc.logger.Info("Tagging scraped metrics with the collector instance ID",
+		zap.String("key", sc.instanceIDKey),
+		zap.String("instance_id", id))
+}
+
+// addInstanceID adds the instance ID to the resource of each of the scraped
+// ResourceMetrics.
+func (sc *controller) addInstanceID(metrics pdata.Metrics) {
+	if sc.instanceIDKey == "" {
+		return
+	}
+	insertResourceAttributes(metrics.ResourceMetrics(), []label{{key: sc.instanceIDKey, value: sc.instanceID.Load()}})
+}
+
+// newInstanceID returns the hostname, or "unknown" if it can not be read,
+// followed by a random suffix.
+func newInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		panic(err)
+	}
+	return hostname + "-" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,124 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+func TestInstanceIDResourceAttribute(t *testing.T) {
+	sink := new(scrapertest.SinkConsumer)
+	tickerCh := make(chan time.Time)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), sink,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("metrics", scrapertest.NewScrapeMetrics(1, 1))),
+		scraperhelper.AddResourceMetricsScraper(scraperhelper.NewResourceMetricsScraper("resource", scrapertest.NewScrapeResourceMetrics(2, 1, 1))),
+		scraperhelper.WithInstanceIDResourceAttribute("collector.instance.id"),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	assert.True(t, receiver.(interface {
+		GetCapabilities() component.ProcessorCapabilities
+	}).GetCapabilities().MutatesConsumedData)
+	assert.Empty(t, receiver.(scraperhelper.ScraperInspector).InstanceID())
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	// instanceIDs returns the instance IDs of all the resources passed to
+	// the sink since it was last reset.
+	instanceIDs := func() []string {
+		var ids []string
+		for _, md := range sink.Batches() {
+			rms := md.ResourceMetrics()
+			for i := 0; i < rms.Len(); i++ {
+				value, ok := rms.At(i).Resource().Attributes().Get("collector.instance.id")
+				require.True(t, ok)
+				require.Equal(t, pdata.AttributeValueSTRING, value.Type())
+				ids = append(ids, value.StringVal())
+			}
+		}
+		return ids
+	}
+
+	var previous string
+	for i := 0; i < 2; i++ {
+		sink.Reset()
+		require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+		id := receiver.(scraperhelper.ScraperInspector).InstanceID()
+		assert.True(t, strings.HasPrefix(id, hostname+"-"), id)
+		assert.NotEqual(t, previous, id)
+		previous = id
+
+		tickerCh <- time.Now()
+		tickerCh <- time.Now()
+		require.NoError(t, receiver.Shutdown(context.Background()))
+
+		// the ID is stable while the receiver is running, across ticks and
+		// scrapers.
+		assert.Equal(t, []string{id, id, id, id, id, id}, instanceIDs())
+		assert.Equal(t, id, receiver.(scraperhelper.ScraperInspector).InstanceID())
+	}
+}
+
+func TestInstanceIDResourceAttributeKeepsScrapedAttribute(t *testing.T) {
+	sink := new(scrapertest.SinkConsumer)
+	scrape := func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		rms := scrapertest.GenerateResourceMetrics(1, 1, 1)
+		rms.At(0).Resource().Attributes().InsertString("collector.instance.id", "scraped")
+		return rms, nil
+	}
+	tickerCh := make(chan time.Time)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), sink,
+		scraperhelper.AddResourceMetricsScraper(scraperhelper.NewResourceMetricsScraper("resource", scrape)),
+		scraperhelper.WithInstanceIDResourceAttribute("collector.instance.id"),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	require.Len(t, sink.Batches(), 1)
+	value, ok := sink.Batches()[0].ResourceMetrics().At(0).Resource().Attributes().Get("collector.instance.id")
+	require.True(t, ok)
+	assert.Equal(t, "scraped", value.StringVal())
+}
+
+func TestInstanceIDResourceAttributeEmptyKey(t *testing.T) {
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	_, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1))),
+		scraperhelper.WithInstanceIDResourceAttribute(""),
+	)
+	assert.EqualError(t, err, "instance ID resource attribute key must not be empty")
+	assert.True(t, errors.Is(err, scraperhelper.ErrInvalidOption))
+}
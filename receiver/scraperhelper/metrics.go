@@ -0,0 +1,408 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	tracetranslator "go.opentelemetry.io/collector/translator/trace"
+)
+
+type label struct {
+	key   string
+	value string
+}
+
+// newLabels returns the given labels sorted by key, so that they are always
+// added to data points in the same order.
+func newLabels(labels map[string]string) []label {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]label, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, label{key: k, value: labels[k]})
+	}
+	return out
+}
+
+// insertResourceAttributes adds the attributes to the resource of each of the
+// resource metrics, without overwriting existing attributes.
+func insertResourceAttributes(rms pdata.ResourceMetricsSlice, attributes []label) {
+	for i := 0; i < rms.Len(); i++ {
+		attrs := rms.At(i).Resource().Attributes()
+		for _, attr := range attributes {
+			attrs.InsertString(attr.key, attr.value)
+		}
+	}
+}
+
+// addResourceMetricsLabels adds the labels to every data point of the
+// resource metrics, without overwriting existing labels.
+func addResourceMetricsLabels(rms pdata.ResourceMetricsSlice, labels []label) {
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			addMetricsLabels(ilms.At(j).Metrics(), labels)
+		}
+	}
+}
+
+// addMetricsLabels adds the labels to every data point of the metrics,
+// without overwriting existing labels.
+func addMetricsLabels(metrics pdata.MetricSlice, labels []label) {
+	for i := 0; i < metrics.Len(); i++ {
+		forEachDataPointLabels(metrics.At(i), func(labelsMap pdata.StringMap) {
+			for _, l := range labels {
+				labelsMap.Insert(l.key, l.value)
+			}
+		})
+	}
+}
+
+// forEachDataPointLabels calls fn with the labels of each data point of the
+// metric, whatever its data type.
+func forEachDataPointLabels(metric pdata.Metric, fn func(pdata.StringMap)) {
+	switch metric.DataType() {
+	case pdata.MetricDataTypeIntGauge:
+		dps := metric.IntGauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).LabelsMap())
+		}
+	case pdata.MetricDataTypeDoubleGauge:
+		dps := metric.DoubleGauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).LabelsMap())
+		}
+	case pdata.MetricDataTypeIntSum:
+		dps := metric.IntSum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).LabelsMap())
+		}
+	case pdata.MetricDataTypeDoubleSum:
+		dps := metric.DoubleSum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).LabelsMap())
+		}
+	case pdata.MetricDataTypeIntHistogram:
+		dps := metric.IntHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).LabelsMap())
+		}
+	case pdata.MetricDataTypeDoubleHistogram:
+		dps := metric.DoubleHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).LabelsMap())
+		}
+	case pdata.MetricDataTypeDoubleSummary:
+		dps := metric.DoubleSummary().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			fn(dps.At(i).LabelsMap())
+		}
+	}
+}
+
+// DataPointCount returns the number of data points of the metrics, counted
+// like the data points reported by the observability of receivers.
+func DataPointCount(metrics pdata.MetricSlice) int {
+	n := 0
+	for i := 0; i < metrics.Len(); i++ {
+		n += dataPointCount(metrics.At(i))
+	}
+	return n
+}
+
+// ResourceMetricsDataPointCount returns the number of data points of the
+// resource metrics, counted like DataPointCount.
+func ResourceMetricsDataPointCount(resourceMetrics pdata.ResourceMetricsSlice) int {
+	n := 0
+	for i := 0; i < resourceMetrics.Len(); i++ {
+		ilms := resourceMetrics.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			n += DataPointCount(ilms.At(j).Metrics())
+		}
+	}
+	return n
+}
+
+// dataPointCount returns the number of data points of the metric.
+func dataPointCount(metric pdata.Metric) int {
+	switch metric.DataType() {
+	case pdata.MetricDataTypeIntGauge:
+		return metric.IntGauge().DataPoints().Len()
+	case pdata.MetricDataTypeDoubleGauge:
+		return metric.DoubleGauge().DataPoints().Len()
+	case pdata.MetricDataTypeIntSum:
+		return metric.IntSum().DataPoints().Len()
+	case pdata.MetricDataTypeDoubleSum:
+		return metric.DoubleSum().DataPoints().Len()
+	case pdata.MetricDataTypeIntHistogram:
+		return metric.IntHistogram().DataPoints().Len()
+	case pdata.MetricDataTypeDoubleHistogram:
+		return metric.DoubleHistogram().DataPoints().Len()
+	case pdata.MetricDataTypeDoubleSummary:
+		return metric.DoubleSummary().DataPoints().Len()
+	}
+	return 0
+}
+
+// truncation records the metrics dropped by truncateMetrics.
+type truncation struct {
+	// remaining is the number of data points that can still be kept.
+	remaining      int
+	droppedMetrics int
+	droppedPoints  int
+}
+
+// truncateMetrics keeps the metrics, in order, for as long as all of their
+// data points fit in the remaining budget, and drops the rest. Metrics are
+// never split.
+func (t *truncation) truncateMetrics(metrics pdata.MetricSlice) {
+	keep := metrics.Len()
+	for i := 0; i < metrics.Len(); i++ {
+		count := dataPointCount(metrics.At(i))
+		if keep == metrics.Len() && count <= t.remaining {
+			t.remaining -= count
+			continue
+		}
+		if keep == metrics.Len() {
+			keep = i
+			t.remaining = 0
+		}
+		t.droppedMetrics++
+		t.droppedPoints += count
+	}
+	metrics.Resize(keep)
+}
+
+// truncateResourceMetrics applies truncateMetrics to each of the metric
+// slices of the resource metrics in order.
+func (t *truncation) truncateResourceMetrics(rms pdata.ResourceMetricsSlice) {
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			t.truncateMetrics(ilms.At(j).Metrics())
+		}
+	}
+}
+
+// err returns a partial scrape error describing the dropped data points, if
+// any were dropped.
+func (t *truncation) err(maxDataPoints int) error {
+	if t.droppedMetrics == 0 {
+		return nil
+	}
+	return consumererror.NewPartialScrapeError(
+		fmt.Errorf("dropped %d data points from %d metrics exceeding the limit of %d data points", t.droppedPoints, t.droppedMetrics, maxDataPoints),
+		t.droppedMetrics,
+	)
+}
+
+// setTimestamps sets the timestamp of every data point of the metrics,
+// leaving their start timestamps unchanged.
+func setTimestamps(metrics pdata.MetricSlice, ts pdata.TimestampUnixNano) {
+	for i := 0; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		switch metric.DataType() {
+		case pdata.MetricDataTypeIntGauge:
+			dps := metric.IntGauge().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dps.At(j).SetTimestamp(ts)
+			}
+		case pdata.MetricDataTypeDoubleGauge:
+			dps := metric.DoubleGauge().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dps.At(j).SetTimestamp(ts)
+			}
+		case pdata.MetricDataTypeIntSum:
+			dps := metric.IntSum().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dps.At(j).SetTimestamp(ts)
+			}
+		case pdata.MetricDataTypeDoubleSum:
+			dps := metric.DoubleSum().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dps.At(j).SetTimestamp(ts)
+			}
+		case pdata.MetricDataTypeIntHistogram:
+			dps := metric.IntHistogram().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dps.At(j).SetTimestamp(ts)
+			}
+		case pdata.MetricDataTypeDoubleHistogram:
+			dps := metric.DoubleHistogram().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dps.At(j).SetTimestamp(ts)
+			}
+		case pdata.MetricDataTypeDoubleSummary:
+			dps := metric.DoubleSummary().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dps.At(j).SetTimestamp(ts)
+			}
+		}
+	}
+}
+
+// seriesKeys builds keys identifying the series of data points, reusing its
+// buffers between calls.
+type seriesKeys struct {
+	buf    strings.Builder
+	labels []string
+}
+
+// key returns a key identifying the series of a data point with the given
+// labels, of the metric with the given name, from the resource identified by
+// resourceKey.
+func (sk *seriesKeys) key(resourceKey, name string, labels pdata.StringMap) string {
+	sk.labels = sk.labels[:0]
+	labels.ForEach(func(k, v string) {
+		sk.labels = append(sk.labels, k+"="+v)
+	})
+	sort.Strings(sk.labels)
+
+	sk.buf.Reset()
+	sk.buf.WriteString(resourceKey)
+	sk.buf.WriteByte(0)
+	sk.buf.WriteString(name)
+	for _, l := range sk.labels {
+		sk.buf.WriteByte(0)
+		sk.buf.WriteString(l)
+	}
+	return sk.buf.String()
+}
+
+// resourceID returns a key identifying a resource by its attributes.
+func resourceID(resource pdata.Resource) string {
+	attrs := resource.Attributes()
+	kvs := make([]string, 0, attrs.Len())
+	attrs.ForEach(func(k string, v pdata.AttributeValue) {
+		kvs = append(kvs, k+"="+tracetranslator.AttributeValueToString(v, false))
+	})
+	sort.Strings(kvs)
+	return strings.Join(kvs, "\x00")
+}
+
+// dataPointSlice gives access to the data points of a metric regardless of
+// its data type.
+type dataPointSlice struct {
+	len       func() int
+	labels    func(i int) pdata.StringMap
+	timestamp func(i int) pdata.TimestampUnixNano
+	// move copies the data point at index from over the one at index to.
+	move   func(from, to int)
+	resize func(n int)
+}
+
+func dataPointsOf(metric pdata.Metric) (dataPointSlice, bool) {
+	switch metric.DataType() {
+	case pdata.MetricDataTypeIntGauge:
+		dps := metric.IntGauge().DataPoints()
+		return dataPointSlice{
+			len:       dps.Len,
+			labels:    func(i int) pdata.StringMap { return dps.At(i).LabelsMap() },
+			timestamp: func(i int) pdata.TimestampUnixNano { return dps.At(i).Timestamp() },
+			move:      func(from, to int) { dps.At(from).CopyTo(dps.At(to)) },
+			resize:    dps.Resize,
+		}, true
+	case pdata.MetricDataTypeDoubleGauge:
+		dps := metric.DoubleGauge().DataPoints()
+		return dataPointSlice{
+			len:       dps.Len,
+			labels:    func(i int) pdata.StringMap { return dps.At(i).LabelsMap() },
+			timestamp: func(i int) pdata.TimestampUnixNano { return dps.At(i).Timestamp() },
+			move:      func(from, to int) { dps.At(from).CopyTo(dps.At(to)) },
+			resize:    dps.Resize,
+		}, true
+	case pdata.MetricDataTypeIntSum:
+		dps := metric.IntSum().DataPoints()
+		return dataPointSlice{
+			len:       dps.Len,
+			labels:    func(i int) pdata.StringMap { return dps.At(i).LabelsMap() },
+			timestamp: func(i int) pdata.TimestampUnixNano { return dps.At(i).Timestamp() },
+			move:      func(from, to int) { dps.At(from).CopyTo(dps.At(to)) },
+			resize:    dps.Resize,
+		}, true
+	case pdata.MetricDataTypeDoubleSum:
+		dps := metric.DoubleSum().DataPoints()
+		return dataPointSlice{
+			len:       dps.Len,
+			labels:    func(i int) pdata.StringMap { return dps.At(i).LabelsMap() },
+			timestamp: func(i int) pdata.TimestampUnixNano { return dps.At(i).Timestamp() },
+			move:      func(from, to int) { dps.At(from).CopyTo(dps.At(to)) },
+			resize:    dps.Resize,
+		}, true
+	case pdata.MetricDataTypeIntHistogram:
+		dps := metric.IntHistogram().DataPoints()
+		return dataPointSlice{
+			len:       dps.Len,
+			labels:    func(i int) pdata.StringMap { return dps.At(i).LabelsMap() },
+			timestamp: func(i int) pdata.TimestampUnixNano { return dps.At(i).Timestamp() },
+			move:      func(from, to int) { dps.At(from).CopyTo(dps.At(to)) },
+			resize:    dps.Resize,
+		}, true
+	case pdata.MetricDataTypeDoubleHistogram:
+		dps := metric.DoubleHistogram().DataPoints()
+		return dataPointSlice{
+			len:       dps.Len,
+			labels:    func(i int) pdata.StringMap { return dps.At(i).LabelsMap() },
+			timestamp: func(i int) pdata.TimestampUnixNano { return dps.At(i).Timestamp() },
+			move:      func(from, to int) { dps.At(from).CopyTo(dps.At(to)) },
+			resize:    dps.Resize,
+		}, true
+	case pdata.MetricDataTypeDoubleSummary:
+		dps := metric.DoubleSummary().DataPoints()
+		return dataPointSlice{
+			len:       dps.Len,
+			labels:    func(i int) pdata.StringMap { return dps.At(i).LabelsMap() },
+			timestamp: func(i int) pdata.TimestampUnixNano { return dps.At(i).Timestamp() },
+			move:      func(from, to int) { dps.At(from).CopyTo(dps.At(to)) },
+			resize:    dps.Resize,
+		}, true
+	}
+	return dataPointSlice{}, false
+}
+
+// removeDataPoints removes the data points of the metric for which remove
+// returns true, preserving the order of the others, and returns the number of
+// data points removed.
+func removeDataPoints(metric pdata.Metric, remove func(dps dataPointSlice, i int) bool) int {
+	dps, ok := dataPointsOf(metric)
+	if !ok {
+		return 0
+	}
+
+	n := dps.len()
+	kept := 0
+	for i := 0; i < n; i++ {
+		if remove(dps, i) {
+			continue
+		}
+		if kept != i {
+			dps.move(i, kept)
+		}
+		kept++
+	}
+	if kept != n {
+		dps.resize(kept)
+	}
+	return n - kept
+}
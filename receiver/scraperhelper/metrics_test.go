@@ -0,0 +1,195 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+var allMetricDataTypes = []pdata.MetricDataType{
+	pdata.MetricDataTypeIntGauge,
+	pdata.MetricDataTypeDoubleGauge,
+	pdata.MetricDataTypeIntSum,
+	pdata.MetricDataTypeDoubleSum,
+	pdata.MetricDataTypeIntHistogram,
+	pdata.MetricDataTypeDoubleHistogram,
+	pdata.MetricDataTypeDoubleSummary,
+}
+
+// metricsOfAllTypes returns a metric of each data type, each with a single
+// data point labeled with source=scraper.
+func metricsOfAllTypes() pdata.MetricSlice {
+	metrics := pdata.NewMetricSlice()
+	metrics.Resize(len(allMetricDataTypes))
+	for i, dataType := range allMetricDataTypes {
+		metric := metrics.At(i)
+		metric.SetDataType(dataType)
+		switch dataType {
+		case pdata.MetricDataTypeIntGauge:
+			metric.IntGauge().DataPoints().Resize(1)
+		case pdata.MetricDataTypeDoubleGauge:
+			metric.DoubleGauge().DataPoints().Resize(1)
+		case pdata.MetricDataTypeIntSum:
+			metric.IntSum().DataPoints().Resize(1)
+		case pdata.MetricDataTypeDoubleSum:
+			metric.DoubleSum().DataPoints().Resize(1)
+		case pdata.MetricDataTypeIntHistogram:
+			metric.IntHistogram().DataPoints().Resize(1)
+		case pdata.MetricDataTypeDoubleHistogram:
+			metric.DoubleHistogram().DataPoints().Resize(1)
+		case pdata.MetricDataTypeDoubleSummary:
+			metric.DoubleSummary().DataPoints().Resize(1)
+		}
+		forEachDataPointLabels(metric, func(labels pdata.StringMap) {
+			labels.Insert("source", "scraper")
+		})
+	}
+	return metrics
+}
+
+func TestConstLabels(t *testing.T) {
+	scrapeMetrics := func(context.Context) (pdata.MetricSlice, error) {
+		return metricsOfAllTypes(), nil
+	}
+	scrapeResourceMetrics := func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		rms := pdata.NewResourceMetricsSlice()
+		rms.Resize(1)
+		ilms := rms.At(0).InstrumentationLibraryMetrics()
+		ilms.Resize(1)
+		metricsOfAllTypes().MoveAndAppendTo(ilms.At(0).Metrics())
+		return rms, nil
+	}
+	constLabels := WithConstLabels(map[string]string{"source": "replica-2", "replica": "2"})
+
+	ms := NewMetricsScraper("metrics", scrapeMetrics, constLabels)
+	metrics, err := ms.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	assertLabels(t, metrics)
+
+	rms := NewResourceMetricsScraper("resource", scrapeResourceMetrics, constLabels)
+	resourceMetrics, err := rms.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	assertLabels(t, resourceMetrics.At(0).InstrumentationLibraryMetrics().At(0).Metrics())
+}
+
+func assertLabels(t *testing.T, metrics pdata.MetricSlice) {
+	require.Equal(t, len(allMetricDataTypes), metrics.Len())
+	for i := 0; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		count := 0
+		forEachDataPointLabels(metric, func(labels pdata.StringMap) {
+			count++
+			got := map[string]string{}
+			labels.ForEach(func(k string, v string) { got[k] = v })
+			assert.Equal(t, map[string]string{"source": "scraper", "replica": "2"}, got, metric.DataType().String())
+		})
+		assert.Equal(t, 1, count, metric.DataType().String())
+	}
+}
+
+// gaugesWithDataPoints returns a gauge for each of the counts, with that
+// many data points.
+func gaugesWithDataPoints(counts ...int) pdata.MetricSlice {
+	metrics := pdata.NewMetricSlice()
+	metrics.Resize(len(counts))
+	for i, count := range counts {
+		metrics.At(i).SetName(fmt.Sprintf("metric%d", i))
+		metrics.At(i).SetDataType(pdata.MetricDataTypeIntGauge)
+		metrics.At(i).IntGauge().DataPoints().Resize(count)
+	}
+	return metrics
+}
+
+func TestMaxDataPoints(t *testing.T) {
+	scrapeMetrics := func(context.Context) (pdata.MetricSlice, error) {
+		return gaugesWithDataPoints(3, 4, 2, 5, 1), nil
+	}
+
+	ms := NewMetricsScraper("metrics", scrapeMetrics, WithMaxDataPoints(8))
+	metrics, err := ms.Scrape(context.Background(), "receiver")
+	require.Error(t, err)
+	assert.EqualError(t, err, "dropped 8 data points from 3 metrics exceeding the limit of 8 data points")
+	var partialErr consumererror.PartialScrapeError
+	require.True(t, errors.As(err, &partialErr))
+	assert.Equal(t, 3, partialErr.Failed)
+
+	require.Equal(t, 2, metrics.Len())
+	assert.Equal(t, "metric0", metrics.At(0).Name())
+	assert.Equal(t, "metric1", metrics.At(1).Name())
+}
+
+func TestMaxDataPointsResourceMetrics(t *testing.T) {
+	scrapeResourceMetrics := func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		rms := pdata.NewResourceMetricsSlice()
+		rms.Resize(2)
+		for i, counts := range [][]int{{10, 20}, {30, 40}} {
+			ilms := rms.At(i).InstrumentationLibraryMetrics()
+			ilms.Resize(1)
+			gaugesWithDataPoints(counts...).MoveAndAppendTo(ilms.At(0).Metrics())
+		}
+		return rms, nil
+	}
+
+	rms := NewResourceMetricsScraper("resource", scrapeResourceMetrics, WithMaxDataPoints(60))
+	resourceMetrics, err := rms.Scrape(context.Background(), "receiver")
+	assert.EqualError(t, err, "dropped 40 data points from 1 metrics exceeding the limit of 60 data points")
+
+	_, dataPoints := metricsFromResourceMetrics(resourceMetrics).MetricAndDataPointCount()
+	assert.Equal(t, 60, dataPoints)
+	assert.Equal(t, 1, resourceMetrics.At(1).InstrumentationLibraryMetrics().At(0).Metrics().Len())
+}
+
+func TestMaxDataPointsUnlimited(t *testing.T) {
+	scrapeMetrics := func(context.Context) (pdata.MetricSlice, error) {
+		return gaugesWithDataPoints(100, 100), nil
+	}
+
+	ms := NewMetricsScraper("metrics", scrapeMetrics, WithMaxDataPoints(0))
+	metrics, err := ms.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	assert.Equal(t, 2, metrics.Len())
+}
+
+func metricsFromResourceMetrics(rms pdata.ResourceMetricsSlice) pdata.Metrics {
+	md := pdata.NewMetrics()
+	rms.CopyTo(md.ResourceMetrics())
+	return md
+}
+
+func TestDataPointCount(t *testing.T) {
+	metrics := metricsOfAllTypes()
+	rms := pdata.NewResourceMetricsSlice()
+	rms.Resize(2)
+	for i := 0; i < rms.Len(); i++ {
+		rms.At(i).InstrumentationLibraryMetrics().Resize(1)
+		metrics.CopyTo(rms.At(i).InstrumentationLibraryMetrics().At(0).Metrics())
+	}
+
+	// the data points are counted like the data points reported by the
+	// observability of receivers.
+	_, expected := metricsFromResourceMetrics(rms).MetricAndDataPointCount()
+	assert.Equal(t, len(allMetricDataTypes), DataPointCount(metrics))
+	assert.Equal(t, expected, ResourceMetricsDataPointCount(rms))
+	assert.Equal(t, 0, DataPointCount(pdata.NewMetricSlice()))
+}
@@ -0,0 +1,301 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"container/heap"
+	"time"
+)
+
+// ScheduleReason is why a scraper is next scraped when it is.
+type ScheduleReason string
+
+const (
+	// ScheduleInterval means the scraper is next scraped once its collection
+	// interval elapsed since it was last scraped, or once the collection
+	// interval of the receiver elapsed since it was started.
+	ScheduleInterval ScheduleReason = "interval"
+	// ScheduleInitialDelay means the scraper is next scraped once its initial
+	// delay elapsed.
+	ScheduleInitialDelay ScheduleReason = "initial_delay"
+	// ScheduleBackoff means the scraper is next scraped once its failure
+	// policy stops backing off after failed scrapes.
+	ScheduleBackoff ScheduleReason = "backoff"
+	// SchedulePaused means the scraper is no longer scraped, as its failure
+	// policy disabled it until the receiver is restarted.
+	SchedulePaused ScheduleReason = "paused"
+)
+
+// ScheduledScrape is when a scraper of a running receiver is next scraped.
+type ScheduledScrape struct {
+	// ID identifies the scraper.
+	ID ScraperID
+	// Time is the deadline the scraper is next scraped on, or the zero time
+	// if it is paused. If the ticks of the receiver are sent with
+	// WithTickerChannel, their deadlines are not known, and Time is instead
+	// the earliest time of a tick the scraper is scraped on, which is the
+	// zero time if it is scraped on the next tick.
+	Time time.Time
+	// Reason is why the scraper is next scraped at Time.
+	Reason ScheduleReason
+}
+
+// NextScrapes returns when each of the scrapers of the receiver is next
+// scraped, in the order they were added, or nil if the receiver is not
+// running. The times are those of the deadlines the receiver waits for, and
+// of the state its scrapers are checked against on each deadline.
+func (sc *controller) NextScrapes() []ScheduledScrape {
+	if sc.State() != StateRunning {
+		return nil
+	}
+
+	sc.scrapersMu.RLock()
+	defer sc.scrapersMu.RUnlock()
+	sc.scheduleMu.Lock()
+	defer sc.scheduleMu.Unlock()
+
+	next := make([]ScheduledScrape, 0, len(sc.scrapers))
+	for _, scraper := range sc.scrapers {
+		next = append(next, sc.nextScrape(scraper))
+	}
+	return next
+}
+
+// nextScrape returns when the scraper is next scraped, as recorded in its
+// schedule. It must be called with scrapersMu and scheduleMu held.
+func (sc *controller) nextScrape(scraper BaseScraper) ScheduledScrape {
+	s := sc.schedules[scraper.Name()]
+	return ScheduledScrape{ID: sc.scraperID(scraper.Name()), Time: s.next, Reason: s.reason}
+}
+
+// scraperSchedule is the schedule of a scraper of a receiver: the collection
+// interval and initial delay it is scraped with, resolved whenever the
+// scrapers or their configurations change, and the deadline it is next due
+// at and why, which are advanced whenever it is due, and after it failed. The
+// schedules of the scrapers are the entries of the heap of deadlines of the
+// receiver, except those of the scrapers paused by their failure policy.
+type scraperSchedule struct {
+	scheduledEntry
+	reason       ScheduleReason
+	initialDelay time.Duration
+	// last is the tick the scraper was last scraped on, once scraped is set.
+	last    time.Time
+	scraped bool
+}
+
+// paused reports whether the failure policy of the scraper disabled it,
+// which removed its schedule from the deadlines.
+func (s *scraperSchedule) paused() bool {
+	return s.index < 0
+}
+
+// updateSchedules adds a schedule for each of the scrapers that do not have
+// one, removes those of the removed scrapers, and resolves the collection
+// intervals and initial delays of the others, keeping when they were last
+// scraped. The deadline of a scraper whose interval or initial delay changed
+// is computed again from them, as is the one of a paused scraper that was
+// replaced by UpdateConfig. It must be called with scrapersMu held for
+// writing whenever the scrapers or their configurations change.
+func (sc *controller) updateSchedules() {
+	sc.scheduleMu.Lock()
+	defer sc.scheduleMu.Unlock()
+
+	names := make(map[string]bool, len(sc.scrapers))
+	for _, scraper := range sc.scrapers {
+		name := scraper.Name()
+		names[name] = true
+		interval, initialDelay := sc.scraperInterval(scraper), sc.initialDelay(scraper)
+		s, ok := sc.schedules[name]
+		if !ok {
+			s = &scraperSchedule{scheduledEntry: scheduledEntry{interval: interval}, initialDelay: initialDelay}
+			s.next, s.reason = sc.firstDeadline(s)
+			sc.schedules[name] = s
+			heap.Push(&sc.deadlines, &s.scheduledEntry)
+			continue
+		}
+		// the failure policy of a replaced scraper starts over.
+		resumed := s.paused() && !failurePaused(scraper)
+		changed := interval != s.interval || initialDelay != s.initialDelay
+		s.interval, s.initialDelay = interval, initialDelay
+		if !resumed && (!changed || s.paused()) {
+			continue
+		}
+		if s.scraped {
+			s.next, s.reason = sc.deadlineAfter(s, s.last), ScheduleInterval
+		} else {
+			s.next, s.reason = sc.firstDeadline(s)
+		}
+		if resumed {
+			heap.Push(&sc.deadlines, &s.scheduledEntry)
+		} else {
+			heap.Fix(&sc.deadlines, s.index)
+		}
+	}
+	for name, s := range sc.schedules {
+		if !names[name] {
+			if !s.paused() {
+				heap.Remove(&sc.deadlines, s.index)
+			}
+			delete(sc.schedules, name)
+		}
+	}
+	sc.notifyRescheduled()
+}
+
+// failurePaused reports whether the failure policy of the scraper disabled it
+// after failed scrapes.
+func failurePaused(scraper BaseScraper) bool {
+	f, ok := scraper.(interface{ failureSchedule() (time.Time, bool) })
+	if !ok {
+		return false
+	}
+	_, paused := f.failureSchedule()
+	return paused
+}
+
+// scheduleFailedScrapers applies the failure policies of the scrapers scraped
+// on the given tick to their schedules: a scraper backing off after failed
+// scrapes is next due once its backoff ends, and a scraper disabled after
+// them is paused, no longer being due until the receiver is restarted.
+func (sc *controller) scheduleFailedScrapers(tick time.Time) {
+	sc.scrapersMu.RLock()
+	defer sc.scrapersMu.RUnlock()
+	sc.scheduleMu.Lock()
+	defer sc.scheduleMu.Unlock()
+
+	for _, scraper := range sc.scrapers {
+		s := sc.schedules[scraper.Name()]
+		if s.paused() || !s.scraped || !s.last.Equal(tick) {
+			continue
+		}
+		f, ok := scraper.(interface{ failureSchedule() (time.Time, bool) })
+		if !ok {
+			continue
+		}
+		until, paused := f.failureSchedule()
+		switch {
+		case paused:
+			heap.Remove(&sc.deadlines, s.index)
+			s.next, s.reason = time.Time{}, SchedulePaused
+		case until.After(s.next):
+			s.next, s.reason = until, ScheduleBackoff
+			heap.Fix(&sc.deadlines, s.index)
+		}
+	}
+}
+
+// externalTicks reports whether the receiver is scraped on ticks it does not
+// schedule, sent with WithTickerChannel or by ScrapeCycle, on which the
+// scrapers whose deadline passed are due.
+func (sc *controller) externalTicks() bool {
+	return sc.tickerCh != nil || sc.noScrapeLoop
+}
+
+// firstDeadline returns the deadline a scraper is first due at, and why: one
+// collection interval of the receiver after the receiver started, or right
+// away if ScrapeOnStartGate is enabled, or one collection interval after it
+// was added to the running receiver, and at the earliest once its initial
+// delay elapsed since the receiver started. Unless it has an initial delay,
+// the scraper is due on the first of the external ticks.
+func (sc *controller) firstDeadline(s *scraperSchedule) (time.Time, ScheduleReason) {
+	var first time.Time
+	switch {
+	case sc.externalTicks():
+	case sc.State() == StateStarting:
+		first = sc.startedAt
+		if !sc.scrapeOnStart {
+			first = first.Add(sc.collectionInterval)
+		}
+	default:
+		first = sc.now().Add(sc.collectionInterval)
+	}
+	if delayed := sc.startedAt.Add(s.initialDelay); s.initialDelay > 0 && delayed.After(first) {
+		return delayed, ScheduleInitialDelay
+	}
+	return first, ScheduleInterval
+}
+
+// deadlineAfter returns the deadline the scraper is next due at after being
+// due on the given tick, which is one collection interval of the scraper
+// later. As the external ticks are not exactly one collection interval of the
+// receiver apart, the scraper is then instead due within half a tick of its
+// interval, or on every tick if its interval is not longer than the one of
+// the receiver.
+func (sc *controller) deadlineAfter(s *scraperSchedule, tick time.Time) time.Time {
+	if !sc.externalTicks() {
+		return tick.Add(s.interval)
+	}
+	if s.interval > sc.collectionInterval {
+		return tick.Add(s.interval - sc.collectionInterval/2)
+	}
+	return tick
+}
+
+// nextDeadline returns the earliest deadline of the scrapers, if there is any.
+func (sc *controller) nextDeadline() (time.Time, bool) {
+	sc.scheduleMu.Lock()
+	defer sc.scheduleMu.Unlock()
+	if len(sc.deadlines) == 0 {
+		return time.Time{}, false
+	}
+	return sc.deadlines[0].next, true
+}
+
+// skipMissedDeadlines advances the deadlines at or before the given tick,
+// which were not scraped on it, and those that passed while scraping on it,
+// to the first deadline of their scraper after now, so that the scrapers are
+// not scraped back-to-back to catch up.
+func (sc *controller) skipMissedDeadlines(tick, now time.Time) {
+	sc.scheduleMu.Lock()
+	defer sc.scheduleMu.Unlock()
+	missed := func(next time.Time) bool {
+		return !next.After(tick) || next.Before(now)
+	}
+	// the earliest deadline is checked first, as none is usually missed.
+	if len(sc.deadlines) == 0 || !missed(sc.deadlines[0].next) {
+		return
+	}
+	skipUntil := now
+	if now.Before(tick) {
+		skipUntil = tick
+	}
+	for _, s := range sc.schedules {
+		if s.paused() || !missed(s.next) {
+			continue
+		}
+		s.next = s.next.Add(s.interval * (skipUntil.Sub(s.next)/s.interval + 1))
+		s.reason = ScheduleInterval
+		heap.Fix(&sc.deadlines, s.index)
+	}
+}
+
+// setRescheduled sets the function called with the earliest deadline of the
+// scrapers whenever the deadlines change other than by scraping, to wake
+// whatever waits for it, and calls it with the current one.
+func (sc *controller) setRescheduled(rescheduled func(time.Time)) {
+	sc.scheduleMu.Lock()
+	defer sc.scheduleMu.Unlock()
+	sc.rescheduled = rescheduled
+	sc.notifyRescheduled()
+}
+
+// notifyRescheduled calls the function set with setRescheduled, if any, with
+// the earliest deadline of the scrapers, if there is any. It must be called
+// with scheduleMu held.
+func (sc *controller) notifyRescheduled() {
+	if sc.rescheduled != nil && len(sc.deadlines) > 0 {
+		sc.rescheduled(sc.deadlines[0].next)
+	}
+}
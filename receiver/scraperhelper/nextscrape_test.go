@@ -0,0 +1,206 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+func scheduled(scraper string, at time.Time, reason scraperhelper.ScheduleReason) scraperhelper.ScheduledScrape {
+	return scraperhelper.ScheduledScrape{
+		ID:     scraperhelper.ScraperID{Receiver: "receiver", Scraper: scraper},
+		Time:   at,
+		Reason: reason,
+	}
+}
+
+func TestNextScrapes(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	clock := scrapertest.NewFakeClock(start)
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return scrapertest.GenerateMetrics(1, 1), nil }
+	fail := func(context.Context) (pdata.MetricSlice, error) {
+		return pdata.NewMetricSlice(), errors.New("unavailable")
+	}
+
+	next := new(scrapertest.SinkConsumer)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = 10 * time.Second
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), next,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("every_tick", scrape)),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("slow", scrape,
+			scraperhelper.WithConfig(&scraperhelper.ScraperSettings{CollectionIntervalVal: 30 * time.Second}))),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("delayed", scrape,
+			scraperhelper.WithInitialDelay(25*time.Second))),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("backoff", fail,
+			scraperhelper.WithFailurePolicy(scraperhelper.FailurePolicy{Mode: scraperhelper.FailureBackoff, InitialBackoff: 25 * time.Second, MaxBackoff: time.Minute}))),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("paused", fail,
+			scraperhelper.WithFailurePolicy(scraperhelper.FailurePolicy{Mode: scraperhelper.FailureDisableAfter, MaxFailures: 1}))),
+		scraperhelper.WithClock(clock),
+	)
+	require.NoError(t, err)
+	assert.Nil(t, receiver.(scraperhelper.ScraperInspector).NextScrapes())
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	// the scheduled scrapes may be read while the receiver scrapes.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				receiver.(scraperhelper.ScraperInspector).NextScrapes()
+			}
+		}
+	}()
+
+	scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+	assert.Equal(t, []scraperhelper.ScheduledScrape{
+		scheduled("every_tick", at(10*time.Second), scraperhelper.ScheduleInterval),
+		scheduled("slow", at(10*time.Second), scraperhelper.ScheduleInterval),
+		scheduled("delayed", at(25*time.Second), scraperhelper.ScheduleInitialDelay),
+		scheduled("backoff", at(10*time.Second), scraperhelper.ScheduleInterval),
+		scheduled("paused", at(10*time.Second), scraperhelper.ScheduleInterval),
+	}, receiver.(scraperhelper.ScraperInspector).NextScrapes())
+
+	// the failed scraper is next scraped once its backoff ends, rather than
+	// on the first deadline after it.
+	clock.Advance(10 * time.Second)
+	scrapertest.WaitForBatches(t, next, 1, time.Second)
+	assert.Equal(t, []scraperhelper.ScheduledScrape{
+		scheduled("every_tick", at(20*time.Second), scraperhelper.ScheduleInterval),
+		scheduled("slow", at(40*time.Second), scraperhelper.ScheduleInterval),
+		scheduled("delayed", at(25*time.Second), scraperhelper.ScheduleInitialDelay),
+		scheduled("backoff", at(35*time.Second), scraperhelper.ScheduleBackoff),
+		scheduled("paused", time.Time{}, scraperhelper.SchedulePaused),
+	}, receiver.(scraperhelper.ScraperInspector).NextScrapes())
+
+	// the delayed scraper is scraped on its own deadline, between those of
+	// the receiver, and then every interval from it.
+	scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+	clock.Advance(10 * time.Second)
+	scrapertest.WaitForBatches(t, next, 2, time.Second)
+	scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+	clock.Advance(5 * time.Second)
+	scrapertest.WaitForBatches(t, next, 3, time.Second)
+	assert.Equal(t, []scraperhelper.ScheduledScrape{
+		scheduled("every_tick", at(30*time.Second), scraperhelper.ScheduleInterval),
+		scheduled("slow", at(40*time.Second), scraperhelper.ScheduleInterval),
+		scheduled("delayed", at(35*time.Second), scraperhelper.ScheduleInterval),
+		scheduled("backoff", at(35*time.Second), scraperhelper.ScheduleBackoff),
+		scheduled("paused", time.Time{}, scraperhelper.SchedulePaused),
+	}, receiver.(scraperhelper.ScraperInspector).NextScrapes())
+
+	close(done)
+	wg.Wait()
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Nil(t, receiver.(scraperhelper.ScraperInspector).NextScrapes())
+}
+
+func TestNextScrapesSingleScraper(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := scrapertest.NewFakeClock(start)
+	next := new(scrapertest.SinkConsumer)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = 10 * time.Second
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), next,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("slow",
+			func(context.Context) (pdata.MetricSlice, error) { return scrapertest.GenerateMetrics(1, 1), nil },
+			scraperhelper.WithConfig(&scraperhelper.ScraperSettings{CollectionIntervalVal: 30 * time.Second}))),
+		scraperhelper.WithClock(clock),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, receiver.Shutdown(context.Background())) }()
+
+	scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+	assert.Equal(t, []scraperhelper.ScheduledScrape{
+		scheduled("slow", start.Add(10*time.Second), scraperhelper.ScheduleInterval),
+	}, receiver.(scraperhelper.ScraperInspector).NextScrapes())
+
+	clock.Advance(10 * time.Second)
+	scrapertest.WaitForBatches(t, next, 1, time.Second)
+	assert.Equal(t, []scraperhelper.ScheduledScrape{
+		scheduled("slow", start.Add(40*time.Second), scraperhelper.ScheduleInterval),
+	}, receiver.(scraperhelper.ScraperInspector).NextScrapes())
+}
+
+func TestNextScrapesTickerChannel(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	next := new(scrapertest.SinkConsumer)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = 10 * time.Second
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), next,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("slow",
+			func(context.Context) (pdata.MetricSlice, error) { return scrapertest.GenerateMetrics(1, 1), nil },
+			scraperhelper.WithConfig(&scraperhelper.ScraperSettings{CollectionIntervalVal: 30 * time.Second}))),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, receiver.Shutdown(context.Background())) }()
+
+	// the deadlines of the ticks are not known, so the earliest time of a
+	// tick the scraper is scraped on is returned.
+	assert.Equal(t, []scraperhelper.ScheduledScrape{
+		scheduled("slow", time.Time{}, scraperhelper.ScheduleInterval),
+	}, receiver.(scraperhelper.ScraperInspector).NextScrapes())
+
+	tick := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tickerCh <- tick
+	scrapertest.WaitForBatches(t, next, 1, time.Second)
+	assert.Equal(t, []scraperhelper.ScheduledScrape{
+		scheduled("slow", tick.Add(25*time.Second), scraperhelper.ScheduleInterval),
+	}, receiver.(scraperhelper.ScraperInspector).NextScrapes())
+}
+
+func TestNextScrapesSharedScheduler(t *testing.T) {
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = time.Hour
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper",
+			func(context.Context) (pdata.MetricSlice, error) { return scrapertest.GenerateMetrics(1, 1), nil },
+			scraperhelper.WithInitialDelay(90*time.Minute))),
+		scraperhelper.WithSharedScheduler(),
+	)
+	require.NoError(t, err)
+	started := time.Now()
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, receiver.Shutdown(context.Background())) }()
+
+	// the shared scheduler waits for the deadline of the scraper, once its
+	// initial delay elapsed.
+	scrapes := receiver.(scraperhelper.ScraperInspector).NextScrapes()
+	require.Len(t, scrapes, 1)
+	assert.Equal(t, scraperhelper.ScheduleInitialDelay, scrapes[0].Reason)
+	assert.WithinDuration(t, started.Add(90*time.Minute), scrapes[0].Time, time.Second)
+}
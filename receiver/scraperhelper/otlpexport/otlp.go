@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlpexport
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configgrpc"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/exporter/otlpexporter"
+)
+
+// Settings configures the exporter created with NewMetricsExporter.
+type Settings struct {
+	configgrpc.GRPCClientSettings
+	exporterhelper.TimeoutSettings
+	exporterhelper.RetrySettings
+}
+
+// DefaultSettings returns default settings for an exporter that sends
+// metrics to the given OTLP gRPC endpoint.
+func DefaultSettings(endpoint string) Settings {
+	return Settings{
+		GRPCClientSettings: configgrpc.GRPCClientSettings{
+			Endpoint: endpoint,
+			Headers:  map[string]string{},
+		},
+		TimeoutSettings: exporterhelper.DefaultTimeoutSettings(),
+		RetrySettings:   exporterhelper.DefaultRetrySettings(),
+	}
+}
+
+// NewMetricsExporter creates an exporter that sends metrics directly to an
+// OTLP gRPC endpoint, to be used as the next consumer of a receiver created
+// with scraperhelper.NewScraperControllerReceiver when the metrics do not
+// need to go through a pipeline, for instance in a standalone agent.
+//
+// The metrics are sent synchronously: ConsumeMetrics returns once the
+// metrics were sent, or once retrying transient gRPC errors failed. The
+// exporter must be started before the receiver, and shutdown after the
+// receiver, which closes the connection to the endpoint.
+func NewMetricsExporter(ctx context.Context, logger *zap.Logger, settings Settings) (component.MetricsExporter, error) {
+	factory := otlpexporter.NewFactory()
+	cfg := factory.CreateDefaultConfig().(*otlpexporter.Config)
+	cfg.GRPCClientSettings = settings.GRPCClientSettings
+	cfg.TimeoutSettings = settings.TimeoutSettings
+	cfg.RetrySettings = settings.RetrySettings
+	cfg.QueueSettings.Enabled = false
+	return factory.CreateMetricsExporter(ctx, component.ExporterCreateParams{Logger: logger}, cfg)
+}
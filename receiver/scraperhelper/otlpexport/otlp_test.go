@@ -0,0 +1,137 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlpexport
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/otlpreceiver"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/testutil"
+)
+
+// newOTLPReceiver creates an OTLP gRPC receiver listening on endpoint, that
+// passes the received metrics to the sink.
+func newOTLPReceiver(t *testing.T, endpoint string, sink *consumertest.MetricsSink) component.MetricsReceiver {
+	factory := otlpreceiver.NewFactory()
+	cfg := factory.CreateDefaultConfig().(*otlpreceiver.Config)
+	cfg.SetName(t.Name())
+	cfg.GRPC.NetAddr.Endpoint = endpoint
+	cfg.HTTP = nil
+	receiver, err := factory.CreateMetricsReceiver(context.Background(), component.ReceiverCreateParams{Logger: zap.NewNop()}, cfg, sink)
+	require.NoError(t, err)
+	return receiver
+}
+
+func gaugesWithDataPoints(counts ...int) pdata.MetricSlice {
+	metrics := pdata.NewMetricSlice()
+	metrics.Resize(len(counts))
+	for i, count := range counts {
+		metrics.At(i).SetName(fmt.Sprintf("metric%d", i))
+		metrics.At(i).SetDataType(pdata.MetricDataTypeIntGauge)
+		metrics.At(i).IntGauge().DataPoints().Resize(count)
+	}
+	return metrics
+}
+
+func testSettings(endpoint string) Settings {
+	settings := DefaultSettings(endpoint)
+	settings.TLSSetting.Insecure = true
+	settings.InitialInterval = 10 * time.Millisecond
+	settings.MaxInterval = 10 * time.Millisecond
+	settings.MaxElapsedTime = 5 * time.Second
+	return settings
+}
+
+func TestOTLPMetricsExporter(t *testing.T) {
+	endpoint := testutil.GetAvailableLocalAddress(t)
+	sink := new(consumertest.MetricsSink)
+	otlp := newOTLPReceiver(t, endpoint, sink)
+	require.NoError(t, otlp.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { assert.NoError(t, otlp.Shutdown(context.Background())) }()
+
+	exporter, err := NewMetricsExporter(context.Background(), zap.NewNop(), testSettings(endpoint))
+	require.NoError(t, err)
+	require.NoError(t, exporter.Start(context.Background(), componenttest.NewNopHost()))
+
+	tickerCh := make(chan time.Time)
+	defaultCfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		exporter,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", func(context.Context) (pdata.MetricSlice, error) {
+			return gaugesWithDataPoints(2, 1), nil
+		})),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	tickerCh <- time.Now()
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	require.NoError(t, exporter.Shutdown(context.Background()))
+
+	require.Len(t, sink.AllMetrics(), 2)
+	for _, md := range sink.AllMetrics() {
+		metricCount, dataPointCount := md.MetricAndDataPointCount()
+		assert.Equal(t, 2, metricCount)
+		assert.Equal(t, 3, dataPointCount)
+	}
+}
+
+func TestOTLPMetricsExporterRetry(t *testing.T) {
+	endpoint := testutil.GetAvailableLocalAddress(t)
+	exporter, err := NewMetricsExporter(context.Background(), zap.NewNop(), testSettings(endpoint))
+	require.NoError(t, err)
+	require.NoError(t, exporter.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { assert.NoError(t, exporter.Shutdown(context.Background())) }()
+
+	// the endpoint is unavailable until the OTLP receiver is started, which
+	// is retried.
+	sink := new(consumertest.MetricsSink)
+	otlp := newOTLPReceiver(t, endpoint, sink)
+	consumed := make(chan error, 1)
+	go func() {
+		md := pdata.NewMetrics()
+		md.ResourceMetrics().Resize(1)
+		md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().Resize(1)
+		gaugesWithDataPoints(1).MoveAndAppendTo(md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics())
+		consumed <- exporter.ConsumeMetrics(context.Background(), md)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, otlp.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { assert.NoError(t, otlp.Shutdown(context.Background())) }()
+
+	select {
+	case err := <-consumed:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "metrics were not sent")
+	}
+	assert.Equal(t, 1, sink.MetricsCount())
+}
@@ -0,0 +1,186 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// WithOwnershipChecks checks that the scrapers created by this package do not
+// modify the metrics they returned once the receiver passed them to the next
+// consumer, which they no longer own. It is meant to be used in tests, as it
+// computes a checksum of the scraped metrics on every tick.
+//
+// The checksum of the metrics returned by a scraper is computed once the next
+// consumer consumed them, and compared with their current checksum when the
+// scraper is scraped again, and when the receiver is shut down. A mismatch is
+// logged as an error with the name of the scraper. The next consumer must not
+// modify the metrics after ConsumeMetrics returns either.
+//
+// The checks are disabled with WithAsyncConsume, as the metrics may still be
+// consumed when the scrapers are scraped again.
+func WithOwnershipChecks() ScraperControllerOption {
+	return func(o *controller) {
+		o.ownershipChecks = true
+	}
+}
+
+// ownershipChecksEnabled reports whether ownership checks are enabled, and
+// are possible.
+func (sc *controller) ownershipChecksEnabled() bool {
+	if !sc.ownershipChecks {
+		return false
+	}
+	if sc.asyncConsume {
+		sc.logger.Info("Ownership checks disabled", zap.String("reason", "metrics are consumed asynchronously"))
+		return false
+	}
+	return true
+}
+
+// ownershipCheck tracks the metrics last returned by a scraper, to detect
+// modifications made to them by the scraper after they were consumed.
+type ownershipCheck struct {
+	mu sync.Mutex
+	// returned shares the metrics last returned by the scraper, without
+	// copying them.
+	returned pdata.Metrics
+	// sealed is set once the metrics were consumed, with their checksum at
+	// that time.
+	sealed   bool
+	checksum uint64
+	// violations counts the modifications detected since they were last
+	// reported.
+	violations int
+}
+
+// returnedMetrics verifies the metrics previously returned by the scraper,
+// and tracks the metrics it just returned instead.
+func (c *ownershipCheck) returnedMetrics(metrics pdata.MetricSlice) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.verify()
+	c.returned = pdata.NewMetrics()
+	rms := c.returned.ResourceMetrics()
+	rms.Resize(1)
+	rms.At(0).InstrumentationLibraryMetrics().Resize(1)
+	dest := rms.At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		dest.Append(metrics.At(i))
+	}
+}
+
+// returnedResourceMetrics verifies the metrics previously returned by the
+// scraper, and tracks the resource metrics it just returned instead.
+func (c *ownershipCheck) returnedResourceMetrics(resourceMetrics pdata.ResourceMetricsSlice) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.verify()
+	c.returned = pdata.NewMetrics()
+	for i := 0; i < resourceMetrics.Len(); i++ {
+		c.returned.ResourceMetrics().Append(resourceMetrics.At(i))
+	}
+}
+
+// seal computes the checksum of the metrics last returned by the scraper,
+// once they were consumed.
+func (c *ownershipCheck) seal() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sealed || c.returned == (pdata.Metrics{}) {
+		return
+	}
+	c.sealed, c.checksum = true, metricsChecksum(c.returned)
+}
+
+// verify counts a violation if the metrics last returned by the scraper were
+// modified since they were sealed, and stops tracking them. It must be
+// called with mu held.
+func (c *ownershipCheck) verify() {
+	if c.sealed && metricsChecksum(c.returned) != c.checksum {
+		c.violations++
+	}
+	c.returned, c.sealed = pdata.Metrics{}, false
+}
+
+// takeViolations returns the violations detected since the last call, after
+// verifying the metrics that are still tracked if final is set.
+func (c *ownershipCheck) takeViolations(final bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if final {
+		c.verify()
+	}
+	violations := c.violations
+	c.violations = 0
+	return violations
+}
+
+// metricsChecksum returns a checksum of the serialized metrics.
+func metricsChecksum(metrics pdata.Metrics) uint64 {
+	h := fnv.New64a()
+	for _, rm := range pdata.MetricsToOtlp(metrics) {
+		// the metrics are generated types that can always be marshaled.
+		b, _ := rm.Marshal()
+		h.Write(b)
+	}
+	return h.Sum64()
+}
+
+// ownership returns the ownership check of the scraper, if it was created by
+// this package and the receiver enables ownership checks.
+func ownership(scraper BaseScraper) *ownershipCheck {
+	s, ok := scraper.(interface{ ownership() *ownershipCheck })
+	if !ok {
+		return nil
+	}
+	return s.ownership()
+}
+
+// sealOwnershipChecks seals the metrics returned by the scrapers once they
+// have been consumed.
+func (sc *controller) sealOwnershipChecks() {
+	sc.scrapersMu.RLock()
+	defer sc.scrapersMu.RUnlock()
+	for _, scraper := range sc.scrapers {
+		if c := ownership(scraper); c != nil {
+			c.seal()
+		}
+	}
+}
+
+// reportOwnershipViolations logs the scrapers that modified metrics they
+// returned after they were consumed. If final is set, the metrics that are
+// still tracked are verified first.
+func (sc *controller) reportOwnershipViolations(final bool) {
+	sc.scrapersMu.RLock()
+	defer sc.scrapersMu.RUnlock()
+	for _, scraper := range sc.scrapers {
+		c := ownership(scraper)
+		if c == nil {
+			continue
+		}
+		if violations := c.takeViolations(final); violations > 0 {
+			sc.logger.Error("Scraper modified metrics it returned after they were consumed",
+				zap.String("scraper", scraper.Name()),
+				zap.Int("violations", violations))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+func TestOwnershipChecks(t *testing.T) {
+	// misbehaving retains the metric it returned, and modifies it on the
+	// next scrape, while the other scrapers only return new metrics.
+	var retained pdata.Metric
+	misbehaving := func(context.Context) (pdata.MetricSlice, error) {
+		if retained != (pdata.Metric{}) {
+			retained.IntGauge().DataPoints().At(0).SetValue(42)
+		}
+		metrics := gaugesWithDataPoints(1)
+		retained = metrics.At(0)
+		return metrics, nil
+	}
+	wellBehaved := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	reusingBuffer := func(_ context.Context, dest pdata.MetricSlice) error {
+		singleMetric().MoveAndAppendTo(dest)
+		return nil
+	}
+
+	core, logs := observer.New(zap.ErrorLevel)
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.New(core), sink,
+		AddMetricsScraper(NewMetricsScraper("misbehaving", misbehaving)),
+		AddMetricsScraper(NewMetricsScraper("well_behaved", wellBehaved)),
+		AddMetricsScraper(NewMetricsScraperInto("reusing_buffer", reusingBuffer)),
+		AddResourceMetricsScraper(NewResourceMetricsScraper("resource", func(context.Context) (pdata.ResourceMetricsSlice, error) {
+			return singleResourceMetric(), nil
+		})),
+		WithOwnershipChecks(),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	for tick := 1; tick <= 3; tick++ {
+		tickerCh <- time.Now()
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) == tick }, time.Second, time.Millisecond)
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	// the modifications made on the second and third scrapes are reported
+	// on the next tick, or when the receiver is shut down.
+	violations := logs.FilterMessage("Scraper modified metrics it returned after they were consumed").All()
+	require.Len(t, violations, 2)
+	for _, violation := range violations {
+		assert.Equal(t, "misbehaving", violation.ContextMap()["scraper"])
+		assert.EqualValues(t, 1, violation.ContextMap()["violations"])
+	}
+	assert.EqualValues(t, 42, sink.AllMetrics()[0].ResourceMetrics().At(1).InstrumentationLibraryMetrics().At(0).Metrics().At(0).IntGauge().DataPoints().At(0).Value())
+}
+
+func TestOwnershipChecksOnShutdown(t *testing.T) {
+	// the resource metrics are modified once consumed, as a scraper
+	// modifying them in the background would.
+	var retained pdata.ResourceMetrics
+	scrape := func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		rms := singleResourceMetric()
+		retained = rms.At(0)
+		return rms, nil
+	}
+
+	core, logs := observer.New(zap.ErrorLevel)
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.New(core), sink,
+		AddResourceMetricsScraper(NewResourceMetricsScraper("resource", scrape)),
+		WithOwnershipChecks(),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	// the checksum is computed on the scrape loop once the metrics were
+	// consumed.
+	require.Eventually(t, func() bool {
+		c := ownership(receiver.(*controller).resourceMetricScrapers[0])
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.sealed
+	}, time.Second, time.Millisecond)
+	retained.Resource().Attributes().InsertString("host.name", "modified")
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	violations := logs.FilterMessage("Scraper modified metrics it returned after they were consumed").All()
+	require.Len(t, violations, 1)
+	assert.Equal(t, "resource", violations[0].ContextMap()["scraper"])
+}
+
+func TestOwnershipChecksDisabled(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	newReceiver := func(options ...ScraperControllerOption) (*controller, *observer.ObservedLogs) {
+		core, logs := observer.New(zap.InfoLevel)
+		defaultCfg := DefaultScraperControllerSettings("receiver")
+		receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.New(core), new(consumertest.MetricsSink),
+			append(options, AddMetricsScraper(NewMetricsScraper("scraper", scrape)))...)
+		require.NoError(t, err)
+		require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+		require.NoError(t, receiver.Shutdown(context.Background()))
+		return receiver.(*controller), logs
+	}
+
+	receiver, _ := newReceiver()
+	assert.False(t, receiver.ownershipChecks)
+	assert.Nil(t, ownership(receiver.metricsScrapers.scrapers[0]))
+
+	receiver, logs := newReceiver(WithOwnershipChecks(), WithAsyncConsume(10, 1))
+	assert.False(t, receiver.ownershipChecks)
+	assert.Nil(t, ownership(receiver.metricsScrapers.scrapers[0]))
+	disabled := logs.FilterMessage("Ownership checks disabled").All()
+	require.Len(t, disabled, 1)
+	assert.Equal(t, "metrics are consumed asynchronously", disabled[0].ContextMap()["reason"])
+}
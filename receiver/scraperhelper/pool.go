@@ -0,0 +1,146 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// ScrapeMetricsInto appends the scraped metrics to dest, which is empty.
+type ScrapeMetricsInto func(ctx context.Context, dest pdata.MetricSlice) error
+
+// ScrapeResourceMetricsInto appends the scraped resource metrics to dest,
+// which is empty.
+type ScrapeResourceMetricsInto func(ctx context.Context, dest pdata.ResourceMetricsSlice) error
+
+// NewMetricsScraperInto creates a MetricsScraper like NewMetricsScraper, that
+// scrapes into a slice reused across scrapes, instead of allocating a new
+// slice on every scrape. The slice returned by Scrape is only valid until the
+// next scrape, and the scraper must not be scraped concurrently, which
+// receivers never do. Receivers copy the scraped metrics out of the slice.
+func NewMetricsScraperInto(name string, scrape ScrapeMetricsInto, options ...ScraperOption) MetricsScraper {
+	ms := &metricsScraper{
+		baseScraper: newBaseScraper(name, newScraperSettings(options)),
+		buffered:    true,
+	}
+	if scrape != nil {
+		buffer := pdata.NewMetricSlice()
+		ms.ScrapeMetrics = func(ctx context.Context) (pdata.MetricSlice, error) {
+			buffer.Resize(0)
+			return buffer, scrape(ctx, buffer)
+		}
+	}
+	return ms
+}
+
+// NewResourceMetricsScraperInto creates a ResourceMetricsScraper like
+// NewResourceMetricsScraper, that scrapes into a slice reused across scrapes,
+// instead of allocating a new slice on every scrape. The slice returned by
+// Scrape is only valid until the next scrape, and the scraper must not be
+// scraped concurrently, which receivers never do. Receivers copy the scraped
+// resource metrics out of the slice.
+func NewResourceMetricsScraperInto(name string, scrape ScrapeResourceMetricsInto, options ...ScraperOption) ResourceMetricsScraper {
+	rms := &resourceMetricsScraper{
+		baseScraper: newBaseScraper(name, newScraperSettings(options)),
+		buffered:    true,
+	}
+	if scrape != nil {
+		buffer := pdata.NewResourceMetricsSlice()
+		rms.ScrapeResourceMetrics = func(ctx context.Context) (pdata.ResourceMetricsSlice, error) {
+			buffer.Resize(0)
+			return buffer, scrape(ctx, buffer)
+		}
+	}
+	return rms
+}
+
+// reusesBuffer reports whether the scraper scrapes into a slice reused
+// across scrapes, which must not be moved by the receiver.
+func reusesBuffer(scraper BaseScraper) bool {
+	s, ok := scraper.(interface{ reusesBuffer() bool })
+	return ok && s.reusesBuffer()
+}
+
+// WithMetricsPool reuses the pdata.Metrics passed to the next consumer across
+// scrapes, returning them to a pool once they have been consumed, instead of
+// allocating new ones on every scrape. Scrapers created with
+// NewMetricsScraperInto and NewResourceMetricsScraperInto additionally reuse
+// the slices they scrape into.
+//
+// Pooling is only enabled if the next consumer declares, with a
+// GetCapabilities method, that it does not mutate the consumed data, in which
+// case it must not retain the metrics after ConsumeMetrics returns either. It
+// is also disabled if WithMetricsTransformer is used, as the metrics may then
+// still be referenced once they have been consumed.
+func WithMetricsPool() ScraperControllerOption {
+	return func(o *controller) {
+		o.metricsPool = true
+	}
+}
+
+// newMetricsPool returns the pool of the metrics passed to the next consumer,
+// or nil if pooling is not enabled, or is not possible.
+func (sc *controller) newMetricsPool() *sync.Pool {
+	if !sc.metricsPool {
+		return nil
+	}
+
+	reason := ""
+	switch {
+	case consumerMutatesData(sc.nextConsumer):
+		reason = "the next consumer does not declare that it does not mutate the consumed data"
+	case len(sc.transformers) > 0:
+		reason = "metrics transformers are used"
+	}
+	if reason != "" {
+		sc.logger.Info("Metrics pooling disabled", zap.String("reason", reason))
+		return nil
+	}
+	return &sync.Pool{New: func() interface{} { return pdata.NewMetrics() }}
+}
+
+// consumerMutatesData reports whether the consumer may mutate the consumed
+// data, which is the case unless it declares otherwise.
+func consumerMutatesData(nextConsumer consumer.MetricsConsumer) bool {
+	c, ok := nextConsumer.(interface {
+		GetCapabilities() component.ProcessorCapabilities
+	})
+	return !ok || c.GetCapabilities().MutatesConsumedData
+}
+
+// newMetrics returns empty metrics from the pool, if any.
+func (sc *controller) newMetrics() pdata.Metrics {
+	if sc.pool == nil {
+		return pdata.NewMetrics()
+	}
+	return sc.pool.Get().(pdata.Metrics)
+}
+
+// releaseMetrics clears the metrics once they have been consumed, or
+// dropped, and returns them to the pool, if any.
+func (sc *controller) releaseMetrics(metrics pdata.Metrics) {
+	if sc.pool == nil {
+		return
+	}
+	metrics.ResourceMetrics().Resize(0)
+	sc.pool.Put(metrics)
+}
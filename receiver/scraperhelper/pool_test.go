@@ -0,0 +1,298 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// namesConsumer records the names of the metrics it consumes, without
+// retaining the metrics, and declares that it does not mutate them.
+type namesConsumer struct {
+	mu       sync.Mutex
+	consumed [][]string
+	signal   chan struct{}
+}
+
+func newNamesConsumer() *namesConsumer {
+	return &namesConsumer{signal: make(chan struct{}, 10)}
+}
+
+func (c *namesConsumer) GetCapabilities() component.ProcessorCapabilities {
+	return component.ProcessorCapabilities{MutatesConsumedData: false}
+}
+
+func (c *namesConsumer) ConsumeMetrics(_ context.Context, md pdata.Metrics) error {
+	var names []string
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			metrics := ilms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				names = append(names, metrics.At(k).Name())
+			}
+		}
+	}
+	c.mu.Lock()
+	c.consumed = append(c.consumed, names)
+	c.mu.Unlock()
+	c.signal <- struct{}{}
+	return nil
+}
+
+func (c *namesConsumer) allConsumed() [][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.consumed
+}
+
+// scrapeNamedMetrics appends the given number of metrics named after the
+// scrape and their index.
+func scrapeNamedMetrics(dest pdata.MetricSlice, scrape, count int) {
+	for i := 0; i < count; i++ {
+		metric := pdata.NewMetric()
+		metric.SetName(fmt.Sprintf("scrape%d-%d", scrape, i))
+		dest.Append(metric)
+	}
+}
+
+func TestMetricsPoolDoesNotLeakPreviousScrape(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		t.Run(fmt.Sprintf("async=%v", async), func(t *testing.T) {
+			// the first scrape has more metrics than the second one, which
+			// must not contain any of them.
+			counts := []int{3, 1}
+			var scrape int
+			scrapeMetrics := func(_ context.Context, dest pdata.MetricSlice) error {
+				require.Equal(t, 0, dest.Len())
+				scrapeNamedMetrics(dest, scrape, counts[scrape])
+				return nil
+			}
+			scrapeResourceMetrics := func(_ context.Context, dest pdata.ResourceMetricsSlice) error {
+				require.Equal(t, 0, dest.Len())
+				for i := 0; i < counts[scrape]; i++ {
+					rms := pdata.NewResourceMetricsSlice()
+					rms.Resize(1)
+					rms.At(0).InstrumentationLibraryMetrics().Resize(1)
+					metric := pdata.NewMetric()
+					metric.SetName(fmt.Sprintf("resource%d-%d", scrape, i))
+					rms.At(0).InstrumentationLibraryMetrics().At(0).Metrics().Append(metric)
+					dest.Append(rms.At(0))
+				}
+				return nil
+			}
+
+			tickerCh := make(chan time.Time)
+			next := newNamesConsumer()
+			options := []ScraperControllerOption{
+				AddMetricsScraper(NewMetricsScraperInto("metrics", scrapeMetrics)),
+				AddResourceMetricsScraper(NewResourceMetricsScraperInto("resource", scrapeResourceMetrics)),
+				WithTickerChannel(tickerCh),
+				WithMetricsPool(),
+			}
+			consumes := 1
+			if async {
+				options = append(options, WithAsyncConsume(10, 1))
+				consumes = 2
+			}
+			defaultCfg := DefaultScraperControllerSettings("receiver")
+			receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), next, options...)
+			require.NoError(t, err)
+			require.NotNil(t, receiver.(*controller).pool)
+			require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+			for scrape = 0; scrape < len(counts); scrape++ {
+				tickerCh <- time.Now()
+				for i := 0; i < consumes; i++ {
+					<-next.signal
+				}
+			}
+			require.NoError(t, receiver.Shutdown(context.Background()))
+
+			var consumed []string
+			for _, names := range next.allConsumed()[consumes:] {
+				consumed = append(consumed, names...)
+			}
+			assert.ElementsMatch(t, []string{"resource1-0", "scrape1-0"}, consumed)
+		})
+	}
+}
+
+// renamingConsumer records the names of the metrics it consumes, then
+// renames them and appends a resource to the consumed metrics.
+type renamingConsumer struct {
+	namesConsumer
+}
+
+func (c *renamingConsumer) GetCapabilities() component.ProcessorCapabilities {
+	return component.ProcessorCapabilities{MutatesConsumedData: true}
+}
+
+func (c *renamingConsumer) ConsumeMetrics(ctx context.Context, md pdata.Metrics) error {
+	err := c.namesConsumer.ConsumeMetrics(ctx, md)
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			metrics := ilms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metrics.At(k).SetName("mutated")
+			}
+		}
+	}
+	rms.Resize(rms.Len() + 1)
+	rms.At(rms.Len() - 1).InstrumentationLibraryMetrics().Resize(1)
+	metric := pdata.NewMetric()
+	metric.SetName("appended")
+	rms.At(rms.Len() - 1).InstrumentationLibraryMetrics().At(0).Metrics().Append(metric)
+	return err
+}
+
+func TestMetricsPoolMutatingConsumer(t *testing.T) {
+	var scrape int
+	scrapeMetrics := func(_ context.Context, dest pdata.MetricSlice) error {
+		require.Equal(t, 0, dest.Len())
+		scrapeNamedMetrics(dest, scrape, 2)
+		return nil
+	}
+
+	tickerCh := make(chan time.Time)
+	next := &renamingConsumer{namesConsumer: *newNamesConsumer()}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), next,
+		AddMetricsScraper(NewMetricsScraperInto("metrics", scrapeMetrics)),
+		WithTickerChannel(tickerCh),
+		WithMetricsPool(),
+	)
+	require.NoError(t, err)
+	require.Nil(t, receiver.(*controller).pool)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	for scrape = 0; scrape < 2; scrape++ {
+		tickerCh <- time.Now()
+		<-next.signal
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	// neither the renamed metrics nor the appended resource of the first
+	// scrape are consumed with the second one.
+	assert.Equal(t, [][]string{
+		{"scrape0-0", "scrape0-1"},
+		{"scrape1-0", "scrape1-1"},
+	}, next.allConsumed())
+}
+
+func TestMetricsPoolDisabled(t *testing.T) {
+	noop := func(_ context.Context, md pdata.Metrics) (pdata.Metrics, error) { return md, nil }
+	tests := []struct {
+		name     string
+		consumer interface {
+			ConsumeMetrics(context.Context, pdata.Metrics) error
+		}
+		options []ScraperControllerOption
+		reason  string
+	}{
+		{
+			name:     "consumer without capabilities",
+			consumer: new(consumertest.MetricsSink),
+			reason:   "the next consumer does not declare that it does not mutate the consumed data",
+		},
+		{
+			name:     "transformer",
+			consumer: newNamesConsumer(),
+			options:  []ScraperControllerOption{WithMetricsTransformer(noop)},
+			reason:   "metrics transformers are used",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.InfoLevel)
+			scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+			options := append([]ScraperControllerOption{
+				AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+				WithMetricsPool(),
+			}, tt.options...)
+			defaultCfg := DefaultScraperControllerSettings("receiver")
+			receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.New(core), tt.consumer, options...)
+			require.NoError(t, err)
+			assert.Nil(t, receiver.(*controller).pool)
+
+			disabled := logs.FilterMessage("Metrics pooling disabled").All()
+			require.Len(t, disabled, 1)
+			assert.Equal(t, tt.reason, disabled[0].ContextMap()["reason"])
+		})
+	}
+}
+
+func BenchmarkMetricsPool(b *testing.B) {
+	// the scraped resources are created once, so that the benchmark measures
+	// the allocations of the slices and payloads holding them.
+	resources := pdata.NewResourceMetricsSlice()
+	resources.Resize(100)
+	unpooled := NewResourceMetricsScraper("scraper", func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		rms := pdata.NewResourceMetricsSlice()
+		for i := 0; i < resources.Len(); i++ {
+			rms.Append(resources.At(i))
+		}
+		return rms, nil
+	})
+	pooled := NewResourceMetricsScraperInto("scraper", func(_ context.Context, dest pdata.ResourceMetricsSlice) error {
+		for i := 0; i < resources.Len(); i++ {
+			dest.Append(resources.At(i))
+		}
+		return nil
+	})
+
+	run := func(b *testing.B, options ...ScraperControllerOption) {
+		tickerCh := make(chan time.Time)
+		next := newNamesConsumer()
+		defaultCfg := DefaultScraperControllerSettings("receiver")
+		receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), next,
+			append(options, WithTickerChannel(tickerCh))...)
+		require.NoError(b, err)
+		require.NoError(b, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			tickerCh <- time.Now()
+			<-next.signal
+		}
+		b.StopTimer()
+		require.NoError(b, receiver.Shutdown(context.Background()))
+	}
+
+	b.Run("unpooled", func(b *testing.B) {
+		run(b, AddResourceMetricsScraper(unpooled))
+	})
+	b.Run("pooled", func(b *testing.B) {
+		run(b, AddResourceMetricsScraper(pooled), WithMetricsPool())
+	})
+}
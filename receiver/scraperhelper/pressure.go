@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/collector/obsreport"
+)
+
+// MemoryPressureCheck reports whether the collector is under memory pressure,
+// for instance because the memory_limiter processor is refusing data.
+type MemoryPressureCheck func() bool
+
+// WithMemoryPressureCheck skips the ticks on which the check reports memory
+// pressure, as the metrics scraped would only be refused downstream. The
+// skipped ticks are counted in the scraper/memory_pressure_skipped_ticks
+// metric, and scraping resumes on the first tick the check no longer reports
+// memory pressure.
+//
+// The check is called before scraping on every tick, from the goroutine
+// scraping the receiver, so it must return quickly, without blocking on the
+// downstream components; reading a flag they maintain is enough.
+func WithMemoryPressureCheck(check MemoryPressureCheck) ScraperControllerOption {
+	return func(o *controller) {
+		if check == nil {
+			o.optionErrs = append(o.optionErrs, errors.New("memory pressure check must not be nil"))
+			return
+		}
+		o.memoryPressureCheck = check
+	}
+}
+
+// skipForMemoryPressure reports whether the tick must be skipped because of
+// memory pressure, logging when memory pressure starts and ends. It must only
+// be called from the scrape loop.
+func (sc *controller) skipForMemoryPressure(ctx context.Context) bool {
+	if sc.memoryPressureCheck == nil {
+		return false
+	}
+	pressure := sc.memoryPressureCheck()
+	if pressure != sc.underMemoryPressure {
+		sc.underMemoryPressure = pressure
+		if pressure {
+			sc.logger.Warn("Skipping scrapes while under memory pressure")
+		} else {
+			sc.logger.Info("Resuming scrapes after memory pressure cleared")
+		}
+	}
+	if pressure {
+		obsreport.RecordMetricsScrapeMemoryPressureSkippedTicks(ctx)
+	}
+	return pressure
+}
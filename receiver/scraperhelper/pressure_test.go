@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport/obsreporttest"
+)
+
+func TestMemoryPressureCheck(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	var scrapes atomic.Int64
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		scrapes.Inc()
+		return singleMetric(), nil
+	}
+	// the pressure reported on each tick, which is only checked from the
+	// scrape loop.
+	ticks := []bool{false, true, true, true, false, false, true, false}
+	var checks int
+	check := func() bool {
+		checks++
+		return ticks[checks-1]
+	}
+
+	tickerCh := make(chan time.Time)
+	core, logs := observer.New(zap.InfoLevel)
+	cfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&cfg, zap.New(core), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithMemoryPressureCheck(check),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	for range ticks {
+		tickerCh <- time.Now()
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	assert.EqualValues(t, 4, scrapes.Load())
+	obsreporttest.CheckScraperMemoryPressureSkippedTicksView(t, "receiver", 4)
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{
+		"Skipping scrapes while under memory pressure",
+		"Resuming scrapes after memory pressure cleared",
+		"Skipping scrapes while under memory pressure",
+		"Resuming scrapes after memory pressure cleared",
+	}, messages)
+}
+
+func TestMemoryPressureCheckNil(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	cfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithMemoryPressureCheck(nil),
+	)
+	assert.EqualError(t, err, "memory pressure check must not be nil")
+}
@@ -0,0 +1,53 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"runtime/pprof"
+)
+
+// WithProfilingLabels calls the scrape functions of the scrapers created by
+// this package with pprof labels set to the name of the receiver, with the
+// "receiver" key, and the name of the scraper, with the "scraper" key, so that
+// the samples of CPU profiles taken while scraping can be attributed to each
+// scraper, for instance with the -tagfocus option of go tool pprof. Labels
+// have a small cost on every scrape, which is why they are not set by
+// default. Goroutines started by the scrape functions inherit the labels.
+func WithProfilingLabels() ScraperControllerOption {
+	return func(o *controller) {
+		o.profilingLabels = true
+	}
+}
+
+// setProfilingLabels creates the pprof labels of the scraper if the receiver
+// about to start it sets them. It must be called with mu held.
+func (b *baseScraper) setProfilingLabels(rs receiverSettings) {
+	b.profilingLabels = nil
+	if rs.profilingLabels {
+		labels := pprof.Labels("receiver", rs.startInfo.ReceiverName, "scraper", b.name)
+		b.profilingLabels = &labels
+	}
+}
+
+// scrapeLabels returns the pprof labels the scrape function is called with,
+// if any.
+func (b *baseScraper) scrapeLabels() (pprof.LabelSet, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.profilingLabels == nil {
+		return pprof.LabelSet{}, false
+	}
+	return *b.profilingLabels, true
+}
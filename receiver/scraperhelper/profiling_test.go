@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// scrapeProfilingLabels scrapes the scrapers of a receiver once, with the
+// given options, and returns the pprof labels each scraper observed.
+func scrapeProfilingLabels(t *testing.T, options ...ScraperControllerOption) map[string]map[string]string {
+	observed := map[string]map[string]string{}
+	observe := func(name string, ctx context.Context) {
+		labels := map[string]string{}
+		pprof.ForLabels(ctx, func(key, value string) bool {
+			labels[key] = value
+			return true
+		})
+		observed[name] = labels
+	}
+	busy := func(ctx context.Context) (pdata.MetricSlice, error) {
+		// a busy scraper, whose CPU samples are attributed to it.
+		for deadline := time.Now().Add(5 * time.Millisecond); time.Now().Before(deadline); {
+		}
+		observe("busy", ctx)
+		return singleMetric(), nil
+	}
+	resource := func(ctx context.Context) (pdata.ResourceMetricsSlice, error) {
+		observe("resource", ctx)
+		return singleResourceMetric(), nil
+	}
+	stream := func(ctx context.Context, emit EmitMetrics) error {
+		observe("stream", ctx)
+		return nil
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), sink, append([]ScraperControllerOption{
+		AddMetricsScraper(NewMetricsScraper("busy", busy)),
+		AddResourceMetricsScraper(NewResourceMetricsScraper("resource", resource)),
+		AddStreamingScraper(NewStreamingScraper("stream", stream)),
+		WithTickerChannel(tickerCh),
+	}, options...)...)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	return observed
+}
+
+func TestProfilingLabels(t *testing.T) {
+	observed := scrapeProfilingLabels(t, WithProfilingLabels())
+	assert.Equal(t, map[string]map[string]string{
+		"busy":     {"receiver": "receiver", "scraper": "busy"},
+		"resource": {"receiver": "receiver", "scraper": "resource"},
+		"stream":   {"receiver": "receiver", "scraper": "stream"},
+	}, observed)
+
+	// the labels are only set with the option.
+	observed = scrapeProfilingLabels(t)
+	assert.Equal(t, map[string]map[string]string{"busy": {}, "resource": {}, "stream": {}}, observed)
+}
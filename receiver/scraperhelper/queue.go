@@ -0,0 +1,194 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// DropPolicy specifies which metrics are dropped when the queue used by
+// WithAsyncConsume is full.
+type DropPolicy int
+
+const (
+	// DropNewest drops the metrics that could not be queued.
+	DropNewest DropPolicy = iota
+	// DropOldest drops the oldest queued metrics to make room for the new
+	// ones.
+	DropOldest
+)
+
+var errQueueFull = errors.New("consume queue is full")
+
+type queuedMetrics struct {
+	ctx     context.Context
+	metrics pdata.Metrics
+}
+
+// consumeQueue passes scraped metrics to the next consumer from a pool of
+// workers, so that scraping is not blocked by a slow consumer.
+//
+// Each worker consumes the metrics of its own lane in order, and the metrics
+// queued with the same key are always queued in the same lane, so that they
+// are consumed in the order they were queued, while metrics queued with
+// different keys can be consumed concurrently.
+type consumeQueue struct {
+	policy  DropPolicy
+	consume func(context.Context, pdata.Metrics)
+	// drop is called with the metrics that are dropped, and the reason they
+	// were dropped.
+	drop func(context.Context, pdata.Metrics, error)
+
+	lanes   []chan queuedMetrics
+	discard chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newConsumeQueue(
+	queueSize, workers int,
+	policy DropPolicy,
+	consume func(context.Context, pdata.Metrics),
+	drop func(context.Context, pdata.Metrics, error),
+) *consumeQueue {
+	q := &consumeQueue{
+		policy:  policy,
+		consume: consume,
+		drop:    drop,
+		lanes:   make([]chan queuedMetrics, workers),
+		discard: make(chan struct{}),
+	}
+	q.wg.Add(workers)
+	for i := range q.lanes {
+		q.lanes[i] = make(chan queuedMetrics, queueSize)
+		go q.work(q.lanes[i])
+	}
+	return q
+}
+
+func (q *consumeQueue) work(lane chan queuedMetrics) {
+	defer q.wg.Done()
+	for item := range lane {
+		select {
+		case <-q.discard:
+			q.drop(item.ctx, item.metrics, errors.New("consume queue discarded on shutdown"))
+		default:
+			q.consumeItem(item)
+		}
+	}
+}
+
+// consumeItem consumes the queued metrics with a context that is canceled if
+// the queue is discarded while they are consumed. The context keeps the values
+// of the context the metrics were queued with, but not its cancellation, as
+// the queue is drained after scraping stopped.
+func (q *consumeQueue) consumeItem(item queuedMetrics) {
+	ctx, cancel := context.WithCancel(detachedContext{item.ctx})
+	defer cancel()
+	go func() {
+		select {
+		case <-q.discard:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	q.consume(ctx, item.metrics)
+}
+
+// lane returns the lane in which the metrics queued with the given key are
+// queued.
+func (q *consumeQueue) lane(key string) chan queuedMetrics {
+	if len(q.lanes) == 1 {
+		return q.lanes[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return q.lanes[h.Sum32()%uint32(len(q.lanes))]
+}
+
+// enqueue queues the metrics in the lane of the given key without blocking,
+// dropping metrics of that lane according to the drop policy if it is full.
+// It must not be called concurrently, nor after stop.
+func (q *consumeQueue) enqueue(ctx context.Context, key string, metrics pdata.Metrics) {
+	lane := q.lane(key)
+	item := queuedMetrics{ctx: ctx, metrics: metrics}
+	select {
+	case lane <- item:
+		return
+	default:
+	}
+
+	if q.policy == DropOldest {
+		select {
+		case oldest := <-lane:
+			q.drop(oldest.ctx, oldest.metrics, errQueueFull)
+		default:
+		}
+		select {
+		case lane <- item:
+			return
+		default:
+		}
+	}
+	q.drop(ctx, metrics, errQueueFull)
+}
+
+// stop waits for the queued metrics to be consumed, until the context
+// expires, after which the metrics being consumed are canceled and the
+// remaining queued metrics are dropped. It returns once the workers exited.
+func (q *consumeQueue) stop(ctx context.Context) error {
+	for _, lane := range q.lanes {
+		close(lane)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		close(q.discard)
+		<-drained
+		return withKind(ErrConsumeQueueDrain, fmt.Errorf("failed to drain consume queue: %w", ctx.Err()))
+	}
+}
+
+// detachedContext is a context with the values of the context it wraps, that
+// is never canceled.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,640 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport/obsreporttest"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+func TestMaxBatchSize(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	next := new(scrapertest.SinkConsumer)
+	next.SetCallError(2, errors.New("rejected"))
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	require.NoError(t, scraperhelper.ScrapeCycle(
+		context.Background(),
+		&cfg,
+		zap.New(core),
+		next,
+		componenttest.NewNopHost(),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(3, 3))),
+		scraperhelper.WithMaxBatchSize(4),
+	))
+	batches := next.Batches()
+	require.Len(t, batches, 2)
+	require.Equal(t, 1, logs.Len())
+
+	// the batch following the rejected one is still consumed.
+	assert.Equal(t, 3, next.Calls())
+	scrapertest.AssertBatchDataPointCounts(t, batches, 3, 3)
+	scrapertest.AssertMetricNames(t, batches[0], "metric.0")
+	scrapertest.AssertMetricNames(t, batches[1], "metric.2")
+	assert.Equal(t, "failed to consume batch 2 of 3: rejected", logs.All()[0].ContextMap()["error"])
+}
+
+func TestReceiverLifecycle(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []scraperhelper.ScraperControllerOption
+	}{
+		{
+			name: "Default",
+		},
+		{
+			name:    "SharedScheduler",
+			options: []scraperhelper.ScraperControllerOption{scraperhelper.WithSharedScheduler()},
+		},
+		{
+			name:    "AsyncConsume",
+			options: []scraperhelper.ScraperControllerOption{scraperhelper.WithAsyncConsume(1, 1)},
+		},
+		{
+			name:    "MaxConcurrentScrapes",
+			options: []scraperhelper.ScraperControllerOption{scraperhelper.WithMaxConcurrentScrapes(2)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var scrapers []scrapertest.TrackedScraper
+			scrapertest.VerifyReceiverLifecycle(t, func(nextConsumer consumer.MetricsConsumer) (component.Receiver, error) {
+				cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+				cfg.CollectionInterval = 10 * time.Millisecond
+				scraper1 := scrapertest.NewTrackedScraper(scraperhelper.NewMetricsScraper("scraper1", scrapertest.NewScrapeMetrics(1, 1)))
+				scraper2 := scrapertest.NewTrackedResourceScraper(scraperhelper.NewResourceMetricsScraper("scraper2", scrapertest.NewScrapeResourceMetrics(1, 1, 1)))
+				scrapers = append(scrapers, scraper1, scraper2)
+				options := append([]scraperhelper.ScraperControllerOption{
+					scraperhelper.AddMetricsScraper(scraper1),
+					scraperhelper.AddResourceMetricsScraper(scraper2),
+				}, test.options...)
+				return scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), nextConsumer, options...)
+			})
+			scrapertest.VerifyAllClosed(t, scrapers...)
+		})
+	}
+}
+
+func TestCloseScrapersAfterPartialInitialization(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []scraperhelper.ScraperControllerOption
+	}{
+		{
+			name: "Sequential",
+		},
+		{
+			name:    "Parallel",
+			options: []scraperhelper.ScraperControllerOption{scraperhelper.WithParallelInit(1)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failStart := scraperhelper.WithStart(func(context.Context, component.Host) error { return errors.New("err1") })
+			scraper1 := scrapertest.NewTrackedScraper(scraperhelper.NewMetricsScraper("scraper1", scrapertest.NewScrapeMetrics(1, 1)))
+			scraper2 := scrapertest.NewTrackedScraper(scraperhelper.NewMetricsScraper("scraper2", scrapertest.NewScrapeMetrics(1, 1), failStart))
+			scraper3 := scrapertest.NewTrackedScraper(scraperhelper.NewMetricsScraper("scraper3", scrapertest.NewScrapeMetrics(1, 1)))
+			cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+			options := append([]scraperhelper.ScraperControllerOption{
+				scraperhelper.AddMetricsScraper(scraper1),
+				scraperhelper.AddMetricsScraper(scraper2),
+				scraperhelper.AddMetricsScraper(scraper3),
+			}, test.options...)
+			receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer), options...)
+			require.NoError(t, err)
+
+			err = receiver.Start(context.Background(), componenttest.NewNopHost())
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, scraperhelper.ErrScraperInitialization))
+			assert.Contains(t, err.Error(), `failed to initialize scraper "scraper2": err1`)
+			assert.Equal(t, 1, scraper1.Initializations())
+			assert.Equal(t, 0, scraper3.Initializations())
+
+			// the started scrapers are closed by Start, not by Shutdown.
+			scrapertest.VerifyAllClosed(t, scraper1, scraper2, scraper3)
+			assert.Equal(t, 1, scraper1.Closes())
+			require.NoError(t, receiver.Shutdown(context.Background()))
+			assert.Equal(t, 1, scraper1.Closes())
+		})
+	}
+}
+
+func TestReceiverImplementsInterfaces(t *testing.T) {
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1))),
+	)
+	require.NoError(t, err)
+
+	assert.Implements(t, (*scraperhelper.StateReporter)(nil), receiver)
+	assert.Implements(t, (*scraperhelper.ScraperManager)(nil), receiver)
+	assert.Implements(t, (*scraperhelper.ScraperInspector)(nil), receiver)
+	assert.Implements(t, (*scraperhelper.FirstScrapeWaiter)(nil), receiver)
+	assert.Implements(t, (*fmt.Stringer)(nil), receiver)
+}
+
+func TestCloseScrapersOnceOnShutdownTwice(t *testing.T) {
+	scraper := scrapertest.NewTrackedScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1)))
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraper),
+	)
+	require.NoError(t, err)
+
+	// the scrapers are closed once per start.
+	for i := 1; i <= 2; i++ {
+		require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+		require.NoError(t, receiver.Shutdown(context.Background()))
+		require.NoError(t, receiver.Shutdown(context.Background()))
+		assert.Equal(t, i, scraper.Closes())
+	}
+	scrapertest.VerifyAllClosed(t, scraper)
+}
+
+func TestReportFatalErrorWhenScrapingStopsUnexpectedly(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(
+		&cfg,
+		zap.NewNop(),
+		new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1))),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+
+	host := new(scrapertest.Host)
+	require.NoError(t, receiver.Start(context.Background(), host))
+
+	close(tickerCh)
+	errs := scrapertest.WaitForFatalErrors(t, host, 1, time.Second)
+	assert.EqualError(t, errs[0], `scraping for receiver "receiver" with scrapers ["scraper"] stopped unexpectedly: ticker channel closed`)
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Len(t, host.FatalErrors(), 1)
+}
+
+func TestReportFatalErrorWhenScrapePanics(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	scrape := func(context.Context) (pdata.ResourceMetricsSlice, error) { panic("boom") }
+	receiver, err := scraperhelper.NewScraperControllerReceiver(
+		&cfg,
+		zap.NewNop(),
+		new(scrapertest.SinkConsumer),
+		scraperhelper.AddResourceMetricsScraper(scraperhelper.NewResourceMetricsScraper("scraper", scrape)),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+
+	host := new(scrapertest.Host)
+	require.NoError(t, receiver.Start(context.Background(), host))
+
+	tickerCh <- time.Now()
+	errs := scrapertest.WaitForFatalErrors(t, host, 1, time.Second)
+	assert.EqualError(t, errs[0], `scraping for receiver "receiver" with scrapers ["scraper"] stopped unexpectedly: panic: boom`)
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+func TestNoFatalErrorOnShutdown(t *testing.T) {
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.WithAllowEmptyScrapers(), scraperhelper.WithTickerChannel(make(chan time.Time)))
+	require.NoError(t, err)
+
+	host := new(scrapertest.Host)
+	require.NoError(t, receiver.Start(context.Background(), host))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Empty(t, host.FatalErrors())
+}
+
+// authExtension is an extension scrapers look up when they are initialized.
+type authExtension struct {
+	token string
+}
+
+func (authExtension) Start(context.Context, component.Host) error { return nil }
+func (authExtension) Shutdown(context.Context) error              { return nil }
+
+func TestInitializeWithHostExtensions(t *testing.T) {
+	// the scraper authenticates its scrapes with the token of the extension
+	// named in its configuration, found when it is initialized.
+	tickerCh := make(chan time.Time)
+	newReceiver := func(next *scrapertest.SinkConsumer) component.Receiver {
+		var token string
+		start := func(_ context.Context, host component.Host) error {
+			for cfg, extension := range host.GetExtensions() {
+				if cfg.Name() == "auth/scraper" {
+					token = extension.(*authExtension).token
+					return nil
+				}
+			}
+			return errors.New(`extension "auth/scraper" not found`)
+		}
+		scrape := func(context.Context) (pdata.MetricSlice, error) {
+			metrics := scrapertest.GenerateMetrics(1, 1)
+			metrics.At(0).SetName("scraped.with." + token)
+			return metrics, nil
+		}
+
+		cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+		receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), next,
+			scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrape, scraperhelper.WithStart(start))),
+			scraperhelper.WithTickerChannel(tickerCh))
+		require.NoError(t, err)
+		return receiver
+	}
+
+	host := new(scrapertest.Host)
+	host.AddExtension("auth", &authExtension{token: "other"})
+	err := newReceiver(new(scrapertest.SinkConsumer)).Start(context.Background(), host)
+	assert.EqualError(t, err, `failed to initialize scraper "scraper": extension "auth/scraper" not found`)
+
+	host.AddExtension("auth/scraper", &authExtension{token: "token"})
+	next := new(scrapertest.SinkConsumer)
+	receiver := newReceiver(next)
+	require.NoError(t, receiver.Start(context.Background(), host))
+	tickerCh <- time.Now()
+	batches := scrapertest.WaitForBatches(t, next, 1, time.Second)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	scrapertest.AssertMetricNames(t, batches[0], "scraped.with.token")
+	assert.Empty(t, host.FatalErrors())
+}
+
+func TestAsyncConsumeWithSlowConsumer(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	core, logs := observer.New(zap.WarnLevel)
+	clock := scrapertest.NewFakeClock(time.Now())
+	next := scrapertest.NewFlakyConsumer(
+		scrapertest.WithConsumeScript(
+			scrapertest.ConsumeStep{Delay: time.Second, Err: errors.New("unavailable")},
+			scrapertest.ConsumeStep{Delay: time.Second},
+		),
+		scrapertest.WithConsumeClock(clock),
+		scrapertest.WithMaxConcurrentCalls(1),
+	)
+	tickerCh := make(chan time.Time)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(
+		&cfg,
+		zap.New(core),
+		next,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1))),
+		scraperhelper.WithAsyncConsume(1, 1),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	// the first payload is being consumed, the second one is queued, and the
+	// third one is dropped.
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	tickerCh <- time.Now()
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return logs.FilterMessage("Dropped scraped metrics").Len() == 1 }, time.Second, time.Millisecond)
+
+	// the failed payload is not retried, and the queued one is consumed once
+	// the failed call returned.
+	clock.Advance(time.Second)
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Second)
+	require.Eventually(t, func() bool { return len(next.Batches()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	calls := next.Calls()
+	require.Len(t, calls, 2)
+	assert.EqualError(t, calls[0].Err, "unavailable")
+	assert.NoError(t, calls[1].Err)
+	assert.False(t, calls[1].Started.Before(calls[0].Returned))
+	assert.Equal(t, 1, next.MaxConcurrentCalls())
+	obsreporttest.CheckReceiverMetricsViews(t, "receiver", "", 1, 2)
+}
+
+func TestConsumeTimeoutWithSlowConsumer(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	// the clock is never advanced, so that the first call only returns once
+	// the consume timeout expires.
+	clock := scrapertest.NewFakeClock(time.Now())
+	next := scrapertest.NewFlakyConsumer(
+		scrapertest.WithConsumeScript(scrapertest.ConsumeStep{Delay: time.Hour}, scrapertest.ConsumeStep{}),
+		scrapertest.WithConsumeClock(clock),
+	)
+	tickerCh := make(chan time.Time)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(
+		&cfg,
+		zap.NewNop(),
+		next,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1))),
+		scraperhelper.WithConsumeTimeout(10*time.Millisecond),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	tickerCh <- time.Now()
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(next.Batches()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	calls := next.Calls()
+	require.Len(t, calls, 2)
+	assert.Equal(t, context.DeadlineExceeded, calls[0].Err)
+	assert.Equal(t, 0, clock.Waiters())
+	obsreporttest.CheckReceiverMetricsViews(t, "receiver", "", 1, 1)
+}
+
+func TestReceiverDrivenByFakeClock(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := scrapertest.NewFakeClock(start)
+	// the first scrape takes longer than two collection intervals.
+	scraper := scrapertest.NewScriptedScraper(
+		[]scrapertest.Step{
+			{Delay: 25 * time.Second, Metrics: scrapertest.GenerateMetrics(1, 1)},
+			{Metrics: scrapertest.GenerateMetrics(1, 1)},
+		},
+		scrapertest.WithClock(clock),
+	)
+	next := new(scrapertest.SinkConsumer)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = 10 * time.Second
+	receiver, err := scraperhelper.NewScraperControllerReceiver(
+		&cfg,
+		zap.NewNop(),
+		next,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scraper.Scrape)),
+		scraperhelper.WithUniformTimestamps(scraperhelper.TimestampTick),
+		scraperhelper.WithClock(clock),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	// the timer of the receiver, then the scrape on the first deadline, wait
+	// for the clock.
+	scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+	clock.Advance(10 * time.Second)
+	scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+	assert.Empty(t, next.Batches())
+
+	// the deadlines at 20s and 30s are missed during the scrape, and skipped.
+	clock.Advance(25 * time.Second)
+	scrapertest.WaitForBatches(t, next, 1, time.Second)
+	scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+	clock.Advance(5 * time.Second)
+	batches := scrapertest.WaitForBatches(t, next, 2, time.Second)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	assert.Equal(t, 2, scraper.CallCount())
+	assert.Equal(t, 0, clock.Waiters())
+	for i, tick := range []time.Time{start.Add(10 * time.Second), start.Add(40 * time.Second)} {
+		dataPoint := batches[i].ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0).IntGauge().DataPoints().At(0)
+		assert.Equal(t, pdata.TimestampUnixNano(tick.UnixNano()), dataPoint.Timestamp())
+	}
+	obsreporttest.CheckScraperSkippedTicksView(t, "receiver", 2)
+}
+
+func TestScraperIntervalsIndependentOfReceiverInterval(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := scrapertest.NewFakeClock(start)
+	var mu sync.Mutex
+	scraped := map[string][]time.Duration{}
+	scraper := func(name string, interval time.Duration) scraperhelper.MetricsScraper {
+		scrape := func(context.Context) (pdata.MetricSlice, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			scraped[name] = append(scraped[name], clock.Now().Sub(start))
+			return scrapertest.GenerateMetrics(1, 1), nil
+		}
+		return scraperhelper.NewMetricsScraper(name, scrape, scraperhelper.WithConfig(&scraperhelper.ScraperSettings{CollectionIntervalVal: interval}))
+	}
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = 10 * time.Second
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraper("fast", 5*time.Second)),
+		scraperhelper.AddMetricsScraper(scraper("slow", 15*time.Second)),
+		scraperhelper.WithClock(clock),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	// the scrapers are first scraped one collection interval of the receiver
+	// after it started, or after they were added, and then every interval
+	// of their own.
+	for elapsed := time.Duration(0); elapsed < time.Minute; elapsed += 5 * time.Second {
+		scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+		if elapsed == 20*time.Second {
+			require.NoError(t, receiver.(scraperhelper.ScraperManager).AddScraper(context.Background(), scraper("added", 25*time.Second)))
+		}
+		clock.Advance(5 * time.Second)
+	}
+	scrapertest.WaitForClockWaiters(t, clock, 1, time.Second)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	s := time.Second
+	assert.Equal(t, map[string][]time.Duration{
+		"fast":  {10 * s, 15 * s, 20 * s, 25 * s, 30 * s, 35 * s, 40 * s, 45 * s, 50 * s, 55 * s, 60 * s},
+		"slow":  {10 * s, 25 * s, 40 * s, 55 * s},
+		"added": {30 * s, 55 * s},
+	}, scraped)
+}
+
+func TestReceiverStress(t *testing.T) {
+	iterations := 300
+	if testing.Short() {
+		iterations = 10
+	}
+
+	// the configurations are updated with random collection intervals.
+	updateConfig := func(ctx context.Context, receiver component.MetricsReceiver, rnd *rand.Rand) {
+		cfg := &scraperhelper.ScraperSettings{CollectionIntervalVal: time.Duration(1+rnd.Intn(3)) * time.Millisecond}
+		_ = receiver.(scraperhelper.ScraperManager).UpdateConfig(ctx, map[string]scraperhelper.ScraperConfig{"scraper1": cfg})
+	}
+	factory := func(name string, cfg scraperhelper.ScraperConfig) (scraperhelper.BaseScraper, error) {
+		return scraperhelper.NewMetricsScraper(name, scrapertest.NewScrapeMetrics(1, 1), scraperhelper.WithConfig(cfg)), nil
+	}
+
+	tests := []struct {
+		name    string
+		options []scraperhelper.ScraperControllerOption
+	}{
+		{
+			name: "Default",
+		},
+		{
+			name:    "SharedScheduler",
+			options: []scraperhelper.ScraperControllerOption{scraperhelper.WithSharedScheduler()},
+		},
+		{
+			name:    "AsyncConsume",
+			options: []scraperhelper.ScraperControllerOption{scraperhelper.WithAsyncConsume(1, 1)},
+		},
+		{
+			name:    "MaxConcurrentScrapes",
+			options: []scraperhelper.ScraperControllerOption{scraperhelper.WithMaxConcurrentScrapes(2)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scrapertest.StressReceiver(t, func(nextConsumer consumer.MetricsConsumer) (component.MetricsReceiver, error) {
+				cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+				cfg.CollectionInterval = time.Millisecond
+				options := append([]scraperhelper.ScraperControllerOption{
+					scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper1", scrapertest.NewScrapeMetrics(1, 1))),
+					scraperhelper.WithScraperFactory(factory),
+				}, test.options...)
+				return scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), nextConsumer, options...)
+			}, scrapertest.WithStressIterations(iterations), scrapertest.WithStressOps(updateConfig))
+		})
+	}
+}
+
+func TestScrapeOnStartGate(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		options []scraperhelper.ScraperControllerOption
+	}{
+		{name: "ScrapeLoop", options: []scraperhelper.ScraperControllerOption{scraperhelper.WithTickerChannel(make(chan time.Time))}},
+		{name: "SharedScheduler", options: []scraperhelper.ScraperControllerOption{scraperhelper.WithSharedScheduler()}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			newReceiver := func(next *scrapertest.SinkConsumer) component.Receiver {
+				cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+				cfg.CollectionInterval = time.Hour
+				options := append([]scraperhelper.ScraperControllerOption{
+					scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", func(context.Context) (pdata.MetricSlice, error) {
+						return scrapertest.GenerateMetrics(1, 1), nil
+					})),
+				}, test.options...)
+				receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), next, options...)
+				require.NoError(t, err)
+				return receiver
+			}
+
+			t.Run("Disabled", func(t *testing.T) {
+				scrapertest.SetGate(t, scraperhelper.ScrapeOnStartGate, false)
+				next := new(scrapertest.SinkConsumer)
+				receiver := newReceiver(next)
+				require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+				require.NoError(t, receiver.Shutdown(context.Background()))
+				assert.Equal(t, 0, next.Calls())
+			})
+
+			t.Run("Enabled", func(t *testing.T) {
+				scrapertest.SetGate(t, scraperhelper.ScrapeOnStartGate, true)
+				next := new(scrapertest.SinkConsumer)
+				receiver := newReceiver(next)
+				require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+				scrapertest.WaitForBatches(t, next, 1, 5*time.Second)
+				require.NoError(t, receiver.Shutdown(context.Background()))
+				assert.Equal(t, 1, next.Calls())
+			})
+		})
+	}
+}
+
+func TestSkipEmptyPayloadsGate(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		enabled       bool
+		expectedCalls int
+	}{
+		{name: "Disabled", enabled: false, expectedCalls: 2},
+		{name: "Enabled", enabled: true, expectedCalls: 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			scrapertest.SetGate(t, scraperhelper.SkipEmptyPayloadsGate, test.enabled)
+
+			tickerCh := make(chan time.Time)
+			next := new(scrapertest.SinkConsumer)
+			cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+			receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), next,
+				scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", func(context.Context) (pdata.MetricSlice, error) {
+					return pdata.NewMetricSlice(), nil
+				})),
+				scraperhelper.WithTickerChannel(tickerCh),
+			)
+			require.NoError(t, err)
+			require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+			// the second tick is only received once the first one was
+			// scraped, and the receiver is shutdown once the second one was.
+			tickerCh <- time.Now()
+			tickerCh <- time.Now()
+			require.NoError(t, receiver.Shutdown(context.Background()))
+			assert.Equal(t, test.expectedCalls, next.Calls())
+		})
+	}
+}
+
+// withLifecycle is a ScraperOption defined outside of scraperhelper.
+func withLifecycle(events *[]string) scraperhelper.ScraperOption {
+	return func(set *scraperhelper.ScraperComponentSettings) {
+		scraperhelper.WithStart(func(context.Context, component.Host) error {
+			*events = append(*events, "start")
+			return nil
+		})(set)
+		scraperhelper.WithShutdown(func(context.Context) error {
+			*events = append(*events, "shutdown")
+			return nil
+		})(set)
+	}
+}
+
+func TestScraperOptionDefinedOutsidePackage(t *testing.T) {
+	var events []string
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1), withLifecycle(&events))),
+		scraperhelper.WithTickerChannel(make(chan time.Time)),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Equal(t, []string{"start", "shutdown"}, events)
+
+	// the options of this package applied by the option are checked as usual.
+	startEx := func(context.Context, component.Host, scraperhelper.StartInfo) error { return nil }
+	_, err = scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1), withLifecycle(&events), scraperhelper.WithStartEx(startEx))),
+	)
+	assert.EqualError(t, err, `invalid scraper "scraper": only one of WithStart and WithStartEx can be set`)
+}
@@ -0,0 +1,288 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenterror"
+)
+
+// ScraperFactory creates the MetricsScraper, ResourceMetricsScraper or
+// StreamingScraper with the given name from its configuration, typically passing the configuration
+// to the scraper with WithConfig.
+type ScraperFactory func(name string, cfg ScraperConfig) (BaseScraper, error)
+
+// WithScraperFactory sets the factory used by UpdateConfig to create the
+// scrapers that are added to the receiver, or whose configuration changed.
+func WithScraperFactory(factory ScraperFactory) ScraperControllerOption {
+	return func(o *controller) {
+		o.scraperFactory = factory
+	}
+}
+
+var scraperSettingsType = reflect.TypeOf(ScraperSettings{})
+
+// UpdateConfig reconfigures the scrapers of the receiver with the given
+// scraper configurations, keyed by scraper name, by comparing them with the
+// configurations the current scrapers were created from:
+//
+// Scrapers without a configuration are removed and closed, in the reverse
+// order to which they were added. Scrapers whose configuration is unchanged
+// are left untouched. If only the collection interval of a scraper changed,
+// the new interval is applied in place, which is only possible for
+// configurations that embed ScraperSettings. If anything else changed, a new
+// scraper is created with the ScraperFactory and initialized, replaces the
+// previous scraper, which is then closed. Scrapers that do not exist yet are
+// created, initialized, and scraped from the next tick on. Disabled scrapers
+// with a configuration are created again, and are recorded as disabled if
+// they still are, while those without are still reported as disabled.
+//
+// UpdateConfig can only be called before the receiver is started, in which
+// case scrapers are neither initialized nor closed, or while it is running.
+// It can be called while scraping, and waits for in-flight scrapes of the
+// scrapers it replaces or removes. If some of the scrapers cannot be updated,
+// the other scrapers are still updated, and the errors are combined.
+func (sc *controller) UpdateConfig(ctx context.Context, newScraperConfigs map[string]ScraperConfig) error {
+	sc.reconfigureMu.Lock()
+	defer sc.reconfigureMu.Unlock()
+
+	if state := sc.State(); state != StateCreated && state != StateRunning {
+		return fmt.Errorf("cannot update the configuration of receiver %q in state %v", sc.name, state)
+	}
+
+	// the scrapers being reconfigured are recorded as disabled again if they
+	// still are, while the other disabled scrapers are kept.
+	sc.scrapersMu.Lock()
+	disabled := sc.disabledScrapers[:0]
+	for _, scraper := range sc.disabledScrapers {
+		if _, ok := newScraperConfigs[scraper.ID.Scraper]; !ok {
+			disabled = append(disabled, scraper)
+		}
+	}
+	sc.disabledScrapers = disabled
+	sc.scrapersMu.Unlock()
+
+	var errs []error
+	current := sc.registeredScrapers()
+	existing := make(map[string]BaseScraper, len(current))
+	for i := len(current) - 1; i >= 0; i-- {
+		name := current[i].Name()
+		if _, ok := newScraperConfigs[name]; ok {
+			existing[name] = current[i]
+			continue
+		}
+		if err := sc.removeScraper(ctx, name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove scraper %q: %w", name, err))
+		}
+	}
+
+	names := make([]string, 0, len(newScraperConfigs))
+	for name := range newScraperConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var err error
+		if scraper, ok := existing[name]; ok {
+			err = sc.updateScraper(ctx, scraper, newScraperConfigs[name])
+		} else {
+			err = sc.addScraperFromConfig(ctx, name, newScraperConfigs[name])
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if sc.State() == StateRunning {
+		sc.logDeprecatedFields()
+	}
+	return componenterror.CombineErrors(errs)
+}
+
+// updateScraper applies the configuration to an existing scraper, leaving it
+// untouched if the configuration is unchanged, applying a new collection
+// interval in place, or else replacing the scraper.
+func (sc *controller) updateScraper(ctx context.Context, scraper BaseScraper, cfg ScraperConfig) error {
+	sc.scrapersMu.RLock()
+	current := sc.scraperConfig(scraper)
+	sc.scrapersMu.RUnlock()
+
+	if reflect.DeepEqual(current, cfg) {
+		return nil
+	}
+	if !equalExceptInterval(current, cfg) {
+		return sc.replaceScraper(ctx, scraper, cfg)
+	}
+
+	if err := validateConfig(cfg); err != nil {
+		return fmt.Errorf("invalid scraper %q: %w", scraper.Name(), err)
+	}
+	sc.scrapersMu.Lock()
+	defer sc.scrapersMu.Unlock()
+	sc.scraperConfigs[scraper.Name()] = cfg
+	sc.updateSchedules()
+	sc.updateSingleScraper()
+	return nil
+}
+
+// addScraperFromConfig creates a scraper from its configuration, and adds it
+// to the receiver.
+func (sc *controller) addScraperFromConfig(ctx context.Context, name string, cfg ScraperConfig) error {
+	scraper, err := sc.newScraper(name, cfg)
+	if err != nil {
+		return err
+	}
+	return sc.AddScraper(ctx, scraper)
+}
+
+// replaceScraper creates a new scraper from the configuration, initializes it
+// if the receiver is running, and replaces the existing scraper with it, which
+// is then closed.
+func (sc *controller) replaceScraper(ctx context.Context, existing BaseScraper, cfg ScraperConfig) error {
+	name := existing.Name()
+	scraper, err := sc.newScraper(name, cfg)
+	if err != nil {
+		return err
+	}
+	if !scraperEnabled(scraper) {
+		if err := sc.removeScraper(ctx, name); err != nil {
+			return fmt.Errorf("failed to remove scraper %q: %w", name, err)
+		}
+		return sc.AddScraper(ctx, scraper)
+	}
+	if err := validateScraper(scraper); err != nil {
+		return err
+	}
+	sc.checkInitialDelay(scraper)
+
+	running := sc.State() == StateRunning
+	if running {
+		host, err := sc.Host()
+		if err != nil {
+			return err
+		}
+		if err := startScraper(ctx, host, scraper, sc.receiverSettings(sc.registeredScrapers())); err != nil {
+			return fmt.Errorf("failed to initialize scraper %q: %w", name, err)
+		}
+	}
+
+	if !sc.swapScraper(existing, scraper) {
+		// the scraper was removed while the new scraper was being initialized
+		if running {
+			if err := scraper.Shutdown(ctx); err != nil {
+				sc.logger.Error("Error closing scraper", zap.String("scraper", name), zap.Error(err))
+			}
+		}
+		return &ScraperNotFoundError{ID: sc.scraperID(name)}
+	}
+	if !running {
+		return nil
+	}
+	if err := existing.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to close replaced scraper %q: %w", name, err)
+	}
+	return nil
+}
+
+// swapScraper replaces the existing scraper with the new scraper, keeping its
+// position in the registration order, and reports whether the existing
+// scraper was found.
+func (sc *controller) swapScraper(existing, scraper BaseScraper) bool {
+	sc.scrapersMu.Lock()
+	defer sc.scrapersMu.Unlock()
+
+	found := false
+	for i, s := range sc.scrapers {
+		if s == existing {
+			sc.scrapers[i] = scraper
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+
+	// the scraper may be of a different type than the one it replaces
+	sc.metricsScrapers.scrapers = sc.metricsScrapers.scrapers[:0]
+	sc.resourceMetricScrapers = sc.resourceMetricScrapers[:0]
+	sc.streamingScrapers = sc.streamingScrapers[:0]
+	for _, s := range sc.scrapers {
+		switch s := s.(type) {
+		case MetricsScraper:
+			sc.metricsScrapers.scrapers = append(sc.metricsScrapers.scrapers, s)
+		case ResourceMetricsScraper:
+			sc.resourceMetricScrapers = append(sc.resourceMetricScrapers, s)
+		case StreamingScraper:
+			sc.streamingScrapers = append(sc.streamingScrapers, s)
+		}
+	}
+	delete(sc.scraperConfigs, scraper.Name())
+	sc.updateSchedules()
+	sc.updateSingleScraper()
+	return true
+}
+
+// newScraper creates a scraper from its configuration with the scraper
+// factory.
+func (sc *controller) newScraper(name string, cfg ScraperConfig) (BaseScraper, error) {
+	if sc.scraperFactory == nil {
+		return nil, fmt.Errorf("cannot create scraper %q, receiver %q has no scraper factory", name, sc.name)
+	}
+	scraper, err := sc.scraperFactory(name, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scraper %q: %w", name, err)
+	}
+	if scraper.Name() != name {
+		return nil, fmt.Errorf("scraper factory created scraper %q for the configuration of scraper %q", scraper.Name(), name)
+	}
+	return scraper, nil
+}
+
+// equalExceptInterval reports whether the configurations are equal other than
+// their collection intervals. Configurations that do not embed
+// ScraperSettings are never considered equal.
+func equalExceptInterval(a, b ScraperConfig) bool {
+	a, b = withoutInterval(a), withoutInterval(b)
+	return a != nil && b != nil && reflect.DeepEqual(a, b)
+}
+
+// withoutInterval returns a copy of the configuration with a collection
+// interval of zero, or nil if the configuration is not a pointer to a struct
+// that is, or embeds, ScraperSettings.
+func withoutInterval(cfg ScraperConfig) ScraperConfig {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+	settings := c.Elem()
+	if settings.Type() != scraperSettingsType {
+		settings = settings.FieldByName("ScraperSettings")
+	}
+	if !settings.IsValid() || settings.Type() != scraperSettingsType {
+		return nil
+	}
+	settings.FieldByName("CollectionIntervalVal").SetInt(0)
+	return c.Interface().(ScraperConfig)
+}
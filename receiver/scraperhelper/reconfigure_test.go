@@ -0,0 +1,309 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// testScraperFactory creates scrapers that record their lifecycle, with the
+// path of their configuration, and their scrapes.
+type testScraperFactory struct {
+	recorder testOrderRecorder
+
+	mu      sync.Mutex
+	scraped map[string]bool
+}
+
+func (f *testScraperFactory) create(name string, cfg ScraperConfig) (BaseScraper, error) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.scraped != nil {
+			f.scraped[name] = true
+		}
+		return singleMetric(), nil
+	}
+	options := append(f.recorder.options(name+" "+cfg.(*testScraperConfig).Path), WithConfig(cfg))
+	return NewMetricsScraper(name, scrape, options...), nil
+}
+
+func (f *testScraperFactory) scraper(t *testing.T, name string, cfg ScraperConfig) BaseScraper {
+	scraper, err := f.create(name, cfg)
+	require.NoError(t, err)
+	return scraper
+}
+
+func (f *testScraperFactory) events() []string {
+	f.recorder.mu.Lock()
+	defer f.recorder.mu.Unlock()
+	events := f.recorder.order
+	f.recorder.order = nil
+	return events
+}
+
+// scrapedOn sends the tick and returns the names of the scrapers scraped on
+// it.
+func (f *testScraperFactory) scrapedOn(t *testing.T, tickerCh chan<- time.Time, sink *consumertest.MetricsSink, tick time.Time) []string {
+	f.mu.Lock()
+	f.scraped = map[string]bool{}
+	f.mu.Unlock()
+	tickerCh <- tick
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	sink.Reset()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for name := range f.scraped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func pathConfig(path string, interval time.Duration) *testScraperConfig {
+	return &testScraperConfig{ScraperSettings: ScraperSettings{CollectionIntervalVal: interval}, Path: path}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	factory := &testScraperFactory{}
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(factory.scraper(t, "unchanged", pathConfig("/unchanged", 0)).(MetricsScraper)),
+		AddMetricsScraper(factory.scraper(t, "interval", pathConfig("/interval", 0)).(MetricsScraper)),
+		AddMetricsScraper(factory.scraper(t, "removed", pathConfig("/removed", 0)).(MetricsScraper)),
+		AddMetricsScraper(factory.scraper(t, "changed", pathConfig("/changed", 0)).(MetricsScraper)),
+		WithScraperFactory(factory.create),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	start := time.Now()
+	receiver.(*controller).now = func() time.Time { return start }
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	factory.events()
+
+	require.NoError(t, receiver.(ScraperManager).UpdateConfig(context.Background(), map[string]ScraperConfig{
+		"unchanged": pathConfig("/unchanged", 0),
+		"interval":  pathConfig("/interval", 3*time.Minute),
+		"changed":   pathConfig("/changed/new", 0),
+		"added":     pathConfig("/added", 0),
+	}))
+	assert.Equal(t, []string{
+		"shutdown removed /removed",
+		"start added /added",
+		"start changed /changed/new",
+		"shutdown changed /changed",
+	}, factory.events())
+
+	all := []string{"added", "changed", "interval", "unchanged"}
+	assert.Equal(t, all, factory.scrapedOn(t, tickerCh, sink, start.Add(time.Minute)))
+	assert.Equal(t, []string{"added", "changed", "unchanged"}, factory.scrapedOn(t, tickerCh, sink, start.Add(2*time.Minute)))
+	assert.Equal(t, []string{"added", "changed", "unchanged"}, factory.scrapedOn(t, tickerCh, sink, start.Add(3*time.Minute)))
+	assert.Equal(t, all, factory.scrapedOn(t, tickerCh, sink, start.Add(4*time.Minute-time.Second)))
+
+	// the interval applied in place is compared against on the next update
+	require.NoError(t, receiver.(ScraperManager).UpdateConfig(context.Background(), map[string]ScraperConfig{
+		"unchanged": pathConfig("/unchanged", 0),
+		"interval":  pathConfig("/interval", 3*time.Minute),
+		"changed":   pathConfig("/changed/new", 0),
+		"added":     pathConfig("/added", 0),
+	}))
+	assert.Empty(t, factory.events())
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Equal(t, []string{
+		"shutdown added /added",
+		"shutdown changed /changed/new",
+		"shutdown interval /interval",
+		"shutdown unchanged /unchanged",
+	}, factory.events())
+}
+
+func TestUpdateConfigBeforeStart(t *testing.T) {
+	factory := &testScraperFactory{}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(factory.scraper(t, "removed", pathConfig("/removed", 0)).(MetricsScraper)),
+		AddMetricsScraper(factory.scraper(t, "changed", pathConfig("/changed", 0)).(MetricsScraper)),
+		WithScraperFactory(factory.create),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.(ScraperManager).UpdateConfig(context.Background(), map[string]ScraperConfig{
+		"changed": pathConfig("/changed/new", 0),
+		"added":   pathConfig("/added", 0),
+	}))
+	assert.Empty(t, factory.events())
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	assert.Equal(t, []string{"start changed /changed/new", "start added /added"}, factory.events())
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Equal(t, []string{"shutdown added /added", "shutdown changed /changed/new"}, factory.events())
+
+	assert.EqualError(t, receiver.(ScraperManager).UpdateConfig(context.Background(), nil), `cannot update the configuration of receiver "receiver" in state Stopped`)
+}
+
+func TestUpdateConfigDisabledScrapers(t *testing.T) {
+	factory := &testScraperFactory{}
+	create := func(name string, cfg ScraperConfig) (BaseScraper, error) {
+		scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+		options := append(factory.recorder.options(name), WithConfig(cfg), WithEnabled(cfg.(*testScraperConfig).Path != ""))
+		return NewMetricsScraper(name, scrape, options...), nil
+	}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("unconfigured", nil, WithEnabled(false))),
+		WithScraperFactory(create),
+		WithAllowEmptyScrapers(),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	// the scraper disabled when the receiver was created has no
+	// configuration, and is still reported as disabled.
+	unconfigured := DisabledScraper{ID: ScraperID{Receiver: "receiver", Scraper: "unconfigured"}, Reason: DisabledByConfig}
+	require.NoError(t, receiver.(ScraperManager).UpdateConfig(context.Background(), map[string]ScraperConfig{
+		"enabled":  pathConfig("/enabled", 0),
+		"disabled": pathConfig("", 0),
+	}))
+	assert.Equal(t, []DisabledScraper{unconfigured, {ID: ScraperID{Receiver: "receiver", Scraper: "disabled"}, Reason: DisabledByConfig}}, receiver.(ScraperInspector).DisabledScrapers())
+	assert.Equal(t, []string{"start enabled"}, factory.events())
+
+	require.NoError(t, receiver.(ScraperManager).UpdateConfig(context.Background(), map[string]ScraperConfig{
+		"enabled":  pathConfig("", 0),
+		"disabled": pathConfig("/disabled", 0),
+	}))
+	assert.Equal(t, []DisabledScraper{unconfigured, {ID: ScraperID{Receiver: "receiver", Scraper: "enabled"}, Reason: DisabledByConfig}}, receiver.(ScraperInspector).DisabledScrapers())
+	assert.Equal(t, []string{"start disabled", "shutdown enabled"}, factory.events())
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+func TestUpdateConfigErrors(t *testing.T) {
+	factory := &testScraperFactory{}
+	create := func(name string, cfg ScraperConfig) (BaseScraper, error) {
+		switch name {
+		case "failing":
+			return nil, errors.New("err1")
+		case "misnamed":
+			return factory.create("other", cfg)
+		}
+		return factory.create(name, cfg)
+	}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(factory.scraper(t, "interval", pathConfig("/interval", 0)).(MetricsScraper)),
+		WithScraperFactory(create),
+	)
+	require.NoError(t, err)
+
+	err = receiver.(ScraperManager).UpdateConfig(context.Background(), map[string]ScraperConfig{
+		"interval": pathConfig("/interval", -time.Minute),
+		"failing":  pathConfig("/failing", 0),
+		"misnamed": pathConfig("/misnamed", 0),
+		"valid":    pathConfig("/valid", 0),
+	})
+	assert.EqualError(t, err, `[failed to create scraper "failing": err1; invalid scraper "interval": collection interval must not be negative; scraper factory created scraper "other" for the configuration of scraper "misnamed"]`)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	assert.Equal(t, []string{"start interval /interval", "start valid /valid"}, factory.events())
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	receiver, err = NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), WithAllowEmptyScrapers())
+	require.NoError(t, err)
+	err = receiver.(ScraperManager).UpdateConfig(context.Background(), map[string]ScraperConfig{"added": pathConfig("/added", 0)})
+	assert.EqualError(t, err, `cannot create scraper "added", receiver "receiver" has no scraper factory`)
+}
+
+func TestUpdateConfigConcurrently(t *testing.T) {
+	factory := &testScraperFactory{}
+	cfg := DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = time.Millisecond
+	receiver, err := NewScraperControllerReceiver(
+		&cfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		WithScraperFactory(factory.create),
+		WithAllowEmptyScrapers(),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	configs := []map[string]ScraperConfig{
+		{"a": pathConfig("/a", 0), "b": pathConfig("/b", 0)},
+		{"a": pathConfig("/a", 2*time.Millisecond), "c": pathConfig("/c", 0)},
+		{"b": pathConfig("/b/new", 0), "c": pathConfig("/c", 0)},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				err := receiver.(ScraperManager).UpdateConfig(context.Background(), configs[(i+j)%len(configs)])
+				if err != nil {
+					// the receiver was shutdown concurrently
+					assert.EqualError(t, err, `cannot update the configuration of receiver "receiver" in state Stopped`)
+					return
+				}
+			}
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	wg.Wait()
+
+	// every scraper that was started was closed exactly once
+	running := map[string]int{}
+	for _, event := range factory.events() {
+		if event[:len("start")] == "start" {
+			running[event[len("start "):]]++
+		} else {
+			running[event[len("shutdown "):]]--
+		}
+	}
+	for scraper, n := range running {
+		assert.Equal(t, 0, n, scraper)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"fmt"
+	"sort"
+
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/config"
+)
+
+// RegisteredScraper describes a scraper supported by a receiver.
+type RegisteredScraper struct {
+	// CreateDefaultConfig creates the default configuration of the scraper.
+	CreateDefaultConfig func() ScraperConfig
+	// CreateScraper creates the scraper from its configuration.
+	CreateScraper ScraperFactory
+	// EnabledByDefault makes the scraper enabled unless it is disabled
+	// explicitly, even if it is not configured.
+	EnabledByDefault bool
+}
+
+// ScraperRegistry contains the scrapers supported by a receiver, by name.
+type ScraperRegistry map[string]RegisteredScraper
+
+// EnabledScrapers returns the sorted names of the enabled scrapers: the
+// configured scrapers, and the scrapers enabled by default, except for the
+// disabled scrapers. An error is returned for each configured or disabled
+// scraper that is not registered, suggesting the closest registered name, and
+// for each scraper that is both configured and disabled.
+func (r ScraperRegistry) EnabledScrapers(configured, disabled []string) ([]string, error) {
+	var errs []error
+	enabled := map[string]bool{}
+	for name, scraper := range r {
+		if scraper.EnabledByDefault {
+			enabled[name] = true
+		}
+	}
+	for _, name := range configured {
+		if err := r.checkName(name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		enabled[name] = true
+	}
+
+	isConfigured := map[string]bool{}
+	for _, name := range configured {
+		isConfigured[name] = true
+	}
+	for _, name := range disabled {
+		if err := r.checkName(name); err != nil {
+			errs = append(errs, fmt.Errorf("invalid disabled scraper: %w", err))
+			continue
+		}
+		if isConfigured[name] {
+			errs = append(errs, fmt.Errorf("scraper %q is both configured and disabled", name))
+		}
+		delete(enabled, name)
+	}
+	if len(errs) > 0 {
+		return nil, componenterror.CombineErrors(errs)
+	}
+
+	names := make([]string, 0, len(enabled))
+	for name := range enabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// LoadScraperConfigs returns the configurations of the enabled scrapers, as
+// computed by EnabledScrapers, from the settings of the configured scrapers,
+// keyed by scraper name, typically the value of a "scrapers" key of the
+// receiver configuration. A scraper configured without settings, and a
+// scraper enabled by default that is not configured, use their default
+// configuration. Settings are unmarshalled with UnmarshalScraperConfigStrict.
+func (r ScraperRegistry) LoadScraperConfigs(scrapers map[string]interface{}, disabled []string) (map[string]ScraperConfig, error) {
+	configured := make([]string, 0, len(scrapers))
+	for name := range scrapers {
+		configured = append(configured, name)
+	}
+	sort.Strings(configured)
+
+	enabled, err := r.EnabledScrapers(configured, disabled)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make(map[string]ScraperConfig, len(enabled))
+	for _, name := range enabled {
+		cfg := r[name].CreateDefaultConfig()
+		switch settings := scrapers[name].(type) {
+		case nil:
+		case map[string]interface{}:
+			v := config.NewViper()
+			if err := v.MergeConfigMap(settings); err != nil {
+				return nil, fmt.Errorf("error reading settings for scraper %q: %w", name, err)
+			}
+			if err := UnmarshalScraperConfigStrict(name, v, cfg); err != nil {
+				return nil, fmt.Errorf("error reading settings for scraper %q: %w", name, err)
+			}
+		default:
+			return nil, fmt.Errorf("settings of scraper %q must be a map, got %T", name, settings)
+		}
+		configs[name] = cfg
+	}
+	return configs, nil
+}
+
+// Create creates the registered scraper with the given name from its
+// configuration. It can be used with WithScraperFactory.
+func (r ScraperRegistry) Create(name string, cfg ScraperConfig) (BaseScraper, error) {
+	if err := r.checkName(name); err != nil {
+		return nil, err
+	}
+	return r[name].CreateScraper(name, cfg)
+}
+
+// AddRegisteredScrapers creates each of the scrapers with the given
+// configurations, keyed by scraper name, typically returned by
+// LoadScraperConfigs, and adds them to the receiver in the order of their
+// names. The registry is also used as the scraper factory of the receiver, as
+// set with WithScraperFactory. Scrapers that cannot be created make
+// NewScraperControllerReceiver fail.
+func AddRegisteredScrapers(registry ScraperRegistry, configs map[string]ScraperConfig) ScraperControllerOption {
+	return func(o *controller) {
+		o.scraperFactory = registry.Create
+
+		names := make([]string, 0, len(configs))
+		for name := range configs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			scraper, err := registry.Create(name, configs[name])
+			if err != nil {
+				o.optionErrs = append(o.optionErrs, fmt.Errorf("failed to create scraper %q: %w", name, err))
+				continue
+			}
+			switch s := scraper.(type) {
+			case MetricsScraper:
+				AddMetricsScraper(s)(o)
+			case ResourceMetricsScraper:
+				AddResourceMetricsScraper(s)(o)
+			case StreamingScraper:
+				AddStreamingScraper(s)(o)
+			default:
+				o.optionErrs = append(o.optionErrs, fmt.Errorf("unsupported scraper type %T of scraper %q", scraper, name))
+			}
+		}
+	}
+}
+
+// checkName returns an error if no scraper is registered with the given name,
+// suggesting the closest registered name, if any is close enough.
+func (r ScraperRegistry) checkName(name string) error {
+	if _, ok := r[name]; ok {
+		return nil
+	}
+
+	names := make([]string, 0, len(r))
+	for registered := range r {
+		names = append(names, registered)
+	}
+	sort.Strings(names)
+	if suggestion := closestName(name, names); suggestion != "" {
+		return fmt.Errorf("unknown scraper %q, did you mean %q?", name, suggestion)
+	}
+	return fmt.Errorf("unknown scraper %q", name)
+}
+
+// closestName returns the candidate with the smallest edit distance to the
+// name, if that distance is at most a third of the length of the name, or
+// one.
+func closestName(name string, candidates []string) string {
+	maxDistance := len(name) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	closest := ""
+	for _, candidate := range candidates {
+		if d := editDistance(name, candidate); d <= maxDistance {
+			closest, maxDistance = candidate, d-1
+		}
+	}
+	return closest
+}
+
+// editDistance returns the optimal string alignment distance between a and b,
+// the Levenshtein distance where swapping two adjacent characters is also a
+// single edit.
+func editDistance(a, b string) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min3(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && rows[i-2][j-2]+1 < rows[i][j] {
+				rows[i][j] = rows[i-2][j-2] + 1
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
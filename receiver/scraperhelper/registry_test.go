@@ -0,0 +1,182 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+)
+
+func testScraperRegistry(factory *testScraperFactory) ScraperRegistry {
+	registered := func(path string, enabledByDefault bool) RegisteredScraper {
+		return RegisteredScraper{
+			CreateDefaultConfig: func() ScraperConfig { return pathConfig(path, 0) },
+			CreateScraper:       factory.create,
+			EnabledByDefault:    enabledByDefault,
+		}
+	}
+	return ScraperRegistry{
+		"cpu":        registered("/cpu", true),
+		"memory":     registered("/memory", true),
+		"disk":       registered("/disk", false),
+		"filesystem": registered("/filesystem", false),
+	}
+}
+
+func TestScraperRegistryEnabledScrapers(t *testing.T) {
+	registry := testScraperRegistry(&testScraperFactory{})
+
+	testCases := []struct {
+		name        string
+		configured  []string
+		disabled    []string
+		expected    []string
+		expectedErr string
+	}{
+		{
+			name:     "Empty",
+			expected: []string{"cpu", "memory"},
+		},
+		{
+			name:       "Configured",
+			configured: []string{"disk", "cpu"},
+			expected:   []string{"cpu", "disk", "memory"},
+		},
+		{
+			name:       "Disabled",
+			configured: []string{"filesystem"},
+			disabled:   []string{"memory"},
+			expected:   []string{"cpu", "filesystem"},
+		},
+		{
+			name:     "AllDisabled",
+			disabled: []string{"cpu", "memory"},
+			expected: []string{},
+		},
+		{
+			name:        "Typo",
+			configured:  []string{"filesytem"},
+			expectedErr: `unknown scraper "filesytem", did you mean "filesystem"?`,
+		},
+		{
+			name:        "DisabledTypo",
+			disabled:    []string{"memroy"},
+			expectedErr: `invalid disabled scraper: unknown scraper "memroy", did you mean "memory"?`,
+		},
+		{
+			name:        "Unknown",
+			configured:  []string{"network"},
+			expectedErr: `unknown scraper "network"`,
+		},
+		{
+			name:        "ConfiguredAndDisabled",
+			configured:  []string{"disk"},
+			disabled:    []string{"disk"},
+			expectedErr: `scraper "disk" is both configured and disabled`,
+		},
+		{
+			name:        "Multiple",
+			configured:  []string{"cpus", "dsk"},
+			expectedErr: `[unknown scraper "cpus", did you mean "cpu"?; unknown scraper "dsk", did you mean "disk"?]`,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			enabled, err := registry.EnabledScrapers(test.configured, test.disabled)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, enabled)
+		})
+	}
+}
+
+func TestScraperRegistryLoadScraperConfigs(t *testing.T) {
+	registry := testScraperRegistry(&testScraperFactory{})
+
+	configs, err := registry.LoadScraperConfigs(map[string]interface{}{
+		"cpu":  nil,
+		"disk": map[string]interface{}{"path": "/mnt", "collection_interval": 30},
+	}, []string{"memory"})
+	require.NoError(t, err)
+	expectedDisk := pathConfig("/mnt", 30*time.Second)
+	expectedDisk.RegisterDeprecatedField(DeprecatedField{Name: "collection_interval: 30", Replacement: "collection_interval: 30s"})
+	assert.Equal(t, map[string]ScraperConfig{
+		"cpu":  pathConfig("/cpu", 0),
+		"disk": expectedDisk,
+	}, configs)
+
+	configs, err = registry.LoadScraperConfigs(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]ScraperConfig{
+		"cpu":    pathConfig("/cpu", 0),
+		"memory": pathConfig("/memory", 0),
+	}, configs)
+
+	_, err = registry.LoadScraperConfigs(map[string]interface{}{"dsik": nil}, nil)
+	assert.EqualError(t, err, `unknown scraper "dsik", did you mean "disk"?`)
+
+	_, err = registry.LoadScraperConfigs(map[string]interface{}{"disk": map[string]interface{}{"pth": "/mnt"}}, nil)
+	assert.EqualError(t, err, `error reading settings for scraper "disk": unknown field "pth" in the configuration of scraper "disk", did you mean "path"?`)
+
+	_, err = registry.LoadScraperConfigs(map[string]interface{}{"disk": "/mnt"}, nil)
+	assert.EqualError(t, err, `settings of scraper "disk" must be a map, got string`)
+}
+
+func TestAddRegisteredScrapers(t *testing.T) {
+	factory := &testScraperFactory{}
+	registry := testScraperRegistry(factory)
+	configs, err := registry.LoadScraperConfigs(map[string]interface{}{"disk": nil}, nil)
+	require.NoError(t, err)
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), AddRegisteredScrapers(registry, configs))
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	assert.Equal(t, []string{"start cpu /cpu", "start disk /disk", "start memory /memory"}, factory.events())
+
+	// the registry is used as the scraper factory
+	require.NoError(t, receiver.(ScraperManager).UpdateConfig(context.Background(), map[string]ScraperConfig{
+		"cpu":        pathConfig("/cpu", 0),
+		"filesystem": pathConfig("/filesystem", 0),
+	}))
+	assert.Equal(t, []string{"shutdown memory /memory", "shutdown disk /disk", "start filesystem /filesystem"}, factory.events())
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	registry["network"] = RegisteredScraper{
+		CreateScraper: func(string, ScraperConfig) (BaseScraper, error) { return nil, errors.New("err1") },
+	}
+	_, err = NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), AddRegisteredScrapers(registry, map[string]ScraperConfig{
+		"network": pathConfig("/network", 0),
+		"sensors": pathConfig("/sensors", 0),
+	}))
+	assert.EqualError(t, err, `receiver "receiver" has 3 configuration errors:
+  - failed to create scraper "network": err1
+  - failed to create scraper "sensors": unknown scraper "sensors"
+  - receiver "receiver" has no scrapers`)
+}
@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxErrorLength   = 1024
+	defaultMaxTrackedSeries = 100000
+)
+
+// RetentionLimits bounds the state retained by the scrapers of a receiver
+// across scrapes. Zero values are replaced with the defaults.
+type RetentionLimits struct {
+	// MaxErrorLength is the maximum length in bytes of the error messages
+	// retained by failure policies, which are truncated past it. Defaults to
+	// 1024.
+	MaxErrorLength int
+	// MaxTrackedSeries is the maximum number of series each of the trackers
+	// of a scraper, set with WithStartTimeTracking, WithDeltaToCumulative and
+	// WithStalenessMarkers, retains between scrapes. Past it, the least
+	// recently seen series are forgotten and counted in the evicted series
+	// metric of the scraper. Defaults to 100000.
+	MaxTrackedSeries int
+}
+
+// withDefaults returns the limits with the zero values replaced with the
+// defaults.
+func (l RetentionLimits) withDefaults() RetentionLimits {
+	if l.MaxErrorLength == 0 {
+		l.MaxErrorLength = defaultMaxErrorLength
+	}
+	if l.MaxTrackedSeries == 0 {
+		l.MaxTrackedSeries = defaultMaxTrackedSeries
+	}
+	return l
+}
+
+// WithRetentionLimits sets the limits of the state retained by the scrapers
+// created by this package across scrapes. Negative limits make
+// NewScraperControllerReceiver fail.
+func WithRetentionLimits(limits RetentionLimits) ScraperControllerOption {
+	return func(o *controller) {
+		if limits.MaxErrorLength < 0 || limits.MaxTrackedSeries < 0 {
+			o.optionErrs = append(o.optionErrs, fmt.Errorf("retention limits must not be negative: %+v", limits))
+			return
+		}
+		o.retention = limits
+	}
+}
+
+// setRetentionLimits sets the limits of the state retained by the scraper.
+func (b *baseScraper) setRetentionLimits(limits RetentionLimits) {
+	limits = limits.withDefaults()
+	if b.failures != nil {
+		b.failures.setMaxErrorLength(limits.MaxErrorLength)
+	}
+	if b.startTimes != nil {
+		b.startTimes.setMaxSeries(limits.MaxTrackedSeries)
+	}
+	if b.cumulative != nil {
+		b.cumulative.setMaxSeries(limits.MaxTrackedSeries)
+	}
+	if b.staleness != nil {
+		b.staleness.setMaxSeries(limits.MaxTrackedSeries)
+	}
+}
+
+// truncationSuffix ends the error messages truncated by truncateError.
+const truncationSuffix = "..."
+
+// truncateError returns an error with the message of err truncated to at most
+// maxLength bytes, or err if its message is not longer. The returned error
+// does not wrap err, so that err is not retained.
+func truncateError(err error, maxLength int) error {
+	msg := err.Error()
+	if len(msg) <= maxLength {
+		return err
+	}
+	suffix := truncationSuffix
+	if maxLength <= len(suffix) {
+		suffix = ""
+	}
+	cut := maxLength - len(suffix)
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return errors.New(msg[:cut] + suffix)
+}
+
+// seenSeries is the key of a tracked series, with the scrape it was last
+// seen on.
+type seenSeries struct {
+	key      string
+	lastSeen uint64
+}
+
+// leastRecentlySeen returns the n least recently seen series.
+func leastRecentlySeen(series []seenSeries, n int) []seenSeries {
+	sort.Slice(series, func(i, j int) bool { return series[i].lastSeen < series[j].lastSeen })
+	return series[:n]
+}
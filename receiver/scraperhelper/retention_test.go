@@ -0,0 +1,173 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport/obsreporttest"
+)
+
+// labelValues returns the given number of label values starting with prefix.
+func labelValues(prefix string, count int) []string {
+	values := make([]string, count)
+	for i := range values {
+		values[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return values
+}
+
+func TestTruncateError(t *testing.T) {
+	err := errors.New("short")
+	assert.Same(t, err, truncateError(err, 5))
+	assert.EqualError(t, truncateError(errors.New("0123456789"), 8), "01234...")
+	assert.EqualError(t, truncateError(errors.New("0123456789"), 2), "01")
+	// the message is not cut in the middle of a multi-byte character.
+	assert.EqualError(t, truncateError(errors.New("abcdé"), 8), "abcdé")
+	assert.EqualError(t, truncateError(errors.New("abcdéfghi"), 8), "abcd...")
+}
+
+func TestRetentionLimitsErrorLength(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	long := strings.Repeat("x", 10000)
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return pdata.NewMetricSlice(), errors.New(long) }
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.New(core), sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape,
+			WithFailurePolicy(FailurePolicy{Mode: FailureDisableAfter, MaxFailures: 2}))),
+		WithTickerChannel(tickerCh),
+		WithRetentionLimits(RetentionLimits{MaxErrorLength: 100}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	for tick := 1; tick <= 2; tick++ {
+		tickerCh <- time.Now()
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) == tick }, time.Second, time.Millisecond)
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	disabled := logs.FilterMessage("Scraper disabled after consecutive failed scrapes until the receiver is restarted").All()
+	require.Len(t, disabled, 1)
+	msg := disabled[0].ContextMap()["error"].(string)
+	assert.Len(t, msg, 100)
+	assert.Equal(t, long[:97]+"...", msg)
+}
+
+func TestRetentionLimitsStartTimeTracking(t *testing.T) {
+	tracker := newStartTimeTracker(10)
+	tracker.setMaxSeries(10)
+
+	assert.Equal(t, 90, tracker.adjustMetrics(cumulativeSum(10, labelValues("a", 100)...)))
+	assert.Len(t, tracker.series, 10)
+
+	// the least recently seen series are evicted first.
+	tracker.adjustMetrics(cumulativeSum(20, labelValues("b", 5)...))
+	assert.Len(t, tracker.series, 10)
+	metrics := cumulativeSum(30, labelValues("b", 5)...)
+	assert.Equal(t, 0, tracker.adjustMetrics(metrics))
+	for _, startTime := range startTimes(metrics) {
+		assert.EqualValues(t, 20, startTime)
+	}
+}
+
+func TestRetentionLimitsDeltaToCumulative(t *testing.T) {
+	accumulator := newDeltaAccumulator(10)
+	accumulator.setMaxSeries(10)
+
+	points := make([]deltaPoint, 100)
+	for i, label := range labelValues("a", 100) {
+		points[i] = deltaPoint{label: label, value: 1}
+	}
+	assert.Equal(t, 90, accumulator.accumulateMetrics(deltaSum(10, points...)))
+	assert.Len(t, accumulator.series, 10)
+
+	// the least recently seen series are evicted first.
+	accumulator.accumulateMetrics(deltaSum(20, points[:5]...))
+	accumulator.accumulateMetrics(deltaSum(30, points[50:60]...))
+	assert.Len(t, accumulator.series, 10)
+	metrics := deltaSum(40, points[:5]...)
+	assert.Equal(t, 5, accumulator.accumulateMetrics(metrics))
+	for _, point := range cumulativePoints(t, metrics) {
+		assert.EqualValues(t, 1, point.value)
+	}
+}
+
+func TestRetentionLimitsStalenessMarkers(t *testing.T) {
+	tracker := newStalenessTracker()
+	tracker.setMaxSeries(10)
+
+	assert.Equal(t, 90, tracker.markMetrics(doubleGauge(labelValues("sda", 100)...)))
+	assert.Len(t, tracker.previous, 10)
+
+	tracker = newStalenessTracker()
+	tracker.setMaxSeries(10)
+	rms := pdata.NewResourceMetricsSlice()
+	rms.Resize(1)
+	rms.At(0).InstrumentationLibraryMetrics().Resize(1)
+	doubleGauge(labelValues("sdb", 100)...).MoveAndAppendTo(rms.At(0).InstrumentationLibraryMetrics().At(0).Metrics())
+	assert.Equal(t, 90, tracker.markResourceMetrics(rms))
+	assert.Len(t, tracker.previous, 10)
+}
+
+func TestRetentionLimitsEvictedSeriesView(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		return cumulativeSum(10, labelValues("a", 100)...), nil
+	}
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape, WithStartTimeTracking(1))),
+		WithTickerChannel(tickerCh),
+		WithRetentionLimits(RetentionLimits{MaxTrackedSeries: 10}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	for tick := 1; tick <= 2; tick++ {
+		tickerCh <- time.Now()
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) == tick }, time.Second, time.Millisecond)
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	obsreporttest.CheckScraperEvictedSeriesView(t, "receiver", "scraper", 180)
+}
+
+func TestRetentionLimitsInvalid(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithRetentionLimits(RetentionLimits{MaxTrackedSeries: -1}),
+	)
+	assert.EqualError(t, err, "retention limits must not be negative: {MaxErrorLength:0 MaxTrackedSeries:-1}")
+}
@@ -0,0 +1,306 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WithSharedScheduler schedules the deadlines of the scrapers of the receiver
+// on a scheduler shared by all the receivers of the process using this
+// option, instead of on a goroutine and timer of its own. The shared
+// scheduler waits for the earliest deadline of all of them with a single
+// timer, on a single goroutine, and the scrapers due on a deadline are
+// scraped on a goroutine only started for the deadline. The deadlines
+// passing while the receiver is still scraping are skipped, as they are
+// otherwise.
+//
+// The scheduler is created when the first receiver using it starts, and
+// stopped once the last one has shut down. WithSharedScheduler can not be used
+// with WithTickerChannel.
+func WithSharedScheduler() ScraperControllerOption {
+	return func(o *controller) {
+		o.sharedScheduler = true
+	}
+}
+
+var (
+	// theSharedScheduler is the scheduler shared by the receivers using
+	// WithSharedScheduler while any of them is running.
+	theSharedScheduler   *sharedScheduler
+	theSharedSchedulerMu sync.Mutex
+)
+
+// acquireSharedScheduler returns the shared scheduler, starting it if it is
+// not running, and counts the caller as one of its users.
+func acquireSharedScheduler() *sharedScheduler {
+	theSharedSchedulerMu.Lock()
+	defer theSharedSchedulerMu.Unlock()
+	if theSharedScheduler == nil {
+		theSharedScheduler = newSharedScheduler(systemClock{})
+	}
+	theSharedScheduler.users++
+	return theSharedScheduler
+}
+
+// releaseSharedScheduler stops the shared scheduler if the caller was its
+// last user.
+func releaseSharedScheduler(s *sharedScheduler) {
+	theSharedSchedulerMu.Lock()
+	defer theSharedSchedulerMu.Unlock()
+	s.users--
+	if s.users > 0 {
+		return
+	}
+	s.stop()
+	theSharedScheduler = nil
+}
+
+// sharedScheduler calls the fire function of each of its entries every
+// interval of the entry, waiting for the earliest of their deadlines with a
+// single timer.
+type sharedScheduler struct {
+	// users is guarded by theSharedSchedulerMu.
+	users int
+	// clock is the system clock, unless replaced by tests.
+	clock Clock
+
+	mu      sync.Mutex
+	entries scheduledEntries
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// scheduledEntry is an entry of a sharedScheduler, or the schedule of a
+// scraper in the deadlines of a receiver.
+type scheduledEntry struct {
+	interval time.Duration
+	// next is the next deadline of the entry.
+	next time.Time
+	// fire is called with the deadline when it is reached, with the mutex of
+	// the scheduler held, so it must not block. It is not set for the
+	// schedules of the scrapers, whose deadlines the receiver waits for.
+	fire func(time.Time)
+	// index is the index of the entry in its heap, or -1 once it has been
+	// removed.
+	index int
+}
+
+func newSharedScheduler(clock Clock) *sharedScheduler {
+	s := &sharedScheduler{
+		clock:   clock,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// schedule adds an entry firing every interval, starting one interval from
+// now.
+func (s *sharedScheduler) schedule(interval time.Duration, fire func(time.Time)) *scheduledEntry {
+	e := &scheduledEntry{interval: interval, next: s.clock.Now().Add(interval), fire: fire}
+	s.mu.Lock()
+	heap.Push(&s.entries, e)
+	s.mu.Unlock()
+
+	// the new entry may be due before the one the scheduler waits for.
+	s.wakeUp()
+	return e
+}
+
+// reschedule moves the next deadline of the entry, unless it was cancelled.
+// The entry still fires every interval from then on, until rescheduled again.
+func (s *sharedScheduler) reschedule(e *scheduledEntry, next time.Time) {
+	s.mu.Lock()
+	if e.index < 0 {
+		s.mu.Unlock()
+		return
+	}
+	e.next = next
+	heap.Fix(&s.entries, e.index)
+	s.mu.Unlock()
+
+	s.wakeUp()
+}
+
+// wakeUp wakes the scheduler, to wait for the earliest deadline again.
+func (s *sharedScheduler) wakeUp() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// cancel removes the entry, if it was not already removed. The entry is
+// never fired once cancel has returned.
+func (s *sharedScheduler) cancel(e *scheduledEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.index >= 0 {
+		heap.Remove(&s.entries, e.index)
+	}
+}
+
+func (s *sharedScheduler) stop() {
+	close(s.done)
+	<-s.stopped
+}
+
+func (s *sharedScheduler) run() {
+	defer close(s.stopped)
+	for {
+		wait, ok := s.fireDue(s.clock.Now())
+
+		var timer Timer
+		var timerCh <-chan time.Time
+		if ok {
+			timer = s.clock.NewTimer(wait)
+			timerCh = timer.C()
+		}
+		select {
+		case <-timerCh:
+		case <-s.wake:
+		case <-s.done:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+	}
+}
+
+// fireDue fires the entries whose deadline has passed, and returns the time
+// until the next deadline, if there is any entry.
+func (s *sharedScheduler) fireDue(now time.Time) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.entries) > 0 && !s.entries[0].next.After(now) {
+		e := s.entries[0]
+		e.fire(e.next)
+		// the deadlines missed entirely, if the scheduler was late, are
+		// skipped.
+		e.next = e.next.Add(e.interval * (now.Sub(e.next)/e.interval + 1))
+		heap.Fix(&s.entries, 0)
+	}
+	if len(s.entries) == 0 {
+		return 0, false
+	}
+	return s.entries[0].next.Sub(now), true
+}
+
+// scheduledEntries is a heap of entries ordered by deadline.
+type scheduledEntries []*scheduledEntry
+
+func (h scheduledEntries) Len() int           { return len(h) }
+func (h scheduledEntries) Less(i, j int) bool { return h[i].next.Before(h[j].next) }
+func (h scheduledEntries) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *scheduledEntries) Push(x interface{}) {
+	e := x.(*scheduledEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *scheduledEntries) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*h = old[:len(old)-1]
+	return e
+}
+
+// scheduleScraping schedules the earliest deadline of the scrapers of the
+// receiver on the shared scheduler, scraping each deadline on a goroutine of
+// its own, unless the previous one is still being scraped. The entry of the
+// receiver is rescheduled to the earliest deadline of the scrapers after each
+// scrape, and whenever the scrapers change, and otherwise fires every
+// collection interval of the receiver.
+func (sc *controller) scheduleScraping() {
+	host := sc.host
+	ctx, cancel := context.WithCancel(contextWithHost(context.Background(), host))
+	ctx = sc.receiverContext(ctx)
+	sc.cancelScraping = cancel
+	if sc.maxConcurrentScrapes > 1 {
+		sc.dispatcher = newScrapeDispatcher(sc.maxConcurrentScrapes)
+	}
+	sc.underMemoryPressure = false
+	sc.skipUntil = time.Time{}
+	sc.scraping.Store(false)
+
+	sc.scheduler = acquireSharedScheduler()
+	onTick := func(tick time.Time) {
+		if !sc.scraping.CAS(false, true) {
+			return
+		}
+		sc.wg.Add(1)
+		go func() {
+			defer sc.wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					// scraping is left set, so that no tick is scraped
+					// anymore, as when a scrape loop stops.
+					err := sc.scrapeLoopError(panicError(r))
+					sc.logger.Error("Scraping stopped unexpectedly", zap.Error(err))
+					host.ReportFatalError(err)
+					return
+				}
+				sc.scraping.Store(false)
+			}()
+
+			sc.skipUntil = sc.scrapeTick(ctx, tick, sc.skipUntil)
+			sc.skipMissedDeadlines(tick, sc.now())
+			sc.scheduleMu.Lock()
+			sc.notifyRescheduled()
+			sc.scheduleMu.Unlock()
+		}()
+	}
+	scheduler := sc.scheduler
+	entry := scheduler.schedule(sc.collectionInterval, onTick)
+	sc.schedulerEntry = entry
+	sc.setRescheduled(func(next time.Time) { scheduler.reschedule(entry, next) })
+}
+
+// unscheduleScraping removes the ticks of the receiver from the shared
+// scheduler, and waits until the tick being scraped, if any, is done, like
+// waitScraping.
+func (sc *controller) unscheduleScraping(ctx context.Context) {
+	sc.setRescheduled(nil)
+	sc.scheduler.cancel(sc.schedulerEntry)
+	sc.waitScraping(ctx)
+	if sc.dispatcher != nil {
+		sc.dispatcher.stop()
+		sc.dispatcher = nil
+	}
+	releaseSharedScheduler(sc.scheduler)
+	sc.scheduler, sc.schedulerEntry = nil, nil
+}
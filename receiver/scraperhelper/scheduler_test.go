@@ -0,0 +1,258 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+func newSharedSchedulerReceiver(t *testing.T, scrape ScrapeMetrics, sink *consumertest.MetricsSink) *controller {
+	cfg := DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = 5 * time.Millisecond
+	receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithSharedScheduler(),
+	)
+	require.NoError(t, err)
+	return receiver.(*controller)
+}
+
+func currentSharedScheduler() *sharedScheduler {
+	theSharedSchedulerMu.Lock()
+	defer theSharedSchedulerMu.Unlock()
+	return theSharedScheduler
+}
+
+func TestSharedScheduler(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+
+	receivers := make([]*controller, 5)
+	sinks := make([]*consumertest.MetricsSink, len(receivers))
+	for i := range receivers {
+		sinks[i] = new(consumertest.MetricsSink)
+		receivers[i] = newSharedSchedulerReceiver(t, scrape, sinks[i])
+		require.NoError(t, receivers[i].Start(context.Background(), componenttest.NewNopHost()))
+	}
+	scheduler := currentSharedScheduler()
+	require.NotNil(t, scheduler)
+	for _, receiver := range receivers {
+		assert.Same(t, scheduler, receiver.scheduler)
+	}
+	for _, sink := range sinks {
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) >= 3 }, time.Second, time.Millisecond)
+	}
+
+	// shutting down a receiver does not affect the others.
+	require.NoError(t, receivers[0].Shutdown(context.Background()))
+	stopped := len(sinks[0].AllMetrics())
+	for _, sink := range sinks[1:] {
+		scraped := len(sink.AllMetrics())
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) >= scraped+3 }, time.Second, time.Millisecond)
+	}
+	assert.Equal(t, stopped, len(sinks[0].AllMetrics()))
+	assert.Same(t, scheduler, currentSharedScheduler())
+
+	// the scheduler is stopped once its last user is shut down.
+	for _, receiver := range receivers[1:] {
+		require.NoError(t, receiver.Shutdown(context.Background()))
+	}
+	assert.Nil(t, currentSharedScheduler())
+	assertNoGoroutineLeak(t, goroutines)
+
+	// and started again when a receiver is restarted.
+	require.NoError(t, receivers[0].Start(context.Background(), componenttest.NewNopHost()))
+	require.Eventually(t, func() bool { return len(sinks[0].AllMetrics()) > stopped }, time.Second, time.Millisecond)
+	assert.NotSame(t, scheduler, currentSharedScheduler())
+	require.NoError(t, receivers[0].Shutdown(context.Background()))
+	assert.Nil(t, currentSharedScheduler())
+}
+
+func TestSharedSchedulerConcurrentReceivers(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var inFlight, overlaps int32
+			scrape := func(context.Context) (pdata.MetricSlice, error) {
+				if atomic.AddInt32(&inFlight, 1) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+				defer atomic.AddInt32(&inFlight, -1)
+				// scrapes longer than the collection interval skip ticks.
+				time.Sleep(7 * time.Millisecond)
+				return singleMetric(), nil
+			}
+			for run := 0; run < 3; run++ {
+				sink := new(consumertest.MetricsSink)
+				receiver := newSharedSchedulerReceiver(t, scrape, sink)
+				assert.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+				assert.Eventually(t, func() bool { return len(sink.AllMetrics()) >= 2 }, time.Second, time.Millisecond)
+				assert.NoError(t, receiver.Shutdown(context.Background()))
+			}
+			// a receiver never scrapes two ticks at once.
+			assert.Zero(t, atomic.LoadInt32(&overlaps))
+		}()
+	}
+	wg.Wait()
+	assert.Nil(t, currentSharedScheduler())
+	assertNoGoroutineLeak(t, goroutines)
+}
+
+func TestSharedSchedulerFiresInDeadlineOrder(t *testing.T) {
+	start := time.Now()
+	s := &sharedScheduler{}
+	var fired []string
+	add := func(name string, interval time.Duration) *scheduledEntry {
+		e := &scheduledEntry{interval: interval, next: start.Add(interval), fire: func(tick time.Time) {
+			fired = append(fired, fmt.Sprintf("%s@%v", name, tick.Sub(start)))
+		}}
+		heap.Push(&s.entries, e)
+		return e
+	}
+	add("a", 3*time.Second)
+	add("b", 2*time.Second)
+	c := add("c", time.Second)
+
+	wait, ok := s.fireDue(start.Add(2 * time.Second))
+	require.True(t, ok)
+	assert.Equal(t, time.Second, wait)
+	assert.Equal(t, []string{"c@1s", "b@2s"}, fired)
+
+	// a cancelled entry is no longer fired, and the deadlines missed by a late
+	// scheduler are skipped.
+	s.cancel(c)
+	s.cancel(c)
+	fired = nil
+	wait, ok = s.fireDue(start.Add(6500 * time.Millisecond))
+	require.True(t, ok)
+	assert.Equal(t, []string{"a@3s", "b@4s"}, fired)
+	assert.Equal(t, 1500*time.Millisecond, wait)
+}
+
+// stubClock is a Clock whose time is only changed by tests, and whose
+// timers all fire when the test sends on fire, after reporting their delay
+// on timers.
+type stubClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers chan time.Duration
+	fire   chan time.Time
+}
+
+func (c *stubClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *stubClock) set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func (c *stubClock) NewTicker(time.Duration) Ticker {
+	panic("unexpected ticker")
+}
+
+func (c *stubClock) NewTimer(d time.Duration) Timer {
+	c.timers <- d
+	return stubTimer{ch: c.fire}
+}
+
+type stubTimer struct {
+	ch chan time.Time
+}
+
+func (t stubTimer) C() <-chan time.Time { return t.ch }
+func (t stubTimer) Stop()               {}
+
+func TestSharedSchedulerUsesItsClock(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &stubClock{now: start, timers: make(chan time.Duration, 10), fire: make(chan time.Time)}
+	s := newSharedScheduler(clock)
+	defer s.stop()
+
+	fired := make(chan time.Time, 1)
+	s.schedule(time.Minute, func(tick time.Time) { fired <- tick })
+	assert.Equal(t, time.Minute, <-clock.timers)
+
+	// the deadline is reached in the time of the clock, not in wall time.
+	clock.set(start.Add(time.Minute))
+	clock.fire <- start.Add(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), <-fired)
+	assert.Equal(t, time.Minute, <-clock.timers)
+}
+
+func TestSharedSchedulerScrapePanics(t *testing.T) {
+	var scrapes int32
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		atomic.AddInt32(&scrapes, 1)
+		panic("boom")
+	}
+	receiver := newSharedSchedulerReceiver(t, scrape, new(consumertest.MetricsSink))
+	host := componenttest.NewErrorWaitingHost()
+	require.NoError(t, receiver.Start(context.Background(), host))
+
+	received, err := host.WaitForFatalError(time.Second)
+	require.True(t, received)
+	assert.EqualError(t, err, `scraping for receiver "receiver" with scrapers ["scraper"] stopped unexpectedly: panic: boom`)
+
+	// no tick is scraped anymore.
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&scrapes))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Nil(t, currentSharedScheduler())
+}
+
+func TestSharedSchedulerWithTickerChannel(t *testing.T) {
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithSharedScheduler(),
+		WithTickerChannel(make(chan time.Time)),
+	)
+	assert.EqualError(t, err, "WithSharedScheduler can not be used with WithTickerChannel")
+}
+
+func TestSharedSchedulerWithClock(t *testing.T) {
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithSharedScheduler(),
+		WithClock(systemClock{}),
+	)
+	assert.EqualError(t, err, "WithSharedScheduler can not be used with WithClock")
+}
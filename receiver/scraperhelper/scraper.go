@@ -16,21 +16,253 @@ package scraperhelper
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/metadata"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenthelper"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/consumer/pdata"
 	"go.opentelemetry.io/collector/obsreport"
 )
 
-// Scrape metrics.
+// Scrape metrics. The returned metrics are owned by the receiver, and must
+// not be retained, reused or modified by the scraper once returned.
 type ScrapeMetrics func(context.Context) (pdata.MetricSlice, error)
 
-// Scrape resource metrics.
+// Scrape resource metrics. The returned resource metrics are owned by the
+// receiver, and must not be retained, reused or modified by the scraper once
+// returned.
 type ScrapeResourceMetrics func(context.Context) (pdata.ResourceMetricsSlice, error)
 
-// ScraperOption apply changes to internal options.
-type ScraperOption func(*componenthelper.ComponentSettings)
+// ScrapePredicate reports whether a scrape should happen.
+type ScrapePredicate func(context.Context) bool
+
+// ScraperConfig is the configuration a scraper was created from. If it has a
+// Validate() error method, it is called exactly once when the scraper is
+// added to a receiver, and an error fails the creation of the receiver, or
+// the addition of the scraper.
+type ScraperConfig interface {
+	// Timeout returns the time a scrape of the scraper may take, and unless
+	// set with WithInitTimeout, the time its initialization may take. Zero
+	// means the receiver default, or no timeout.
+	Timeout() time.Duration
+
+	// InitialDelay returns the delay of the first scrape of the scraper after
+	// the receiver is started. Zero means the receiver default.
+	InitialDelay() time.Duration
+
+	// ResourceAttributes returns the attributes added to the resource of the
+	// metrics scraped by the scraper, unless already set by the scraper.
+	ResourceAttributes() map[string]string
+
+	// CollectionInterval returns the interval at which the scraper is
+	// scraped. Zero means the collection interval of the receiver.
+	CollectionInterval() time.Duration
+
+	// MaxDataPoints returns the maximum number of data points the scraper
+	// can return from a single scrape, unless set with WithMaxDataPoints.
+	// Zero means no limit.
+	MaxDataPoints() int
+
+	// OnFailure returns what happens when the scraper keeps failing, unless
+	// set with WithFailurePolicy.
+	OnFailure() FailurePolicy
+}
+
+// ScraperSettings defines common settings for a scraper configuration.
+// Scraper configurations can embed this struct to implement ScraperConfig.
+type ScraperSettings struct {
+	TimeoutVal            time.Duration     `mapstructure:"timeout"`
+	InitialDelayVal       time.Duration     `mapstructure:"initial_delay"`
+	ResourceAttributesVal map[string]string `mapstructure:"resource_attributes"`
+	CollectionIntervalVal time.Duration     `mapstructure:"collection_interval"`
+	MaxDataPointsVal      int               `mapstructure:"max_data_points"`
+	OnFailureVal          FailurePolicy     `mapstructure:"on_failure"`
+
+	deprecatedFields []DeprecatedField
+}
+
+// DeprecatedField describes a field of a scraper configuration that was
+// renamed, or is set in a deprecated form, that is still accepted.
+type DeprecatedField struct {
+	// Name is the deprecated name or form of the field.
+	Name string
+	// Replacement is the name or form of the field that replaces it.
+	Replacement string
+	// RemovalVersion is the version in which the deprecated name or form
+	// will no longer be accepted, or empty if its removal is not planned.
+	RemovalVersion string
+}
+
+var _ ScraperConfig = (*ScraperSettings)(nil)
+
+// Timeout returns the time a scrape of the scraper may take.
+func (s *ScraperSettings) Timeout() time.Duration {
+	return s.TimeoutVal
+}
+
+// InitialDelay returns the delay of the first scrape of the scraper.
+func (s *ScraperSettings) InitialDelay() time.Duration {
+	return s.InitialDelayVal
+}
+
+// ResourceAttributes returns the attributes added to the resource of the
+// scraped metrics.
+func (s *ScraperSettings) ResourceAttributes() map[string]string {
+	return s.ResourceAttributesVal
+}
+
+// CollectionInterval returns the interval at which the scraper is scraped.
+func (s *ScraperSettings) CollectionInterval() time.Duration {
+	return s.CollectionIntervalVal
+}
+
+// MaxDataPoints returns the maximum number of data points of a scrape.
+func (s *ScraperSettings) MaxDataPoints() int {
+	return s.MaxDataPointsVal
+}
+
+// OnFailure returns what happens when the scraper keeps failing.
+func (s *ScraperSettings) OnFailure() FailurePolicy {
+	return s.OnFailureVal
+}
+
+// RegisterDeprecatedField records that the configuration uses the deprecated
+// name of a field, typically from the Validate method of the configuration.
+// Receivers log a warning for each deprecated field used by their scrapers
+// once they are started, only once per field regardless of the number of
+// scrapers using it.
+func (s *ScraperSettings) RegisterDeprecatedField(field DeprecatedField) {
+	for _, registered := range s.deprecatedFields {
+		if registered == field {
+			return
+		}
+	}
+	s.deprecatedFields = append(s.deprecatedFields, field)
+}
+
+// registeredDeprecatedFields returns the deprecated fields used by the
+// configuration.
+func (s *ScraperSettings) registeredDeprecatedFields() []DeprecatedField {
+	return s.deprecatedFields
+}
+
+// ScraperOption apply changes to internal options. Options are applied in
+// the order they are given, and each overrides the value set by an earlier
+// application of the same option. WithStart and WithStartEx can not be used
+// together.
+type ScraperOption func(*ScraperComponentSettings)
+
+// StartEx specifies the function invoked when the scraper is being started,
+// with information about the receiver that is starting it.
+type StartEx func(context.Context, component.Host, StartInfo) error
+
+// StartInfo contains information about the receiver that is starting a
+// scraper.
+type StartInfo struct {
+	// ReceiverName is the name of the receiver.
+	ReceiverName string
+	// NextConsumer is the consumer the receiver passes scraped metrics to.
+	NextConsumer consumer.MetricsConsumer
+	// Scrapers contains the scrapers configured on the receiver.
+	Scrapers []ScraperInfo
+	// Settings are the settings the receiver was created with.
+	Settings ReceiverCreateSettings
+}
+
+// ScraperInfo contains information about a scraper configured on a receiver.
+type ScraperInfo struct {
+	// ID identifies the scraper.
+	ID ScraperID
+	// CollectionInterval is the interval at which the scraper is scraped.
+	CollectionInterval time.Duration
+}
+
+// ScraperComponentSettings are the settings a scraper is created with, which
+// are changed by the ScraperOption it is created with. Its fields are only
+// changed by the options of this package, so that the options that can not
+// be used together are always detected: options defined outside of this
+// package apply options of this package.
+type ScraperComponentSettings struct {
+	component    componenthelper.ComponentSettings
+	disabled     bool
+	enabledSet   bool
+	config       ScraperConfig
+	startSet     bool
+	startEx      StartEx
+	lazyInit     bool
+	initTimeout  time.Duration
+	closeTimeout time.Duration
+	initialDelay time.Duration
+	// initialDelaySet is set if the initial delay was set with
+	// WithInitialDelay.
+	initialDelaySet bool
+	constLabels     []label
+	predicate       ScrapePredicate
+	maxDataPoints   int
+	// maxDataPointsSet is set if the limit was set with WithMaxDataPoints.
+	maxDataPointsSet bool
+	failurePolicy    FailurePolicy
+	// failurePolicySet is set if the policy was set with WithFailurePolicy.
+	failurePolicySet bool
+	startTimes       *startTimeTracker
+	staleness        *stalenessTracker
+	forwardEvery     int
+	cumulative       *deltaAccumulator
+	nameFilter       *metricNameFilter
+	nameFilterErr    error
+	resultCache      *resultCache
+	sizeHints        *sizeHintTracker
+	clientAuth       *clientAuthTracker
+	consumeMetadata  metadata.MD
+	// dryRun is set by WithScraperDryRun.
+	dryRun bool
+	// optionErrs are the errors of the options the scraper was created with,
+	// reported when the scraper is validated or started.
+	optionErrs []error
+}
+
+// receiverSettings are passed by a receiver to the scrapers created by this
+// package before starting them.
+type receiverSettings struct {
+	// create are the settings the receiver was created with.
+	create    ReceiverCreateSettings
+	startInfo StartInfo
+	// initTimeout and closeTimeout are the receiver defaults, used if the
+	// scraper does not set its own timeouts.
+	initTimeout  time.Duration
+	closeTimeout time.Duration
+	// defaultTimeout is the timeout of the scrapers without one, set with
+	// WithEnvDefaults.
+	defaultTimeout time.Duration
+	// profilingLabels is set by WithProfilingLabels.
+	profilingLabels bool
+	// retention is set by WithRetentionLimits.
+	retention RetentionLimits
+	// ownershipChecks is set by WithOwnershipChecks.
+	ownershipChecks bool
+	// cpuAccounting is set by WithCPUAccounting.
+	cpuAccounting bool
+	// now is the time source of the receiver, set with WithClock.
+	now func() time.Time
+}
+
+func newScraperSettings(options []ScraperOption) *ScraperComponentSettings {
+	set := &ScraperComponentSettings{component: *componenthelper.DefaultComponentSettings()}
+	for _, op := range options {
+		op(set)
+	}
+	return set
+}
 
 type BaseScraper interface {
 	component.Component
@@ -40,12 +272,24 @@ type BaseScraper interface {
 }
 
 // MetricsScraper is an interface for scrapers that scrape metrics.
+//
+// The metrics returned by Scrape belong to the receiver, which passes them to
+// the next consumer, possibly without copying them: the scraper must not
+// retain, reuse or modify them, nor any of the metrics and data points they
+// hold, once returned. Only the slice itself may be reused by scrapers
+// created with NewMetricsScraperInto, as the receiver copies the metrics out
+// of it. WithOwnershipChecks detects scrapers breaking this contract.
 type MetricsScraper interface {
 	BaseScraper
 	Scrape(context.Context, string) (pdata.MetricSlice, error)
 }
 
 // ResourceMetricsScraper is an interface for scrapers that scrape resource metrics.
+//
+// The resource metrics returned by Scrape belong to the receiver, like the
+// metrics returned by MetricsScraper.Scrape: the scraper must not retain,
+// reuse or modify them once returned, except for the slice itself if the
+// scraper was created with NewResourceMetricsScraperInto.
 type ResourceMetricsScraper interface {
 	BaseScraper
 	Scrape(context.Context, string) (pdata.ResourceMetricsSlice, error)
@@ -55,30 +299,724 @@ var _ BaseScraper = (*baseScraper)(nil)
 
 type baseScraper struct {
 	component.Component
-	name string
+	name          string
+	disabled      bool
+	enabledSet    bool
+	config        ScraperConfig
+	startEx       StartEx
+	lazyInit      bool
+	initTimeout   time.Duration
+	closeTimeout  time.Duration
+	scrapeTimeout time.Duration
+	initialDelay  time.Duration
+	// initialDelaySource is where the initial delay was set, or empty if it
+	// was not set.
+	initialDelaySource ConfigSource
+	resourceAttributes []label
+	constLabels        []label
+	predicate          ScrapePredicate
+	maxDataPoints      int
+	failures           *failureTracker
+	startTimes         *startTimeTracker
+	staleness          *stalenessTracker
+	forwardEvery       int
+	cumulative         *deltaAccumulator
+	nameFilter         *metricNameFilter
+	resultCache        *resultCache
+	sizeHints          *sizeHintTracker
+	clientAuth         *clientAuthTracker
+	consumeMetadata    metadata.MD
+	dryRun             bool
+	// settingsErr is set if the options the scraper was created with are
+	// invalid.
+	settingsErr error
+
+	mu               sync.Mutex
+	host             component.Host
+	receiverSettings receiverSettings
+	// profilingLabels are the pprof labels of the scrapes, if the receiver
+	// sets them.
+	profilingLabels *pprof.LabelSet
+	// ownershipCheck tracks the returned metrics if the receiver enables
+	// ownership checks.
+	ownershipCheck *ownershipCheck
+	initialized    bool
+	// successes counts the successful scrapes, when only every
+	// forwardEvery-th one is forwarded.
+	successes uint64
+	// withheld is set if the metrics of the last scrape were not forwarded.
+	withheld atomic.Bool
+	// scraperCtx caches the observability context of the scrapes.
+	scraperCtx scraperContextCache
+	// costs accumulates the costs of the scrapes, whose CPU time is only
+	// measured if cpuAccounting is set.
+	costs         scrapeCosts
+	cpuAccounting atomic.Bool
+	// stats tracks the outcomes of the scrapes.
+	stats scrapeStats
+}
+
+// scraperContextCache caches the observability context of a scraper, derived
+// from the context the scraper is scraped with, which is the same on every
+// tick of a receiver, so that its tags are not allocated again on every
+// scrape.
+type scraperContextCache struct {
+	mu       sync.Mutex
+	parent   context.Context
+	receiver string
+	ctx      context.Context
+}
+
+func (c *scraperContextCache) get(parent context.Context, receiverName string, b *baseScraper) context.Context {
+	// contexts of types that are not comparable can not be looked up.
+	if !reflect.TypeOf(parent).Comparable() {
+		return b.newScraperContext(parent, receiverName)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx == nil || c.parent != parent || c.receiver != receiverName {
+		c.parent, c.receiver = parent, receiverName
+		c.ctx = b.newScraperContext(parent, receiverName)
+	}
+	return c.ctx
+}
+
+// newScraperContext returns the context the scraper is scraped with.
+func (b *baseScraper) newScraperContext(parent context.Context, receiverName string) context.Context {
+	ctx := contextWithSizeHints(obsreport.ScraperContext(parent, receiverName, b.name), b.sizeHints)
+	ctx = contextWithScraperID(ctx, ScraperID{Receiver: receiverName, Scraper: b.name})
+	return contextWithClientAuth(ctx, b.clientAuth)
 }
 
-func (b baseScraper) Name() string {
+func newBaseScraper(name string, set *ScraperComponentSettings) baseScraper {
+	var settingsErr error
+	if set.startSet && set.startEx != nil {
+		settingsErr = errors.New("only one of WithStart and WithStartEx can be set")
+	} else if set.nameFilterErr != nil {
+		settingsErr = set.nameFilterErr
+	} else if len(set.optionErrs) > 0 {
+		settingsErr = set.optionErrs[0]
+	}
+
+	initTimeout := set.initTimeout
+	maxDataPoints := set.maxDataPoints
+	failurePolicy := set.failurePolicy
+	var initialDelay time.Duration
+	var initialDelaySource ConfigSource
+	if set.initialDelaySet {
+		initialDelay, initialDelaySource = set.initialDelay, ConfigSourceOption
+	}
+	var scrapeTimeout time.Duration
+	var resourceAttributes []label
+	if set.config != nil {
+		resourceAttributes = newLabels(set.config.ResourceAttributes())
+		scrapeTimeout = set.config.Timeout()
+		if initTimeout <= 0 {
+			initTimeout = scrapeTimeout
+		}
+		if initialDelaySource == "" && set.config.InitialDelay() != 0 {
+			initialDelay, initialDelaySource = set.config.InitialDelay(), ConfigSourceScraperConfig
+		}
+		if !set.maxDataPointsSet {
+			maxDataPoints = set.config.MaxDataPoints()
+		}
+		if !set.failurePolicySet {
+			failurePolicy = set.config.OnFailure()
+		}
+	}
+
+	return baseScraper{
+		Component:          componenthelper.NewComponent(&set.component),
+		name:               name,
+		disabled:           set.disabled,
+		enabledSet:         set.enabledSet,
+		config:             set.config,
+		startEx:            set.startEx,
+		lazyInit:           set.lazyInit,
+		initTimeout:        initTimeout,
+		closeTimeout:       set.closeTimeout,
+		scrapeTimeout:      scrapeTimeout,
+		initialDelay:       initialDelay,
+		initialDelaySource: initialDelaySource,
+		resourceAttributes: resourceAttributes,
+		constLabels:        set.constLabels,
+		predicate:          set.predicate,
+		maxDataPoints:      maxDataPoints,
+		failures:           newFailureTracker(failurePolicy),
+		startTimes:         set.startTimes,
+		staleness:          set.staleness,
+		forwardEvery:       set.forwardEvery,
+		cumulative:         set.cumulative,
+		nameFilter:         set.nameFilter,
+		resultCache:        set.resultCache,
+		sizeHints:          set.sizeHints,
+		clientAuth:         set.clientAuth,
+		consumeMetadata:    set.consumeMetadata,
+		dryRun:             set.dryRun,
+		settingsErr:        settingsErr,
+	}
+}
+
+func (b *baseScraper) Name() string {
 	return b.name
 }
 
-// WithStart sets the function that will be called on startup.
+// Start initializes the scraper, or if lazy initialization is enabled,
+// stores the host so that initialization can be done before the first
+// scrape.
+func (b *baseScraper) Start(ctx context.Context, host component.Host) error {
+	if b.settingsErr != nil {
+		return b.settingsErr
+	}
+
+	if b.startTimes != nil {
+		b.startTimes.reset()
+	}
+	if b.staleness != nil {
+		b.staleness.reset()
+	}
+	if b.cumulative != nil {
+		b.cumulative.reset()
+	}
+	if b.failures != nil {
+		b.failures.reset()
+	}
+	if b.resultCache != nil {
+		b.resultCache.reset()
+	}
+	if b.sizeHints != nil {
+		b.sizeHints.reset()
+	}
+	if b.clientAuth != nil {
+		if err := b.clientAuth.lookup(b.name, host); err != nil {
+			return err
+		}
+		ctx = contextWithClientAuth(ctx, b.clientAuth)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.lazyInit {
+		b.host = host
+		return nil
+	}
+	return b.start(ctx, host)
+}
+
+// start calls the configured start function, with the init timeout as
+// deadline. It must be called with the lock held.
+func (b *baseScraper) start(ctx context.Context, host component.Host) error {
+	timeout := b.initTimeout
+	if timeout <= 0 {
+		timeout = b.receiverSettings.initTimeout
+	}
+	if timeout <= 0 {
+		timeout = b.receiverSettings.defaultTimeout
+	}
+
+	if b.startEx != nil {
+		startEx, info := b.startEx, b.receiverSettings.startInfo
+		return callWithDeadline(ctx, timeout, "initialize", func(ctx context.Context) error {
+			return startEx(ctx, host, info)
+		})
+	}
+	return callWithDeadline(ctx, timeout, "initialize", func(ctx context.Context) error {
+		return b.Component.Start(ctx, host)
+	})
+}
+
+// shutdown calls the configured shutdown function, with the close timeout as
+// deadline. It must be called with the lock held.
+func (b *baseScraper) shutdown(ctx context.Context) error {
+	timeout := b.closeTimeout
+	if timeout <= 0 {
+		timeout = b.receiverSettings.closeTimeout
+	}
+	return callWithDeadline(ctx, timeout, "close", b.Component.Shutdown)
+}
+
+// setReceiverSettings stores the settings of the receiver that is about to
+// start the scraper.
+func (b *baseScraper) setReceiverSettings(rs receiverSettings) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.receiverSettings = rs
+	b.setProfilingLabels(rs)
+	b.setRetentionLimits(rs.retention)
+	b.setClock(rs.now)
+	b.cpuAccounting.Store(rs.cpuAccounting)
+	b.ownershipCheck = nil
+	if rs.ownershipChecks {
+		b.ownershipCheck = &ownershipCheck{}
+	}
+}
+
+// ownership returns the ownership check of the scraper, if the receiver
+// enables ownership checks.
+func (b *baseScraper) ownership() *ownershipCheck {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.ownershipCheck
+}
+
+// isEnabled reports whether the scraper was enabled with WithEnabled.
+func (b *baseScraper) isEnabled() bool {
+	return !b.disabled
+}
+
+// configuredInitialDelay returns the initial delay of the scraper, and
+// whether it was set with WithInitialDelay or in the configuration of the
+// scraper, or neither if the source is empty.
+func (b *baseScraper) configuredInitialDelay() (time.Duration, ConfigSource) {
+	return b.initialDelay, b.initialDelaySource
+}
+
+// effectiveConfig sets the settings of the effective configuration that were
+// set on the scraper, rather than on the receiver.
+func (b *baseScraper) effectiveConfig(cfg *EffectiveScraperConfig) {
+	if b.enabledSet {
+		cfg.Enabled.Source = ConfigSourceOption
+	}
+	if b.scrapeTimeout > 0 {
+		cfg.Timeout = &EffectiveDuration{Value: b.scrapeTimeout, Source: ConfigSourceScraperConfig}
+	}
+	if b.initialDelaySource != "" {
+		cfg.InitialDelay = &EffectiveDuration{Value: b.initialDelay, Source: b.initialDelaySource}
+	}
+}
+
+// scraperResourceAttributes returns the attributes added to the resource of
+// the scraped metrics, set in the configuration of the scraper.
+func (b *baseScraper) scraperResourceAttributes() []label {
+	return b.resourceAttributes
+}
+
+// scraperConfig returns the configuration the scraper was created from, if
+// any.
+func (b *baseScraper) scraperConfig() ScraperConfig {
+	return b.config
+}
+
+// validate returns an error if the options or the configuration the scraper
+// was created with are invalid.
+func (b *baseScraper) validate() error {
+	if b.settingsErr != nil {
+		return b.settingsErr
+	}
+	if b.initialDelay < 0 {
+		return errors.New("initial delay must not be negative")
+	}
+	if b.config != nil {
+		if err := validateConfig(b.config); err != nil {
+			return err
+		}
+	}
+	if b.failures != nil {
+		if err := b.failures.policy.Validate(); err != nil {
+			return fmt.Errorf("invalid failure policy: %w", err)
+		}
+	}
+	if b.resultCache != nil && b.resultCache.ttl <= 0 {
+		return errors.New("result cache ttl must be positive")
+	}
+	if b.clientAuth != nil && b.clientAuth.extension == "" {
+		return errors.New("authenticator extension name must not be empty")
+	}
+	return nil
+}
+
+// validateConfig returns an error if the scraper configuration is invalid.
+func validateConfig(config ScraperConfig) error {
+	if config.Timeout() < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if config.InitialDelay() < 0 {
+		return errors.New("initial delay must not be negative")
+	}
+	if config.CollectionInterval() < 0 {
+		return errors.New("collection interval must not be negative")
+	}
+	if config.MaxDataPoints() < 0 {
+		return errors.New("max data points must not be negative")
+	}
+	if err := config.OnFailure().Validate(); err != nil {
+		return fmt.Errorf("invalid on_failure: %w", err)
+	}
+	for key := range config.ResourceAttributes() {
+		if key == "" {
+			return errors.New("resource attribute keys must not be empty")
+		}
+	}
+	if cfg, ok := config.(interface{ Validate() error }); ok {
+		return cfg.Validate()
+	}
+	return nil
+}
+
+// Shutdown closes the scraper. Lazily initialized scrapers that were never
+// initialized are not closed.
+func (b *baseScraper) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.lazyInit {
+		if !b.initialized {
+			return nil
+		}
+		b.initialized = false
+	}
+	return b.shutdown(ctx)
+}
+
+// initialize calls the start function of a lazily initialized scraper if it
+// has not yet been successfully initialized.
+func (b *baseScraper) initialize(ctx context.Context) error {
+	if !b.lazyInit {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.initialized {
+		return nil
+	}
+	if err := b.start(ctx, b.host); err != nil {
+		return err
+	}
+	b.initialized = true
+	return nil
+}
+
+// WithStart sets the function that will be called on startup. Only one of
+// WithStart and WithStartEx can be set.
 func WithStart(start componenthelper.Start) ScraperOption {
-	return func(s *componenthelper.ComponentSettings) {
-		s.Start = start
+	return func(s *ScraperComponentSettings) {
+		s.component.Start = start
+		s.startSet = true
+	}
+}
+
+// WithStartEx sets the function that will be called on startup, which in
+// addition to the host also receives information about the receiver that is
+// starting the scraper, such as its next consumer. Only one of WithStart and
+// WithStartEx can be set.
+func WithStartEx(start StartEx) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.startEx = start
 	}
 }
 
 // WithShutdown sets the function that will be called on shutdown.
 func WithShutdown(shutdown componenthelper.Shutdown) ScraperOption {
-	return func(s *componenthelper.ComponentSettings) {
-		s.Shutdown = shutdown
+	return func(s *ScraperComponentSettings) {
+		s.component.Shutdown = shutdown
 	}
 }
 
+// WithInitTimeout sets a deadline on the context passed to the start function
+// of the scraper. If the start function fails once the deadline expired, the
+// initialization of the scraper fails with a timeout error. This overrides
+// the timeout of the scraper configuration set with WithConfig, and the
+// receiver default set with WithDefaultInitTimeout.
+//
+// The receiver waits for the start function to return, which it should do
+// once the context expires, as it is not otherwise interrupted.
+func WithInitTimeout(timeout time.Duration) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.initTimeout = timeout
+	}
+}
+
+// WithInitialDelay delays the first scrape of the scraper after the receiver
+// is started. The scraper is first scraped on the first tick after the delay
+// has elapsed. This overrides the initial delay of the scraper configuration
+// set with WithConfig, and the receiver default set with
+// WithDefaultInitialDelay.
+func WithInitialDelay(delay time.Duration) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.initialDelay = delay
+		s.initialDelaySet = true
+	}
+}
+
+// WithCloseTimeout sets a deadline on the context passed to the shutdown
+// function of the scraper. If the shutdown function fails once the deadline
+// expired, a timeout error is returned and the receiver continues shutting
+// down. This overrides the receiver default set with WithDefaultCloseTimeout.
+//
+// The receiver waits for the shutdown function to return, which it should do
+// once the context expires, as it is not otherwise interrupted.
+func WithCloseTimeout(timeout time.Duration) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.closeTimeout = timeout
+	}
+}
+
+// callWithDeadline calls fn with a context that expires after the timeout,
+// and waits for fn to return, so that nothing is left running once it
+// returns. fn is expected to return once the context expires; if it then
+// returns an error, the error reports the timeout. A timeout of zero or less
+// means no timeout.
+func callWithDeadline(ctx context.Context, timeout time.Duration, op string, fn func(context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s timed out after %v: %w", op, timeout, ctx.Err())
+	}
+	return err
+}
+
+// WithConfig sets the configuration the scraper was created from, and applies
+// its settings to the scraper.
+func WithConfig(cfg ScraperConfig) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.config = cfg
+	}
+}
+
+// WithEnabled sets whether the scraper is enabled, typically from the
+// configuration of the scraper. Disabled scrapers added to a receiver are
+// recorded as disabled, and are never initialized, scraped or closed. By
+// default, scrapers are enabled.
+func WithEnabled(enabled bool) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.disabled = !enabled
+		s.enabledSet = true
+	}
+}
+
+// WithLazyInit defers calling the start function of the scraper until just
+// before its first scrape, so that the receiver does not block on it during
+// startup. A failed initialization is reported as a failed scrape and is
+// retried on the next scrape.
+func WithLazyInit() ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.lazyInit = true
+	}
+}
+
+// WithConstLabels adds the given labels to every data point scraped by the
+// scraper. Labels already set by the scraper are not overwritten.
+func WithConstLabels(labels map[string]string) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.constLabels = newLabels(labels)
+	}
+}
+
+// WithScrapePredicate sets a predicate that is evaluated before every scrape.
+// If it returns false, the scrape is skipped without an error, and recorded as
+// a filtered scrape. The predicate is called on every tick, so it must be
+// fast. If the predicate panics, the scrape is skipped and reported as failed.
+func WithScrapePredicate(predicate ScrapePredicate) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.predicate = predicate
+	}
+}
+
+// WithMaxDataPoints limits the number of data points the scraper can return
+// from a single scrape. Metrics are kept in order for as long as all their data
+// points fit within the limit, and the remaining metrics are dropped and
+// reported with a partial scrape error. A limit of zero or less means no
+// limit. This overrides the limit of the scraper configuration set with
+// WithConfig.
+func WithMaxDataPoints(maxDataPoints int) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.maxDataPoints = maxDataPoints
+		s.maxDataPointsSet = true
+	}
+}
+
+// WithStartTimeTracking fills in the start timestamp of the cumulative data
+// points returned by the scraper that do not have one, with the time the
+// series of the data point was first observed. A series is identified by its
+// metric name, labels and, for resource metrics scrapers, its resource.
+//
+// Series that are not returned for more than maxMissedScrapes consecutive
+// scrapes are forgotten, and are considered new if they are seen again. All
+// the series are forgotten when the scraper is restarted.
+func WithStartTimeTracking(maxMissedScrapes int) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.startTimes = newStartTimeTracker(maxMissedScrapes)
+	}
+}
+
+// WithDeltaToCumulative converts the delta IntSum and DoubleSum metrics
+// returned by the scraper to cumulative sums, by keeping the running total of
+// each series across scrapes. The start timestamp of a series is the start
+// timestamp of its first delta, or if it has none, its timestamp. A negative
+// delta of a monotonic sum is treated as a reset of the source, and restarts
+// the running total from zero.
+//
+// Series that are not returned for more than maxMissedScrapes consecutive
+// scrapes are forgotten, and start a new running total if they are seen
+// again. All the running totals are forgotten when the scraper is restarted.
+func WithDeltaToCumulative(maxMissedScrapes int) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.cumulative = newDeltaAccumulator(maxMissedScrapes)
+	}
+}
+
+// WithStalenessMarkers emits a staleness marker, a data point with the
+// StaleNaN value, for each series that was returned by the previous
+// successful scrape but is missing from the current one, so that downstream
+// systems stop reporting its last value. Only DoubleGauge and DoubleSum
+// series are tracked. Only the series of the previous successful scrape are
+// remembered, and they are forgotten when the scraper is restarted.
+func WithStalenessMarkers() ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.staleness = newStalenessTracker()
+	}
+}
+
+// WithForwardEvery only forwards the metrics of every n-th successful scrape
+// to the next consumer, while still scraping on every tick, for instance to
+// keep the state of the scraper up to date. Scrapes that are not forwarded
+// are recorded as such, and return no metrics. Failed scrapes are always
+// forwarded, and are not counted. By default, every scrape is forwarded.
+func WithForwardEvery(n int) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.forwardEvery = n
+	}
+}
+
+// WithMetricNameFilter drops the metrics returned by the scraper whose name
+// does not match any of the include regular expressions, or matches any of
+// the exclude regular expressions, right after they are scraped. Exclude patterns take precedence over include patterns, and if no
+// include pattern is given, all the metrics that are not excluded are kept.
+// The number of dropped data points is recorded as filtered metric points.
+// Invalid patterns make NewScraperControllerReceiver fail.
+func WithMetricNameFilter(include, exclude []string) ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.nameFilter, s.nameFilterErr = newMetricNameFilter(include, exclude)
+	}
+}
+
+// forward reports whether the metrics of a scrape that completed with the
+// given error should be forwarded to the next consumer.
+func (b *baseScraper) forward(ctx context.Context, err error) bool {
+	if b.forwardEvery <= 1 || err != nil {
+		b.withheld.Store(false)
+		return true
+	}
+
+	b.mu.Lock()
+	b.successes++
+	forward := b.successes%uint64(b.forwardEvery) == 0
+	b.mu.Unlock()
+
+	b.withheld.Store(!forward)
+	if !forward {
+		obsreport.RecordMetricsScrapeNotForwarded(ctx)
+	}
+	return forward
+}
+
+// allowScrape reports whether the failure policy of the scraper allows
+// scraping it on the given tick.
+func (b *baseScraper) allowScrape(tick time.Time) bool {
+	return b.failures == nil || b.failures.allow(tick)
+}
+
+// failureSchedule returns the time until which the failure policy of the
+// scraper skips its scrapes, and whether it disabled the scraper.
+func (b *baseScraper) failureSchedule() (time.Time, bool) {
+	if b.failures == nil {
+		return time.Time{}, false
+	}
+	return b.failures.schedule()
+}
+
+// recordScrape records the outcome of a scrape for the failure policy and
+// the statistics of the scraper.
+func (b *baseScraper) recordScrape(err error) {
+	b.stats.record(err, b.now())
+	if b.failures != nil {
+		b.failures.record(err)
+	}
+}
+
+// persistentFailure returns the mode of the failure policy, the number of
+// consecutive failed scrapes and the last error, once the scraper failed the
+// maximum number of times allowed by its policy. It is only returned once.
+func (b *baseScraper) persistentFailure() (FailureMode, int, error) {
+	if b.failures == nil {
+		return "", 0, nil
+	}
+	failures, err := b.failures.persistentFailure()
+	return b.failures.policy.Mode, failures, err
+}
+
+// lastScrapeSucceeded reports whether the last scrape of the scraper did not
+// fail, or failed with a partial scrape error.
+func (b *baseScraper) lastScrapeSucceeded() bool {
+	return b.stats.lastSuccess.Load() != 0 && b.stats.consecutiveFailures.Load() == 0
+}
+
+// disabledByFailures reports whether the scraper is no longer scraped after
+// failing the maximum number of consecutive times allowed by its
+// FailureDisableAfter failure policy.
+func (b *baseScraper) disabledByFailures() bool {
+	if b.failures == nil {
+		return false
+	}
+	_, disabled := b.failures.schedule()
+	return disabled
+}
+
+// lastScrapeWithheld reports whether the metrics of the last scrape were not
+// forwarded because of WithForwardEvery.
+func (b *baseScraper) lastScrapeWithheld() bool {
+	return b.withheld.Load()
+}
+
+// withScrapeTimeout returns a context that expires once the scrape timeout
+// set in the configuration of the scraper, or else the receiver default set
+// with WithEnvDefaults, expires, if any.
+func (b *baseScraper) withScrapeTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := b.scrapeTimeout
+	if timeout <= 0 {
+		b.mu.Lock()
+		timeout = b.receiverSettings.defaultTimeout
+		b.mu.Unlock()
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// hasScrapeTimeout reports whether the timeout of the scraper was set in its
+// configuration.
+func (b *baseScraper) hasScrapeTimeout() bool {
+	return b.scrapeTimeout > 0
+}
+
+// shouldScrape evaluates the scrape predicate, if any.
+func (b *baseScraper) shouldScrape(ctx context.Context) (ok bool, err error) {
+	if b.predicate == nil {
+		return true, nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			ok, err = false, fmt.Errorf("scrape predicate panicked: %v", r)
+		}
+	}()
+	return b.predicate(ctx), nil
+}
+
 type metricsScraper struct {
 	baseScraper
 	ScrapeMetrics
+	// buffered is set if the scraper scrapes into a slice reused across
+	// scrapes.
+	buffered bool
 }
 
 var _ MetricsScraper = (*metricsScraper)(nil)
@@ -91,33 +1029,121 @@ func NewMetricsScraper(
 	scrape ScrapeMetrics,
 	options ...ScraperOption,
 ) MetricsScraper {
-	set := componenthelper.DefaultComponentSettings()
-	for _, op := range options {
-		op(set)
-	}
-
 	ms := &metricsScraper{
-		baseScraper: baseScraper{
-			Component: componenthelper.NewComponent(set),
-			name:      name,
-		},
+		baseScraper:   newBaseScraper(name, newScraperSettings(options)),
 		ScrapeMetrics: scrape,
 	}
 
 	return ms
 }
 
-func (ms metricsScraper) Scrape(ctx context.Context, receiverName string) (pdata.MetricSlice, error) {
-	ctx = obsreport.ScraperContext(ctx, receiverName, ms.Name())
-	ctx = obsreport.StartMetricsScrapeOp(ctx, receiverName, ms.Name())
-	metrics, err := ms.ScrapeMetrics(ctx)
-	obsreport.EndMetricsScrapeOp(ctx, metrics.Len(), err)
+// validate returns an error if the scraper was created without a scrape
+// function, or with invalid options.
+func (ms *metricsScraper) validate() error {
+	if ms.ScrapeMetrics == nil {
+		return errors.New("scrape function must not be nil")
+	}
+	return ms.baseScraper.validate()
+}
+
+func (ms *metricsScraper) reusesBuffer() bool {
+	return ms.buffered
+}
+
+func (ms *metricsScraper) Scrape(ctx context.Context, receiverName string) (pdata.MetricSlice, error) {
+	ctx = ms.scraperCtx.get(ctx, receiverName, &ms.baseScraper)
+	ok, predicateErr := ms.shouldScrape(ctx)
+	if !ok && predicateErr == nil {
+		ms.withheld.Store(false)
+		obsreport.RecordMetricsScrapeFiltered(ctx)
+		return pdata.NewMetricSlice(), nil
+	}
+	observe := observed(ctx)
+	if observe {
+		ctx = obsreport.StartMetricsScrapeOp(ctx, receiverName, ms.Name())
+	}
+	var metrics pdata.MetricSlice
+	err := predicateErr
+	if err == nil {
+		ms.timeScrape(ctx, func() { metrics, err = ms.scrape(ctx) })
+	} else {
+		metrics = pdata.NewMetricSlice()
+	}
+	if observe {
+		obsreport.EndMetricsScrapeOp(ctx, metrics.Len(), err)
+	}
+	ms.recordScrape(err)
+	if !ms.forward(ctx, err) {
+		return pdata.NewMetricSlice(), nil
+	}
+	if c := ms.ownership(); c != nil {
+		c.returnedMetrics(metrics)
+	}
+	return metrics, err
+}
+
+func (ms *metricsScraper) scrape(ctx context.Context) (pdata.MetricSlice, error) {
+	if err := ms.initialize(ctx); err != nil {
+		return pdata.NewMetricSlice(), err
+	}
+	// the cached metrics are those returned by the scrape function, and are
+	// processed like the metrics of a scrape.
+	var metrics pdata.MetricSlice
+	var err error
+	cached := false
+	if ms.resultCache != nil {
+		metrics, cached = ms.resultCache.getMetrics()
+	}
+	if !cached {
+		scrapeCtx, cancel := ms.withScrapeTimeout(ctx)
+		if labels, ok := ms.scrapeLabels(); ok {
+			pprof.Do(scrapeCtx, labels, func(ctx context.Context) { metrics, err = ms.ScrapeMetrics(ctx) })
+		} else {
+			metrics, err = ms.ScrapeMetrics(scrapeCtx)
+		}
+		cancel()
+		if ms.sizeHints != nil && (err == nil || consumererror.IsPartialScrapeError(err)) {
+			ms.sizeHints.record(metricsSizeHint(metrics))
+		}
+		if ms.resultCache != nil && err == nil {
+			ms.resultCache.putMetrics(metrics)
+		}
+	}
+	if ms.nameFilter != nil {
+		if filtered := ms.nameFilter.filterMetrics(metrics); filtered > 0 {
+			obsreport.RecordMetricsScrapeFilteredPoints(ctx, filtered)
+		}
+	}
+	evicted := 0
+	if ms.cumulative != nil {
+		evicted += ms.cumulative.accumulateMetrics(metrics)
+	}
+	if ms.startTimes != nil {
+		evicted += ms.startTimes.adjustMetrics(metrics)
+	}
+	if ms.staleness != nil && err == nil {
+		evicted += ms.staleness.markMetrics(metrics)
+	}
+	if evicted > 0 {
+		obsreport.RecordMetricsScrapeEvictedSeries(ctx, evicted)
+	}
+	if len(ms.constLabels) > 0 {
+		addMetricsLabels(metrics, ms.constLabels)
+	}
+	if ms.maxDataPoints > 0 {
+		t := &truncation{remaining: ms.maxDataPoints}
+		t.truncateMetrics(metrics)
+		err = combineTruncationError(err, t.err(ms.maxDataPoints))
+	}
 	return metrics, err
 }
 
 type resourceMetricsScraper struct {
 	baseScraper
 	ScrapeResourceMetrics
+	// buffered is set if the scraper scrapes into a slice reused across
+	// scrapes.
+	buffered bool
 }
 
 var _ ResourceMetricsScraper = (*resourceMetricsScraper)(nil)
@@ -130,30 +1156,130 @@ func NewResourceMetricsScraper(
 	scrape ScrapeResourceMetrics,
 	options ...ScraperOption,
 ) ResourceMetricsScraper {
-	set := componenthelper.DefaultComponentSettings()
-	for _, op := range options {
-		op(set)
-	}
-
 	rms := &resourceMetricsScraper{
-		baseScraper: baseScraper{
-			Component: componenthelper.NewComponent(set),
-			name:      name,
-		},
+		baseScraper:           newBaseScraper(name, newScraperSettings(options)),
 		ScrapeResourceMetrics: scrape,
 	}
 
 	return rms
 }
 
-func (rms resourceMetricsScraper) Scrape(ctx context.Context, receiverName string) (pdata.ResourceMetricsSlice, error) {
-	ctx = obsreport.ScraperContext(ctx, receiverName, rms.Name())
-	ctx = obsreport.StartMetricsScrapeOp(ctx, receiverName, rms.Name())
-	resourceMetrics, err := rms.ScrapeResourceMetrics(ctx)
-	obsreport.EndMetricsScrapeOp(ctx, metricCount(resourceMetrics), err)
+// validate returns an error if the scraper was created without a scrape
+// function, or with invalid options.
+func (rms *resourceMetricsScraper) validate() error {
+	if rms.ScrapeResourceMetrics == nil {
+		return errors.New("scrape function must not be nil")
+	}
+	return rms.baseScraper.validate()
+}
+
+func (rms *resourceMetricsScraper) reusesBuffer() bool {
+	return rms.buffered
+}
+
+func (rms *resourceMetricsScraper) Scrape(ctx context.Context, receiverName string) (pdata.ResourceMetricsSlice, error) {
+	ctx = rms.scraperCtx.get(ctx, receiverName, &rms.baseScraper)
+	ok, predicateErr := rms.shouldScrape(ctx)
+	if !ok && predicateErr == nil {
+		rms.withheld.Store(false)
+		obsreport.RecordMetricsScrapeFiltered(ctx)
+		return pdata.NewResourceMetricsSlice(), nil
+	}
+	observe := observed(ctx)
+	if observe {
+		ctx = obsreport.StartMetricsScrapeOp(ctx, receiverName, rms.Name())
+	}
+	var resourceMetrics pdata.ResourceMetricsSlice
+	err := predicateErr
+	if err == nil {
+		rms.timeScrape(ctx, func() { resourceMetrics, err = rms.scrape(ctx) })
+	} else {
+		resourceMetrics = pdata.NewResourceMetricsSlice()
+	}
+	if observe {
+		obsreport.EndMetricsScrapeOp(ctx, metricCount(resourceMetrics), err)
+	}
+	rms.recordScrape(err)
+	if !rms.forward(ctx, err) {
+		return pdata.NewResourceMetricsSlice(), nil
+	}
+	if c := rms.ownership(); c != nil {
+		c.returnedResourceMetrics(resourceMetrics)
+	}
 	return resourceMetrics, err
 }
 
+func (rms *resourceMetricsScraper) scrape(ctx context.Context) (pdata.ResourceMetricsSlice, error) {
+	if err := rms.initialize(ctx); err != nil {
+		return pdata.NewResourceMetricsSlice(), err
+	}
+	// the cached resource metrics are those returned by the scrape function,
+	// and are processed like the resource metrics of a scrape.
+	var resourceMetrics pdata.ResourceMetricsSlice
+	var err error
+	cached := false
+	if rms.resultCache != nil {
+		resourceMetrics, cached = rms.resultCache.getResourceMetrics()
+	}
+	if !cached {
+		scrapeCtx, cancel := rms.withScrapeTimeout(ctx)
+		if labels, ok := rms.scrapeLabels(); ok {
+			pprof.Do(scrapeCtx, labels, func(ctx context.Context) { resourceMetrics, err = rms.ScrapeResourceMetrics(ctx) })
+		} else {
+			resourceMetrics, err = rms.ScrapeResourceMetrics(scrapeCtx)
+		}
+		cancel()
+		if rms.sizeHints != nil && (err == nil || consumererror.IsPartialScrapeError(err)) {
+			rms.sizeHints.record(resourceMetricsSizeHint(resourceMetrics))
+		}
+		if rms.resultCache != nil && err == nil {
+			rms.resultCache.putResourceMetrics(resourceMetrics)
+		}
+	}
+	if rms.nameFilter != nil {
+		if filtered := rms.nameFilter.filterResourceMetrics(resourceMetrics); filtered > 0 {
+			obsreport.RecordMetricsScrapeFilteredPoints(ctx, filtered)
+		}
+	}
+	evicted := 0
+	if rms.cumulative != nil {
+		evicted += rms.cumulative.accumulateResourceMetrics(resourceMetrics)
+	}
+	if rms.startTimes != nil {
+		evicted += rms.startTimes.adjustResourceMetrics(resourceMetrics)
+	}
+	if rms.staleness != nil && err == nil {
+		evicted += rms.staleness.markResourceMetrics(resourceMetrics)
+	}
+	if evicted > 0 {
+		obsreport.RecordMetricsScrapeEvictedSeries(ctx, evicted)
+	}
+	if len(rms.constLabels) > 0 {
+		addResourceMetricsLabels(resourceMetrics, rms.constLabels)
+	}
+	if len(rms.resourceAttributes) > 0 {
+		insertResourceAttributes(resourceMetrics, rms.resourceAttributes)
+	}
+	if rms.maxDataPoints > 0 {
+		t := &truncation{remaining: rms.maxDataPoints}
+		t.truncateResourceMetrics(resourceMetrics)
+		err = combineTruncationError(err, t.err(rms.maxDataPoints))
+	}
+	return resourceMetrics, err
+}
+
+// combineTruncationError combines the error returned by a scrape with the
+// error reporting that the scraped metrics were truncated.
+func combineTruncationError(scrapeErr, truncationErr error) error {
+	if truncationErr == nil {
+		return scrapeErr
+	}
+	if scrapeErr == nil {
+		return truncationErr
+	}
+	return CombineScrapeErrors([]error{scrapeErr, truncationErr})
+}
+
 func metricCount(resourceMetrics pdata.ResourceMetricsSlice) int {
 	count := 0
 
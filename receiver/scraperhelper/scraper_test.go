@@ -0,0 +1,364 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+type testScraperConfig struct {
+	ScraperSettings `mapstructure:",squash"`
+	Path            string `mapstructure:"path"`
+}
+
+func readTestScraperConfigs(t *testing.T) map[string]*testScraperConfig {
+	testFile := path.Join(".", "testdata", "scraper_config.yaml")
+	v := configtest.NewViperFromYamlFile(t, testFile)
+
+	cfgs := map[string]*testScraperConfig{}
+	require.NoErrorf(t, v.UnmarshalExact(&cfgs), "unable to unmarshal yaml from file %v", testFile)
+	return cfgs
+}
+
+func TestScraperSettingsUnmarshal(t *testing.T) {
+	assert.Equal(t, map[string]*testScraperConfig{
+		"default":       {Path: "/proc"},
+		"timeout":       {ScraperSettings: ScraperSettings{TimeoutVal: 10 * time.Second}, Path: "/proc"},
+		"initial_delay": {ScraperSettings: ScraperSettings{InitialDelayVal: 25 * time.Second}, Path: "/proc"},
+		"resource_attributes": {
+			ScraperSettings: ScraperSettings{ResourceAttributesVal: map[string]string{"storage.tier": "ssd", "host.name": "disk-host"}},
+			Path:            "/proc",
+		},
+		"collection_interval": {ScraperSettings: ScraperSettings{CollectionIntervalVal: 5 * time.Minute}, Path: "/proc"},
+		"max_data_points":     {ScraperSettings: ScraperSettings{MaxDataPointsVal: 8}, Path: "/proc"},
+		"on_failure": {
+			ScraperSettings: ScraperSettings{OnFailureVal: FailurePolicy{Mode: FailureBackoff, InitialBackoff: time.Minute, MaxBackoff: 10 * time.Minute}},
+			Path:            "/proc",
+		},
+	}, readTestScraperConfigs(t))
+}
+
+func TestScraperConfigTimeout(t *testing.T) {
+	cfg := readTestScraperConfigs(t)["timeout"]
+
+	var deadline time.Duration
+	scrape := func(ctx context.Context) (pdata.MetricSlice, error) {
+		d, ok := ctx.Deadline()
+		require.True(t, ok)
+		deadline = time.Until(d)
+		return singleMetric(), nil
+	}
+	var initDeadline time.Duration
+	start := func(ctx context.Context, _ component.Host) error {
+		d, ok := ctx.Deadline()
+		require.True(t, ok)
+		initDeadline = time.Until(d)
+		return nil
+	}
+
+	scraper := NewMetricsScraper("scraper", scrape, WithConfig(cfg), WithStart(start))
+	require.NoError(t, scraper.Start(context.Background(), componenttest.NewNopHost()))
+	_, err := scraper.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	assert.True(t, deadline > 9*time.Second && deadline <= 10*time.Second, deadline)
+	assert.True(t, initDeadline > 9*time.Second && initDeadline <= 10*time.Second, initDeadline)
+
+	// the init timeout option takes precedence.
+	scraper = NewMetricsScraper("scraper", scrape, WithConfig(cfg), WithStart(start), WithInitTimeout(time.Minute))
+	require.NoError(t, scraper.Start(context.Background(), componenttest.NewNopHost()))
+	assert.True(t, initDeadline > 59*time.Second && initDeadline <= time.Minute, initDeadline)
+}
+
+func TestScraperConfigNoTimeout(t *testing.T) {
+	scrape := func(ctx context.Context) (pdata.MetricSlice, error) {
+		_, ok := ctx.Deadline()
+		assert.False(t, ok)
+		return singleMetric(), nil
+	}
+
+	scraper := NewMetricsScraper("scraper", scrape, WithConfig(readTestScraperConfigs(t)["default"]))
+	require.NoError(t, scraper.Start(context.Background(), componenttest.NewNopHost()))
+	_, err := scraper.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+}
+
+func TestScraperConfigNegativeTimeout(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	cfg := &testScraperConfig{ScraperSettings: ScraperSettings{TimeoutVal: -time.Second}}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape, WithConfig(cfg))),
+	)
+	assert.EqualError(t, err, `invalid scraper "scraper": timeout must not be negative`)
+}
+
+func TestInitialDelay(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	scraped := map[string]bool{}
+	newScraper := func(name string, options ...ScraperOption) MetricsScraper {
+		return NewMetricsScraper(name, func(context.Context) (pdata.MetricSlice, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			scraped[name] = true
+			return singleMetric(), nil
+		}, options...)
+	}
+	cfg := readTestScraperConfigs(t)["initial_delay"]
+
+	core, logs := observer.New(zap.WarnLevel)
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	defaultCfg.CollectionInterval = 20 * time.Second
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.New(core),
+		sink,
+		AddMetricsScraper(newScraper("default")),
+		AddMetricsScraper(newScraper("option", WithInitialDelay(time.Second), WithConfig(cfg))),
+		AddMetricsScraper(newScraper("config", WithConfig(cfg))),
+		AddMetricsScraper(newScraper("no_delay", WithInitialDelay(0), WithConfig(cfg))),
+		WithDefaultInitialDelay(10*time.Second),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "config", logs.All()[0].ContextMap()["scraper"])
+	receiver.(*controller).now = func() time.Time { return start }
+
+	scrapedOn := func(tick time.Duration) []string {
+		mu.Lock()
+		scraped = map[string]bool{}
+		mu.Unlock()
+		tickerCh <- start.Add(tick)
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+		sink.Reset()
+
+		mu.Lock()
+		defer mu.Unlock()
+		var names []string
+		for _, name := range []string{"default", "option", "config", "no_delay"} {
+			if scraped[name] {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	assert.Equal(t, []string{"option", "no_delay"}, scrapedOn(5*time.Second))
+	assert.Equal(t, []string{"default", "option", "no_delay"}, scrapedOn(20*time.Second))
+	assert.Equal(t, []string{"default", "option", "config", "no_delay"}, scrapedOn(40*time.Second))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+func TestNegativeInitialDelay(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("option", scrape, WithInitialDelay(-time.Second))),
+		AddMetricsScraper(NewMetricsScraper("config", scrape, WithConfig(&ScraperSettings{InitialDelayVal: -time.Second}))),
+		WithDefaultInitialDelay(-time.Second),
+	)
+	assert.EqualError(t, err, `receiver "receiver" has 3 configuration errors:
+  - initial delay must not be negative
+  - invalid scraper "config": initial delay must not be negative
+  - invalid scraper "option": initial delay must not be negative`)
+}
+
+func TestScraperConfigResourceAttributes(t *testing.T) {
+	cfg := readTestScraperConfigs(t)["resource_attributes"]
+	scrapeResource := func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		rms := singleResourceMetric()
+		rms.At(0).Resource().Attributes().InsertString("host.name", "set-by-scraper")
+		return rms, nil
+	}
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddResourceMetricsScraper(NewResourceMetricsScraper("resource", scrapeResource, WithConfig(cfg))),
+		AddMetricsScraper(NewMetricsScraper("metrics", scrape)),
+		AddMetricsScraper(NewMetricsScraper("disk", scrape, WithConfig(cfg))),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	rms := sink.AllMetrics()[0].ResourceMetrics()
+	require.Equal(t, 3, rms.Len())
+	// attributes set by the scraper are not overwritten.
+	assert.Equal(t, map[string]string{"storage.tier": "ssd", "host.name": "set-by-scraper"}, resourceAttributes(rms.At(0).Resource()))
+	assert.Equal(t, map[string]string{}, resourceAttributes(rms.At(1).Resource()))
+	assert.Equal(t, 1, rms.At(1).InstrumentationLibraryMetrics().At(0).Metrics().Len())
+	assert.Equal(t, map[string]string{"storage.tier": "ssd", "host.name": "disk-host"}, resourceAttributes(rms.At(2).Resource()))
+	assert.Equal(t, 1, rms.At(2).InstrumentationLibraryMetrics().At(0).Metrics().Len())
+}
+
+func TestScraperConfigEmptyResourceAttributeKey(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	cfg := &ScraperSettings{ResourceAttributesVal: map[string]string{"": "value"}}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape, WithConfig(cfg))),
+	)
+	assert.EqualError(t, err, `invalid scraper "scraper": resource attribute keys must not be empty`)
+}
+
+func resourceAttributes(resource pdata.Resource) map[string]string {
+	attrs := map[string]string{}
+	resource.Attributes().ForEach(func(k string, v pdata.AttributeValue) {
+		attrs[k] = v.StringVal()
+	})
+	return attrs
+}
+
+type renamedScraperConfig struct {
+	ScraperSettings `mapstructure:",squash"`
+	Path            string `mapstructure:"path"`
+	Directory       string `mapstructure:"directory"`
+	Mode            string `mapstructure:"mode"`
+	Format          string `mapstructure:"format"`
+}
+
+func (cfg *renamedScraperConfig) Validate() error {
+	if cfg.Directory != "" {
+		cfg.Path = cfg.Directory
+		cfg.RegisterDeprecatedField(DeprecatedField{Name: "directory", Replacement: "path", RemovalVersion: "v0.20.0"})
+	}
+	if cfg.Mode != "" {
+		cfg.Format = cfg.Mode
+		cfg.RegisterDeprecatedField(DeprecatedField{Name: "mode", Replacement: "format", RemovalVersion: "v0.21.0"})
+	}
+	return nil
+}
+
+func TestDeprecatedFields(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	newScraper := func(name string, cfg *renamedScraperConfig) MetricsScraper {
+		return NewMetricsScraper(name, scrape, WithConfig(cfg))
+	}
+
+	core, logs := observer.New(zap.WarnLevel)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.New(core),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(newScraper("scraper1", &renamedScraperConfig{Directory: "/proc"})),
+		AddMetricsScraper(newScraper("scraper2", &renamedScraperConfig{Directory: "/sys", Mode: "raw"})),
+		AddMetricsScraper(newScraper("scraper3", &renamedScraperConfig{Path: "/dev"})),
+		AddMetricsScraper(newScraper("scraper4", &renamedScraperConfig{Directory: "/tmp"})),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 0, logs.Len())
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.Equal(t, 2, logs.Len())
+	for _, log := range logs.All() {
+		assert.Equal(t, "Scraper configuration field is deprecated", log.Message)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"field":           "directory",
+		"replacement":     "path",
+		"removal_version": "v0.20.0",
+		"scrapers":        []interface{}{"scraper1", "scraper2", "scraper4"},
+	}, logs.All()[0].ContextMap())
+	assert.Equal(t, map[string]interface{}{
+		"field":           "mode",
+		"replacement":     "format",
+		"removal_version": "v0.21.0",
+		"scrapers":        []interface{}{"scraper2"},
+	}, logs.All()[1].ContextMap())
+
+	// fields that were already logged are not logged again
+	require.NoError(t, receiver.(ScraperManager).AddScraper(context.Background(), newScraper("scraper5", &renamedScraperConfig{Directory: "/var", Mode: "raw"})))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Equal(t, 2, logs.Len())
+}
+
+func TestScraperConfigMaxDataPoints(t *testing.T) {
+	cfg := readTestScraperConfigs(t)["max_data_points"]
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		return gaugesWithDataPoints(3, 4, 2, 5, 1), nil
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("config", scrape, WithConfig(cfg))),
+		// the option takes precedence over the configuration.
+		AddMetricsScraper(NewMetricsScraper("option", scrape, WithConfig(cfg), WithMaxDataPoints(0))),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	metricCount, dataPointCount := sink.AllMetrics()[0].MetricAndDataPointCount()
+	assert.Equal(t, 2+5, metricCount)
+	assert.Equal(t, 7+15, dataPointCount)
+
+	_, err = NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("negative", scrape, WithConfig(&ScraperSettings{MaxDataPointsVal: -1}))),
+	)
+	assert.EqualError(t, err, `invalid scraper "negative": max data points must not be negative`)
+}
@@ -15,11 +15,20 @@
 package scraperhelper
 
 import (
+	"container/heap"
 	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/metadata"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenterror"
@@ -50,9 +59,38 @@ func DefaultScraperControllerSettings(cfgType configmodels.Type) ScraperControll
 	}
 }
 
-// ScraperControllerOption apply changes to internal options.
+// ScraperControllerOption apply changes to internal options. Options are
+// only applied while creating a receiver, the scrapers of a created receiver
+// are changed with AddScraper and RemoveScraper instead.
+//
+// Options are applied in the order they are given. An option setting a value,
+// such as WithMaxConcurrentScrapes, overrides the value set by an earlier
+// application of the same option, while the Add options, WithStateListener,
+// WithResourceAttributes and WithMetricsTransformer add to those of earlier
+// applications. Options that can not be used together, such as
+// WithSharedScheduler and WithTickerChannel, make creating the receiver fail
+// with an error naming both options, whatever their order.
 type ScraperControllerOption func(*controller)
 
+// applyOptions applies the options the receiver is created with. Applying
+// options to a receiver that was already created panics.
+func (sc *controller) applyOptions(options []ScraperControllerOption) {
+	sc.checkCreating()
+	for _, op := range options {
+		op(sc)
+	}
+	sc.created = true
+}
+
+// checkCreating panics if the receiver was already created, so that stale
+// options changing the scrapers of the receiver are not applied to it
+// afterwards, bypassing the checks of AddScraper.
+func (sc *controller) checkCreating() {
+	if sc.created {
+		panic(fmt.Sprintf("option applied to receiver %q after it was created", sc.name))
+	}
+}
+
 // AddMetricsScraper configures the provided scrape function to be called
 // with the specified options, and at the specified collection interval.
 //
@@ -60,7 +98,17 @@ type ScraperControllerOption func(*controller)
 // will be passed to the next consumer.
 func AddMetricsScraper(scraper MetricsScraper) ScraperControllerOption {
 	return func(o *controller) {
+		o.checkCreating()
+		if scraper == nil {
+			o.optionErrs = append(o.optionErrs, errors.New("metrics scraper must not be nil"))
+			return
+		}
+		if !scraperEnabled(scraper) {
+			o.disabledScrapers = append(o.disabledScrapers, DisabledScraper{ID: o.scraperID(scraper.Name()), Reason: DisabledByConfig})
+			return
+		}
 		o.metricsScrapers.scrapers = append(o.metricsScrapers.scrapers, scraper)
+		o.scrapers = append(o.scrapers, scraper)
 	}
 }
 
@@ -72,7 +120,17 @@ func AddMetricsScraper(scraper MetricsScraper) ScraperControllerOption {
 // metrics will be passed to the next consumer.
 func AddResourceMetricsScraper(scraper ResourceMetricsScraper) ScraperControllerOption {
 	return func(o *controller) {
+		o.checkCreating()
+		if scraper == nil {
+			o.optionErrs = append(o.optionErrs, errors.New("resource metrics scraper must not be nil"))
+			return
+		}
+		if !scraperEnabled(scraper) {
+			o.disabledScrapers = append(o.disabledScrapers, DisabledScraper{ID: o.scraperID(scraper.Name()), Reason: DisabledByConfig})
+			return
+		}
 		o.resourceMetricScrapers = append(o.resourceMetricScrapers, scraper)
+		o.scrapers = append(o.scrapers, scraper)
 	}
 }
 
@@ -85,192 +143,2189 @@ func WithTickerChannel(tickerCh <-chan time.Time) ScraperControllerOption {
 	}
 }
 
+// WithParallelInit initializes the scrapers concurrently when the receiver is
+// started, running at most maxConcurrency initializations at a time. A
+// maxConcurrency of zero or less places no limit on the number of concurrent
+// initializations.
+//
+// If any scraper fails to initialize, the scrapers that were successfully
+// initialized are closed, and Start returns an error naming all the scrapers
+// that failed.
+func WithParallelInit(maxConcurrency int) ScraperControllerOption {
+	return func(o *controller) {
+		o.parallelInit = true
+		o.maxInitConcurrency = maxConcurrency
+	}
+}
+
+// WithDefaultInitTimeout sets a deadline on the context passed to the start
+// function of each scraper, unless the scraper sets its own timeout with
+// WithInitTimeout.
+func WithDefaultInitTimeout(timeout time.Duration) ScraperControllerOption {
+	return func(o *controller) {
+		o.initTimeout = timeout
+	}
+}
+
+// WithDefaultInitialDelay delays the first scrape of each scraper after the
+// receiver is started, unless the scraper sets its own initial delay with
+// WithInitialDelay or in its configuration. A scraper is first scraped on the
+// first tick after its initial delay has elapsed.
+func WithDefaultInitialDelay(delay time.Duration) ScraperControllerOption {
+	return func(o *controller) {
+		o.defaultInitialDelay = delay
+	}
+}
+
+// WithZeroIntervalDisables makes a collection interval of zero disable all
+// the scrapers of the receiver, instead of being rejected. The scrapers are
+// then reported by DisabledScrapers with the DisabledByZeroInterval reason,
+// and are never initialized, scraped or closed.
+func WithZeroIntervalDisables() ScraperControllerOption {
+	return func(o *controller) {
+		o.zeroIntervalDisables = true
+	}
+}
+
+// WithDefaultCloseTimeout sets a deadline on the context passed to the
+// shutdown function of each scraper, unless the scraper sets its own timeout
+// with WithCloseTimeout.
+func WithDefaultCloseTimeout(timeout time.Duration) ScraperControllerOption {
+	return func(o *controller) {
+		o.closeTimeout = timeout
+	}
+}
+
+// WithAllowEmptyScrapers allows creating a receiver without any scrapers,
+// for instance when scrapers are added after the receiver is created using
+// AddScraper. By default, creating a receiver without scrapers fails.
+func WithAllowEmptyScrapers() ScraperControllerOption {
+	return func(o *controller) {
+		o.allowEmptyScrapers = true
+	}
+}
+
+// WithStateListener registers a function that will be called every time the
+// receiver transitions to a new lifecycle State. The listener is not called
+// while any internal locks are held, so it may call back into the receiver,
+// including UpdateConfig, Start and Shutdown. The listeners are called with
+// the transitions in order, once Start or Shutdown released their locks, so
+// the receiver may already be in a later State.
+func WithStateListener(listener func(State)) ScraperControllerOption {
+	return func(o *controller) {
+		o.stateListeners = append(o.stateListeners, listener)
+	}
+}
+
+// WithCapabilities declares the capabilities of the receiver. Options that
+// modify the scraped metrics, such as adding resource attributes, declare
+// that the receiver mutates the data regardless of the capabilities set here.
+func WithCapabilities(capabilities component.ProcessorCapabilities) ScraperControllerOption {
+	return func(o *controller) {
+		o.capabilities = capabilities
+	}
+}
+
+// WithResourceAttributes adds the given attributes to the resource of all the
+// scraped metrics before they are passed to the next consumer. Attributes
+// already set by the scrapers are not overwritten. Using this option declares
+// that the receiver mutates the scraped metrics.
+func WithResourceAttributes(attributes map[string]string) ScraperControllerOption {
+	return func(o *controller) {
+		o.resourceAttributes = append(o.resourceAttributes, newLabels(attributes)...)
+		o.mutatesData = true
+	}
+}
+
+// WithMetricNamePrefix prepends the given prefix to the names of all the
+// scraped metrics that do not already start with it. The prefix must start
+// with a letter, and contain only letters, digits, '_', '.' and '-'. Using
+// this option declares that the receiver mutates the scraped metrics. An empty
+// prefix leaves the metric names unchanged.
+func WithMetricNamePrefix(prefix string) ScraperControllerOption {
+	return func(o *controller) {
+		o.metricNamePrefix = prefix
+		o.mutatesData = true
+	}
+}
+
+// TimestampSource specifies the time used by WithUniformTimestamps.
+type TimestampSource int
+
+const (
+	// TimestampTick uses the time of the tick that triggered the scrape.
+	TimestampTick TimestampSource = iota
+	// TimestampScrapeStart uses the time at which the scrape started.
+	TimestampScrapeStart
+)
+
+// WithUniformTimestamps sets the timestamp of every scraped data point to the
+// same time for each scrape, so that data points scraped on the same tick
+// fall in the same aggregation window downstream. The start timestamps of
+// the data points are not changed. Using this option declares that the
+// receiver mutates the scraped metrics.
+func WithUniformTimestamps(source TimestampSource) ScraperControllerOption {
+	return func(o *controller) {
+		o.uniformTimestamps = true
+		o.timestampSource = source
+		o.mutatesData = true
+	}
+}
+
+// WithMaxBatchSize splits the metrics scraped on each tick into batches of at
+// most maxBatchSize data points, each passed to the next consumer separately.
+// Batches are split between resources and between metrics, and the data
+// points of a metric are only split across batches if the metric has more
+// than maxBatchSize data points. A maxBatchSize of zero or less means the
+// metrics are not split.
+func WithMaxBatchSize(maxBatchSize int) ScraperControllerOption {
+	return func(o *controller) {
+		o.maxBatchSize = maxBatchSize
+	}
+}
+
+// WithAsyncConsume passes the scraped metrics to the next consumer from a
+// pool of workers, instead of from the goroutine that scrapes, so that a slow
+// consumer does not delay scraping.
+//
+// The metrics of each scraper are passed to the next consumer separately,
+// and always by the same worker, so that the payloads and batches of a
+// scraper are consumed in the order they were scraped: a batch is consumed,
+// or abandoned, before the next batch of the same scraper is passed to the
+// next consumer. The metrics of different scrapers may be consumed
+// concurrently, and if there are more scrapers than workers, scrapers share
+// workers.
+//
+// Each worker has its own queue of up to queueSize scraped payloads, so up to
+// queueSize*workers payloads are queued in total, and when the queue of a
+// worker is full, payloads are dropped according to the DropPolicy set with
+// WithDropPolicy, and recorded as refused. On shutdown, the queues are
+// drained until the shutdown context expires, after which the payloads being
+// consumed are canceled, Shutdown waits for the next consumer to return, and
+// any remaining payloads are dropped. Both queueSize and workers must be
+// positive.
+func WithAsyncConsume(queueSize int, workers int) ScraperControllerOption {
+	return func(o *controller) {
+		if queueSize < 1 || workers < 1 {
+			o.optionErrs = append(o.optionErrs, fmt.Errorf("async consume queue size and workers must be positive, got %d and %d", queueSize, workers))
+			return
+		}
+		o.asyncConsume = true
+		o.queueSize = queueSize
+		o.consumeWorkers = workers
+	}
+}
+
+// WithDropPolicy sets which payloads are dropped when the queue used by
+// WithAsyncConsume is full. The default is DropNewest.
+func WithDropPolicy(policy DropPolicy) ScraperControllerOption {
+	return func(o *controller) {
+		o.dropPolicy = policy
+	}
+}
+
+// WithDeduplicateSeries drops the data points scraped on a tick that have the
+// same resource, metric name, labels and timestamp as a data point returned
+// by a scraper scraped earlier on the same tick, for instance when scrapers
+// overlap. The order of labels and resource attributes is ignored. Metrics
+// left without data points are dropped. As this requires keeping track of
+// all the scraped series, it is disabled by default.
+func WithDeduplicateSeries() ScraperControllerOption {
+	return func(o *controller) {
+		o.deduplicateSeries = true
+		o.mutatesData = true
+	}
+}
+
+// WithConsumeTimeout sets a deadline on the context passed to the next
+// consumer with the scraped metrics, independently of the time taken to
+// scrape them. The receiver waits for the next consumer to return, which it
+// is expected to do once the context expires; the metrics are then recorded
+// as refused. A timeout of zero or less means no timeout.
+func WithConsumeTimeout(timeout time.Duration) ScraperControllerOption {
+	return func(o *controller) {
+		o.consumeTimeout = timeout
+	}
+}
+
+// IntervalPolicy specifies how a collection interval outside of the range set
+// with WithMinCollectionInterval and WithMaxCollectionInterval is handled.
+type IntervalPolicy int
+
+const (
+	// RejectInterval makes NewScraperControllerReceiver fail.
+	RejectInterval IntervalPolicy = iota
+	// ClampInterval uses the closest allowed interval instead, and logs a
+	// warning.
+	ClampInterval
+)
+
+// WithMinCollectionInterval sets the shortest collection interval the
+// scrapers support, for instance because the scraped source is not updated
+// more often. A shorter configured interval is handled according to the
+// IntervalPolicy set with WithIntervalPolicy.
+func WithMinCollectionInterval(interval time.Duration) ScraperControllerOption {
+	return func(o *controller) {
+		o.minCollectionInterval = interval
+	}
+}
+
+// WithMaxCollectionInterval sets the longest collection interval the
+// scrapers support. A longer configured interval is handled according to the
+// IntervalPolicy set with WithIntervalPolicy.
+func WithMaxCollectionInterval(interval time.Duration) ScraperControllerOption {
+	return func(o *controller) {
+		o.maxCollectionInterval = interval
+	}
+}
+
+// WithIntervalPolicy sets how a collection interval outside of the range set
+// with WithMinCollectionInterval and WithMaxCollectionInterval is handled.
+// The default is RejectInterval.
+func WithIntervalPolicy(policy IntervalPolicy) ScraperControllerOption {
+	return func(o *controller) {
+		o.intervalPolicy = policy
+	}
+}
+
+// MetricsTransformer transforms the metrics scraped on each tick before they
+// are passed to the next consumer.
+type MetricsTransformer func(ctx context.Context, md pdata.Metrics) (pdata.Metrics, error)
+
+// WithMetricsTransformer adds a function that transforms the scraped metrics
+// before they are passed to the next consumer. Transformers are called in the
+// order they were added, after resource attributes have been applied.
+//
+// If a transformer returns an error, the metrics scraped on that tick are
+// dropped, the error is logged, and the scrape is recorded as failed, with
+// all its metrics errored. If the transformed metrics are empty, they are not
+// passed to the next consumer. Using this option declares that the receiver
+// mutates the scraped metrics.
+func WithMetricsTransformer(transformer MetricsTransformer) ScraperControllerOption {
+	return func(o *controller) {
+		o.transformers = append(o.transformers, transformer)
+		o.mutatesData = true
+	}
+}
+
+// The receivers created with NewScraperControllerReceiver are
+// component.MetricsReceiver, that also implement the interfaces below, which
+// callers assert the receivers to. They also implement fmt.Stringer,
+// describing the receiver, its number of scrapers and its State for
+// debugging, and have a GetCapabilities method reporting whether the
+// receiver modifies the scraped metrics before passing them to the next
+// consumer.
+
+// StateReporter is implemented by the receivers reporting their lifecycle.
+type StateReporter interface {
+	// State returns the current lifecycle State of the receiver.
+	State() State
+
+	// Host returns the component.Host the receiver was started with, or
+	// ErrNotStarted if the receiver is not running.
+	Host() (component.Host, error)
+}
+
+// ScraperManager is implemented by the receivers whose scrapers can be
+// changed while they are running.
+type ScraperManager interface {
+	// Name returns the name of the receiver, from the settings it was
+	// created with, which identifies its scrapers along with their names.
+	Name() string
+
+	// AddScraper adds a MetricsScraper, ResourceMetricsScraper or
+	// StreamingScraper to the receiver. If the receiver is running, the
+	// scraper is initialized and will be scraped on the next tick. Disabled
+	// scrapers are only recorded as such. The errors returned match
+	// ErrInvalidScraper, ErrDuplicateScraper, ErrInvalidState or
+	// ErrScraperInitialization with errors.Is.
+	AddScraper(ctx context.Context, scraper BaseScraper) error
+
+	// RemoveScraper removes the scraper with the given ID from the receiver,
+	// waiting for any in-flight scrape to complete. If the receiver is
+	// running, the scraper is closed. If the receiver has no scraper with the
+	// given ID, a *ScraperNotFoundError is returned.
+	RemoveScraper(ctx context.Context, id ScraperID) error
+
+	// UpdateConfig reconfigures the scrapers of the receiver with the given
+	// scraper configurations, keyed by scraper name, without restarting the
+	// scrapers whose configuration did not change. Scrapers are created with
+	// the ScraperFactory set with WithScraperFactory.
+	UpdateConfig(ctx context.Context, newScraperConfigs map[string]ScraperConfig) error
+}
+
+// ScraperInspector is implemented by the receivers describing how their
+// scrapers are configured and scraped.
+type ScraperInspector interface {
+	// DisabledScrapers returns the scrapers that were added to the receiver
+	// but are disabled, in the order they were added.
+	DisabledScrapers() []DisabledScraper
+
+	// EffectiveConfig returns the settings each of the scrapers is scraped
+	// with, once defaults have been applied, and where each setting comes
+	// from.
+	EffectiveConfig() []EffectiveScraperConfig
+
+	// ScrapeCosts returns the cumulative cost of the scrapes of each of the
+	// scrapers created by this package, in the order they were added. The
+	// costs are updated after each scrape.
+	ScrapeCosts() []ScrapeCost
+
+	// NextScrapes returns when each of the scrapers of the receiver is next
+	// scraped, and why, in the order they were added, or nil if the receiver
+	// is not running.
+	NextScrapes() []ScheduledScrape
+
+	// InstanceID returns the collector instance ID added to the scraped
+	// metrics by WithInstanceIDResourceAttribute, as generated when the
+	// receiver was last started.
+	InstanceID() string
+}
+
+// FirstScrapeWaiter is implemented by the receivers that can be waited on
+// until they scraped.
+type FirstScrapeWaiter interface {
+	// WaitForFirstScrape blocks until each of the scrapers, or any of them
+	// with WithWaitForAnyScraper, completed a successful scrape whose metrics
+	// were consumed since the receiver was started, or until the context
+	// expires.
+	WaitForFirstScrape(ctx context.Context) error
+}
+
+// DisabledReason specifies why a scraper is disabled.
+type DisabledReason int
+
+const (
+	// DisabledByConfig means the scraper was disabled with WithEnabled.
+	DisabledByConfig DisabledReason = iota
+	// DisabledByZeroInterval means the collection interval of the receiver is
+	// zero, and WithZeroIntervalDisables is set.
+	DisabledByZeroInterval
+)
+
+// DisabledScraper describes a scraper that was added to a receiver, but is
+// disabled.
+type DisabledScraper struct {
+	// ID identifies the scraper.
+	ID ScraperID
+	// Reason is why the scraper is disabled.
+	Reason DisabledReason
+}
+
 type controller struct {
 	name               string
+	settings           ReceiverCreateSettings
 	logger             *zap.Logger
 	collectionInterval time.Duration
 	nextConsumer       consumer.MetricsConsumer
 
+	// scrapersMu guards the scrapers, and is held for reading while
+	// scraping so that removing a scraper waits for in-flight scrapes.
+	scrapersMu             sync.RWMutex
 	metricsScrapers        *multiMetricScraper
 	resourceMetricScrapers []ResourceMetricsScraper
+	streamingScrapers      []StreamingScraper
+	// scrapers contains all the scrapers in registration order.
+	scrapers []BaseScraper
+	// disabledScrapers contains the scrapers that were added but are
+	// disabled, and are never initialized, scraped or closed.
+	disabledScrapers []DisabledScraper
+	// optionErrs contains the errors found while applying the options.
+	optionErrs []error
+	// created is set once the options of the receiver are applied.
+	created bool
+	// scraperConfigs contains the configurations applied to the scrapers by
+	// UpdateConfig without recreating them.
+	scraperConfigs map[string]ScraperConfig
+	// loggedDeprecatedFields contains the deprecated fields that were logged.
+	loggedDeprecatedFields map[DeprecatedField]bool
+	// schedules contains the schedule of each scraper, and is only replaced
+	// or modified with scrapersMu held for writing.
+	schedules map[string]*scraperSchedule
+	// zeroIntervalDisables is set by WithZeroIntervalDisables, and
+	// scrapingDisabled if the collection interval disables the scrapers.
+	zeroIntervalDisables bool
+	scrapingDisabled     bool
+
+	capabilities component.ProcessorCapabilities
+	// mutatesData is set by options that modify the scraped metrics.
+	mutatesData           bool
+	resourceAttributes    []label
+	metricNamePrefix      string
+	uniformTimestamps     bool
+	maxBatchSize          int
+	batchFlushThreshold   int
+	ownershipChecks       bool
+	cpuAccounting         bool
+	deduplicateSeries     bool
+	consumeTimeout        time.Duration
+	minCollectionInterval time.Duration
+	maxCollectionInterval time.Duration
+	intervalPolicy        IntervalPolicy
+	asyncConsume          bool
+	queueSize             int
+	consumeWorkers        int
+	dropPolicy            DropPolicy
+	timestampSource       TimestampSource
+	transformers          []MetricsTransformer
+	metricsPool           bool
+	memoryPressureCheck   MemoryPressureCheck
+	shutdownDetector      *consumerShutdownDetector
+	probeConsumerShutdown bool
+	maxConcurrentScrapes  int
+	sharedScheduler       bool
+	profilingLabels       bool
+	retention             RetentionLimits
+	// instanceIDKey is set by WithInstanceIDResourceAttribute, and instanceID
+	// is generated on each start.
+	instanceIDKey string
+	instanceID    atomic.String
+	// shutdownBudget is set by WithShutdownBudget.
+	shutdownBudget *ShutdownBudget
+	// firstScrapes tracks the first successful scrapes waited for by
+	// WaitForFirstScrape, of any of the scrapers with waitForAnyScraper.
+	firstScrapes      *firstScrapeTracker
+	waitForAnyScraper bool
+	// dryRun is set by WithDryRun.
+	dryRun bool
+
+	allowEmptyScrapers  bool
+	scraperFactory      ScraperFactory
+	parallelInit        bool
+	maxInitConcurrency  int
+	initTimeout         time.Duration
+	closeTimeout        time.Duration
+	defaultInitialDelay time.Duration
+	// envPrefix, envCollectionInterval and envTimeout are set by
+	// WithEnvDefaults.
+	envPrefix             string
+	envCollectionInterval time.Duration
+	envTimeout            time.Duration
+
+	// reconfigureMu serializes UpdateConfig with Start and Shutdown.
+	reconfigureMu sync.Mutex
 
 	tickerCh <-chan time.Time
+	// clock is the clock set with WithClock, or nil for the system clock.
+	clock Clock
+	// now returns the current time, and is only replaced by tests.
+	now func() time.Time
+	// noScrapeLoop is set by ScrapeCycle, which scrapes from the goroutine
+	// running the cycle instead.
+	noScrapeLoop bool
+	// noSingleScraper is only set by tests, to scrape a single scraper like
+	// several scrapers.
+	noSingleScraper bool
 
-	initialized bool
-	done        chan struct{}
-	terminated  chan struct{}
+	stateMu        sync.Mutex
+	state          State
+	host           component.Host
+	stateListeners []func(State)
+	// pendingStates are the transitions the state listeners have not been
+	// notified of yet, and notifyingStates is set while they are notified.
+	pendingStates   []State
+	notifyingStates bool
+
+	// per-run state, recreated every time the receiver is started
+	scrapersClosed bool
+	startedAt      time.Time
+	done           chan struct{}
+	wg             sync.WaitGroup
+	queue          *consumeQueue
+	// statsRegistries are the StatsRegistry extensions of the host the
+	// receiver registered with.
+	statsRegistries []StatsRegistry
+	// scheduleMu guards the writes of startedAt and of the schedules of the
+	// scrapers, deadlines, the heap of their schedules ordered by deadline,
+	// and rescheduled, which wakes whatever waits for the earliest deadline
+	// when the deadlines change other than by scraping.
+	scheduleMu  sync.Mutex
+	deadlines   scheduledEntries
+	rescheduled func(time.Time)
+	// cancelScraping cancels the context of the scrapes, once the deadline
+	// of the shutdown of the receiver expires.
+	cancelScraping context.CancelFunc
+	// single is the only scraper of the receiver, if it is scraped without
+	// dueScrapers, dueResourceScrapers, multiScraper and results, and is only
+	// replaced with scrapersMu held for writing.
+	single *singleScraper
+	// dueScrapers, dueResourceScrapers, multiScraper, results, payloads and
+	// streamReporter are only used by the scrape loop, and reused on every tick to avoid
+	// allocating them again.
+	dueScrapers         []MetricsScraper
+	dueResourceScrapers []ResourceMetricsScraper
+	multiScraper        multiMetricScraper
+	results             []scrapeResult
+	payloads            []scrapedPayload
+	streamReporter      streamReporter
+	// scheduler and schedulerEntry schedule the ticks if WithSharedScheduler
+	// is set, in which case scraping is set while a tick is scraped, and
+	// skipUntil is the time until which ticks were missed by the last one.
+	scheduler      *sharedScheduler
+	schedulerEntry *scheduledEntry
+	scraping       atomic.Bool
+	skipUntil      time.Time
+	// dispatcher scrapes the scrapers concurrently, if
+	// WithMaxConcurrentScrapes is greater than 1, while the scrape loop runs.
+	dispatcher *scrapeDispatcher
+	// underMemoryPressure is set by the scrape loop while the memory
+	// pressure check reports memory pressure.
+	underMemoryPressure bool
+	// pool holds the metrics passed to the next consumer, if WithMetricsPool
+	// is set and pooling is possible.
+	pool *sync.Pool
+	// scrapeOnStart and skipEmptyPayloads are set if ScrapeOnStartGate and
+	// SkipEmptyPayloadsGate were enabled when the receiver was created.
+	scrapeOnStart     bool
+	skipEmptyPayloads bool
 }
 
+var (
+	_ component.MetricsReceiver = (*controller)(nil)
+	_ StateReporter             = (*controller)(nil)
+	_ ScraperManager            = (*controller)(nil)
+	_ ScraperInspector          = (*controller)(nil)
+	_ FirstScrapeWaiter         = (*controller)(nil)
+)
+
 // NewScraperControllerReceiver creates a Receiver with the configured options, that can control multiple scrapers.
+// It is NewScraperControllerReceiverWithSettings with settings only setting the logger.
+//
+// Scrapers are initialized in the order they were added when the receiver is
+// started, and are closed in the reverse order when the receiver is shutdown,
+// including when a failed initialization has to close the scrapers that were
+// already initialized, which Start does before returning the error.
+//
+// The metrics passed to the next consumer are allocated on every scrape, and
+// are not referenced by the receiver once passed to the next consumer, so
+// they are not cloned even if the next consumer mutates the consumed data.
+// With WithMetricsPool, they are instead taken from a pool and returned to it
+// once consumed, which is only done if the next consumer declares that it
+// does not mutate them, and if WithMetricsTransformer is not used; otherwise
+// they are allocated on every scrape.
+// The slices of the scrapers created with NewMetricsScraperInto and
+// NewResourceMetricsScraperInto are copied into the consumed metrics, so they
+// are never mutated by the next consumer.
+//
+// The feature gates of this package, such as ScrapeOnStartGate, apply to the
+// receiver as they are when it is created.
+//
+// If the next consumer is nil, componenterror.ErrNilNextConsumer is returned.
+// Otherwise, the problems found with the options and scrapers are returned as
+// a *ValidationError, matching with errors.Is ErrInvalidOption,
+// ErrInvalidCollectionInterval, ErrNoScrapers, ErrDuplicateScraper or
+// ErrInvalidScraper for each of the problems found. The errors returned by
+// Start match ErrScraperInitialization, and those returned by Shutdown match
+// ErrScraperClose, ErrConsumeQueueDrain or ErrShutdownPhaseTimeout, as well
+// as the underlying errors.
 func NewScraperControllerReceiver(
 	cfg *ScraperControllerSettings,
 	logger *zap.Logger,
 	nextConsumer consumer.MetricsConsumer,
 	options ...ScraperControllerOption,
-) (component.Receiver, error) {
+) (component.MetricsReceiver, error) {
+	return newScraperControllerReceiver(ReceiverCreateSettings{Logger: logger}, cfg, nextConsumer, options)
+}
+
+func newScraperControllerReceiver(
+	settings ReceiverCreateSettings,
+	cfg *ScraperControllerSettings,
+	nextConsumer consumer.MetricsConsumer,
+	options []ScraperControllerOption,
+) (component.MetricsReceiver, error) {
 	if nextConsumer == nil {
 		return nil, componenterror.ErrNilNextConsumer
 	}
-
-	if cfg.CollectionInterval <= 0 {
-		return nil, errors.New("collection_interval must be a positive duration")
+	if settings.Logger == nil {
+		settings.Logger = zap.NewNop()
 	}
 
 	sc := &controller{
-		name:               cfg.Name(),
-		logger:             logger,
-		collectionInterval: cfg.CollectionInterval,
-		nextConsumer:       nextConsumer,
-		metricsScrapers:    &multiMetricScraper{},
-		done:               make(chan struct{}),
-		terminated:         make(chan struct{}),
+		name:                   cfg.Name(),
+		settings:               settings,
+		logger:                 settings.Logger,
+		collectionInterval:     cfg.CollectionInterval,
+		now:                    time.Now,
+		nextConsumer:           nextConsumer,
+		metricsScrapers:        &multiMetricScraper{},
+		scraperConfigs:         map[string]ScraperConfig{},
+		loggedDeprecatedFields: map[DeprecatedField]bool{},
+		schedules:              map[string]*scraperSchedule{},
+		firstScrapes:           newFirstScrapeTracker(),
+		scrapeOnStart:          IsGateEnabled(ScrapeOnStartGate),
+		skipEmptyPayloads:      IsGateEnabled(SkipEmptyPayloadsGate),
 	}
 
-	for _, op := range options {
-		op(sc)
+	sc.applyOptions(options)
+	if err := sc.validate(); err != nil {
+		return nil, err
 	}
 
-	if len(sc.metricsScrapers.scrapers) > 0 {
-		sc.resourceMetricScrapers = append(sc.resourceMetricScrapers, sc.metricsScrapers)
+	for _, scraper := range sc.scrapers {
+		sc.checkInitialDelay(scraper)
 	}
-
+	sc.logEnvDefaults()
+	sc.pool = sc.newMetricsPool()
+	sc.ownershipChecks = sc.ownershipChecksEnabled()
 	return sc, nil
 }
 
-// Start the receiver, invoked during service start.
-func (sc *controller) Start(ctx context.Context, host component.Host) error {
-	for _, scraper := range sc.resourceMetricScrapers {
-		if err := scraper.Start(ctx, host); err != nil {
-			return err
+// validate checks the options the receiver was created with, and the
+// scrapers that were added with them, returning a *ValidationError listing
+// all the problems found.
+func (sc *controller) validate() error {
+	verr := &ValidationError{Receiver: sc.name}
+	for _, err := range sc.optionErrs {
+		verr.Errors = append(verr.Errors, withKind(ErrInvalidOption, err))
+	}
+	switch {
+	case sc.collectionInterval == 0 && sc.zeroIntervalDisables:
+		sc.disableScraping()
+	case sc.collectionInterval <= 0:
+		verr.Errors = append(verr.Errors, withKind(ErrInvalidCollectionInterval, errors.New("collection_interval must be a positive duration")))
+	default:
+		if err := sc.limitCollectionInterval(); err != nil {
+			verr.Errors = append(verr.Errors, withKind(ErrInvalidCollectionInterval, err))
+		}
+	}
+	if len(sc.scrapers) == 0 && !sc.scrapingDisabled && !sc.allowEmptyScrapers {
+		verr.Errors = append(verr.Errors, withKind(ErrNoScrapers, fmt.Errorf("receiver %q has no scrapers", sc.name)))
+	}
+	if sc.probeConsumerShutdown && sc.shutdownDetector == nil {
+		verr.Errors = append(verr.Errors, withKind(ErrInvalidOption, errors.New("consumer shutdown probe requires consumer shutdown detection")))
+	}
+	for _, attr := range sc.resourceAttributes {
+		if attr.key == "" {
+			verr.Errors = append(verr.Errors, withKind(ErrInvalidOption, errors.New("resource attribute keys must not be empty")))
+			break
+		}
+	}
+	if sc.metricNamePrefix != "" {
+		if err := validateMetricNamePrefix(sc.metricNamePrefix); err != nil {
+			verr.Errors = append(verr.Errors, withKind(ErrInvalidOption, err))
+		}
+	}
+	if sc.defaultInitialDelay < 0 {
+		verr.Errors = append(verr.Errors, withKind(ErrInvalidOption, errors.New("initial delay must not be negative")))
+	}
+	verr.Errors = append(verr.Errors, sc.conflictErrs()...)
+
+	// the errors of the scrapers are listed by scraper name, after the
+	// errors of the receiver.
+	scrapers := append([]BaseScraper(nil), sc.scrapers...)
+	sort.SliceStable(scrapers, func(i, j int) bool {
+		return scrapers[i].Name() < scrapers[j].Name()
+	})
+	for i, scraper := range scrapers {
+		if i > 0 && scrapers[i-1].Name() == scraper.Name() {
+			if i == 1 || scrapers[i-2].Name() != scraper.Name() {
+				verr.Errors = append(verr.Errors, withKind(ErrDuplicateScraper, fmt.Errorf("duplicate scraper name %q", scraper.Name())))
+			}
 		}
+		if err := validateScraper(scraper); err != nil {
+			verr.Errors = append(verr.Errors, err)
+		}
+		if err := sc.scraperConflictErr(scraper); err != nil {
+			verr.Errors = append(verr.Errors, err)
+		}
+	}
+
+	if len(verr.Errors) == 0 {
+		return nil
+	}
+	return verr
+}
+
+// Start the receiver, invoked during service start. The receiver can be
+// started again after it has been shutdown, in which case the scrapers are
+// initialized again.
+func (sc *controller) Start(ctx context.Context, host component.Host) error {
+	defer sc.notifyStates()
+	sc.reconfigureMu.Lock()
+	defer sc.reconfigureMu.Unlock()
+
+	sc.setState(StateStarting)
+
+	sc.scrapersClosed = false
+	if err := sc.initializeScrapers(ctx, host, sc.registeredScrapers()); err != nil {
+		sc.setState(StateStopped)
+		return err
 	}
 
-	sc.initialized = true
-	sc.startScraping()
+	sc.setHost(host)
+	sc.logDryRun()
+	sc.generateInstanceID()
+	sc.registerStats(host)
+	sc.logDeprecatedFields()
+	if sc.asyncConsume {
+		sc.queue = newConsumeQueue(sc.queueSize, sc.consumeWorkers, sc.dropPolicy,
+			func(ctx context.Context, metrics pdata.Metrics) {
+				sc.consumeBatches(ctx, metrics)
+				sc.releaseMetrics(metrics)
+			},
+			func(ctx context.Context, metrics pdata.Metrics, err error) {
+				sc.refuse(ctx, metrics, err)
+				sc.releaseMetrics(metrics)
+			})
+	}
+	sc.done = make(chan struct{})
+	sc.scheduleMu.Lock()
+	sc.startedAt = sc.now()
+	sc.scheduleMu.Unlock()
+	if sc.shutdownDetector != nil {
+		sc.shutdownDetector.reset()
+	}
+	sc.firstScrapes.reset()
+	sc.scrapersMu.Lock()
+	sc.schedules, sc.deadlines = map[string]*scraperSchedule{}, nil
+	sc.updateSchedules()
+	sc.single = nil
+	sc.updateSingleScraper()
+	sc.scrapersMu.Unlock()
+	if sc.scrapingDisabled {
+		sc.logger.Info("Scraping disabled by a collection interval of zero", zap.Int("scrapers", len(sc.DisabledScrapers())))
+	} else if !sc.noScrapeLoop {
+		sc.startScraping()
+	}
+	sc.setState(StateRunning)
 	return nil
 }
 
 // Shutdown the receiver, invoked during service shutdown.
 func (sc *controller) Shutdown(ctx context.Context) error {
-	sc.stopScraping()
+	defer sc.notifyStates()
+	sc.reconfigureMu.Lock()
+	defer sc.reconfigureMu.Unlock()
 
-	// wait until scraping ticker has terminated
-	if sc.initialized {
-		<-sc.terminated
-	}
+	sc.setState(StateStopping)
+	defer sc.setState(StateStopped)
 
-	var errs []error
-	for _, scraper := range sc.resourceMetricScrapers {
-		if err := scraper.Shutdown(ctx); err != nil {
-			errs = append(errs, err)
-		}
+	sc.deregisterStats()
+	defer sc.setHost(nil)
+
+	errs := sc.runShutdownPhases(ctx, sc.shutdownPhases())
+	if sc.ownershipChecks {
+		sc.reportOwnershipViolations(true)
 	}
 	return componenterror.CombineErrors(errs)
 }
 
-// startScraping initiates a ticker that calls Scrape based on the configured
-// collection interval.
-func (sc *controller) startScraping() {
-	go func() {
-		if sc.tickerCh == nil {
-			ticker := time.NewTicker(sc.collectionInterval)
-			defer ticker.Stop()
-
-			sc.tickerCh = ticker.C
-		}
+// GetCapabilities returns the capabilities of the receiver.
+func (sc *controller) GetCapabilities() component.ProcessorCapabilities {
+	capabilities := sc.capabilities
+	capabilities.MutatesConsumedData = capabilities.MutatesConsumedData || sc.mutatesData
+	return capabilities
+}
 
-		for {
-			select {
-			case <-sc.tickerCh:
-				sc.scrapeMetricsAndReport(context.Background())
-			case <-sc.done:
-				sc.terminated <- struct{}{}
-				return
-			}
-		}
-	}()
+// Name returns the name of the receiver.
+func (sc *controller) Name() string {
+	return sc.name
 }
 
-// scrapeMetricsAndReport calls the Scrape function for each of the configured
-// Scrapers, records observability information, and passes the scraped metrics
-// to the next component.
-func (sc *controller) scrapeMetricsAndReport(ctx context.Context) {
-	ctx = obsreport.ReceiverContext(ctx, sc.name, "")
+// String describes the receiver, its number of scrapers and its State.
+func (sc *controller) String() string {
+	return fmt.Sprintf("receiver %q (%d scrapers, %v)", sc.name, len(sc.registeredScrapers()), sc.State())
+}
 
-	metrics := pdata.NewMetrics()
+// State returns the current lifecycle State of the receiver.
+func (sc *controller) State() State {
+	sc.stateMu.Lock()
+	defer sc.stateMu.Unlock()
+	return sc.state
+}
 
-	for _, rms := range sc.resourceMetricScrapers {
-		resourceMetrics, err := rms.Scrape(ctx, sc.name)
-		if err != nil {
-			sc.logger.Error("Error scraping metrics", zap.Error(err))
+// setState transitions the receiver to the given State. The state listeners
+// are notified of the transition by notifyStates.
+func (sc *controller) setState(state State) {
+	sc.stateMu.Lock()
+	defer sc.stateMu.Unlock()
+	sc.state = state
+	if len(sc.stateListeners) > 0 {
+		sc.pendingStates = append(sc.pendingStates, state)
+	}
+}
 
-			if !consumererror.IsPartialScrapeError(err) {
-				continue
+// notifyStates notifies the state listeners of the pending transitions. It
+// must be called without any internal lock held. The transitions made while
+// the listeners are notified, by the listeners themselves or concurrently,
+// are notified by the call already notifying, so that the listeners are
+// called with the transitions in order.
+func (sc *controller) notifyStates() {
+	sc.stateMu.Lock()
+	if sc.notifyingStates {
+		sc.stateMu.Unlock()
+		return
+	}
+	sc.notifyingStates = true
+	for len(sc.pendingStates) > 0 {
+		states := sc.pendingStates
+		sc.pendingStates = nil
+		sc.stateMu.Unlock()
+		for _, state := range states {
+			for _, listener := range sc.stateListeners {
+				listener(state)
 			}
 		}
-		resourceMetrics.MoveAndAppendTo(metrics.ResourceMetrics())
+		sc.stateMu.Lock()
 	}
+	sc.notifyingStates = false
+	sc.stateMu.Unlock()
+}
 
-	_, dataPointCount := metrics.MetricAndDataPointCount()
+// registeredScrapers returns a snapshot of the scrapers in registration order.
+func (sc *controller) registeredScrapers() []BaseScraper {
+	sc.scrapersMu.RLock()
+	defer sc.scrapersMu.RUnlock()
+	return append([]BaseScraper(nil), sc.scrapers...)
+}
 
-	ctx = obsreport.StartMetricsReceiveOp(ctx, sc.name, "")
-	err := sc.nextConsumer.ConsumeMetrics(ctx, metrics)
-	obsreport.EndMetricsReceiveOp(ctx, "", dataPointCount, err)
+// Host returns the component.Host the receiver was started with, or
+// ErrNotStarted if the receiver is not running.
+func (sc *controller) Host() (component.Host, error) {
+	sc.stateMu.Lock()
+	defer sc.stateMu.Unlock()
+	if sc.host == nil {
+		return nil, ErrNotStarted
+	}
+	return sc.host, nil
 }
 
-// stopScraping stops the ticker
-func (sc *controller) stopScraping() {
-	close(sc.done)
+// setHost stores the host the receiver was started with, or clears it when
+// the receiver is shutdown.
+func (sc *controller) setHost(host component.Host) {
+	sc.stateMu.Lock()
+	defer sc.stateMu.Unlock()
+	sc.host = host
 }
 
-var _ ResourceMetricsScraper = (*multiMetricScraper)(nil)
+// initializeScrapers calls Start on each of the given scrapers. If any of the
+// scrapers fail to start, the ones that did start are closed.
+func (sc *controller) initializeScrapers(ctx context.Context, host component.Host, scrapers []BaseScraper) error {
+	if sc.parallelInit {
+		return sc.initializeScrapersInParallel(ctx, host, scrapers)
+	}
 
-type multiMetricScraper struct {
-	scrapers []MetricsScraper
+	rs := sc.receiverSettings(scrapers)
+	for i, scraper := range scrapers {
+		if err := startScraper(ctx, host, scraper, rs); err != nil {
+			sc.closeStartedScrapers(scrapers[:i])
+			return withKind(ErrScraperInitialization, fmt.Errorf("failed to initialize scraper %q: %w", scraper.Name(), err))
+		}
+	}
+	return nil
 }
 
-func (mms *multiMetricScraper) Name() string {
-	return ""
+// receiverSettings returns the settings passed to the scrapers created by
+// this package when they are started.
+func (sc *controller) receiverSettings(scrapers []BaseScraper) receiverSettings {
+	info := StartInfo{
+		ReceiverName: sc.name,
+		NextConsumer: sc.nextConsumer,
+		Scrapers:     make([]ScraperInfo, 0, len(scrapers)),
+		Settings:     sc.settings,
+	}
+	sc.scrapersMu.RLock()
+	for _, scraper := range scrapers {
+		info.Scrapers = append(info.Scrapers, ScraperInfo{
+			ID:                 sc.scraperID(scraper.Name()),
+			CollectionInterval: sc.scraperInterval(scraper),
+		})
+	}
+	sc.scrapersMu.RUnlock()
+	return receiverSettings{
+		create:          sc.settings,
+		startInfo:       info,
+		initTimeout:     sc.initTimeout,
+		closeTimeout:    sc.closeTimeout,
+		defaultTimeout:  sc.envTimeout,
+		profilingLabels: sc.profilingLabels,
+		retention:       sc.retention,
+		ownershipChecks: sc.ownershipChecks,
+		cpuAccounting:   sc.cpuAccounting,
+		now:             sc.now,
+	}
 }
 
-func (mms *multiMetricScraper) Start(ctx context.Context, host component.Host) error {
-	for _, scraper := range mms.scrapers {
-		if err := scraper.Start(ctx, host); err != nil {
-			return err
-		}
+// startScraper starts the scraper, passing the receiver settings to scrapers
+// created by this package.
+func startScraper(ctx context.Context, host component.Host, scraper BaseScraper, rs receiverSettings) error {
+	if s, ok := scraper.(interface{ setReceiverSettings(receiverSettings) }); ok {
+		s.setReceiverSettings(rs)
 	}
-	return nil
+	return scraper.Start(contextWithCreateSettings(ctx, rs.create), host)
 }
 
-func (mms *multiMetricScraper) Shutdown(ctx context.Context) error {
-	var errs []error
-	for _, scraper := range mms.scrapers {
-		if err := scraper.Shutdown(ctx); err != nil {
-			errs = append(errs, err)
-		}
+// scraperEnabled reports whether the scraper is enabled. Only scrapers created
+// by this package can be disabled.
+func scraperEnabled(scraper BaseScraper) bool {
+	s, ok := scraper.(interface{ isEnabled() bool })
+	return !ok || s.isEnabled()
+}
+
+// validateScraper returns an error if a scraper created by this package was
+// created with invalid options.
+func validateScraper(scraper BaseScraper) error {
+	s, ok := scraper.(interface{ validate() error })
+	if !ok {
+		return nil
 	}
-	return componenterror.CombineErrors(errs)
+	if err := s.validate(); err != nil {
+		return withKind(ErrInvalidScraper, fmt.Errorf("invalid scraper %q: %w", scraper.Name(), err))
+	}
+	return nil
 }
 
-func (mms *multiMetricScraper) Scrape(ctx context.Context, receiverName string) (pdata.ResourceMetricsSlice, error) {
-	rms := pdata.NewResourceMetricsSlice()
-	rms.Resize(1)
-	rm := rms.At(0)
-	ilms := rm.InstrumentationLibraryMetrics()
-	ilms.Resize(1)
-	ilm := ilms.At(0)
+// initializeScrapersInParallel calls Start on the given scrapers
+// concurrently, bounded by maxInitConcurrency. If any of the scrapers fail to
+// start, the scrapers not started yet are not started, and the ones that did
+// start are closed.
+func (sc *controller) initializeScrapersInParallel(ctx context.Context, host component.Host, scrapers []BaseScraper) error {
+	limit := sc.maxInitConcurrency
+	if limit <= 0 {
+		limit = len(scrapers)
+	}
+	sem := make(chan struct{}, limit)
 
-	var errs []error
-	for _, scraper := range mms.scrapers {
-		metrics, err := scraper.Scrape(ctx, receiverName)
-		if err != nil {
-			errs = append(errs, err)
-			if !consumererror.IsPartialScrapeError(err) {
-				continue
-			}
+	rs := sc.receiverSettings(scrapers)
+	g, gctx := errgroup.WithContext(ctx)
+	started := make([]bool, len(scrapers))
+	// errs holds the errors of all the scrapers failing before the others
+	// are cancelled, as the group only returns the first one.
+	errs := make([]error, len(scrapers))
+	for i, scraper := range scrapers {
+		select {
+		case sem <- struct{}{}:
+		case <-gctx.Done():
+		}
+		if gctx.Err() != nil {
+			break
 		}
 
-		metrics.MoveAndAppendTo(ilm.Metrics())
+		i, scraper := i, scraper
+		g.Go(func() error {
+			defer func() { <-sem }()
+
+			if err := startScraper(gctx, host, scraper, rs); err != nil {
+				errs[i] = withKind(ErrScraperInitialization, fmt.Errorf("failed to initialize scraper %q: %w", scraper.Name(), err))
+				return errs[i]
+			}
+			started[i] = true
+			return nil
+		})
+	}
+
+	var initErrs []error
+	if g.Wait() != nil {
+		for _, err := range errs {
+			if err != nil {
+				initErrs = append(initErrs, err)
+			}
+		}
+	} else if err := ctx.Err(); err != nil {
+		initErrs = append(initErrs, withKind(ErrScraperInitialization, err))
+	}
+	if len(initErrs) == 0 {
+		return nil
+	}
+
+	var startedScrapers []BaseScraper
+	for i, scraper := range scrapers {
+		if started[i] {
+			startedScrapers = append(startedScrapers, scraper)
+		}
+	}
+	sc.closeStartedScrapers(startedScrapers)
+
+	return componenterror.CombineErrors(initErrs)
+}
+
+// closeStartedScrapers closes the scrapers that were started before the
+// initialization of the receiver failed, in the reverse order, so that they
+// are not closed again when the receiver is shutdown. The errors are only
+// logged, as the initialization error is the one returned by Start.
+func (sc *controller) closeStartedScrapers(scrapers []BaseScraper) {
+	// use a fresh context for the cleanup as the start context may have
+	// been cancelled.
+	for i := len(scrapers) - 1; i >= 0; i-- {
+		scraper := scrapers[i]
+		if err := scraper.Shutdown(contextWithCreateSettings(context.Background(), sc.settings)); err != nil {
+			sc.logger.Error("Error closing scraper after failed initialization", zap.String("scraper", scraper.Name()), zap.Error(err))
+		}
+	}
+	sc.scrapersClosed = true
+}
+
+// closeScrapers calls Shutdown on each of the configured scrapers, in the
+// reverse order to which they were registered, so that scrapers that depend
+// on resources created by previously registered scrapers are closed first.
+// The host, if the receiver was started, is available from the context using
+// HostFromContext. The scrapers are only closed once until the receiver is
+// started again, even if the receiver is shut down several times.
+func (sc *controller) closeScrapers(ctx context.Context) error {
+	if sc.scrapersClosed {
+		return nil
+	}
+	sc.scrapersClosed = true
+	ctx = contextWithCreateSettings(ctx, sc.settings)
+	if host, err := sc.Host(); err == nil {
+		ctx = contextWithHost(ctx, host)
+	}
+
+	scrapers := sc.registeredScrapers()
+	var errs []error
+	for i := len(scrapers) - 1; i >= 0; i-- {
+		if err := scrapers[i].Shutdown(ctx); err != nil {
+			errs = append(errs, withKind(ErrScraperClose, err))
+		}
+	}
+	return componenterror.CombineErrors(errs)
+}
+
+// startScraping starts scraping each scraper every collection interval of its
+// own. All the scrapers of the receiver are scheduled by a single goroutine
+// waiting for the earliest of their deadlines with a single timer, regardless
+// of their number, and the scrapers due on the same deadline are scraped
+// together.
+//
+// The deadlines passing while scraping are skipped, and counted as such in
+// collection intervals of the receiver, so that each scraper is scraped again
+// on its first deadline after the long scrape, instead of back-to-back to
+// catch up. The ticks sent with WithTickerChannel that are missed during a
+// scrape are skipped too, whether they are delivered or not.
+//
+// If scraping stops for any reason other than the receiver being shutdown,
+// the error is reported to the host as a fatal error.
+//
+// With WithSharedScheduler, the deadlines are waited for by the shared
+// scheduler instead. If ScrapeOnStartGate is enabled, the receiver also
+// scrapes as soon as scraping starts.
+func (sc *controller) startScraping() {
+	if sc.sharedScheduler {
+		sc.scheduleScraping()
+		return
+	}
+	done := sc.done
+	host := sc.host
+	ctx, cancel := context.WithCancel(contextWithHost(context.Background(), host))
+	sc.cancelScraping = cancel
+	sc.wg.Add(1)
+	go func() {
+		defer sc.wg.Done()
+
+		if err := sc.runScrapeLoop(ctx, done); err != nil {
+			sc.logger.Error("Scraping stopped unexpectedly", zap.Error(err))
+			host.ReportFatalError(err)
+		}
+	}()
+}
+
+// runScrapeLoop scrapes the scrapers due on every deadline, or on every tick
+// sent with WithTickerChannel, with the given context, until done is closed.
+// An error is returned if the loop exits for any other reason, including a
+// panic.
+func (sc *controller) runScrapeLoop(ctx context.Context, done <-chan struct{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = sc.scrapeLoopError(panicError(r))
+		}
+	}()
+
+	// the context of the receiver is the same on every tick.
+	ctx = sc.receiverContext(ctx)
+	if sc.maxConcurrentScrapes > 1 {
+		sc.dispatcher = newScrapeDispatcher(sc.maxConcurrentScrapes)
+		defer sc.dispatcher.stop()
+	}
+
+	var skipUntil time.Time
+	sc.underMemoryPressure = false
+	if sc.tickerCh != nil {
+		if sc.scrapeOnStart {
+			skipUntil = sc.scrapeTick(ctx, sc.now(), skipUntil)
+		}
+		for {
+			select {
+			case tick, ok := <-sc.tickerCh:
+				if !ok {
+					return sc.scrapeLoopError(errors.New("ticker channel closed"))
+				}
+				skipUntil = sc.scrapeTick(ctx, tick, skipUntil)
+			case <-done:
+				return nil
+			}
+		}
+	}
+
+	clock := sc.clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	rescheduled := make(chan struct{}, 1)
+	sc.setRescheduled(func(time.Time) {
+		select {
+		case rescheduled <- struct{}{}:
+		default:
+		}
+	})
+	defer sc.setRescheduled(nil)
+	for {
+		deadline, ok := sc.nextDeadline()
+		var timer Timer
+		var timerCh <-chan time.Time
+		if ok {
+			timer = clock.NewTimer(deadline.Sub(sc.now()))
+			timerCh = timer.C()
+		}
+		select {
+		case <-timerCh:
+			skipUntil = sc.scrapeTick(ctx, deadline, skipUntil)
+			sc.skipMissedDeadlines(deadline, sc.now())
+		case <-rescheduled:
+		case <-done:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+	}
+}
+
+// scrapeTick scrapes on the given tick, and returns the time until which the
+// ticks received are ticks missed during the scrape. The tick is skipped if it
+// was missed during the previous scrape, which lasted until skipUntil, as it
+// was already counted as skipped, if the collector is under memory
+// pressure, or if the next consumer was shut down.
+func (sc *controller) scrapeTick(ctx context.Context, tick time.Time, skipUntil time.Time) time.Time {
+	if tick.Before(skipUntil) || sc.skipForMemoryPressure(ctx) || sc.skipForConsumerShutdown(ctx) {
+		return skipUntil
+	}
+	sc.scrapeMetricsAndReport(ctx, tick)
+	return sc.skipMissedTicks(ctx, tick)
+}
+
+// skipMissedTicks counts the ticks whose deadline passed while scraping on the
+// given tick as skipped, and returns the time until which the ticks received
+// are such missed ticks, or the zero time if no tick was missed.
+func (sc *controller) skipMissedTicks(ctx context.Context, tick time.Time) time.Time {
+	end := sc.now()
+	missed := int(end.Sub(tick) / sc.collectionInterval)
+	if missed <= 0 {
+		return time.Time{}
+	}
+	obsreport.RecordMetricsScrapeSkippedTicks(ctx, missed)
+	sc.logger.Warn("Skipped ticks missed during a long scrape",
+		zap.Int("skipped_ticks", missed),
+		zap.Duration("scrape_duration", end.Sub(tick)))
+	return end
+}
+
+// panicError returns the error reporting a panic of the scrape loop with the
+// given value, wrapping it if it is an error.
+func panicError(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", r)
+}
+
+func (sc *controller) scrapeLoopError(err error) error {
+	scrapers := sc.registeredScrapers()
+	names := make([]string, 0, len(scrapers))
+	for _, scraper := range scrapers {
+		names = append(names, scraper.Name())
+	}
+	return fmt.Errorf("scraping for receiver %q with scrapers %q stopped unexpectedly: %w", sc.name, names, err)
+}
+
+// scrapeMetricsAndReport calls the Scrape function for each of the configured
+// Scrapers, records observability information, and passes the scraped metrics
+// to the next component.
+func (sc *controller) scrapeMetricsAndReport(ctx context.Context, tick time.Time) {
+	scrapeStart := sc.now()
+
+	ts := tick
+	if sc.timestampSource == TimestampScrapeStart {
+		ts = scrapeStart
+	}
+
+	payloads := sc.scrapeAll(ctx, tick)
+	if sc.ownershipChecks {
+		sc.reportOwnershipViolations(false)
+	}
+	sc.scrapeStreams(ctx, tick, pdata.TimestampUnixNano(ts.UnixNano()))
+	sc.scheduleFailedScrapers(tick)
+	sc.handlePersistentFailures(ctx)
+	if sc.deduplicateSeries {
+		dedup := newSeriesDeduplicator()
+		duplicates := 0
+		for _, payload := range payloads {
+			duplicates += dedup.deduplicate(payload.metrics)
+		}
+		if duplicates > 0 {
+			sc.logger.Debug("Dropped duplicate data points", zap.Int("data_points", duplicates))
+		}
+	}
+
+	for i, payload := range payloads {
+		err := sc.report(ctx, payload, pdata.TimestampUnixNano(ts.UnixNano()))
+		if err != nil && len(payloads) > 1 && payload.scrapers != nil {
+			sc.logger.Error("Error consuming flushed metrics",
+				zap.Int("flush", i+1),
+				zap.Int("flushes", len(payloads)),
+				zap.Strings("scrapers", payload.scrapers),
+				zap.Error(err))
+		}
+	}
+	if sc.ownershipChecks {
+		sc.sealOwnershipChecks()
+	}
+}
+
+// report processes the metrics of a scraped payload, and passes them to the
+// next consumer, or queues them if WithAsyncConsume is set. The error of the
+// transformers, or of the next consumer if the metrics were passed to it, is
+// returned.
+func (sc *controller) report(ctx context.Context, payload scrapedPayload, ts pdata.TimestampUnixNano) error {
+	metrics := payload.metrics
+	if sc.uniformTimestamps {
+		sc.setTimestamps(metrics, ts)
+	}
+	sc.addResourceAttributes(metrics)
+	sc.addInstanceID(metrics)
+	sc.addMetricNamePrefix(metrics)
+
+	transformed, err := sc.transform(ctx, metrics)
+	if err != nil {
+		sc.recordTransformError(ctx, payload, metrics, err)
+		sc.releaseMetrics(metrics)
+		return err
+	}
+	metrics = transformed
+	if metricCount, _ := metrics.MetricAndDataPointCount(); metricCount == 0 && (payload.withheld || len(sc.transformers) > 0 || sc.skipEmptyPayloads) {
+		// the scrapes succeeded although there is nothing to consume.
+		sc.firstScrapes.record(payload.firstScrapes)
+		sc.releaseMetrics(metrics)
+		return nil
+	}
+
+	if sc.dryRun || payload.dryRun {
+		sc.dropDryRun(ctx, payload, metrics)
+		return nil
+	}
+	ctx = contextWithConsumeMetadata(ctx, payload.metadata)
+	ctx = contextWithFirstScrapes(ctx, payload.firstScrapes)
+	if sc.queue != nil {
+		sc.queue.enqueue(ctx, payload.scraper, metrics)
+		return nil
+	}
+	err = sc.consumeBatches(ctx, metrics)
+	sc.releaseMetrics(metrics)
+	return err
+}
+
+// consumeBatches passes the metrics to the next consumer, split in batches if
+// WithMaxBatchSize is set, and returns the errors of the next consumer.
+func (sc *controller) consumeBatches(ctx context.Context, metrics pdata.Metrics) error {
+	if sc.maxBatchSize <= 0 {
+		err := sc.consume(ctx, metrics)
+		if err == nil {
+			sc.recordFirstScrapes(ctx)
+		}
+		return err
+	}
+
+	batches := splitMetrics(metrics, sc.maxBatchSize)
+	var errs []error
+	for i, batch := range batches {
+		if err := sc.consume(ctx, batch); err != nil {
+			errs = append(errs, fmt.Errorf("failed to consume batch %d of %d: %w", i+1, len(batches), err))
+		}
+	}
+	if len(errs) == 0 {
+		sc.recordFirstScrapes(ctx)
+		return nil
+	}
+	err := componenterror.CombineErrors(errs)
+	sc.logger.Error("Error consuming scraped metrics", zap.Error(err))
+	return err
+}
+
+// refuse records that the metrics were not passed to the next consumer.
+func (sc *controller) refuse(ctx context.Context, metrics pdata.Metrics, err error) {
+	_, dataPointCount := metrics.MetricAndDataPointCount()
+	ctx = obsreport.StartMetricsReceiveOp(ctx, sc.name, "")
+	obsreport.EndMetricsReceiveOp(ctx, "", dataPointCount, err)
+	sc.logger.Warn("Dropped scraped metrics", zap.Int("data_points", dataPointCount), zap.Error(err))
+}
+
+// consume passes the metrics to the next consumer, recording observability
+// information.
+func (sc *controller) consume(ctx context.Context, metrics pdata.Metrics) error {
+	observe := observed(ctx)
+	if observe {
+		ctx = obsreport.StartMetricsReceiveOp(ctx, sc.name, "")
+	}
+	err := callWithDeadline(ctx, sc.consumeTimeout, "consume", func(ctx context.Context) error {
+		return sc.nextConsumer.ConsumeMetrics(ctx, metrics)
+	})
+	sc.recordConsumeError(err)
+	if observe {
+		_, dataPointCount := metrics.MetricAndDataPointCount()
+		obsreport.EndMetricsReceiveOp(ctx, "", dataPointCount, err)
+	}
+	if sc.consumeTimeout > 0 && errors.Is(err, context.DeadlineExceeded) {
+		_, dataPointCount := metrics.MetricAndDataPointCount()
+		sc.logger.Warn("Dropped scraped metrics", zap.String("outcome", "consume_timeout"), zap.Int("data_points", dataPointCount), zap.Error(err))
+	}
+	return err
+}
+
+// scrapedPayload holds the metrics scraped on a tick that are passed to the
+// next consumer together.
+type scrapedPayload struct {
+	// scraper is the name of the scraper the metrics were scraped from, or
+	// empty if they were scraped from all the scrapers.
+	scraper string
+	// scrapers are the names of the scrapers the metrics were scraped from if
+	// they were flushed because of WithBatchFlushThreshold.
+	scrapers []string
+	metrics  pdata.Metrics
+	// withheld reports whether any of the scrapers did not forward its
+	// metrics because of WithForwardEvery.
+	withheld bool
+	// metadata is the metadata the metrics are consumed with, set with
+	// WithConsumeContextMetadata.
+	metadata metadata.MD
+	// firstScrapes are the names of the scrapers whose first successful
+	// scrape is in the metrics, recorded once they are consumed.
+	firstScrapes []string
+	// dryRun is set if the metrics are dropped by WithScraperDryRun.
+	dryRun bool
+}
+
+// scrapeAll scrapes all the scrapers. The scraped metrics are returned in a
+// single payload, or in a payload per scraper if WithAsyncConsume is set, so
+// that the metrics of each scraper can be consumed in order, or else in the
+// payloads flushed because of WithBatchFlushThreshold. The metrics of the
+// scrapers created with WithConsumeContextMetadata are returned in a payload
+// each, after the others. The returned payloads are only valid until the
+// next tick.
+func (sc *controller) scrapeAll(ctx context.Context, tick time.Time) []scrapedPayload {
+	sc.scrapersMu.RLock()
+	defer sc.scrapersMu.RUnlock()
+
+	// streaming scrapers pass on their metrics themselves, so that there is
+	// nothing to pass on if there are no others.
+	if len(sc.streamingScrapers) > 0 && len(sc.resourceMetricScrapers) == 0 && len(sc.metricsScrapers.scrapers) == 0 {
+		return nil
+	}
+	if sc.single != nil {
+		return sc.scrapeSingle(ctx, sc.single, tick)
+	}
+
+	// the scrapers whose metrics are consumed with metadata, or dropped by
+	// WithScraperDryRun, are scraped after the others, in a payload each
+	// unless WithAsyncConsume is set, so that their metadata is not passed
+	// with the metrics of other scrapers, and only their metrics are
+	// dropped.
+	metricsScrapers := sc.dueScrapers[:0]
+	var separateScrapers []MetricsScraper
+	for _, ms := range sc.metricsScrapers.scrapers {
+		if !sc.due(ms, tick) {
+			continue
+		}
+		if !sc.asyncConsume && consumedSeparately(ms) {
+			separateScrapers = append(separateScrapers, ms)
+			continue
+		}
+		metricsScrapers = append(metricsScrapers, ms)
+	}
+	sharedScrapers := len(metricsScrapers)
+	metricsScrapers = append(metricsScrapers, separateScrapers...)
+	sc.dueScrapers = metricsScrapers
+	resourceScrapers := sc.dueResourceScrapers[:0]
+	var separateResourceScrapers []ResourceMetricsScraper
+	for _, rms := range sc.resourceMetricScrapers {
+		if !sc.due(rms, tick) {
+			continue
+		}
+		if !sc.asyncConsume && consumedSeparately(rms) {
+			separateResourceScrapers = append(separateResourceScrapers, rms)
+			continue
+		}
+		resourceScrapers = append(resourceScrapers, rms)
+	}
+	sharedResourceScrapers := len(resourceScrapers)
+	resourceScrapers = append(resourceScrapers, separateResourceScrapers...)
+	sc.dueResourceScrapers = resourceScrapers
+	payloads := sc.payloads[:0]
+
+	// the scrapers are either all scraped up front by the dispatcher, or
+	// one after the other below.
+	var results []scrapeResult
+	if sc.dispatcher != nil {
+		sc.dispatcher.prune(sc.scrapers)
+		results = sc.dispatcher.scrape(ctx, sc.name, resourceScrapers, metricsScrapers, sc.results)
+		sc.results = results
+	}
+	scrapeResource := func(i int, metrics pdata.Metrics) {
+		if results == nil {
+			sc.scrapeResourceMetrics(ctx, resourceScrapers[i], metrics)
+			return
+		}
+		sc.appendResourceMetrics(resourceScrapers[i], results[i].resourceMetrics, results[i].err, metrics)
+	}
+	metricsResults := func(from, to int) []scrapeResult {
+		if results == nil {
+			return nil
+		}
+		return results[len(resourceScrapers)+from : len(resourceScrapers)+to]
+	}
+	// scrapeEach scrapes the scrapers from the given indexes in a payload
+	// each.
+	scrapeEach := func(payloads []scrapedPayload, resourceFrom, metricsFrom int) []scrapedPayload {
+		for i := resourceFrom; i < len(resourceScrapers); i++ {
+			rms := resourceScrapers[i]
+			payload := scrapedPayload{scraper: rms.Name(), metrics: sc.newMetrics(), metadata: consumeMetadata(rms)}
+			scrapeResource(i, payload.metrics)
+			payload.withheld = withheld(rms)
+			payload.firstScrapes = sc.appendFirstScrape(nil, rms)
+			payload.dryRun = dryRun(rms)
+			payloads = append(payloads, payload)
+		}
+		for i := metricsFrom; i < len(metricsScrapers); i++ {
+			ms := metricsScrapers[i]
+			payload := scrapedPayload{scraper: ms.Name(), metrics: sc.newMetrics(), metadata: consumeMetadata(ms)}
+			mms := &multiMetricScraper{scrapers: []MetricsScraper{ms}, results: metricsResults(i, i+1)}
+			sc.scrapeResourceMetrics(ctx, mms, payload.metrics)
+			payload.withheld = withheld(ms)
+			payload.firstScrapes = sc.appendFirstScrape(nil, ms)
+			payload.dryRun = dryRun(ms)
+			payloads = append(payloads, payload)
+		}
+		return payloads
+	}
+
+	if sc.asyncConsume {
+		sc.payloads = scrapeEach(payloads, 0, 0)
+		return sc.payloads
+	}
+	// the metrics of the other scrapers are consumed together, even if none
+	// is due, unless all the due scrapers are consumed separately.
+	separate := len(separateScrapers)+len(separateResourceScrapers) > 0
+	shared := sharedScrapers+sharedResourceScrapers > 0 || !separate
+	if shared && sc.batchFlushThreshold > 0 {
+		sharedResults := results
+		if results != nil && separate {
+			sharedResults = make([]scrapeResult, 0, sharedResourceScrapers+sharedScrapers)
+			sharedResults = append(sharedResults, results[:sharedResourceScrapers]...)
+			sharedResults = append(sharedResults, metricsResults(0, sharedScrapers)...)
+		}
+		payloads = sc.scrapeFlushes(ctx, resourceScrapers[:sharedResourceScrapers], metricsScrapers[:sharedScrapers], sharedResults)
+	} else if shared {
+		payload := scrapedPayload{metrics: sc.newMetrics()}
+		for i, rms := range resourceScrapers[:sharedResourceScrapers] {
+			scrapeResource(i, payload.metrics)
+			payload.withheld = payload.withheld || withheld(rms)
+			payload.firstScrapes = sc.appendFirstScrape(payload.firstScrapes, rms)
+		}
+		if sharedScrapers > 0 {
+			sc.multiScraper.scrapers = metricsScrapers[:sharedScrapers]
+			sc.multiScraper.results = metricsResults(0, sharedScrapers)
+			sc.scrapeResourceMetrics(ctx, &sc.multiScraper, payload.metrics)
+			for _, ms := range metricsScrapers[:sharedScrapers] {
+				payload.withheld = payload.withheld || withheld(ms)
+				payload.firstScrapes = sc.appendFirstScrape(payload.firstScrapes, ms)
+			}
+		}
+		payloads = append(payloads, payload)
+	}
+	sc.payloads = scrapeEach(payloads, sharedResourceScrapers, sharedScrapers)
+	return sc.payloads
+}
+
+// due reports whether the scraper should be scraped on the given tick,
+// according to its schedule, like dueOn. It must be called with scrapersMu
+// held.
+func (sc *controller) due(scraper BaseScraper, tick time.Time) bool {
+	return sc.dueOn(scraper, sc.schedules[scraper.Name()], tick)
+}
+
+// dueOn reports whether the scraper should be scraped on the given tick,
+// which is once its deadline has passed, unless its failure policy paused it
+// or still backs off. Once its deadline passed, the deadline is advanced to
+// the next one, and the tick is recorded in the schedule of the scraper if it
+// is due. It must be called with scrapersMu held.
+func (sc *controller) dueOn(scraper BaseScraper, s *scraperSchedule, tick time.Time) bool {
+	sc.scheduleMu.Lock()
+	next, paused := s.next, s.paused()
+	sc.scheduleMu.Unlock()
+	if paused || next.After(tick) {
+		return false
+	}
+	due := true
+	if f, ok := scraper.(interface{ allowScrape(time.Time) bool }); ok {
+		due = f.allowScrape(tick)
+	}
+
+	sc.scheduleMu.Lock()
+	defer sc.scheduleMu.Unlock()
+	if !due && sc.externalTicks() {
+		// the scraper is checked again on the next tick.
+		return false
+	}
+	if due {
+		s.last, s.scraped = tick, true
+	}
+	s.next, s.reason = sc.deadlineAfter(s, tick), ScheduleInterval
+	heap.Fix(&sc.deadlines, s.index)
+	return due
+}
+
+// handlePersistentFailures applies the failure policy of the scrapers that
+// failed the maximum number of consecutive times allowed by their policy:
+// scrapers in FailureDisableAfter mode are no longer scraped, which is
+// logged, and in FailureFatal mode, a fatal error is reported to the host.
+func (sc *controller) handlePersistentFailures(ctx context.Context) {
+	// the scrapers are not copied, as this is done on every tick, and the
+	// fatal errors are only reported once scrapersMu is released.
+	var fatalErrs []error
+	sc.scrapersMu.RLock()
+	for _, scraper := range sc.scrapers {
+		s, ok := scraper.(interface {
+			persistentFailure() (FailureMode, int, error)
+		})
+		if !ok {
+			continue
+		}
+		mode, failures, err := s.persistentFailure()
+		if err == nil {
+			continue
+		}
+
+		switch mode {
+		case FailureDisableAfter:
+			sc.logger.Error("Scraper disabled after consecutive failed scrapes until the receiver is restarted",
+				zap.String("scraper", scraper.Name()),
+				zap.Int("failures", failures),
+				zap.Error(err))
+			sc.firstScrapes.notify()
+		case FailureFatal:
+			err = fmt.Errorf("scraper %q of receiver %q failed %d consecutive scrapes: %w", scraper.Name(), sc.name, failures, err)
+			sc.logger.Error("Scraper failed persistently", zap.Error(err))
+			fatalErrs = append(fatalErrs, err)
+		}
+	}
+	sc.scrapersMu.RUnlock()
+
+	if host, ok := HostFromContext(ctx); ok {
+		for _, err := range fatalErrs {
+			host.ReportFatalError(err)
+		}
+	}
+}
+
+// scraperInterval returns the collection interval of the scraper, which is
+// the interval set in its configuration, or else the default set with
+// WithEnvDefaults, or else the collection interval of the receiver. It must
+// be called with scrapersMu held.
+func (sc *controller) scraperInterval(scraper BaseScraper) time.Duration {
+	if cfg := sc.scraperConfig(scraper); cfg != nil && cfg.CollectionInterval() > 0 {
+		return cfg.CollectionInterval()
+	}
+	if sc.envCollectionInterval > 0 {
+		return sc.envCollectionInterval
+	}
+	return sc.collectionInterval
+}
+
+// scraperConfig returns the configuration last applied to the scraper by
+// UpdateConfig, or else the configuration it was created from, if any. It
+// must be called with scrapersMu held.
+func (sc *controller) scraperConfig(scraper BaseScraper) ScraperConfig {
+	if cfg, ok := sc.scraperConfigs[scraper.Name()]; ok {
+		return cfg
+	}
+	if s, ok := scraper.(interface{ scraperConfig() ScraperConfig }); ok {
+		return s.scraperConfig()
+	}
+	return nil
+}
+
+// initialDelay returns the initial delay of the scraper, which is the delay
+// set with WithInitialDelay, or else in the configuration of the scraper, or
+// else the receiver default.
+func (sc *controller) initialDelay(scraper BaseScraper) time.Duration {
+	delay, _ := sc.initialDelayWithSource(scraper)
+	return delay
+}
+
+// initialDelayWithSource returns the initial delay of the scraper, like
+// initialDelay, and where it was set.
+func (sc *controller) initialDelayWithSource(scraper BaseScraper) (time.Duration, ConfigSource) {
+	if s, ok := scraper.(interface {
+		configuredInitialDelay() (time.Duration, ConfigSource)
+	}); ok {
+		if delay, source := s.configuredInitialDelay(); source != "" {
+			return delay, source
+		}
+	}
+	return sc.defaultInitialDelay, ConfigSourceReceiver
+}
+
+// checkInitialDelay warns if the initial delay of the scraper is longer than
+// the collection interval, which skips the first ticks.
+func (sc *controller) checkInitialDelay(scraper BaseScraper) {
+	if delay := sc.initialDelay(scraper); delay > sc.collectionInterval {
+		sc.logger.Warn("Initial delay of scraper is longer than the collection interval",
+			zap.String("scraper", scraper.Name()),
+			zap.Duration("initial_delay", delay),
+			zap.Duration("collection_interval", sc.collectionInterval))
+	}
+}
+
+// consumedSeparately reports whether the metrics of the scraper must not be
+// consumed with those of other scrapers, because of
+// WithConsumeContextMetadata or WithScraperDryRun.
+func consumedSeparately(scraper BaseScraper) bool {
+	return len(consumeMetadata(scraper)) > 0 || dryRun(scraper)
+}
+
+// withheld reports whether the scraper did not forward the metrics of its
+// last scrape because of WithForwardEvery.
+func withheld(scraper BaseScraper) bool {
+	s, ok := scraper.(interface{ lastScrapeWithheld() bool })
+	return ok && s.lastScrapeWithheld()
+}
+
+// addResourceAttributes adds the configured resource attributes to the
+// resource of each of the scraped ResourceMetrics.
+func (sc *controller) addResourceAttributes(metrics pdata.Metrics) {
+	if len(sc.resourceAttributes) == 0 {
+		return
+	}
+	insertResourceAttributes(metrics.ResourceMetrics(), sc.resourceAttributes)
+}
+
+// seriesDeduplicator keeps track of the series scraped on a tick, to remove
+// the data points that duplicate an earlier data point.
+type seriesDeduplicator struct {
+	keys seriesKeys
+	seen map[string]struct{}
+}
+
+func newSeriesDeduplicator() *seriesDeduplicator {
+	return &seriesDeduplicator{seen: map[string]struct{}{}}
+}
+
+// deduplicate removes the data points that duplicate an earlier data point
+// of the same series with the same timestamp, and returns the number of data
+// points removed.
+func (d *seriesDeduplicator) deduplicate(metrics pdata.Metrics) int {
+	removed := 0
+
+	rms := metrics.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		id := resourceID(rms.At(i).Resource())
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			ms := ilms.At(j).Metrics()
+			kept := 0
+			for k := 0; k < ms.Len(); k++ {
+				metric := ms.At(k)
+				n := removeDataPoints(metric, func(dps dataPointSlice, l int) bool {
+					key := d.keys.key(id, metric.Name(), dps.labels(l)) + "\x00" + strconv.FormatUint(uint64(dps.timestamp(l)), 10)
+					if _, ok := d.seen[key]; ok {
+						return true
+					}
+					d.seen[key] = struct{}{}
+					return false
+				})
+				removed += n
+				if n > 0 && dataPointCount(metric) == 0 {
+					continue
+				}
+				if kept != k {
+					metric.CopyTo(ms.At(kept))
+				}
+				kept++
+			}
+			ms.Resize(kept)
+		}
+	}
+	return removed
+}
+
+// setTimestamps sets the timestamp of all the scraped data points.
+func (sc *controller) setTimestamps(metrics pdata.Metrics, ts pdata.TimestampUnixNano) {
+	rms := metrics.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			setTimestamps(ilms.At(j).Metrics(), ts)
+		}
+	}
+}
+
+// addMetricNamePrefix prepends the configured prefix to the names of the
+// scraped metrics that do not already have it.
+func (sc *controller) addMetricNamePrefix(metrics pdata.Metrics) {
+	if sc.metricNamePrefix == "" {
+		return
+	}
+
+	rms := metrics.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			ms := ilms.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				metric := ms.At(k)
+				if !strings.HasPrefix(metric.Name(), sc.metricNamePrefix) {
+					metric.SetName(sc.metricNamePrefix + metric.Name())
+				}
+			}
+		}
+	}
+}
+
+// limitCollectionInterval checks that the collection interval is within the
+// range set with WithMinCollectionInterval and WithMaxCollectionInterval,
+// clamping it if the interval policy is ClampInterval.
+func (sc *controller) limitCollectionInterval() error {
+	min, max := sc.minCollectionInterval, sc.maxCollectionInterval
+	if min > 0 && max > 0 && min > max {
+		return fmt.Errorf("minimum collection interval %v of receiver %q is greater than its maximum collection interval %v", min, sc.name, max)
+	}
+
+	interval := sc.collectionInterval
+	switch {
+	case min > 0 && interval < min:
+		interval = min
+	case max > 0 && interval > max:
+		interval = max
+	default:
+		return nil
+	}
+
+	if sc.intervalPolicy != ClampInterval {
+		return fmt.Errorf("collection_interval %v of receiver %q is outside of the allowed range, it must be %s", sc.collectionInterval, sc.name, intervalRange(min, max))
+	}
+	sc.logger.Warn("Collection interval outside of the allowed range, using the closest allowed interval",
+		zap.Duration("configured_interval", sc.collectionInterval),
+		zap.Duration("collection_interval", interval))
+	sc.collectionInterval = interval
+	return nil
+}
+
+// intervalRange describes the allowed range of collection intervals, where a
+// bound of zero or less means no bound.
+func intervalRange(min, max time.Duration) string {
+	switch {
+	case max <= 0:
+		return fmt.Sprintf("at least %v", min)
+	case min <= 0:
+		return fmt.Sprintf("at most %v", max)
+	default:
+		return fmt.Sprintf("between %v and %v", min, max)
+	}
+}
+
+// validateMetricNamePrefix returns an error if the prefix cannot start a
+// metric name.
+func validateMetricNamePrefix(prefix string) error {
+	for i, r := range prefix {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-'):
+		default:
+			return fmt.Errorf("invalid metric name prefix %q", prefix)
+		}
+	}
+	return nil
+}
+
+// recordTransformError logs the error of a transformer, and records the
+// scrape of the payload as failed, with all the scraped metrics errored.
+func (sc *controller) recordTransformError(ctx context.Context, payload scrapedPayload, metrics pdata.Metrics, err error) {
+	metricCount, _ := metrics.MetricAndDataPointCount()
+	sc.logger.Error("Error transforming scraped metrics",
+		zap.String("scraper", payload.scraper),
+		zap.Int("metrics", metricCount),
+		zap.Error(err))
+	ctx = obsreport.ScraperContext(ctx, sc.name, payload.scraper)
+	ctx = obsreport.StartMetricsScrapeOp(ctx, sc.name, payload.scraper)
+	obsreport.EndMetricsScrapeOp(ctx, metricCount, err)
+}
+
+// transform calls the configured transformers in order, stopping at the first
+// one that fails.
+func (sc *controller) transform(ctx context.Context, metrics pdata.Metrics) (pdata.Metrics, error) {
+	for _, transformer := range sc.transformers {
+		var err error
+		if metrics, err = transformer(ctx, metrics); err != nil {
+			return metrics, err
+		}
+	}
+	return metrics, nil
+}
+
+func (sc *controller) scrapeResourceMetrics(ctx context.Context, rms ResourceMetricsScraper, metrics pdata.Metrics) {
+	resourceMetrics, err := rms.Scrape(ctx, sc.name)
+	sc.appendResourceMetrics(rms, resourceMetrics, err, metrics)
+}
+
+// appendResourceMetrics appends the resource metrics scraped by the scraper
+// to metrics, unless the scrape failed without a partial result.
+func (sc *controller) appendResourceMetrics(rms ResourceMetricsScraper, resourceMetrics pdata.ResourceMetricsSlice, err error, metrics pdata.Metrics) {
+	if err != nil {
+		sc.logger.Error("Error scraping metrics", zap.Error(err))
+
+		if !consumererror.IsPartialScrapeError(err) {
+			return
+		}
+	}
+	if reusesBuffer(rms) {
+		for i := 0; i < resourceMetrics.Len(); i++ {
+			metrics.ResourceMetrics().Append(resourceMetrics.At(i))
+		}
+		return
+	}
+	resourceMetrics.MoveAndAppendTo(metrics.ResourceMetrics())
+}
+
+// stopScraping stops the ticker and waits until scraping has terminated, or
+// until the context expires, like waitScraping.
+func (sc *controller) stopScraping(ctx context.Context) {
+	if sc.done != nil {
+		close(sc.done)
+		sc.done = nil
+	}
+	if sc.scheduler != nil {
+		sc.unscheduleScraping(ctx)
+		return
+	}
+	sc.waitScraping(ctx)
+}
+
+// waitScraping waits until the tick being scraped, if any, is done. If the
+// context expires first, the context of the scrape is canceled, and the
+// scrapers and the next consumer are expected to return when it is, as they
+// are not otherwise interrupted.
+func (sc *controller) waitScraping(ctx context.Context) {
+	if sc.cancelScraping == nil {
+		sc.wg.Wait()
+		return
+	}
+
+	stopped := make(chan struct{})
+	go func(cancel context.CancelFunc) {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-stopped:
+		}
+	}(sc.cancelScraping)
+	sc.wg.Wait()
+	close(stopped)
+	// the context is not canceled once scraping stopped on time, as it is
+	// derived from the background context, which is never canceled.
+	sc.cancelScraping = nil
+}
+
+// AddScraper adds a MetricsScraper, ResourceMetricsScraper or
+// StreamingScraper to the receiver.
+// Scrapers can only be added before the receiver is started, or while it is
+// running, in which case the scraper is initialized before it is added.
+func (sc *controller) AddScraper(ctx context.Context, scraper BaseScraper) error {
+	switch scraper.(type) {
+	case MetricsScraper, ResourceMetricsScraper, StreamingScraper:
+	default:
+		return withKind(ErrInvalidScraper, fmt.Errorf("unsupported scraper type %T", scraper))
+	}
+	if reason, disabled := sc.disabledReason(scraper); disabled {
+		sc.scrapersMu.Lock()
+		defer sc.scrapersMu.Unlock()
+		sc.disabledScrapers = append(sc.disabledScrapers, DisabledScraper{ID: sc.scraperID(scraper.Name()), Reason: reason})
+		return nil
+	}
+	if err := validateScraper(scraper); err != nil {
+		return err
+	}
+	sc.checkInitialDelay(scraper)
+
+	sc.stateMu.Lock()
+	state, host := sc.state, sc.host
+	sc.stateMu.Unlock()
+
+	switch state {
+	case StateCreated:
+		return sc.registerScraper(scraper, state)
+	case StateRunning:
+	default:
+		return withKind(ErrInvalidState, fmt.Errorf("cannot add scraper %q to a receiver in state %v", scraper.Name(), state))
+	}
+
+	if err := startScraper(ctx, host, scraper, sc.receiverSettings(append(sc.registeredScrapers(), scraper))); err != nil {
+		return withKind(ErrScraperInitialization, fmt.Errorf("failed to initialize scraper %q: %w", scraper.Name(), err))
+	}
+
+	if err := sc.registerScraper(scraper, state); err != nil {
+		// the receiver stopped while the scraper was being initialized
+		if shutdownErr := scraper.Shutdown(ctx); shutdownErr != nil {
+			sc.logger.Error("Error closing scraper", zap.String("scraper", scraper.Name()), zap.Error(shutdownErr))
+		}
+		return err
+	}
+	sc.logDeprecatedFields()
+	return nil
+}
+
+// logDeprecatedFields logs a warning for each deprecated field used by the
+// configurations of the scrapers that has not been logged yet.
+func (sc *controller) logDeprecatedFields() {
+	sc.scrapersMu.Lock()
+	defer sc.scrapersMu.Unlock()
+
+	var fields []DeprecatedField
+	scrapers := map[DeprecatedField][]string{}
+	for _, scraper := range sc.scrapers {
+		cfg, ok := sc.scraperConfig(scraper).(interface{ registeredDeprecatedFields() []DeprecatedField })
+		if !ok {
+			continue
+		}
+		for _, field := range cfg.registeredDeprecatedFields() {
+			if sc.loggedDeprecatedFields[field] {
+				continue
+			}
+			if _, ok := scrapers[field]; !ok {
+				fields = append(fields, field)
+			}
+			scrapers[field] = append(scrapers[field], scraper.Name())
+		}
+	}
+
+	for _, field := range fields {
+		sc.loggedDeprecatedFields[field] = true
+		sc.logger.Warn("Scraper configuration field is deprecated",
+			zap.String("field", field.Name),
+			zap.String("replacement", field.Replacement),
+			zap.String("removal_version", field.RemovalVersion),
+			zap.Strings("scrapers", scrapers[field]))
+	}
+}
+
+// DisabledScrapers returns the scrapers that were added to the receiver but
+// are disabled.
+func (sc *controller) DisabledScrapers() []DisabledScraper {
+	sc.scrapersMu.RLock()
+	defer sc.scrapersMu.RUnlock()
+	return append([]DisabledScraper(nil), sc.disabledScrapers...)
+}
+
+// disabledReason reports whether the scraper is disabled, and why.
+func (sc *controller) disabledReason(scraper BaseScraper) (DisabledReason, bool) {
+	if !scraperEnabled(scraper) {
+		return DisabledByConfig, true
+	}
+	if sc.scrapingDisabled {
+		return DisabledByZeroInterval, true
+	}
+	return 0, false
+}
+
+// disableScraping records all the enabled scrapers as disabled by a
+// collection interval of zero.
+func (sc *controller) disableScraping() {
+	sc.scrapingDisabled = true
+	for _, scraper := range sc.scrapers {
+		sc.disabledScrapers = append(sc.disabledScrapers, DisabledScraper{ID: sc.scraperID(scraper.Name()), Reason: DisabledByZeroInterval})
+	}
+	sc.metricsScrapers.scrapers = nil
+	sc.resourceMetricScrapers = nil
+	sc.streamingScrapers = nil
+	sc.scrapers = nil
+}
+
+// registerScraper adds the scraper to the scrapers being scraped, as long as
+// the receiver is still in the expected state.
+func (sc *controller) registerScraper(scraper BaseScraper, expected State) error {
+	sc.scrapersMu.Lock()
+	defer sc.scrapersMu.Unlock()
+
+	if state := sc.State(); state != expected {
+		return withKind(ErrInvalidState, fmt.Errorf("cannot add scraper %q to a receiver in state %v", scraper.Name(), state))
+	}
+	for _, existing := range sc.scrapers {
+		if existing.Name() == scraper.Name() {
+			return withKind(ErrDuplicateScraper, fmt.Errorf("scraper %q already exists", scraper.Name()))
+		}
+	}
+
+	switch s := scraper.(type) {
+	case MetricsScraper:
+		sc.metricsScrapers.scrapers = append(sc.metricsScrapers.scrapers, s)
+	case ResourceMetricsScraper:
+		sc.resourceMetricScrapers = append(sc.resourceMetricScrapers, s)
+	case StreamingScraper:
+		sc.streamingScrapers = append(sc.streamingScrapers, s)
+	}
+	sc.scrapers = append(sc.scrapers, scraper)
+	sc.updateSchedules()
+	sc.updateSingleScraper()
+	sc.firstScrapes.notify()
+	return nil
+}
+
+// RemoveScraper removes the scraper with the given ID from the receiver,
+// waiting for any in-flight scrape to complete. If the receiver is running,
+// the scraper is closed once it has been removed.
+func (sc *controller) RemoveScraper(ctx context.Context, id ScraperID) error {
+	if id.Receiver != sc.name {
+		return &ScraperNotFoundError{ID: id}
+	}
+	return sc.removeScraper(ctx, id.Scraper)
+}
+
+// removeScraper removes the scraper with the given name from the receiver.
+func (sc *controller) removeScraper(ctx context.Context, name string) error {
+	sc.scrapersMu.Lock()
+	// the slices of scrapers are copied rather than changed in place, so that
+	// the slices read before are left unchanged.
+	var removed BaseScraper
+	for i, scraper := range sc.scrapers {
+		if scraper.Name() == name {
+			removed = scraper
+			sc.scrapers = append(sc.scrapers[:i:i], sc.scrapers[i+1:]...)
+			break
+		}
+	}
+	if removed == nil {
+		sc.scrapersMu.Unlock()
+		return &ScraperNotFoundError{ID: sc.scraperID(name)}
+	}
+	for i, scraper := range sc.metricsScrapers.scrapers {
+		if scraper == removed {
+			sc.metricsScrapers.scrapers = append(sc.metricsScrapers.scrapers[:i:i], sc.metricsScrapers.scrapers[i+1:]...)
+			break
+		}
+	}
+	for i, scraper := range sc.resourceMetricScrapers {
+		if scraper == removed {
+			sc.resourceMetricScrapers = append(sc.resourceMetricScrapers[:i:i], sc.resourceMetricScrapers[i+1:]...)
+			break
+		}
+	}
+	for i, scraper := range sc.streamingScrapers {
+		if scraper == removed {
+			sc.streamingScrapers = append(sc.streamingScrapers[:i:i], sc.streamingScrapers[i+1:]...)
+			break
+		}
+	}
+	delete(sc.scraperConfigs, name)
+	sc.updateSchedules()
+	sc.updateSingleScraper()
+	state := sc.State()
+	sc.scrapersMu.Unlock()
+	sc.firstScrapes.forget(name)
+
+	if state != StateRunning {
+		return nil
+	}
+	return removed.Shutdown(contextWithCreateSettings(ctx, sc.settings))
+}
+
+var _ ResourceMetricsScraper = (*multiMetricScraper)(nil)
+
+type multiMetricScraper struct {
+	scrapers []MetricsScraper
+	// results are the results of the scrapers, if they were already scraped
+	// by the dispatcher, in which case Scrape does not scrape them again.
+	results []scrapeResult
+}
+
+func (mms *multiMetricScraper) Name() string {
+	return ""
+}
+
+func (mms *multiMetricScraper) Start(ctx context.Context, host component.Host) error {
+	for _, scraper := range mms.scrapers {
+		if err := scraper.Start(ctx, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mms *multiMetricScraper) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, scraper := range mms.scrapers {
+		if err := scraper.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return componenterror.CombineErrors(errs)
+}
+
+func (mms *multiMetricScraper) Scrape(ctx context.Context, receiverName string) (pdata.ResourceMetricsSlice, error) {
+	rms := pdata.NewResourceMetricsSlice()
+	rms.Resize(1)
+	rm := rms.At(0)
+	ilms := rm.InstrumentationLibraryMetrics()
+	ilms.Resize(1)
+	ilm := ilms.At(0)
+
+	var errs []error
+	for i, scraper := range mms.scrapers {
+		var metrics pdata.MetricSlice
+		var err error
+		if mms.results != nil {
+			metrics, err = mms.results[i].metrics, mms.results[i].err
+		} else {
+			metrics, err = scraper.Scrape(ctx, receiverName)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			if !consumererror.IsPartialScrapeError(err) {
+				continue
+			}
+		}
+
+		appendScraperMetrics(scraper, metrics, ilm.Metrics(), rms)
+	}
+	return rms, CombineScrapeErrors(errs)
+}
+
+// appendScraperMetrics appends the metrics scraped by the scraper to dest, or
+// to a separate resource appended to rms if the scraper has its own resource
+// attributes.
+func appendScraperMetrics(scraper MetricsScraper, metrics, dest pdata.MetricSlice, rms pdata.ResourceMetricsSlice) {
+	s, ok := scraper.(interface{ scraperResourceAttributes() []label })
+	if !ok || len(s.scraperResourceAttributes()) == 0 {
+		appendScrapedMetrics(scraper, metrics, dest)
+		return
+	}
+	own := pdata.NewResourceMetricsSlice()
+	own.Resize(1)
+	own.At(0).InstrumentationLibraryMetrics().Resize(1)
+	appendScrapedMetrics(scraper, metrics, own.At(0).InstrumentationLibraryMetrics().At(0).Metrics())
+	insertResourceAttributes(own, s.scraperResourceAttributes())
+	own.MoveAndAppendTo(rms)
+}
+
+// appendScrapedMetrics appends the metrics scraped by the scraper to dest.
+// The metrics of scrapers reusing their slice are appended one by one, so
+// that the slice keeps its capacity, and the others are moved.
+func appendScrapedMetrics(scraper MetricsScraper, metrics, dest pdata.MetricSlice) {
+	if !reusesBuffer(scraper) {
+		metrics.MoveAndAppendTo(dest)
+		return
+	}
+	for i := 0; i < metrics.Len(); i++ {
+		dest.Append(metrics.At(i))
 	}
-	return rms, CombineScrapeErrors(errs)
 }
@@ -17,7 +17,13 @@ package scraperhelper
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -25,6 +31,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.opencensus.io/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenterror"
@@ -33,6 +40,7 @@ import (
 	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
 	"go.opentelemetry.io/collector/obsreport/obsreporttest"
 )
 
@@ -110,7 +118,8 @@ type metricsTestCase struct {
 func TestScrapeController(t *testing.T) {
 	testCases := []metricsTestCase{
 		{
-			name: "NoScrapers",
+			name:           "NoScrapers",
+			expectedNewErr: `receiver "receiver" has no scrapers`,
 		},
 		{
 			name:          "AddMetricsScrapersWithCollectionInterval",
@@ -188,9 +197,7 @@ func TestScrapeController(t *testing.T) {
 			trace.RegisterExporter(ss)
 			defer trace.UnregisterExporter(ss)
 
-			done, err := obsreporttest.SetupRecordedMetricsTest()
-			require.NoError(t, err)
-			defer done()
+			obsreporttest.SetupRecordedMetrics(t)
 
 			initializeChs := make([]chan bool, test.scrapers+test.resourceScrapers)
 			scrapeMetricsChs := make([]chan int, test.scrapers)
@@ -223,7 +230,9 @@ func TestScrapeController(t *testing.T) {
 			err = mr.Start(context.Background(), componenttest.NewNopHost())
 			expectedStartErr := getExpectedStartErr(test)
 			if expectedStartErr != nil {
-				assert.Equal(t, expectedStartErr, err)
+				assert.EqualError(t, err, expectedStartErr.Error())
+				assert.True(t, errors.Is(err, test.initializeErr))
+				assert.True(t, errors.Is(err, ErrScraperInitialization))
 			} else if test.initialize {
 				assertChannelsCalled(t, initializeChs, "start was not called")
 			}
@@ -258,13 +267,15 @@ func TestScrapeController(t *testing.T) {
 				assertReceiverSpan(t, spans)
 				assertReceiverViews(t, sink)
 				assertScraperSpan(t, test.scrapeErr, spans)
-				assertScraperViews(t, test.scrapeErr, sink)
+				assertScraperViews(t, test.scrapeErr, sink, test.scrapers+test.resourceScrapers)
 			}
 
 			err = mr.Shutdown(context.Background())
 			expectedShutdownErr := getExpectedShutdownErr(test)
 			if expectedShutdownErr != nil {
 				assert.EqualError(t, err, expectedShutdownErr.Error())
+			} else if test.close && test.initializeErr != nil {
+				assertChannelsNotCalled(t, closeChs, "shutdown was called")
 			} else if test.close {
 				assertChannelsCalled(t, closeChs, "shutdown was not called")
 			}
@@ -290,7 +301,7 @@ func configureMetricOptions(test metricsTestCase, initializeChs []chan bool, scr
 
 		scrapeMetricsChs[i] = make(chan int)
 		tsm := &testScrapeMetrics{ch: scrapeMetricsChs[i], err: test.scrapeErr}
-		metricOptions = append(metricOptions, AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape, scraperOptions...)))
+		metricOptions = append(metricOptions, AddMetricsScraper(NewMetricsScraper(fmt.Sprintf("scraper%d", i), tsm.scrape, scraperOptions...)))
 	}
 
 	for i := 0; i < test.resourceScrapers; i++ {
@@ -308,20 +319,25 @@ func configureMetricOptions(test metricsTestCase, initializeChs []chan bool, scr
 
 		testScrapeResourceMetricsChs[i] = make(chan int)
 		tsrm := &testScrapeResourceMetrics{ch: testScrapeResourceMetricsChs[i], err: test.scrapeErr}
-		metricOptions = append(metricOptions, AddResourceMetricsScraper(NewResourceMetricsScraper("scraper", tsrm.scrape, scraperOptions...)))
+		metricOptions = append(metricOptions, AddResourceMetricsScraper(NewResourceMetricsScraper(fmt.Sprintf("scraper%d", test.scrapers+i), tsrm.scrape, scraperOptions...)))
 	}
 
 	return metricOptions
 }
 
 func getExpectedStartErr(test metricsTestCase) error {
-	return test.initializeErr
+	if test.initializeErr == nil {
+		return nil
+	}
+	// the scrapers are started in order, so the first one fails.
+	return fmt.Errorf("failed to initialize scraper %q: %w", "scraper0", test.initializeErr)
 }
 
 func getExpectedShutdownErr(test metricsTestCase) error {
 	var errs []error
 
-	if test.closeErr != nil {
+	// the scrapers are not closed if none of them started.
+	if test.closeErr != nil && test.initializeErr == nil {
 		for i := 0; i < test.scrapers; i++ {
 			errs = append(errs, test.closeErr)
 		}
@@ -336,6 +352,16 @@ func assertChannelsCalled(t *testing.T, chs []chan bool, message string) {
 	}
 }
 
+func assertChannelsNotCalled(t *testing.T, chs []chan bool, message string) {
+	for _, ch := range chs {
+		select {
+		case <-ch:
+			assert.Fail(t, message)
+		default:
+		}
+	}
+}
+
 func assertChannelCalled(t *testing.T, ch chan bool, message string) {
 	select {
 	case <-ch:
@@ -374,7 +400,7 @@ func assertScraperSpan(t *testing.T, expectedErr error, spans []*trace.SpanData)
 
 	scraperSpan := false
 	for _, span := range spans {
-		if span.Name == "scraper/receiver/scraper/MetricsScraped" {
+		if strings.HasPrefix(span.Name, "scraper/receiver/scraper") && strings.HasSuffix(span.Name, "/MetricsScraped") {
 			scraperSpan = true
 			assert.Equal(t, expectedScrapeTraceStatus, span.Status)
 			assert.Equal(t, expectedScrapeTraceMessage, span.Message)
@@ -384,19 +410,23 @@ func assertScraperSpan(t *testing.T, expectedErr error, spans []*trace.SpanData)
 	assert.True(t, scraperSpan)
 }
 
-func assertScraperViews(t *testing.T, expectedErr error, sink *consumertest.MetricsSink) {
-	expectedScraped := int64(sink.MetricsCount())
+// assertScraperViews checks the views of each of the scrapers, named
+// scraper0 to scraperN, which all scrape the same metrics.
+func assertScraperViews(t *testing.T, expectedErr error, sink *consumertest.MetricsSink, scrapers int) {
+	expectedScraped := int64(sink.MetricsCount() / scrapers)
 	expectedErrored := int64(0)
 	if expectedErr != nil {
 		if partialError, isPartial := expectedErr.(consumererror.PartialScrapeError); isPartial {
 			expectedErrored = int64(partialError.Failed)
 		} else {
 			expectedScraped = int64(0)
-			expectedErrored = int64(sink.MetricsCount())
+			expectedErrored = int64(sink.MetricsCount() / scrapers)
 		}
 	}
 
-	obsreporttest.CheckScraperMetricsViews(t, "receiver", "scraper", expectedScraped, expectedErrored)
+	for i := 0; i < scrapers; i++ {
+		obsreporttest.CheckScraperMetricsViews(t, "receiver", fmt.Sprintf("scraper%d", i), expectedScraped, expectedErrored)
+	}
 }
 
 func singleMetric() pdata.MetricSlice {
@@ -434,13 +464,14 @@ func TestSingleScrapePerTick(t *testing.T) {
 		cfg,
 		zap.NewNop(),
 		new(consumertest.MetricsSink),
-		AddMetricsScraper(NewMetricsScraper("", tsm.scrape)),
-		AddResourceMetricsScraper(NewResourceMetricsScraper("", tsrm.scrape)),
+		AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape)),
+		AddResourceMetricsScraper(NewResourceMetricsScraper("resource_scraper", tsrm.scrape)),
 		WithTickerChannel(tickerCh),
 	)
 	require.NoError(t, err)
 
 	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, receiver.Shutdown(context.Background())) }()
 
 	tickerCh <- time.Now()
 
@@ -475,3 +506,1985 @@ func (ss *spanStore) PullAllSpans() []*trace.SpanData {
 	ss.Unlock()
 	return capturedSpans
 }
+
+func TestLazyInit(t *testing.T) {
+	initializeCh := make(chan bool, 1)
+	ti := &testInitialize{ch: initializeCh, err: errors.New("err1")}
+	closeCh := make(chan bool, 1)
+	tc := &testClose{ch: closeCh}
+	scrapeMetricsCh := make(chan int, 10)
+	tsm := &testScrapeMetrics{ch: scrapeMetricsCh}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape, WithStart(ti.start), WithShutdown(tc.shutdown), WithLazyInit())),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	select {
+	case <-initializeCh:
+		assert.Fail(t, "start was called before the first scrape")
+	default:
+	}
+
+	// the first initialization fails, so the scrape function is not called
+	tickerCh <- time.Now()
+	assertChannelCalled(t, waitFor(initializeCh), "start was not called on the first scrape")
+	require.Eventually(t, func() bool { return sink.MetricsCount() == 0 && len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 0, len(scrapeMetricsCh))
+
+	// initialization is retried on the next scrape
+	ti.err = nil
+	tickerCh <- time.Now()
+	assertChannelCalled(t, waitFor(initializeCh), "start was not retried on the second scrape")
+	assert.Equal(t, 1, <-scrapeMetricsCh)
+
+	// once initialized, start is not called again
+	tickerCh <- time.Now()
+	assert.Equal(t, 2, <-scrapeMetricsCh)
+	assert.Equal(t, 0, len(initializeCh))
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assertChannelCalled(t, closeCh, "shutdown was not called")
+}
+
+func TestLazyInitNeverInitialized(t *testing.T) {
+	closeCh := make(chan bool, 1)
+	tc := &testClose{ch: closeCh}
+	tsm := &testScrapeMetrics{ch: make(chan int, 10)}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape, WithShutdown(tc.shutdown), WithLazyInit())),
+		WithTickerChannel(make(chan time.Time)),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Equal(t, 0, len(closeCh), "shutdown was called on a scraper that was never initialized")
+}
+
+// waitFor blocks until a value is available on the channel, and returns a
+// channel containing it.
+func waitFor(ch chan bool) chan bool {
+	out := make(chan bool, 1)
+	select {
+	case v := <-ch:
+		out <- v
+	case <-time.After(time.Second):
+	}
+	return out
+}
+
+type testSlowInitialize struct {
+	delay    time.Duration
+	err      error
+	inFlight *int32
+	maxSeen  *int32
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (ts *testSlowInitialize) start(context.Context, component.Host) error {
+	n := atomic.AddInt32(ts.inFlight, 1)
+	defer atomic.AddInt32(ts.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(ts.maxSeen)
+		if n <= max || atomic.CompareAndSwapInt32(ts.maxSeen, max, n) {
+			break
+		}
+	}
+
+	time.Sleep(ts.delay)
+	return ts.err
+}
+
+func (ts *testSlowInitialize) shutdown(context.Context) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.closed = true
+	return nil
+}
+
+func (ts *testSlowInitialize) isClosed() bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.closed
+}
+
+func TestParallelInit(t *testing.T) {
+	const scrapers = 6
+	const delay = 100 * time.Millisecond
+
+	var inFlight, maxSeen int32
+	options := []ScraperControllerOption{WithParallelInit(3), WithTickerChannel(make(chan time.Time))}
+	for i := 0; i < scrapers; i++ {
+		ti := &testSlowInitialize{delay: delay, inFlight: &inFlight, maxSeen: &maxSeen}
+		tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+		options = append(options, AddMetricsScraper(NewMetricsScraper(fmt.Sprintf("scraper%d", i), tsm.scrape, WithStart(ti.start), WithShutdown(ti.shutdown))))
+	}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), options...)
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	elapsed := time.Since(start)
+
+	assert.Less(t, int64(elapsed), int64(scrapers*delay), "initialization was not done in parallel")
+	assert.GreaterOrEqual(t, int64(elapsed), int64(2*delay))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&maxSeen))
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+func TestParallelInitPartialFailure(t *testing.T) {
+	var inFlight, maxSeen int32
+	initializers := []*testSlowInitialize{
+		{delay: 10 * time.Millisecond, inFlight: &inFlight, maxSeen: &maxSeen},
+		{delay: 50 * time.Millisecond, inFlight: &inFlight, maxSeen: &maxSeen, err: errors.New("err1")},
+		{delay: 100 * time.Millisecond, inFlight: &inFlight, maxSeen: &maxSeen},
+		{delay: 10 * time.Millisecond, inFlight: &inFlight, maxSeen: &maxSeen, err: errors.New("err2")},
+	}
+	names := []string{"scraper1", "scraper2", "scraper3", "scraper4"}
+
+	options := []ScraperControllerOption{WithParallelInit(0), WithTickerChannel(make(chan time.Time))}
+	for i, ti := range initializers {
+		tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+		options = append(options, AddMetricsScraper(NewMetricsScraper(names[i], tsm.scrape, WithStart(ti.start), WithShutdown(ti.shutdown))))
+	}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), options...)
+	require.NoError(t, err)
+
+	err = receiver.Start(context.Background(), componenttest.NewNopHost())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `failed to initialize scraper "scraper2": err1`)
+	assert.Contains(t, err.Error(), `failed to initialize scraper "scraper4": err2`)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&inFlight))
+
+	assert.True(t, initializers[0].isClosed())
+	assert.False(t, initializers[1].isClosed())
+	assert.True(t, initializers[2].isClosed())
+	assert.False(t, initializers[3].isClosed())
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+type testOrderRecorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *testOrderRecorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.order = append(r.order, event)
+}
+
+func (r *testOrderRecorder) options(name string) []ScraperOption {
+	return []ScraperOption{
+		WithStart(func(context.Context, component.Host) error {
+			r.record("start " + name)
+			return nil
+		}),
+		WithShutdown(func(context.Context) error {
+			r.record("shutdown " + name)
+			return nil
+		}),
+	}
+}
+
+func TestCloseScrapersInReverseOrder(t *testing.T) {
+	recorder := &testOrderRecorder{}
+	tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+	tsrm := &testScrapeResourceMetrics{ch: make(chan int, 1)}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("first", tsm.scrape, recorder.options("first")...)),
+		AddResourceMetricsScraper(NewResourceMetricsScraper("second", tsrm.scrape, recorder.options("second")...)),
+		AddMetricsScraper(NewMetricsScraper("third", tsm.scrape, recorder.options("third")...)),
+		WithTickerChannel(make(chan time.Time)),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	assert.Equal(t, []string{
+		"start first", "start second", "start third",
+		"shutdown third", "shutdown second", "shutdown first",
+	}, recorder.order)
+}
+
+func TestCloseScrapersInReverseOrderAfterFailedInit(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		t.Run(fmt.Sprintf("parallel=%v", parallel), func(t *testing.T) {
+			recorder := &testOrderRecorder{}
+			tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+			failingStart := WithStart(func(context.Context, component.Host) error {
+				return errors.New("err1")
+			})
+
+			options := []ScraperControllerOption{
+				AddMetricsScraper(NewMetricsScraper("first", tsm.scrape, recorder.options("first")...)),
+				AddMetricsScraper(NewMetricsScraper("second", tsm.scrape, recorder.options("second")...)),
+				AddMetricsScraper(NewMetricsScraper("third", tsm.scrape, append(recorder.options("third"), failingStart)...)),
+				WithTickerChannel(make(chan time.Time)),
+			}
+			if parallel {
+				options = append(options, WithParallelInit(1))
+			}
+			defaultCfg := DefaultScraperControllerSettings("receiver")
+			receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), options...)
+			require.NoError(t, err)
+
+			err = receiver.Start(context.Background(), componenttest.NewNopHost())
+			assert.EqualError(t, err, `failed to initialize scraper "third": err1`)
+
+			// the started scrapers are closed by Start, not by Shutdown.
+			expected := []string{
+				"start first", "start second",
+				"shutdown second", "shutdown first",
+			}
+			assert.Equal(t, expected, recorder.order)
+			require.NoError(t, receiver.Shutdown(context.Background()))
+			assert.Equal(t, expected, recorder.order)
+		})
+	}
+}
+
+func TestRestart(t *testing.T) {
+	initializeCh := make(chan bool, 1)
+	ti := &testInitialize{ch: initializeCh}
+	closeCh := make(chan bool, 1)
+	tc := &testClose{ch: closeCh}
+	tsm := &testScrapeMetrics{ch: make(chan int, 1000)}
+
+	cfg := DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = time.Millisecond
+	sink := new(consumertest.MetricsSink)
+	receiver, err := NewScraperControllerReceiver(
+		&cfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape, WithStart(ti.start), WithShutdown(tc.shutdown))),
+	)
+	require.NoError(t, err)
+
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 2; i++ {
+		sink.Reset()
+
+		require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+		assertChannelCalled(t, initializeCh, "start was not called")
+
+		require.Eventually(t, func() bool { return sink.MetricsCount() > 0 }, time.Second, time.Millisecond)
+
+		require.NoError(t, receiver.Shutdown(context.Background()))
+		assertChannelCalled(t, closeCh, "shutdown was not called")
+
+		// drain scrape notifications so the scrape function never blocks
+		for len(tsm.ch) > 0 {
+			<-tsm.ch
+		}
+
+		assertNoGoroutineLeak(t, goroutines)
+	}
+}
+
+// assertNoGoroutineLeak waits for the number of goroutines to return to the
+// given count. require.Eventually is not used as it runs the condition in a
+// separate goroutine.
+func assertNoGoroutineLeak(t *testing.T, goroutines int) {
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines {
+		if time.Now().After(deadline) {
+			assert.Fail(t, "goroutines leaked", "expected at most %d goroutines, got %d", goroutines, runtime.NumGoroutine())
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newManyScrapersReceiver creates a receiver with the given number of
+// scrapers, scraped every millisecond unless a ticker channel is given.
+func newManyScrapersReceiver(t testing.TB, scrapers int, sink *consumertest.MetricsSink, options ...ScraperControllerOption) component.MetricsReceiver {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	for i := 0; i < scrapers; i++ {
+		options = append(options, AddMetricsScraper(NewMetricsScraper(fmt.Sprintf("scraper%d", i), scrape)))
+	}
+	cfg := DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = time.Millisecond
+	receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), sink, options...)
+	require.NoError(t, err)
+	return receiver
+}
+
+func TestScrapersShareSchedulerGoroutine(t *testing.T) {
+	sink := new(consumertest.MetricsSink)
+	receiver := newManyScrapersReceiver(t, 50, sink)
+
+	goroutines := runtime.NumGoroutine()
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.Eventually(t, func() bool { return sink.MetricsCount() >= 50 }, time.Second, time.Millisecond)
+	// a single goroutine schedules all the scrapers, with a single ticker.
+	assert.LessOrEqual(t, runtime.NumGoroutine()-goroutines, 1)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assertNoGoroutineLeak(t, goroutines)
+}
+
+func BenchmarkScrapeLoop50Scrapers(b *testing.B) {
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	receiver := newManyScrapersReceiver(b, 50, sink, WithTickerChannel(tickerCh))
+
+	goroutines := runtime.NumGoroutine()
+	require.NoError(b, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	started := runtime.NumGoroutine() - goroutines
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tickerCh <- time.Now()
+		for len(sink.AllMetrics()) == 0 {
+			runtime.Gosched()
+		}
+		sink.Reset()
+	}
+	b.StopTimer()
+	// the number of goroutines started by the receiver does not depend on
+	// the number of scrapers.
+	b.ReportMetric(float64(started), "goroutines")
+	require.NoError(b, receiver.Shutdown(context.Background()))
+}
+
+func TestMissedTicksAreSkipped(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	const interval = time.Minute
+	start := time.Now()
+	var mu sync.Mutex
+	now := start
+	var scrapes int
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// the first scrape spans 3.5 intervals.
+		if scrapes++; scrapes == 1 {
+			now = now.Add(interval * 7 / 2)
+		}
+		return singleMetric(), nil
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	core, logs := observer.New(zap.WarnLevel)
+	cfg := DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = interval
+	receiver, err := NewScraperControllerReceiver(&cfg, zap.New(core), sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithUniformTimestamps(TimestampTick),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	receiver.(*controller).now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	// the ticks of the deadlines missed during the first scrape are all
+	// delivered, as a custom scheduler could, and are skipped.
+	for i := 0; i <= 5; i++ {
+		tickerCh <- start.Add(time.Duration(i) * interval)
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	var scrapedOn []time.Duration
+	for _, md := range sink.AllMetrics() {
+		timestamps, _ := dataPointTimestamps(md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0))
+		scrapedOn = append(scrapedOn, time.Duration(int64(timestamps[0])-start.UnixNano()))
+	}
+	assert.Equal(t, []time.Duration{0, 4 * interval, 5 * interval}, scrapedOn)
+	skipped := logs.FilterMessage("Skipped ticks missed during a long scrape").All()
+	require.Len(t, skipped, 1)
+	assert.EqualValues(t, 3, skipped[0].ContextMap()["skipped_ticks"])
+	obsreporttest.CheckScraperSkippedTicksView(t, "receiver", 3)
+}
+
+// newSteadyStateReceiver returns a started receiver with scrapers that scrape
+// no metrics, and the context its scrape loop scrapes with, so that only the
+// allocations of the receiver itself are measured.
+func newSteadyStateReceiver(t testing.TB, scrapers int, options ...ScraperControllerOption) (*controller, context.Context) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return pdata.NewMetricSlice(), nil }
+	options = append(options, WithTickerChannel(make(chan time.Time)))
+	for i := 0; i < scrapers; i++ {
+		options = append(options, AddMetricsScraper(NewMetricsScraper(fmt.Sprintf("scraper%d", i), scrape)))
+	}
+	cfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), consumertest.NewMetricsNop(), options...)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	return receiver.(*controller), obsreport.ReceiverContext(context.Background(), "receiver", "")
+}
+
+// maxTickAllocs bounds the allocations of a tick scraping a single scraper
+// once the receiver is in a steady state, most of which are made by the
+// observability of the scrape and of the consume, depending on the views
+// registered by other tests.
+const maxTickAllocs = 35
+
+func TestScrapeAndReportAllocations(t *testing.T) {
+	// sampled spans allocate their attributes, so spans are sampled as by
+	// default, which is almost never.
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+	sc, ctx := newSteadyStateReceiver(t, 1)
+	defer func() { require.NoError(t, sc.Shutdown(context.Background())) }()
+
+	allocs := testing.AllocsPerRun(100, func() { sc.scrapeMetricsAndReport(ctx, time.Now()) })
+	assert.LessOrEqual(t, allocs, float64(maxTickAllocs))
+}
+
+// maxTickOverheadAllocs bounds the allocations of a tick besides those of the
+// scrapes, which are those of the payload passed to the next consumer, and of
+// the observability of the consume.
+const maxTickOverheadAllocs = 16
+
+// tickOverheadAllocs returns the allocations of a tick of the receiver, and
+// of the scrapes of its scrapers.
+func tickOverheadAllocs(sc *controller, ctx context.Context, runs int) (tick, scrapes float64) {
+	tick = testing.AllocsPerRun(runs, func() { sc.scrapeMetricsAndReport(ctx, time.Now()) })
+	for _, ms := range sc.metricsScrapers.scrapers {
+		scrapes += testing.AllocsPerRun(runs, func() { _, _ = ms.Scrape(ctx, sc.name) })
+	}
+	return tick, scrapes
+}
+
+func TestScrapeAndReportOverheadAllocations(t *testing.T) {
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+	sc, ctx := newSteadyStateReceiver(t, 10)
+	defer func() { require.NoError(t, sc.Shutdown(context.Background())) }()
+
+	// the overhead of a tick does not depend on the number of scrapers.
+	tick, scrapes := tickOverheadAllocs(sc, ctx, 100)
+	assert.LessOrEqual(t, tick-scrapes, float64(maxTickOverheadAllocs))
+}
+
+func BenchmarkScrapeAndReportOverhead(b *testing.B) {
+	sc, ctx := newSteadyStateReceiver(b, 10)
+	defer func() { require.NoError(b, sc.Shutdown(context.Background())) }()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sc.scrapeMetricsAndReport(ctx, time.Now())
+	}
+	b.StopTimer()
+	tick, scrapes := tickOverheadAllocs(sc, ctx, 100)
+	b.ReportMetric(tick-scrapes, "overhead-allocs/op")
+}
+
+func BenchmarkScrapeAndReport(b *testing.B) {
+	sc, ctx := newSteadyStateReceiver(b, 10)
+	defer func() { require.NoError(b, sc.Shutdown(context.Background())) }()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sc.scrapeMetricsAndReport(ctx, time.Now())
+	}
+}
+
+func TestAddAndRemoveScraper(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	tsm1 := &testScrapeMetrics{ch: make(chan int, 10)}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper1", tsm1.scrape)),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+
+	// scrapers added before start are initialized on start
+	initializeCh2 := make(chan bool, 1)
+	closeCh2 := make(chan bool, 1)
+	tsm2 := &testScrapeMetrics{ch: make(chan int, 10)}
+	scraper2 := NewMetricsScraper("scraper2", tsm2.scrape,
+		WithStart((&testInitialize{ch: initializeCh2}).start),
+		WithShutdown((&testClose{ch: closeCh2}).shutdown))
+	require.NoError(t, receiver.(ScraperManager).AddScraper(context.Background(), scraper2))
+	assert.Equal(t, 0, len(initializeCh2))
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	assertChannelCalled(t, initializeCh2, "start was not called")
+
+	// scrapers added while running are initialized immediately
+	initializeCh3 := make(chan bool, 1)
+	closeCh3 := make(chan bool, 1)
+	tsrm3 := &testScrapeResourceMetrics{ch: make(chan int, 10)}
+	scraper3 := NewResourceMetricsScraper("scraper3", tsrm3.scrape,
+		WithStart((&testInitialize{ch: initializeCh3}).start),
+		WithShutdown((&testClose{ch: closeCh3}).shutdown))
+	require.NoError(t, receiver.(ScraperManager).AddScraper(context.Background(), scraper3))
+	assertChannelCalled(t, initializeCh3, "start was not called")
+
+	assert.EqualError(t, receiver.(ScraperManager).AddScraper(context.Background(), NewMetricsScraper("scraper1", tsm1.scrape)), `scraper "scraper1" already exists`)
+
+	tickerCh <- time.Now()
+	assert.Equal(t, 1, <-tsm1.ch)
+	assert.Equal(t, 1, <-tsm2.ch)
+	assert.Equal(t, 1, <-tsrm3.ch)
+	require.Eventually(t, func() bool { return sink.MetricsCount() == 3 }, time.Second, time.Millisecond)
+
+	id := ScraperID{Receiver: "receiver", Scraper: "scraper2"}
+	require.NoError(t, receiver.(ScraperManager).RemoveScraper(context.Background(), id))
+	assertChannelCalled(t, closeCh2, "shutdown was not called")
+
+	err = receiver.(ScraperManager).RemoveScraper(context.Background(), id)
+	var notFoundErr *ScraperNotFoundError
+	require.True(t, errors.As(err, &notFoundErr))
+	assert.Equal(t, id, notFoundErr.ID)
+	assert.EqualError(t, err, `scraper "receiver/scraper2" not found`)
+
+	// the scrapers of other receivers are not found.
+	err = receiver.(ScraperManager).RemoveScraper(context.Background(), ScraperID{Receiver: "other", Scraper: "scraper1"})
+	require.True(t, errors.As(err, &notFoundErr))
+	assert.Equal(t, ScraperID{Receiver: "other", Scraper: "scraper1"}, notFoundErr.ID)
+
+	tickerCh <- time.Now()
+	assert.Equal(t, 2, <-tsm1.ch)
+	assert.Equal(t, 2, <-tsrm3.ch)
+	require.Eventually(t, func() bool { return sink.MetricsCount() == 5 }, time.Second, time.Millisecond)
+	assert.Equal(t, 0, len(tsm2.ch))
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assertChannelCalled(t, closeCh3, "shutdown was not called")
+	assert.Equal(t, 0, len(closeCh2), "removed scraper was closed twice")
+
+	assert.EqualError(t, receiver.(ScraperManager).AddScraper(context.Background(), scraper2), `cannot add scraper "scraper2" to a receiver in state Stopped`)
+}
+
+func TestAddAndRemoveScraperConcurrently(t *testing.T) {
+	cfg := DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = time.Millisecond
+	receiver, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), new(consumertest.MetricsSink), WithAllowEmptyScrapers())
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("scraper%d", i)
+			for j := 0; j < 50; j++ {
+				scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+				assert.NoError(t, receiver.(ScraperManager).AddScraper(context.Background(), NewMetricsScraper(name, scrape)))
+				assert.NoError(t, receiver.(ScraperManager).RemoveScraper(context.Background(), ScraperID{Receiver: "receiver", Scraper: name}))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+func TestStartEx(t *testing.T) {
+	var info StartInfo
+	startEx := func(ctx context.Context, _ component.Host, si StartInfo) error {
+		info = si
+		md := pdata.NewMetrics()
+		md.ResourceMetrics().Resize(1)
+		return si.NextConsumer.ConsumeMetrics(ctx, md)
+	}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	sink := new(consumertest.MetricsSink)
+	tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+	tsrm := &testScrapeResourceMetrics{ch: make(chan int, 1)}
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper1", tsm.scrape, WithStartEx(startEx))),
+		AddResourceMetricsScraper(NewResourceMetricsScraper("scraper2", tsrm.scrape)),
+		WithTickerChannel(make(chan time.Time)),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	assert.Equal(t, "receiver", info.ReceiverName)
+	assert.Equal(t, sink, info.NextConsumer)
+	assert.Equal(t, []ScraperInfo{
+		{ID: ScraperID{Receiver: "receiver", Scraper: "scraper1"}, CollectionInterval: time.Minute},
+		{ID: ScraperID{Receiver: "receiver", Scraper: "scraper2"}, CollectionInterval: time.Minute},
+	}, info.Scrapers)
+	assert.Len(t, sink.AllMetrics(), 1)
+}
+
+func TestStartExConflictsWithStart(t *testing.T) {
+	start := func(context.Context, component.Host) error { return nil }
+	startEx := func(context.Context, component.Host, StartInfo) error { return nil }
+	tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+	scraper := NewMetricsScraper("scraper", tsm.scrape, WithStart(start), WithStartEx(startEx))
+
+	assert.EqualError(t, scraper.Start(context.Background(), componenttest.NewNopHost()), "only one of WithStart and WithStartEx can be set")
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), AddMetricsScraper(scraper))
+	assert.EqualError(t, err, `invalid scraper "scraper": only one of WithStart and WithStartEx can be set`)
+}
+
+func TestInitTimeout(t *testing.T) {
+	var inFlight int64
+	blockingStart := func(ctx context.Context, _ component.Host) error {
+		atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name    string
+		options []ScraperControllerOption
+		scraper []ScraperOption
+	}{
+		{
+			name:    "ScraperTimeout",
+			scraper: []ScraperOption{WithStart(blockingStart), WithInitTimeout(10 * time.Millisecond)},
+		},
+		{
+			name:    "ReceiverDefault",
+			options: []ScraperControllerOption{WithDefaultInitTimeout(10 * time.Millisecond)},
+			scraper: []ScraperOption{WithStart(blockingStart)},
+		},
+		{
+			name:    "ScraperTimeoutOverridesReceiverDefault",
+			options: []ScraperControllerOption{WithDefaultInitTimeout(time.Hour)},
+			scraper: []ScraperOption{WithStart(blockingStart), WithInitTimeout(10 * time.Millisecond)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+			options := append([]ScraperControllerOption{
+				AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape, test.scraper...)),
+				WithTickerChannel(make(chan time.Time)),
+			}, test.options...)
+
+			defaultCfg := DefaultScraperControllerSettings("receiver")
+			receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), options...)
+			require.NoError(t, err)
+
+			err = receiver.Start(context.Background(), componenttest.NewNopHost())
+			assert.EqualError(t, err, `failed to initialize scraper "scraper": initialize timed out after 10ms: context deadline exceeded`)
+			assert.True(t, errors.Is(err, context.DeadlineExceeded))
+			// the start function returned before Start did.
+			assert.Equal(t, int64(0), atomic.LoadInt64(&inFlight))
+		})
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	var inFlight int64
+	blockingShutdown := func(ctx context.Context) error {
+		atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	closeCh := make(chan bool, 1)
+	tc := &testClose{ch: closeCh}
+
+	tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper1", tsm.scrape, WithShutdown(tc.shutdown))),
+		AddMetricsScraper(NewMetricsScraper("scraper2", tsm.scrape, WithShutdown(blockingShutdown))),
+		WithDefaultCloseTimeout(10*time.Millisecond),
+		WithTickerChannel(make(chan time.Time)),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	err = receiver.Shutdown(context.Background())
+	assert.EqualError(t, err, "close timed out after 10ms: context deadline exceeded")
+	assert.Equal(t, int64(0), atomic.LoadInt64(&inFlight))
+	assertChannelCalled(t, closeCh, "shutdown was not called after a previous scraper timed out")
+}
+
+func TestShutdownErrorsDeterministic(t *testing.T) {
+	errDisk := errors.New("disk busy")
+	shutdownWith := func(err error) func(context.Context) error {
+		return func(context.Context) error { return err }
+	}
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+
+	// the scrapers are closed in the reverse order of their registration.
+	for _, names := range [][]string{{"scraper1", "scraper2", "scraper3"}, {"scraper3", "scraper2", "scraper1"}} {
+		closeErrs := map[string]error{
+			"scraper1": fmt.Errorf("failed to close /proc: %w", errDisk),
+			"scraper2": errors.New("connection reset"),
+			"scraper3": errors.New("connection reset"),
+		}
+		options := []ScraperControllerOption{WithTickerChannel(make(chan time.Time))}
+		for _, name := range names {
+			options = append(options, AddMetricsScraper(NewMetricsScraper(name, scrape, WithShutdown(shutdownWith(closeErrs[name])))))
+		}
+		defaultCfg := DefaultScraperControllerSettings("receiver")
+		receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), options...)
+		require.NoError(t, err)
+		require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+		err = receiver.Shutdown(context.Background())
+		assert.EqualError(t, err, "[connection reset (x2); failed to close /proc: disk busy]")
+		assert.True(t, errors.Is(err, errDisk))
+		var combined *componenterror.CombinedError
+		require.True(t, errors.As(err, &combined))
+		assert.Len(t, combined.Errors(), 3)
+	}
+}
+
+func TestAllowEmptyScrapers(t *testing.T) {
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink))
+	assert.EqualError(t, err, `receiver "receiver" has no scrapers`)
+
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), WithAllowEmptyScrapers())
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+func TestDisabledScrapers(t *testing.T) {
+	var calls int64
+	start := func(context.Context, component.Host) error {
+		atomic.AddInt64(&calls, 1)
+		return nil
+	}
+	shutdown := func(context.Context) error {
+		atomic.AddInt64(&calls, 1)
+		return nil
+	}
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		atomic.AddInt64(&calls, 1)
+		return singleMetric(), nil
+	}
+	scrapeResource := func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		atomic.AddInt64(&calls, 1)
+		return singleResourceMetric(), nil
+	}
+	disabled := []ScraperOption{
+		WithEnabled(false),
+		WithStart(start),
+		WithShutdown(shutdown),
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("enabled", func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }, WithEnabled(true))),
+		AddMetricsScraper(NewMetricsScraper("disabled", scrape, disabled...)),
+		AddResourceMetricsScraper(NewResourceMetricsScraper("disabled_resource", scrapeResource, disabled...)),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, receiver.(ScraperManager).AddScraper(context.Background(), NewMetricsScraper("disabled_later", scrape, disabled...)))
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	assert.Equal(t, int64(0), atomic.LoadInt64(&calls))
+	assert.Equal(t, 1, sink.MetricsCount())
+	assert.Equal(t, []DisabledScraper{
+		{ID: ScraperID{Receiver: "receiver", Scraper: "disabled"}, Reason: DisabledByConfig},
+		{ID: ScraperID{Receiver: "receiver", Scraper: "disabled_resource"}, Reason: DisabledByConfig},
+		{ID: ScraperID{Receiver: "receiver", Scraper: "disabled_later"}, Reason: DisabledByConfig},
+	}, receiver.(ScraperInspector).DisabledScrapers())
+}
+
+func TestAllScrapersDisabled(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper1", scrape, WithEnabled(false))),
+		AddMetricsScraper(NewMetricsScraper("scraper2", scrape, WithEnabled(false))),
+	)
+	assert.EqualError(t, err, `receiver "receiver" has no scrapers`)
+}
+
+func TestZeroCollectionInterval(t *testing.T) {
+	var calls int64
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		atomic.AddInt64(&calls, 1)
+		return singleMetric(), nil
+	}
+	start := func(context.Context, component.Host) error {
+		atomic.AddInt64(&calls, 1)
+		return nil
+	}
+	cfg := DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = 0
+	options := []ScraperControllerOption{
+		AddMetricsScraper(NewMetricsScraper("flag", scrape, WithEnabled(false))),
+		AddMetricsScraper(NewMetricsScraper("interval", scrape, WithStart(start))),
+	}
+
+	// by default, a collection interval of zero is rejected.
+	_, err := NewScraperControllerReceiver(&cfg, zap.NewNop(), new(consumertest.MetricsSink), options...)
+	assert.EqualError(t, err, "collection_interval must be a positive duration")
+
+	core, logs := observer.New(zap.InfoLevel)
+	sink := new(consumertest.MetricsSink)
+	receiver, err := NewScraperControllerReceiver(&cfg, zap.New(core), sink, append(options, WithZeroIntervalDisables())...)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, receiver.(ScraperManager).AddScraper(context.Background(), NewMetricsScraper("added", scrape, WithStart(start))))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	assert.Equal(t, int64(0), atomic.LoadInt64(&calls))
+	assert.Len(t, sink.AllMetrics(), 0)
+	assert.Equal(t, []DisabledScraper{
+		{ID: ScraperID{Receiver: "receiver", Scraper: "flag"}, Reason: DisabledByConfig},
+		{ID: ScraperID{Receiver: "receiver", Scraper: "interval"}, Reason: DisabledByZeroInterval},
+		{ID: ScraperID{Receiver: "receiver", Scraper: "added"}, Reason: DisabledByZeroInterval},
+	}, receiver.(ScraperInspector).DisabledScrapers())
+	require.Equal(t, 1, logs.FilterMessage("Scraping disabled by a collection interval of zero").Len())
+}
+
+func TestCapabilities(t *testing.T) {
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), WithAllowEmptyScrapers())
+	require.NoError(t, err)
+	assert.Equal(t, component.ProcessorCapabilities{MutatesConsumedData: false}, receiver.(*controller).GetCapabilities())
+
+	receiver, err = NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		WithAllowEmptyScrapers(),
+		WithCapabilities(component.ProcessorCapabilities{MutatesConsumedData: true}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, component.ProcessorCapabilities{MutatesConsumedData: true}, receiver.(*controller).GetCapabilities())
+}
+
+func TestResourceAttributes(t *testing.T) {
+	scrapeResource := func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		rms := singleResourceMetric()
+		rms.At(0).Resource().Attributes().InsertString("deployment.environment", "set-by-scraper")
+		return rms, nil
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddResourceMetricsScraper(NewResourceMetricsScraper("resource", scrapeResource)),
+		AddMetricsScraper(NewMetricsScraper("metrics", func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil })),
+		WithResourceAttributes(map[string]string{
+			"k8s.cluster.name":       "cluster",
+			"deployment.environment": "production",
+		}),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	assert.True(t, receiver.(*controller).GetCapabilities().MutatesConsumedData)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	rms := sink.AllMetrics()[0].ResourceMetrics()
+	require.Equal(t, 2, rms.Len())
+
+	expected := []map[string]string{
+		{"k8s.cluster.name": "cluster", "deployment.environment": "set-by-scraper"},
+		{"k8s.cluster.name": "cluster", "deployment.environment": "production"},
+	}
+	for i, want := range expected {
+		got := map[string]string{}
+		rms.At(i).Resource().Attributes().ForEach(func(k string, v pdata.AttributeValue) {
+			got[k] = v.StringVal()
+		})
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestResourceAttributesEmptyKey(t *testing.T) {
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		WithAllowEmptyScrapers(),
+		WithResourceAttributes(map[string]string{"": "value"}),
+	)
+	assert.EqualError(t, err, "resource attribute keys must not be empty")
+}
+
+func TestMetricsTransformer(t *testing.T) {
+	scrapeMetrics := func(context.Context) (pdata.MetricSlice, error) {
+		metrics := pdata.NewMetricSlice()
+		metrics.Resize(3)
+		for i, name := range []string{"keep", "rename", "drop"} {
+			metrics.At(i).SetName(name)
+		}
+		return metrics, nil
+	}
+	rename := func(_ context.Context, md pdata.Metrics) (pdata.Metrics, error) {
+		metrics := md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+		for i := 0; i < metrics.Len(); i++ {
+			if metrics.At(i).Name() == "rename" {
+				metrics.At(i).SetName("renamed")
+			}
+		}
+		return md, nil
+	}
+	drop := func(_ context.Context, md pdata.Metrics) (pdata.Metrics, error) {
+		out := pdata.NewMetrics()
+		out.ResourceMetrics().Resize(1)
+		out.ResourceMetrics().At(0).InstrumentationLibraryMetrics().Resize(1)
+		outMetrics := out.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+
+		metrics := md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+		for i := 0; i < metrics.Len(); i++ {
+			if metrics.At(i).Name() != "drop" {
+				outMetrics.Append(metrics.At(i))
+			}
+		}
+		return out, nil
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrapeMetrics)),
+		WithMetricsTransformer(rename),
+		WithMetricsTransformer(drop),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	assert.True(t, receiver.(*controller).GetCapabilities().MutatesConsumedData)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	metrics := sink.AllMetrics()[0].ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+	require.Equal(t, 2, metrics.Len())
+	assert.Equal(t, "keep", metrics.At(0).Name())
+	assert.Equal(t, "renamed", metrics.At(1).Name())
+}
+
+func TestMetricsTransformerErrorAndEmptyPayload(t *testing.T) {
+	for _, transformer := range []MetricsTransformer{
+		func(_ context.Context, md pdata.Metrics) (pdata.Metrics, error) {
+			return md, errors.New("err1")
+		},
+		func(context.Context, pdata.Metrics) (pdata.Metrics, error) {
+			return pdata.NewMetrics(), nil
+		},
+	} {
+		tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+		sink := new(consumertest.MetricsSink)
+		defaultCfg := DefaultScraperControllerSettings("receiver")
+		require.NoError(t, ScrapeCycle(
+			context.Background(),
+			&defaultCfg,
+			zap.NewNop(),
+			sink,
+			componenttest.NewNopHost(),
+			AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape)),
+			WithMetricsTransformer(transformer),
+		))
+
+		assert.Equal(t, 1, tsm.timesScrapeCalled)
+		assert.Len(t, sink.AllMetrics(), 0)
+	}
+}
+
+func TestMetricsTransformerErrorRecordsFailedScrape(t *testing.T) {
+	done, err := obsreporttest.SetupRecordedMetricsTest()
+	require.NoError(t, err)
+	defer done()
+
+	tickerCh := make(chan time.Time)
+	tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape)),
+		WithMetricsTransformer(func(_ context.Context, md pdata.Metrics) (pdata.Metrics, error) {
+			return md, errors.New("err1")
+		}),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	<-tsm.ch
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	// the scraper succeeded, but the scrape of the receiver failed with all
+	// the scraped metrics errored.
+	obsreporttest.CheckScraperMetricsViews(t, "receiver", "scraper", 1, 0)
+	obsreporttest.CheckScraperMetricsViews(t, "receiver", "", 0, 1)
+}
+
+func TestMetricsTransformerEmptyPayloadCompletesFirstScrape(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape)),
+		WithMetricsTransformer(func(context.Context, pdata.Metrics) (pdata.Metrics, error) {
+			return pdata.NewMetrics(), nil
+		}),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, receiver.(FirstScrapeWaiter).WaitForFirstScrape(ctx))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+func TestScrapePredicate(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	results := []bool{false, false, true, false, true}
+	resultsCh := make(chan bool, len(results))
+	for _, result := range results {
+		resultsCh <- result
+	}
+	predicate := func(context.Context) bool { return <-resultsCh }
+
+	tickerCh := make(chan time.Time)
+	tsm := &testScrapeMetrics{ch: make(chan int, 10)}
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape, WithScrapePredicate(predicate))),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	for range results {
+		tickerCh <- time.Now()
+	}
+	require.Eventually(t, func() bool { return len(resultsCh) == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	assert.Equal(t, 2, tsm.timesScrapeCalled)
+	obsreporttest.CheckScraperFilteredScrapesView(t, "receiver", "scraper", 3)
+	obsreporttest.CheckScraperMetricsViews(t, "receiver", "scraper", 2, 0)
+}
+
+func TestScrapePredicatePanics(t *testing.T) {
+	predicate := func(context.Context) bool { panic("predicate") }
+
+	tsm := &testScrapeMetrics{ch: make(chan int, 10)}
+	scraper := NewMetricsScraper("scraper", tsm.scrape, WithScrapePredicate(predicate))
+	_, err := scraper.Scrape(context.Background(), "receiver")
+	assert.EqualError(t, err, "scrape predicate panicked: predicate")
+	assert.Equal(t, 0, tsm.timesScrapeCalled)
+}
+
+func TestMetricNamePrefix(t *testing.T) {
+	scrapeMetrics := func(context.Context) (pdata.MetricSlice, error) {
+		metrics := pdata.NewMetricSlice()
+		metrics.Resize(2)
+		metrics.At(0).SetName("cpu.time")
+		metrics.At(1).SetName("acme.hostmetrics.memory.usage")
+		return metrics, nil
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrapeMetrics)),
+		WithMetricNamePrefix("acme.hostmetrics."),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	assert.True(t, receiver.(*controller).GetCapabilities().MutatesConsumedData)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	metrics := sink.AllMetrics()[0].ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+	require.Equal(t, 2, metrics.Len())
+	assert.Equal(t, "acme.hostmetrics.cpu.time", metrics.At(0).Name())
+	assert.Equal(t, "acme.hostmetrics.memory.usage", metrics.At(1).Name())
+}
+
+func TestInvalidMetricNamePrefix(t *testing.T) {
+	for _, prefix := range []string{"1acme.", ".acme", "acme hostmetrics."} {
+		defaultCfg := DefaultScraperControllerSettings("receiver")
+		_, err := NewScraperControllerReceiver(
+			&defaultCfg,
+			zap.NewNop(),
+			new(consumertest.MetricsSink),
+			WithAllowEmptyScrapers(),
+			WithMetricNamePrefix(prefix),
+		)
+		assert.EqualError(t, err, fmt.Sprintf("invalid metric name prefix %q", prefix))
+	}
+}
+
+func TestUniformTimestamps(t *testing.T) {
+	start := pdata.TimestampUnixNano(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	scrapeMetrics := func(context.Context) (pdata.MetricSlice, error) {
+		metrics := metricsOfAllTypes()
+		setTimestamps(metrics, pdata.TimestampUnixNano(time.Now().UnixNano()))
+		for i := 0; i < metrics.Len(); i++ {
+			setStartTimestamps(metrics.At(i), start)
+		}
+		return metrics, nil
+	}
+
+	tick := time.Date(2021, 2, 1, 10, 0, 0, 0, time.UTC)
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper1", scrapeMetrics)),
+		AddMetricsScraper(NewMetricsScraper("scraper2", scrapeMetrics)),
+		WithUniformTimestamps(TimestampTick),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- tick
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	metrics := sink.AllMetrics()[0].ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+	require.Equal(t, 2*len(allMetricDataTypes), metrics.Len())
+	for i := 0; i < metrics.Len(); i++ {
+		timestamps, startTimestamps := dataPointTimestamps(metrics.At(i))
+		require.Len(t, timestamps, 1)
+		assert.Equal(t, pdata.TimestampUnixNano(tick.UnixNano()), timestamps[0], metrics.At(i).DataType().String())
+		if metrics.At(i).DataType() != pdata.MetricDataTypeIntGauge && metrics.At(i).DataType() != pdata.MetricDataTypeDoubleGauge {
+			assert.Equal(t, start, startTimestamps[0], metrics.At(i).DataType().String())
+		}
+	}
+}
+
+func setStartTimestamps(metric pdata.Metric, ts pdata.TimestampUnixNano) {
+	switch metric.DataType() {
+	case pdata.MetricDataTypeIntSum:
+		metric.IntSum().DataPoints().At(0).SetStartTime(ts)
+	case pdata.MetricDataTypeDoubleSum:
+		metric.DoubleSum().DataPoints().At(0).SetStartTime(ts)
+	case pdata.MetricDataTypeIntHistogram:
+		metric.IntHistogram().DataPoints().At(0).SetStartTime(ts)
+	case pdata.MetricDataTypeDoubleHistogram:
+		metric.DoubleHistogram().DataPoints().At(0).SetStartTime(ts)
+	case pdata.MetricDataTypeDoubleSummary:
+		metric.DoubleSummary().DataPoints().At(0).SetStartTime(ts)
+	}
+}
+
+func dataPointTimestamps(metric pdata.Metric) (timestamps, startTimestamps []pdata.TimestampUnixNano) {
+	switch metric.DataType() {
+	case pdata.MetricDataTypeIntGauge:
+		dps := metric.IntGauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			timestamps = append(timestamps, dps.At(i).Timestamp())
+			startTimestamps = append(startTimestamps, dps.At(i).StartTime())
+		}
+	case pdata.MetricDataTypeDoubleGauge:
+		dps := metric.DoubleGauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			timestamps = append(timestamps, dps.At(i).Timestamp())
+			startTimestamps = append(startTimestamps, dps.At(i).StartTime())
+		}
+	case pdata.MetricDataTypeIntSum:
+		dps := metric.IntSum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			timestamps = append(timestamps, dps.At(i).Timestamp())
+			startTimestamps = append(startTimestamps, dps.At(i).StartTime())
+		}
+	case pdata.MetricDataTypeDoubleSum:
+		dps := metric.DoubleSum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			timestamps = append(timestamps, dps.At(i).Timestamp())
+			startTimestamps = append(startTimestamps, dps.At(i).StartTime())
+		}
+	case pdata.MetricDataTypeIntHistogram:
+		dps := metric.IntHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			timestamps = append(timestamps, dps.At(i).Timestamp())
+			startTimestamps = append(startTimestamps, dps.At(i).StartTime())
+		}
+	case pdata.MetricDataTypeDoubleHistogram:
+		dps := metric.DoubleHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			timestamps = append(timestamps, dps.At(i).Timestamp())
+			startTimestamps = append(startTimestamps, dps.At(i).StartTime())
+		}
+	case pdata.MetricDataTypeDoubleSummary:
+		dps := metric.DoubleSummary().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			timestamps = append(timestamps, dps.At(i).Timestamp())
+			startTimestamps = append(startTimestamps, dps.At(i).StartTime())
+		}
+	}
+	return timestamps, startTimestamps
+}
+
+func TestUniformTimestampsScrapeStart(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", func(context.Context) (pdata.MetricSlice, error) { return metricsOfAllTypes(), nil })),
+		WithUniformTimestamps(TimestampScrapeStart),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	before := pdata.TimestampUnixNano(time.Now().UnixNano())
+	tickerCh <- time.Time{}
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	after := pdata.TimestampUnixNano(time.Now().UnixNano())
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	metrics := sink.AllMetrics()[0].ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+	first, _ := dataPointTimestamps(metrics.At(0))
+	assert.True(t, first[0] >= before && first[0] <= after)
+	for i := 0; i < metrics.Len(); i++ {
+		timestamps, _ := dataPointTimestamps(metrics.At(i))
+		assert.Equal(t, first, timestamps)
+	}
+}
+
+func TestForwardEvery(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	// the second and fifth scrapes fail, and do not count towards n.
+	scrapeErrs := []error{nil, errors.New("err1"), nil, nil, errors.New("err2"), nil, nil, nil, nil, nil, nil}
+	scrapes := make(chan struct{}, len(scrapeErrs))
+	i := 0
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		err := scrapeErrs[i]
+		i++
+		scrapes <- struct{}{}
+		if err != nil {
+			return pdata.NewMetricSlice(), err
+		}
+		return singleMetric(), nil
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape, WithForwardEvery(3))),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	for range scrapeErrs {
+		tickerCh <- time.Now()
+		<-scrapes
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	// 9 successful scrapes forward 3 times, and the failed scrapes are
+	// forwarded as empty payloads.
+	require.Len(t, sink.AllMetrics(), 5)
+	assert.Equal(t, 3, sink.MetricsCount())
+	obsreporttest.CheckScraperNotForwardedScrapesView(t, "receiver", "scraper", 6)
+}
+
+type failingBatchConsumer struct {
+	consumertest.MetricsSink
+	calls int
+	fail  map[int]bool
+}
+
+func (c *failingBatchConsumer) ConsumeMetrics(ctx context.Context, md pdata.Metrics) error {
+	c.calls++
+	if c.fail[c.calls] {
+		return errors.New("rejected")
+	}
+	return c.MetricsSink.ConsumeMetrics(ctx, md)
+}
+
+type blockingConsumer struct {
+	consumertest.MetricsSink
+	release chan struct{}
+}
+
+func (c *blockingConsumer) ConsumeMetrics(ctx context.Context, md pdata.Metrics) error {
+	select {
+	case <-c.release:
+		return c.MetricsSink.ConsumeMetrics(ctx, md)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestAsyncConsume(t *testing.T) {
+	for _, test := range []struct {
+		name           string
+		policy         DropPolicy
+		expectedValues []int64
+	}{
+		{name: "DropNewest", policy: DropNewest, expectedValues: []int64{1, 2}},
+		{name: "DropOldest", policy: DropOldest, expectedValues: []int64{1, 5}},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			obsreporttest.SetupRecordedMetrics(t)
+
+			var scrapes int64
+			scrapeMetrics := func(context.Context) (pdata.MetricSlice, error) {
+				metrics := gaugesWithDataPoints(1)
+				metrics.At(0).IntGauge().DataPoints().At(0).SetValue(atomic.AddInt64(&scrapes, 1))
+				return metrics, nil
+			}
+
+			core, logs := observer.New(zap.WarnLevel)
+			tickerCh := make(chan time.Time)
+			next := &blockingConsumer{release: make(chan struct{})}
+			defaultCfg := DefaultScraperControllerSettings("receiver")
+			receiver, err := NewScraperControllerReceiver(
+				&defaultCfg,
+				zap.New(core),
+				next,
+				AddMetricsScraper(NewMetricsScraper("scraper", scrapeMetrics)),
+				WithAsyncConsume(1, 1),
+				WithDropPolicy(test.policy),
+				WithTickerChannel(tickerCh),
+			)
+			require.NoError(t, err)
+			require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+			// the first payload blocks the only worker, the second one is queued,
+			// and the following ones are dropped, without delaying the ticks.
+			tickerCh <- time.Now()
+			require.Eventually(t, func() bool { return atomic.LoadInt64(&scrapes) == 1 }, time.Second, time.Millisecond)
+			for i := 0; i < 4; i++ {
+				select {
+				case tickerCh <- time.Now():
+				case <-time.After(time.Second):
+					require.Fail(t, "tick was delayed by the consumer")
+				}
+			}
+			require.Eventually(t, func() bool { return logs.Len() == 3 }, time.Second, time.Millisecond)
+
+			close(next.release)
+			require.NoError(t, receiver.Shutdown(context.Background()))
+
+			var values []int64
+			for _, md := range next.AllMetrics() {
+				values = append(values, md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0).IntGauge().DataPoints().At(0).Value())
+			}
+			assert.Equal(t, test.expectedValues, values)
+			obsreporttest.CheckReceiverMetricsViews(t, "receiver", "", 2, 3)
+		})
+	}
+}
+
+func TestAsyncConsumeShutdownDeadline(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	tickerCh := make(chan time.Time)
+	next := &blockingConsumer{release: make(chan struct{})}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.New(core),
+		next,
+		AddMetricsScraper(NewMetricsScraper("scraper", func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil })),
+		WithAsyncConsume(10, 1),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	tickerCh <- time.Now()
+	tickerCh <- time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.EqualError(t, receiver.Shutdown(ctx), "failed to drain consume queue: context deadline exceeded")
+
+	// the in-flight payload is canceled, and Shutdown returns once it is,
+	// after dropping the queued one.
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "consume queue discarded on shutdown", logs.All()[0].ContextMap()["error"])
+	close(next.release)
+	assert.Len(t, next.AllMetrics(), 0)
+}
+
+func TestAsyncConsumeInvalid(t *testing.T) {
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	for _, option := range []ScraperControllerOption{WithAsyncConsume(0, 1), WithAsyncConsume(1, 0)} {
+		_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+			AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+			option,
+		)
+		assert.Error(t, err)
+	}
+	_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithAsyncConsume(-1, 2),
+	)
+	assert.EqualError(t, err, "async consume queue size and workers must be positive, got -1 and 2")
+}
+
+// sequenceRecordingConsumer records the sequence label of the consumed data
+// points by metric name, after a random delay.
+type sequenceRecordingConsumer struct {
+	mu        sync.Mutex
+	sequences map[string][]int
+}
+
+func (c *sequenceRecordingConsumer) ConsumeMetrics(_ context.Context, md pdata.Metrics) error {
+	time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			ms := ilms.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				dps := ms.At(k).IntGauge().DataPoints()
+				for l := 0; l < dps.Len(); l++ {
+					value, _ := dps.At(l).LabelsMap().Get("sequence")
+					sequence, err := strconv.Atoi(value)
+					if err != nil {
+						return err
+					}
+					c.sequences[ms.At(k).Name()] = append(c.sequences[ms.At(k).Name()], sequence)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func TestAsyncConsumeOrdering(t *testing.T) {
+	const (
+		scrapers        = 5
+		ticks           = 50
+		pointsPerScrape = 3
+	)
+
+	next := &sequenceRecordingConsumer{sequences: map[string][]int{}}
+	tickerCh := make(chan time.Time)
+	options := []ScraperControllerOption{
+		WithAsyncConsume(ticks*scrapers, 3),
+		WithMaxBatchSize(1),
+		WithTickerChannel(tickerCh),
+	}
+	for i := 0; i < scrapers; i++ {
+		name := fmt.Sprintf("scraper%d", i)
+		sequence := 0
+		options = append(options, AddMetricsScraper(NewMetricsScraper(name, func(context.Context) (pdata.MetricSlice, error) {
+			metrics := gaugesWithDataPoints(pointsPerScrape)
+			metrics.At(0).SetName(name)
+			dps := metrics.At(0).IntGauge().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				dps.At(j).LabelsMap().Insert("sequence", strconv.Itoa(sequence))
+				sequence++
+			}
+			return metrics, nil
+		})))
+	}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), next, options...)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	for i := 0; i < ticks; i++ {
+		tickerCh <- time.Now()
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	require.Len(t, next.sequences, scrapers)
+	for name, sequences := range next.sequences {
+		require.Len(t, sequences, ticks*pointsPerScrape, name)
+		for i, sequence := range sequences {
+			assert.Equal(t, i, sequence, name)
+		}
+	}
+}
+
+func TestDeduplicateSeries(t *testing.T) {
+	uptime := func(labels map[string]string) pdata.MetricSlice {
+		metrics := gaugesWithDataPoints(1)
+		metrics.At(0).SetName("system.uptime")
+		dp := metrics.At(0).IntGauge().DataPoints().At(0)
+		dp.SetTimestamp(100)
+		dp.LabelsMap().InitFromMap(labels)
+		return metrics
+	}
+	scrape1 := func(context.Context) (pdata.MetricSlice, error) {
+		metrics := uptime(map[string]string{"a": "1", "b": "2"})
+		metrics.At(0).IntGauge().DataPoints().At(0).SetValue(1)
+		gaugesWithDataPoints(1).MoveAndAppendTo(metrics)
+		return metrics, nil
+	}
+	scrape2 := func(context.Context) (pdata.MetricSlice, error) {
+		// the same series, with the labels inserted in a different order.
+		metrics := pdata.NewMetricSlice()
+		metrics.Resize(1)
+		metrics.At(0).SetName("system.uptime")
+		metrics.At(0).SetDataType(pdata.MetricDataTypeIntGauge)
+		dps := metrics.At(0).IntGauge().DataPoints()
+		dps.Resize(2)
+		dps.At(0).LabelsMap().Insert("b", "2")
+		dps.At(0).LabelsMap().Insert("a", "1")
+		dps.At(0).SetTimestamp(100)
+		dps.At(0).SetValue(2)
+		// a different series, which is kept.
+		dps.At(1).LabelsMap().Insert("a", "3")
+		dps.At(1).SetTimestamp(100)
+		dps.At(1).SetValue(3)
+		return metrics, nil
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		sink,
+		AddMetricsScraper(NewMetricsScraper("scraper1", scrape1)),
+		AddMetricsScraper(NewMetricsScraper("scraper2", scrape2)),
+		AddMetricsScraper(NewMetricsScraper("scraper3", func(context.Context) (pdata.MetricSlice, error) {
+			return uptime(map[string]string{"a": "1", "b": "2"}), nil
+		})),
+		WithDeduplicateSeries(),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	metrics := sink.AllMetrics()[0].ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+	require.Equal(t, 3, metrics.Len())
+	assert.Equal(t, "system.uptime", metrics.At(0).Name())
+	assert.Equal(t, int64(1), metrics.At(0).IntGauge().DataPoints().At(0).Value())
+	assert.Equal(t, "metric0", metrics.At(1).Name())
+	assert.Equal(t, "system.uptime", metrics.At(2).Name())
+	require.Equal(t, 1, metrics.At(2).IntGauge().DataPoints().Len())
+	assert.Equal(t, int64(3), metrics.At(2).IntGauge().DataPoints().At(0).Value())
+}
+
+// deadlineConsumer blocks until the context of each consume call expires,
+// tracking the calls that did not return yet.
+type deadlineConsumer struct {
+	inFlight int64
+}
+
+func (c *deadlineConsumer) ConsumeMetrics(ctx context.Context, _ pdata.Metrics) error {
+	atomic.AddInt64(&c.inFlight, 1)
+	defer atomic.AddInt64(&c.inFlight, -1)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestConsumeTimeout(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	core, logs := observer.New(zap.WarnLevel)
+	tickerCh := make(chan time.Time)
+	next := new(deadlineConsumer)
+	var scraperCtx context.Context
+	scrape := func(ctx context.Context) (pdata.MetricSlice, error) {
+		scraperCtx = ctx
+		return singleMetric(), nil
+	}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.New(core),
+		next,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithConsumeTimeout(10*time.Millisecond),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return logs.Len() == 1 }, time.Second, time.Millisecond)
+	// the consume call returned once its context expired, so that the next
+	// tick is processed.
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return logs.Len() == 2 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Equal(t, int64(0), atomic.LoadInt64(&next.inFlight))
+
+	entry := logs.All()[0]
+	assert.Equal(t, "consume_timeout", entry.ContextMap()["outcome"])
+	assert.Equal(t, "consume timed out after 10ms: context deadline exceeded", entry.ContextMap()["error"])
+	assert.NoError(t, scraperCtx.Err())
+	obsreporttest.CheckReceiverMetricsViews(t, "receiver", "", 0, 2)
+}
+
+// mutatingConsumer records a copy of the consumed metrics, then modifies
+// them, like a processor that mutates the consumed data.
+type mutatingConsumer struct {
+	consumertest.MetricsSink
+}
+
+func (c *mutatingConsumer) ConsumeMetrics(ctx context.Context, md pdata.Metrics) error {
+	if err := c.MetricsSink.ConsumeMetrics(ctx, md.Clone()); err != nil {
+		return err
+	}
+
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rms.At(i).Resource().Attributes().UpsertString("mutated", "true")
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			ms := ilms.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				ms.At(k).SetName("mutated")
+				dps := ms.At(k).IntSum().DataPoints()
+				for l := 0; l < dps.Len(); l++ {
+					dps.At(l).LabelsMap().Upsert("label", "mutated")
+					dps.At(l).SetStartTime(0)
+					dps.At(l).SetValue(-1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func TestMutatingConsumer(t *testing.T) {
+	// the metrics passed to the next consumer are not referenced by the
+	// receiver once consumed, so a consumer that mutates them does not
+	// affect the following scrapes.
+	run := func(next consumer.MetricsConsumer) {
+		var ts pdata.TimestampUnixNano
+		scrape := func(context.Context) (pdata.MetricSlice, error) {
+			ts += 10
+			return deltaSum(ts, deltaPoint{"a", 1}, deltaPoint{"b", 2}), nil
+		}
+
+		tickerCh := make(chan time.Time)
+		defaultCfg := DefaultScraperControllerSettings("receiver")
+		receiver, err := NewScraperControllerReceiver(
+			&defaultCfg,
+			zap.NewNop(),
+			next,
+			AddMetricsScraper(NewMetricsScraper("scraper", scrape, WithDeltaToCumulative(1), WithConstLabels(map[string]string{"host": "a"}))),
+			WithResourceAttributes(map[string]string{"service.name": "test"}),
+			WithTickerChannel(tickerCh),
+		)
+		require.NoError(t, err)
+		require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+		for i := 0; i < 3; i++ {
+			tickerCh <- time.Now()
+		}
+		require.NoError(t, receiver.Shutdown(context.Background()))
+	}
+
+	mutating := &mutatingConsumer{}
+	run(mutating)
+	sink := new(consumertest.MetricsSink)
+	run(sink)
+
+	require.Len(t, sink.AllMetrics(), 3)
+	assert.Equal(t, sink.AllMetrics(), mutating.AllMetrics())
+}
+
+func TestCollectionIntervalLimits(t *testing.T) {
+	tests := []struct {
+		name             string
+		interval         time.Duration
+		options          []ScraperControllerOption
+		expectedInterval time.Duration
+		expectedErr      string
+		expectedWarning  bool
+	}{
+		{
+			name:             "in range",
+			interval:         time.Minute,
+			options:          []ScraperControllerOption{WithMinCollectionInterval(10 * time.Second), WithMaxCollectionInterval(time.Hour)},
+			expectedInterval: time.Minute,
+		},
+		{
+			name:        "below floor",
+			interval:    time.Second,
+			options:     []ScraperControllerOption{WithMinCollectionInterval(10 * time.Second), WithMaxCollectionInterval(time.Hour)},
+			expectedErr: `collection_interval 1s of receiver "receiver" is outside of the allowed range, it must be between 10s and 1h0m0s`,
+		},
+		{
+			name:        "above ceiling",
+			interval:    2 * time.Hour,
+			options:     []ScraperControllerOption{WithMaxCollectionInterval(time.Hour)},
+			expectedErr: `collection_interval 2h0m0s of receiver "receiver" is outside of the allowed range, it must be at most 1h0m0s`,
+		},
+		{
+			name:             "clamped to floor",
+			interval:         time.Second,
+			options:          []ScraperControllerOption{WithMinCollectionInterval(10 * time.Second), WithIntervalPolicy(ClampInterval)},
+			expectedInterval: 10 * time.Second,
+			expectedWarning:  true,
+		},
+		{
+			name:             "clamped to ceiling",
+			interval:         2 * time.Hour,
+			options:          []ScraperControllerOption{WithMinCollectionInterval(10 * time.Second), WithMaxCollectionInterval(time.Hour), WithIntervalPolicy(ClampInterval)},
+			expectedInterval: time.Hour,
+			expectedWarning:  true,
+		},
+		{
+			name:        "invalid range",
+			interval:    time.Minute,
+			options:     []ScraperControllerOption{WithMinCollectionInterval(time.Hour), WithMaxCollectionInterval(time.Minute)},
+			expectedErr: `minimum collection interval 1h0m0s of receiver "receiver" is greater than its maximum collection interval 1m0s`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+			cfg := DefaultScraperControllerSettings("receiver")
+			cfg.CollectionInterval = test.interval
+			receiver, err := NewScraperControllerReceiver(
+				&cfg,
+				zap.New(core),
+				new(consumertest.MetricsSink),
+				append([]ScraperControllerOption{WithAllowEmptyScrapers()}, test.options...)...,
+			)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedInterval, receiver.(*controller).collectionInterval)
+
+			if !test.expectedWarning {
+				assert.Equal(t, 0, logs.Len())
+				return
+			}
+			require.Equal(t, 1, logs.Len())
+			fields := logs.All()[0].ContextMap()
+			assert.Equal(t, test.interval, fields["configured_interval"])
+			assert.Equal(t, test.expectedInterval, fields["collection_interval"])
+		})
+	}
+}
+
+type validatedConfig struct {
+	ScraperSettings
+	err   error
+	calls int
+}
+
+func (cfg *validatedConfig) Validate() error {
+	cfg.calls++
+	return cfg.err
+}
+
+func TestScraperConfigValidate(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	scrapeResource := func(context.Context) (pdata.ResourceMetricsSlice, error) { return singleResourceMetric(), nil }
+	valid := &validatedConfig{}
+	invalid1 := &validatedConfig{err: errors.New("invalid path")}
+	invalid2 := &validatedConfig{err: errors.New("invalid regexp")}
+
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("valid", scrape, WithConfig(valid))),
+		AddMetricsScraper(NewMetricsScraper("invalid1", scrape, WithConfig(invalid1))),
+		AddResourceMetricsScraper(NewResourceMetricsScraper("invalid2", scrapeResource, WithConfig(invalid2))),
+		// configurations without a Validate method are not validated.
+		AddMetricsScraper(NewMetricsScraper("unvalidated", scrape, WithConfig(&ScraperSettings{}))),
+	)
+	assert.EqualError(t, err, `receiver "receiver" has 2 configuration errors:
+  - invalid scraper "invalid1": invalid path
+  - invalid scraper "invalid2": invalid regexp`)
+	for _, cfg := range []*validatedConfig{valid, invalid1, invalid2} {
+		assert.Equal(t, 1, cfg.calls)
+	}
+
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink), WithAllowEmptyScrapers())
+	require.NoError(t, err)
+	invalid := &validatedConfig{err: errors.New("invalid path")}
+	err = receiver.(ScraperManager).AddScraper(context.Background(), NewMetricsScraper("invalid", scrape, WithConfig(invalid)))
+	assert.EqualError(t, err, `invalid scraper "invalid": invalid path`)
+	assert.Equal(t, 1, invalid.calls)
+}
+
+func TestValidationErrorAggregation(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	cfg := DefaultScraperControllerSettings("receiver")
+	cfg.CollectionInterval = -time.Second
+
+	_, err := NewScraperControllerReceiver(
+		&cfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("memory", scrape, WithConfig(&validatedConfig{err: errors.New("invalid path")}))),
+		AddMetricsScraper(NewMetricsScraper("cpu", nil)),
+		AddMetricsScraper(NewMetricsScraper("disk", scrape)),
+		AddResourceMetricsScraper(NewResourceMetricsScraper("disk", func(context.Context) (pdata.ResourceMetricsSlice, error) {
+			return singleResourceMetric(), nil
+		})),
+		AddMetricsScraper(nil),
+	)
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, "receiver", verr.Receiver)
+	assert.Len(t, verr.Errors, 5)
+	assert.EqualError(t, err, `receiver "receiver" has 5 configuration errors:
+  - metrics scraper must not be nil
+  - collection_interval must be a positive duration
+  - invalid scraper "cpu": scrape function must not be nil
+  - duplicate scraper name "disk"
+  - invalid scraper "memory": invalid path`)
+}
+
+func TestOptionsAfterCreate(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	resource := func(context.Context) (pdata.ResourceMetricsSlice, error) { return pdata.NewResourceMetricsSlice(), nil }
+	stream := func(context.Context, EmitMetrics) error { return nil }
+	options := []ScraperControllerOption{
+		AddMetricsScraper(NewMetricsScraper("metrics", scrape)),
+		AddResourceMetricsScraper(NewResourceMetricsScraper("resource", resource)),
+		AddStreamingScraper(NewStreamingScraper("stream", stream)),
+	}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), consumertest.NewMetricsNop(), options...)
+	require.NoError(t, err)
+	sc := receiver.(*controller)
+
+	// the stale options fail, before and after the receiver is started,
+	// leaving the scrapers unchanged.
+	const msg = `option applied to receiver "receiver" after it was created`
+	stale := func() {
+		for _, op := range options {
+			assert.PanicsWithValue(t, msg, func() { op(sc) })
+		}
+		assert.PanicsWithValue(t, msg, func() { sc.applyOptions(options) })
+		assert.Len(t, sc.registeredScrapers(), 3)
+	}
+	stale()
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, receiver.Shutdown(context.Background())) }()
+	stale()
+
+	// removing a scraper leaves the scrapers read before unchanged.
+	sc.scrapersMu.RLock()
+	scrapers := sc.scrapers
+	sc.scrapersMu.RUnlock()
+	id, err := NewScraperID("receiver", "metrics")
+	require.NoError(t, err)
+	require.NoError(t, receiver.(ScraperManager).RemoveScraper(context.Background(), id))
+	assert.Equal(t, []string{"metrics", "resource", "stream"}, scraperNames(scrapers))
+	assert.Equal(t, []string{"resource", "stream"}, scraperNames(sc.registeredScrapers()))
+}
+
+func scraperNames(scrapers []BaseScraper) []string {
+	names := make([]string, 0, len(scrapers))
+	for _, scraper := range scrapers {
+		names = append(names, scraper.Name())
+	}
+	return names
+}
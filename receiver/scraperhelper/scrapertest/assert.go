@@ -0,0 +1,93 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+// WaitForBatches waits until the sink recorded at least n batches of metrics,
+// and returns them. The test fails immediately if the sink did not record
+// them within the timeout, so that WaitForBatches must be called from the
+// goroutine running the test.
+func WaitForBatches(t testing.TB, sink *SinkConsumer, n int, timeout time.Duration) []pdata.Metrics {
+	t.Helper()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		batches, changed := sink.batchesChanged()
+		if len(batches) >= n {
+			return batches
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			t.Fatalf("sink recorded %d batches of metrics after %v, want %d", len(batches), timeout, n)
+			return nil
+		}
+	}
+}
+
+// MetricNames returns the names of the metrics, in order.
+func MetricNames(md pdata.Metrics) []string {
+	var names []string
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			metrics := ilms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				names = append(names, metrics.At(k).Name())
+			}
+		}
+	}
+	return names
+}
+
+// DataPointCount returns the number of data points of the metrics, counted
+// like scraperhelper.DataPointCount.
+func DataPointCount(md pdata.Metrics) int {
+	return scraperhelper.ResourceMetricsDataPointCount(md.ResourceMetrics())
+}
+
+// AssertMetricNames asserts that the metrics have the given names, in order.
+func AssertMetricNames(t testing.TB, md pdata.Metrics, names ...string) bool {
+	t.Helper()
+	return assert.Equal(t, names, MetricNames(md))
+}
+
+// AssertDataPointCount asserts that the metrics have the given number of
+// data points.
+func AssertDataPointCount(t testing.TB, md pdata.Metrics, dataPointCount int) bool {
+	t.Helper()
+	return assert.Equal(t, dataPointCount, DataPointCount(md))
+}
+
+// AssertBatchDataPointCounts asserts that the batches have the given numbers
+// of data points, in order.
+func AssertBatchDataPointCounts(t testing.TB, batches []pdata.Metrics, dataPointCounts ...int) bool {
+	t.Helper()
+	counts := make([]int, 0, len(batches))
+	for _, md := range batches {
+		counts = append(counts, DataPointCount(md))
+	}
+	return assert.Equal(t, dataPointCounts, counts)
+}
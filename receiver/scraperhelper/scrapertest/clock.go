@@ -0,0 +1,252 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+// FakeClock is a clock for use in tests, whose time only changes when it is
+// advanced, so that delays measured with it do not consume wall time. It is
+// a scraperhelper.Clock, so that a receiver created with
+// scraperhelper.WithClock ticks when the clock is advanced. A FakeClock is
+// safe for concurrent use.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []clockWaiter
+	// changed is closed, and replaced, whenever a waiter is added.
+	changed chan struct{}
+}
+
+type clockWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+	// period is the period of a ticker, or zero for a timer or a channel
+	// returned by After.
+	period time.Duration
+}
+
+var _ scraperhelper.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, changed: make(chan struct{})}
+}
+
+// added signals that a waiter was added. It must be called with the mutex
+// held.
+func (c *FakeClock) added() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel receiving the time of the clock once it has been
+// advanced by at least d, like time.After.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.after(d)
+}
+
+// NewTimer returns a timer sending the time of the clock on its channel once
+// the clock has been advanced by at least d, like time.NewTimer.
+func (c *FakeClock) NewTimer(d time.Duration) scraperhelper.Timer {
+	return &fakeTimer{clock: c, ch: c.after(d)}
+}
+
+func (c *FakeClock) after(d time.Duration) chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, clockWaiter{deadline: c.now.Add(d), ch: ch})
+	c.added()
+	return ch
+}
+
+// NewTicker returns a ticker sending the time of the clock on its channel
+// each time the clock is advanced past a multiple of the period d, like
+// time.NewTicker. As with time.Ticker, a tick is dropped if the previous one
+// was not received yet. It panics if d is not positive.
+func (c *FakeClock) NewTicker(d time.Duration) scraperhelper.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, clockWaiter{deadline: c.now.Add(d), ch: ch, period: d})
+	c.added()
+	return &fakeTimer{clock: c, ch: ch}
+}
+
+// Advance advances the clock by d, releasing the channels returned by After,
+// the timers and the tickers whose deadline elapsed, in the order of their deadlines.
+// Each of them receives its deadline, the time of the clock when it fired.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for {
+		i := c.nextDue(end)
+		if i < 0 {
+			break
+		}
+		w := &c.waiters[i]
+		c.now = w.deadline
+		select {
+		case w.ch <- c.now:
+		default:
+		}
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+			continue
+		}
+		c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+	}
+	c.now = end
+}
+
+// Waiters returns the number of channels returned by After and of timers
+// whose delay did not elapse yet, and of tickers that were not stopped, so that tests can
+// wait until a goroutine waits on the clock before advancing it.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntilWaiters blocks until at least n channels returned by After,
+// timers or tickers wait for the clock to be advanced, for instance until the
+// scheduler of a receiver waits for its next deadline, without polling
+// Waiters. It returns the error of the context if it is done first.
+func (c *FakeClock) BlockUntilWaiters(ctx context.Context, n int) error {
+	for {
+		c.mu.Lock()
+		waiters, changed := len(c.waiters), c.changed
+		c.mu.Unlock()
+		if waiters >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// PendingTicks returns the number of ticks sent by the tickers of the clock
+// that were not received yet.
+func (c *FakeClock) PendingTicks() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := 0
+	for _, w := range c.waiters {
+		if w.period > 0 {
+			pending += len(w.ch)
+		}
+	}
+	return pending
+}
+
+// nextDue returns the index of the waiter with the earliest deadline not
+// after end, the first one added if several have the same deadline, or -1
+// if there is none.
+func (c *FakeClock) nextDue(end time.Time) int {
+	next := -1
+	for i, w := range c.waiters {
+		if w.deadline.After(end) {
+			continue
+		}
+		if next < 0 || w.deadline.Before(c.waiters[next].deadline) {
+			next = i
+		}
+	}
+	return next
+}
+
+// stop stops the wait on the channel returned by After, or the timer or the
+// ticker.
+func (c *FakeClock) stop(ch <-chan time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, w := range c.waiters {
+		if w.ch == ch {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// fakeTimer is a timer or a ticker of a FakeClock.
+type fakeTimer struct {
+	clock *FakeClock
+	ch    chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() {
+	t.clock.stop(t.ch)
+}
+
+// WaitForClockWaiters waits until at least n channels returned by After,
+// timers or tickers wait for the clock to be advanced, and the ticks already sent by
+// the tickers were received, so that advancing the clock does not race with
+// the goroutines about to wait for it. The test fails immediately if they did
+// not within the timeout, so that WaitForClockWaiters must be called from the
+// goroutine running the test.
+func WaitForClockWaiters(t testing.TB, clock *FakeClock, n int, timeout time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// the ticks received are not signaled, so they are polled for once the
+	// waiters wait.
+	poll := time.NewTicker(time.Millisecond)
+	defer poll.Stop()
+	for {
+		err := clock.BlockUntilWaiters(ctx, n)
+		waiters, pending := clock.Waiters(), clock.PendingTicks()
+		if err == nil && waiters >= n && pending == 0 {
+			return
+		}
+		if err == nil {
+			select {
+			case <-poll.C:
+				continue
+			case <-ctx.Done():
+			}
+		}
+		t.Fatalf("%d waiters waited for the clock after %v with %d ticks not received, want %d", waiters, timeout, pending, n)
+		return
+	}
+}
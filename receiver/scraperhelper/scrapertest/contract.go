@@ -0,0 +1,173 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+// scrapeErrorMessage is the message logged by receivers when a scrape fails.
+const scrapeErrorMessage = "Error scraping metrics"
+
+// ScraperTestCase describes a scrape of a scraper created by a factory, and
+// its expected outcome, for RunScraperTests.
+type ScraperTestCase struct {
+	// Name is the name of the subtest running the case.
+	Name string
+	// Scraper is the name of the scraper passed to the factory, "scraper"
+	// if empty.
+	Scraper string
+	// Config is the configuration of the scraper passed to the factory.
+	Config scraperhelper.ScraperConfig
+
+	// Setup, if not nil, prepares the environment of the scraper before it
+	// is created.
+	Setup func(t testing.TB)
+	// Teardown, if not nil, cleans up the environment of the scraper once it
+	// is closed, even if the case failed.
+	Teardown func(t testing.TB)
+
+	// MetricNames, if not nil, are the expected names of the scraped
+	// metrics, in order.
+	MetricNames []string
+	// DataPointCount, if not zero, is the expected number of scraped data
+	// points.
+	DataPointCount int
+	// Golden, if set, is the path of the golden file the scraped metrics are
+	// compared with by AssertGolden, with the GoldenOptions.
+	Golden        string
+	GoldenOptions []GoldenOption
+
+	// Error, if set, is a substring of the expected error of the creation,
+	// the initialization, the scrape or the closing of the scraper, partial
+	// scrape errors included, in which case the scraped metrics are not
+	// checked. No error is expected otherwise.
+	Error string
+}
+
+// RunScraperTests runs each case in a subtest: the scraper is created by the
+// factory from the configuration of the case, then initialized, scraped and
+// closed by a single cycle of a receiver, like RunOnce, and the scraped
+// metrics or the error are checked against the expectations of the case.
+// MetricsScraper and ResourceMetricsScraper are supported.
+//
+// The factory is typically the Create method of the scraperhelper.ScraperRegistry
+// of a receiver, so that the cases cover how the receiver creates its
+// scrapers.
+func RunScraperTests(t *testing.T, factory scraperhelper.ScraperFactory, cases []ScraperTestCase) {
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			runScraperTest(t, factory, tc)
+		})
+	}
+}
+
+// runScraperTest runs a single case of RunScraperTests.
+func runScraperTest(t testing.TB, factory scraperhelper.ScraperFactory, tc ScraperTestCase) {
+	t.Helper()
+	if tc.Setup != nil {
+		tc.Setup(t)
+	}
+	if tc.Teardown != nil {
+		defer tc.Teardown(t)
+	}
+
+	name := tc.Scraper
+	if name == "" {
+		name = "scraper"
+	}
+	md, err := scrapeFromFactory(t, factory, name, tc.Config)
+	if tc.Error != "" {
+		if assert.Error(t, err, "expected error containing %q", tc.Error) {
+			assert.Contains(t, err.Error(), tc.Error)
+		}
+		return
+	}
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if tc.MetricNames != nil {
+		if len(tc.MetricNames) == 0 {
+			assert.Empty(t, MetricNames(md))
+		} else {
+			AssertMetricNames(t, md, tc.MetricNames...)
+		}
+	}
+	if tc.DataPointCount != 0 {
+		AssertDataPointCount(t, md, tc.DataPointCount)
+	}
+	if tc.Golden != "" {
+		AssertGolden(t, tc.Golden, md, tc.GoldenOptions...)
+	}
+}
+
+// scrapeFromFactory creates the scraper with the factory and runs a cycle of
+// a receiver with it, returning the consumed metrics, and the error of the
+// creation, the initialization, the scrape or the closing of the scraper.
+func scrapeFromFactory(t testing.TB, factory scraperhelper.ScraperFactory, name string, cfg scraperhelper.ScraperConfig) (pdata.Metrics, error) {
+	t.Helper()
+	scraper, err := factory(name, cfg)
+	if err != nil {
+		return pdata.NewMetrics(), err
+	}
+
+	var option scraperhelper.ScraperControllerOption
+	switch s := scraper.(type) {
+	case scraperhelper.MetricsScraper:
+		option = scraperhelper.AddMetricsScraper(s)
+	case scraperhelper.ResourceMetricsScraper:
+		option = scraperhelper.AddResourceMetricsScraper(s)
+	default:
+		t.Fatalf("unsupported scraper type %T of scraper %q", scraper, name)
+		return pdata.NewMetrics(), nil
+	}
+
+	// scrape errors are only logged by receivers.
+	core, logs := observer.New(zap.ErrorLevel)
+	sink := new(SinkConsumer)
+	settings := scraperhelper.DefaultScraperControllerSettings("receiver")
+	if err := runCycle(t, &settings, zap.New(core), sink, option); err != nil {
+		return pdata.NewMetrics(), err
+	}
+	for _, entry := range logs.FilterMessage(scrapeErrorMessage).All() {
+		if err := loggedError(entry.Context); err != nil {
+			return consumedMetrics(sink), err
+		}
+	}
+	return consumedMetrics(sink), nil
+}
+
+// loggedError returns the error of the fields of a log entry, if any.
+func loggedError(fields []zapcore.Field) error {
+	for _, field := range fields {
+		if field.Type != zapcore.ErrorType {
+			continue
+		}
+		if err, ok := field.Interface.(error); ok {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+// exampleConfig is the configuration of the example scrapers.
+type exampleConfig struct {
+	scraperhelper.ScraperSettings `mapstructure:",squash"`
+	Metrics                       int    `mapstructure:"metrics"`
+	Points                        int    `mapstructure:"points"`
+	Fail                          string `mapstructure:"fail"`
+}
+
+// exampleSourceUp is the environment of the example scrapers, failing to
+// initialize if it is not set.
+var exampleSourceUp = true
+
+// exampleRegistry registers the example scrapers, scraping the metrics
+// generated by GenerateMetrics, for a single resource for "example_resource".
+var exampleRegistry = scraperhelper.ScraperRegistry{
+	"example": {
+		CreateDefaultConfig: func() scraperhelper.ScraperConfig { return &exampleConfig{Metrics: 2, Points: 3} },
+		CreateScraper: func(name string, cfg scraperhelper.ScraperConfig) (scraperhelper.BaseScraper, error) {
+			c, err := checkExampleConfig(cfg)
+			if err != nil {
+				return nil, err
+			}
+			scrape := NewScrapeMetrics(c.Metrics, c.Points)
+			return scraperhelper.NewMetricsScraper(name, func(ctx context.Context) (pdata.MetricSlice, error) {
+				if c.Fail != "" {
+					return pdata.NewMetricSlice(), errors.New(c.Fail)
+				}
+				return scrape(ctx)
+			}, scraperhelper.WithConfig(cfg), scraperhelper.WithStart(startExample)), nil
+		},
+	},
+	"example_resource": {
+		CreateDefaultConfig: func() scraperhelper.ScraperConfig { return &exampleConfig{Metrics: 2, Points: 3} },
+		CreateScraper: func(name string, cfg scraperhelper.ScraperConfig) (scraperhelper.BaseScraper, error) {
+			c, err := checkExampleConfig(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return scraperhelper.NewResourceMetricsScraper(name, NewScrapeResourceMetrics(1, c.Metrics, c.Points),
+				scraperhelper.WithConfig(cfg), scraperhelper.WithStart(startExample)), nil
+		},
+	},
+}
+
+func checkExampleConfig(cfg scraperhelper.ScraperConfig) (*exampleConfig, error) {
+	c, ok := cfg.(*exampleConfig)
+	if !ok {
+		return nil, fmt.Errorf("unexpected configuration type %T", cfg)
+	}
+	if c.Metrics < 0 || c.Points < 0 {
+		return nil, errors.New("metrics and points must not be negative")
+	}
+	return c, nil
+}
+
+func startExample(context.Context, component.Host) error {
+	if !exampleSourceUp {
+		return errors.New("source unavailable")
+	}
+	return nil
+}
+
+func newExampleConfig(metrics, points int) *exampleConfig {
+	return &exampleConfig{Metrics: metrics, Points: points}
+}
+
+func TestExampleScrapers(t *testing.T) {
+	withSettings := func(cfg *exampleConfig, settings scraperhelper.ScraperSettings) *exampleConfig {
+		cfg.ScraperSettings = settings
+		return cfg
+	}
+	RunScraperTests(t, exampleRegistry.Create, []ScraperTestCase{
+		{
+			Name:           "Default",
+			Scraper:        "example",
+			Config:         exampleRegistry["example"].CreateDefaultConfig(),
+			MetricNames:    []string{"metric.0", "metric.1"},
+			DataPointCount: 6,
+		},
+		{
+			Name:        "NoMetrics",
+			Scraper:     "example",
+			Config:      newExampleConfig(0, 3),
+			MetricNames: []string{},
+		},
+		{
+			Name:    "MaxDataPoints",
+			Scraper: "example",
+			Config:  withSettings(newExampleConfig(2, 3), scraperhelper.ScraperSettings{MaxDataPointsVal: 4}),
+			Error:   "dropped 3 data points from 1 metrics exceeding the limit of 4 data points",
+		},
+		{
+			Name:    "ResourceAttributes",
+			Scraper: "example_resource",
+			Config: withSettings(newExampleConfig(1, 2), scraperhelper.ScraperSettings{
+				ResourceAttributesVal: map[string]string{"host.name": "example-host", "resource": "not-overwritten"},
+			}),
+			Golden: "testdata/example_resource.golden",
+		},
+		{
+			Name:    "ScrapeError",
+			Scraper: "example",
+			Config:  &exampleConfig{Metrics: 1, Points: 1, Fail: "example failure"},
+			Error:   "example failure",
+		},
+		{
+			Name:    "NegativeTimeout",
+			Scraper: "example",
+			Config:  withSettings(newExampleConfig(1, 1), scraperhelper.ScraperSettings{TimeoutVal: -time.Second}),
+			Error:   "timeout must not be negative",
+		},
+		{
+			Name:    "InvalidConfig",
+			Scraper: "example",
+			Config:  newExampleConfig(-1, 1),
+			Error:   "metrics and points must not be negative",
+		},
+		{
+			Name:    "UnknownScraper",
+			Scraper: "exmaple",
+			Config:  newExampleConfig(1, 1),
+			Error:   `did you mean "example"?`,
+		},
+		{
+			Name:     "SourceUnavailable",
+			Scraper:  "example",
+			Config:   newExampleConfig(1, 1),
+			Setup:    func(testing.TB) { exampleSourceUp = false },
+			Teardown: func(testing.TB) { exampleSourceUp = true },
+			Error:    "source unavailable",
+		},
+	})
+}
+
+func TestRunScraperTestFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		tc       ScraperTestCase
+		failures int
+	}{
+		{
+			name:     "MetricNames",
+			tc:       ScraperTestCase{Scraper: "example", Config: newExampleConfig(1, 1), MetricNames: []string{"metric.1"}},
+			failures: 1,
+		},
+		{
+			name:     "NoMetricNames",
+			tc:       ScraperTestCase{Scraper: "example", Config: newExampleConfig(1, 1), MetricNames: []string{}},
+			failures: 1,
+		},
+		{
+			name:     "DataPointCount",
+			tc:       ScraperTestCase{Scraper: "example", Config: newExampleConfig(1, 1), DataPointCount: 2},
+			failures: 1,
+		},
+		{
+			name:     "UnexpectedError",
+			tc:       ScraperTestCase{Scraper: "example", Config: &exampleConfig{Fail: "example failure"}, DataPointCount: 2},
+			failures: 1,
+		},
+		{
+			name:     "MissingError",
+			tc:       ScraperTestCase{Scraper: "example", Config: newExampleConfig(1, 1), Error: "example failure"},
+			failures: 1,
+		},
+		{
+			name:     "OtherError",
+			tc:       ScraperTestCase{Scraper: "example", Config: &exampleConfig{Fail: "other failure"}, Error: "example failure"},
+			failures: 1,
+		},
+		{
+			name:     "Success",
+			tc:       ScraperTestCase{Scraper: "example", Config: newExampleConfig(1, 1), MetricNames: []string{"metric.0"}, DataPointCount: 1},
+			failures: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &failureRecorder{TB: t}
+			runScraperTest(recorder, exampleRegistry.Create, tt.tc)
+			assert.Len(t, recorder.failures, tt.failures, recorder.failures)
+		})
+	}
+}
+
+func TestRunScraperTestSetupAndTeardown(t *testing.T) {
+	var calls []string
+	runScraperTest(t, func(name string, cfg scraperhelper.ScraperConfig) (scraperhelper.BaseScraper, error) {
+		calls = append(calls, "create")
+		return exampleRegistry.Create("example", cfg)
+	}, ScraperTestCase{
+		Config:   newExampleConfig(1, 1),
+		Setup:    func(testing.TB) { calls = append(calls, "setup") },
+		Teardown: func(testing.TB) { calls = append(calls, "teardown") },
+	})
+	assert.Equal(t, []string{"setup", "create", "teardown"}, calls)
+}
+
+func TestRunScraperTestUnsupportedScraper(t *testing.T) {
+	recorder := &failureRecorder{TB: t}
+	runScraperTest(recorder, func(name string, cfg scraperhelper.ScraperConfig) (scraperhelper.BaseScraper, error) {
+		return scraperhelper.NewStreamingScraper(name, nil), nil
+	}, ScraperTestCase{Scraper: "streaming"})
+	require.Len(t, recorder.failures, 1)
+	assert.Contains(t, recorder.failures[0], `unsupported scraper type`)
+}
@@ -0,0 +1,39 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scrapertest defines types and functions used to help test and
+// benchmark scrapers and receivers created with the scraperhelper package:
+//   - generators of metrics payloads, including Generate, generating
+//     reproducible payloads of mixed types and configurable cardinality,
+//   - a SinkConsumer recording the metrics passed to the next consumer, and
+//     assertions on the recorded metrics, including the comparison with
+//     golden files of AssertGolden,
+//   - a FlakyConsumer, whose calls fail and take time as scripted, with a
+//     limit on the calls running concurrently, recording their timeline,
+//   - a Host with configurable extensions, recording the fatal errors
+//     reported to it,
+//   - a ScriptedScraper, whose scrapes fail, return metrics, take time or
+//     panic as scripted,
+//   - a FakeClock, measuring the delays of the scripted scrapers and
+//     consumers, and driving the ticks of receivers created with
+//     scraperhelper.WithClock, without waiting,
+//   - RunOnce, running a single scrape cycle of a scraper synchronously,
+//   - RunScraperTests, running table-driven cases of scrapers created by a
+//     factory, checking their metrics or errors uniformly,
+//   - VerifyReceiverLifecycle, checking the lifecycle of receivers, and
+//     StressReceiver, checking it under random concurrent operations,
+//   - tracked scrapers, checking with VerifyAllClosed that receivers close
+//     the scrapers they initialized,
+//   - SetGate, overriding the feature gates of scraperhelper in a test.
+package scrapertest
@@ -0,0 +1,247 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// ConsumeStep is a step of the script of a FlakyConsumer, describing what a
+// single call to ConsumeMetrics does.
+type ConsumeStep struct {
+	// Delay is how long the call takes, measured with the clock of the
+	// consumer. The call returns the error of its context if the context
+	// expires first.
+	Delay time.Duration
+	// Err is returned by the call, if set, in which case the batch is not
+	// recorded.
+	Err error
+	// Permanent makes the call return Err as a permanent error, that must not
+	// be retried, instead of a retryable one.
+	Permanent bool
+}
+
+// ConsumeCall records a call to the ConsumeMetrics method of a
+// FlakyConsumer. The times are measured with the clock of the consumer.
+type ConsumeCall struct {
+	// Metrics is the batch of metrics passed to the call.
+	Metrics pdata.Metrics
+	// Called is when ConsumeMetrics was called.
+	Called time.Time
+	// Started is when the call was allowed to run by the concurrency limit of
+	// the consumer, or zero if its context expired first.
+	Started time.Time
+	// Returned is when the call returned, or zero if it is still running.
+	Returned time.Time
+	// Err is the error returned by the call.
+	Err error
+}
+
+// FlakyConsumerOption changes a FlakyConsumer.
+type FlakyConsumerOption func(*FlakyConsumer)
+
+// WithConsumeScript sets the steps run by the calls to ConsumeMetrics, one per
+// call, then repeating the last step, unless WithConsumeRepeat is used.
+func WithConsumeScript(script ...ConsumeStep) FlakyConsumerOption {
+	return func(c *FlakyConsumer) {
+		c.script = script
+	}
+}
+
+// WithConsumeRepeat makes the consumer repeat its script once it reached the
+// end, instead of repeating the last step.
+func WithConsumeRepeat() FlakyConsumerOption {
+	return func(c *FlakyConsumer) {
+		c.repeat = true
+	}
+}
+
+// WithConsumeClock makes the consumer measure the delays of its steps, and the
+// times of its calls, with the fake clock, instead of wall time.
+func WithConsumeClock(clock *FakeClock) FlakyConsumerOption {
+	return func(c *FlakyConsumer) {
+		c.clock = clock
+	}
+}
+
+// WithMaxConcurrentCalls limits the number of calls to ConsumeMetrics running
+// concurrently: the extra calls block until a running call returns, or their
+// context expires. A limit of zero or less means no limit.
+func WithMaxConcurrentCalls(n int) FlakyConsumerOption {
+	return func(c *FlakyConsumer) {
+		if n > 0 {
+			c.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// FlakyConsumer is a consumer.MetricsConsumer for use in tests, whose calls
+// take time and fail as scripted, such as being slow on the second call, or
+// failing with a retryable error then succeeding, and which can limit the
+// calls running concurrently. It records the batches of metrics it accepted,
+// and the timeline of its calls. A FlakyConsumer is safe for concurrent use.
+type FlakyConsumer struct {
+	script []ConsumeStep
+	repeat bool
+	clock  *FakeClock
+	slots  chan struct{}
+
+	mu      sync.Mutex
+	calls   []*ConsumeCall
+	batches []pdata.Metrics
+	running int
+	// maxRunning is the highest number of calls that ran concurrently.
+	maxRunning int
+}
+
+var _ consumer.MetricsConsumer = (*FlakyConsumer)(nil)
+
+// NewFlakyConsumer returns a consumer running the steps of its script. A
+// consumer without a script accepts all the batches without delay.
+func NewFlakyConsumer(options ...FlakyConsumerOption) *FlakyConsumer {
+	c := &FlakyConsumer{}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// ConsumeMetrics waits for the concurrency limit of the consumer, then runs
+// the next step of the script, recording the batch of metrics unless the
+// step fails.
+func (c *FlakyConsumer) ConsumeMetrics(ctx context.Context, md pdata.Metrics) error {
+	call, step := c.startCall(md)
+	if c.slots != nil {
+		select {
+		case c.slots <- struct{}{}:
+			defer func() { <-c.slots }()
+		case <-ctx.Done():
+			return c.endCall(call, false, ctx.Err())
+		}
+	}
+	c.runCall(call)
+
+	if step.Delay > 0 {
+		elapsed, stop := c.after(step.Delay)
+		select {
+		case <-elapsed:
+		case <-ctx.Done():
+			stop()
+			return c.endCall(call, true, ctx.Err())
+		}
+	}
+	err := step.Err
+	if err != nil && step.Permanent {
+		err = consumererror.Permanent(err)
+	}
+	return c.endCall(call, true, err)
+}
+
+// Batches returns the batches of metrics accepted by the consumer, in the
+// order the calls accepting them returned.
+func (c *FlakyConsumer) Batches() []pdata.Metrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]pdata.Metrics(nil), c.batches...)
+}
+
+// Calls returns the calls to ConsumeMetrics in the order they were made,
+// including the calls that failed, and the calls still in progress.
+func (c *FlakyConsumer) Calls() []ConsumeCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := make([]ConsumeCall, len(c.calls))
+	for i, call := range c.calls {
+		calls[i] = *call
+	}
+	return calls
+}
+
+// MaxConcurrentCalls returns the highest number of calls that ran
+// concurrently, not counting the calls blocked by the concurrency limit.
+func (c *FlakyConsumer) MaxConcurrentCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.maxRunning
+}
+
+// startCall records the call, and returns its step.
+func (c *FlakyConsumer) startCall(md pdata.Metrics) (*ConsumeCall, ConsumeStep) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call := &ConsumeCall{Metrics: md, Called: c.now()}
+	c.calls = append(c.calls, call)
+	if len(c.script) == 0 {
+		return call, ConsumeStep{}
+	}
+	i := len(c.calls) - 1
+	if c.repeat {
+		i %= len(c.script)
+	} else if i >= len(c.script) {
+		i = len(c.script) - 1
+	}
+	return call, c.script[i]
+}
+
+// runCall records that the call got past the concurrency limit.
+func (c *FlakyConsumer) runCall(call *ConsumeCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call.Started = c.now()
+	c.running++
+	if c.running > c.maxRunning {
+		c.maxRunning = c.running
+	}
+}
+
+// endCall records that the call returned the error, and returns it.
+func (c *FlakyConsumer) endCall(call *ConsumeCall, started bool, err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if started {
+		c.running--
+	}
+	call.Returned = c.now()
+	call.Err = err
+	if err == nil {
+		c.batches = append(c.batches, call.Metrics)
+	}
+	return err
+}
+
+// now returns the time of the clock of the consumer.
+func (c *FlakyConsumer) now() time.Time {
+	if c.clock != nil {
+		return c.clock.Now()
+	}
+	return time.Now()
+}
+
+// after returns a channel receiving the time once the delay elapsed, and a
+// function stopping the wait.
+func (c *FlakyConsumer) after(d time.Duration) (<-chan time.Time, func()) {
+	if c.clock != nil {
+		ch := c.clock.After(d)
+		return ch, func() { c.clock.stop(ch) }
+	}
+	timer := time.NewTimer(d)
+	return timer.C, func() { timer.Stop() }
+}
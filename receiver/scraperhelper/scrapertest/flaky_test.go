@@ -0,0 +1,178 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// sizedBatch returns a batch of metrics identified by its number of
+// resources.
+func sizedBatch(resources int) pdata.Metrics {
+	md := pdata.NewMetrics()
+	md.ResourceMetrics().Resize(resources)
+	return md
+}
+
+// batchSizes returns the number of resources of the batches.
+func batchSizes(batches []pdata.Metrics) []int {
+	var sizes []int
+	for _, md := range batches {
+		sizes = append(sizes, md.ResourceMetrics().Len())
+	}
+	return sizes
+}
+
+func TestFlakyConsumer(t *testing.T) {
+	errRetryable := errors.New("retryable")
+	errFatal := errors.New("fatal")
+	script := []ConsumeStep{
+		{Err: errRetryable},
+		{},
+		{Err: errFatal, Permanent: true},
+	}
+
+	consumer := NewFlakyConsumer(WithConsumeScript(script...))
+	err := consumer.ConsumeMetrics(context.Background(), sizedBatch(1))
+	assert.Equal(t, errRetryable, err)
+	assert.False(t, consumererror.IsPermanent(err))
+	assert.NoError(t, consumer.ConsumeMetrics(context.Background(), sizedBatch(2)))
+	for i := 3; i <= 4; i++ {
+		err = consumer.ConsumeMetrics(context.Background(), sizedBatch(i))
+		assert.EqualError(t, err, "Permanent error: fatal")
+		assert.True(t, consumererror.IsPermanent(err))
+	}
+	assert.Equal(t, []int{2}, batchSizes(consumer.Batches()))
+
+	calls := consumer.Calls()
+	require.Len(t, calls, 4)
+	for i, call := range calls {
+		assert.Equal(t, i+1, call.Metrics.ResourceMetrics().Len())
+		assert.False(t, call.Called.IsZero())
+		assert.False(t, call.Started.Before(call.Called))
+		assert.False(t, call.Returned.Before(call.Started))
+	}
+	assert.Equal(t, errRetryable, calls[0].Err)
+	assert.NoError(t, calls[1].Err)
+
+	consumer = NewFlakyConsumer(WithConsumeScript(script...), WithConsumeRepeat())
+	for i := 1; i <= 4; i++ {
+		_ = consumer.ConsumeMetrics(context.Background(), sizedBatch(i))
+	}
+	assert.Equal(t, errRetryable, consumer.Calls()[3].Err)
+
+	consumer = NewFlakyConsumer()
+	for i := 1; i <= 2; i++ {
+		assert.NoError(t, consumer.ConsumeMetrics(context.Background(), sizedBatch(i)))
+	}
+	assert.Equal(t, []int{1, 2}, batchSizes(consumer.Batches()))
+}
+
+func TestFlakyConsumerDelay(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	consumer := NewFlakyConsumer(WithConsumeScript(ConsumeStep{Delay: 5 * time.Second}), WithConsumeClock(clock))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.ConsumeMetrics(context.Background(), sizedBatch(1))
+	}()
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(4 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("call returned before its delay elapsed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.True(t, consumer.Calls()[0].Returned.IsZero())
+	clock.Advance(time.Second)
+	assert.NoError(t, <-done)
+
+	calls := consumer.Calls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, start, calls[0].Called)
+	assert.Equal(t, start, calls[0].Started)
+	assert.Equal(t, start.Add(5*time.Second), calls[0].Returned)
+}
+
+func TestFlakyConsumerDelayTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	consumer := NewFlakyConsumer(WithConsumeScript(ConsumeStep{Delay: 5 * time.Second}), WithConsumeClock(clock))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, consumer.ConsumeMetrics(ctx, sizedBatch(1)))
+	assert.Equal(t, 0, clock.Waiters())
+	assert.Empty(t, consumer.Batches())
+	assert.Equal(t, context.Canceled, consumer.Calls()[0].Err)
+}
+
+func TestFlakyConsumerMaxConcurrentCalls(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	consumer := NewFlakyConsumer(
+		WithConsumeScript(ConsumeStep{Delay: time.Second}),
+		WithConsumeClock(clock),
+		WithMaxConcurrentCalls(2),
+	)
+
+	done := make(chan error, 3)
+	for i := 1; i <= 3; i++ {
+		go func(i int) {
+			done <- consumer.ConsumeMetrics(context.Background(), sizedBatch(i))
+		}(i)
+	}
+	// the third call is blocked until one of the first two returns.
+	require.Eventually(t, func() bool { return clock.Waiters() == 2 && len(consumer.Calls()) == 3 }, time.Second, time.Millisecond)
+	assert.Equal(t, 2, consumer.MaxConcurrentCalls())
+	clock.Advance(time.Second)
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Second)
+	require.NoError(t, <-done)
+	assert.Equal(t, 2, consumer.MaxConcurrentCalls())
+	assert.Len(t, consumer.Batches(), 3)
+
+	var blocked int
+	for _, call := range consumer.Calls() {
+		if call.Started.After(call.Called) {
+			blocked++
+		}
+	}
+	assert.Equal(t, 1, blocked)
+
+	// a blocked call returns once its context expires.
+	consumer = NewFlakyConsumer(WithConsumeScript(ConsumeStep{Delay: time.Second}), WithConsumeClock(clock), WithMaxConcurrentCalls(1))
+	go func() {
+		done <- consumer.ConsumeMetrics(context.Background(), sizedBatch(1))
+	}()
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, consumer.ConsumeMetrics(ctx, sizedBatch(2)))
+	assert.True(t, consumer.Calls()[1].Started.IsZero())
+	assert.Equal(t, 1, consumer.MaxConcurrentCalls())
+	clock.Advance(time.Second)
+	require.NoError(t, <-done)
+}
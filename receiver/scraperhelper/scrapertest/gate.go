@@ -0,0 +1,39 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+// SetGate enables or disables the feature gate with the given ID for the
+// duration of the test, restoring it once the test and its subtests are
+// done. Gates are global, so tests setting them must not run in parallel
+// with tests creating receivers.
+func SetGate(t testing.TB, id string, enabled bool) {
+	t.Helper()
+	previous := scraperhelper.IsGateEnabled(id)
+	if err := scraperhelper.SetGateEnabled(id, enabled); err != nil {
+		t.Fatalf("failed to set gate %q: %v", id, err)
+		return
+	}
+	t.Cleanup(func() {
+		if err := scraperhelper.SetGateEnabled(id, previous); err != nil {
+			t.Errorf("failed to restore gate %q: %v", id, err)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+func TestSetGate(t *testing.T) {
+	t.Run("Enable", func(t *testing.T) {
+		SetGate(t, scraperhelper.SkipEmptyPayloadsGate, true)
+		assert.True(t, scraperhelper.IsGateEnabled(scraperhelper.SkipEmptyPayloadsGate))
+
+		t.Run("Disable", func(t *testing.T) {
+			SetGate(t, scraperhelper.SkipEmptyPayloadsGate, false)
+			assert.False(t, scraperhelper.IsGateEnabled(scraperhelper.SkipEmptyPayloadsGate))
+		})
+		assert.True(t, scraperhelper.IsGateEnabled(scraperhelper.SkipEmptyPayloadsGate))
+	})
+	assert.False(t, scraperhelper.IsGateEnabled(scraperhelper.SkipEmptyPayloadsGate))
+}
+
+func TestSetGateUnknown(t *testing.T) {
+	recorder := &failureRecorder{TB: t}
+	SetGate(recorder, "unknown", true)
+	assert.Equal(t, []string{`failed to set gate "unknown": gate "unknown" is not registered`}, recorder.failures)
+}
@@ -0,0 +1,235 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+// GenSpec specifies the shape of the metrics generated by Generate.
+type GenSpec struct {
+	// Resources is the number of resources, each with the attribute
+	// "resource"="<i>" and a single instrumentation library.
+	Resources int
+	// Metrics is the number of metrics of each resource, named
+	// "metric.<j>".
+	Metrics int
+	// DataPoints is the number of data points of each metric.
+	DataPoints int
+	// Types are the types of the metrics, which cycle through them. Gauges,
+	// cumulative monotonic sums and cumulative histograms of ints or doubles
+	// are supported, and the metrics are double gauges if no type is set.
+	Types []pdata.MetricDataType
+	// Labels is the number of labels of each data point, named
+	// "label.<k>".
+	Labels int
+	// LabelCardinality is the number of distinct values of each label, one
+	// if not set. The data points of a metric have distinct label values as
+	// long as there are enough combinations of them.
+	LabelCardinality int
+	// Seed seeds the generation of the values of the data points.
+	Seed int64
+}
+
+// generatedStartTime is the start time of the generated cumulative metrics.
+var generatedStartTime = PayloadTimestamp - pdata.TimestampUnixNano(time.Minute)
+
+// generatedBounds are the explicit bounds of the generated histograms.
+var generatedBounds = []float64{1, 5, 10, 50}
+
+// Generate generates metrics of the shape specified by spec, with data points
+// timestamped PayloadTimestamp and random values. The generated metrics only
+// depend on the spec, including its seed, so that they are reproducible
+// across runs. Generate panics if a type of the spec is not supported.
+func Generate(spec GenSpec) pdata.Metrics {
+	types := spec.Types
+	if len(types) == 0 {
+		types = []pdata.MetricDataType{pdata.MetricDataTypeDoubleGauge}
+	}
+	g := &generator{
+		spec:   spec,
+		rand:   rand.New(rand.NewSource(spec.Seed)),
+		labels: generatedLabels(spec),
+	}
+
+	md := pdata.NewMetrics()
+	rms := md.ResourceMetrics()
+	rms.Resize(spec.Resources)
+	for i := 0; i < spec.Resources; i++ {
+		rm := rms.At(i)
+		rm.Resource().Attributes().InsertString("resource", strconv.Itoa(i))
+		rm.InstrumentationLibraryMetrics().Resize(1)
+		metrics := rm.InstrumentationLibraryMetrics().At(0).Metrics()
+		metrics.Resize(spec.Metrics)
+		for j := 0; j < spec.Metrics; j++ {
+			metric := metrics.At(j)
+			metric.SetName("metric." + strconv.Itoa(j))
+			g.generateMetric(metric, types[j%len(types)])
+		}
+	}
+	return md
+}
+
+// NewScrapeGenerated returns a scrape function returning a copy of the
+// resource metrics generated by Generate on every scrape. The resource
+// metrics are only generated once, so that benchmarks only measure the cost
+// of copying them.
+func NewScrapeGenerated(spec GenSpec) scraperhelper.ScrapeResourceMetrics {
+	payload := Generate(spec).ResourceMetrics()
+	return func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		resourceMetrics := pdata.NewResourceMetricsSlice()
+		payload.CopyTo(resourceMetrics)
+		return resourceMetrics, nil
+	}
+}
+
+type generator struct {
+	spec GenSpec
+	rand *rand.Rand
+	// labels are the label values of the data points of every metric, in
+	// the order of the labels.
+	labels [][]string
+}
+
+func (g *generator) generateMetric(metric pdata.Metric, dataType pdata.MetricDataType) {
+	metric.SetDataType(dataType)
+	switch dataType {
+	case pdata.MetricDataTypeIntGauge:
+		g.intDataPoints(metric.IntGauge().DataPoints(), false)
+	case pdata.MetricDataTypeDoubleGauge:
+		g.doubleDataPoints(metric.DoubleGauge().DataPoints(), false)
+	case pdata.MetricDataTypeIntSum:
+		metric.IntSum().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+		metric.IntSum().SetIsMonotonic(true)
+		g.intDataPoints(metric.IntSum().DataPoints(), true)
+	case pdata.MetricDataTypeDoubleSum:
+		metric.DoubleSum().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+		metric.DoubleSum().SetIsMonotonic(true)
+		g.doubleDataPoints(metric.DoubleSum().DataPoints(), true)
+	case pdata.MetricDataTypeIntHistogram:
+		metric.IntHistogram().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+		g.intHistogramDataPoints(metric.IntHistogram().DataPoints())
+	case pdata.MetricDataTypeDoubleHistogram:
+		metric.DoubleHistogram().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+		g.doubleHistogramDataPoints(metric.DoubleHistogram().DataPoints())
+	default:
+		panic(fmt.Sprintf("unsupported generated metric type %v", dataType))
+	}
+}
+
+func (g *generator) intDataPoints(dps pdata.IntDataPointSlice, cumulative bool) {
+	dps.Resize(g.spec.DataPoints)
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		g.setLabels(dp.LabelsMap(), i)
+		if cumulative {
+			dp.SetStartTime(generatedStartTime)
+		}
+		dp.SetTimestamp(PayloadTimestamp)
+		dp.SetValue(g.rand.Int63n(1000))
+	}
+}
+
+func (g *generator) doubleDataPoints(dps pdata.DoubleDataPointSlice, cumulative bool) {
+	dps.Resize(g.spec.DataPoints)
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		g.setLabels(dp.LabelsMap(), i)
+		if cumulative {
+			dp.SetStartTime(generatedStartTime)
+		}
+		dp.SetTimestamp(PayloadTimestamp)
+		dp.SetValue(g.rand.Float64() * 1000)
+	}
+}
+
+func (g *generator) intHistogramDataPoints(dps pdata.IntHistogramDataPointSlice) {
+	dps.Resize(g.spec.DataPoints)
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		g.setLabels(dp.LabelsMap(), i)
+		dp.SetStartTime(generatedStartTime)
+		dp.SetTimestamp(PayloadTimestamp)
+		counts, count := g.bucketCounts()
+		dp.SetBucketCounts(counts)
+		dp.SetExplicitBounds(append([]float64(nil), generatedBounds...))
+		dp.SetCount(count)
+		dp.SetSum(g.rand.Int63n(100) * int64(count))
+	}
+}
+
+func (g *generator) doubleHistogramDataPoints(dps pdata.DoubleHistogramDataPointSlice) {
+	dps.Resize(g.spec.DataPoints)
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		g.setLabels(dp.LabelsMap(), i)
+		dp.SetStartTime(generatedStartTime)
+		dp.SetTimestamp(PayloadTimestamp)
+		counts, count := g.bucketCounts()
+		dp.SetBucketCounts(counts)
+		dp.SetExplicitBounds(append([]float64(nil), generatedBounds...))
+		dp.SetCount(count)
+		dp.SetSum(g.rand.Float64() * 100 * float64(count))
+	}
+}
+
+// setLabels sets the labels of the i-th data point of a metric, inserted in
+// order, so that the generated metrics are reproducible.
+func (g *generator) setLabels(labels pdata.StringMap, i int) {
+	labels.InitEmptyWithCapacity(len(g.labels[i]))
+	for k, value := range g.labels[i] {
+		labels.Insert("label."+strconv.Itoa(k), value)
+	}
+}
+
+// bucketCounts returns random counts of the buckets of a histogram, and
+// their total.
+func (g *generator) bucketCounts() ([]uint64, uint64) {
+	counts := make([]uint64, len(generatedBounds)+1)
+	var total uint64
+	for i := range counts {
+		counts[i] = uint64(g.rand.Int63n(10))
+		total += counts[i]
+	}
+	return counts, total
+}
+
+// generatedLabels returns the label values of the data points of a metric:
+// the values of the labels of the i-th data point are the digits of i, in
+// base LabelCardinality, so that the data points have distinct label values
+// as long as there are enough combinations of them.
+func generatedLabels(spec GenSpec) [][]string {
+	cardinality := spec.LabelCardinality
+	if cardinality < 1 {
+		cardinality = 1
+	}
+	labels := make([][]string, spec.DataPoints)
+	for i := range labels {
+		labels[i] = make([]string, spec.Labels)
+		n := i
+		for k := range labels[i] {
+			labels[i][k] = "value." + strconv.Itoa(n%cardinality)
+			n /= cardinality
+		}
+	}
+	return labels
+}
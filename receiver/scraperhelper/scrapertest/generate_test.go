@@ -0,0 +1,182 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// generatedTypes are the metric types supported by Generate.
+var generatedTypes = []pdata.MetricDataType{
+	pdata.MetricDataTypeIntGauge,
+	pdata.MetricDataTypeDoubleGauge,
+	pdata.MetricDataTypeIntSum,
+	pdata.MetricDataTypeDoubleSum,
+	pdata.MetricDataTypeIntHistogram,
+	pdata.MetricDataTypeDoubleHistogram,
+}
+
+// dataPointLabels returns the labels of the data points of the metric.
+func dataPointLabels(metric pdata.Metric) []pdata.StringMap {
+	var labels []pdata.StringMap
+	switch metric.DataType() {
+	case pdata.MetricDataTypeIntGauge:
+		for i := 0; i < metric.IntGauge().DataPoints().Len(); i++ {
+			labels = append(labels, metric.IntGauge().DataPoints().At(i).LabelsMap())
+		}
+	case pdata.MetricDataTypeDoubleGauge:
+		for i := 0; i < metric.DoubleGauge().DataPoints().Len(); i++ {
+			labels = append(labels, metric.DoubleGauge().DataPoints().At(i).LabelsMap())
+		}
+	case pdata.MetricDataTypeIntSum:
+		for i := 0; i < metric.IntSum().DataPoints().Len(); i++ {
+			labels = append(labels, metric.IntSum().DataPoints().At(i).LabelsMap())
+		}
+	case pdata.MetricDataTypeDoubleSum:
+		for i := 0; i < metric.DoubleSum().DataPoints().Len(); i++ {
+			labels = append(labels, metric.DoubleSum().DataPoints().At(i).LabelsMap())
+		}
+	case pdata.MetricDataTypeIntHistogram:
+		for i := 0; i < metric.IntHistogram().DataPoints().Len(); i++ {
+			labels = append(labels, metric.IntHistogram().DataPoints().At(i).LabelsMap())
+		}
+	case pdata.MetricDataTypeDoubleHistogram:
+		for i := 0; i < metric.DoubleHistogram().DataPoints().Len(); i++ {
+			labels = append(labels, metric.DoubleHistogram().DataPoints().At(i).LabelsMap())
+		}
+	}
+	return labels
+}
+
+// hasShape reports whether the metrics have the shape specified by spec.
+func hasShape(t *testing.T, md pdata.Metrics, spec GenSpec) bool {
+	rms := md.ResourceMetrics()
+	if !assert.Equal(t, spec.Resources, rms.Len()) {
+		return false
+	}
+	for i := 0; i < rms.Len(); i++ {
+		resource, ok := rms.At(i).Resource().Attributes().Get("resource")
+		if !assert.True(t, ok) || !assert.Equal(t, strconv.Itoa(i), resource.StringVal()) {
+			return false
+		}
+		metrics := rms.At(i).InstrumentationLibraryMetrics().At(0).Metrics()
+		if !assert.Equal(t, spec.Metrics, metrics.Len()) {
+			return false
+		}
+		for j := 0; j < metrics.Len(); j++ {
+			metric := metrics.At(j)
+			if !assert.Equal(t, spec.Types[j%len(spec.Types)], metric.DataType()) {
+				return false
+			}
+			labels := dataPointLabels(metric)
+			if !assert.Len(t, labels, spec.DataPoints) {
+				return false
+			}
+			// the data points have distinct label values when there are
+			// enough combinations of them.
+			distinct := map[string]bool{}
+			for _, l := range labels {
+				if !assert.Equal(t, spec.Labels, l.Len()) {
+					return false
+				}
+				key := ""
+				l.ForEach(func(k, v string) { key += k + "=" + v + "," })
+				distinct[key] = true
+			}
+			combinations := 1
+			for k := 0; k < spec.Labels && combinations < spec.DataPoints; k++ {
+				combinations *= spec.LabelCardinality
+			}
+			if spec.DataPoints > 0 && combinations >= spec.DataPoints && !assert.Len(t, distinct, spec.DataPoints) {
+				return false
+			}
+		}
+	}
+	return assert.Equal(t, spec.Resources*spec.Metrics*spec.DataPoints, DataPointCount(md))
+}
+
+func TestGenerateShape(t *testing.T) {
+	shape := func(resources, metrics, dataPoints, labels, cardinality, types uint8, seed int64) bool {
+		spec := GenSpec{
+			Resources:        int(resources % 4),
+			Metrics:          int(metrics % 8),
+			DataPoints:       int(dataPoints % 16),
+			Types:            generatedTypes[:1+int(types)%len(generatedTypes)],
+			Labels:           int(labels % 4),
+			LabelCardinality: 1 + int(cardinality%5),
+			Seed:             seed,
+		}
+		md := Generate(spec)
+		return hasShape(t, md, spec) && assert.Equal(t, md, Generate(spec))
+	}
+	require.NoError(t, quick.Check(shape, &quick.Config{MaxCount: 200}))
+}
+
+func TestGenerate(t *testing.T) {
+	spec := GenSpec{Resources: 2, Metrics: 3, DataPoints: 4, Labels: 2, LabelCardinality: 2, Seed: 1}
+	md := Generate(spec)
+	metric := md.ResourceMetrics().At(1).InstrumentationLibraryMetrics().At(0).Metrics().At(2)
+	assert.Equal(t, "metric.2", metric.Name())
+	// the metrics are double gauges unless types are set.
+	require.Equal(t, pdata.MetricDataTypeDoubleGauge, metric.DataType())
+	dp := metric.DoubleGauge().DataPoints().At(3)
+	assert.Equal(t, PayloadTimestamp, dp.Timestamp())
+	assert.Equal(t, map[string]string{"label.0": "value.1", "label.1": "value.1"}, labelValues(dp.LabelsMap()))
+
+	// the values depend on the seed.
+	spec.Seed = 2
+	assert.NotEqual(t, md, Generate(spec))
+
+	spec.Types = []pdata.MetricDataType{pdata.MetricDataTypeDoubleHistogram}
+	histogram := Generate(spec).ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0).DoubleHistogram()
+	assert.Equal(t, pdata.AggregationTemporalityCumulative, histogram.AggregationTemporality())
+	hdp := histogram.DataPoints().At(0)
+	var count uint64
+	for _, c := range hdp.BucketCounts() {
+		count += c
+	}
+	assert.Equal(t, count, hdp.Count())
+	assert.Len(t, hdp.BucketCounts(), len(hdp.ExplicitBounds())+1)
+
+	spec.Types = []pdata.MetricDataType{pdata.MetricDataTypeDoubleSummary}
+	assert.Panics(t, func() { Generate(spec) })
+}
+
+func TestNewScrapeGenerated(t *testing.T) {
+	spec := GenSpec{Resources: 2, Metrics: 2, DataPoints: 2, Types: generatedTypes, Seed: 1}
+	scrape := NewScrapeGenerated(spec)
+	first, err := scrape(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Generate(spec).ResourceMetrics(), first)
+
+	first.Resize(0)
+	second, err := scrape(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Generate(spec).ResourceMetrics(), second)
+}
+
+func labelValues(labels pdata.StringMap) map[string]string {
+	values := map[string]string{}
+	labels.ForEach(func(k, v string) { values[k] = v })
+	return values
+}
@@ -0,0 +1,454 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+var updateGolden = flag.Bool("update", false, "regenerate the golden files compared with scrapertest.AssertGolden")
+
+// masked replaces the masked fields in golden files.
+const masked = "<masked>"
+
+// GoldenOption changes how metrics are serialized by MarshalGolden.
+type GoldenOption func(*goldenSettings)
+
+type goldenSettings struct {
+	maskTimestamps bool
+	maskAttributes map[string]bool
+	maskValues     map[string]bool
+}
+
+// MaskTimestamps masks the start time and the timestamp of the data points,
+// and the timestamp of the exemplars.
+func MaskTimestamps() GoldenOption {
+	return func(s *goldenSettings) {
+		s.maskTimestamps = true
+	}
+}
+
+// MaskAttributes masks the values of the resource attributes and of the
+// labels with the given keys, which are still serialized.
+func MaskAttributes(keys ...string) GoldenOption {
+	return func(s *goldenSettings) {
+		if s.maskAttributes == nil {
+			s.maskAttributes = map[string]bool{}
+		}
+		for _, key := range keys {
+			s.maskAttributes[key] = true
+		}
+	}
+}
+
+// MaskValues masks the values, counts, sums, bucket counts and quantile
+// values of the data points of the metrics with the given names.
+func MaskValues(metricNames ...string) GoldenOption {
+	return func(s *goldenSettings) {
+		if s.maskValues == nil {
+			s.maskValues = map[string]bool{}
+		}
+		for _, name := range metricNames {
+			s.maskValues[name] = true
+		}
+	}
+}
+
+// AssertGolden asserts that the metrics, serialized with MarshalGolden, match
+// the golden file at the path, reporting a unified diff of the golden file
+// and of the serialized metrics otherwise. When the tests are run with the
+// -update flag, the golden file is written instead, creating its directory
+// if needed.
+func AssertGolden(t testing.TB, path string, md pdata.Metrics, options ...GoldenOption) bool {
+	t.Helper()
+	return assertGolden(t, path, md, *updateGolden, options...)
+}
+
+func assertGolden(t testing.TB, path string, md pdata.Metrics, update bool, options ...GoldenOption) bool {
+	t.Helper()
+	actual := MarshalGolden(md, options...)
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create the directory of golden file %s: %v", path, err)
+			return false
+		}
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatalf("failed to write golden file %s: %v", path, err)
+			return false
+		}
+		return true
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Errorf("golden file %s does not exist, run the test with -update to create it", path)
+		return false
+	}
+	if err != nil {
+		t.Fatalf("failed to read golden file %s: %v", path, err)
+		return false
+	}
+	if string(expected) == string(actual) {
+		return true
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(expected)),
+		B:        difflib.SplitLines(string(actual)),
+		FromFile: path,
+		ToFile:   "actual",
+		Context:  3,
+	})
+	if err != nil {
+		diff = err.Error()
+	}
+	t.Errorf("metrics do not match golden file %s, run the test with -update to regenerate it:\n%s", path, diff)
+	return false
+}
+
+// MarshalGolden serializes the metrics to the text format of the golden
+// files of AssertGolden: an indented tree of the resources, instrumentation
+// libraries, metrics and data points, with a field per line. Siblings in the
+// tree, attributes and labels are sorted, so that the serialization does not
+// depend on their order, and timestamps are written in UTC, in RFC 3339
+// format.
+func MarshalGolden(md pdata.Metrics, options ...GoldenOption) []byte {
+	var set goldenSettings
+	for _, option := range options {
+		option(&set)
+	}
+
+	rms := md.ResourceMetrics()
+	resources := make([]string, 0, rms.Len())
+	for i := 0; i < rms.Len(); i++ {
+		resources = append(resources, set.resourceMetrics(rms.At(i)))
+	}
+	return []byte(sortedBlocks(resources))
+}
+
+func (s *goldenSettings) resourceMetrics(rm pdata.ResourceMetrics) string {
+	var b strings.Builder
+	b.WriteString("resource\n")
+	b.WriteString(indent(s.attributes(rm.Resource().Attributes())))
+
+	ilms := rm.InstrumentationLibraryMetrics()
+	libraries := make([]string, 0, ilms.Len())
+	for i := 0; i < ilms.Len(); i++ {
+		libraries = append(libraries, s.instrumentationLibraryMetrics(ilms.At(i)))
+	}
+	b.WriteString(indent(sortedBlocks(libraries)))
+	return b.String()
+}
+
+func (s *goldenSettings) instrumentationLibraryMetrics(ilm pdata.InstrumentationLibraryMetrics) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "instrumentation library %q %q\n", ilm.InstrumentationLibrary().Name(), ilm.InstrumentationLibrary().Version())
+
+	ms := ilm.Metrics()
+	metrics := make([]string, 0, ms.Len())
+	for i := 0; i < ms.Len(); i++ {
+		metrics = append(metrics, s.metric(ms.At(i)))
+	}
+	b.WriteString(indent(sortedBlocks(metrics)))
+	return b.String()
+}
+
+func (s *goldenSettings) metric(metric pdata.Metric) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "metric %s\n", metric.Name())
+	fields := new(goldenFields)
+	fields.add("description", strconv.Quote(metric.Description()))
+	fields.add("unit", strconv.Quote(metric.Unit()))
+	fields.add("type", metric.DataType().String())
+
+	maskValues := s.maskValues[metric.Name()]
+	var points []string
+	switch metric.DataType() {
+	case pdata.MetricDataTypeIntGauge:
+		points = s.intDataPoints(metric.IntGauge().DataPoints(), maskValues)
+	case pdata.MetricDataTypeDoubleGauge:
+		points = s.doubleDataPoints(metric.DoubleGauge().DataPoints(), maskValues)
+	case pdata.MetricDataTypeIntSum:
+		sum := metric.IntSum()
+		fields.add("aggregation temporality", sum.AggregationTemporality().String())
+		fields.add("monotonic", strconv.FormatBool(sum.IsMonotonic()))
+		points = s.intDataPoints(sum.DataPoints(), maskValues)
+	case pdata.MetricDataTypeDoubleSum:
+		sum := metric.DoubleSum()
+		fields.add("aggregation temporality", sum.AggregationTemporality().String())
+		fields.add("monotonic", strconv.FormatBool(sum.IsMonotonic()))
+		points = s.doubleDataPoints(sum.DataPoints(), maskValues)
+	case pdata.MetricDataTypeIntHistogram:
+		histogram := metric.IntHistogram()
+		fields.add("aggregation temporality", histogram.AggregationTemporality().String())
+		points = s.intHistogramDataPoints(histogram.DataPoints(), maskValues)
+	case pdata.MetricDataTypeDoubleHistogram:
+		histogram := metric.DoubleHistogram()
+		fields.add("aggregation temporality", histogram.AggregationTemporality().String())
+		points = s.doubleHistogramDataPoints(histogram.DataPoints(), maskValues)
+	case pdata.MetricDataTypeDoubleSummary:
+		points = s.doubleSummaryDataPoints(metric.DoubleSummary().DataPoints(), maskValues)
+	}
+	b.WriteString(indent(fields.String()))
+	b.WriteString(indent(sortedBlocks(points)))
+	return b.String()
+}
+
+func (s *goldenSettings) intDataPoints(dps pdata.IntDataPointSlice, maskValues bool) []string {
+	points := make([]string, 0, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		fields := s.dataPointFields(dp.LabelsMap(), dp.StartTime(), dp.Timestamp())
+		fields.addValue("value", strconv.FormatInt(dp.Value(), 10), maskValues)
+		points = append(points, s.dataPoint(fields, s.intExemplars(dp.Exemplars(), maskValues)))
+	}
+	return points
+}
+
+func (s *goldenSettings) doubleDataPoints(dps pdata.DoubleDataPointSlice, maskValues bool) []string {
+	points := make([]string, 0, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		fields := s.dataPointFields(dp.LabelsMap(), dp.StartTime(), dp.Timestamp())
+		fields.addValue("value", formatFloat(dp.Value()), maskValues)
+		points = append(points, s.dataPoint(fields, s.doubleExemplars(dp.Exemplars(), maskValues)))
+	}
+	return points
+}
+
+func (s *goldenSettings) intHistogramDataPoints(dps pdata.IntHistogramDataPointSlice, maskValues bool) []string {
+	points := make([]string, 0, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		fields := s.dataPointFields(dp.LabelsMap(), dp.StartTime(), dp.Timestamp())
+		fields.addValue("count", strconv.FormatUint(dp.Count(), 10), maskValues)
+		fields.addValue("sum", strconv.FormatInt(dp.Sum(), 10), maskValues)
+		fields.addValue("bucket counts", formatUints(dp.BucketCounts()), maskValues)
+		fields.add("explicit bounds", formatFloats(dp.ExplicitBounds()))
+		points = append(points, s.dataPoint(fields, s.intExemplars(dp.Exemplars(), maskValues)))
+	}
+	return points
+}
+
+func (s *goldenSettings) doubleHistogramDataPoints(dps pdata.DoubleHistogramDataPointSlice, maskValues bool) []string {
+	points := make([]string, 0, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		fields := s.dataPointFields(dp.LabelsMap(), dp.StartTime(), dp.Timestamp())
+		fields.addValue("count", strconv.FormatUint(dp.Count(), 10), maskValues)
+		fields.addValue("sum", formatFloat(dp.Sum()), maskValues)
+		fields.addValue("bucket counts", formatUints(dp.BucketCounts()), maskValues)
+		fields.add("explicit bounds", formatFloats(dp.ExplicitBounds()))
+		points = append(points, s.dataPoint(fields, s.doubleExemplars(dp.Exemplars(), maskValues)))
+	}
+	return points
+}
+
+func (s *goldenSettings) doubleSummaryDataPoints(dps pdata.DoubleSummaryDataPointSlice, maskValues bool) []string {
+	points := make([]string, 0, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		fields := s.dataPointFields(dp.LabelsMap(), dp.StartTime(), dp.Timestamp())
+		fields.addValue("count", strconv.FormatUint(dp.Count(), 10), maskValues)
+		fields.addValue("sum", formatFloat(dp.Sum()), maskValues)
+		quantiles := dp.QuantileValues()
+		for j := 0; j < quantiles.Len(); j++ {
+			fields.addValue("quantile "+formatFloat(quantiles.At(j).Quantile()), formatFloat(quantiles.At(j).Value()), maskValues)
+		}
+		points = append(points, s.dataPoint(fields, nil))
+	}
+	return points
+}
+
+func (s *goldenSettings) intExemplars(exemplars pdata.IntExemplarSlice, maskValues bool) []string {
+	blocks := make([]string, 0, exemplars.Len())
+	for i := 0; i < exemplars.Len(); i++ {
+		exemplar := exemplars.At(i)
+		blocks = append(blocks, s.exemplar(exemplar.FilteredLabels(), exemplar.Timestamp(), strconv.FormatInt(exemplar.Value(), 10), maskValues))
+	}
+	return blocks
+}
+
+func (s *goldenSettings) doubleExemplars(exemplars pdata.DoubleExemplarSlice, maskValues bool) []string {
+	blocks := make([]string, 0, exemplars.Len())
+	for i := 0; i < exemplars.Len(); i++ {
+		exemplar := exemplars.At(i)
+		blocks = append(blocks, s.exemplar(exemplar.FilteredLabels(), exemplar.Timestamp(), formatFloat(exemplar.Value()), maskValues))
+	}
+	return blocks
+}
+
+func (s *goldenSettings) exemplar(labels pdata.StringMap, timestamp pdata.TimestampUnixNano, value string, maskValues bool) string {
+	fields := new(goldenFields)
+	fields.addLines(s.labels("filtered labels", labels))
+	fields.add("time", s.timestamp(timestamp))
+	fields.addValue("value", value, maskValues)
+	return "exemplar\n" + indent(fields.String())
+}
+
+// dataPointFields returns the fields common to all data points.
+func (s *goldenSettings) dataPointFields(labels pdata.StringMap, start, timestamp pdata.TimestampUnixNano) *goldenFields {
+	fields := new(goldenFields)
+	fields.addLines(s.labels("labels", labels))
+	fields.add("start time", s.timestamp(start))
+	fields.add("time", s.timestamp(timestamp))
+	return fields
+}
+
+func (s *goldenSettings) dataPoint(fields *goldenFields, exemplars []string) string {
+	return "data point\n" + indent(fields.String()) + indent(sortedBlocks(exemplars))
+}
+
+func (s *goldenSettings) attributes(attrs pdata.AttributeMap) string {
+	if attrs.Len() == 0 {
+		return ""
+	}
+	var lines []string
+	attrs.ForEach(func(k string, v pdata.AttributeValue) {
+		lines = append(lines, s.field(k, formatAttributeValue(v)))
+	})
+	sort.Strings(lines)
+	return "attributes\n" + indent(strings.Join(lines, ""))
+}
+
+func (s *goldenSettings) labels(name string, labels pdata.StringMap) string {
+	if labels.Len() == 0 {
+		return ""
+	}
+	var lines []string
+	labels.ForEach(func(k string, v string) {
+		lines = append(lines, s.field(k, strconv.Quote(v)))
+	})
+	sort.Strings(lines)
+	return name + "\n" + indent(strings.Join(lines, ""))
+}
+
+// field returns the line of the attribute or label, masking its value if
+// needed.
+func (s *goldenSettings) field(key, value string) string {
+	if s.maskAttributes[key] {
+		value = masked
+	}
+	return fmt.Sprintf("%s: %s\n", key, value)
+}
+
+func (s *goldenSettings) timestamp(ts pdata.TimestampUnixNano) string {
+	switch {
+	case ts == 0:
+		return "unset"
+	case s.maskTimestamps:
+		return masked
+	}
+	return time.Unix(0, int64(ts)).UTC().Format(time.RFC3339Nano)
+}
+
+// goldenFields builds the lines of the fields of an element.
+type goldenFields struct {
+	b strings.Builder
+}
+
+func (f *goldenFields) add(name, value string) {
+	fmt.Fprintf(&f.b, "%s: %s\n", name, value)
+}
+
+func (f *goldenFields) addValue(name, value string, mask bool) {
+	if mask {
+		value = masked
+	}
+	f.add(name, value)
+}
+
+func (f *goldenFields) addLines(lines string) {
+	f.b.WriteString(lines)
+}
+
+func (f *goldenFields) String() string {
+	return f.b.String()
+}
+
+// sortedBlocks returns the concatenation of the sorted blocks of lines.
+func sortedBlocks(blocks []string) string {
+	sort.Strings(blocks)
+	return strings.Join(blocks, "")
+}
+
+// indent indents the lines by two spaces.
+func indent(lines string) string {
+	if lines == "" {
+		return ""
+	}
+	return "  " + strings.Replace(strings.TrimSuffix(lines, "\n"), "\n", "\n  ", -1) + "\n"
+}
+
+func formatAttributeValue(v pdata.AttributeValue) string {
+	switch v.Type() {
+	case pdata.AttributeValueSTRING:
+		return strconv.Quote(v.StringVal())
+	case pdata.AttributeValueINT:
+		return strconv.FormatInt(v.IntVal(), 10)
+	case pdata.AttributeValueDOUBLE:
+		return formatFloat(v.DoubleVal())
+	case pdata.AttributeValueBOOL:
+		return strconv.FormatBool(v.BoolVal())
+	case pdata.AttributeValueMAP:
+		var entries []string
+		v.MapVal().ForEach(func(k string, v pdata.AttributeValue) {
+			entries = append(entries, k+": "+formatAttributeValue(v))
+		})
+		sort.Strings(entries)
+		return "{" + strings.Join(entries, ", ") + "}"
+	case pdata.AttributeValueARRAY:
+		values := v.ArrayVal()
+		entries := make([]string, 0, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			entries = append(entries, formatAttributeValue(values.At(i)))
+		}
+		return "[" + strings.Join(entries, ", ") + "]"
+	}
+	return "null"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func formatFloats(values []float64) string {
+	entries := make([]string, 0, len(values))
+	for _, v := range values {
+		entries = append(entries, formatFloat(v))
+	}
+	return "[" + strings.Join(entries, ", ") + "]"
+}
+
+func formatUints(values []uint64) string {
+	entries := make([]string, 0, len(values))
+	for _, v := range values {
+		entries = append(entries, strconv.FormatUint(v, 10))
+	}
+	return "[" + strings.Join(entries, ", ") + "]"
+}
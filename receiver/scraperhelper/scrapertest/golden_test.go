@@ -0,0 +1,182 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+var goldenTime = pdata.TimestampUnixNano(time.Date(2021, 1, 2, 3, 4, 5, 6, time.UTC).UnixNano())
+
+// goldenMetrics returns metrics of all the types, for two processes, in the
+// order given by reversed.
+func goldenMetrics(reversed bool) pdata.Metrics {
+	md := pdata.NewMetrics()
+	pids := []int64{1, 2}
+	if reversed {
+		pids = []int64{2, 1}
+	}
+	for _, pid := range pids {
+		rm := pdata.NewResourceMetrics()
+		rm.Resource().Attributes().InsertString("process.executable.name", "otelcol")
+		rm.Resource().Attributes().InsertInt("process.pid", pid)
+		rm.InstrumentationLibraryMetrics().Resize(1)
+		ilm := rm.InstrumentationLibraryMetrics().At(0)
+		ilm.InstrumentationLibrary().SetName("process")
+		ilm.InstrumentationLibrary().SetVersion("1.0")
+
+		metrics := pdata.NewMetricSlice()
+		metrics.Resize(4)
+		cpu := metrics.At(0)
+		cpu.SetName("process.cpu.time")
+		cpu.SetUnit("s")
+		cpu.SetDataType(pdata.MetricDataTypeDoubleSum)
+		cpu.DoubleSum().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+		cpu.DoubleSum().SetIsMonotonic(true)
+		states := []string{"user", "system"}
+		if reversed {
+			states = []string{"system", "user"}
+		}
+		for _, state := range states {
+			dp := pdata.NewDoubleDataPoint()
+			dp.LabelsMap().Insert("state", state)
+			dp.SetStartTime(goldenTime)
+			dp.SetTimestamp(goldenTime + pdata.TimestampUnixNano(time.Second))
+			dp.SetValue(float64(pid) * 1.5)
+			cpu.DoubleSum().DataPoints().Append(dp)
+		}
+
+		threads := metrics.At(1)
+		threads.SetName("process.threads")
+		threads.SetDataType(pdata.MetricDataTypeIntGauge)
+		threads.IntGauge().DataPoints().Resize(1)
+		threads.IntGauge().DataPoints().At(0).SetTimestamp(goldenTime)
+		threads.IntGauge().DataPoints().At(0).SetValue(pid * 10)
+
+		latency := metrics.At(2)
+		latency.SetName("process.latency")
+		latency.SetDataType(pdata.MetricDataTypeDoubleHistogram)
+		latency.DoubleHistogram().SetAggregationTemporality(pdata.AggregationTemporalityDelta)
+		latency.DoubleHistogram().DataPoints().Resize(1)
+		hdp := latency.DoubleHistogram().DataPoints().At(0)
+		hdp.SetTimestamp(goldenTime)
+		hdp.SetCount(3)
+		hdp.SetSum(0.75)
+		hdp.SetBucketCounts([]uint64{1, 2})
+		hdp.SetExplicitBounds([]float64{0.5})
+		hdp.Exemplars().Resize(1)
+		hdp.Exemplars().At(0).SetValue(0.25)
+		hdp.Exemplars().At(0).SetTimestamp(goldenTime)
+		hdp.Exemplars().At(0).FilteredLabels().Insert("trace_id", "abc")
+
+		duration := metrics.At(3)
+		duration.SetName("process.duration")
+		duration.SetDataType(pdata.MetricDataTypeDoubleSummary)
+		duration.DoubleSummary().DataPoints().Resize(1)
+		sdp := duration.DoubleSummary().DataPoints().At(0)
+		sdp.SetTimestamp(goldenTime)
+		sdp.SetCount(2)
+		sdp.SetSum(3)
+		sdp.QuantileValues().Resize(1)
+		sdp.QuantileValues().At(0).SetQuantile(0.99)
+		sdp.QuantileValues().At(0).SetValue(2)
+
+		if reversed {
+			reversedMetrics := pdata.NewMetricSlice()
+			for i := metrics.Len() - 1; i >= 0; i-- {
+				reversedMetrics.Append(metrics.At(i))
+			}
+			metrics = reversedMetrics
+		}
+		metrics.MoveAndAppendTo(ilm.Metrics())
+		md.ResourceMetrics().Append(rm)
+	}
+	return md
+}
+
+func TestAssertGolden(t *testing.T) {
+	// the golden file is regenerated with go test -update.
+	AssertGolden(t, filepath.Join("testdata", "metrics.golden"), goldenMetrics(false), MaskAttributes("process.pid"))
+}
+
+func TestAssertGoldenRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golden")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "testdata", "metrics.golden")
+
+	require.True(t, assertGolden(t, path, goldenMetrics(false), true))
+	assert.True(t, assertGolden(t, path, goldenMetrics(false), false))
+	// the serialization does not depend on the order of the metrics.
+	assert.True(t, assertGolden(t, path, goldenMetrics(true), false))
+
+	written, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, MarshalGolden(goldenMetrics(true)), written)
+}
+
+func TestAssertGoldenMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golden")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.golden")
+	require.True(t, assertGolden(t, path, goldenMetrics(false), true))
+
+	md := goldenMetrics(false)
+	md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(1).IntGauge().DataPoints().At(0).SetValue(11)
+	recorder := &failureRecorder{TB: t}
+	assert.False(t, assertGolden(recorder, path, md, false))
+	require.Len(t, recorder.failures, 1)
+	failure := recorder.failures[0]
+	assert.True(t, strings.HasPrefix(failure, "metrics do not match golden file "+path))
+	assert.Contains(t, failure, "--- "+path+"\n+++ actual\n")
+	assert.Contains(t, failure, "\n-        value: 10\n+        value: 11\n")
+}
+
+func TestAssertGoldenMissingFile(t *testing.T) {
+	recorder := &failureRecorder{TB: t}
+	path := filepath.Join("testdata", "missing.golden")
+	assert.False(t, assertGolden(recorder, path, goldenMetrics(false), false))
+	assert.Equal(t, []string{"golden file " + path + " does not exist, run the test with -update to create it"}, recorder.failures)
+}
+
+func TestMarshalGoldenMasks(t *testing.T) {
+	md := goldenMetrics(false)
+	golden := string(MarshalGolden(md, MaskTimestamps(), MaskAttributes("process.pid", "state"), MaskValues("process.threads", "process.latency")))
+	assert.NotContains(t, golden, "2021-01-02")
+	assert.NotContains(t, golden, "process.pid: 1")
+	assert.NotContains(t, golden, `state: "user"`)
+	assert.Contains(t, golden, "process.pid: <masked>")
+	assert.Contains(t, golden, "value: 1.5")
+	assert.NotContains(t, golden, "value: 10")
+	assert.NotContains(t, golden, "bucket counts: [1, 2]")
+	assert.Contains(t, golden, "explicit bounds: [0.5]")
+
+	// the unmasked serialization keeps all the fields.
+	golden = string(MarshalGolden(md))
+	for _, field := range []string{"2021-01-02T03:04:05.000000006Z", "process.pid: 1", `state: "user"`, "value: 10", "bucket counts: [1, 2]"} {
+		assert.Contains(t, golden, field)
+	}
+}
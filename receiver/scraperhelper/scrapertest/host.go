@@ -0,0 +1,159 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configmodels"
+)
+
+// Host is a component.Host for use in tests, that returns the extensions and
+// exporters registered with AddExtension and AddExporter, and records the
+// errors reported with ReportFatalError. The zero value is ready to use, and
+// a Host is safe for concurrent use.
+type Host struct {
+	mu          sync.Mutex
+	extensions  map[configmodels.Extension]component.ServiceExtension
+	exporters   map[configmodels.DataType]map[configmodels.Exporter]component.Exporter
+	fatalErrors []error
+	// changed is closed, and replaced, whenever a fatal error is reported.
+	changed chan struct{}
+}
+
+var _ component.Host = (*Host)(nil)
+
+// AddExtension registers the extension under its full name, such as
+// "health_check" or "health_check/1", whose type is the part before the
+// slash, if any.
+func (h *Host) AddExtension(name string, extension component.ServiceExtension) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.extensions == nil {
+		h.extensions = map[configmodels.Extension]component.ServiceExtension{}
+	}
+	h.extensions[&configmodels.ExtensionSettings{TypeVal: typeOf(name), NameVal: name}] = extension
+}
+
+// AddExporter registers the exporter of the data type under its full name,
+// such as "otlp" or "otlp/2", whose type is the part before the slash, if
+// any. GetExporters returns nil until an exporter is registered, like hosts
+// that do not support it.
+func (h *Host) AddExporter(dataType configmodels.DataType, name string, exporter component.Exporter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.exporters == nil {
+		h.exporters = map[configmodels.DataType]map[configmodels.Exporter]component.Exporter{}
+	}
+	if h.exporters[dataType] == nil {
+		h.exporters[dataType] = map[configmodels.Exporter]component.Exporter{}
+	}
+	h.exporters[dataType][&configmodels.ExporterSettings{TypeVal: typeOf(name), NameVal: name}] = exporter
+}
+
+// ReportFatalError records the error.
+func (h *Host) ReportFatalError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fatalErrors = append(h.fatalErrors, err)
+	if h.changed != nil {
+		close(h.changed)
+		h.changed = nil
+	}
+}
+
+// FatalErrors returns the errors reported with ReportFatalError, in the
+// order they were reported.
+func (h *Host) FatalErrors() []error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]error(nil), h.fatalErrors...)
+}
+
+// GetFactory returns nil, as the host has no factories.
+func (h *Host) GetFactory(component.Kind, configmodels.Type) component.Factory {
+	return nil
+}
+
+// GetExtensions returns the extensions registered with AddExtension.
+func (h *Host) GetExtensions() map[configmodels.Extension]component.ServiceExtension {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	extensions := make(map[configmodels.Extension]component.ServiceExtension, len(h.extensions))
+	for cfg, extension := range h.extensions {
+		extensions[cfg] = extension
+	}
+	return extensions
+}
+
+// GetExporters returns the exporters registered with AddExporter, by data
+// type, or nil if none was.
+func (h *Host) GetExporters() map[configmodels.DataType]map[configmodels.Exporter]component.Exporter {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.exporters == nil {
+		return nil
+	}
+	exporters := make(map[configmodels.DataType]map[configmodels.Exporter]component.Exporter, len(h.exporters))
+	for dataType, byConfig := range h.exporters {
+		exporters[dataType] = make(map[configmodels.Exporter]component.Exporter, len(byConfig))
+		for cfg, exporter := range byConfig {
+			exporters[dataType][cfg] = exporter
+		}
+	}
+	return exporters
+}
+
+// fatalErrorsChanged returns the reported fatal errors, and a channel closed
+// once another one is reported.
+func (h *Host) fatalErrorsChanged() ([]error, <-chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.changed == nil {
+		h.changed = make(chan struct{})
+	}
+	return append([]error(nil), h.fatalErrors...), h.changed
+}
+
+// WaitForFatalErrors waits until at least n fatal errors were reported to the
+// host, and returns them. The test fails immediately if they were not
+// reported within the timeout, so that WaitForFatalErrors must be called from
+// the goroutine running the test.
+func WaitForFatalErrors(t testing.TB, host *Host, n int, timeout time.Duration) []error {
+	t.Helper()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		errs, changed := host.fatalErrorsChanged()
+		if len(errs) >= n {
+			return errs
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			t.Fatalf("host recorded %d fatal errors after %v, want %d", len(errs), timeout, n)
+			return nil
+		}
+	}
+}
+
+// typeOf returns the type of the component with the full name.
+func typeOf(name string) configmodels.Type {
+	return configmodels.Type(strings.SplitN(name, "/", 2)[0])
+}
@@ -0,0 +1,94 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configmodels"
+)
+
+type nopComponent struct{}
+
+func (nopComponent) Start(context.Context, component.Host) error { return nil }
+func (nopComponent) Shutdown(context.Context) error              { return nil }
+
+func TestHostExtensions(t *testing.T) {
+	host := new(Host)
+	assert.Empty(t, host.GetExtensions())
+	assert.Nil(t, host.GetFactory(component.KindReceiver, "receiver"))
+
+	first, second := &nopComponent{}, &nopComponent{}
+	host.AddExtension("health_check", first)
+	host.AddExtension("health_check/2", second)
+
+	extensions := host.GetExtensions()
+	require.Len(t, extensions, 2)
+	byName := map[string]component.ServiceExtension{}
+	for cfg, extension := range extensions {
+		assert.Equal(t, configmodels.Type("health_check"), cfg.Type())
+		byName[cfg.Name()] = extension
+	}
+	assert.Same(t, first, byName["health_check"])
+	assert.Same(t, second, byName["health_check/2"])
+}
+
+func TestHostExporters(t *testing.T) {
+	host := new(Host)
+	assert.Nil(t, host.GetExporters())
+
+	host.AddExporter(configmodels.MetricsDataType, "otlp/2", nopComponent{})
+
+	exporters := host.GetExporters()
+	require.Len(t, exporters, 1)
+	require.Len(t, exporters[configmodels.MetricsDataType], 1)
+	for cfg := range exporters[configmodels.MetricsDataType] {
+		assert.Equal(t, configmodels.Type("otlp"), cfg.Type())
+		assert.Equal(t, "otlp/2", cfg.Name())
+	}
+}
+
+func TestHostFatalErrors(t *testing.T) {
+	host := new(Host)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			time.Sleep(time.Millisecond)
+			host.ReportFatalError(fmt.Errorf("err%d", i))
+		}(i)
+	}
+	errs := WaitForFatalErrors(t, host, 3, time.Second)
+	wg.Wait()
+	assert.Len(t, errs, 3)
+	assert.Equal(t, errs, host.FatalErrors())
+
+	recorder := &failureRecorder{TB: t}
+	assert.Nil(t, WaitForFatalErrors(recorder, host, 4, 10*time.Millisecond))
+	assert.Equal(t, []string{"host recorded 3 fatal errors after 10ms, want 4"}, recorder.failures)
+
+	host.ReportFatalError(errors.New("err3"))
+	assert.EqualError(t, host.FatalErrors()[3], "err3")
+}
@@ -0,0 +1,249 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+const (
+	// firstConsumeTimeout is how long the receiver is given to pass metrics
+	// to the next consumer, before checks needing them carry on without.
+	firstConsumeTimeout = time.Second
+	// shutdownDeadline is the deadline of the shutdown of a receiver whose
+	// next consumer is blocked, and shutdownGracePeriod how long Shutdown
+	// may return after it.
+	shutdownDeadline    = 100 * time.Millisecond
+	shutdownGracePeriod = time.Second
+)
+
+// CreateReceiver creates the receiver checked by VerifyReceiverLifecycle,
+// passing the metrics it receives to the next consumer.
+type CreateReceiver func(nextConsumer consumer.MetricsConsumer) (component.Receiver, error)
+
+// VerifyReceiverLifecycle runs the battery of lifecycle checks all receivers
+// should pass, such as the receivers created with scraperhelper, against new
+// receivers created with create, each in a subtest:
+//   - the next consumer is only called once the receiver is started, and
+//     not anymore once Shutdown returns,
+//   - Shutdown can be called without Start, and twice,
+//   - Shutdown returns soon after its context expires, even if the next
+//     consumer is blocked until then,
+//   - the receiver keeps running when the next consumer fails,
+//   - no fatal error is reported to the host, and no goroutine is leaked
+//     once the receiver is shut down, which is detected with goleak.
+//
+// Receivers should be created with a short collection interval, as the
+// checks needing metrics wait up to a second for them, and carry on without
+// them otherwise. Receiver authors should call VerifyReceiverLifecycle from
+// the tests of their receivers.
+func VerifyReceiverLifecycle(t *testing.T, create CreateReceiver) {
+	t.Run("StartShutdown", func(t *testing.T) {
+		check := newLifecycleCheck(t, create, nil)
+		defer check.verify()
+
+		check.start()
+		check.waitForConsume()
+		check.shutdown(context.Background(), true)
+	})
+
+	t.Run("ShutdownWithoutStart", func(t *testing.T) {
+		check := newLifecycleCheck(t, create, nil)
+		defer check.verify()
+
+		check.shutdown(context.Background(), true)
+	})
+
+	t.Run("ShutdownTwice", func(t *testing.T) {
+		check := newLifecycleCheck(t, create, nil)
+		defer check.verify()
+
+		check.start()
+		check.shutdown(context.Background(), true)
+		check.shutdown(context.Background(), true)
+	})
+
+	t.Run("ShutdownDeadline", func(t *testing.T) {
+		release := make(chan struct{})
+		check := newLifecycleCheck(t, create, func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-release:
+				return nil
+			}
+		})
+		defer check.verify()
+
+		check.start()
+		check.waitForConsume()
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownDeadline)
+		defer cancel()
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			check.shutdown(ctx, false)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(shutdownDeadline + shutdownGracePeriod):
+			t.Errorf("Shutdown did not return within %v after its context expired", shutdownGracePeriod)
+		}
+		// the next consumer is released once Shutdown returned, or was given
+		// up on, so that the consume still in progress is not a leak.
+		close(release)
+		<-stopped
+	})
+
+	t.Run("FailingConsumer", func(t *testing.T) {
+		check := newLifecycleCheck(t, create, func(context.Context) error {
+			return errors.New("consumer failed")
+		})
+		defer check.verify()
+
+		check.start()
+		check.waitForConsume()
+		check.waitForConsume()
+		check.shutdown(context.Background(), true)
+	})
+}
+
+// lifecycleCheck runs a lifecycle check against a receiver.
+type lifecycleCheck struct {
+	t        *testing.T
+	ignore   goleak.Option
+	receiver component.Receiver
+	host     *Host
+	consumer *lifecycleConsumer
+}
+
+func newLifecycleCheck(t *testing.T, create CreateReceiver, consume func(context.Context) error) *lifecycleCheck {
+	t.Helper()
+	check := &lifecycleCheck{
+		t:        t,
+		ignore:   goleak.IgnoreCurrent(),
+		host:     new(Host),
+		consumer: &lifecycleConsumer{consume: consume, calls: make(chan struct{}, 1)},
+	}
+	receiver, err := create(check.consumer)
+	if err != nil {
+		t.Fatalf("failed to create the receiver: %v", err)
+	}
+	check.receiver = receiver
+	return check
+}
+
+func (c *lifecycleCheck) start() {
+	c.t.Helper()
+	c.consumer.setState(true, false)
+	if err := c.receiver.Start(context.Background(), c.host); err != nil {
+		c.t.Fatalf("failed to start the receiver: %v", err)
+	}
+}
+
+// waitForConsume waits until the next consumer is called, if it is before
+// firstConsumeTimeout.
+func (c *lifecycleCheck) waitForConsume() {
+	select {
+	case <-c.consumer.calls:
+	case <-time.After(firstConsumeTimeout):
+	}
+}
+
+// shutdown shuts down the receiver, failing the check if it fails and
+// mustSucceed is set.
+func (c *lifecycleCheck) shutdown(ctx context.Context, mustSucceed bool) {
+	c.t.Helper()
+	err := c.receiver.Shutdown(ctx)
+	c.consumer.setState(c.consumer.isStarted(), true)
+	if err != nil && mustSucceed {
+		c.t.Errorf("failed to shut down the receiver: %v", err)
+	}
+}
+
+// verify checks the violations recorded by the next consumer and the host,
+// and that no goroutine was leaked.
+func (c *lifecycleCheck) verify() {
+	c.t.Helper()
+	for _, violation := range c.consumer.takeViolations() {
+		c.t.Error(violation)
+	}
+	for _, err := range c.host.FatalErrors() {
+		c.t.Errorf("the receiver reported a fatal error: %v", err)
+	}
+	goleak.VerifyNone(c.t, c.ignore)
+}
+
+// lifecycleConsumer records the calls of the next consumer violating the
+// lifecycle of the receiver.
+type lifecycleConsumer struct {
+	consume func(context.Context) error
+	calls   chan struct{}
+
+	mu         sync.Mutex
+	started    bool
+	stopped    bool
+	violations []string
+}
+
+func (c *lifecycleConsumer) ConsumeMetrics(ctx context.Context, _ pdata.Metrics) error {
+	c.mu.Lock()
+	switch {
+	case !c.started:
+		c.violations = append(c.violations, "the next consumer was called before the receiver was started")
+	case c.stopped:
+		c.violations = append(c.violations, "the next consumer was called after Shutdown returned")
+	}
+	c.mu.Unlock()
+
+	select {
+	case c.calls <- struct{}{}:
+	default:
+	}
+	if c.consume == nil {
+		return nil
+	}
+	return c.consume(ctx)
+}
+
+func (c *lifecycleConsumer) setState(started, stopped bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started, c.stopped = started, stopped
+}
+
+func (c *lifecycleConsumer) isStarted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.started
+}
+
+func (c *lifecycleConsumer) takeViolations() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	violations := c.violations
+	c.violations = nil
+	return violations
+}
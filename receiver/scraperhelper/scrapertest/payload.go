@@ -0,0 +1,91 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"strconv"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+// PayloadTimestamp is the timestamp of the data points of the generated
+// payloads.
+const PayloadTimestamp = pdata.TimestampUnixNano(1600000000000000000)
+
+// GenerateMetrics generates metricCount int gauges named "metric.<i>", each
+// with pointCount data points labeled "point"="<j>" and of value
+// i*pointCount+j. The generated metrics only depend on the arguments.
+func GenerateMetrics(metricCount, pointCount int) pdata.MetricSlice {
+	metrics := pdata.NewMetricSlice()
+	metrics.Resize(metricCount)
+	for i := 0; i < metricCount; i++ {
+		metric := metrics.At(i)
+		metric.SetName("metric." + strconv.Itoa(i))
+		metric.SetDataType(pdata.MetricDataTypeIntGauge)
+		points := metric.IntGauge().DataPoints()
+		points.Resize(pointCount)
+		for j := 0; j < pointCount; j++ {
+			point := points.At(j)
+			point.LabelsMap().Insert("point", strconv.Itoa(j))
+			point.SetTimestamp(PayloadTimestamp)
+			point.SetValue(int64(i*pointCount + j))
+		}
+	}
+	return metrics
+}
+
+// GenerateResourceMetrics generates resourceCount resources with the attribute
+// "resource"="<i>", each with the metrics generated by GenerateMetrics with
+// the given metricCount and pointCount. The generated resource metrics only
+// depend on the arguments.
+func GenerateResourceMetrics(resourceCount, metricCount, pointCount int) pdata.ResourceMetricsSlice {
+	resourceMetrics := pdata.NewResourceMetricsSlice()
+	resourceMetrics.Resize(resourceCount)
+	for i := 0; i < resourceCount; i++ {
+		rm := resourceMetrics.At(i)
+		rm.Resource().Attributes().InsertString("resource", strconv.Itoa(i))
+		ilms := rm.InstrumentationLibraryMetrics()
+		ilms.Resize(1)
+		GenerateMetrics(metricCount, pointCount).MoveAndAppendTo(ilms.At(0).Metrics())
+	}
+	return resourceMetrics
+}
+
+// NewScrapeMetrics returns a scrape function returning a copy of the metrics
+// generated by GenerateMetrics on every scrape. The metrics are only generated
+// once, so that benchmarks only measure the cost of copying them.
+func NewScrapeMetrics(metricCount, pointCount int) scraperhelper.ScrapeMetrics {
+	payload := GenerateMetrics(metricCount, pointCount)
+	return func(context.Context) (pdata.MetricSlice, error) {
+		metrics := pdata.NewMetricSlice()
+		payload.CopyTo(metrics)
+		return metrics, nil
+	}
+}
+
+// NewScrapeResourceMetrics returns a scrape function returning a copy of the
+// resource metrics generated by GenerateResourceMetrics on every scrape. The
+// resource metrics are only generated once, so that benchmarks only measure
+// the cost of copying them.
+func NewScrapeResourceMetrics(resourceCount, metricCount, pointCount int) scraperhelper.ScrapeResourceMetrics {
+	payload := GenerateResourceMetrics(resourceCount, metricCount, pointCount)
+	return func(context.Context) (pdata.ResourceMetricsSlice, error) {
+		resourceMetrics := pdata.NewResourceMetricsSlice()
+		payload.CopyTo(resourceMetrics)
+		return resourceMetrics, nil
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+func TestGenerateMetrics(t *testing.T) {
+	metrics := GenerateMetrics(3, 2)
+	require.Equal(t, 3, metrics.Len())
+
+	metric := metrics.At(2)
+	assert.Equal(t, "metric.2", metric.Name())
+	require.Equal(t, pdata.MetricDataTypeIntGauge, metric.DataType())
+	points := metric.IntGauge().DataPoints()
+	require.Equal(t, 2, points.Len())
+	assert.Equal(t, int64(5), points.At(1).Value())
+	assert.Equal(t, PayloadTimestamp, points.At(1).Timestamp())
+	value, ok := points.At(1).LabelsMap().Get("point")
+	require.True(t, ok)
+	assert.Equal(t, "1", value)
+
+	assert.Equal(t, metrics, GenerateMetrics(3, 2))
+}
+
+func TestGenerateResourceMetrics(t *testing.T) {
+	resourceMetrics := GenerateResourceMetrics(2, 3, 4)
+	require.Equal(t, 2, resourceMetrics.Len())
+
+	attr, ok := resourceMetrics.At(1).Resource().Attributes().Get("resource")
+	require.True(t, ok)
+	assert.Equal(t, "1", attr.StringVal())
+
+	md := pdata.NewMetrics()
+	resourceMetrics.CopyTo(md.ResourceMetrics())
+	metricCount, pointCount := md.MetricAndDataPointCount()
+	assert.Equal(t, 6, metricCount)
+	assert.Equal(t, 24, pointCount)
+}
+
+func TestNewScrapeMetrics(t *testing.T) {
+	scrape := NewScrapeMetrics(3, 2)
+	first, err := scrape(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, GenerateMetrics(3, 2), first)
+
+	// every scrape returns a copy, that can be modified without changing the
+	// next scrapes.
+	first.At(0).SetName("modified")
+	second, err := scrape(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, GenerateMetrics(3, 2), second)
+}
+
+func TestNewScrapeResourceMetrics(t *testing.T) {
+	scrape := NewScrapeResourceMetrics(2, 3, 2)
+	first, err := scrape(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, GenerateResourceMetrics(2, 3, 2), first)
+
+	first.Resize(0)
+	second, err := scrape(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, GenerateResourceMetrics(2, 3, 2), second)
+}
@@ -0,0 +1,79 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"runtime/debug"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+// RunOnce runs a single initialize, scrape, consume and close cycle of a
+// receiver with a single scraper, created with NewMetricsScraper from the
+// scrape function, the configuration of the scraper, if not nil, and the
+// options. The cycle runs synchronously with scraperhelper.ScrapeCycle, on
+// the same code paths as a running receiver, including the scrape timeout,
+// the middleware and the observability of the scraper. A scraper whose
+// initial delay is set is not scraped.
+//
+// The metrics passed to the next consumer are returned, with the error of
+// the scrape, or of the initialization or closing of the scraper. The test
+// fails immediately, with the stack trace, if the cycle panics.
+func RunOnce(t testing.TB, cfg scraperhelper.ScraperConfig, scrape scraperhelper.ScrapeMetrics, options ...scraperhelper.ScraperOption) (pdata.Metrics, error) {
+	t.Helper()
+	if cfg != nil {
+		options = append([]scraperhelper.ScraperOption{scraperhelper.WithConfig(cfg)}, options...)
+	}
+	var scrapeErr error
+	scraper := scraperhelper.NewMetricsScraper("scraper", func(ctx context.Context) (pdata.MetricSlice, error) {
+		metrics, err := scrape(ctx)
+		scrapeErr = err
+		return metrics, err
+	}, options...)
+
+	sink := new(SinkConsumer)
+	settings := scraperhelper.DefaultScraperControllerSettings("receiver")
+	if err := runCycle(t, &settings, zap.NewNop(), sink, scraperhelper.AddMetricsScraper(scraper)); err != nil {
+		return pdata.NewMetrics(), err
+	}
+	return consumedMetrics(sink), scrapeErr
+}
+
+// consumedMetrics returns the metrics of all the batches recorded by the
+// sink, moved to a single pdata.Metrics.
+func consumedMetrics(sink *SinkConsumer) pdata.Metrics {
+	md := pdata.NewMetrics()
+	for _, batch := range sink.Batches() {
+		batch.ResourceMetrics().MoveAndAppendTo(md.ResourceMetrics())
+	}
+	return md
+}
+
+// runCycle runs the cycle of the receiver, failing the test if it panics.
+func runCycle(t testing.TB, settings *scraperhelper.ScraperControllerSettings, logger *zap.Logger, sink *SinkConsumer, options ...scraperhelper.ScraperControllerOption) (err error) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("scrape cycle panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return scraperhelper.ScrapeCycle(context.Background(), settings, logger, sink, componenttest.NewNopHost(), options...)
+}
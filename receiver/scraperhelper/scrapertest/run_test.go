@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+func TestRunOnce(t *testing.T) {
+	md, err := RunOnce(t, nil, NewScrapeMetrics(2, 3), scraperhelper.WithMetricNameFilter(nil, []string{"metric.1"}))
+	require.NoError(t, err)
+	AssertMetricNames(t, md, "metric.0")
+	AssertDataPointCount(t, md, 3)
+}
+
+func TestRunOnceScrapeError(t *testing.T) {
+	_, err := RunOnce(t, nil, func(context.Context) (pdata.MetricSlice, error) {
+		return pdata.NewMetricSlice(), errors.New("err1")
+	})
+	assert.EqualError(t, err, "err1")
+}
+
+func TestRunOnceTimeout(t *testing.T) {
+	cfg := &scraperhelper.ScraperSettings{TimeoutVal: time.Millisecond}
+	_, err := RunOnce(t, cfg, func(ctx context.Context) (pdata.MetricSlice, error) {
+		<-ctx.Done()
+		return pdata.NewMetricSlice(), ctx.Err()
+	})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestRunOncePanic(t *testing.T) {
+	recorder := &failureRecorder{TB: t}
+	_, err := RunOnce(recorder, nil, func(context.Context) (pdata.MetricSlice, error) {
+		panic("scraper bug")
+	})
+	assert.NoError(t, err)
+	require.Len(t, recorder.failures, 1)
+	assert.True(t, strings.HasPrefix(recorder.failures[0], "scrape cycle panicked: scraper bug\n"))
+	assert.Contains(t, recorder.failures[0], "TestRunOncePanic")
+}
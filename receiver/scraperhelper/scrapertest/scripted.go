@@ -0,0 +1,145 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// Step is a step of the script of a ScriptedScraper, describing what a
+// single scrape does.
+type Step struct {
+	// Delay is how long the scrape takes, measured with the clock of the
+	// scraper. The scrape returns the error of its context if the context
+	// expires first.
+	Delay time.Duration
+	// Metrics are returned by the scrape, copied, unless Err is set.
+	Metrics pdata.MetricSlice
+	// Err is returned by the scrape, if set.
+	Err error
+	// Panic, if set, is the value the scrape panics with, once the delay
+	// elapsed.
+	Panic interface{}
+}
+
+// ScriptedScraperOption changes a ScriptedScraper.
+type ScriptedScraperOption func(*ScriptedScraper)
+
+// WithRepeat makes the scraper repeat its script once it reached the end,
+// instead of repeating the last step.
+func WithRepeat() ScriptedScraperOption {
+	return func(s *ScriptedScraper) {
+		s.repeat = true
+	}
+}
+
+// WithClock makes the scraper measure the delays of its steps with the fake
+// clock, instead of wall time.
+func WithClock(clock *FakeClock) ScriptedScraperOption {
+	return func(s *ScriptedScraper) {
+		s.clock = clock
+	}
+}
+
+// ScriptedScraper is a scrape function for use in tests, whose behavior
+// changes over time: each scrape runs the next step of its script, such as
+// failing three times then succeeding, or taking longer on the second scrape.
+// A ScriptedScraper is safe for concurrent use.
+type ScriptedScraper struct {
+	script []Step
+	repeat bool
+	clock  *FakeClock
+
+	mu    sync.Mutex
+	calls int
+}
+
+// NewScriptedScraper returns a scraper running the steps of the script, one
+// per scrape, then repeating the last step, unless WithRepeat is used. A
+// scraper with an empty script returns no metrics.
+func NewScriptedScraper(script []Step, options ...ScriptedScraperOption) *ScriptedScraper {
+	s := &ScriptedScraper{script: script}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Scrape runs the next step of the script. It is a
+// scraperhelper.ScrapeMetrics.
+func (s *ScriptedScraper) Scrape(ctx context.Context) (pdata.MetricSlice, error) {
+	step := s.nextStep()
+	if step.Delay > 0 {
+		elapsed, stop := s.after(step.Delay)
+		select {
+		case <-elapsed:
+		case <-ctx.Done():
+			stop()
+			return pdata.NewMetricSlice(), ctx.Err()
+		}
+	}
+	if step.Panic != nil {
+		panic(step.Panic)
+	}
+	if step.Err != nil {
+		return pdata.NewMetricSlice(), step.Err
+	}
+
+	metrics := pdata.NewMetricSlice()
+	if step.Metrics != (pdata.MetricSlice{}) {
+		step.Metrics.CopyTo(metrics)
+	}
+	return metrics, nil
+}
+
+// CallCount returns the number of scrapes, including the scrapes still in
+// progress.
+func (s *ScriptedScraper) CallCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// nextStep counts the scrape, and returns its step.
+func (s *ScriptedScraper) nextStep() Step {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if len(s.script) == 0 {
+		return Step{}
+	}
+	i := s.calls - 1
+	if s.repeat {
+		i %= len(s.script)
+	} else if i >= len(s.script) {
+		i = len(s.script) - 1
+	}
+	return s.script[i]
+}
+
+// after returns a channel receiving the time once the delay elapsed, and a
+// function stopping the wait.
+func (s *ScriptedScraper) after(d time.Duration) (<-chan time.Time, func()) {
+	if s.clock != nil {
+		ch := s.clock.After(d)
+		return ch, func() { s.clock.stop(ch) }
+	}
+	timer := time.NewTimer(d)
+	return timer.C, func() { timer.Stop() }
+}
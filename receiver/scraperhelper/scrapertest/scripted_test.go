@@ -0,0 +1,220 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	assert.Equal(t, start, <-clock.After(0))
+
+	first, second := clock.After(2*time.Second), clock.After(time.Second)
+	assert.Equal(t, 2, clock.Waiters())
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), <-second)
+	assert.Len(t, first, 0)
+	assert.Equal(t, 1, clock.Waiters())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-first)
+	assert.Equal(t, start.Add(2*time.Second), clock.Now())
+	assert.Equal(t, 0, clock.Waiters())
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	assert.Panics(t, func() { clock.NewTicker(0) })
+
+	ticker := clock.NewTicker(10 * time.Second)
+	after := clock.After(15 * time.Second)
+	assert.Equal(t, 2, clock.Waiters())
+	clock.Advance(5 * time.Second)
+	assert.Len(t, ticker.C(), 0)
+
+	// the ticks are sent in the order of the deadlines, and the tick at 20s
+	// is dropped, as the one at 10s was not received.
+	clock.Advance(20 * time.Second)
+	assert.Equal(t, 1, clock.PendingTicks())
+	assert.Equal(t, start.Add(10*time.Second), <-ticker.C())
+	assert.Equal(t, start.Add(15*time.Second), <-after)
+	assert.Equal(t, start.Add(25*time.Second), clock.Now())
+	assert.Equal(t, 0, clock.PendingTicks())
+
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, start.Add(30*time.Second), <-ticker.C())
+
+	ticker.Stop()
+	assert.Equal(t, 0, clock.Waiters())
+	clock.Advance(10 * time.Second)
+	assert.Len(t, ticker.C(), 0)
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	assert.Equal(t, start, <-clock.NewTimer(0).C())
+
+	timer, stopped := clock.NewTimer(10*time.Second), clock.NewTimer(5*time.Second)
+	stopped.Stop()
+	assert.Equal(t, 1, clock.Waiters())
+	clock.Advance(20 * time.Second)
+	assert.Equal(t, start.Add(10*time.Second), <-timer.C())
+	assert.Len(t, stopped.C(), 0)
+	assert.Equal(t, 0, clock.Waiters())
+}
+
+func TestFakeClockBlockUntilWaiters(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	waited := make(chan error, 1)
+	go func() {
+		waited <- clock.BlockUntilWaiters(context.Background(), 2)
+	}()
+	clock.After(time.Second)
+	select {
+	case <-waited:
+		t.Fatal("returned before the second waiter waited")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.NewTimer(time.Second)
+	assert.NoError(t, <-waited)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, clock.BlockUntilWaiters(ctx, 3))
+}
+
+func TestWaitForClockWaiters(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	ticker := clock.NewTicker(time.Second)
+	go func() {
+		<-clock.After(time.Second)
+	}()
+	WaitForClockWaiters(t, clock, 2, time.Second)
+
+	clock.Advance(time.Second)
+	recorder := &failureRecorder{TB: t}
+	WaitForClockWaiters(recorder, clock, 1, 10*time.Millisecond)
+	assert.Equal(t, []string{"1 waiters waited for the clock after 10ms with 1 ticks not received, want 1"}, recorder.failures)
+
+	<-ticker.C()
+	WaitForClockWaiters(t, clock, 1, time.Second)
+}
+
+// scrapeNames returns the names of the metrics of the scrapes, or their
+// errors.
+func scrapeNames(scraper *ScriptedScraper, scrapes int) []string {
+	var results []string
+	for i := 0; i < scrapes; i++ {
+		metrics, err := scraper.Scrape(context.Background())
+		if err != nil {
+			results = append(results, err.Error())
+			continue
+		}
+		for j := 0; j < metrics.Len(); j++ {
+			results = append(results, metrics.At(j).Name())
+		}
+	}
+	return results
+}
+
+func TestScriptedScraper(t *testing.T) {
+	script := []Step{
+		{Err: errors.New("err1")},
+		{Err: errors.New("err2")},
+		{Metrics: GenerateMetrics(1, 1)},
+	}
+
+	scraper := NewScriptedScraper(script)
+	assert.Equal(t, []string{"err1", "err2", "metric.0", "metric.0", "metric.0"}, scrapeNames(scraper, 5))
+	assert.Equal(t, 5, scraper.CallCount())
+
+	scraper = NewScriptedScraper(script, WithRepeat())
+	assert.Equal(t, []string{"err1", "err2", "metric.0", "err1", "err2"}, scrapeNames(scraper, 5))
+
+	scraper = NewScriptedScraper(nil)
+	assert.Empty(t, scrapeNames(scraper, 2))
+	assert.Equal(t, 2, scraper.CallCount())
+}
+
+func TestScriptedScraperCopiesMetrics(t *testing.T) {
+	scraper := NewScriptedScraper([]Step{{Metrics: GenerateMetrics(1, 1)}})
+	metrics, err := scraper.Scrape(context.Background())
+	require.NoError(t, err)
+	metrics.At(0).SetName("modified")
+	assert.Equal(t, []string{"metric.0"}, scrapeNames(scraper, 1))
+}
+
+func TestScriptedScraperPanic(t *testing.T) {
+	scraper := NewScriptedScraper([]Step{{Panic: "scraper bug"}, {}})
+	assert.PanicsWithValue(t, "scraper bug", func() { _, _ = scraper.Scrape(context.Background()) })
+	assert.NotPanics(t, func() { _, _ = scraper.Scrape(context.Background()) })
+}
+
+func TestScriptedScraperDelay(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	scraper := NewScriptedScraper([]Step{{Delay: 5 * time.Second, Metrics: GenerateMetrics(1, 1)}}, WithClock(clock))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := scraper.Scrape(context.Background())
+		done <- err
+	}()
+	require.NoError(t, clock.BlockUntilWaiters(context.Background(), 1))
+	clock.Advance(4 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("scrape returned before its delay elapsed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	assert.NoError(t, <-done)
+}
+
+func TestScriptedScraperDelayTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	scraper := NewScriptedScraper([]Step{{Delay: 5 * time.Second}, {}}, WithClock(clock))
+
+	// the scrape times out without waiting for its delay.
+	cfg := &scraperhelper.ScraperSettings{TimeoutVal: 10 * time.Millisecond}
+	_, err := RunOnce(t, cfg, scraper.Scrape)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 0, clock.Waiters())
+
+	md, err := RunOnce(t, cfg, scraper.Scrape)
+	require.NoError(t, err)
+	assert.Equal(t, 0, md.MetricCount())
+	assert.Equal(t, 2, scraper.CallCount())
+}
+
+func TestScriptedScraperWallTimeDelay(t *testing.T) {
+	scraper := NewScriptedScraper([]Step{{Delay: time.Millisecond, Metrics: pdata.NewMetricSlice()}})
+	start := time.Now()
+	_, err := scraper.Scrape(context.Background())
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Millisecond))
+}
@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// SinkConsumer is a consumer.MetricsConsumer for use in tests, that records
+// the batches of metrics it consumes. Errors can be injected for all the
+// calls to ConsumeMetrics, or for specific calls, in which case the batch is
+// not recorded. The zero value is ready to use, and a SinkConsumer is safe
+// for concurrent use.
+type SinkConsumer struct {
+	mu      sync.Mutex
+	batches []pdata.Metrics
+	calls   int
+	// consumeError is returned by the calls without an error of their own.
+	consumeError error
+	callErrors   map[int]error
+	// changed is closed, and replaced, whenever a batch is recorded.
+	changed chan struct{}
+}
+
+var _ consumer.MetricsConsumer = (*SinkConsumer)(nil)
+
+// ConsumeMetrics records the batch of metrics, unless an error was injected
+// for the call, in which case the error is returned.
+func (s *SinkConsumer) ConsumeMetrics(_ context.Context, md pdata.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	err, ok := s.callErrors[s.calls]
+	if !ok {
+		err = s.consumeError
+	}
+	if err != nil {
+		return err
+	}
+
+	s.batches = append(s.batches, md)
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = nil
+	}
+	return nil
+}
+
+// SetConsumeError sets the error returned by the calls to ConsumeMetrics
+// without an error set with SetCallError, or clears it if err is nil.
+func (s *SinkConsumer) SetConsumeError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.consumeError = err
+}
+
+// SetCallError sets the error returned by the given call to ConsumeMetrics,
+// counting from 1 since the sink was created or reset.
+func (s *SinkConsumer) SetCallError(call int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.callErrors == nil {
+		s.callErrors = map[int]error{}
+	}
+	s.callErrors[call] = err
+}
+
+// Batches returns the batches of metrics recorded by the sink, in the order
+// they were consumed.
+func (s *SinkConsumer) Batches() []pdata.Metrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]pdata.Metrics(nil), s.batches...)
+}
+
+// Calls returns the number of calls to ConsumeMetrics, including the calls
+// that returned an error.
+func (s *SinkConsumer) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// Reset deletes the recorded batches, and the injected errors.
+func (s *SinkConsumer) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = nil
+	s.calls = 0
+	s.consumeError = nil
+	s.callErrors = nil
+}
+
+// batchesChanged returns the recorded batches, and a channel closed once
+// another batch is recorded.
+func (s *SinkConsumer) batchesChanged() ([]pdata.Metrics, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.changed == nil {
+		s.changed = make(chan struct{})
+	}
+	return append([]pdata.Metrics(nil), s.batches...), s.changed
+}
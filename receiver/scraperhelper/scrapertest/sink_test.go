@@ -0,0 +1,133 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// namedMetrics returns metrics holding a single metric with the given name.
+func namedMetrics(name string) pdata.Metrics {
+	md := pdata.NewMetrics()
+	rms := md.ResourceMetrics()
+	rms.Resize(1)
+	rms.At(0).InstrumentationLibraryMetrics().Resize(1)
+	GenerateMetrics(1, 2).MoveAndAppendTo(rms.At(0).InstrumentationLibraryMetrics().At(0).Metrics())
+	rms.At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0).SetName(name)
+	return md
+}
+
+func TestSinkConsumer(t *testing.T) {
+	sink := new(SinkConsumer)
+	sink.SetCallError(2, errors.New("call 2"))
+	require.NoError(t, sink.ConsumeMetrics(context.Background(), namedMetrics("first")))
+	assert.EqualError(t, sink.ConsumeMetrics(context.Background(), namedMetrics("second")), "call 2")
+	require.NoError(t, sink.ConsumeMetrics(context.Background(), namedMetrics("third")))
+
+	sink.SetConsumeError(errors.New("all calls"))
+	assert.EqualError(t, sink.ConsumeMetrics(context.Background(), namedMetrics("fourth")), "all calls")
+	sink.SetConsumeError(nil)
+	require.NoError(t, sink.ConsumeMetrics(context.Background(), namedMetrics("fifth")))
+
+	// the batches of the failed calls are not recorded.
+	batches := sink.Batches()
+	require.Len(t, batches, 3)
+	AssertMetricNames(t, batches[0], "first")
+	AssertMetricNames(t, batches[1], "third")
+	AssertMetricNames(t, batches[2], "fifth")
+	assert.Equal(t, 5, sink.Calls())
+
+	sink.Reset()
+	assert.Empty(t, sink.Batches())
+	assert.Equal(t, 0, sink.Calls())
+	sink.SetConsumeError(errors.New("after reset"))
+	sink.Reset()
+	assert.NoError(t, sink.ConsumeMetrics(context.Background(), namedMetrics("first")))
+}
+
+func TestSinkConsumerConcurrent(t *testing.T) {
+	sink := new(SinkConsumer)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, sink.ConsumeMetrics(context.Background(), namedMetrics(fmt.Sprint(i))))
+		}(i)
+	}
+	batches := WaitForBatches(t, sink, 10, time.Second)
+	wg.Wait()
+	assert.Len(t, batches, 10)
+	assert.Equal(t, 10, sink.Calls())
+}
+
+// failureRecorder records the failures of a test, without failing it.
+type failureRecorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *failureRecorder) Helper() {}
+
+func (r *failureRecorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *failureRecorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+func TestWaitForBatches(t *testing.T) {
+	sink := new(SinkConsumer)
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(time.Millisecond)
+			assert.NoError(t, sink.ConsumeMetrics(context.Background(), namedMetrics(fmt.Sprint(i))))
+		}
+	}()
+	batches := WaitForBatches(t, sink, 3, time.Second)
+	require.Len(t, batches, 3)
+	AssertMetricNames(t, batches[2], "2")
+
+	recorder := &failureRecorder{TB: t}
+	assert.Nil(t, WaitForBatches(recorder, sink, 4, 10*time.Millisecond))
+	assert.Equal(t, []string{"sink recorded 3 batches of metrics after 10ms, want 4"}, recorder.failures)
+}
+
+func TestAssertions(t *testing.T) {
+	md := pdata.NewMetrics()
+	GenerateResourceMetrics(2, 2, 3).MoveAndAppendTo(md.ResourceMetrics())
+	assert.Equal(t, []string{"metric.0", "metric.1", "metric.0", "metric.1"}, MetricNames(md))
+	assert.Equal(t, 12, DataPointCount(md))
+	assert.True(t, AssertMetricNames(t, md, "metric.0", "metric.1", "metric.0", "metric.1"))
+	assert.True(t, AssertDataPointCount(t, md, 12))
+	assert.True(t, AssertBatchDataPointCounts(t, []pdata.Metrics{md, pdata.NewMetrics()}, 12, 0))
+
+	recorder := &failureRecorder{TB: t}
+	assert.False(t, AssertMetricNames(recorder, md, "metric.0"))
+	assert.False(t, AssertDataPointCount(recorder, md, 11))
+	assert.False(t, AssertBatchDataPointCounts(recorder, []pdata.Metrics{md}, 12, 0))
+	assert.Len(t, recorder.failures, 3)
+}
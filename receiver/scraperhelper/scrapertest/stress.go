@@ -0,0 +1,205 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+const (
+	// defaultStressIterations is the number of receivers StressReceiver runs
+	// by default.
+	defaultStressIterations = 100
+	// stressWorkers is the number of goroutines running operations on each
+	// receiver, concurrently with its lifecycle.
+	stressWorkers = 4
+	// maxStressOps is the maximum number of operations run by each worker.
+	maxStressOps = 8
+	// maxStressRestarts is the maximum number of times each receiver is
+	// started and shut down.
+	maxStressRestarts = 3
+)
+
+// CreateControllerReceiver creates the receivers run by StressReceiver,
+// passing the metrics they receive to the next consumer.
+type CreateControllerReceiver func(nextConsumer consumer.MetricsConsumer) (component.MetricsReceiver, error)
+
+// StressOp is an operation StressReceiver runs on a receiver, concurrently
+// with the lifecycle of the receiver and with the other operations. The
+// operation may fail, as it may legitimately do so depending on when it runs.
+// Operations assert the receiver to the interfaces they need, such as
+// scraperhelper.ScraperManager.
+type StressOp func(ctx context.Context, receiver component.MetricsReceiver, rnd *rand.Rand)
+
+// StressOption changes how StressReceiver runs.
+type StressOption func(*stressSettings)
+
+type stressSettings struct {
+	iterations int
+	seed       int64
+	ops        []StressOp
+}
+
+// WithStressIterations sets the number of receivers StressReceiver runs,
+// each with its own random interleaving. The default is 100.
+func WithStressIterations(iterations int) StressOption {
+	return func(s *stressSettings) {
+		s.iterations = iterations
+	}
+}
+
+// WithStressSeed sets the seed of the random interleavings, so that a failed
+// run can be reproduced. By default, the seed is derived from the current
+// time, and logged.
+func WithStressSeed(seed int64) StressOption {
+	return func(s *stressSettings) {
+		s.seed = seed
+	}
+}
+
+// WithStressOps adds operations to the ones StressReceiver runs, such as
+// configuration updates, which depend on the scrapers of the receiver.
+func WithStressOps(ops ...StressOp) StressOption {
+	return func(s *stressSettings) {
+		s.ops = append(s.ops, ops...)
+	}
+}
+
+// StressReceiver runs random interleavings of the lifecycle of new receivers
+// created with create, started and shut down up to three times, with
+// concurrent reads of their state, additions and removals of scrapers, and
+// the operations added with WithStressOps. Each receiver is checked like in
+// VerifyReceiverLifecycle: the next consumer is only called while the
+// receiver runs, Shutdown succeeds, no fatal error is reported, and no
+// goroutine is leaked.
+//
+// StressReceiver is meant to be run with the race detector, and receivers
+// should be created with a short collection interval, so that scrapes
+// interleave with the other operations.
+func StressReceiver(t *testing.T, create CreateControllerReceiver, options ...StressOption) {
+	t.Helper()
+	settings := stressSettings{
+		iterations: defaultStressIterations,
+		seed:       time.Now().UnixNano(),
+		ops:        []StressOp{readReceiverState, addAndRemoveScraper},
+	}
+	for _, option := range options {
+		option(&settings)
+	}
+	t.Logf("stress seed: %d", settings.seed)
+
+	rnd := rand.New(rand.NewSource(settings.seed))
+	for i := 0; i < settings.iterations && !t.Failed(); i++ {
+		runStressIteration(t, create, settings.ops, rnd.Int63())
+	}
+}
+
+// runStressIteration runs a random interleaving, derived from the seed, on a
+// new receiver.
+func runStressIteration(t *testing.T, create CreateControllerReceiver, ops []StressOp, seed int64) {
+	t.Helper()
+	var receiver component.MetricsReceiver
+	check := newLifecycleCheck(t, func(nextConsumer consumer.MetricsConsumer) (component.Receiver, error) {
+		r, err := create(nextConsumer)
+		receiver = r
+		return r, err
+	}, nil)
+	defer check.verify()
+
+	rnd := rand.New(rand.NewSource(seed))
+	var wg sync.WaitGroup
+	for w := 0; w < stressWorkers; w++ {
+		workerRnd := rand.New(rand.NewSource(rnd.Int63()))
+		n := 1 + workerRnd.Intn(maxStressOps)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				stressPause(workerRnd)
+				ops[workerRnd.Intn(len(ops))](context.Background(), receiver, workerRnd)
+			}
+		}()
+	}
+
+	restarts := 1 + rnd.Intn(maxStressRestarts)
+	for i := 0; i < restarts; i++ {
+		stressPause(rnd)
+		check.start()
+		stressPause(rnd)
+		check.shutdown(context.Background(), true)
+	}
+	wg.Wait()
+	// the operations may have run after the last shutdown.
+	check.shutdown(context.Background(), true)
+	if t.Failed() {
+		t.Logf("stress iteration seed: %d", seed)
+	}
+}
+
+// stressPause lets the other goroutines run for a random time, from not at
+// all to a millisecond.
+func stressPause(rnd *rand.Rand) {
+	switch rnd.Intn(3) {
+	case 0:
+	case 1:
+		runtime.Gosched()
+	default:
+		time.Sleep(time.Duration(rnd.Int63n(int64(time.Millisecond))))
+	}
+}
+
+// readReceiverState reads the state of the receiver.
+func readReceiverState(_ context.Context, receiver component.MetricsReceiver, _ *rand.Rand) {
+	if r, ok := receiver.(scraperhelper.StateReporter); ok {
+		r.State()
+		_, _ = r.Host()
+	}
+	if r, ok := receiver.(interface {
+		GetCapabilities() component.ProcessorCapabilities
+	}); ok {
+		r.GetCapabilities()
+	}
+	if r, ok := receiver.(scraperhelper.ScraperInspector); ok {
+		r.DisabledScrapers()
+		r.EffectiveConfig()
+		r.ScrapeCosts()
+	}
+}
+
+// addAndRemoveScraper adds a scraper to the receiver, or removes it, picking
+// its name from a small set, so that scrapers are added while others of the
+// same name are being removed.
+func addAndRemoveScraper(ctx context.Context, receiver component.MetricsReceiver, rnd *rand.Rand) {
+	manager, ok := receiver.(scraperhelper.ScraperManager)
+	if !ok {
+		return
+	}
+	name := fmt.Sprintf("stress%d", rnd.Intn(2))
+	if rnd.Intn(2) == 0 {
+		_ = manager.AddScraper(ctx, scraperhelper.NewMetricsScraper(name, NewScrapeMetrics(1, 1)))
+		return
+	}
+	_ = manager.RemoveScraper(ctx, scraperhelper.ScraperID{Receiver: manager.Name(), Scraper: name})
+}
@@ -0,0 +1,182 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+// TrackedScraper is a scraper wrapped by NewTrackedScraper or
+// NewTrackedResourceScraper, recording its initializations and closes.
+type TrackedScraper interface {
+	scraperhelper.BaseScraper
+	// Initializations returns the number of times the scraper was started
+	// successfully.
+	Initializations() int
+	// Closes returns the number of times the scraper was shut down.
+	Closes() int
+
+	tracker() *scraperTracker
+}
+
+// scraperTracker records the initializations and closes of a scraper, and the
+// ones that do not pair up.
+type scraperTracker struct {
+	scraper scraperhelper.BaseScraper
+
+	mu              sync.Mutex
+	initializations int
+	closes          int
+	initialized     bool
+	// closed is set once the scraper was closed after its last successful
+	// initialization.
+	closed bool
+	// unpaired describes the initializations and closes that did not pair
+	// up.
+	unpaired []string
+}
+
+// Name returns the name of the wrapped scraper.
+func (s *scraperTracker) Name() string {
+	return s.scraper.Name()
+}
+
+// Start starts the wrapped scraper, recording its initialization if it
+// succeeds.
+func (s *scraperTracker) Start(ctx context.Context, host component.Host) error {
+	err := s.scraper.Start(ctx, host)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.initialized {
+		s.unpaired = append(s.unpaired, fmt.Sprintf("scraper %q was initialized again without being closed", s.Name()))
+	}
+	s.initialized = err == nil
+	s.closed = false
+	if err == nil {
+		s.initializations++
+	}
+	return err
+}
+
+// Shutdown shuts the wrapped scraper down, recording its close.
+func (s *scraperTracker) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closes++
+	if s.closed {
+		s.unpaired = append(s.unpaired, fmt.Sprintf("scraper %q was closed twice", s.Name()))
+	}
+	s.closed = s.initialized || s.closed
+	s.initialized = false
+	s.mu.Unlock()
+
+	return s.scraper.Shutdown(ctx)
+}
+
+// Initializations returns the number of times the scraper was started
+// successfully.
+func (s *scraperTracker) Initializations() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.initializations
+}
+
+// Closes returns the number of times the scraper was shut down.
+func (s *scraperTracker) Closes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closes
+}
+
+func (s *scraperTracker) tracker() *scraperTracker {
+	return s
+}
+
+// TrackedMetricsScraper is a scraperhelper.MetricsScraper recording its
+// initializations and closes.
+type TrackedMetricsScraper struct {
+	*scraperTracker
+	scraper scraperhelper.MetricsScraper
+}
+
+var _ scraperhelper.MetricsScraper = (*TrackedMetricsScraper)(nil)
+
+// NewTrackedScraper wraps the scraper to record its initializations and
+// closes, so that VerifyAllClosed can check that the receiver closed it.
+//
+// The receiver only sees the wrapper, so the settings of the wrapped scraper
+// that the receiver applies to scrapers created by scraperhelper, such as
+// their collection interval and initial delay, are not applied: tracked
+// scrapers are meant for lifecycle tests.
+func NewTrackedScraper(scraper scraperhelper.MetricsScraper) *TrackedMetricsScraper {
+	return &TrackedMetricsScraper{scraperTracker: &scraperTracker{scraper: scraper}, scraper: scraper}
+}
+
+// Scrape scrapes the wrapped scraper.
+func (s *TrackedMetricsScraper) Scrape(ctx context.Context, receiverName string) (pdata.MetricSlice, error) {
+	return s.scraper.Scrape(ctx, receiverName)
+}
+
+// TrackedResourceMetricsScraper is a scraperhelper.ResourceMetricsScraper
+// recording its initializations and closes.
+type TrackedResourceMetricsScraper struct {
+	*scraperTracker
+	scraper scraperhelper.ResourceMetricsScraper
+}
+
+var _ scraperhelper.ResourceMetricsScraper = (*TrackedResourceMetricsScraper)(nil)
+
+// NewTrackedResourceScraper wraps the scraper to record its initializations
+// and closes, like NewTrackedScraper.
+func NewTrackedResourceScraper(scraper scraperhelper.ResourceMetricsScraper) *TrackedResourceMetricsScraper {
+	return &TrackedResourceMetricsScraper{scraperTracker: &scraperTracker{scraper: scraper}, scraper: scraper}
+}
+
+// Scrape scrapes the wrapped scraper.
+func (s *TrackedResourceMetricsScraper) Scrape(ctx context.Context, receiverName string) (pdata.ResourceMetricsSlice, error) {
+	return s.scraper.Scrape(ctx, receiverName)
+}
+
+// VerifyAllClosed checks that each scraper initialized successfully was
+// closed exactly once, and that none of them is still initialized. Closing a
+// scraper that is not initialized, because it was never started or failed to
+// start, is allowed, as receivers may be shut down without being started.
+func VerifyAllClosed(t testing.TB, scrapers ...TrackedScraper) bool {
+	t.Helper()
+	ok := true
+	for _, scraper := range scrapers {
+		s := scraper.tracker()
+		s.mu.Lock()
+		unpaired := append([]string(nil), s.unpaired...)
+		if s.initialized {
+			unpaired = append(unpaired, fmt.Sprintf("scraper %q was initialized but not closed", s.Name()))
+		}
+		s.mu.Unlock()
+
+		for _, msg := range unpaired {
+			ok = assert.Fail(t, msg) && ok
+		}
+	}
+	return ok
+}
@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+)
+
+// verifyAllClosedFailures returns the failures reported by VerifyAllClosed.
+func verifyAllClosedFailures(t *testing.T, scrapers ...TrackedScraper) []string {
+	recorder := &failureRecorder{TB: t}
+	ok := VerifyAllClosed(recorder, scrapers...)
+	assert.Equal(t, len(recorder.failures) == 0, ok)
+	return recorder.failures
+}
+
+func TestTrackedScraper(t *testing.T) {
+	var startErr error
+	start := func(context.Context, component.Host) error { return startErr }
+	newScraper := func(name string) *TrackedMetricsScraper {
+		return NewTrackedScraper(scraperhelper.NewMetricsScraper(name, NewScrapeMetrics(1, 1), scraperhelper.WithStart(start)))
+	}
+	host := componenttest.NewNopHost()
+
+	// a scraper started and closed twice, and a scraper never started but
+	// closed.
+	scraper1, scraper2 := newScraper("scraper1"), newScraper("scraper2")
+	for i := 0; i < 2; i++ {
+		require.NoError(t, scraper1.Start(context.Background(), host))
+		metrics, err := scraper1.Scrape(context.Background(), "receiver")
+		require.NoError(t, err)
+		assert.Equal(t, 1, metrics.Len())
+		require.NoError(t, scraper1.Shutdown(context.Background()))
+	}
+	require.NoError(t, scraper2.Shutdown(context.Background()))
+	assert.Equal(t, "scraper1", scraper1.Name())
+	assert.Equal(t, 2, scraper1.Initializations())
+	assert.Equal(t, 2, scraper1.Closes())
+	assert.Empty(t, verifyAllClosedFailures(t, scraper1, scraper2))
+
+	// a scraper failing to start, then closed.
+	startErr = errors.New("err1")
+	scraper3 := newScraper("scraper3")
+	assert.Error(t, scraper3.Start(context.Background(), host))
+	require.NoError(t, scraper3.Shutdown(context.Background()))
+	assert.Equal(t, 0, scraper3.Initializations())
+	assert.Empty(t, verifyAllClosedFailures(t, scraper3))
+	startErr = nil
+
+	scraper4, scraper5, scraper6 := newScraper("scraper4"), newScraper("scraper5"), newScraper("scraper6")
+	require.NoError(t, scraper4.Start(context.Background(), host))
+	require.NoError(t, scraper5.Start(context.Background(), host))
+	require.NoError(t, scraper5.Shutdown(context.Background()))
+	require.NoError(t, scraper5.Shutdown(context.Background()))
+	require.NoError(t, scraper6.Start(context.Background(), host))
+	require.NoError(t, scraper6.Start(context.Background(), host))
+	require.NoError(t, scraper6.Shutdown(context.Background()))
+	failures := verifyAllClosedFailures(t, scraper4, scraper5, scraper6)
+	require.Len(t, failures, 3)
+	assert.Contains(t, failures[0], `scraper "scraper4" was initialized but not closed`)
+	assert.Contains(t, failures[1], `scraper "scraper5" was closed twice`)
+	assert.Contains(t, failures[2], `scraper "scraper6" was initialized again without being closed`)
+}
+
+func TestTrackedResourceScraper(t *testing.T) {
+	scraper := NewTrackedResourceScraper(scraperhelper.NewResourceMetricsScraper("scraper", NewScrapeResourceMetrics(2, 1, 1)))
+	require.NoError(t, scraper.Start(context.Background(), componenttest.NewNopHost()))
+	resourceMetrics, err := scraper.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	assert.Equal(t, 2, resourceMetrics.Len())
+	assert.Contains(t, verifyAllClosedFailures(t, scraper)[0], `scraper "scraper" was initialized but not closed`)
+	require.NoError(t, scraper.Shutdown(context.Background()))
+	assert.Empty(t, verifyAllClosedFailures(t, scraper))
+}
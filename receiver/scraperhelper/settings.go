@@ -0,0 +1,84 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer"
+)
+
+// ReceiverCreateSettings are the settings a receiver is created with by its
+// factory, shared by the receiver and its scrapers.
+type ReceiverCreateSettings struct {
+	// Logger is used for the logs of the receiver. If nil, the logs are
+	// discarded.
+	Logger *zap.Logger
+
+	// ApplicationStartInfo describes the build of the collector the receiver
+	// runs in.
+	ApplicationStartInfo component.ApplicationStartInfo
+
+	// MetricsLevel is the level of the telemetry of the receiver. With
+	// configtelemetry.LevelNone, the scrapes and consumes of the receiver are
+	// not traced nor counted, as when obsreport is configured with it, unless
+	// their context carries a span already. Otherwise, the telemetry is
+	// recorded as configured with obsreport.
+	MetricsLevel configtelemetry.Level
+}
+
+// NewReceiverCreateSettings returns the settings of a receiver created by a
+// factory with the given parameters.
+func NewReceiverCreateSettings(params component.ReceiverCreateParams) ReceiverCreateSettings {
+	return ReceiverCreateSettings{
+		Logger:               params.Logger,
+		ApplicationStartInfo: params.ApplicationStartInfo,
+	}
+}
+
+// NewScraperControllerReceiverWithSettings creates a Receiver with the
+// configured options, like NewScraperControllerReceiver, using the logger,
+// build information and telemetry level of the settings. The settings are
+// passed to scrapers in StartInfo, and are available with
+// CreateSettingsFromContext from the context passed to their start, scrape
+// and shutdown functions.
+func NewScraperControllerReceiverWithSettings(
+	settings ReceiverCreateSettings,
+	cfg *ScraperControllerSettings,
+	nextConsumer consumer.MetricsConsumer,
+	options ...ScraperControllerOption,
+) (component.MetricsReceiver, error) {
+	return newScraperControllerReceiver(settings, cfg, nextConsumer, options)
+}
+
+type createSettingsContextKey struct{}
+
+// contextWithCreateSettings returns a copy of the context carrying the
+// settings.
+func contextWithCreateSettings(ctx context.Context, settings ReceiverCreateSettings) context.Context {
+	return context.WithValue(ctx, createSettingsContextKey{}, settings)
+}
+
+// CreateSettingsFromContext returns the settings the receiver was created
+// with, from the context passed to the start, scrape and shutdown functions
+// of its scrapers.
+func CreateSettingsFromContext(ctx context.Context) (ReceiverCreateSettings, bool) {
+	settings, ok := ctx.Value(createSettingsContextKey{}).(ReceiverCreateSettings)
+	return settings, ok
+}
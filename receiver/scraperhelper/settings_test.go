@@ -0,0 +1,130 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+func TestNewScraperControllerReceiverWithSettings(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	settings := ReceiverCreateSettings{
+		Logger:               zap.New(core),
+		ApplicationStartInfo: component.ApplicationStartInfo{ExeName: "otelcol", Version: "1.2.3"},
+	}
+
+	var started, scraped, closed ReceiverCreateSettings
+	start := func(ctx context.Context, _ component.Host, info StartInfo) error {
+		started = info.Settings
+		fromCtx, ok := CreateSettingsFromContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, info.Settings, fromCtx)
+		return nil
+	}
+	scrape := func(ctx context.Context) (pdata.MetricSlice, error) {
+		scraped, _ = CreateSettingsFromContext(ctx)
+		return pdata.NewMetricSlice(), errors.New("unavailable")
+	}
+	shutdown := func(ctx context.Context) error {
+		closed, _ = CreateSettingsFromContext(ctx)
+		return nil
+	}
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	cfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiverWithSettings(settings, &cfg, sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape, WithStartEx(start), WithShutdown(shutdown))),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	assert.Equal(t, settings, started)
+	assert.Equal(t, settings, scraped)
+	assert.Equal(t, settings, closed)
+	assert.Equal(t, 1, logs.FilterMessage("Error scraping metrics").Len())
+}
+
+func TestNewScraperControllerReceiverDefaultSettings(t *testing.T) {
+	logger := zap.NewNop()
+	var scraped ReceiverCreateSettings
+	scrape := func(ctx context.Context) (pdata.MetricSlice, error) {
+		scraped, _ = CreateSettingsFromContext(ctx)
+		return singleMetric(), nil
+	}
+	cfg := DefaultScraperControllerSettings("receiver")
+	require.NoError(t, ScrapeCycle(context.Background(), &cfg, logger, new(consumertest.MetricsSink), componenttest.NewNopHost(),
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape))))
+	assert.Equal(t, ReceiverCreateSettings{Logger: logger}, scraped)
+}
+
+func TestNewReceiverCreateSettings(t *testing.T) {
+	params := component.ReceiverCreateParams{
+		Logger:               zap.NewNop(),
+		ApplicationStartInfo: component.ApplicationStartInfo{ExeName: "otelcol", Version: "1.2.3"},
+	}
+	assert.Equal(t, ReceiverCreateSettings{Logger: params.Logger, ApplicationStartInfo: params.ApplicationStartInfo}, NewReceiverCreateSettings(params))
+}
+
+func TestReceiverCreateSettingsMetricsLevel(t *testing.T) {
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+	defer trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+	ss := &spanStore{}
+	trace.RegisterExporter(ss)
+	defer trace.UnregisterExporter(ss)
+
+	for _, level := range []configtelemetry.Level{configtelemetry.LevelNone, configtelemetry.LevelBasic} {
+		t.Run(level.String(), func(t *testing.T) {
+			tickerCh := make(chan time.Time)
+			sink := new(consumertest.MetricsSink)
+			scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+			cfg := DefaultScraperControllerSettings("receiver")
+			receiver, err := NewScraperControllerReceiverWithSettings(ReceiverCreateSettings{MetricsLevel: level}, &cfg, sink,
+				AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+				WithTickerChannel(tickerCh),
+			)
+			require.NoError(t, err)
+			require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, receiver.Shutdown(context.Background())) }()
+
+			tickerCh <- time.Now()
+			require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 1 }, time.Second, time.Millisecond)
+			if level == configtelemetry.LevelNone {
+				assert.Empty(t, ss.PullAllSpans())
+				return
+			}
+			assert.Equal(t, []string{"scraper/receiver/scraper/MetricsScraped", "receiver/receiver/MetricsReceived"}, spanNames(ss.PullAllSpans()))
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ShutdownBudget splits the time left before the deadline of the context
+// passed to Shutdown across the phases of the shutdown, in proportion to
+// their shares, so that a slow phase does not take the time of the phases
+// after it.
+type ShutdownBudget struct {
+	// Scrapes is the share of waiting for the scrapes in flight to complete,
+	// whose context is canceled once its budget is exhausted.
+	Scrapes float64
+	// Queue is the share of draining the queue of WithAsyncConsume, which is
+	// ignored without it.
+	Queue float64
+	// Close is the share of closing the scrapers, which calls their shutdown
+	// functions.
+	Close float64
+}
+
+// WithShutdownBudget bounds each of the phases of Shutdown, in order waiting
+// for the scrapes in flight, draining the queue of WithAsyncConsume and
+// closing the scrapers, to its share of the time left before the deadline of
+// the context passed to Shutdown. A phase done before its budget is exhausted
+// leaves the remaining time to the phases after it, and a phase that exhausts
+// its budget is given up on, counted as timed out in the error returned by
+// Shutdown, which matches ErrShutdownPhaseTimeout with errors.Is, and the
+// next phases still run. Without a deadline, the phases are not bounded.
+//
+// The shares must not be negative, and at least one of them must be
+// positive.
+func WithShutdownBudget(budget ShutdownBudget) ScraperControllerOption {
+	return func(o *controller) {
+		if budget.Scrapes < 0 || budget.Queue < 0 || budget.Close < 0 {
+			o.optionErrs = append(o.optionErrs, errors.New("shutdown budget shares must not be negative"))
+			return
+		}
+		if budget.Scrapes+budget.Queue+budget.Close <= 0 {
+			o.optionErrs = append(o.optionErrs, errors.New("shutdown budget must have a positive share"))
+			return
+		}
+		o.shutdownBudget = &budget
+	}
+}
+
+// shutdownPhase is one of the phases of Shutdown.
+type shutdownPhase struct {
+	name  string
+	share float64
+	run   func(ctx context.Context) error
+}
+
+// shutdownPhases returns the phases of Shutdown, in order.
+func (sc *controller) shutdownPhases() []shutdownPhase {
+	var budget ShutdownBudget
+	if sc.shutdownBudget != nil {
+		budget = *sc.shutdownBudget
+	}
+	phases := []shutdownPhase{{
+		name:  "scrapes",
+		share: budget.Scrapes,
+		run: func(ctx context.Context) error {
+			sc.stopScraping(ctx)
+			return nil
+		},
+	}}
+	if sc.queue != nil {
+		phases = append(phases, shutdownPhase{
+			name:  "queue",
+			share: budget.Queue,
+			run: func(ctx context.Context) error {
+				err := sc.queue.stop(ctx)
+				sc.queue = nil
+				return err
+			},
+		})
+	}
+	return append(phases, shutdownPhase{
+		name:  "close",
+		share: budget.Close,
+		run:   sc.closeScrapers,
+	})
+}
+
+// runShutdownPhases runs the phases in order, each with the context expiring
+// at the end of its budget if WithShutdownBudget is set and the context has
+// a deadline, and returns their errors, and the phases that timed out.
+func (sc *controller) runShutdownPhases(ctx context.Context, phases []shutdownPhase) []error {
+	deadline, hasDeadline := ctx.Deadline()
+	if sc.shutdownBudget == nil || !hasDeadline {
+		var errs []error
+		for _, phase := range phases {
+			if err := phase.run(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errs
+	}
+
+	var total float64
+	for _, phase := range phases {
+		total += phase.share
+	}
+	start := time.Now()
+	remaining := deadline.Sub(start)
+
+	var errs []error
+	var cumulative float64
+	for i, phase := range phases {
+		cumulative += phase.share
+		// the phases end at fixed points of the time remaining, so that the
+		// time left by a phase done early goes to the phases after it. The
+		// last phase ends at the deadline of the context.
+		phaseDeadline := deadline
+		if i < len(phases)-1 && total > 0 {
+			phaseDeadline = start.Add(time.Duration(float64(remaining) * cumulative / total))
+		}
+		phaseCtx, cancel := context.WithDeadline(ctx, phaseDeadline)
+		budget := phaseDeadline.Sub(time.Now())
+		if err := phase.run(phaseCtx); err != nil {
+			errs = append(errs, err)
+		}
+		if errors.Is(phaseCtx.Err(), context.DeadlineExceeded) {
+			if budget < 0 {
+				budget = 0
+			}
+			errs = append(errs, withKind(ErrShutdownPhaseTimeout, fmt.Errorf("shutdown phase %q exceeded its budget of %v", phase.name, budget)))
+		}
+		cancel()
+	}
+	return errs
+}
@@ -0,0 +1,188 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+// blockingScrape returns a scrape function that signals when it starts, then
+// blocks until its context is done.
+func blockingScrape(started chan<- struct{}) scraperhelper.ScrapeMetrics {
+	return func(ctx context.Context) (pdata.MetricSlice, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return pdata.NewMetricSlice(), ctx.Err()
+	}
+}
+
+// consumerFunc is a consumer.MetricsConsumer calling the function.
+type consumerFunc func(ctx context.Context, md pdata.Metrics) error
+
+func (f consumerFunc) ConsumeMetrics(ctx context.Context, md pdata.Metrics) error {
+	return f(ctx, md)
+}
+
+// shutdownRecorder records the deadline of the context the shutdown function
+// of a scraper is called with.
+type shutdownRecorder struct {
+	called   chan time.Time
+	deadline time.Time
+}
+
+func newShutdownRecorder() *shutdownRecorder {
+	return &shutdownRecorder{called: make(chan time.Time, 1)}
+}
+
+func (r *shutdownRecorder) shutdown(ctx context.Context) error {
+	r.deadline, _ = ctx.Deadline()
+	r.called <- time.Now()
+	return nil
+}
+
+func TestShutdownBudgetSlowScrapes(t *testing.T) {
+	started := make(chan struct{}, 1)
+	closed := newShutdownRecorder()
+	tickerCh := make(chan time.Time)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", blockingScrape(started), scraperhelper.WithShutdown(closed.shutdown))),
+		scraperhelper.WithShutdownBudget(scraperhelper.ShutdownBudget{Scrapes: 1, Close: 3}),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	begin := time.Now()
+	err = receiver.Shutdown(ctx)
+
+	// the scrape is canceled after a quarter of the time left, and the
+	// scrapers are still closed with the rest of it.
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, scraperhelper.ErrShutdownPhaseTimeout))
+	assert.Contains(t, err.Error(), `shutdown phase "scrapes" exceeded its budget of`)
+	assert.NotContains(t, err.Error(), `shutdown phase "close"`)
+	select {
+	case called := <-closed.called:
+		assert.True(t, called.Before(deadline))
+		assert.True(t, called.Sub(begin) >= 100*time.Millisecond, called.Sub(begin))
+	default:
+		t.Fatal("scraper not closed")
+	}
+	assert.Equal(t, deadline, closed.deadline)
+}
+
+func TestShutdownBudgetSlowQueue(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	consumed := make(chan struct{}, 1)
+	next := consumerFunc(func(ctx context.Context, _ pdata.Metrics) error {
+		consumed <- struct{}{}
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	closed := newShutdownRecorder()
+	tickerCh := make(chan time.Time)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), next,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1), scraperhelper.WithShutdown(closed.shutdown))),
+		scraperhelper.WithAsyncConsume(10, 1),
+		scraperhelper.WithShutdownBudget(scraperhelper.ShutdownBudget{Scrapes: 1, Queue: 1, Close: 1}),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	tickerCh <- time.Now()
+	<-consumed
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err = receiver.Shutdown(ctx)
+
+	// the payload being consumed is canceled once the queue exceeds its
+	// budget, before the deadline, and the scrapers are still closed.
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, scraperhelper.ErrConsumeQueueDrain))
+	assert.True(t, errors.Is(err, scraperhelper.ErrShutdownPhaseTimeout))
+	assert.Contains(t, err.Error(), `shutdown phase "queue" exceeded its budget of`)
+	assert.NotContains(t, err.Error(), `shutdown phase "scrapes"`)
+	require.Len(t, closed.called, 1)
+	assert.NoError(t, ctx.Err())
+}
+
+func TestShutdownBudgetWithoutDeadline(t *testing.T) {
+	closed := newShutdownRecorder()
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1), scraperhelper.WithShutdown(closed.shutdown))),
+		scraperhelper.WithShutdownBudget(scraperhelper.ShutdownBudget{Scrapes: 1}),
+		scraperhelper.WithTickerChannel(make(chan time.Time)),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	require.Len(t, closed.called, 1)
+	assert.True(t, closed.deadline.IsZero())
+}
+
+func TestShutdownBudgetInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		budget  scraperhelper.ShutdownBudget
+		wantErr string
+	}{
+		{
+			name:    "negative",
+			budget:  scraperhelper.ShutdownBudget{Scrapes: 1, Close: -1},
+			wantErr: "shutdown budget shares must not be negative",
+		},
+		{
+			name:    "zero",
+			wantErr: "shutdown budget must have a positive share",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+			_, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), new(scrapertest.SinkConsumer),
+				scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1))),
+				scraperhelper.WithShutdownBudget(tt.budget),
+			)
+			assert.EqualError(t, err, tt.wantErr)
+			assert.True(t, errors.Is(err, scraperhelper.ErrInvalidOption))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// singleScraper is the only scraper of a receiver, other than its streaming
+// scrapers, which the scrape loop scrapes directly rather than through the
+// structures used to select and merge the metrics of several scrapers. It
+// references the schedule of the scraper, so that it is not looked up on
+// every tick.
+type singleScraper struct {
+	scraper BaseScraper
+	// either metrics or resource is set.
+	metrics  MetricsScraper
+	resource ResourceMetricsScraper
+	schedule *scraperSchedule
+}
+
+// updateSingleScraper sets the single scraper of the receiver if it has
+// exactly one scraper other than its streaming scrapers, and does not scrape
+// them concurrently, or else clears it. It must be called with scrapersMu
+// held for writing whenever the scrapers or their configurations change,
+// once their schedules are updated.
+func (sc *controller) updateSingleScraper() {
+	sc.single = nil
+	if sc.noSingleScraper || sc.maxConcurrentScrapes > 1 || len(sc.metricsScrapers.scrapers)+len(sc.resourceMetricScrapers) != 1 {
+		return
+	}
+
+	s := &singleScraper{}
+	if len(sc.metricsScrapers.scrapers) == 1 {
+		s.metrics = sc.metricsScrapers.scrapers[0]
+		s.scraper = s.metrics
+	} else {
+		s.resource = sc.resourceMetricScrapers[0]
+		s.scraper = s.resource
+	}
+	s.schedule = sc.schedules[s.scraper.Name()]
+	sc.single = s
+}
+
+// scrapeSingle scrapes the single scraper of the receiver if it is due on the
+// given tick, and returns its metrics in a payload, or no payload if it is
+// not due and WithAsyncConsume is set, like scrapeAll. It must be called
+// with scrapersMu held.
+func (sc *controller) scrapeSingle(ctx context.Context, s *singleScraper, tick time.Time) []scrapedPayload {
+	due := sc.dueOn(s.scraper, s.schedule, tick)
+	if !due && sc.asyncConsume {
+		return nil
+	}
+
+	payload := scrapedPayload{metrics: sc.newMetrics(), metadata: consumeMetadata(s.scraper)}
+	if sc.asyncConsume {
+		payload.scraper = s.scraper.Name()
+	}
+	if due {
+		if s.resource != nil {
+			sc.scrapeResourceMetrics(ctx, s.resource, payload.metrics)
+		} else {
+			sc.scrapeMetrics(ctx, s.metrics, payload.metrics)
+		}
+		payload.withheld = withheld(s.scraper)
+		payload.firstScrapes = sc.appendFirstScrape(nil, s.scraper)
+	}
+	payload.dryRun = dryRun(s.scraper)
+	sc.payloads = append(sc.payloads[:0], payload)
+	return sc.payloads
+}
+
+// scrapeMetrics appends the metrics scraped by the scraper to metrics, in the
+// same resources as multiMetricScraper would, unless the scrape failed
+// without a partial result.
+func (sc *controller) scrapeMetrics(ctx context.Context, ms MetricsScraper, metrics pdata.Metrics) {
+	scraped, err := ms.Scrape(ctx, sc.name)
+	if err != nil {
+		sc.logger.Error("Error scraping metrics", zap.Error(err))
+
+		if !consumererror.IsPartialScrapeError(err) {
+			return
+		}
+	}
+	rms := metrics.ResourceMetrics()
+	rms.Resize(rms.Len() + 1)
+	rms.At(rms.Len() - 1).InstrumentationLibraryMetrics().Resize(1)
+	appendScraperMetrics(ms, scraped, rms.At(rms.Len()-1).InstrumentationLibraryMetrics().At(0).Metrics(), rms)
+}
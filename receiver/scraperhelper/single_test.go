@@ -0,0 +1,253 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// withoutSingleScraper scrapes a single scraper like several scrapers.
+func withoutSingleScraper() ScraperControllerOption {
+	return func(o *controller) {
+		o.noSingleScraper = true
+	}
+}
+
+// countedMetrics returns a gauge named after the scrape.
+func countedMetrics(scrape int) pdata.MetricSlice {
+	metrics := singleMetric()
+	metrics.At(0).SetName(fmt.Sprintf("scrape%d", scrape))
+	return metrics
+}
+
+// scrapeWithErrors returns a scrape function whose scrapes fail on every
+// third scrape, and partially fail on every fifth scrape.
+func scrapeWithErrors() ScrapeMetrics {
+	var scrape int
+	return func(context.Context) (pdata.MetricSlice, error) {
+		scrape++
+		switch {
+		case scrape%3 == 0:
+			return pdata.NewMetricSlice(), errors.New("err1")
+		case scrape%5 == 0:
+			return countedMetrics(scrape), consumererror.NewPartialScrapeError(errors.New("err2"), 1)
+		}
+		return countedMetrics(scrape), nil
+	}
+}
+
+type singleScraperScenario struct {
+	name    string
+	options func() []ScraperControllerOption
+}
+
+var singleScraperScenarios = []singleScraperScenario{
+	{
+		name: "metrics scraper",
+		options: func() []ScraperControllerOption {
+			return []ScraperControllerOption{AddMetricsScraper(NewMetricsScraper("scraper", scrapeWithErrors()))}
+		},
+	},
+	{
+		name: "resource metrics scraper",
+		options: func() []ScraperControllerOption {
+			var scrape int
+			return []ScraperControllerOption{AddResourceMetricsScraper(NewResourceMetricsScraper("scraper", func(context.Context) (pdata.ResourceMetricsSlice, error) {
+				scrape++
+				if scrape%3 == 0 {
+					return pdata.NewResourceMetricsSlice(), errors.New("err1")
+				}
+				return singleResourceMetric(), nil
+			}))}
+		},
+	},
+	{
+		name: "scraper resource attributes",
+		options: func() []ScraperControllerOption {
+			cfg := &testScraperConfig{ScraperSettings: ScraperSettings{ResourceAttributesVal: map[string]string{"host": "a"}}}
+			return []ScraperControllerOption{AddMetricsScraper(NewMetricsScraper("scraper", scrapeWithErrors(), WithConfig(cfg)))}
+		},
+	},
+	{
+		name: "collection interval and initial delay",
+		options: func() []ScraperControllerOption {
+			cfg := &testScraperConfig{ScraperSettings: ScraperSettings{CollectionIntervalVal: 3 * time.Minute, InitialDelayVal: 2 * time.Minute}}
+			return []ScraperControllerOption{AddMetricsScraper(NewMetricsScraper("scraper", scrapeWithErrors(), WithConfig(cfg)))}
+		},
+	},
+	{
+		name: "failure policy",
+		options: func() []ScraperControllerOption {
+			policy := FailurePolicy{Mode: FailureBackoff, InitialBackoff: 2 * time.Minute, MaxBackoff: 4 * time.Minute}
+			return []ScraperControllerOption{AddMetricsScraper(NewMetricsScraper("scraper", scrapeWithErrors(), WithFailurePolicy(policy)))}
+		},
+	},
+	{
+		name: "forward every",
+		options: func() []ScraperControllerOption {
+			return []ScraperControllerOption{AddMetricsScraper(NewMetricsScraper("scraper", scrapeWithErrors(), WithForwardEvery(2)))}
+		},
+	},
+	{
+		name: "async consume",
+		options: func() []ScraperControllerOption {
+			cfg := &testScraperConfig{ScraperSettings: ScraperSettings{CollectionIntervalVal: 2 * time.Minute}}
+			return []ScraperControllerOption{
+				AddMetricsScraper(NewMetricsScraper("scraper", scrapeWithErrors(), WithConfig(cfg))),
+				WithAsyncConsume(20, 1),
+			}
+		},
+	},
+	{
+		name: "streaming scraper",
+		options: func() []ScraperControllerOption {
+			stream := func(_ context.Context, emit EmitMetrics) error {
+				return emit(metricsChunk("stream"))
+			}
+			return []ScraperControllerOption{
+				AddMetricsScraper(NewMetricsScraper("scraper", scrapeWithErrors())),
+				AddStreamingScraper(NewStreamingScraper("stream", stream)),
+			}
+		},
+	},
+}
+
+// runSingleScraperScenario sends the given number of ticks, one minute apart,
+// to a receiver with the options of the scenario, and returns the consumed
+// metrics and the logged messages.
+func runSingleScraperScenario(t *testing.T, scenario singleScraperScenario, ticks int, options ...ScraperControllerOption) ([]pdata.Metrics, []string) {
+	core, logs := observer.New(zap.DebugLevel)
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.New(core), sink,
+		append(append(scenario.options(), WithTickerChannel(tickerCh)), options...)...)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	start := time.Now()
+	for tick := 1; tick <= ticks; tick++ {
+		tickerCh <- start.Add(time.Duration(tick) * time.Minute)
+	}
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, fmt.Sprintf("%s %v", entry.Message, entry.ContextMap()))
+	}
+	return sink.AllMetrics(), messages
+}
+
+func TestSingleScraperMatchesMultipleScrapers(t *testing.T) {
+	for _, scenario := range singleScraperScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			metrics, logs := runSingleScraperScenario(t, scenario, 20)
+			expectedMetrics, expectedLogs := runSingleScraperScenario(t, scenario, 20, withoutSingleScraper())
+			require.NotEmpty(t, expectedMetrics)
+			assert.Equal(t, expectedMetrics, metrics)
+			assert.Equal(t, expectedLogs, logs)
+		})
+	}
+}
+
+func TestSingleScraperUpdates(t *testing.T) {
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	cfg := &testScraperConfig{ScraperSettings: ScraperSettings{CollectionIntervalVal: 3 * time.Minute}}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), sink,
+		AddMetricsScraper(NewMetricsScraper("first", scrape, WithConfig(cfg))),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	sc := receiver.(*controller)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	single := func() BaseScraper {
+		sc.scrapersMu.RLock()
+		defer sc.scrapersMu.RUnlock()
+		if sc.single == nil {
+			return nil
+		}
+		return sc.single.scraper
+	}
+	require.NotNil(t, single())
+	assert.Equal(t, "first", single().Name())
+
+	start := time.Now()
+	tick := func(n int) {
+		tickerCh <- start.Add(time.Duration(n) * time.Minute)
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) == n+1 }, time.Second, time.Millisecond)
+	}
+	tick(0)
+	// the tick the single scraper was last scraped on is kept when it is no
+	// longer the single scraper, and the other way around.
+	require.NoError(t, receiver.(ScraperManager).AddScraper(context.Background(), NewMetricsScraper("second", scrape)))
+	assert.Nil(t, single())
+	tick(1)
+	require.NoError(t, receiver.(ScraperManager).RemoveScraper(context.Background(), ScraperID{Receiver: "receiver", Scraper: "second"}))
+	assert.Equal(t, "first", single().Name())
+	tick(2)
+	tick(3)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	// first is scraped on the first and last ticks, and second on the
+	// second tick.
+	var scraped []int
+	for _, md := range sink.AllMetrics() {
+		scraped = append(scraped, md.MetricCount())
+	}
+	assert.Equal(t, []int{1, 1, 0, 1}, scraped)
+
+	// scrapers scraped concurrently are never scraped directly.
+	sc, _ = newSteadyStateReceiver(t, 1, WithMaxConcurrentScrapes(2))
+	assert.Nil(t, sc.single)
+	require.NoError(t, sc.Shutdown(context.Background()))
+}
+
+func BenchmarkSingleScraperFastPath(b *testing.B) {
+	run := func(b *testing.B, options ...ScraperControllerOption) {
+		sc, ctx := newSteadyStateReceiver(b, 1, options...)
+		defer func() { require.NoError(b, sc.Shutdown(context.Background())) }()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			sc.scrapeMetricsAndReport(ctx, time.Now())
+		}
+	}
+
+	b.Run("single", func(b *testing.B) {
+		run(b)
+	})
+	b.Run("multiple", func(b *testing.B) {
+		run(b, withoutSingleScraper())
+	})
+}
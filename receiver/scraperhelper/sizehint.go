@@ -0,0 +1,171 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// SizeHint is the cardinality of the recent scrapes of a scraper, which its
+// scrape function can use to pre-allocate the metrics it scrapes.
+type SizeHint struct {
+	// ResourceMetrics is the number of scraped resource metrics.
+	ResourceMetrics int
+	// Metrics is the number of scraped metrics.
+	Metrics int
+	// DataPoints is the number of scraped data points.
+	DataPoints int
+}
+
+// WithSizeHint tracks the cardinality of the successful scrapes of the
+// scraper, which is passed to its scrape function as a SizeHint in the
+// context. The hint follows increases of the cardinality immediately, and
+// decays by half of the difference on every scrape when it decreases, or is
+// reset when it drops below half of the hint, so that a single large scrape
+// does not keep the payloads oversized.
+func WithSizeHint() ScraperOption {
+	return func(s *ScraperComponentSettings) {
+		s.sizeHints = &sizeHintTracker{}
+	}
+}
+
+type sizeHintContextKey struct{}
+
+// SizeHintFromContext returns the SizeHint of the scraper, from the context
+// passed to its scrape function, if it was created with WithSizeHint and was
+// successfully scraped before.
+func SizeHintFromContext(ctx context.Context) (SizeHint, bool) {
+	t, ok := ctx.Value(sizeHintContextKey{}).(*sizeHintTracker)
+	if !ok {
+		return SizeHint{}, false
+	}
+	return t.get()
+}
+
+// sizeHintTracker tracks the SizeHint of a scraper. It is added to the
+// observability context of the scraper, which is cached across scrapes, so
+// that passing the hint does not allocate on every scrape.
+type sizeHintTracker struct {
+	mu   sync.Mutex
+	hint SizeHint
+	set  bool
+}
+
+// contextWithSizeHints adds the tracker to the context, if any.
+func contextWithSizeHints(ctx context.Context, t *sizeHintTracker) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sizeHintContextKey{}, t)
+}
+
+func (t *sizeHintTracker) get() (SizeHint, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.hint, t.set
+}
+
+// reset forgets the hint, when the scraper is restarted.
+func (t *sizeHintTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hint, t.set = SizeHint{}, false
+}
+
+// record updates the hint with the cardinality of a successful scrape.
+func (t *sizeHintTracker) record(scraped SizeHint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.set {
+		t.hint, t.set = scraped, true
+		return
+	}
+	t.hint = SizeHint{
+		ResourceMetrics: decayHint(t.hint.ResourceMetrics, scraped.ResourceMetrics),
+		Metrics:         decayHint(t.hint.Metrics, scraped.Metrics),
+		DataPoints:      decayHint(t.hint.DataPoints, scraped.DataPoints),
+	}
+}
+
+func decayHint(hint, scraped int) int {
+	if scraped >= hint || scraped < hint/2 {
+		return scraped
+	}
+	return scraped + (hint-scraped)/2
+}
+
+// metricsSizeHint returns the cardinality of the scraped metrics.
+func metricsSizeHint(metrics pdata.MetricSlice) SizeHint {
+	hint := SizeHint{Metrics: metrics.Len()}
+	for i := 0; i < metrics.Len(); i++ {
+		hint.DataPoints += dataPointCount(metrics.At(i))
+	}
+	return hint
+}
+
+// resourceMetricsSizeHint returns the cardinality of the scraped resource
+// metrics.
+func resourceMetricsSizeHint(rms pdata.ResourceMetricsSlice) SizeHint {
+	hint := SizeHint{ResourceMetrics: rms.Len()}
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			h := metricsSizeHint(ilms.At(j).Metrics())
+			hint.Metrics += h.Metrics
+			hint.DataPoints += h.DataPoints
+		}
+	}
+	return hint
+}
+
+// MetricSliceBuilder builds a MetricSlice of a number of metrics that is not
+// known in advance, allocating the metrics in blocks instead of one by one.
+type MetricSliceBuilder struct {
+	metrics pdata.MetricSlice
+	len     int
+}
+
+// NewMetricSliceBuilder returns a MetricSliceBuilder with the number of
+// metrics of the hint pre-allocated.
+func NewMetricSliceBuilder(hint SizeHint) *MetricSliceBuilder {
+	b := &MetricSliceBuilder{metrics: pdata.NewMetricSlice()}
+	b.metrics.Resize(hint.Metrics)
+	return b
+}
+
+// AppendEmpty returns the next empty metric of the slice, doubling the number
+// of allocated metrics if they are all used.
+func (b *MetricSliceBuilder) AppendEmpty() pdata.Metric {
+	if b.len == b.metrics.Len() {
+		size := 2 * b.len
+		if size == 0 {
+			size = 1
+		}
+		b.metrics.Resize(size)
+	}
+	metric := b.metrics.At(b.len)
+	b.len++
+	return metric
+}
+
+// MetricSlice returns the built metrics, without the allocated metrics that
+// were not used.
+func (b *MetricSliceBuilder) MetricSlice() pdata.MetricSlice {
+	b.metrics.Resize(b.len)
+	return b.metrics
+}
@@ -0,0 +1,156 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// gaugesWithPoints returns the given number of int gauges with two data
+// points each.
+func gaugesWithPoints(count int) pdata.MetricSlice {
+	metrics := pdata.NewMetricSlice()
+	metrics.Resize(count)
+	for i := 0; i < count; i++ {
+		metrics.At(i).SetDataType(pdata.MetricDataTypeIntGauge)
+		metrics.At(i).IntGauge().DataPoints().Resize(2)
+	}
+	return metrics
+}
+
+func TestSizeHint(t *testing.T) {
+	counts := []int{100, 120, 80, 30, 40}
+	var hints []*SizeHint
+	scrape := func(ctx context.Context) (pdata.MetricSlice, error) {
+		if hint, ok := SizeHintFromContext(ctx); ok {
+			hints = append(hints, &hint)
+		} else {
+			hints = append(hints, nil)
+		}
+		return gaugesWithPoints(counts[len(hints)-1]), nil
+	}
+	ms := NewMetricsScraper("scraper", scrape, WithSizeHint())
+	require.NoError(t, ms.Start(context.Background(), componenttest.NewNopHost()))
+	for range counts {
+		_, err := ms.Scrape(context.Background(), "receiver")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []*SizeHint{
+		nil,
+		{Metrics: 100, DataPoints: 200},
+		// increases are followed immediately.
+		{Metrics: 120, DataPoints: 240},
+		// decreases decay by half of the difference.
+		{Metrics: 100, DataPoints: 200},
+		// drops below half of the hint reset it.
+		{Metrics: 30, DataPoints: 60},
+	}, hints)
+
+	// restarting the scraper forgets the hint.
+	require.NoError(t, ms.Start(context.Background(), componenttest.NewNopHost()))
+	counts, hints = []int{1}, nil
+	_, err := ms.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	assert.Equal(t, []*SizeHint{nil}, hints)
+}
+
+func TestSizeHintResourceMetrics(t *testing.T) {
+	var hint SizeHint
+	var ok bool
+	scrape := func(ctx context.Context) (pdata.ResourceMetricsSlice, error) {
+		hint, ok = SizeHintFromContext(ctx)
+		rms := pdata.NewResourceMetricsSlice()
+		rms.Resize(2)
+		for i := 0; i < rms.Len(); i++ {
+			rms.At(i).InstrumentationLibraryMetrics().Resize(1)
+			gaugesWithPoints(3).MoveAndAppendTo(rms.At(i).InstrumentationLibraryMetrics().At(0).Metrics())
+		}
+		return rms, nil
+	}
+	rms := NewResourceMetricsScraper("scraper", scrape, WithSizeHint())
+	require.NoError(t, rms.Start(context.Background(), componenttest.NewNopHost()))
+	for i := 0; i < 2; i++ {
+		_, err := rms.Scrape(context.Background(), "receiver")
+		require.NoError(t, err)
+	}
+	require.True(t, ok)
+	assert.Equal(t, SizeHint{ResourceMetrics: 2, Metrics: 6, DataPoints: 12}, hint)
+}
+
+func TestSizeHintNotSet(t *testing.T) {
+	var ok bool
+	scrape := func(ctx context.Context) (pdata.MetricSlice, error) {
+		_, ok = SizeHintFromContext(ctx)
+		return singleMetric(), nil
+	}
+	ms := NewMetricsScraper("scraper", scrape)
+	for i := 0; i < 2; i++ {
+		_, err := ms.Scrape(context.Background(), "receiver")
+		require.NoError(t, err)
+	}
+	assert.False(t, ok)
+}
+
+func TestMetricSliceBuilder(t *testing.T) {
+	for _, hint := range []SizeHint{{}, {Metrics: 2}, {Metrics: 10}} {
+		b := NewMetricSliceBuilder(hint)
+		for i := 0; i < 5; i++ {
+			b.AppendEmpty().SetName(strconv.Itoa(i))
+		}
+		metrics := b.MetricSlice()
+		require.Equal(t, 5, metrics.Len(), "hint %v", hint)
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, strconv.Itoa(i), metrics.At(i).Name())
+		}
+	}
+}
+
+func BenchmarkSizeHint(b *testing.B) {
+	// a scraper of 10,000 data points, that does not know their number in
+	// advance.
+	scrape := func(ctx context.Context) (pdata.MetricSlice, error) {
+		hint, _ := SizeHintFromContext(ctx)
+		builder := NewMetricSliceBuilder(hint)
+		for i := 0; i < 10000; i++ {
+			metric := builder.AppendEmpty()
+			metric.SetDataType(pdata.MetricDataTypeIntGauge)
+			metric.IntGauge().DataPoints().Resize(1)
+		}
+		return builder.MetricSlice(), nil
+	}
+
+	run := func(b *testing.B, options ...ScraperOption) {
+		ms := NewMetricsScraper("scraper", scrape, options...)
+		require.NoError(b, ms.Start(context.Background(), componenttest.NewNopHost()))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := ms.Scrape(context.Background(), "receiver")
+			require.NoError(b, err)
+		}
+	}
+
+	b.Run("without", func(b *testing.B) { run(b) })
+	b.Run("with", func(b *testing.B) { run(b, WithSizeHint()) })
+}
@@ -0,0 +1,226 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// StaleNaN is the value of the data points emitted to mark a series as
+// stale. It is the staleness marker used by Prometheus, as the data model
+// does not have a flag to mark data points without a recorded value.
+var StaleNaN = math.Float64frombits(0x7ff0000000000002)
+
+// staleSeries describes a series that was reported on the previous scrape,
+// with enough information to emit a staleness marker for it.
+type staleSeries struct {
+	resourceID  string
+	name        string
+	description string
+	unit        string
+	dataType    pdata.MetricDataType
+	temporality pdata.AggregationTemporality
+	monotonic   bool
+	labels      map[string]string
+}
+
+// stalenessTracker remembers the series reported on the previous successful
+// scrape, and emits a staleness marker for each of them that is missing from
+// the current scrape. Only DoubleGauge and DoubleSum series are tracked, as
+// they are the only ones that can carry the StaleNaN value. At most maxSeries
+// series are tracked, the series past it on a scrape are not.
+type stalenessTracker struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	maxSeries int
+	previous  map[string]*staleSeries
+	resources map[string]pdata.Resource
+	keys      seriesKeys
+}
+
+func newStalenessTracker() *stalenessTracker {
+	return &stalenessTracker{now: time.Now, maxSeries: defaultMaxTrackedSeries}
+}
+
+// setClock sets the source of the timestamps of the staleness markers.
+func (t *stalenessTracker) setClock(now func() time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.now = now
+}
+
+// setMaxSeries sets the maximum number of series tracked.
+func (t *stalenessTracker) setMaxSeries(maxSeries int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.maxSeries = maxSeries
+}
+
+// reset forgets the series reported on the previous scrape.
+func (t *stalenessTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.previous = nil
+	t.resources = nil
+}
+
+// markMetrics appends staleness markers to the metrics for the series that
+// were reported on the previous scrape, but not in the given metrics, and
+// returns the number of series not tracked past the maximum number of series.
+func (t *stalenessTracker) markMetrics(metrics pdata.MetricSlice) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := make(map[string]*staleSeries, len(t.previous))
+	untracked := t.collect(current, "", metrics)
+	now := t.now()
+	for _, series := range t.missing(current) {
+		appendMarker(metrics, series, now)
+	}
+	t.previous = current
+	return untracked
+}
+
+// markResourceMetrics appends staleness markers to the resource metrics for
+// the series that were reported on the previous scrape, but not in the given
+// resource metrics, and returns the number of series not tracked past the
+// maximum number of series.
+func (t *stalenessTracker) markResourceMetrics(rms pdata.ResourceMetricsSlice) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	untracked := 0
+	current := make(map[string]*staleSeries, len(t.previous))
+	resources := make(map[string]pdata.Resource, len(t.resources))
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		id := resourceID(rm.Resource())
+		if _, ok := resources[id]; !ok {
+			resource := pdata.NewResource()
+			rm.Resource().CopyTo(resource)
+			resources[id] = resource
+		}
+		ilms := rm.InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			untracked += t.collect(current, id, ilms.At(j).Metrics())
+		}
+	}
+
+	markers := map[string]pdata.MetricSlice{}
+	now := t.now()
+	for _, series := range t.missing(current) {
+		metrics, ok := markers[series.resourceID]
+		if !ok {
+			rms.Resize(rms.Len() + 1)
+			rm := rms.At(rms.Len() - 1)
+			t.resources[series.resourceID].CopyTo(rm.Resource())
+			rm.InstrumentationLibraryMetrics().Resize(1)
+			metrics = rm.InstrumentationLibraryMetrics().At(0).Metrics()
+			markers[series.resourceID] = metrics
+		}
+		appendMarker(metrics, series, now)
+	}
+	t.previous = current
+	t.resources = resources
+	return untracked
+}
+
+// collect adds the series of the metrics to current, up to the maximum
+// number of series, and returns the number of series it did not add.
+func (t *stalenessTracker) collect(current map[string]*staleSeries, resourceID string, metrics pdata.MetricSlice) int {
+	untracked := 0
+	for i := 0; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		var dps pdata.DoubleDataPointSlice
+		series := staleSeries{
+			resourceID:  resourceID,
+			name:        metric.Name(),
+			description: metric.Description(),
+			unit:        metric.Unit(),
+			dataType:    metric.DataType(),
+		}
+		switch metric.DataType() {
+		case pdata.MetricDataTypeDoubleGauge:
+			dps = metric.DoubleGauge().DataPoints()
+		case pdata.MetricDataTypeDoubleSum:
+			dps = metric.DoubleSum().DataPoints()
+			series.temporality = metric.DoubleSum().AggregationTemporality()
+			series.monotonic = metric.DoubleSum().IsMonotonic()
+		default:
+			continue
+		}
+
+		for j := 0; j < dps.Len(); j++ {
+			labels := dps.At(j).LabelsMap()
+			key := t.keys.key(resourceID, series.name, labels)
+			if _, ok := current[key]; !ok && len(current) >= t.maxSeries {
+				untracked++
+				continue
+			}
+			if previous, ok := t.previous[key]; ok {
+				current[key] = previous
+				continue
+			}
+			s := series
+			s.labels = make(map[string]string, labels.Len())
+			labels.ForEach(func(k, v string) { s.labels[k] = v })
+			current[key] = &s
+		}
+	}
+	return untracked
+}
+
+// missing returns the series reported on the previous scrape that are not
+// in current.
+func (t *stalenessTracker) missing(current map[string]*staleSeries) []*staleSeries {
+	var missing []*staleSeries
+	for key, series := range t.previous {
+		if _, ok := current[key]; !ok {
+			missing = append(missing, series)
+		}
+	}
+	return missing
+}
+
+// appendMarker appends a metric with a single StaleNaN data point for the
+// series to metrics, timestamped with the given time.
+func appendMarker(metrics pdata.MetricSlice, series *staleSeries, now time.Time) {
+	metric := pdata.NewMetric()
+	metric.SetName(series.name)
+	metric.SetDescription(series.description)
+	metric.SetUnit(series.unit)
+	metric.SetDataType(series.dataType)
+
+	var dps pdata.DoubleDataPointSlice
+	switch series.dataType {
+	case pdata.MetricDataTypeDoubleGauge:
+		dps = metric.DoubleGauge().DataPoints()
+	case pdata.MetricDataTypeDoubleSum:
+		metric.DoubleSum().SetAggregationTemporality(series.temporality)
+		metric.DoubleSum().SetIsMonotonic(series.monotonic)
+		dps = metric.DoubleSum().DataPoints()
+	}
+	dps.Resize(1)
+	dp := dps.At(0)
+	dp.LabelsMap().InitFromMap(series.labels)
+	dp.SetTimestamp(pdata.TimestampUnixNano(now.UnixNano()))
+	dp.SetValue(StaleNaN)
+
+	metrics.Append(metric)
+}
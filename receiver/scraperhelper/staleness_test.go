@@ -0,0 +1,122 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// doubleGauge returns a gauge with a data point with value 1 for each of the
+// given devices.
+func doubleGauge(devices ...string) pdata.MetricSlice {
+	metrics := pdata.NewMetricSlice()
+	metrics.Resize(1)
+	metric := metrics.At(0)
+	metric.SetName("disk.usage")
+	metric.SetUnit("By")
+	metric.SetDataType(pdata.MetricDataTypeDoubleGauge)
+	dps := metric.DoubleGauge().DataPoints()
+	dps.Resize(len(devices))
+	for i, device := range devices {
+		dps.At(i).LabelsMap().Insert("device", device)
+		dps.At(i).SetValue(1)
+	}
+	return metrics
+}
+
+// deviceValues returns the values of the data points of the metrics by
+// device, with "stale" for staleness markers.
+func deviceValues(t *testing.T, metrics pdata.MetricSlice) map[string]string {
+	out := map[string]string{}
+	for i := 0; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		assert.Equal(t, "disk.usage", metric.Name())
+		assert.Equal(t, "By", metric.Unit())
+		dps := metric.DoubleGauge().DataPoints()
+		for j := 0; j < dps.Len(); j++ {
+			device, _ := dps.At(j).LabelsMap().Get("device")
+			if value := dps.At(j).Value(); math.Float64bits(value) == math.Float64bits(StaleNaN) {
+				out[device] = "stale"
+			} else {
+				out[device] = "value"
+			}
+		}
+	}
+	return out
+}
+
+func TestStalenessMarkers(t *testing.T) {
+	scrapes := [][]string{
+		{"sda"},
+		{"sda", "sdb"},
+		{"sda", "sdb"},
+		{"sda"},
+		{"sda"},
+	}
+	expected := []map[string]string{
+		{"sda": "value"},
+		{"sda": "value", "sdb": "value"},
+		{"sda": "value", "sdb": "value"},
+		{"sda": "value", "sdb": "stale"},
+		{"sda": "value"},
+	}
+
+	i := 0
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		metrics := doubleGauge(scrapes[i]...)
+		i++
+		return metrics, nil
+	}
+
+	scraper := NewMetricsScraper("scraper", scrape, WithStalenessMarkers())
+	require.NoError(t, scraper.Start(context.Background(), componenttest.NewNopHost()))
+	for _, want := range expected {
+		metrics, err := scraper.Scrape(context.Background(), "receiver")
+		require.NoError(t, err)
+		assert.Equal(t, want, deviceValues(t, metrics))
+	}
+}
+
+func TestStalenessMarkersResourceMetrics(t *testing.T) {
+	resourceMetrics := func(host string, devices ...string) pdata.ResourceMetricsSlice {
+		rms := pdata.NewResourceMetricsSlice()
+		rms.Resize(1)
+		rms.At(0).Resource().Attributes().InsertString("host.name", host)
+		rms.At(0).InstrumentationLibraryMetrics().Resize(1)
+		doubleGauge(devices...).MoveAndAppendTo(rms.At(0).InstrumentationLibraryMetrics().At(0).Metrics())
+		return rms
+	}
+
+	tracker := newStalenessTracker()
+	rms := resourceMetrics("host1", "sda")
+	resourceMetrics("host2", "sda").MoveAndAppendTo(rms)
+	tracker.markResourceMetrics(rms)
+	assert.Equal(t, 2, rms.Len())
+
+	rms = resourceMetrics("host1", "sda")
+	tracker.markResourceMetrics(rms)
+	require.Equal(t, 2, rms.Len())
+	host, _ := rms.At(1).Resource().Attributes().Get("host.name")
+	assert.Equal(t, "host2", host.StringVal())
+	assert.Equal(t, map[string]string{"sda": "stale"}, deviceValues(t, rms.At(1).InstrumentationLibraryMetrics().At(0).Metrics()))
+}
@@ -0,0 +1,203 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// cumulativeDataPoint is implemented by the data points that can have a
+// start timestamp.
+type cumulativeDataPoint interface {
+	LabelsMap() pdata.StringMap
+	StartTime() pdata.TimestampUnixNano
+	SetStartTime(pdata.TimestampUnixNano)
+	Timestamp() pdata.TimestampUnixNano
+}
+
+type startTimeEntry struct {
+	startTime pdata.TimestampUnixNano
+	lastSeen  uint64
+}
+
+// startTimeTracker records the time each cumulative series was first
+// observed, and uses it as the start timestamp of data points of that series
+// that do not have one. Series that are not seen for more than maxMissed
+// consecutive scrapes are forgotten, as are the least recently seen series
+// past maxSeries.
+type startTimeTracker struct {
+	maxMissed uint64
+
+	mu        sync.Mutex
+	now       func() time.Time
+	maxSeries int
+	scrapes   uint64
+	series    map[string]*startTimeEntry
+	keys      seriesKeys
+}
+
+func newStartTimeTracker(maxMissed int) *startTimeTracker {
+	if maxMissed < 0 {
+		maxMissed = 0
+	}
+	return &startTimeTracker{
+		maxMissed: uint64(maxMissed),
+		now:       time.Now,
+		maxSeries: defaultMaxTrackedSeries,
+		series:    map[string]*startTimeEntry{},
+	}
+}
+
+// setMaxSeries sets the maximum number of series retained between scrapes.
+func (t *startTimeTracker) setMaxSeries(maxSeries int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.maxSeries = maxSeries
+}
+
+// setClock sets the source of the start timestamps of the series without
+// timestamps.
+func (t *startTimeTracker) setClock(now func() time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.now = now
+}
+
+// reset forgets all the series, so that start timestamps are tracked anew.
+func (t *startTimeTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scrapes = 0
+	t.series = map[string]*startTimeEntry{}
+}
+
+// adjustResourceMetrics fills in the start timestamps of the cumulative data
+// points of the resource metrics scraped in a single scrape, and returns the
+// number of series evicted past the maximum number of series.
+func (t *startTimeTracker) adjustResourceMetrics(rms pdata.ResourceMetricsSlice) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.scrapes++
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		resourceKey := resourceID(rm.Resource())
+		ilms := rm.InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			t.adjust(resourceKey, ilms.At(j).Metrics())
+		}
+	}
+	return t.evict()
+}
+
+// adjustMetrics fills in the start timestamps of the cumulative data points
+// of the metrics scraped in a single scrape, and returns the number of series
+// evicted past the maximum number of series.
+func (t *startTimeTracker) adjustMetrics(metrics pdata.MetricSlice) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.scrapes++
+	t.adjust("", metrics)
+	return t.evict()
+}
+
+func (t *startTimeTracker) adjust(resourceKey string, metrics pdata.MetricSlice) {
+	for i := 0; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		switch metric.DataType() {
+		case pdata.MetricDataTypeIntSum:
+			if sum := metric.IntSum(); sum.AggregationTemporality() == pdata.AggregationTemporalityCumulative {
+				dps := sum.DataPoints()
+				for j := 0; j < dps.Len(); j++ {
+					t.adjustDataPoint(resourceKey, metric.Name(), dps.At(j))
+				}
+			}
+		case pdata.MetricDataTypeDoubleSum:
+			if sum := metric.DoubleSum(); sum.AggregationTemporality() == pdata.AggregationTemporalityCumulative {
+				dps := sum.DataPoints()
+				for j := 0; j < dps.Len(); j++ {
+					t.adjustDataPoint(resourceKey, metric.Name(), dps.At(j))
+				}
+			}
+		case pdata.MetricDataTypeIntHistogram:
+			if histogram := metric.IntHistogram(); histogram.AggregationTemporality() == pdata.AggregationTemporalityCumulative {
+				dps := histogram.DataPoints()
+				for j := 0; j < dps.Len(); j++ {
+					t.adjustDataPoint(resourceKey, metric.Name(), dps.At(j))
+				}
+			}
+		case pdata.MetricDataTypeDoubleHistogram:
+			if histogram := metric.DoubleHistogram(); histogram.AggregationTemporality() == pdata.AggregationTemporalityCumulative {
+				dps := histogram.DataPoints()
+				for j := 0; j < dps.Len(); j++ {
+					t.adjustDataPoint(resourceKey, metric.Name(), dps.At(j))
+				}
+			}
+		case pdata.MetricDataTypeDoubleSummary:
+			dps := metric.DoubleSummary().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				t.adjustDataPoint(resourceKey, metric.Name(), dps.At(j))
+			}
+		}
+	}
+}
+
+func (t *startTimeTracker) adjustDataPoint(resourceKey, name string, dp cumulativeDataPoint) {
+	key := t.keys.key(resourceKey, name, dp.LabelsMap())
+	entry, ok := t.series[key]
+	if !ok {
+		startTime := dp.StartTime()
+		if startTime == 0 {
+			startTime = dp.Timestamp()
+		}
+		if startTime == 0 {
+			startTime = pdata.TimestampUnixNano(t.now().UnixNano())
+		}
+		entry = &startTimeEntry{startTime: startTime}
+		t.series[key] = entry
+	}
+	entry.lastSeen = t.scrapes
+
+	if dp.StartTime() == 0 {
+		dp.SetStartTime(entry.startTime)
+	}
+}
+
+// evict forgets the series that have not been seen for more than maxMissed
+// scrapes, then the least recently seen series past maxSeries, and returns
+// the number of the latter.
+func (t *startTimeTracker) evict() int {
+	for key, entry := range t.series {
+		if t.scrapes-entry.lastSeen > t.maxMissed {
+			delete(t.series, key)
+		}
+	}
+	excess := len(t.series) - t.maxSeries
+	if excess <= 0 {
+		return 0
+	}
+	seen := make([]seenSeries, 0, len(t.series))
+	for key, entry := range t.series {
+		seen = append(seen, seenSeries{key: key, lastSeen: entry.lastSeen})
+	}
+	for _, series := range leastRecentlySeen(seen, excess) {
+		delete(t.series, series.key)
+	}
+	return excess
+}
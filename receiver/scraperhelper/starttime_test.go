@@ -0,0 +1,121 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// cumulativeSum returns a cumulative sum with a data point for each of the
+// given label values, at the given timestamp and without a start timestamp.
+func cumulativeSum(ts pdata.TimestampUnixNano, labelValues ...string) pdata.MetricSlice {
+	metrics := pdata.NewMetricSlice()
+	metrics.Resize(1)
+	metric := metrics.At(0)
+	metric.SetName("sum")
+	metric.SetDataType(pdata.MetricDataTypeIntSum)
+	metric.IntSum().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+	dps := metric.IntSum().DataPoints()
+	dps.Resize(len(labelValues))
+	for i, value := range labelValues {
+		dps.At(i).LabelsMap().Insert("label", value)
+		dps.At(i).SetTimestamp(ts)
+	}
+	return metrics
+}
+
+func startTimes(metrics pdata.MetricSlice) map[string]pdata.TimestampUnixNano {
+	out := map[string]pdata.TimestampUnixNano{}
+	dps := metrics.At(0).IntSum().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		value, _ := dps.At(i).LabelsMap().Get("label")
+		out[value] = dps.At(i).StartTime()
+	}
+	return out
+}
+
+func TestStartTimeTracking(t *testing.T) {
+	var ts pdata.TimestampUnixNano
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		ts += 10
+		return cumulativeSum(ts, "a", "b"), nil
+	}
+
+	scraper := NewMetricsScraper("scraper", scrape, WithStartTimeTracking(1))
+	require.NoError(t, scraper.Start(context.Background(), componenttest.NewNopHost()))
+	for i := 0; i < 3; i++ {
+		metrics, err := scraper.Scrape(context.Background(), "receiver")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]pdata.TimestampUnixNano{"a": 10, "b": 10}, startTimes(metrics))
+	}
+}
+
+func TestStartTimeTrackingEviction(t *testing.T) {
+	tracker := newStartTimeTracker(1)
+
+	tracker.adjustMetrics(cumulativeSum(10, "a", "b"))
+	// b is missed once, and is still remembered.
+	tracker.adjustMetrics(cumulativeSum(20, "a"))
+	metrics := cumulativeSum(30, "a", "b")
+	tracker.adjustMetrics(metrics)
+	assert.Equal(t, map[string]pdata.TimestampUnixNano{"a": 10, "b": 10}, startTimes(metrics))
+
+	// b is missed twice, and is forgotten.
+	tracker.adjustMetrics(cumulativeSum(40, "a"))
+	tracker.adjustMetrics(cumulativeSum(50, "a"))
+	assert.Len(t, tracker.series, 1)
+	metrics = cumulativeSum(60, "a", "b")
+	tracker.adjustMetrics(metrics)
+	assert.Equal(t, map[string]pdata.TimestampUnixNano{"a": 10, "b": 60}, startTimes(metrics))
+}
+
+func TestStartTimeTrackingKeepsExistingStartTime(t *testing.T) {
+	tracker := newStartTimeTracker(0)
+
+	metrics := cumulativeSum(10, "a")
+	metrics.At(0).IntSum().DataPoints().At(0).SetStartTime(5)
+	tracker.adjustMetrics(metrics)
+	assert.Equal(t, map[string]pdata.TimestampUnixNano{"a": 5}, startTimes(metrics))
+
+	metrics = cumulativeSum(20, "a")
+	tracker.adjustMetrics(metrics)
+	assert.Equal(t, map[string]pdata.TimestampUnixNano{"a": 5}, startTimes(metrics))
+}
+
+func TestStartTimeTrackingRestart(t *testing.T) {
+	var ts pdata.TimestampUnixNano
+	scrape := func(context.Context) (pdata.MetricSlice, error) {
+		ts += 10
+		return cumulativeSum(ts, "a"), nil
+	}
+
+	scraper := NewMetricsScraper("scraper", scrape, WithStartTimeTracking(1))
+	require.NoError(t, scraper.Start(context.Background(), componenttest.NewNopHost()))
+	_, err := scraper.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	require.NoError(t, scraper.Shutdown(context.Background()))
+
+	require.NoError(t, scraper.Start(context.Background(), componenttest.NewNopHost()))
+	metrics, err := scraper.Scrape(context.Background(), "receiver")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]pdata.TimestampUnixNano{"a": 20}, startTimes(metrics))
+}
@@ -0,0 +1,51 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+// State is the lifecycle state of a scraper controller receiver.
+type State int32
+
+const (
+	// StateCreated is the state of a receiver that has not yet been started.
+	StateCreated State = iota
+	// StateStarting is the state of a receiver that is initializing its
+	// scrapers.
+	StateStarting
+	// StateRunning is the state of a receiver that is scraping.
+	StateRunning
+	// StateStopping is the state of a receiver that is stopping scraping and
+	// closing its scrapers.
+	StateStopping
+	// StateStopped is the state of a receiver that has been shutdown, or
+	// that failed to start.
+	StateStopped
+)
+
+// String returns the string representation of the State.
+func (s State) String() string {
+	switch s {
+	case StateCreated:
+		return "Created"
+	case StateStarting:
+		return "Starting"
+	case StateRunning:
+		return "Running"
+	case StateStopping:
+		return "Stopping"
+	case StateStopped:
+		return "Stopped"
+	}
+	return "Unknown"
+}
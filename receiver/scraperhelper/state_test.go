@@ -0,0 +1,170 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+)
+
+func TestStateTransitions(t *testing.T) {
+	var transitions []State
+	var observed []State
+
+	var receiver component.MetricsReceiver
+	tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape)),
+		WithTickerChannel(make(chan time.Time)),
+		WithStateListener(func(state State) {
+			transitions = append(transitions, state)
+			// calling back into the receiver must not deadlock
+			observed = append(observed, receiver.(StateReporter).State())
+		}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, StateCreated, receiver.(StateReporter).State())
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	assert.Equal(t, StateRunning, receiver.(StateReporter).State())
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Equal(t, StateStopped, receiver.(StateReporter).State())
+
+	assert.Equal(t, []State{StateStarting, StateRunning, StateStopping, StateStopped}, transitions)
+	// the listeners are notified once Start and Shutdown are done.
+	assert.Equal(t, []State{StateRunning, StateRunning, StateStopped, StateStopped}, observed)
+}
+
+func TestStateListenerCallsBack(t *testing.T) {
+	factory := &testScraperFactory{}
+	var transitions []State
+	var receiver component.MetricsReceiver
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(factory.scraper(t, "scraper", pathConfig("/scraper", 0)).(MetricsScraper)),
+		WithScraperFactory(factory.create),
+		WithTickerChannel(make(chan time.Time)),
+		WithStateListener(func(state State) {
+			transitions = append(transitions, state)
+			if state != StateRunning {
+				return
+			}
+			// calling back into the receiver must not deadlock
+			assert.NoError(t, receiver.(ScraperManager).UpdateConfig(context.Background(), map[string]ScraperConfig{
+				"scraper": pathConfig("/scraper", 0),
+				"added":   pathConfig("/added", 0),
+			}))
+			assert.NoError(t, receiver.Shutdown(context.Background()))
+		}),
+	)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- receiver.Start(context.Background(), componenttest.NewNopHost())
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("state listener deadlocked")
+	}
+
+	assert.Equal(t, StateStopped, receiver.(StateReporter).State())
+	assert.Equal(t, []State{StateStarting, StateRunning, StateStopping, StateStopped}, transitions)
+	assert.Equal(t, []string{
+		"start scraper /scraper",
+		"start added /added",
+		"shutdown added /added",
+		"shutdown scraper /scraper",
+	}, factory.events())
+}
+
+func TestStateTransitionsFailedStart(t *testing.T) {
+	var transitions []State
+
+	tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+	ti := &testInitialize{ch: make(chan bool, 1), err: errors.New("err1")}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(
+		&defaultCfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper", tsm.scrape, WithStart(ti.start))),
+		WithTickerChannel(make(chan time.Time)),
+		WithStateListener(func(state State) { transitions = append(transitions, state) }),
+	)
+	require.NoError(t, err)
+
+	require.Error(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	assert.Equal(t, StateStopped, receiver.(StateReporter).State())
+	assert.Equal(t, []State{StateStarting, StateStopped}, transitions)
+}
+
+func TestStateString(t *testing.T) {
+	assert.Equal(t, "Created", StateCreated.String())
+	assert.Equal(t, "Starting", StateStarting.String())
+	assert.Equal(t, "Running", StateRunning.String())
+	assert.Equal(t, "Stopping", StateStopping.String())
+	assert.Equal(t, "Stopped", StateStopped.String())
+	assert.Equal(t, "Unknown", State(-1).String())
+}
+
+func TestReceiverNameAndString(t *testing.T) {
+	tsm := &testScrapeMetrics{ch: make(chan int, 1)}
+	cfg := DefaultScraperControllerSettings("receiver")
+	cfg.SetName("receiver/1")
+	receiver, err := NewScraperControllerReceiver(
+		&cfg,
+		zap.NewNop(),
+		new(consumertest.MetricsSink),
+		AddMetricsScraper(NewMetricsScraper("scraper1", tsm.scrape)),
+		AddMetricsScraper(NewMetricsScraper("scraper2", tsm.scrape)),
+		WithTickerChannel(make(chan time.Time)),
+	)
+	require.NoError(t, err)
+
+	// the name does not change if the settings are changed once the receiver
+	// is created.
+	cfg.SetName("receiver/2")
+	assert.Equal(t, "receiver/1", receiver.(ScraperManager).Name())
+	assert.Equal(t, `receiver "receiver/1" (2 scrapers, Created)`, receiver.(*controller).String())
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	assert.Equal(t, "receiver/1", receiver.(ScraperManager).Name())
+	assert.Equal(t, `receiver "receiver/1" (2 scrapers, Running)`, receiver.(*controller).String())
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Equal(t, "receiver/1", receiver.(ScraperManager).Name())
+	assert.Equal(t, `receiver "receiver/1" (2 scrapers, Stopped)`, receiver.(*controller).String())
+}
@@ -0,0 +1,241 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+)
+
+// ScraperStats are the statistics of the scrapes of a scraper created by this
+// package, since it was created. A scrape fails if it returns an error that
+// is not a partial scrape error.
+type ScraperStats struct {
+	// ID identifies the scraper.
+	ID ScraperID
+	// Scrapes is the number of scrapes, including the scrapes that failed.
+	Scrapes uint64
+	// Failures is the number of failed scrapes.
+	Failures uint64
+	// ConsecutiveFailures is the number of failed scrapes since the last
+	// scrape that did not fail.
+	ConsecutiveFailures uint64
+	// LastSuccess is when the last scrape that did not fail ended, or the
+	// zero time if every scrape failed.
+	LastSuccess time.Time
+	// LastDuration is the time the last scrape took.
+	LastDuration time.Duration
+}
+
+// StatsRegistry is implemented by extensions collecting the statistics of the
+// scrapers of receivers, for instance to expose them on a local endpoint
+// without the telemetry of the collector. Receivers created with
+// NewScraperControllerReceiver register with each of the extensions of the
+// host implementing StatsRegistry when they are started, and deregister when
+// they are shut down. MemoryStatsRegistry is an implementation extensions
+// can embed.
+type StatsRegistry interface {
+	// Register registers the receiver with the given name, whose scraper
+	// statistics are returned by stats, which is safe for concurrent use.
+	Register(receiver string, stats func() []ScraperStats)
+	// Deregister deregisters the receiver with the given name.
+	Deregister(receiver string)
+}
+
+// scrapeStats tracks the outcomes of the scrapes of a scraper. It may be
+// read while the scraper is scraped.
+type scrapeStats struct {
+	failures            atomic.Uint64
+	consecutiveFailures atomic.Uint64
+	// lastSuccess is in nanoseconds since the Unix epoch, or zero.
+	lastSuccess  atomic.Int64
+	lastDuration atomic.Int64
+}
+
+// record records the outcome of a scrape that ended at the given time.
+func (s *scrapeStats) record(err error, end time.Time) {
+	if err == nil || consumererror.IsPartialScrapeError(err) {
+		s.consecutiveFailures.Store(0)
+		s.lastSuccess.Store(end.UnixNano())
+		return
+	}
+	s.failures.Inc()
+	s.consecutiveFailures.Inc()
+}
+
+// scraperStats returns the statistics of the scrapes of the scraper, without
+// its ID, which is set by the receiver.
+func (b *baseScraper) scraperStats() ScraperStats {
+	stats := ScraperStats{
+		Scrapes:             b.costs.scrapes.Load(),
+		Failures:            b.stats.failures.Load(),
+		ConsecutiveFailures: b.stats.consecutiveFailures.Load(),
+		LastDuration:        time.Duration(b.stats.lastDuration.Load()),
+	}
+	if lastSuccess := b.stats.lastSuccess.Load(); lastSuccess != 0 {
+		stats.LastSuccess = time.Unix(0, lastSuccess)
+	}
+	return stats
+}
+
+// scraperStats returns the statistics of each of the scrapers created by this
+// package, in the order they were added.
+func (sc *controller) scraperStats() []ScraperStats {
+	sc.scrapersMu.RLock()
+	defer sc.scrapersMu.RUnlock()
+	var stats []ScraperStats
+	for _, scraper := range sc.scrapers {
+		if s, ok := scraper.(interface{ scraperStats() ScraperStats }); ok {
+			scraperStats := s.scraperStats()
+			scraperStats.ID = sc.scraperID(scraper.Name())
+			stats = append(stats, scraperStats)
+		}
+	}
+	return stats
+}
+
+// registerStats registers the receiver with the StatsRegistry extensions of
+// the host.
+func (sc *controller) registerStats(host component.Host) {
+	for _, extension := range host.GetExtensions() {
+		if registry, ok := extension.(StatsRegistry); ok {
+			registry.Register(sc.name, sc.scraperStats)
+			sc.statsRegistries = append(sc.statsRegistries, registry)
+		}
+	}
+}
+
+// deregisterStats deregisters the receiver from the StatsRegistry extensions
+// it registered with.
+func (sc *controller) deregisterStats() {
+	for _, registry := range sc.statsRegistries {
+		registry.Deregister(sc.name)
+	}
+	sc.statsRegistries = nil
+}
+
+var _ StatsRegistry = (*MemoryStatsRegistry)(nil)
+
+// MemoryStatsRegistry is a StatsRegistry keeping the registered receivers in
+// memory, that renders the statistics of their scrapers in the Prometheus text
+// exposition format, for instance when serving HTTP requests. It is safe for
+// concurrent use.
+type MemoryStatsRegistry struct {
+	mu        sync.Mutex
+	receivers map[string]func() []ScraperStats
+}
+
+// NewMemoryStatsRegistry returns a registry without registered receivers.
+func NewMemoryStatsRegistry() *MemoryStatsRegistry {
+	return &MemoryStatsRegistry{receivers: map[string]func() []ScraperStats{}}
+}
+
+// Register registers the receiver, replacing any receiver registered with the
+// same name.
+func (r *MemoryStatsRegistry) Register(receiver string, stats func() []ScraperStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.receivers[receiver] = stats
+}
+
+// Deregister deregisters the receiver.
+func (r *MemoryStatsRegistry) Deregister(receiver string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.receivers, receiver)
+}
+
+// Stats returns the statistics of the scrapers of the registered receivers,
+// sorted by receiver name, and in the order the scrapers were added.
+func (r *MemoryStatsRegistry) Stats() []ScraperStats {
+	r.mu.Lock()
+	receivers := make([]string, 0, len(r.receivers))
+	for receiver := range r.receivers {
+		receivers = append(receivers, receiver)
+	}
+	sort.Strings(receivers)
+	statsFuncs := make([]func() []ScraperStats, len(receivers))
+	for i, receiver := range receivers {
+		statsFuncs[i] = r.receivers[receiver]
+	}
+	r.mu.Unlock()
+
+	var stats []ScraperStats
+	for _, statsFunc := range statsFuncs {
+		stats = append(stats, statsFunc()...)
+	}
+	return stats
+}
+
+// statsMetrics are the metrics rendered by MemoryStatsRegistry.
+var statsMetrics = []struct {
+	name, help, typ string
+	value           func(ScraperStats) float64
+}{
+	{"scraper_scrapes_total", "Number of scrapes, including the scrapes that failed.", "counter",
+		func(s ScraperStats) float64 { return float64(s.Scrapes) }},
+	{"scraper_failures_total", "Number of failed scrapes.", "counter",
+		func(s ScraperStats) float64 { return float64(s.Failures) }},
+	{"scraper_consecutive_failures", "Number of failed scrapes since the last scrape that did not fail.", "gauge",
+		func(s ScraperStats) float64 { return float64(s.ConsecutiveFailures) }},
+	{"scraper_last_success_timestamp_seconds", "Time the last scrape that did not fail ended, in seconds since the Unix epoch, or 0.", "gauge",
+		func(s ScraperStats) float64 {
+			if s.LastSuccess.IsZero() {
+				return 0
+			}
+			return float64(s.LastSuccess.UnixNano()) / float64(time.Second)
+		}},
+	{"scraper_last_scrape_duration_seconds", "Time the last scrape took, in seconds.", "gauge",
+		func(s ScraperStats) float64 { return s.LastDuration.Seconds() }},
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteText writes the statistics of the scrapers in the Prometheus text
+// exposition format, with the receiver and scraper labels.
+func (r *MemoryStatsRegistry) WriteText(w io.Writer) error {
+	stats := r.Stats()
+	bw := bufio.NewWriter(w)
+	for _, metric := range statsMetrics {
+		bw.WriteString("# HELP " + metric.name + " " + metric.help + "\n")
+		bw.WriteString("# TYPE " + metric.name + " " + metric.typ + "\n")
+		for _, s := range stats {
+			bw.WriteString(metric.name)
+			bw.WriteString(`{receiver="` + labelValueReplacer.Replace(s.ID.Receiver))
+			bw.WriteString(`",scraper="` + labelValueReplacer.Replace(s.ID.Scraper) + `"} `)
+			bw.WriteString(strconv.FormatFloat(metric.value(s), 'g', -1, 64))
+			bw.WriteString("\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the statistics of the scrapers in the Prometheus text
+// exposition format.
+func (r *MemoryStatsRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.WriteText(w)
+}
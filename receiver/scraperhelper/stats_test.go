@@ -0,0 +1,177 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+// statsExtension is an extension exposing the statistics of the scrapers
+// registered with it.
+type statsExtension struct {
+	*scraperhelper.MemoryStatsRegistry
+}
+
+func (statsExtension) Start(context.Context, component.Host) error { return nil }
+func (statsExtension) Shutdown(context.Context) error              { return nil }
+
+func TestStatsRegistry(t *testing.T) {
+	registry := scraperhelper.NewMemoryStatsRegistry()
+	host := new(scrapertest.Host)
+	host.AddExtension("stats", statsExtension{registry})
+
+	fail := true
+	scrapeFlaky := func(context.Context) (pdata.MetricSlice, error) {
+		if fail {
+			return pdata.NewMetricSlice(), errors.New("unavailable")
+		}
+		return scrapertest.GenerateMetrics(1, 1), nil
+	}
+	tickerCh := make(chan time.Time)
+	next := new(scrapertest.SinkConsumer)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), next,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("ok",
+			func(context.Context) (pdata.MetricSlice, error) { return scrapertest.GenerateMetrics(1, 1), nil })),
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("flaky", scrapeFlaky)),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	assert.Empty(t, registry.Stats())
+
+	start := time.Now()
+	require.NoError(t, receiver.Start(context.Background(), host))
+	tickerCh <- time.Now()
+	scrapertest.WaitForBatches(t, next, 1, time.Second)
+	tickerCh <- time.Now()
+	scrapertest.WaitForBatches(t, next, 2, time.Second)
+
+	stats := registry.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, scraperhelper.ScraperID{Receiver: "receiver", Scraper: "ok"}, stats[0].ID)
+	assert.Equal(t, uint64(2), stats[0].Scrapes)
+	assert.Zero(t, stats[0].Failures)
+	assert.Zero(t, stats[0].ConsecutiveFailures)
+	assert.WithinDuration(t, time.Now(), stats[0].LastSuccess, time.Since(start))
+	assert.Equal(t, scraperhelper.ScraperID{Receiver: "receiver", Scraper: "flaky"}, stats[1].ID)
+	assert.Equal(t, uint64(2), stats[1].Scrapes)
+	assert.Equal(t, uint64(2), stats[1].Failures)
+	assert.Equal(t, uint64(2), stats[1].ConsecutiveFailures)
+	assert.True(t, stats[1].LastSuccess.IsZero())
+
+	// a scrape that does not fail resets the consecutive failures.
+	fail = false
+	tickerCh <- time.Now()
+	scrapertest.WaitForBatches(t, next, 3, time.Second)
+	stats = registry.Stats()
+	assert.Equal(t, uint64(3), stats[1].Scrapes)
+	assert.Equal(t, uint64(2), stats[1].Failures)
+	assert.Zero(t, stats[1].ConsecutiveFailures)
+	assert.False(t, stats[1].LastSuccess.IsZero())
+
+	require.NoError(t, receiver.Shutdown(context.Background()))
+	assert.Empty(t, registry.Stats())
+}
+
+func TestMemoryStatsRegistryText(t *testing.T) {
+	registry := scraperhelper.NewMemoryStatsRegistry()
+	registry.Register("b", func() []scraperhelper.ScraperStats {
+		return []scraperhelper.ScraperStats{{
+			ID:           scraperhelper.ScraperID{Receiver: "b", Scraper: "cpu"},
+			Scrapes:      3,
+			LastSuccess:  time.Unix(1600000000, 500000000),
+			LastDuration: 250 * time.Millisecond,
+		}}
+	})
+	registry.Register("a", func() []scraperhelper.ScraperStats {
+		return []scraperhelper.ScraperStats{{
+			ID:                  scraperhelper.ScraperID{Receiver: "a", Scraper: `"disk"`},
+			Scrapes:             4,
+			Failures:            2,
+			ConsecutiveFailures: 1,
+		}}
+	})
+	registry.Register("c", func() []scraperhelper.ScraperStats { return nil })
+	registry.Deregister("c")
+
+	const text = `# HELP scraper_scrapes_total Number of scrapes, including the scrapes that failed.
+# TYPE scraper_scrapes_total counter
+scraper_scrapes_total{receiver="a",scraper="\"disk\""} 4
+scraper_scrapes_total{receiver="b",scraper="cpu"} 3
+# HELP scraper_failures_total Number of failed scrapes.
+# TYPE scraper_failures_total counter
+scraper_failures_total{receiver="a",scraper="\"disk\""} 2
+scraper_failures_total{receiver="b",scraper="cpu"} 0
+# HELP scraper_consecutive_failures Number of failed scrapes since the last scrape that did not fail.
+# TYPE scraper_consecutive_failures gauge
+scraper_consecutive_failures{receiver="a",scraper="\"disk\""} 1
+scraper_consecutive_failures{receiver="b",scraper="cpu"} 0
+# HELP scraper_last_success_timestamp_seconds Time the last scrape that did not fail ended, in seconds since the Unix epoch, or 0.
+# TYPE scraper_last_success_timestamp_seconds gauge
+scraper_last_success_timestamp_seconds{receiver="a",scraper="\"disk\""} 0
+scraper_last_success_timestamp_seconds{receiver="b",scraper="cpu"} 1.6000000005e+09
+# HELP scraper_last_scrape_duration_seconds Time the last scrape took, in seconds.
+# TYPE scraper_last_scrape_duration_seconds gauge
+scraper_last_scrape_duration_seconds{receiver="a",scraper="\"disk\""} 0
+scraper_last_scrape_duration_seconds{receiver="b",scraper="cpu"} 0.25
+`
+	var buf bytes.Buffer
+	require.NoError(t, registry.WriteText(&buf))
+	assert.Equal(t, text, buf.String())
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, text, rec.Body.String())
+}
+
+func TestStatsTimedWithClock(t *testing.T) {
+	registry := scraperhelper.NewMemoryStatsRegistry()
+	host := new(scrapertest.Host)
+	host.AddExtension("stats", statsExtension{registry})
+
+	clock := scrapertest.NewFakeClock(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	tickerCh := make(chan time.Time)
+	next := new(scrapertest.SinkConsumer)
+	cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+	receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), next,
+		scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper("scraper", scrapertest.NewScrapeMetrics(1, 1))),
+		scraperhelper.WithClock(clock),
+		scraperhelper.WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), host))
+	tickerCh <- clock.Now()
+	scrapertest.WaitForBatches(t, next, 1, time.Second)
+
+	stats := registry.Stats()
+	require.Len(t, stats, 1)
+	assert.True(t, clock.Now().Equal(stats[0].LastSuccess), stats[0].LastSuccess)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
@@ -0,0 +1,478 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/receiver/scraperhelper"
+	"go.opentelemetry.io/collector/receiver/scraperhelper/scrapertest"
+)
+
+// schedulingStrategy is a way of scheduling the scrapes of many scrapers,
+// compared under the same load by TestSchedulingStrategies and
+// BenchmarkSchedulingStrategies.
+type schedulingStrategy struct {
+	name string
+	// receiverPerScraper creates a receiver for each scraper, instead of a
+	// single receiver for all of them.
+	receiverPerScraper bool
+	options            []scraperhelper.ScraperControllerOption
+	// realTime is set for the strategies that can not be driven by a fake
+	// clock.
+	realTime bool
+}
+
+var schedulingStrategies = []schedulingStrategy{
+	{
+		name:               "GoroutinePerScraper",
+		receiverPerScraper: true,
+	},
+	{
+		name:               "SharedScheduler",
+		receiverPerScraper: true,
+		options:            []scraperhelper.ScraperControllerOption{scraperhelper.WithSharedScheduler()},
+		realTime:           true,
+	},
+	{
+		name: "Sequential",
+	},
+	{
+		name:    "WorkerPool",
+		options: []scraperhelper.ScraperControllerOption{scraperhelper.WithMaxConcurrentScrapes(4)},
+	},
+}
+
+// schedulingLoad is the synthetic load scraped with each strategy.
+type schedulingLoad struct {
+	scrapers int
+	// intervals are the collection intervals of the scrapers, assigned in
+	// turn. The first one is the collection interval of the receivers
+	// scraping more than one scraper.
+	intervals []time.Duration
+	// every slowEvery scrape of a scraper takes slowTime, the others take no
+	// time.
+	slowEvery int
+	slowTime  time.Duration
+}
+
+// scaled returns the load with all its durations divided by factor.
+func (l schedulingLoad) scaled(factor time.Duration) schedulingLoad {
+	intervals := make([]time.Duration, len(l.intervals))
+	for i, interval := range l.intervals {
+		intervals[i] = interval / factor
+	}
+	l.intervals = intervals
+	l.slowTime /= factor
+	return l
+}
+
+// tickRecord is a scrape recorded by a tickRecorder.
+type tickRecord struct {
+	scraper string
+	// tick is the tick the scraper was scraped on, and start and end when
+	// the scrape started and ended.
+	tick, start, end time.Time
+}
+
+// tickRecorder is the next consumer of the receivers, recording the scrapes of
+// the loadScrapers from the metrics they scraped, whose data points have the
+// tick as timestamp, and the start and the end of the scrape as values.
+type tickRecorder struct {
+	mu      sync.Mutex
+	records []tickRecord
+}
+
+func (r *tickRecorder) ConsumeMetrics(_ context.Context, md pdata.Metrics) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			metrics := ilms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				dps := metrics.At(k).IntGauge().DataPoints()
+				r.records = append(r.records, tickRecord{
+					scraper: metrics.At(k).Name(),
+					tick:    time.Unix(0, int64(dps.At(0).Timestamp())).UTC(),
+					start:   time.Unix(0, dps.At(0).Value()).UTC(),
+					end:     time.Unix(0, dps.At(1).Value()).UTC(),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// scrapes returns the scrapes of each scraper, sorted by tick.
+func (r *tickRecorder) scrapes() map[string][]tickRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scrapes := map[string][]tickRecord{}
+	for _, record := range r.records {
+		scrapes[record.scraper] = append(scrapes[record.scraper], record)
+	}
+	for _, records := range scrapes {
+		sort.Slice(records, func(i, j int) bool { return records[i].tick.Before(records[j].tick) })
+	}
+	return scrapes
+}
+
+// latenesses returns the sorted times the scrapes started after their tick.
+func (r *tickRecorder) latenesses() []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	latenesses := make([]time.Duration, 0, len(r.records))
+	for _, record := range r.records {
+		latenesses = append(latenesses, record.start.Sub(record.tick))
+	}
+	sort.Slice(latenesses, func(i, j int) bool { return latenesses[i] < latenesses[j] })
+	return latenesses
+}
+
+// loadScraper scrapes a single metric, and counts the scrapes running
+// concurrently with another scrape of the same scraper.
+type loadScraper struct {
+	run *schedulingRun
+	// receiver is the index of the receiver scraping the scraper.
+	receiver int
+	name     string
+	interval time.Duration
+
+	calls    int32
+	active   int32
+	overlaps int32
+}
+
+func (s *loadScraper) scrape(ctx context.Context) (pdata.MetricSlice, error) {
+	if atomic.AddInt32(&s.active, 1) > 1 {
+		atomic.AddInt32(&s.overlaps, 1)
+	}
+	defer atomic.AddInt32(&s.active, -1)
+	atomic.AddInt32(&s.run.running, 1)
+	defer atomic.AddInt32(&s.run.running, -1)
+	atomic.AddInt32(&s.run.scraping[s.receiver], 1)
+	defer atomic.AddInt32(&s.run.scraping[s.receiver], -1)
+
+	start := s.run.now()
+	load := s.run.load
+	if load.slowEvery > 0 && int(atomic.AddInt32(&s.calls, 1))%load.slowEvery == 0 {
+		s.run.wait(ctx, load.slowTime)
+	}
+
+	metrics := pdata.NewMetricSlice()
+	metrics.Resize(1)
+	metrics.At(0).SetName(s.name)
+	metrics.At(0).SetDataType(pdata.MetricDataTypeIntGauge)
+	dps := metrics.At(0).IntGauge().DataPoints()
+	dps.Resize(2)
+	dps.At(0).SetValue(start.UnixNano())
+	dps.At(1).SetValue(s.run.now().UnixNano())
+	return metrics, nil
+}
+
+// schedulingRun runs a load with a strategy, on the fake clock if set, or in
+// real time.
+type schedulingRun struct {
+	load  schedulingLoad
+	clock *scrapertest.FakeClock
+	// release makes the slow scrapes return, so that the receivers can be
+	// shutdown without advancing the fake clock.
+	release chan struct{}
+	// running counts the scrapes running, and blocked the slow scrapes
+	// waiting for the fake clock, and scraping the scrapes running for each
+	// receiver.
+	running, blocked int32
+	scraping         []int32
+
+	scrapers  []*loadScraper
+	receivers []component.MetricsReceiver
+	recorder  *tickRecorder
+}
+
+func newSchedulingRun(t testing.TB, strategy schedulingStrategy, load schedulingLoad, clock *scrapertest.FakeClock) *schedulingRun {
+	r := &schedulingRun{
+		load:     load,
+		clock:    clock,
+		release:  make(chan struct{}),
+		recorder: new(tickRecorder),
+	}
+	newReceiver := func(i int, interval time.Duration, scrapers []*loadScraper) {
+		cfg := scraperhelper.DefaultScraperControllerSettings("receiver")
+		cfg.NameVal = fmt.Sprintf("receiver%d", i)
+		cfg.CollectionInterval = interval
+		options := append([]scraperhelper.ScraperControllerOption{
+			scraperhelper.WithUniformTimestamps(scraperhelper.TimestampTick),
+		}, strategy.options...)
+		if clock != nil {
+			options = append(options, scraperhelper.WithClock(clock))
+		}
+		for _, s := range scrapers {
+			scraperCfg := &scraperhelper.ScraperSettings{CollectionIntervalVal: s.interval}
+			options = append(options, scraperhelper.AddMetricsScraper(scraperhelper.NewMetricsScraper(s.name, s.scrape, scraperhelper.WithConfig(scraperCfg))))
+		}
+		receiver, err := scraperhelper.NewScraperControllerReceiver(&cfg, zap.NewNop(), r.recorder, options...)
+		require.NoError(t, err)
+		r.receivers = append(r.receivers, receiver)
+		r.scraping = append(r.scraping, 0)
+		for _, s := range scrapers {
+			s.receiver = i
+		}
+	}
+
+	for i := 0; i < load.scrapers; i++ {
+		r.scrapers = append(r.scrapers, &loadScraper{
+			run:      r,
+			name:     fmt.Sprintf("scraper%d", i),
+			interval: load.intervals[i%len(load.intervals)],
+		})
+	}
+	if strategy.receiverPerScraper {
+		for i, s := range r.scrapers {
+			newReceiver(i, s.interval, []*loadScraper{s})
+		}
+	} else {
+		newReceiver(0, load.intervals[0], r.scrapers)
+	}
+	return r
+}
+
+func (r *schedulingRun) now() time.Time {
+	if r.clock != nil {
+		return r.clock.Now()
+	}
+	return time.Now()
+}
+
+// wait waits for the given time to elapse, unless the context expires or the
+// slow scrapes are released first.
+func (r *schedulingRun) wait(ctx context.Context, d time.Duration) {
+	var elapsed <-chan time.Time
+	if r.clock != nil {
+		atomic.AddInt32(&r.blocked, 1)
+		defer atomic.AddInt32(&r.blocked, -1)
+		elapsed = r.clock.After(d)
+	} else {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		elapsed = timer.C
+	}
+	select {
+	case <-elapsed:
+	case <-ctx.Done():
+	case <-r.release:
+	}
+}
+
+func (r *schedulingRun) start(t testing.TB) {
+	for _, receiver := range r.receivers {
+		require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	}
+}
+
+func (r *schedulingRun) shutdown(t testing.TB) {
+	close(r.release)
+	for _, receiver := range r.receivers {
+		require.NoError(t, receiver.Shutdown(context.Background()))
+	}
+}
+
+// advance advances the fake clock by d in steps of a quarter of the shortest
+// interval, letting the receivers settle after each step: the scrapes running
+// are waiting for the clock, and the other receivers for their next deadline.
+func (r *schedulingRun) advance(t testing.TB, d time.Duration) {
+	step := r.load.intervals[0] / 4
+	for elapsed := time.Duration(0); elapsed < d; elapsed += step {
+		r.settle(t)
+		r.clock.Advance(step)
+	}
+	r.settle(t)
+}
+
+func (r *schedulingRun) settle(t testing.TB) {
+	require.Eventually(t, func() bool {
+		blocked := int(atomic.LoadInt32(&r.blocked))
+		// the receivers waiting for a slow scrape do not wait for their next
+		// deadline meanwhile.
+		idle := 0
+		for i := range r.scraping {
+			if atomic.LoadInt32(&r.scraping[i]) == 0 {
+				idle++
+			}
+		}
+		return int(atomic.LoadInt32(&r.running)) == blocked &&
+			r.clock.Waiters() == idle+blocked
+	}, 5*time.Second, 100*time.Microsecond)
+}
+
+// violations returns the violations of the invariants of scheduling by the
+// run: a scraper is never scraped concurrently with itself, and ticks missed
+// during a slow scrape are skipped, rather than scraped in a burst once it
+// ends.
+func (r *schedulingRun) violations(tolerance time.Duration) []string {
+	var violations []string
+	intervals := map[string]time.Duration{}
+	for _, s := range r.scrapers {
+		if overlaps := atomic.LoadInt32(&s.overlaps); overlaps > 0 {
+			violations = append(violations, fmt.Sprintf("%s was scraped concurrently with itself %d times", s.name, overlaps))
+		}
+		intervals[s.name] = s.interval
+	}
+	return append(violations, burstViolations(r.recorder.scrapes(), intervals, tolerance)...)
+}
+
+// burstViolations returns a violation for each scrape of a scraper on a tick
+// that was due, give or take the tolerance, before its previous scrape
+// ended, or less than its interval after the tick of its previous scrape.
+func burstViolations(scrapes map[string][]tickRecord, intervals map[string]time.Duration, tolerance time.Duration) []string {
+	names := make([]string, 0, len(scrapes))
+	for name := range scrapes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var violations []string
+	for _, name := range names {
+		records := scrapes[name]
+		for i := 1; i < len(records); i++ {
+			previous, record := records[i-1], records[i]
+			if missed := previous.end.Sub(record.tick); missed > tolerance {
+				violations = append(violations, fmt.Sprintf("%s was scraped on a tick due %v before its previous scrape ended", name, missed))
+			}
+			if gap := record.tick.Sub(previous.tick); gap < intervals[name]-tolerance {
+				violations = append(violations, fmt.Sprintf("%s was scraped %v after its previous scrape, with an interval of %v", name, gap, intervals[name]))
+			}
+		}
+	}
+	return violations
+}
+
+// schedulingTestLoad overruns the collection interval of some scrapers.
+var schedulingTestLoad = schedulingLoad{
+	scrapers:  12,
+	intervals: []time.Duration{10 * time.Second, 20 * time.Second, 50 * time.Second},
+	slowEvery: 3,
+	slowTime:  25 * time.Second,
+}
+
+func TestSchedulingStrategies(t *testing.T) {
+	const duration = 10 * time.Minute
+	for _, strategy := range schedulingStrategies {
+		strategy := strategy
+		t.Run(strategy.name, func(t *testing.T) {
+			var r *schedulingRun
+			tolerance := time.Duration(0)
+			if strategy.realTime {
+				// ticks are not exactly one interval apart in real time.
+				load := schedulingTestLoad.scaled(1000)
+				tolerance = load.intervals[0] / 2
+				r = newSchedulingRun(t, strategy, load, nil)
+				r.start(t)
+				time.Sleep(duration / 1000)
+			} else {
+				r = newSchedulingRun(t, strategy, schedulingTestLoad, scrapertest.NewFakeClock(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)))
+				r.start(t)
+				r.advance(t, duration)
+			}
+			r.shutdown(t)
+
+			assert.Empty(t, r.violations(tolerance))
+			scrapes := r.recorder.scrapes()
+			for _, s := range r.scrapers {
+				assert.NotEmpty(t, scrapes[s.name], "%s was never scraped", s.name)
+			}
+		})
+	}
+}
+
+func TestSchedulingBurstViolations(t *testing.T) {
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	scrape := func(tick, duration time.Duration) tickRecord {
+		return tickRecord{tick: t0.Add(tick), start: t0.Add(tick), end: t0.Add(tick + duration)}
+	}
+	scrapes := map[string][]tickRecord{
+		// the ticks at 20s and 30s were missed during the slow scrape, and
+		// skipped.
+		"scraper1": {scrape(0, 0), scrape(10*time.Second, 25*time.Second), scrape(40*time.Second, 0)},
+		// the tick at 20s was missed during the slow scrape, and scraped
+		// once it ended.
+		"scraper2": {scrape(0, 0), scrape(10*time.Second, 15*time.Second), scrape(20*time.Second, 0)},
+		// the tick at 5s was scraped too early.
+		"scraper3": {scrape(0, 0), scrape(5*time.Second, 0)},
+	}
+	intervals := map[string]time.Duration{"scraper1": 10 * time.Second, "scraper2": 10 * time.Second, "scraper3": 10 * time.Second}
+
+	assert.Equal(t, []string{
+		"scraper2 was scraped on a tick due 5s before its previous scrape ended",
+		"scraper3 was scraped 5s after its previous scrape, with an interval of 10s",
+	}, burstViolations(scrapes, intervals, 0))
+	assert.Empty(t, burstViolations(scrapes, intervals, 5*time.Second))
+}
+
+// schedulingBenchmarkLoad is scraped in real time by
+// BenchmarkSchedulingStrategies.
+var schedulingBenchmarkLoad = schedulingLoad{
+	scrapers:  100,
+	intervals: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond},
+}
+
+// BenchmarkSchedulingStrategies runs the load in real time with each strategy,
+// for one shortest interval per operation, and reports the CPU time of the
+// process, the number of scrapes and the median and 99th percentile of the
+// time scrapes started after their tick, per operation.
+func BenchmarkSchedulingStrategies(b *testing.B) {
+	for _, strategy := range schedulingStrategies {
+		strategy := strategy
+		b.Run(strategy.name, func(b *testing.B) {
+			r := newSchedulingRun(b, strategy, schedulingBenchmarkLoad, nil)
+			r.start(b)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			cpuStart, cpuOK := processCPUTime()
+			time.Sleep(time.Duration(b.N) * schedulingBenchmarkLoad.intervals[0])
+			cpuEnd, _ := processCPUTime()
+			b.StopTimer()
+			r.shutdown(b)
+
+			if violations := r.violations(schedulingBenchmarkLoad.intervals[0] / 2); len(violations) > 0 {
+				b.Fatalf("scheduling invariants violated: %q", violations)
+			}
+			if cpuOK {
+				b.ReportMetric(float64(cpuEnd-cpuStart)/float64(b.N), "cpu-ns/op")
+			}
+			latenesses := r.recorder.latenesses()
+			b.ReportMetric(float64(len(latenesses))/float64(b.N), "scrapes/op")
+			if len(latenesses) > 0 {
+				b.ReportMetric(float64(latenesses[len(latenesses)/2]), "p50-late-ns")
+				b.ReportMetric(float64(latenesses[len(latenesses)*99/100]), "p99-late-ns")
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+)
+
+// EmitMetrics passes a chunk of the metrics being scraped to the receiver.
+// The chunk must not be used by the scraper once it has been emitted. An
+// error is returned if the scrape was cancelled, in which case the chunk was
+// not passed on, and the scraper should stop emitting.
+type EmitMetrics func(pdata.Metrics) error
+
+// ScrapeMetricsStream scrapes metrics, passing them to emit in chunks as
+// they are scraped, instead of returning them all at once.
+type ScrapeMetricsStream func(ctx context.Context, emit EmitMetrics) error
+
+// StreamingScraper is an interface for scrapers that pass the scraped
+// metrics to the receiver in chunks, so that very large scrapes do not have
+// to be held in memory at once.
+type StreamingScraper interface {
+	BaseScraper
+	ScrapeStream(ctx context.Context, receiverName string, emit EmitMetrics) error
+}
+
+// AddStreamingScraper configures the provided streaming scraper to be
+// scraped at the specified collection interval.
+//
+// Each chunk emitted by the scraper is passed to the next consumer as soon as
+// it is emitted, split in batches if WithMaxBatchSize is set, separately from
+// the metrics of the other scrapers, which WithDeduplicateSeries does not
+// compare them to. Observability information is reported once per scrape,
+// counting the metrics of all the chunks.
+func AddStreamingScraper(scraper StreamingScraper) ScraperControllerOption {
+	return func(o *controller) {
+		o.checkCreating()
+		if scraper == nil {
+			o.optionErrs = append(o.optionErrs, errors.New("streaming scraper must not be nil"))
+			return
+		}
+		if !scraperEnabled(scraper) {
+			o.disabledScrapers = append(o.disabledScrapers, DisabledScraper{ID: o.scraperID(scraper.Name()), Reason: DisabledByConfig})
+			return
+		}
+		o.streamingScrapers = append(o.streamingScrapers, scraper)
+		o.scrapers = append(o.scrapers, scraper)
+	}
+}
+
+type streamingScraper struct {
+	baseScraper
+	ScrapeMetricsStream
+}
+
+var _ StreamingScraper = (*streamingScraper)(nil)
+
+// NewStreamingScraper creates a StreamingScraper that calls scrape at the
+// specified collection interval, reports observability information, and
+// passes each chunk of scraped metrics to the next consumer as soon as it is
+// emitted.
+//
+// The options applying to the whole of a scrape, WithForwardEvery,
+// WithResultCache, WithStalenessMarkers, WithStartTimeTracking and
+// WithDeltaToCumulative, are not supported. WithMaxDataPoints limits the data
+// points of all the chunks of a scrape together.
+func NewStreamingScraper(name string, scrape ScrapeMetricsStream, options ...ScraperOption) StreamingScraper {
+	return &streamingScraper{
+		baseScraper:         newBaseScraper(name, newScraperSettings(options)),
+		ScrapeMetricsStream: scrape,
+	}
+}
+
+// validate returns an error if the scraper was created without a scrape
+// function, or with invalid or unsupported options.
+func (ss *streamingScraper) validate() error {
+	if ss.ScrapeMetricsStream == nil {
+		return errors.New("scrape function must not be nil")
+	}
+	switch {
+	case ss.forwardEvery > 1:
+		return errors.New("WithForwardEvery is not supported by streaming scrapers")
+	case ss.resultCache != nil:
+		return errors.New("WithResultCache is not supported by streaming scrapers")
+	case ss.staleness != nil:
+		return errors.New("WithStalenessMarkers is not supported by streaming scrapers")
+	case ss.startTimes != nil:
+		return errors.New("WithStartTimeTracking is not supported by streaming scrapers")
+	case ss.cumulative != nil:
+		return errors.New("WithDeltaToCumulative is not supported by streaming scrapers")
+	}
+	return ss.baseScraper.validate()
+}
+
+func (ss *streamingScraper) ScrapeStream(ctx context.Context, receiverName string, emit EmitMetrics) error {
+	ctx = ss.scraperCtx.get(ctx, receiverName, &ss.baseScraper)
+	ok, predicateErr := ss.shouldScrape(ctx)
+	if !ok && predicateErr == nil {
+		obsreport.RecordMetricsScrapeFiltered(ctx)
+		return nil
+	}
+	observe := observed(ctx)
+	if observe {
+		ctx = obsreport.StartMetricsScrapeOp(ctx, receiverName, ss.Name())
+	}
+	stream := &metricsStream{scraper: ss, obsCtx: ctx, emit: emit}
+	err := predicateErr
+	if err == nil {
+		ss.timeScrape(ctx, func() { err = ss.scrape(ctx, stream) })
+	}
+	if observe {
+		obsreport.EndMetricsScrapeOp(ctx, stream.metricCount, err)
+	}
+	ss.recordScrape(err)
+	return err
+}
+
+func (ss *streamingScraper) scrape(ctx context.Context, stream *metricsStream) error {
+	if err := ss.initialize(ctx); err != nil {
+		return err
+	}
+	scrapeCtx, cancel := ss.withScrapeTimeout(ctx)
+	defer cancel()
+
+	stream.ctx = scrapeCtx
+	if ss.maxDataPoints > 0 {
+		stream.truncation = &truncation{remaining: ss.maxDataPoints}
+	}
+	var err error
+	if labels, ok := ss.scrapeLabels(); ok {
+		pprof.Do(scrapeCtx, labels, func(ctx context.Context) { err = ss.ScrapeMetricsStream(ctx, stream.emitChunk) })
+	} else {
+		err = ss.ScrapeMetricsStream(scrapeCtx, stream.emitChunk)
+	}
+	if stream.truncation != nil {
+		err = combineTruncationError(err, stream.truncation.err(ss.maxDataPoints))
+	}
+	return err
+}
+
+// metricsStream processes the chunks emitted during a scrape of a streaming
+// scraper.
+type metricsStream struct {
+	scraper *streamingScraper
+	// ctx is the context of the scrape, and obsCtx the context its
+	// observability information is recorded with.
+	ctx        context.Context
+	obsCtx     context.Context
+	emit       EmitMetrics
+	truncation *truncation
+	// metricCount counts the metrics of all the chunks.
+	metricCount int
+}
+
+// emitChunk processes a chunk the same way the metrics of other scrapers are
+// processed, and passes it on unless the scrape was cancelled.
+func (s *metricsStream) emitChunk(chunk pdata.Metrics) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	ss := s.scraper
+	resourceMetrics := chunk.ResourceMetrics()
+	if ss.nameFilter != nil {
+		if filtered := ss.nameFilter.filterResourceMetrics(resourceMetrics); filtered > 0 {
+			obsreport.RecordMetricsScrapeFilteredPoints(s.obsCtx, filtered)
+		}
+	}
+	if len(ss.constLabels) > 0 {
+		addResourceMetricsLabels(resourceMetrics, ss.constLabels)
+	}
+	if len(ss.resourceAttributes) > 0 {
+		insertResourceAttributes(resourceMetrics, ss.resourceAttributes)
+	}
+	if s.truncation != nil {
+		s.truncation.truncateResourceMetrics(resourceMetrics)
+	}
+	s.metricCount += metricCount(resourceMetrics)
+	return s.emit(chunk)
+}
+
+// scrapeStreams scrapes the streaming scrapers due on the given tick, passing
+// each chunk they emit to the next consumer as soon as it is emitted.
+func (sc *controller) scrapeStreams(ctx context.Context, tick time.Time, ts pdata.TimestampUnixNano) {
+	sc.scrapersMu.RLock()
+	defer sc.scrapersMu.RUnlock()
+
+	r := &sc.streamReporter
+	if r.emit == nil {
+		r.sc = sc
+		r.emit = r.report
+	}
+	for _, ss := range sc.streamingScrapers {
+		if !sc.due(ss, tick) {
+			continue
+		}
+		r.ctx, r.scraper, r.metadata, r.dryRun, r.ts = ctx, ss.Name(), consumeMetadata(ss), dryRun(ss), ts
+		err := ss.ScrapeStream(ctx, sc.name, r.emit)
+		if err != nil {
+			sc.logger.Error("Error scraping metrics", zap.String("scraper", ss.Name()), zap.Error(err))
+		}
+		if len(r.consumeErrs) > 0 {
+			sc.logger.Error("Error consuming streamed metrics",
+				zap.String("scraper", ss.Name()),
+				zap.Int("chunks", len(r.consumeErrs)),
+				zap.Error(componenterror.CombineErrors(r.consumeErrs)))
+		}
+		r.ctx, r.consumeErrs = nil, r.consumeErrs[:0]
+	}
+}
+
+// streamReporter passes the chunks emitted by the streaming scraper being
+// scraped to the next consumer, collecting the errors of the next consumer.
+// It is only used by the scrape loop, and reused on every tick, with emit
+// bound to it once, so that scraping a streaming scraper does not allocate a
+// new emit function.
+type streamReporter struct {
+	sc          *controller
+	ctx         context.Context
+	scraper     string
+	metadata    metadata.MD
+	dryRun      bool
+	ts          pdata.TimestampUnixNano
+	consumeErrs []error
+	emit        EmitMetrics
+}
+
+func (r *streamReporter) report(chunk pdata.Metrics) error {
+	payload := scrapedPayload{scraper: r.scraper, metrics: chunk, metadata: r.metadata, dryRun: r.dryRun}
+	if !r.sc.firstScrapes.done(r.scraper) {
+		payload.firstScrapes = []string{r.scraper}
+	}
+	if err := r.sc.report(r.ctx, payload, r.ts); err != nil {
+		r.consumeErrs = append(r.consumeErrs, err)
+	}
+	return nil
+}
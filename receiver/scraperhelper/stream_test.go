@@ -0,0 +1,185 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport/obsreporttest"
+)
+
+// metricsChunk returns metrics with a single resource, holding gauges with
+// a single data point and the given names.
+func metricsChunk(names ...string) pdata.Metrics {
+	md := pdata.NewMetrics()
+	md.ResourceMetrics().Resize(1)
+	ilms := md.ResourceMetrics().At(0).InstrumentationLibraryMetrics()
+	ilms.Resize(1)
+	metrics := ilms.At(0).Metrics()
+	metrics.Resize(len(names))
+	for i, name := range names {
+		metrics.At(i).SetName(name)
+		metrics.At(i).SetDataType(pdata.MetricDataTypeDoubleGauge)
+		metrics.At(i).DoubleGauge().DataPoints().Resize(1)
+	}
+	return md
+}
+
+// streamChunks returns a scrape function emitting the given chunks in order.
+func streamChunks(chunks ...[]string) ScrapeMetricsStream {
+	return func(_ context.Context, emit EmitMetrics) error {
+		for _, names := range chunks {
+			if err := emit(metricsChunk(names...)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func chunkNames(md pdata.Metrics) []string {
+	var names []string
+	metrics := md.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		names = append(names, metrics.At(i).Name())
+	}
+	return names
+}
+
+func TestStreamingScraper(t *testing.T) {
+	obsreporttest.SetupRecordedMetrics(t)
+
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	scrape := streamChunks([]string{"m1", "m2"}, []string{"m3"}, []string{"m4", "m5"})
+	cfg := &testScraperConfig{ScraperSettings: ScraperSettings{ResourceAttributesVal: map[string]string{"host": "a"}}}
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), sink,
+		AddStreamingScraper(NewStreamingScraper("scraper", scrape, WithConfig(cfg))),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	tickerCh <- time.Now()
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) == 3 }, time.Second, time.Millisecond)
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	// each chunk is passed to the next consumer separately.
+	chunks := sink.AllMetrics()
+	assert.Equal(t, []string{"m1", "m2"}, chunkNames(chunks[0]))
+	assert.Equal(t, []string{"m3"}, chunkNames(chunks[1]))
+	assert.Equal(t, []string{"m4", "m5"}, chunkNames(chunks[2]))
+	for _, chunk := range chunks {
+		host, ok := chunk.ResourceMetrics().At(0).Resource().Attributes().Get("host")
+		require.True(t, ok)
+		assert.Equal(t, "a", host.StringVal())
+	}
+
+	// the scrape is recorded once, with the metrics of all the chunks.
+	obsreporttest.CheckScraperMetricsViews(t, "receiver", "scraper", 5, 0)
+	obsreporttest.CheckReceiverMetricsViews(t, "receiver", "", 5, 0)
+}
+
+func TestStreamingScraperCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var emitErr error
+	scrape := func(_ context.Context, emit EmitMetrics) error {
+		require.NoError(t, emit(metricsChunk("m1")))
+		cancel()
+		emitErr = emit(metricsChunk("m2"))
+		return emitErr
+	}
+	scraper := NewStreamingScraper("scraper", scrape)
+
+	var emitted []pdata.Metrics
+	err := scraper.ScrapeStream(ctx, "receiver", func(chunk pdata.Metrics) error {
+		emitted = append(emitted, chunk)
+		return nil
+	})
+	assert.Equal(t, context.Canceled, emitErr)
+	assert.Equal(t, context.Canceled, err)
+	require.Len(t, emitted, 1)
+	assert.Equal(t, []string{"m1"}, chunkNames(emitted[0]))
+}
+
+func TestStreamingScraperMaxDataPoints(t *testing.T) {
+	scrape := streamChunks([]string{"m1", "m2"}, []string{"m3", "m4"}, []string{"m5"})
+	scraper := NewStreamingScraper("scraper", scrape, WithMaxDataPoints(3))
+
+	var names []string
+	err := scraper.ScrapeStream(context.Background(), "receiver", func(chunk pdata.Metrics) error {
+		names = append(names, chunkNames(chunk)...)
+		return nil
+	})
+	// the data points of all the chunks are limited together.
+	assert.Equal(t, []string{"m1", "m2", "m3"}, names)
+	assert.Error(t, err)
+}
+
+func TestStreamingScraperConsumeErrors(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	tickerCh := make(chan time.Time)
+	next := &failingBatchConsumer{fail: map[int]bool{1: true, 3: true}}
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	scrape := streamChunks([]string{"m1"}, []string{"m2"}, []string{"m3"})
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.New(core), next,
+		AddStreamingScraper(NewStreamingScraper("scraper", scrape)),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+
+	tickerCh <- time.Now()
+	require.NoError(t, receiver.Shutdown(context.Background()))
+
+	// the chunks after a failed one are still passed on, and the errors are
+	// logged together once the scrape is done.
+	require.Len(t, next.AllMetrics(), 1)
+	assert.Equal(t, []string{"m2"}, chunkNames(next.AllMetrics()[0]))
+	failed := logs.FilterMessage("Error consuming streamed metrics").All()
+	require.Len(t, failed, 1)
+	assert.EqualValues(t, 2, failed[0].ContextMap()["chunks"])
+	assert.Equal(t, "scraper", failed[0].ContextMap()["scraper"])
+}
+
+func TestStreamingScraperUnsupportedOptions(t *testing.T) {
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	_, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddStreamingScraper(NewStreamingScraper("forward", streamChunks(), WithForwardEvery(2))),
+		AddStreamingScraper(NewStreamingScraper("nil", nil)),
+		AddStreamingScraper(NewStreamingScraper("staleness", streamChunks(), WithStalenessMarkers())),
+	)
+	assert.EqualError(t, err, `receiver "receiver" has 3 configuration errors:
+  - invalid scraper "forward": WithForwardEvery is not supported by streaming scrapers
+  - invalid scraper "nil": scrape function must not be nil
+  - invalid scraper "staleness": WithStalenessMarkers is not supported by streaming scrapers`)
+
+	_, err = NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), new(consumertest.MetricsSink),
+		AddStreamingScraper(NewStreamingScraper("scraper", streamChunks())),
+		AddStreamingScraper(nil),
+	)
+	assert.EqualError(t, err, "streaming scraper must not be nil")
+}
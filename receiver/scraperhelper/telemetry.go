@@ -0,0 +1,47 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+
+	"go.opencensus.io/trace"
+
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/obsreport"
+)
+
+// observed reports whether the spans and metrics of a scrape or consume with
+// the given context are recorded. They are not if the collector's own
+// telemetry, or that of the receiver set in its ReceiverCreateSettings, is
+// disabled with the none level, unless the context carries a span already,
+// so that the operations of traced callers are still traced. This is checked
+// on every operation, so that a level set with obsreport.Configure applies
+// without restarting the receiver.
+func observed(ctx context.Context) bool {
+	if trace.FromContext(ctx) != nil {
+		return true
+	}
+	if settings, ok := CreateSettingsFromContext(ctx); ok && settings.MetricsLevel == configtelemetry.LevelNone {
+		return false
+	}
+	return obsreport.Enabled()
+}
+
+// receiverContext returns the context the receiver scrapes with, carrying
+// its name and settings.
+func (sc *controller) receiverContext(ctx context.Context) context.Context {
+	return obsreport.ReceiverContext(contextWithCreateSettings(ctx, sc.settings), sc.name, "")
+}
@@ -0,0 +1,111 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/obsreport"
+	"go.opentelemetry.io/collector/obsreport/obsreporttest"
+)
+
+func spanNames(spans []*trace.SpanData) []string {
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	return names
+}
+
+func TestTelemetryDisabled(t *testing.T) {
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+	defer trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+	ss := &spanStore{}
+	trace.RegisterExporter(ss)
+	defer trace.UnregisterExporter(ss)
+	defer obsreport.Configure(configtelemetry.LevelBasic)
+
+	obsreport.Configure(configtelemetry.LevelNone)
+	tickerCh := make(chan time.Time)
+	sink := new(consumertest.MetricsSink)
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return singleMetric(), nil }
+	defaultCfg := DefaultScraperControllerSettings("receiver")
+	receiver, err := NewScraperControllerReceiver(&defaultCfg, zap.NewNop(), sink,
+		AddMetricsScraper(NewMetricsScraper("scraper", scrape)),
+		WithTickerChannel(tickerCh),
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, receiver.Shutdown(context.Background())) }()
+
+	tick := func(n int) {
+		tickerCh <- time.Now()
+		require.Eventually(t, func() bool { return len(sink.AllMetrics()) == n }, time.Second, time.Millisecond)
+	}
+	tick(1)
+	assert.Empty(t, ss.PullAllSpans())
+
+	// the operations of traced callers are still traced.
+	ctx, span := trace.StartSpan(context.Background(), "caller")
+	_, err = receiver.(*controller).metricsScrapers.scrapers[0].Scrape(ctx, "receiver")
+	require.NoError(t, err)
+	span.End()
+	assert.Equal(t, []string{"scraper/receiver/scraper/MetricsScraped", "caller"}, spanNames(ss.PullAllSpans()))
+
+	// enabling the telemetry applies to the next scrape, without restarting
+	// the receiver.
+	obsreporttest.SetupRecordedMetrics(t)
+	tick(2)
+	assert.Equal(t, []string{"scraper/receiver/scraper/MetricsScraped", "receiver/receiver/MetricsReceived"}, spanNames(ss.PullAllSpans()))
+	obsreporttest.CheckScraperMetricsViews(t, "receiver", "scraper", 1, 0)
+	obsreporttest.CheckReceiverMetricsViews(t, "receiver", "", 1, 0)
+}
+
+// BenchmarkScrapeTelemetry compares the scrapes of a scraper with the
+// collector's own telemetry disabled and enabled, to calling its scrape
+// function directly.
+func BenchmarkScrapeTelemetry(b *testing.B) {
+	defer obsreport.Configure(configtelemetry.LevelBasic)
+
+	metrics := pdata.NewMetricSlice()
+	scrape := func(context.Context) (pdata.MetricSlice, error) { return metrics, nil }
+	scraper := NewMetricsScraper("scraper", scrape)
+	for _, level := range []configtelemetry.Level{configtelemetry.LevelNone, configtelemetry.LevelBasic} {
+		b.Run(level.String(), func(b *testing.B) {
+			obsreport.Configure(level)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = scraper.Scrape(context.Background(), "receiver")
+			}
+		})
+	}
+	b.Run("baseline", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = scrape(context.Background())
+		}
+	})
+}
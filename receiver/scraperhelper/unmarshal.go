@@ -0,0 +1,249 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"go.opentelemetry.io/collector/component/componenterror"
+	"go.opentelemetry.io/collector/config"
+)
+
+// durationKeys contains the keys of the duration settings of ScraperSettings.
+var durationKeys = func() []string {
+	var keys []string
+	for i := 0; i < scraperSettingsType.NumField(); i++ {
+		field := scraperSettingsType.Field(i)
+		if field.Type == reflect.TypeOf(time.Duration(0)) {
+			keys = append(keys, field.Tag.Get("mapstructure"))
+		}
+	}
+	return keys
+}()
+
+// UnmarshalScraperConfig unmarshals the configuration section of a scraper
+// into its configuration like viper.UnmarshalExact, additionally accepting a
+// bare number of seconds, such as 30 or 2.5, for the duration settings of
+// ScraperSettings, in addition to duration strings such as "30s".
+//
+// Bare numbers are registered as deprecated with RegisterDeprecatedField, if
+// the configuration embeds ScraperSettings, so that a warning is logged when
+// the receiver is started. Negative durations, and numeric strings such as
+// "30", which could have been meant in any unit, are rejected, as are
+// invalid failure policies. The section is not modified.
+func UnmarshalScraperConfig(v *viper.Viper, cfg ScraperConfig) error {
+	settings := v.AllSettings()
+
+	var deprecated []DeprecatedField
+	for _, key := range durationKeys {
+		value, ok := settings[key]
+		if !ok {
+			continue
+		}
+		d, bare, err := parseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %s: %w", key, formatDurationValue(value), err)
+		}
+		settings[key] = d
+		if bare {
+			deprecated = append(deprecated, DeprecatedField{
+				Name:        fmt.Sprintf("%s: %v", key, value),
+				Replacement: fmt.Sprintf("%s: %v", key, d),
+			})
+		}
+	}
+
+	section := config.NewViper()
+	if err := section.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	if err := section.UnmarshalExact(cfg); err != nil {
+		return err
+	}
+	if err := cfg.OnFailure().Validate(); err != nil {
+		return fmt.Errorf("invalid on_failure: %w", err)
+	}
+
+	if r, ok := cfg.(interface{ RegisterDeprecatedField(DeprecatedField) }); ok {
+		for _, field := range deprecated {
+			r.RegisterDeprecatedField(field)
+		}
+	}
+	return nil
+}
+
+// UnmarshalScraperConfigStrict unmarshals the configuration section of the
+// scraper with the given name like UnmarshalScraperConfig, after checking that
+// every key of the section, including the keys of nested sections, is a field
+// of the configuration, either of the embedded ScraperSettings or of the
+// configuration itself. An error is returned for each unknown key, naming the
+// scraper and suggesting the closest field name, so that typos are not
+// silently ignored.
+func UnmarshalScraperConfigStrict(name string, v *viper.Viper, cfg ScraperConfig) error {
+	unknown := unknownFields(v.AllSettings(), reflect.TypeOf(cfg), "")
+	if len(unknown) == 0 {
+		return UnmarshalScraperConfig(v, cfg)
+	}
+
+	errs := make([]error, 0, len(unknown))
+	for _, field := range unknown {
+		if field.closest == "" {
+			errs = append(errs, fmt.Errorf("unknown field %q in the configuration of scraper %q", field.key, name))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("unknown field %q in the configuration of scraper %q, did you mean %q?", field.key, name, field.closest))
+	}
+	return componenterror.CombineErrors(errs)
+}
+
+// unknownField is a key of a configuration section that is not a field of
+// the configuration, with the closest field name, if any.
+type unknownField struct {
+	key     string
+	closest string
+}
+
+// unknownFields returns the keys of the settings that are not fields of the
+// given struct type, recursing into the settings of struct fields, in the
+// order of the keys. The keys of nested settings are prefixed with the keys
+// of their parents.
+func unknownFields(settings map[string]interface{}, t reflect.Type, prefix string) []unknownField {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := map[string]reflect.Type{}
+	collectFields(t, fields)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var unknown []unknownField
+	for _, key := range keys {
+		field, ok := fields[strings.ToLower(key)]
+		if !ok {
+			closest := closestName(strings.ToLower(key), names)
+			if closest != "" {
+				closest = prefix + closest
+			}
+			unknown = append(unknown, unknownField{key: prefix + key, closest: closest})
+			continue
+		}
+		if nested, ok := settings[key].(map[string]interface{}); ok {
+			unknown = append(unknown, unknownFields(nested, field, prefix+key+".")...)
+		}
+	}
+	return unknown
+}
+
+// collectFields adds the mapstructure names of the fields of the struct type,
+// including the fields of squashed embedded structs, to the fields.
+func collectFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		squash := false
+		for _, opt := range tag[1:] {
+			squash = squash || opt == "squash"
+		}
+		if squash && field.Type.Kind() == reflect.Struct {
+			collectFields(field.Type, fields)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = field.Type
+	}
+}
+
+// parseDuration parses a duration string, or a bare number of seconds, and
+// reports whether the duration was a bare number.
+func parseDuration(value interface{}) (time.Duration, bool, error) {
+	var seconds float64
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if d, ok := value.(time.Duration); ok {
+			return d, false, checkDuration(d)
+		}
+		seconds = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		seconds = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		seconds = v.Float()
+	case reflect.String:
+		s := strings.TrimSpace(v.String())
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return 0, false, fmt.Errorf("ambiguous duration without a unit, use a duration such as \"%ss\", or a number of seconds without quotes", s)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, false, err
+		}
+		return d, false, checkDuration(d)
+	default:
+		return 0, false, fmt.Errorf("unsupported duration of type %T", value)
+	}
+
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) || seconds*float64(time.Second) > math.MaxInt64 {
+		return 0, true, errors.New("number of seconds out of range")
+	}
+	d := time.Duration(seconds * float64(time.Second))
+	return d, true, checkDuration(d)
+}
+
+// checkDuration returns an error if the duration is negative.
+func checkDuration(d time.Duration) error {
+	if d < 0 {
+		return errors.New("duration must not be negative")
+	}
+	return nil
+}
+
+// formatDurationValue formats a configured duration for error messages,
+// quoting strings.
+func formatDurationValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,227 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package scraperhelper
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/config"
+)
+
+// The fuzz targets only run their seed corpus with go test. Run them with
+// random inputs with, for instance:
+//
+//	go test -run '^$' -fuzz FuzzUnmarshalScraperConfigYAML -fuzztime 1m
+
+// seedConfigs are realistic scraper configurations, and configurations
+// shaped like the ones that were mishandled in the past.
+var seedConfigs = []string{
+	"collection_interval: 30s\n",
+	"collection_interval: 10s\ntimeout: 5s\ninitial_delay: 1s\npath: /proc\n",
+	"collection_interval: 1m\nmax_data_points: 1000\nresource_attributes:\n  host.name: server\n  env: prod\n",
+	"on_failure:\n  mode: backoff\n  initial_backoff: 1s\n  max_backoff: 1m\n",
+	"on_failure:\n  mode: disable_after\n  max_failures: 3\n",
+	"on_failure:\n  mode: fatal\n  max_failures: 1\n",
+	// bare numbers of seconds.
+	"collection_interval: 30\ntimeout: 0.25\n",
+	// numeric strings, without a unit.
+	"collection_interval: \"30\"\n",
+	"timeout: -1s\n",
+	"collection_interval: -5\n",
+	"collection_interval: .nan\n",
+	"collection_interval: .inf\n",
+	"collection_interval: 1e300\n",
+	"collection_interval: 9223372036.854775807\n",
+	"collection_interval: true\n",
+	"collection_interval: [30s]\n",
+	"collection_interval:\n  seconds: 30\n",
+	"collection_interval:\n",
+	"on_failure: backoff\n",
+	"on_failure:\n  mode: retry\n",
+	"on_failure:\n  mode: backoff\n  initial_backoff: 1m\n  max_backoff: 30s\n",
+	"on_failure:\n  mode: fatal\n  max_failures: -1\n",
+	"resource_attributes:\n  \"\": value\n",
+	"resource_attributes:\n  key: [a, b]\n",
+	"resource_attributes: value\n",
+	"max_data_points: -1\n",
+	"max_data_points: 1.5\n",
+	"unknown: true\n",
+}
+
+func FuzzUnmarshalScraperConfigYAML(f *testing.F) {
+	for _, seed := range seedConfigs {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v := config.NewViper()
+		v.SetConfigType("yaml")
+		if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+			return
+		}
+		checkUnmarshalScraperConfig(t, v.AllSettings())
+	})
+}
+
+// settingPaths are the paths of the settings of testScraperConfig that
+// FuzzUnmarshalScraperConfigMap changes.
+var settingPaths = []string{
+	"timeout",
+	"initial_delay",
+	"collection_interval",
+	"max_data_points",
+	"path",
+	"resource_attributes",
+	"resource_attributes.host.name",
+	"on_failure",
+	"on_failure.mode",
+	"on_failure.max_failures",
+	"on_failure.initial_backoff",
+	"on_failure.max_backoff",
+}
+
+func FuzzUnmarshalScraperConfigMap(f *testing.F) {
+	f.Add(uint8(2), uint8(0), "1m", int64(0), 0.0)
+	f.Add(uint8(2), uint8(1), "", int64(-30), 0.0)
+	f.Add(uint8(0), uint8(2), "", int64(0), math.NaN())
+	f.Add(uint8(0), uint8(2), "", int64(0), math.Inf(1))
+	f.Add(uint8(1), uint8(2), "", int64(0), 1e300)
+	f.Add(uint8(2), uint8(0), "30", int64(0), 0.0)
+	f.Add(uint8(8), uint8(0), "retry", int64(0), 0.0)
+	f.Add(uint8(8), uint8(0), "fatal", int64(0), 0.0)
+	f.Add(uint8(9), uint8(1), "", int64(math.MinInt64), 0.0)
+	f.Add(uint8(10), uint8(0), "-1s", int64(0), 0.0)
+	f.Add(uint8(7), uint8(0), "backoff", int64(0), 0.0)
+	f.Add(uint8(5), uint8(5), "", int64(0), 0.0)
+	f.Add(uint8(6), uint8(6), "", int64(0), 0.0)
+	f.Add(uint8(3), uint8(2), "", int64(0), 1.5)
+	f.Add(uint8(12), uint8(0), "Unknown.Key", int64(0), 0.0)
+	f.Fuzz(func(t *testing.T, path, kind uint8, s string, i int64, fl float64) {
+		settings := map[string]interface{}{
+			"collection_interval": "10s",
+			"timeout":             "5s",
+			"path":                "/proc",
+			"resource_attributes": map[string]interface{}{"env": "prod"},
+			"on_failure": map[string]interface{}{
+				"mode":            "backoff",
+				"initial_backoff": "1s",
+				"max_backoff":     "1m",
+			},
+		}
+		key := s
+		if int(path) < len(settingPaths) {
+			key = settingPaths[path]
+		}
+		setSetting(settings, strings.Split(key, "."), fuzzValue(kind, s, i, fl))
+		checkUnmarshalScraperConfig(t, settings)
+	})
+}
+
+// fuzzValue returns a setting value of the kind selected by the fuzzer.
+func fuzzValue(kind uint8, s string, i int64, fl float64) interface{} {
+	switch kind % 8 {
+	case 0:
+		return s
+	case 1:
+		return i
+	case 2:
+		return fl
+	case 3:
+		return i%2 == 0
+	case 4:
+		return nil
+	case 5:
+		return map[string]interface{}{s: i}
+	case 6:
+		return []interface{}{s, i}
+	default:
+		return uint64(i)
+	}
+}
+
+// setSetting sets the value at the path of the settings, replacing the
+// settings on the way that are not maps.
+func setSetting(settings map[string]interface{}, path []string, value interface{}) {
+	if len(path) == 1 {
+		settings[path[0]] = value
+		return
+	}
+	nested, ok := settings[path[0]].(map[string]interface{})
+	if !ok {
+		nested = map[string]interface{}{}
+		settings[path[0]] = nested
+	}
+	setSetting(nested, path[1:], value)
+}
+
+// checkUnmarshalScraperConfig unmarshals the settings, which must not panic,
+// and checks that an accepted configuration is valid as far as unmarshalling
+// goes, and unmarshals to the same configuration once marshalled back.
+func checkUnmarshalScraperConfig(t *testing.T, settings map[string]interface{}) {
+	v := config.NewViper()
+	require.NoError(t, v.MergeConfigMap(settings))
+	cfg := &testScraperConfig{}
+	if err := UnmarshalScraperConfig(v, cfg); err != nil {
+		return
+	}
+	assert.GreaterOrEqual(t, int64(cfg.TimeoutVal), int64(0))
+	assert.GreaterOrEqual(t, int64(cfg.InitialDelayVal), int64(0))
+	assert.GreaterOrEqual(t, int64(cfg.CollectionIntervalVal), int64(0))
+	assert.NoError(t, cfg.OnFailure().Validate())
+
+	v = config.NewViper()
+	require.NoError(t, v.MergeConfigMap(marshalTestScraperConfig(cfg)))
+	roundTripped := &testScraperConfig{}
+	require.NoError(t, UnmarshalScraperConfig(v, roundTripped))
+	cfg.deprecatedFields = nil
+	// empty sections are dropped.
+	if len(cfg.ResourceAttributesVal) == 0 {
+		cfg.ResourceAttributesVal = nil
+	}
+	assert.Equal(t, cfg, roundTripped)
+	assert.Empty(t, roundTripped.registeredDeprecatedFields())
+	assert.Equal(t, validateConfig(cfg), validateConfig(roundTripped))
+}
+
+// marshalTestScraperConfig returns the settings of the configuration, with
+// the durations in their preferred form.
+func marshalTestScraperConfig(cfg *testScraperConfig) map[string]interface{} {
+	attributes := map[string]interface{}{}
+	for key, value := range cfg.ResourceAttributesVal {
+		attributes[key] = value
+	}
+	return map[string]interface{}{
+		"timeout":             cfg.TimeoutVal.String(),
+		"initial_delay":       cfg.InitialDelayVal.String(),
+		"collection_interval": cfg.CollectionIntervalVal.String(),
+		"max_data_points":     cfg.MaxDataPointsVal,
+		"resource_attributes": attributes,
+		"on_failure": map[string]interface{}{
+			"mode":            string(cfg.OnFailureVal.Mode),
+			"max_failures":    cfg.OnFailureVal.MaxFailures,
+			"initial_backoff": cfg.OnFailureVal.InitialBackoff.String(),
+			"max_backoff":     cfg.OnFailureVal.MaxBackoff.String(),
+		},
+		"path": cfg.Path,
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scraperhelper
+
+import (
+	"math"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/config/configtest"
+)
+
+func TestUnmarshalScraperConfig(t *testing.T) {
+	v := configtest.NewViperFromYamlFile(t, path.Join(".", "testdata", "scraper_durations.yaml"))
+
+	tests := []struct {
+		key        string
+		expected   ScraperSettings
+		deprecated []DeprecatedField
+	}{
+		{
+			key:      "string",
+			expected: ScraperSettings{CollectionIntervalVal: 30 * time.Second, TimeoutVal: time.Minute},
+		},
+		{
+			key:      "int",
+			expected: ScraperSettings{CollectionIntervalVal: 30 * time.Second},
+			deprecated: []DeprecatedField{
+				{Name: "initial_delay: 0", Replacement: "initial_delay: 0s"},
+				{Name: "collection_interval: 30", Replacement: "collection_interval: 30s"},
+			},
+		},
+		{
+			key:      "float",
+			expected: ScraperSettings{CollectionIntervalVal: 2500 * time.Millisecond, TimeoutVal: 250 * time.Millisecond},
+			deprecated: []DeprecatedField{
+				{Name: "timeout: 0.25", Replacement: "timeout: 250ms"},
+				{Name: "collection_interval: 2.5", Replacement: "collection_interval: 2.5s"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			cfg := &testScraperConfig{}
+			require.NoError(t, UnmarshalScraperConfig(v.Sub(test.key), cfg))
+			assert.Equal(t, "/proc", cfg.Path)
+			assert.Equal(t, test.expected.CollectionIntervalVal, cfg.CollectionIntervalVal)
+			assert.Equal(t, test.expected.TimeoutVal, cfg.TimeoutVal)
+			assert.Equal(t, test.expected.InitialDelayVal, cfg.InitialDelayVal)
+			assert.Equal(t, test.deprecated, cfg.registeredDeprecatedFields())
+		})
+	}
+}
+
+func TestUnmarshalScraperConfigInvalidDurations(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		err      string
+	}{
+		{
+			name:     "numeric string",
+			settings: map[string]interface{}{"collection_interval": "30"},
+			err:      `invalid collection_interval "30": ambiguous duration without a unit, use a duration such as "30s", or a number of seconds without quotes`,
+		},
+		{
+			name:     "negative number",
+			settings: map[string]interface{}{"timeout": -5},
+			err:      `invalid timeout -5: duration must not be negative`,
+		},
+		{
+			name:     "negative string",
+			settings: map[string]interface{}{"initial_delay": "-1m"},
+			err:      `invalid initial_delay "-1m": duration must not be negative`,
+		},
+		{
+			name:     "infinite",
+			settings: map[string]interface{}{"collection_interval": math.Inf(1)},
+			err:      `invalid collection_interval +Inf: number of seconds out of range`,
+		},
+		{
+			name:     "too large",
+			settings: map[string]interface{}{"collection_interval": 1e12},
+			err:      `invalid collection_interval 1e+12: number of seconds out of range`,
+		},
+		{
+			name:     "invalid string",
+			settings: map[string]interface{}{"timeout": "soon"},
+			err:      `invalid timeout "soon": time: invalid duration "soon"`,
+		},
+		{
+			name:     "unsupported type",
+			settings: map[string]interface{}{"timeout": []interface{}{1}},
+			err:      `invalid timeout [1]: unsupported duration of type []interface {}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := config.NewViper()
+			require.NoError(t, v.MergeConfigMap(test.settings))
+			assert.EqualError(t, UnmarshalScraperConfig(v, &testScraperConfig{}), test.err)
+		})
+	}
+}
+
+func TestUnmarshalScraperConfigUnknownField(t *testing.T) {
+	v := config.NewViper()
+	require.NoError(t, v.MergeConfigMap(map[string]interface{}{"collection_interval": 30, "unknown": true}))
+	assert.Error(t, UnmarshalScraperConfig(v, &testScraperConfig{}))
+	// the section is not modified
+	assert.Equal(t, 30, v.Get("collection_interval"))
+}
+
+func TestUnmarshalScraperConfigInvalidFailurePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		err      string
+	}{
+		{
+			name:     "unknown mode",
+			settings: map[string]interface{}{"mode": "retry"},
+			err:      `invalid on_failure: unknown mode "retry", must be one of keep_trying, backoff, disable_after or fatal`,
+		},
+		{
+			name:     "backoff without initial backoff",
+			settings: map[string]interface{}{"mode": "backoff", "max_backoff": "1m"},
+			err:      `invalid on_failure: initial_backoff must be positive in backoff mode`,
+		},
+		{
+			name:     "backoff with lower max backoff",
+			settings: map[string]interface{}{"mode": "backoff", "initial_backoff": "1m", "max_backoff": "30s"},
+			err:      `invalid on_failure: max_backoff must not be less than initial_backoff in backoff mode`,
+		},
+		{
+			name:     "disable_after without max failures",
+			settings: map[string]interface{}{"mode": "disable_after"},
+			err:      `invalid on_failure: max_failures must be positive in disable_after mode`,
+		},
+		{
+			name:     "fatal with negative max failures",
+			settings: map[string]interface{}{"mode": "fatal", "max_failures": -1},
+			err:      `invalid on_failure: max_failures must be positive in fatal mode`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := config.NewViper()
+			require.NoError(t, v.MergeConfigMap(map[string]interface{}{"on_failure": test.settings}))
+			assert.EqualError(t, UnmarshalScraperConfig(v, &testScraperConfig{}), test.err)
+		})
+	}
+}
+
+func TestUnmarshalScraperConfigStrict(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		err      string
+	}{
+		{
+			name:     "scraper settings",
+			settings: map[string]interface{}{"colletion_interval": "30s"},
+			err:      `unknown field "colletion_interval" in the configuration of scraper "disk", did you mean "collection_interval"?`,
+		},
+		{
+			name:     "receiver specific",
+			settings: map[string]interface{}{"pth": "/proc"},
+			err:      `unknown field "pth" in the configuration of scraper "disk", did you mean "path"?`,
+		},
+		{
+			name:     "nested",
+			settings: map[string]interface{}{"on_failure": map[string]interface{}{"mdoe": "backoff"}},
+			err:      `unknown field "on_failure.mdoe" in the configuration of scraper "disk", did you mean "on_failure.mode"?`,
+		},
+		{
+			name:     "no suggestion",
+			settings: map[string]interface{}{"partitions": []interface{}{"sda"}},
+			err:      `unknown field "partitions" in the configuration of scraper "disk"`,
+		},
+		{
+			name:     "multiple",
+			settings: map[string]interface{}{"pth": "/proc", "timeot": "1s"},
+			err:      `[unknown field "pth" in the configuration of scraper "disk", did you mean "path"?; unknown field "timeot" in the configuration of scraper "disk", did you mean "timeout"?]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := config.NewViper()
+			require.NoError(t, v.MergeConfigMap(test.settings))
+			assert.EqualError(t, UnmarshalScraperConfigStrict("disk", v, &testScraperConfig{}), test.err)
+		})
+	}
+}
+
+func TestUnmarshalScraperConfigStrictValid(t *testing.T) {
+	v := config.NewViper()
+	require.NoError(t, v.MergeConfigMap(map[string]interface{}{
+		"path":                "/proc",
+		"collection_interval": 30,
+		"resource_attributes": map[string]interface{}{"any.key": "value"},
+		"on_failure":          map[string]interface{}{"mode": "disable_after", "max_failures": 3},
+	}))
+	cfg := &testScraperConfig{}
+	require.NoError(t, UnmarshalScraperConfigStrict("disk", v, cfg))
+	assert.Equal(t, "/proc", cfg.Path)
+	assert.Equal(t, 30*time.Second, cfg.CollectionIntervalVal)
+	assert.Equal(t, map[string]string{"any.key": "value"}, cfg.ResourceAttributesVal)
+	assert.Equal(t, FailurePolicy{Mode: FailureDisableAfter, MaxFailures: 3}, cfg.OnFailureVal)
+}